@@ -0,0 +1,84 @@
+//go:build !cli && !daemon
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/user/gopherclaw/internal/config"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/logging"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func init() {
+	localChat = startLocalChat
+}
+
+// startLocalChat runs the runtime in-process for `gopherclaw chat` when no
+// daemon is reachable. Logs go only to the log file so they don't interleave
+// with the conversation.
+func startLocalChat(cfg *config.Config, key types.SessionKey) (chatSender, func(), error) {
+	parsed, err := key.Parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Simulate.Enabled {
+		simulateConfig(cfg)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("create data dir: %w", err)
+	}
+	f, err := logging.OpenFile(logFile(cfg))
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: logLevel(cfg)}))))
+
+	startedAt := time.Now()
+	c, err := newCore(cfg)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if leader := otherLeader(cfg.DataDir, ""); leader != "" {
+		slog.Info("a daemon holds the leader lease; leaving its runs alone", "leader", leader)
+	} else {
+		c.recover(startedAt, false)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.gw.Start(ctx)
+	cleanup := func() {
+		cancel()
+		c.gw.Stop()
+		c.close()
+		f.Close()
+	}
+
+	userID := parsed.Field("user")
+	send := func(text string) (*chatReply, error) {
+		done := make(chan *gateway.RunResult, 1)
+		if err := c.gw.HandleInbound(ctx, &types.InboundEvent{
+			Source:     "cli",
+			SessionKey: key,
+			UserID:     userID,
+			Text:       text,
+		}, gateway.WithOnResult(func(result *gateway.RunResult) {
+			done <- result
+		})); err != nil {
+			return nil, err
+		}
+		result := <-done
+		reply := &chatReply{SessionKey: key, Response: result.Text, RunID: result.RunID, Usage: result.Usage}
+		if result.Err != nil {
+			reply.Error = result.Err.Error()
+		}
+		return reply, nil
+	}
+	return send, cleanup, nil
+}
@@ -0,0 +1,97 @@
+//go:build !daemon
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	askSession string
+	askLocal   bool
+	askJSON    bool
+)
+
+func init() {
+	askCmd.Flags().StringVar(&askSession, "session-key", "", "session key (default cli:<your user name>)")
+	askCmd.Flags().BoolVar(&askLocal, "local", false, "run the prompt in-process instead of through the daemon")
+	askCmd.Flags().BoolVar(&askJSON, "json", false, "print the response, run ID and token usage as JSON")
+	rootCmd.AddCommand(askCmd)
+}
+
+var askCmd = &cobra.Command{
+	Use:   "ask [prompt]",
+	Short: "Send one prompt and print the response",
+	Long: `Send a single prompt through the gateway and runtime and print the
+response to stdout. Like chat, it goes to the running daemon over its HTTP
+API, or runs in-process with --local or when no daemon is reachable. The
+prompt is the arguments joined by spaces, or stdin when there are none or
+the only argument is "-". The command fails if the run fails, after
+printing the apology sent instead.`,
+	Example: `  gopherclaw ask "What's on my calendar today?"
+  git diff | gopherclaw ask --session-key cli:review --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+				return errors.New("give a prompt as arguments or pipe one on stdin")
+			}
+		}
+		prompt, err := askPrompt(args, os.Stdin)
+		if err != nil {
+			return err
+		}
+		cfg := loadConfig()
+		key, err := chatKey(askSession)
+		if err != nil {
+			return err
+		}
+		send, cleanup, _, err := connectChat(cfg, key, askLocal)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		reply, err := send(prompt)
+		if err != nil {
+			return err
+		}
+		if askJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(reply); err != nil {
+				return err
+			}
+		} else if reply.Response != "" {
+			fmt.Println(reply.Response)
+		}
+		if reply.Error != "" {
+			return fmt.Errorf("run failed: %s", reply.Error)
+		}
+		return nil
+	},
+}
+
+// askPrompt returns the prompt from the arguments, or from in when there
+// are none or the only one is "-".
+func askPrompt(args []string, in io.Reader) (string, error) {
+	prompt := strings.Join(args, " ")
+	if len(args) == 0 || prompt == "-" {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return "", fmt.Errorf("read prompt: %w", err)
+		}
+		prompt = string(data)
+	}
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return "", errors.New("prompt is empty")
+	}
+	return prompt, nil
+}
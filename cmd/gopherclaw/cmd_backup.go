@@ -0,0 +1,153 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/backup"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd, backupRestoreCmd)
+
+	backupCreateCmd.Flags().StringP("out", "o", "", `archive path, or "-" for stdout (default gopherclaw-backup-<time>.tar.gz[.age])`)
+	backupCreateCmd.Flags().StringArray("encrypt-to", nil, "encrypt to this age public key (repeatable)")
+	backupCreateCmd.Flags().StringArray("session", nil, "back up only this session, by ID or key (repeatable)")
+	backupRestoreCmd.Flags().StringArrayP("identity", "i", nil, "age identity file to decrypt with (repeatable)")
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore sessions",
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Write sessions, memory, tasks and macros to an archive",
+	Long: `Write a .tar.gz of session transcripts (index entries, events and
+artifacts) plus memory.md, tasks.json and macros.json. With --session only the
+named sessions are included. With --encrypt-to the archive is encrypted to the
+given age public keys, so it can be kept in untrusted storage; restoring it
+needs a matching identity. config.json is never included.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		out, _ := cmd.Flags().GetString("out")
+		keys, _ := cmd.Flags().GetStringArray("encrypt-to")
+		selected, _ := cmd.Flags().GetStringArray("session")
+
+		recipients, err := backup.ParseRecipients(keys)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		ids, err := resolveSessions(ctx, state.NewSessionStore(cfg.DataDir), selected)
+		if err != nil {
+			return err
+		}
+
+		if out == "" {
+			out = "gopherclaw-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+			if len(recipients) > 0 {
+				out += ".age"
+			}
+		}
+		var w io.Writer = os.Stdout
+		if out != "-" {
+			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return fmt.Errorf("create backup: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		manifest, err := backup.Create(ctx, w, cfg.DataDir, backup.Options{Sessions: ids, Recipients: recipients})
+		if err != nil {
+			if out != "-" {
+				os.Remove(out)
+			}
+			return err
+		}
+		if out != "-" {
+			encrypted := ""
+			if len(recipients) > 0 {
+				encrypted = fmt.Sprintf(", encrypted to %d recipient(s)", len(recipients))
+			}
+			fmt.Printf("Backed up %d sessions and %d files to %s%s.\n", len(manifest.Sessions), len(manifest.Files), out, encrypted)
+		}
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore sessions from a backup archive",
+	Long: `Restore a backup written by 'gopherclaw backup create' ("-" reads stdin).
+Sessions already in the data dir are skipped, and memory.md, tasks.json and
+macros.json are only written if missing. A restored session whose key is now
+used by another session comes back archived. Encrypted backups need
+--identity.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		paths, _ := cmd.Flags().GetStringArray("identity")
+		identities, err := backup.ReadIdentities(paths)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("open backup: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		result, err := backup.Restore(context.Background(), r, cfg.DataDir, identities)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %d sessions; skipped %d already present.\n", len(result.Restored), len(result.Skipped))
+		for _, name := range result.Files {
+			fmt.Printf("Restored %s.\n", name)
+		}
+		return nil
+	},
+}
+
+// resolveSessions maps session IDs or keys to session IDs.
+func resolveSessions(ctx context.Context, store *state.SessionStore, refs []string) ([]types.SessionID, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	all, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	ids := make([]types.SessionID, 0, len(refs))
+	for _, ref := range refs {
+		found := false
+		for _, sess := range all {
+			if string(sess.SessionID) == ref || string(sess.SessionKey) == ref {
+				ids = append(ids, sess.SessionID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("session not found: %s", ref)
+		}
+	}
+	return ids, nil
+}
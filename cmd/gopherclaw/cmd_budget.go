@@ -0,0 +1,105 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+	budgetCmd.AddCommand(budgetResetCmd)
+	budgetCmd.Flags().String("session", "", "also show this session's budgets")
+	budgetResetCmd.Flags().String("session", "", "reset only this session's budgets")
+}
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show spend against the configured budgets",
+	Long: `Show what the model has spent this day and month against the limits in
+the config's budget section: the global ones, and with --session those of
+one session. Once a budget is spent, the daemon refuses LLM calls until
+the period rolls over or "gopherclaw budget reset" starts it over.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		session, _ := cmd.Flags().GetString("session")
+		budget := spendBudget(cfg, modelRegistry(cfg))
+		if budget == nil {
+			fmt.Println("No budgets configured.")
+			return nil
+		}
+
+		ctx := context.Background()
+		sessions := state.NewSessionStore(cfg.DataDir)
+		if session != "" {
+			if _, err := sessions.Get(ctx, types.SessionID(session)); err != nil {
+				return fmt.Errorf("session %s: %w", session, err)
+			}
+		}
+		if err := budget.Load(ctx, sessions, state.NewEventStore(cfg.DataDir)); err != nil {
+			return fmt.Errorf("load spend: %w", err)
+		}
+
+		status := budget.Status(types.SessionID(session))
+		if len(status) == 0 {
+			fmt.Println("Only session budgets are configured; pass --session to see one.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "BUDGET\tSPENT\tLIMIT\tSINCE\tUNTIL")
+		for _, s := range status {
+			spent, limit := fmt.Sprintf("%d tokens", s.Tokens), fmt.Sprintf("%d tokens", s.LimitTokens)
+			if s.LimitUSD > 0 {
+				spent, limit = fmt.Sprintf("$%.4f", s.CostUSD), fmt.Sprintf("$%.2f", s.LimitUSD)
+				if s.LimitTokens > 0 {
+					spent += fmt.Sprintf(", %d tokens", s.Tokens)
+					limit += fmt.Sprintf(", %d tokens", s.LimitTokens)
+				}
+			}
+			name := s.Scope + " " + s.Period
+			if s.Exceeded() != "" {
+				name += " (spent)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, spent, limit, s.Since.Local().Format("2006-01-02 15:04"), s.Until.Local().Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var budgetResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Start the budgets over from now",
+	Long: `Start every budget over from now, or with --session only that session's,
+so the spend before it no longer counts. The running daemon picks the reset
+up on its next LLM call.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		session, _ := cmd.Flags().GetString("session")
+		if session != "" {
+			if _, err := state.NewSessionStore(cfg.DataDir).Get(context.Background(), types.SessionID(session)); err != nil {
+				return fmt.Errorf("session %s: %w", session, err)
+			}
+		}
+		store := state.NewBudgetStore(filepath.Join(cfg.DataDir, "budget.json"))
+		if err := store.Reset(types.SessionID(session), time.Now()); err != nil {
+			return fmt.Errorf("reset budget: %w", err)
+		}
+		if session != "" {
+			fmt.Printf("Budgets of session %s reset.\n", session)
+		} else {
+			fmt.Println("All budgets reset.")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,195 @@
+//go:build !daemon
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/config"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/webhook"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// chatTimeout bounds one exchange with the daemon, tool rounds included.
+const chatTimeout = 10 * time.Minute
+
+// chatReply is the outcome of one message in a chat session.
+type chatReply struct {
+	SessionKey types.SessionKey `json:"session_key"`
+	Response   string           `json:"response"`
+	RunID      types.RunID      `json:"run_id,omitempty"`
+	Usage      llm.Usage        `json:"usage"`
+	// Error is why the run failed; Response is then the apology.
+	Error string `json:"error,omitempty"`
+}
+
+// chatSender sends one message in a chat session and returns the reply.
+type chatSender func(text string) (*chatReply, error)
+
+// localChat starts an in-process runtime for the session and returns its
+// sender and a cleanup function. It is nil in builds without the runtime
+// (-tags cli).
+var localChat func(cfg *config.Config, key types.SessionKey) (chatSender, func(), error)
+
+var (
+	chatSession string
+	chatLocal   bool
+)
+
+func init() {
+	chatCmd.Flags().StringVar(&chatSession, "session", "", "session key (default cli:<your user name>)")
+	chatCmd.Flags().BoolVar(&chatLocal, "local", false, "run the conversation in-process instead of through the daemon")
+	rootCmd.AddCommand(chatCmd)
+}
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat with the assistant in the terminal",
+	Long: `Start an interactive conversation in the terminal. Messages go to the running
+daemon over its HTTP API (http.listen, using http.admin_token) and are
+recorded in the session like a Telegram conversation. Without a reachable
+daemon, or with --local, the runtime runs in-process against the same data
+directory. Type /quit or press Ctrl-D to leave.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		key, err := chatKey(chatSession)
+		if err != nil {
+			return err
+		}
+
+		send, cleanup, remote, err := connectChat(cfg, key, chatLocal)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		if remote {
+			fmt.Printf("Connected to the daemon. Session %s.\n", key)
+		} else {
+			fmt.Printf("Running locally (model %s). Session %s.\n", cfg.LLM.Model, key)
+		}
+		fmt.Println("Type /quit or press Ctrl-D to leave.")
+		return chatLoop(os.Stdin, os.Stdout, send)
+	},
+}
+
+// connectChat returns a sender for the session and a cleanup function. It
+// goes through the daemon (remote is true) unless local is set or no
+// daemon is reachable, then runs the runtime in-process.
+func connectChat(cfg *config.Config, key types.SessionKey, local bool) (send chatSender, cleanup func(), remote bool, err error) {
+	switch {
+	case !local && daemonReachable(cfg):
+		return remoteChat(cfg, key), func() {}, true, nil
+	case localChat == nil:
+		return nil, nil, false, fmt.Errorf("no running daemon at %s, and this build can't run the assistant in-process", cfg.HTTP.Listen)
+	}
+	if _, err := readPID(); err == nil {
+		return nil, nil, false, fmt.Errorf("the daemon is running but its HTTP API is unreachable; enable http to reach it, or stop it to run locally")
+	}
+	send, cleanup, err = localChat(cfg, key)
+	return send, cleanup, false, err
+}
+
+// chatKey returns the session key for --session, defaulting to the
+// current user's cli: session.
+func chatKey(session string) (types.SessionKey, error) {
+	if session == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", fmt.Errorf("look up current user: %w", err)
+		}
+		session = u.Username
+	}
+	key := types.SessionKey(session)
+	if key.Channel() != "cli" {
+		key = types.CLIKey(session)
+	}
+	if _, err := key.Parse(); err != nil {
+		return "", fmt.Errorf("--session: %w", err)
+	}
+	return key, nil
+}
+
+// chatLoop reads messages from in until EOF or /quit and prints replies.
+func chatLoop(in io.Reader, out io.Writer, send chatSender) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for {
+		fmt.Fprint(out, "you> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		text := strings.TrimSpace(scanner.Text())
+		switch text {
+		case "":
+			continue
+		case "/quit", "/exit":
+			return nil
+		}
+		reply, err := send(text)
+		switch {
+		case err != nil:
+			fmt.Fprintf(out, "error: %v\n", err)
+		case reply.Response == "":
+			fmt.Fprintln(out, "(no reply)")
+		default:
+			fmt.Fprintf(out, "gopherclaw> %s\n", reply.Response)
+		}
+	}
+}
+
+// daemonReachable reports whether the daemon's HTTP API answers /health.
+func daemonReachable(cfg *config.Config) bool {
+	if !cfg.HTTP.Enabled {
+		return false
+	}
+	client, base := webhook.NewClient(cfg.HTTP.Listen, time.Second)
+	resp, err := client.Get(base + "/health")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// remoteChat sends messages through the daemon's POST /api/chat.
+func remoteChat(cfg *config.Config, key types.SessionKey) chatSender {
+	client, base := webhook.NewClient(cfg.HTTP.Listen, chatTimeout)
+	return func(text string) (*chatReply, error) {
+		body, _ := json.Marshal(map[string]string{"session_key": string(key), "text": text})
+		req, err := http.NewRequest(http.MethodPost, base+"/api/chat", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("build request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.HTTP.AdminToken != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.HTTP.AdminToken)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("send to daemon: %w", err)
+		}
+		defer resp.Body.Close()
+
+		reply := &chatReply{SessionKey: key}
+		if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+			return nil, fmt.Errorf("daemon: %s", resp.Status)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("daemon: %s", strings.TrimSpace(reply.Error+" ("+resp.Status+")"))
+		}
+		return reply, nil
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/feedback"
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func init() {
+	rootCmd.AddCommand(feedbackCmd)
+	feedbackCmd.AddCommand(feedbackStatsCmd)
+}
+
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Inspect user feedback on responses",
+}
+
+var feedbackStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show good/bad ratings per model",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		sessions := state.NewSessionStore(cfg.DataDir)
+		events := state.NewEventStore(cfg.DataDir)
+
+		stats, err := feedback.Collect(context.Background(), sessions, events)
+		if err != nil {
+			return fmt.Errorf("collect feedback: %w", err)
+		}
+		if len(stats) == 0 {
+			fmt.Println("No feedback recorded.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tGOOD\tBAD\tSCORE")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.0f%%\n", s.Model, s.Good, s.Bad, s.Score*100)
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,108 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/importer"
+	"github.com/user/gopherclaw/internal/runtime/tools"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().String("from", "", "export format: "+strings.Join(importer.Formats, ", "))
+	importCmd.Flags().Bool("memories", false, "extract long-term memories with the configured model")
+	importCmd.Flags().Bool("dry-run", false, "parse the export and report what would be imported")
+	_ = importCmd.MarkFlagRequired("from")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import conversation history exported from another assistant",
+	Long: `Import conversations from a ChatGPT or Claude data export (the .zip or its
+conversations.json), or from OpenAI chat-format JSON/JSONL (openai-export).
+Each conversation becomes an archived session keyed import:<format>:<id>;
+conversations imported before are skipped. With --memories, the configured
+model reads each new conversation and saves durable facts about you to
+memory.md.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		format, _ := cmd.Flags().GetString("from")
+		withMemories, _ := cmd.Flags().GetBool("memories")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		convs, err := importer.ReadFile(format, args[0])
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			messages := 0
+			for _, c := range convs {
+				messages += len(c.Messages)
+			}
+			fmt.Printf("Found %d conversations (%d messages) in %s.\n", len(convs), messages, args[0])
+			return nil
+		}
+
+		ctx := context.Background()
+		sessions := state.NewSessionStore(cfg.DataDir)
+		result, err := importer.Import(ctx, sessions, state.NewEventStore(cfg.DataDir), format, convs)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d conversations (%d messages); skipped %d empty or already imported.\n",
+			len(result.Sessions), result.Messages, result.Skipped)
+		if !withMemories || len(result.Sessions) == 0 {
+			return nil
+		}
+
+		provider, err := newProvider(cfg, &llm.Config{
+			BaseURL:    cfg.LLM.BaseURL,
+			APIKey:     cfg.LLM.APIKey,
+			Model:      cfg.LLM.Model,
+			MaxTokens:  cfg.LLM.MaxTokens,
+			MaxRetries: cfg.LLM.RateLimitRetries,
+		})
+		if err != nil {
+			return err
+		}
+		imported := make(map[string]bool, len(result.Sessions))
+		for _, id := range result.Sessions {
+			if sess, err := sessions.Get(ctx, id); err == nil {
+				imported[string(sess.SessionKey)] = true
+			}
+		}
+		save := tools.NewMemorySave(filepath.Join(cfg.DataDir, "memory.md"))
+		saved := 0
+		for _, conv := range convs {
+			if !imported[string(importer.SessionKey(format, conv.ID))] {
+				continue
+			}
+			facts, err := importer.ExtractMemories(ctx, provider, conv)
+			if err != nil {
+				return fmt.Errorf("conversation %q: %w", conv.Title, err)
+			}
+			for _, fact := range facts {
+				args, _ := json.Marshal(map[string]string{"content": fact})
+				out, err := save.Execute(ctx, args)
+				if err != nil {
+					return fmt.Errorf("save memory: %w", err)
+				}
+				if !strings.HasPrefix(out, "Memory already exists") {
+					saved++
+				}
+			}
+		}
+		fmt.Printf("Saved %d memories to memory.md.\n", saved)
+		return nil
+	},
+}
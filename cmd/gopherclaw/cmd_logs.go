@@ -0,0 +1,111 @@
+//go:build !daemon
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/logging"
+)
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().String("run", "", "only lines logged during this run")
+	logsCmd.Flags().String("session", "", "only lines logged during runs of this session")
+	logsCmd.Flags().String("level", "", "lowest level to show (debug, info, warn, error)")
+	logsCmd.Flags().Duration("since", 0, "only lines from this long ago (e.g. 2h)")
+	logsCmd.Flags().Bool("json", false, "print the raw JSON lines")
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Search the daemon log",
+	Long: `Search the daemon's JSON log (log_file, default data_dir/logs/gopherclaw.log,
+and its rotated predecessor). Lines logged while a run was processing carry
+its run_id and session_id, so --run shows everything one conversation turn did.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		var f logging.Filter
+		f.RunID, _ = cmd.Flags().GetString("run")
+		f.SessionID, _ = cmd.Flags().GetString("session")
+		if level, _ := cmd.Flags().GetString("level"); level != "" {
+			if err := f.Level.UnmarshalText([]byte(level)); err != nil {
+				return fmt.Errorf("invalid --level %q", level)
+			}
+		} else {
+			f.Level = slog.LevelDebug
+		}
+		if since, _ := cmd.Flags().GetDuration("since"); since > 0 {
+			f.Since = time.Now().Add(-since)
+		}
+		raw, _ := cmd.Flags().GetBool("json")
+
+		path := logFile(cfg)
+		found := 0
+		for _, p := range []string{path + ".1", path} {
+			file, err := os.Open(p)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return fmt.Errorf("open log: %w", err)
+			}
+			err = logging.Scan(file, f, func(r logging.Record) {
+				found++
+				if raw {
+					line, _ := json.Marshal(r)
+					fmt.Println(string(line))
+					return
+				}
+				fmt.Println(formatLogRecord(r))
+			})
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if found == 0 {
+			fmt.Fprintf(os.Stderr, "No matching log lines in %s.\n", path)
+		}
+		return nil
+	},
+}
+
+// formatLogRecord renders a record as "time LEVEL message key=value ..."
+// with the attributes sorted by key.
+func formatLogRecord(r logging.Record) string {
+	var b strings.Builder
+	b.WriteString(r.Time().Local().Format("2006-01-02 15:04:05.000"))
+	fmt.Fprintf(&b, " %-5s %s", r.String(slog.LevelKey), r.String(slog.MessageKey))
+
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		switch k {
+		case slog.TimeKey, slog.LevelKey, slog.MessageKey:
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := r[k]
+		if s, ok := v.(string); ok {
+			if strings.ContainsAny(s, " \t\n\"=") {
+				v = fmt.Sprintf("%q", s)
+			}
+			fmt.Fprintf(&b, " %s=%v", k, v)
+			continue
+		}
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(&b, " %s=%s", k, data)
+	}
+	return b.String()
+}
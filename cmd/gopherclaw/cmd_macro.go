@@ -0,0 +1,128 @@
+//go:build !daemon
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func init() {
+	rootCmd.AddCommand(macroCmd)
+	macroCmd.AddCommand(macroAddCmd, macroListCmd, macroShowCmd, macroRemoveCmd)
+
+	macroAddCmd.Flags().String("description", "", "one-line description shown in listings")
+	macroAddCmd.Flags().StringArray("default", nil, "default argument value as key=value (repeatable)")
+}
+
+func macroStore() *state.MacroStore {
+	cfg := loadConfig()
+	return state.NewMacroStore(filepath.Join(cfg.DataDir, "macros.json"))
+}
+
+var macroCmd = &cobra.Command{
+	Use:   "macro",
+	Short: "Manage prompt macros",
+}
+
+var macroAddCmd = &cobra.Command{
+	Use:   "add <name> <template>",
+	Short: "Add or replace a macro",
+	Long: `Add or replace a macro. The template uses Go text/template syntax with
+the invocation's key=value arguments as data, e.g.
+
+  gopherclaw macro add report 'Write the status report for week {{.week}}. {{.text}}' --default text=
+
+and is invoked as "/m report week=12" in Telegram.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		description, _ := cmd.Flags().GetString("description")
+		defaults, _ := cmd.Flags().GetStringArray("default")
+
+		macro := &state.Macro{Name: args[0], Template: args[1], Description: description}
+		for _, d := range defaults {
+			key, value, ok := strings.Cut(d, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid default %q: want key=value", d)
+			}
+			if macro.Defaults == nil {
+				macro.Defaults = make(map[string]string)
+			}
+			macro.Defaults[key] = value
+		}
+		if err := macroStore().Put(macro); err != nil {
+			return fmt.Errorf("add macro: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Macro %q saved.\n", macro.Name)
+		return nil
+	},
+}
+
+var macroListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all macros",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		macros, err := macroStore().List()
+		if err != nil {
+			return fmt.Errorf("list macros: %w", err)
+		}
+		if len(macros) == 0 {
+			fmt.Println("No macros configured.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION")
+		for _, m := range macros {
+			fmt.Fprintf(w, "%s\t%s\n", m.Name, m.Description)
+		}
+		return w.Flush()
+	},
+}
+
+var macroShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a macro's template and defaults",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		macro, err := macroStore().Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Name:        %s\n", macro.Name)
+		if macro.Description != "" {
+			fmt.Printf("Description: %s\n", macro.Description)
+		}
+		keys := make([]string, 0, len(macro.Defaults))
+		for k := range macro.Defaults {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("Default:     %s=%s\n", k, macro.Defaults[k])
+		}
+		fmt.Printf("Template:\n%s\n", macro.Template)
+		return nil
+	},
+}
+
+var macroRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a macro",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := macroStore().Remove(args[0]); err != nil {
+			return fmt.Errorf("remove macro: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Macro %q removed.\n", args[0])
+		return nil
+	},
+}
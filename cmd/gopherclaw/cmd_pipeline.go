@@ -0,0 +1,79 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/pipeline"
+)
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.AddCommand(pipelineListCmd, pipelineCheckCmd)
+	pipelineCheckCmd.Flags().String("task", "", "task name passed to the pipeline as {{.task}}")
+}
+
+func pipelineStore() *pipeline.Store {
+	cfg := loadConfig()
+	return pipeline.NewStore(filepath.Join(cfg.DataDir, "pipelines"))
+}
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Manage pipelines",
+	Long: `Pipelines are YAML files in the data dir's pipelines/ directory. They wrap a
+scheduled task's LLM call with deterministic steps: shell commands, HTTP
+fetches and templates that gather inputs before the prompt, and steps that act
+on the response afterwards. Attach one with 'gopherclaw task add --pipeline'.`,
+}
+
+var pipelineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pipelines",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := pipelineStore()
+		names, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("No pipelines defined.")
+			return nil
+		}
+		for _, name := range names {
+			p, err := store.Get(name)
+			if err != nil {
+				fmt.Printf("%s\tinvalid: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("%s\t%s\n", name, p.Description)
+		}
+		return nil
+	},
+}
+
+var pipelineCheckCmd = &cobra.Command{
+	Use:   "check <name>",
+	Short: "Run a pipeline's pre-LLM steps and print the prompt",
+	Long: `Validate a pipeline, run the steps before its LLM call and print the prompt
+it would send. The model is not called and post steps do not run.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		task, _ := cmd.Flags().GetString("task")
+		p, err := pipelineStore().Get(args[0])
+		if err != nil {
+			return err
+		}
+		prompt, err := p.Prepare(context.Background(), task)
+		if err != nil {
+			return err
+		}
+		fmt.Println(prompt)
+		return nil
+	},
+}
@@ -0,0 +1,85 @@
+//go:build !daemon
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.AddCommand(runListCmd)
+
+	runListCmd.Flags().String("session", "", "only runs of this session ID")
+	runListCmd.Flags().String("status", "", "only runs with this status (queued, running, complete, failed, interrupted)")
+	runListCmd.Flags().Int("limit", 20, "number of most recent runs to show")
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Inspect run records",
+}
+
+var runListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent runs, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		var filter state.RunFilter
+		sessionID, _ := cmd.Flags().GetString("session")
+		filter.SessionID = types.SessionID(sessionID)
+		filter.Status, _ = cmd.Flags().GetString("status")
+		filter.Limit, _ = cmd.Flags().GetInt("limit")
+
+		runs, err := state.NewRunStore(filepath.Join(cfg.DataDir, "runs.jsonl")).List(filter)
+		if err != nil {
+			return fmt.Errorf("list runs: %w", err)
+		}
+		if len(runs) == 0 {
+			fmt.Println("No runs found.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSESSION\tSTATUS\tCREATED\tDURATION\tTOKENS\tROUNDS\tERROR")
+		for _, r := range runs {
+			errText := r.Error
+			if len(errText) > 60 {
+				errText = errText[:60] + "…"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+				r.ID,
+				r.SessionKey,
+				r.Status,
+				r.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+				runDuration(r),
+				r.InputTokens+r.OutputTokens,
+				r.ToolRounds,
+				errText,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+// runDuration is how long a run took, or has taken so far, since it
+// started; "-" if it never started.
+func runDuration(r *types.RunRecord) string {
+	if r.StartedAt == nil {
+		return "-"
+	}
+	end := time.Now()
+	if r.EndedAt != nil {
+		end = *r.EndedAt
+	}
+	return end.Sub(*r.StartedAt).Round(100 * time.Millisecond).String()
+}
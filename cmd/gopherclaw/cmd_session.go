@@ -1,7 +1,10 @@
+//go:build !daemon
+
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,12 +12,24 @@ import (
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/gateway"
 	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 func init() {
 	rootCmd.AddCommand(sessionCmd)
-	sessionCmd.AddCommand(sessionListCmd, sessionClearCmd)
+	sessionCmd.AddCommand(sessionListCmd, sessionShowCmd, sessionClearCmd, sessionLockCmd, sessionUnlockCmd)
+
+	sessionListCmd.Flags().String("status", "", "only sessions with this status: active or archived")
+	sessionListCmd.Flags().String("channel", "", "only sessions of this channel, e.g. telegram")
+	sessionListCmd.Flags().String("key", "", "only sessions of this session key, including those archived from it")
+	sessionListCmd.Flags().Bool("archived", false, "show archived sessions with the key each was archived from (same as --status archived)")
+	sessionLockCmd.Flags().String("reason", "", "reason shown to senders while locked")
+	sessionShowCmd.Flags().Int("limit", 100, "number of most recent events to show")
+	sessionShowCmd.Flags().Bool("reasoning", false, "include model reasoning traces")
+	sessionShowCmd.Flags().Bool("usage", false, "include the token usage of each LLM call")
 }
 
 var sessionCmd = &cobra.Command{
@@ -24,15 +39,32 @@ var sessionCmd = &cobra.Command{
 
 var sessionListCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List all sessions",
-	Args:  cobra.NoArgs,
+	Short: "List sessions",
+	Long: `List sessions, optionally filtered by status, channel or key. Starting a
+new conversation (/new in Telegram, or the idle timeout) archives the old
+session under "archived:<id>" with its history intact; --archived lists
+those with the key each was archived from, and --key matches them by it.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := loadConfig()
 		sessions := state.NewSessionStore(cfg.DataDir)
 		events := state.NewEventStore(cfg.DataDir)
 
+		var filter types.SessionFilter
+		filter.Status, _ = cmd.Flags().GetString("status")
+		filter.Channel, _ = cmd.Flags().GetString("channel")
+		key, _ := cmd.Flags().GetString("key")
+		filter.Key = types.SessionKey(key)
+		archived, _ := cmd.Flags().GetBool("archived")
+		if archived {
+			filter.Status = types.SessionArchived
+		}
+		if filter.Status != "" && filter.Status != types.SessionActive && filter.Status != types.SessionArchived {
+			return fmt.Errorf("--status must be active or archived, not %q", filter.Status)
+		}
+
 		ctx := context.Background()
-		list, err := sessions.List(ctx)
+		list, err := sessions.ListFiltered(ctx, filter)
 		if err != nil {
 			return fmt.Errorf("list sessions: %w", err)
 		}
@@ -43,16 +75,38 @@ var sessionListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tKEY\tSTATUS\tMESSAGES\tCREATED")
+		if archived {
+			fmt.Fprintln(w, "ID\tARCHIVED FROM\tMESSAGES\tCREATED\tARCHIVED")
+		} else {
+			fmt.Fprintln(w, "ID\tKEY\tSTATUS\tMESSAGES\tCREATED")
+		}
 		for _, s := range list {
 			count, err := events.Count(ctx, s.SessionID)
 			if err != nil {
 				count = 0
 			}
+			if archived {
+				at := "-"
+				if s.ArchivedAt != nil {
+					at = s.ArchivedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+					s.SessionID,
+					s.OriginKey(),
+					count,
+					s.CreatedAt.Format("2006-01-02 15:04:05"),
+					at,
+				)
+				continue
+			}
+			status := s.Status
+			if s.Locked {
+				status += " (locked)"
+			}
 			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
 				s.SessionID,
 				s.SessionKey,
-				s.Status,
+				status,
 				count,
 				s.CreatedAt.Format("2006-01-02 15:04:05"),
 			)
@@ -61,6 +115,103 @@ var sessionListCmd = &cobra.Command{
 	},
 }
 
+var sessionShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print a session's event history",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		limit, _ := cmd.Flags().GetInt("limit")
+		reasoning, _ := cmd.Flags().GetBool("reasoning")
+		showUsage, _ := cmd.Flags().GetBool("usage")
+
+		ctx := context.Background()
+		sessions := state.NewSessionStore(cfg.DataDir)
+		sess, err := sessions.Get(ctx, types.SessionID(args[0]))
+		if err != nil {
+			return fmt.Errorf("get session: %w", err)
+		}
+		events, err := state.NewEventStore(cfg.DataDir).Tail(ctx, sess.SessionID, limit)
+		if err != nil {
+			return fmt.Errorf("load events: %w", err)
+		}
+
+		fmt.Printf("Session %s (%s, %s)\n\n", sess.SessionID, sess.SessionKey, sess.Status)
+		for _, ev := range events {
+			if ev.Type == "reasoning" && !reasoning || ev.Type == "usage" && !showUsage {
+				continue
+			}
+			fmt.Printf("%s  %s\n", ev.At.Format("2006-01-02 15:04:05"), formatEvent(ev))
+		}
+		return nil
+	},
+}
+
+// formatEvent renders an event as a single transcript entry.
+func formatEvent(ev *types.Event) string {
+	var p struct {
+		Text      string          `json:"text"`
+		Tool      string          `json:"tool"`
+		Arguments json.RawMessage `json:"arguments"`
+		Result    string          `json:"result"`
+		Message   string          `json:"message"`
+		Reason    string          `json:"reason"`
+		Approved  bool            `json:"approved"`
+		Purpose   string          `json:"purpose"`
+		Model     string          `json:"model"`
+		Input     int             `json:"input_tokens"`
+		Output    int             `json:"output_tokens"`
+		Backend   string          `json:"backend"`
+		Failed    []llm.Attempt   `json:"failed"`
+	}
+	json.Unmarshal(ev.Payload, &p)
+
+	switch ev.Type {
+	case "user_message":
+		return "user: " + p.Text
+	case "assistant_message":
+		return "assistant: " + p.Text
+	case "reasoning":
+		return "reasoning: " + p.Text
+	case "tool_call":
+		return fmt.Sprintf("tool_call: %s %s", p.Tool, p.Arguments)
+	case "tool_result":
+		result := p.Result
+		if len(result) > 200 {
+			result = result[:200] + "…"
+		}
+		return fmt.Sprintf("tool_result: %s: %s", p.Tool, result)
+	case "error":
+		return "error: " + p.Message
+	case "timeout":
+		return "timeout: " + p.Message
+	case "approval_request":
+		return fmt.Sprintf("approval_request: %s %s", p.Tool, p.Arguments)
+	case "approval":
+		if p.Approved {
+			return "approval: " + p.Tool + " approved"
+		}
+		return fmt.Sprintf("approval: %s denied (%s)", p.Tool, p.Reason)
+	case "no_reply":
+		return "no_reply: " + p.Reason
+	case "usage":
+		line := fmt.Sprintf("usage: %s %s, %d in, %d out", p.Purpose, p.Model, p.Input, p.Output)
+		if p.Backend != "" {
+			line += " via " + p.Backend
+			for _, f := range p.Failed {
+				line += fmt.Sprintf(" (%s failed: %s)", f.Backend, f.Error)
+			}
+		}
+		return line
+	case "session_summary":
+		return "summary: " + p.Text
+	case "conversation_summary":
+		return "conversation summary: " + p.Text
+	default:
+		return fmt.Sprintf("%s: %s", ev.Type, ev.Payload)
+	}
+}
+
 var sessionClearCmd = &cobra.Command{
 	Use:   "clear <id|all>",
 	Short: "Clear a session or all sessions",
@@ -97,3 +248,34 @@ var sessionClearCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var sessionLockCmd = &cobra.Command{
+	Use:   "lock <id>",
+	Short: "Freeze a session so it accepts no new runs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		reason, _ := cmd.Flags().GetString("reason")
+		sessions := state.NewSessionStore(cfg.DataDir)
+		if err := gateway.SetLocked(context.Background(), sessions, types.SessionID(args[0]), true, reason); err != nil {
+			return fmt.Errorf("lock session: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Session %s locked.\n", args[0])
+		return nil
+	},
+}
+
+var sessionUnlockCmd = &cobra.Command{
+	Use:   "unlock <id>",
+	Short: "Unfreeze a locked session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		sessions := state.NewSessionStore(cfg.DataDir)
+		if err := gateway.SetLocked(context.Background(), sessions, types.SessionID(args[0]), false, ""); err != nil {
+			return fmt.Errorf("unlock session: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Session %s unlocked.\n", args[0])
+		return nil
+	},
+}
@@ -1,3 +1,5 @@
+//go:build !daemon
+
 package main
 
 import (
@@ -9,6 +11,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/user/gopherclaw/internal/config"
+	"github.com/user/gopherclaw/pkg/llm/anthropic"
 )
 
 func init() {
@@ -27,25 +30,31 @@ var setupCmd = &cobra.Command{
 		fmt.Println("Press Enter to accept the default value shown in brackets.")
 		fmt.Println()
 
-		// 1. LLM base URL
+		// 1. LLM provider
+		cfg.LLM.Provider = prompt(scanner, "LLM provider (openai or anthropic)", cfg.LLM.Provider)
+		if cfg.LLM.Provider == "anthropic" && cfg.LLM.BaseURL == config.DefaultOpenAIBaseURL {
+			cfg.LLM.BaseURL = anthropic.DefaultBaseURL
+		}
+
+		// 2. LLM base URL
 		cfg.LLM.BaseURL = prompt(scanner, "LLM base URL", cfg.LLM.BaseURL)
 
-		// 2. LLM API key
+		// 3. LLM API key
 		cfg.LLM.APIKey = prompt(scanner, "LLM API key", cfg.LLM.APIKey)
 
-		// 3. LLM model name
+		// 4. LLM model name
 		cfg.LLM.Model = prompt(scanner, "LLM model name", cfg.LLM.Model)
 
-		// 4. Max output tokens
+		// 5. Max output tokens
 		maxTokensStr := prompt(scanner, "Max output tokens", strconv.Itoa(cfg.LLM.MaxTokens))
 		if n, err := strconv.Atoi(maxTokensStr); err == nil {
 			cfg.LLM.MaxTokens = n
 		}
 
-		// 5. Telegram bot token (optional)
+		// 6. Telegram bot token (optional)
 		cfg.Telegram.Token = prompt(scanner, "Telegram bot token (optional)", cfg.Telegram.Token)
 
-		// 6. Brave API key (optional)
+		// 7. Brave API key (optional)
 		cfg.Brave.APIKey = prompt(scanner, "Brave API key (optional)", cfg.Brave.APIKey)
 
 		if err := config.Save(cfgPath, cfg); err != nil {
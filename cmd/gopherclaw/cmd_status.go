@@ -0,0 +1,78 @@
+//go:build !daemon
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/webhook"
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the running daemon's status",
+	Long: `Ask the running daemon for its uptime and scheduler state over its HTTP API
+(http.listen, typically a unix socket), using http.admin_token or
+http.observer_token.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, err := daemonStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Running since %s (up %s).\n",
+			status.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			(time.Duration(status.UptimeSeconds) * time.Second).String())
+		if status.Scheduler == nil {
+			fmt.Println("Scheduler: not running on this instance.")
+			return nil
+		}
+		failed := 0
+		for _, e := range status.Scheduler {
+			if e.Error != "" || (e.LastRun != nil && e.LastRun.Error != "") {
+				failed++
+			}
+		}
+		fmt.Printf("Scheduler: %d tasks loaded, %d failing.\n", len(status.Scheduler), failed)
+		return nil
+	},
+}
+
+// daemonStatus fetches /api/admin/status from the running daemon.
+func daemonStatus() (*webhook.AdminStatus, error) {
+	cfg := loadConfig()
+	if !cfg.HTTP.Enabled {
+		return nil, fmt.Errorf("daemon HTTP API is disabled (set http.enabled)")
+	}
+	client, base := webhook.NewClient(cfg.HTTP.Listen, time.Second)
+	req, err := http.NewRequest(http.MethodGet, base+"/api/admin/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if token := cmp.Or(cfg.HTTP.AdminToken, cfg.HTTP.ObserverToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no running daemon at %s: %w", cfg.HTTP.Listen, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon status: %s", resp.Status)
+	}
+
+	var status webhook.AdminStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("decode daemon status: %w", err)
+	}
+	return &status, nil
+}
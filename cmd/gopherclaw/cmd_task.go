@@ -1,13 +1,22 @@
+//go:build !daemon
+
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/scheduler"
 	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
 )
 
 func init() {
@@ -15,11 +24,21 @@ func init() {
 	taskCmd.AddCommand(taskAddCmd, taskListCmd, taskRemoveCmd, taskEnableCmd, taskDisableCmd)
 
 	taskAddCmd.Flags().String("name", "", "task name (required)")
-	taskAddCmd.Flags().String("prompt", "", "prompt text (required)")
+	taskAddCmd.Flags().String("prompt", "", "prompt text (required unless --pipeline is set)")
 	taskAddCmd.Flags().String("schedule", "", "cron schedule expression")
+	taskAddCmd.Flags().String("catch-up", state.CatchUpSkip, "what to do about fires missed while the daemon was down: skip, run-once-on-start or run-all-missed")
+	taskAddCmd.Flags().String("timezone", "", "IANA time zone the schedule fires in, e.g. Europe/Oslo (default scheduler.timezone)")
 	taskAddCmd.Flags().String("session-key", "", "session key (required)")
+	taskAddCmd.Flags().StringArray("deliver-to", nil, "deliver scheduled responses to this key instead of the session key (repeatable)")
+	taskAddCmd.Flags().Int("concurrency", 0, "max parallel runs in the task's session (default 1)")
+	taskAddCmd.Flags().String("payload-template", "", "Go template rendered with a webhook's JSON body to build the prompt")
+	taskAddCmd.Flags().Bool("expect-nonempty", false, "alert admins when a scheduled run returns an empty response")
+	taskAddCmd.Flags().String("expect-contains", "", "alert admins when a scheduled run's response lacks this text")
+	taskAddCmd.Flags().String("expect-schema", "", "alert admins unless a scheduled run returns JSON matching this schema (inline JSON or @file)")
+	taskAddCmd.Flags().String("delivery", "", `per-channel delivery templates, e.g. {"telegram": {"emoji": "🚨", "max_length": 300}} (inline JSON or @file)`)
+	taskAddCmd.Flags().Bool("dedupe", false, "skip delivering a scheduled response identical to the previous run's")
+	taskAddCmd.Flags().String("pipeline", "", "run scheduled triggers through this pipeline from the data dir's pipelines/")
 	_ = taskAddCmd.MarkFlagRequired("name")
-	_ = taskAddCmd.MarkFlagRequired("prompt")
 	_ = taskAddCmd.MarkFlagRequired("session-key")
 }
 
@@ -41,15 +60,75 @@ var taskAddCmd = &cobra.Command{
 		name, _ := cmd.Flags().GetString("name")
 		prompt, _ := cmd.Flags().GetString("prompt")
 		schedule, _ := cmd.Flags().GetString("schedule")
+		timezone, _ := cmd.Flags().GetString("timezone")
+		catchUp, _ := cmd.Flags().GetString("catch-up")
 		sessionKey, _ := cmd.Flags().GetString("session-key")
+		deliverTo, _ := cmd.Flags().GetStringArray("deliver-to")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		payloadTemplate, _ := cmd.Flags().GetString("payload-template")
+		pipelineName, _ := cmd.Flags().GetString("pipeline")
+		dedupe, _ := cmd.Flags().GetBool("dedupe")
+		if prompt == "" && pipelineName == "" {
+			return fmt.Errorf("--prompt is required unless --pipeline is set")
+		}
+		if _, err := types.ParseSessionKey(sessionKey); err != nil {
+			return fmt.Errorf("--session-key: %w", err)
+		}
+		for _, target := range deliverTo {
+			if _, err := types.ParseSessionKey(target); err != nil {
+				return fmt.Errorf("--deliver-to: %w", err)
+			}
+		}
+		switch catchUp {
+		case state.CatchUpSkip, state.CatchUpOnce, state.CatchUpAll:
+		default:
+			return fmt.Errorf("--catch-up must be %s, %s or %s", state.CatchUpSkip, state.CatchUpOnce, state.CatchUpAll)
+		}
+		if catchUp == state.CatchUpSkip {
+			catchUp = ""
+		}
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return fmt.Errorf("--timezone: %w", err)
+			}
+		}
+		if pipelineName != "" {
+			if _, err := pipelineStore().Get(pipelineName); err != nil {
+				return err
+			}
+		}
+		if payloadTemplate != "" {
+			if _, err := template.New("payload").Parse(payloadTemplate); err != nil {
+				return fmt.Errorf("parse payload template: %w", err)
+			}
+		}
+
+		expect, err := taskExpect(cmd)
+		if err != nil {
+			return err
+		}
+
+		templates, err := deliveryTemplates(cmd)
+		if err != nil {
+			return err
+		}
 
 		store := taskStore()
 		task := &state.Task{
-			Name:       name,
-			Prompt:     prompt,
-			Schedule:   schedule,
-			SessionKey: sessionKey,
-			Enabled:    true,
+			Name:            name,
+			Prompt:          prompt,
+			Schedule:        schedule,
+			Timezone:        timezone,
+			CatchUp:         catchUp,
+			SessionKey:      sessionKey,
+			DeliverTo:       deliverTo,
+			Enabled:         true,
+			Concurrency:     concurrency,
+			PayloadTemplate: payloadTemplate,
+			Expect:          expect,
+			Delivery:        templates,
+			Pipeline:        pipelineName,
+			Dedupe:          dedupe,
 		}
 		if err := store.Add(task); err != nil {
 			return fmt.Errorf("add task: %w", err)
@@ -59,6 +138,56 @@ var taskAddCmd = &cobra.Command{
 	},
 }
 
+// taskExpect builds a task's expectation from the --expect-* flags,
+// returning nil when none are set.
+func taskExpect(cmd *cobra.Command) (*state.TaskExpect, error) {
+	nonEmpty, _ := cmd.Flags().GetBool("expect-nonempty")
+	contains, _ := cmd.Flags().GetString("expect-contains")
+	schema, _ := cmd.Flags().GetString("expect-schema")
+	if !nonEmpty && contains == "" && schema == "" {
+		return nil, nil
+	}
+	expect := &state.TaskExpect{NonEmpty: nonEmpty, Contains: contains}
+	if schema != "" {
+		if path, ok := strings.CutPrefix(schema, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read schema: %w", err)
+			}
+			schema = string(data)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(schema), &obj); err != nil {
+			return nil, fmt.Errorf("parse schema: %w", err)
+		}
+		expect.JSONSchema = json.RawMessage(schema)
+	}
+	return expect, nil
+}
+
+// deliveryTemplates parses the --delivery flag, returning nil when unset.
+func deliveryTemplates(cmd *cobra.Command) (map[string]*state.DeliveryTemplate, error) {
+	raw, _ := cmd.Flags().GetString("delivery")
+	if raw == "" {
+		return nil, nil
+	}
+	if path, ok := strings.CutPrefix(raw, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read delivery templates: %w", err)
+		}
+		raw = string(data)
+	}
+	var templates map[string]*state.DeliveryTemplate
+	if err := json.Unmarshal([]byte(raw), &templates); err != nil {
+		return nil, fmt.Errorf("parse delivery templates: %w", err)
+	}
+	if err := delivery.ValidateTemplates(templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
 var taskListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all tasks",
@@ -75,14 +204,58 @@ var taskListCmd = &cobra.Command{
 			return nil
 		}
 
+		// Prefer the running daemon's view: it knows which tasks are
+		// actually loaded. Fall back to computing from the task file.
+		loaded, live := daemonSchedule()
+		defaultTZ := loadConfig().Scheduler.Timezone
+
 		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tSCHEDULE\tENABLED\tSESSION KEY")
+		fmt.Fprintln(w, "NAME\tSCHEDULE\tENABLED\tSESSION KEY\tNEXT FIRE\tLAST RUN")
 		for _, t := range tasks {
-			fmt.Fprintf(w, "%s\t%s\t%v\t%s\n",
+			next := "-"
+			tz := t.Timezone
+			if tz == "" {
+				tz = defaultTZ
+			}
+			if t.Enabled && t.Schedule != "" {
+				if live {
+					entry, ok := loaded[t.Name]
+					switch {
+					case !ok:
+						next = "not loaded yet"
+					case entry.Error != "":
+						next = "invalid schedule"
+					case entry.Running:
+						next = "running"
+					case entry.Next != nil:
+						next = entry.Next.Local().Format("2006-01-02 15:04:05")
+					}
+				} else if at, err := scheduler.NextFire(scheduler.Spec(t.Schedule, tz), time.Now()); err == nil {
+					next = at.Local().Format("2006-01-02 15:04:05")
+				}
+			}
+			last := "-"
+			if t.LastRun != nil {
+				last = t.LastRun.At.Format("2006-01-02 15:04:05") + " (" + t.LastRun.Trigger + ")"
+				if t.LastRun.Error != "" {
+					last += " failed"
+				} else if t.LastRun.Violation != "" {
+					last += " check failed"
+				} else if t.LastRun.Duplicate {
+					last += " unchanged"
+				}
+			}
+			schedule := t.Schedule
+			if schedule != "" && t.Timezone != "" {
+				schedule += " (" + t.Timezone + ")"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%s\n",
 				t.Name,
-				t.Schedule,
+				schedule,
 				t.Enabled,
 				t.SessionKey,
+				next,
+				last,
 			)
 		}
 		return w.Flush()
@@ -130,3 +303,17 @@ var taskDisableCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// daemonSchedule asks the running daemon which tasks its scheduler has
+// loaded. It reports false when the daemon or its HTTP server is not up.
+func daemonSchedule() (map[string]scheduler.Entry, bool) {
+	status, err := daemonStatus()
+	if err != nil {
+		return nil, false
+	}
+	entries := make(map[string]scheduler.Entry, len(status.Scheduler))
+	for _, e := range status.Scheduler {
+		entries[e.Name] = e
+	}
+	return entries, true
+}
@@ -0,0 +1,97 @@
+//go:build !daemon
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/webhook"
+)
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().StringSlice("scope", nil, "scope to grant: chat, sessions:read, tasks:read, tasks:write or admin (repeatable)")
+	tokenCreateCmd.MarkFlagRequired("scope")
+}
+
+func tokenStore() *state.TokenStore {
+	cfg := loadConfig()
+	return state.NewTokenStore(filepath.Join(cfg.DataDir, "tokens.json"))
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage scoped HTTP API tokens",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a token and print its secret",
+	Long: `Create an HTTP API token with the given scopes and print its secret. The
+secret is shown only once; gopherclaw stores its hash. For example, a
+read-only dashboard credential:
+
+  gopherclaw token create dashboard --scope sessions:read --scope tasks:read
+
+The running daemon picks up new and revoked tokens without a restart.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopes, _ := cmd.Flags().GetStringSlice("scope")
+		for _, scope := range scopes {
+			if !webhook.ValidScope(webhook.Scope(scope)) {
+				return fmt.Errorf("unknown scope %q (want chat, sessions:read, tasks:read, tasks:write or admin)", scope)
+			}
+		}
+		secret, _, err := tokenStore().Create(args[0], scopes)
+		if err != nil {
+			return fmt.Errorf("create token: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Token %q created. Copy the secret now; it won't be shown again.\n", args[0])
+		fmt.Println(secret)
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tokens and their scopes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokens, err := tokenStore().List()
+		if err != nil {
+			return fmt.Errorf("list tokens: %w", err)
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No tokens created.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPES\tCREATED")
+		for _, t := range tokens {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, strings.Join(t.Scopes, ","), t.Created.Local().Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke a token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := tokenStore().Revoke(args[0]); err != nil {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Token %q revoked.\n", args[0])
+		return nil
+	},
+}
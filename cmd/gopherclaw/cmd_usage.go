@@ -0,0 +1,98 @@
+//go:build !daemon
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+)
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().Int("days", 30, "count the last N days, today included (0 counts everything)")
+	usageCmd.Flags().String("session", "", "only count this session, listing its runs")
+	usageCmd.Flags().Bool("json", false, "print the report as JSON")
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and cost per day, model and session",
+	Long: `Show the tokens the model used and what they cost, per day, model and
+session, or per run for one session. Costs use the model registry's prices,
+which models.<name>.input_price and output_price (USD per million tokens)
+override.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		days, _ := cmd.Flags().GetInt("days")
+		session, _ := cmd.Flags().GetString("session")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		filter := usage.Filter{Since: usage.Since(days, time.Now()), SessionID: types.SessionID(session)}
+		report, err := usage.Collect(context.Background(), state.NewSessionStore(cfg.DataDir), state.NewEventStore(cfg.DataDir), modelRegistry(cfg), filter)
+		if err != nil {
+			return fmt.Errorf("collect usage: %w", err)
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		if report.Total.Calls == 0 {
+			fmt.Println("No usage recorded.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		table := func(title string, groups []usage.Group, names bool) {
+			if names {
+				fmt.Fprintf(w, "%s\tKEY\tCALLS\tINPUT\tOUTPUT\tCOST\n", title)
+			} else {
+				fmt.Fprintf(w, "%s\tCALLS\tINPUT\tOUTPUT\tCOST\n", title)
+			}
+			for _, g := range groups {
+				label := g.Key
+				if names {
+					label += "\t" + g.Name
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", label, g.Calls, g.InputTokens, g.OutputTokens, formatCost(g.Totals))
+			}
+			fmt.Fprintln(w)
+		}
+		table("DAY", report.Days, false)
+		table("MODEL", report.Models, false)
+		if filter.SessionID != "" {
+			table("RUN", report.Runs, false)
+		} else {
+			table("SESSION", report.Sessions, true)
+		}
+		t := report.Total
+		fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%s\n", t.Calls, t.InputTokens, t.OutputTokens, formatCost(t))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if t.Unpriced > 0 {
+			fmt.Printf("\n%d call(s) used models without a price; set models.<name>.input_price and output_price to count them.\n", t.Unpriced)
+		}
+		return nil
+	},
+}
+
+// formatCost renders a cost in dollars, marked with "+" when some of the
+// calls behind it have no price.
+func formatCost(t usage.Totals) string {
+	cost := fmt.Sprintf("$%.4f", t.CostUSD)
+	if t.Unpriced > 0 {
+		cost += "+"
+	}
+	return cost
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/gopherclaw/internal/config"
+	"github.com/user/gopherclaw/internal/logging"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+	"github.com/user/gopherclaw/pkg/llm/anthropic"
+	"github.com/user/gopherclaw/pkg/llm/openai"
+)
+
+// cfgPath is set by the --config flag.
+var cfgPath string
+
+// defaultConfigPath returns ~/.gopherclaw/config.json.
+func defaultConfigPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".gopherclaw", "config.json")
+}
+
+func loadConfig() *config.Config {
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// setupLogging logs text to stderr and JSON to the log file, tagging lines
+// logged during a run with its run and session IDs. The returned function
+// closes the log file.
+func setupLogging(cfg *config.Config) (func(), error) {
+	opts := &slog.HandlerOptions{Level: logLevel(cfg)}
+	f, err := logging.OpenFile(logFile(cfg))
+	if err != nil {
+		return nil, err
+	}
+	handler := logging.Tee(slog.NewTextHandler(os.Stderr, opts), slog.NewJSONHandler(f, opts))
+	slog.SetDefault(slog.New(logging.NewHandler(handler)))
+	return func() { f.Close() }, nil
+}
+
+// logLevel returns the slog level for log_level.
+func logLevel(cfg *config.Config) slog.Level {
+	switch strings.ToLower(cfg.LogLevel) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// logFile returns the path of the daemon's JSON log.
+func logFile(cfg *config.Config) string {
+	if cfg.LogFile != "" {
+		return cfg.LogFile
+	}
+	return filepath.Join(cfg.DataDir, "logs", "gopherclaw.log")
+}
+
+// newProvider creates the client for llm.provider with the given settings.
+func newProvider(cfg *config.Config, c *llm.Config) (llm.Provider, error) {
+	provider, err := providerOf(cfg.LLM.Provider, c)
+	if err != nil {
+		return nil, fmt.Errorf("llm.provider: %w", err)
+	}
+	return provider, nil
+}
+
+// providerOf creates the client for the named provider.
+func providerOf(name string, c *llm.Config) (llm.Provider, error) {
+	switch name {
+	case "", "openai":
+		return openai.New(c), nil
+	case "anthropic":
+		return anthropic.New(c), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want openai or anthropic)", name)
+	}
+}
+
+// profileProviders creates the clients for model_profiles, filling in what
+// each profile leaves empty from llm, or for a profile with another
+// provider, from that provider's defaults and API key variable.
+func profileProviders(cfg *config.Config) (map[string]llm.Provider, error) {
+	providers := make(map[string]llm.Provider, len(cfg.ModelProfiles))
+	for name, p := range cfg.ModelProfiles {
+		c := &llm.Config{
+			BaseURL:     p.BaseURL,
+			APIKey:      p.APIKey,
+			Model:       p.Model,
+			MaxTokens:   p.MaxTokens,
+			Temperature: cfg.LLM.Temperature,
+			MaxRetries:  cfg.LLM.RateLimitRetries,
+		}
+		kind := p.Provider
+		if kind == "" || kind == cfg.LLM.Provider {
+			kind = cfg.LLM.Provider
+			if c.BaseURL == "" {
+				c.BaseURL = cfg.LLM.BaseURL
+			}
+			if c.APIKey == "" {
+				c.APIKey = cfg.LLM.APIKey
+			}
+		} else {
+			// Another provider gets its own defaults.
+			baseURL, keyEnv := config.DefaultOpenAIBaseURL, "OPENAI_API_KEY"
+			if kind == "anthropic" {
+				baseURL, keyEnv = anthropic.DefaultBaseURL, "ANTHROPIC_API_KEY"
+			}
+			if c.BaseURL == "" {
+				c.BaseURL = baseURL
+			}
+			if c.APIKey == "" {
+				c.APIKey = os.Getenv(keyEnv)
+			}
+		}
+		if c.Model == "" {
+			c.Model = cfg.LLM.Model
+		}
+		if c.MaxTokens == 0 {
+			c.MaxTokens = cfg.LLM.MaxTokens
+		}
+		if p.Temperature != nil {
+			c.Temperature = *p.Temperature
+		}
+		provider, err := providerOf(kind, c)
+		if err != nil {
+			return nil, fmt.Errorf("model_profiles.%s: %w", name, err)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// withFailover returns a Failover that tries provider, as name, and then
+// the named profiles in order, or provider itself if there are none.
+func withFailover(name string, provider llm.Provider, failover []string, profiles map[string]llm.Provider) (llm.Provider, error) {
+	if len(failover) == 0 {
+		return provider, nil
+	}
+	backends := []llm.Backend{{Name: name, Provider: provider}}
+	for _, profile := range failover {
+		p, ok := profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", profile)
+		}
+		backends = append(backends, llm.Backend{Name: profile, Provider: p})
+	}
+	return llm.NewFailover(backends...), nil
+}
+
+// modelRegistry returns the built-in model registry with the config's
+// per-model overrides applied on top.
+func modelRegistry(cfg *config.Config) *llm.Registry {
+	models := llm.NewRegistry()
+	for name, override := range cfg.Models {
+		caps, _ := models.Lookup(name)
+		if override.ContextWindow != 0 {
+			caps.ContextWindow = override.ContextWindow
+		}
+		if override.SupportsTools != nil {
+			caps.SupportsTools = *override.SupportsTools
+		}
+		if override.SupportsVision != nil {
+			caps.SupportsVision = *override.SupportsVision
+		}
+		if override.Tokenizer != "" {
+			caps.Tokenizer = override.Tokenizer
+		}
+		if override.InputPrice != 0 {
+			caps.InputPrice = override.InputPrice
+		}
+		if override.OutputPrice != 0 {
+			caps.OutputPrice = override.OutputPrice
+		}
+		models.Set(name, caps)
+	}
+	return models
+}
+
+// spendBudget returns the spend budgets the config sets, priced with
+// models, or nil if it sets no limits.
+func spendBudget(cfg *config.Config, models *llm.Registry) *usage.Budget {
+	global, session := usage.Limits(cfg.Budget.BudgetLimits), usage.Limits(cfg.Budget.Session)
+	if global.IsZero() && session.IsZero() {
+		return nil
+	}
+	return usage.NewBudget(global, session, models, state.NewBudgetStore(filepath.Join(cfg.DataDir, "budget.json")))
+}
@@ -0,0 +1,473 @@
+//go:build !cli
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/user/gopherclaw/internal/config"
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/mcp"
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/runtime/tools"
+	"github.com/user/gopherclaw/internal/simulate"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// core is the conversation machinery shared by the daemon and a local
+// `gopherclaw chat`: stores, tools, runtime and gateway. The gateway is
+// not started.
+type core struct {
+	sessions   *state.SessionStore
+	events     *state.EventStore
+	artifacts  *state.ArtifactStore
+	reminders  *state.ReminderStore
+	runs       *state.RunStore
+	engine     *ctxengine.Engine
+	registry   *runtime.Registry
+	models     *llm.Registry
+	memoryPath string
+	rt         *runtime.Runtime
+	gw         *gateway.Gateway
+	// sim records what simulation mode holds back; nil outside it.
+	sim *simulate.Recorder
+	// mcp are the connected MCP servers; see close.
+	mcp []*mcp.Client
+}
+
+// simulateConfig prepares cfg for simulation mode before anything uses the
+// model: simulate.model replaces llm.model, and without one there is no
+// provider to probe.
+func simulateConfig(cfg *config.Config) {
+	if cfg.Simulate.Model != "" {
+		cfg.LLM.Model = cfg.Simulate.Model
+		cfg.LLM.FallbackModel = ""
+	} else {
+		cfg.LLM.ProbeOnStart = false
+	}
+}
+
+// simulateLog returns the path of the simulation log.
+func simulateLog(cfg *config.Config) string {
+	if cfg.Simulate.LogFile != "" {
+		return cfg.Simulate.LogFile
+	}
+	return filepath.Join(cfg.DataDir, "simulate.jsonl")
+}
+
+// newCore builds the core from the config.
+func newCore(cfg *config.Config) (*core, error) {
+	// Stores
+	sessions := state.NewSessionStore(cfg.DataDir)
+	events := state.NewEventStore(cfg.DataDir)
+	if err := events.SetCompression(cfg.Session.Compression, cfg.Session.SegmentSize); err != nil {
+		return nil, fmt.Errorf("session.compression: %w", err)
+	}
+	artifacts := state.NewArtifactStore(cfg.DataDir)
+
+	// LLM provider
+	provider, err := newProvider(cfg, &llm.Config{
+		BaseURL:     cfg.LLM.BaseURL,
+		APIKey:      cfg.LLM.APIKey,
+		Model:       cfg.LLM.Model,
+		MaxTokens:   cfg.LLM.MaxTokens,
+		Temperature: cfg.LLM.Temperature,
+		MaxRetries:  cfg.LLM.RateLimitRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var sim *simulate.Recorder
+	if cfg.Simulate.Enabled {
+		if sim, err = simulate.NewRecorder(simulateLog(cfg)); err != nil {
+			return nil, err
+		}
+		if cfg.Simulate.Model == "" {
+			provider = simulate.Provider()
+		}
+	}
+	// Model profiles, which simulation replaces with its own model too.
+	var modelProfiles map[string]llm.Provider
+	if sim == nil {
+		if modelProfiles, err = profileProviders(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Model capabilities
+	models := modelRegistry(cfg)
+	caps, known := models.Lookup(cfg.LLM.Model)
+	if !known {
+		slog.Warn("model not in registry, using defaults", "model", cfg.LLM.Model)
+	}
+	contextWindow := cfg.LLM.MaxContextTokens
+	if contextWindow == 0 {
+		contextWindow = caps.ContextWindow
+	}
+	if contextWindow == 0 {
+		contextWindow = defaultContextWindow
+	}
+
+	// Context engine
+	engine, err := ctxengine.New(cfg.LLM.Model, contextWindow, cfg.LLM.OutputReserve, cfg.SystemPromptPath)
+	if err != nil {
+		return nil, fmt.Errorf("create context engine: %w", err)
+	}
+	if caps.Tokenizer != "" {
+		if err := engine.SetTokenizer(caps.Tokenizer); err != nil {
+			slog.Warn("model tokenizer unavailable, keeping default", "tokenizer", caps.Tokenizer, "error", err)
+		}
+	}
+
+	// Tool registry
+	registry := runtime.NewRegistry()
+	bash, err := bashPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	registry.Register(tools.NewBash(bash))
+	if cfg.Brave.APIKey != "" {
+		registry.Register(tools.NewBraveSearch(cfg.Brave.APIKey))
+	}
+	registry.Register(tools.NewReadURL())
+	if len(cfg.HTTPRequest.AllowedHosts) > 0 {
+		httpCfg := tools.HTTPRequestConfig{AllowedHosts: cfg.HTTPRequest.AllowedHosts, Headers: cfg.HTTPRequest.Headers}
+		if cfg.HTTPRequest.Timeout != "" {
+			if httpCfg.Timeout, err = time.ParseDuration(cfg.HTTPRequest.Timeout); err != nil {
+				return nil, fmt.Errorf("parse http_request.timeout: %w", err)
+			}
+		}
+		registry.Register(tools.NewHTTPRequest(httpCfg))
+	}
+	registry.Register(tools.NewNoReply())
+
+	// Memory tools
+	memoryPath := filepath.Join(cfg.DataDir, "memory.md")
+	registry.Register(tools.NewMemorySave(memoryPath))
+	registry.Register(tools.NewMemoryDelete(memoryPath))
+	registry.Register(tools.NewMemoryList(memoryPath))
+
+	// Reminder tools
+	reminders := state.NewReminderStore(filepath.Join(cfg.DataDir, "reminders.json"))
+	registry.Register(tools.NewReminderSet(reminders))
+	registry.Register(tools.NewReminderList(reminders))
+	registry.Register(tools.NewReminderCancel(reminders))
+
+	// MCP servers' tools
+	mcpClients, mcpTools := connectMCP(cfg, registry)
+
+	if cfg.Chaos.Enabled {
+		inj, err := chaosInjector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		slog.Warn("chaos mode enabled: injecting provider faults", "tools", cfg.Chaos.Tools)
+		provider = inj.Provider(provider)
+		for name, p := range modelProfiles {
+			modelProfiles[name] = inj.Provider(p)
+		}
+		if cfg.Chaos.Tools {
+			for _, t := range registry.All() {
+				registry.Register(inj.Tool(t))
+			}
+		}
+	}
+
+	if sim != nil {
+		for _, t := range registry.All() {
+			// MCP tools may act on anything, so none of them run.
+			if gateway.IsMutatingTool(t.Name()) || mcpTools[t.Name()] {
+				registry.Register(sim.Tool(t))
+			}
+		}
+		slog.Warn("simulation mode: mutating tools only record their calls, deliveries go to the simulation log", "log", sim.Path(), "model", cfg.Simulate.Model)
+	}
+
+	// Operator renames and rewordings, checked against what is registered
+	overrides := make(map[string]runtime.ToolOverride, len(cfg.Tools.Overrides))
+	for name, o := range cfg.Tools.Overrides {
+		overrides[name] = runtime.ToolOverride{Name: o.Name, Description: o.Description, Parameters: o.Parameters}
+	}
+	if err := registry.SetOverrides(overrides); err != nil {
+		return nil, fmt.Errorf("tools.overrides: %w", err)
+	}
+
+	// Wire memory path into context engine
+	engine.SetMemoryPath(memoryPath)
+
+	// Reply length profiles by channel
+	profiles := make(map[string]ctxengine.Verbosity, len(cfg.Verbosity))
+	for channel, v := range cfg.Verbosity {
+		profiles[channel] = ctxengine.Verbosity{Style: v.Style, MaxChars: v.MaxChars}
+	}
+	if err := engine.SetVerbosity(profiles); err != nil {
+		return nil, fmt.Errorf("verbosity: %w", err)
+	}
+
+	// Which events reach the prompt, by agent
+	agentInclusion := make(map[string]ctxengine.Inclusion, len(cfg.Context.Agents))
+	for agent, in := range cfg.Context.Agents {
+		agentInclusion[agent] = ctxengine.Inclusion(in)
+	}
+	if err := engine.SetInclusion(ctxengine.Inclusion(cfg.Context.ContextInclusion), agentInclusion); err != nil {
+		return nil, fmt.Errorf("context: %w", err)
+	}
+
+	// Failover, from llm and from each profile to the profiles it lists
+	if sim == nil {
+		if provider, err = withFailover("llm", provider, cfg.LLM.Failover, modelProfiles); err != nil {
+			return nil, fmt.Errorf("llm.failover: %w", err)
+		}
+		withBackups := make(map[string]llm.Provider, len(modelProfiles))
+		for name, p := range modelProfiles {
+			if withBackups[name], err = withFailover(name, p, cfg.ModelProfiles[name].Failover, modelProfiles); err != nil {
+				return nil, fmt.Errorf("model_profiles.%s.failover: %w", name, err)
+			}
+		}
+		modelProfiles = withBackups
+	}
+
+	// Which model each LLM call goes to
+	selector := runtime.SingleModel(provider)
+	if len(cfg.ModelRoutes) > 0 && sim == nil {
+		routes := make([]runtime.Route, len(cfg.ModelRoutes))
+		for i, r := range cfg.ModelRoutes {
+			routes[i] = runtime.Route(r)
+		}
+		router, err := runtime.NewRouter(provider, modelProfiles, routes)
+		if err != nil {
+			return nil, fmt.Errorf("model_routes: %w", err)
+		}
+		selector = router
+	}
+
+	// Runtime
+	rt := runtime.New(selector, engine, sessions, events, artifacts, registry, cfg.MaxToolRounds)
+	if cfg.Session.InterimAfter != "" {
+		interim, err := time.ParseDuration(cfg.Session.InterimAfter)
+		if err != nil {
+			return nil, fmt.Errorf("parse session.interim_after: %w", err)
+		}
+		rt.SetInterimAfter(interim)
+	}
+	if err := setTimeouts(rt, cfg); err != nil {
+		return nil, err
+	}
+	rt.SetSummarizeArtifacts(cfg.LLM.SummarizeArtifacts)
+	rt.SetSummarizeHistory(cfg.Session.SummarizeHistory)
+	rt.SetCitations(cfg.LLM.Citations)
+	rt.SetToolParallelism(cfg.Runtime.ParallelTools)
+	if budget := spendBudget(cfg, models); budget != nil {
+		if err := budget.Load(context.Background(), sessions, events); err != nil {
+			return nil, fmt.Errorf("load budget spend: %w", err)
+		}
+		rt.SetBudget(budget)
+	}
+
+	// Keep a copy of every system prompt template a run was built with.
+	promptDir := filepath.Join(cfg.DataDir, "prompts")
+	if version, err := ctxengine.ArchivePrompt(promptDir, engine.PromptSource()); err != nil {
+		slog.Warn("failed to archive system prompt", "error", err)
+	} else {
+		slog.Info("system prompt", "version", version)
+	}
+	rt.SetPromptArchive(promptDir)
+
+	// Gateway
+	gw := gateway.New(sessions, events, artifacts, int64(cfg.MaxConcurrent))
+	gw.Queue.SetProcessor(rt.ProcessRun)
+	runs := state.NewRunStore(filepath.Join(cfg.DataDir, "runs.jsonl"))
+	gw.SetRunStore(runs)
+	if cfg.Session.IdleTimeout != "" {
+		idle, err := time.ParseDuration(cfg.Session.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("parse session.idle_timeout: %w", err)
+		}
+		gw.SetIdleTimeout(idle)
+	}
+
+	return &core{
+		sessions:   sessions,
+		events:     events,
+		artifacts:  artifacts,
+		reminders:  reminders,
+		runs:       runs,
+		engine:     engine,
+		registry:   registry,
+		models:     models,
+		memoryPath: memoryPath,
+		rt:         rt,
+		gw:         gw,
+		sim:        sim,
+		mcp:        mcpClients,
+	}, nil
+}
+
+// otherLeader returns the holder of an unexpired leader lease on dataDir
+// if it is someone other than holder, or "" if there is none. While such a
+// leader lives, the run records and event logs it is writing are its own.
+func otherLeader(dataDir, holder string) string {
+	cur, err := state.NewLease(filepath.Join(dataDir, "leader.json"), holder, 0).Current()
+	if err != nil || cur == nil || cur.Holder == holder || !time.Now().Before(cur.Expires) {
+		return ""
+	}
+	return cur.Holder
+}
+
+// recover closes out what a crash or restart left unfinished: tool calls
+// without results in the event logs and, unless the gateway will resume
+// them, runs still recorded as queued or running from before startedAt.
+// Only call it when no other instance leads; see otherLeader.
+func (c *core) recover(startedAt time.Time, resume bool) {
+	if repaired, err := c.events.RepairAll(context.Background()); err != nil {
+		slog.Warn("event log repair failed", "error", err)
+	} else if repaired > 0 {
+		slog.Info("repaired dangling tool calls", "count", repaired)
+	}
+	if resume {
+		return
+	}
+	if interrupted, err := c.runs.Recover(startedAt); err != nil {
+		slog.Warn("run record recovery failed", "error", err)
+	} else if interrupted > 0 {
+		slog.Info("marked unfinished runs as interrupted", "count", interrupted)
+	}
+}
+
+// close stops what the core started, such as MCP servers.
+func (c *core) close() {
+	for _, client := range c.mcp {
+		if err := client.Close(); err != nil {
+			slog.Warn("mcp server close failed", "server", client.Name(), "error", err)
+		}
+	}
+}
+
+// connectMCP connects to the configured MCP servers and registers their
+// tools, returning the connections and the names of the tools. A server
+// that can't be reached is logged and left out, so the agent still starts
+// with the rest.
+func connectMCP(cfg *config.Config, registry *runtime.Registry) ([]*mcp.Client, map[string]bool) {
+	names := make([]string, 0, len(cfg.MCPServers))
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var clients []*mcp.Client
+	registered := make(map[string]bool)
+	ctx := context.Background()
+	for _, name := range names {
+		server := cfg.MCPServers[name]
+		client, err := mcp.Connect(ctx, name, mcp.ServerConfig{
+			Command: server.Command,
+			Env:     server.Env,
+			URL:     server.URL,
+			Headers: server.Headers,
+			Tools:   server.Tools,
+		})
+		if err != nil {
+			slog.Warn("mcp server unavailable", "server", name, "error", err)
+			continue
+		}
+		serverTools, err := client.Tools(ctx)
+		if err != nil {
+			slog.Warn("mcp server unavailable", "server", name, "error", err)
+			client.Close()
+			continue
+		}
+		for _, t := range serverTools {
+			if _, taken := registry.Get(t.Name()); taken {
+				slog.Warn("mcp tool name taken, skipping", "server", name, "tool", t.Name())
+				continue
+			}
+			registry.Register(t)
+			registered[t.Name()] = true
+		}
+		slog.Info("mcp tools registered", "server", name, "tools", len(serverTools))
+		clients = append(clients, client)
+	}
+	return clients, registered
+}
+
+// bashPolicy compiles the bash tool's policy from config.
+func bashPolicy(cfg *config.Config) (tools.BashPolicy, error) {
+	policy := tools.BashPolicy{
+		Dir:       cfg.Bash.Dir,
+		MaxOutput: cfg.Bash.MaxOutput,
+		Wrapper:   cfg.Bash.Wrapper,
+	}
+	compile := func(key string, patterns []string) ([]*regexp.Regexp, error) {
+		var res []*regexp.Regexp
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s[%d]: %w", key, i, err)
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+	var err error
+	if policy.Allow, err = compile("bash.allow", cfg.Bash.Allow); err != nil {
+		return policy, err
+	}
+	if policy.Deny, err = compile("bash.deny", cfg.Bash.Deny); err != nil {
+		return policy, err
+	}
+	if policy.Dir != "" {
+		if policy.Dir, err = filepath.Abs(policy.Dir); err != nil {
+			return policy, fmt.Errorf("resolve bash.dir: %w", err)
+		}
+		if err := os.MkdirAll(policy.Dir, 0o755); err != nil {
+			return policy, fmt.Errorf("create bash.dir: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// setTimeouts applies the runtime section's tool and run timeouts and the
+// approval settings.
+func setTimeouts(rt *runtime.Runtime, cfg *config.Config) error {
+	parse := func(key, value string) (time.Duration, error) {
+		if value == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("parse %s %q: want a Go duration such as \"2m\"", key, value)
+		}
+		return d, nil
+	}
+	tool, err := parse("runtime.tool_timeout", cfg.Runtime.ToolTimeout)
+	if err != nil {
+		return err
+	}
+	perTool := make(map[string]time.Duration, len(cfg.Runtime.ToolTimeouts))
+	for name, value := range cfg.Runtime.ToolTimeouts {
+		if perTool[name], err = parse("runtime.tool_timeouts."+name, value); err != nil {
+			return err
+		}
+	}
+	run, err := parse("runtime.run_timeout", cfg.Runtime.RunTimeout)
+	if err != nil {
+		return err
+	}
+	approval, err := parse("approval.timeout", cfg.Approval.Timeout)
+	if err != nil {
+		return err
+	}
+	rt.SetToolTimeout(tool, perTool)
+	rt.SetRunTimeout(run)
+	rt.SetApprovalTools(cfg.Approval.Tools, approval)
+	return nil
+}
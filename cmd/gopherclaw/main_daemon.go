@@ -0,0 +1,26 @@
+//go:build daemon
+
+// The daemon build (go build -tags daemon) is a headless gopherclaw that
+// only serves: it has no admin subcommands and does not link the CLI.
+// Manage it with a CLI build talking to its HTTP API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	flag.StringVar(&cfgPath, "config", defaultConfigPath(), "config file path")
+	flag.BoolVar(&simulateFlag, "simulate", false, "run without external side effects")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", flag.Args())
+		os.Exit(2)
+	}
+	if err := serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,989 @@
+//go:build !cli
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/user/gopherclaw/internal/backup"
+	"github.com/user/gopherclaw/internal/chaos"
+	"github.com/user/gopherclaw/internal/config"
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/email"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/heartbeat"
+	"github.com/user/gopherclaw/internal/maintenance"
+	"github.com/user/gopherclaw/internal/pipeline"
+	"github.com/user/gopherclaw/internal/scheduler"
+	signalbot "github.com/user/gopherclaw/internal/signal"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/telegram"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/watchdog"
+	"github.com/user/gopherclaw/internal/webhook"
+	"github.com/user/gopherclaw/internal/webpush"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func writePIDFile(dataDir string) (string, error) {
+	pidPath := filepath.Join(dataDir, "gopherclaw.pid")
+	pid := os.Getpid()
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("write PID file: %w", err)
+	}
+	return pidPath, nil
+}
+
+// simulateFlag is set by --simulate and turns on simulation mode.
+var simulateFlag bool
+
+// serve runs the daemon until it is stopped or restarted by a signal.
+func serve() error {
+	cfg := loadConfig()
+	if simulateFlag {
+		cfg.Simulate.Enabled = true
+	}
+	if cfg.Simulate.Enabled {
+		simulateConfig(cfg)
+	}
+	closeLog, err := setupLogging(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+
+	// Write PID file
+	pidPath, err := writePIDFile(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(pidPath)
+
+	// Leader lease: instances sharing data_dir all serve HTTP, but only the
+	// holder polls Telegram, runs scheduled tasks and accepts writes.
+	leaseTTL := defaultLeaseTTL
+	if cfg.Leader.LeaseTTL != "" {
+		leaseTTL, err = time.ParseDuration(cfg.Leader.LeaseTTL)
+		if err != nil {
+			return fmt.Errorf("parse leader.lease_ttl: %w", err)
+		}
+	}
+	host, _ := os.Hostname()
+	lease := state.NewLease(filepath.Join(cfg.DataDir, "leader.json"), fmt.Sprintf("%s:%d", host, os.Getpid()), leaseTTL)
+	leader := otherLeader(cfg.DataDir, lease.Holder())
+	follower := leader != ""
+
+	// Repair crash damage in the data directory before any store touches
+	// it. A live leader is writing there, so a follower only looks: its
+	// temp files and half-written lines are work in progress.
+	check := state.CheckIntegrity
+	if follower {
+		slog.Info("another instance is leader; starting as a read-only follower", "leader", leader)
+		check = state.VerifyIntegrity
+	}
+	report, err := check(cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("check data dir integrity: %w", err)
+	}
+	for _, msg := range report.Repaired {
+		slog.Info("integrity repair", "detail", msg)
+	}
+	for _, msg := range report.Problems {
+		slog.Warn("integrity problem", "detail", msg)
+	}
+
+	// Fail fast on a misconfigured provider, or degrade to the fallback
+	// model or the failover profiles
+	if cfg.LLM.ProbeOnStart {
+		if err := probeModel(cfg, cfg.LLM.Model); err != nil {
+			switch {
+			case cfg.LLM.FallbackModel != "":
+				slog.Warn("llm provider check failed, trying fallback model", "model", cfg.LLM.Model, "fallback", cfg.LLM.FallbackModel, "error", err)
+				if err := probeModel(cfg, cfg.LLM.FallbackModel); err != nil {
+					return fmt.Errorf("llm provider check failed for fallback model %q at %s: %w", cfg.LLM.FallbackModel, cfg.LLM.BaseURL, err)
+				}
+				cfg.LLM.Model = cfg.LLM.FallbackModel
+				slog.Info("llm provider check passed", "model", cfg.LLM.Model)
+			case len(cfg.LLM.Failover) > 0:
+				slog.Warn("llm provider check failed, relying on failover", "model", cfg.LLM.Model, "failover", cfg.LLM.Failover, "error", err)
+			default:
+				return fmt.Errorf("llm provider check failed for model %q at %s: %w", cfg.LLM.Model, cfg.LLM.BaseURL, err)
+			}
+		} else {
+			slog.Info("llm provider check passed", "model", cfg.LLM.Model)
+		}
+	}
+
+	// Stores, tools, runtime and gateway
+	startedAt := time.Now()
+	c, err := newCore(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	sessions, events, artifacts := c.sessions, c.events, c.artifacts
+	engine, registry, rt, gw := c.engine, c.registry, c.rt, c.gw
+	memoryPath, reminders := c.memoryPath, c.reminders
+
+	// Web push for browsers subscribed through the web UI. Long-run
+	// notices wrap the processor, so this comes before the gateway starts.
+	var (
+		pushStore *state.PushStore
+		pushKeys  *webpush.Keys
+		notifier  *webpush.Notifier
+	)
+	if cfg.WebPush.Enabled {
+		pushStore = state.NewPushStore(filepath.Join(cfg.DataDir, "push.json"))
+		if pushKeys, err = webpush.LoadOrCreateKeys(filepath.Join(cfg.DataDir, "vapid.json")); err != nil {
+			return err
+		}
+		subject := cmp.Or(cfg.WebPush.Subject, cfg.HTTP.PublicURL)
+		if subject == "" {
+			slog.Warn("webpush.subject and http.public_url are unset; some push services reject notifications without a contact")
+		}
+		sender, err := webpush.NewSender(pushKeys, subject)
+		if err != nil {
+			return err
+		}
+		notifier = webpush.NewNotifier(pushStore, sender)
+		if cfg.WebPush.NotifyAfter != "" && c.sim == nil {
+			after, err := time.ParseDuration(cfg.WebPush.NotifyAfter)
+			if err != nil {
+				return fmt.Errorf("parse webpush.notify_after: %w", err)
+			}
+			gw.Queue.SetProcessor(notifyLongRuns(rt.ProcessRun, after, notifier))
+		}
+	}
+
+	// Close out tool calls left dangling by a crash. Runs left unfinished
+	// are resumed once their replies can be delivered. While another
+	// instance leads, they are its runs and are left alone until this one
+	// takes over.
+	if !follower {
+		c.recover(startedAt, true)
+	}
+	resume := !follower
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slog.Info("gopherclaw started",
+		"data_dir", cfg.DataDir,
+		"log_level", cfg.LogLevel,
+		"max_concurrent", cfg.MaxConcurrent,
+		"max_tool_rounds", cfg.MaxToolRounds,
+		"llm_provider", cfg.LLM.Provider,
+		"llm_model", cfg.LLM.Model,
+		"pid_file", pidPath,
+	)
+
+	// Collect tool names for context summary
+	var toolNames []string
+	for _, t := range registry.All() {
+		toolNames = append(toolNames, t.Name())
+	}
+
+	// Task store
+	taskStore := state.NewTaskStore(filepath.Join(cfg.DataDir, "tasks.json"))
+	macroStore := state.NewMacroStore(filepath.Join(cfg.DataDir, "macros.json"))
+
+	// Per-session concurrency overrides from task definitions, updated
+	// when the scheduler reloads changed tasks
+	overrides := map[types.SessionKey]int{}
+	applyConcurrency := func(tasks []*state.Task) {
+		next := map[types.SessionKey]int{}
+		for _, t := range tasks {
+			if key := types.SessionKey(t.SessionKey); t.Concurrency > next[key] {
+				next[key] = t.Concurrency
+			}
+		}
+		for key := range overrides {
+			if _, ok := next[key]; !ok {
+				gw.Queue.SetConcurrency(key, 1)
+			}
+		}
+		for key, n := range next {
+			gw.Queue.SetConcurrency(key, n)
+		}
+		overrides = next
+	}
+	if tasks, err := taskStore.List(); err != nil {
+		slog.Warn("failed to load tasks for concurrency overrides", "error", err)
+	} else {
+		applyConcurrency(tasks)
+	}
+
+	// Delivery registry, with an outbox that retries failed deliveries
+	deliveryReg := delivery.NewRegistry()
+	var retryInterval, maxAge time.Duration
+	if cfg.Delivery.RetryInterval != "" {
+		if retryInterval, err = time.ParseDuration(cfg.Delivery.RetryInterval); err != nil {
+			return fmt.Errorf("parse delivery.retry_interval: %w", err)
+		}
+	}
+	if cfg.Delivery.MaxAge != "" {
+		if maxAge, err = time.ParseDuration(cfg.Delivery.MaxAge); err != nil {
+			return fmt.Errorf("parse delivery.max_age: %w", err)
+		}
+	}
+	outbox := delivery.NewOutbox(deliveryReg, state.NewOutboxStore(filepath.Join(cfg.DataDir, "outbox.json")), maxAge)
+	broadcast := func(ctx context.Context, message string) (*delivery.BroadcastResult, error) {
+		all, err := sessions.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list sessions: %w", err)
+		}
+		var keys []string
+		for _, sess := range all {
+			if sess.Status == "active" {
+				keys = append(keys, string(sess.SessionKey))
+			}
+		}
+		result := deliveryReg.Broadcast(ctx, keys, message, delivery.DefaultBroadcastInterval)
+		slog.Info("broadcast sent", "sent", result.Sent, "skipped", result.Skipped, "failed", len(result.Failed))
+		return result, nil
+	}
+
+	// Telegram adapter; it only polls once this instance is the leader.
+	var adapter *telegram.Adapter
+	if c.sim != nil {
+		// Nothing reaches Telegram: no polling, and deliveries are logged.
+		deliveryReg.Register("telegram:", c.sim.Deliver)
+		slog.Warn("simulation mode: telegram adapter disabled")
+	} else if cfg.Telegram.Token != "" {
+		adapter, err = telegram.New(cfg.Telegram.Token, gw, events, sessions, engine, toolNames, memoryPath)
+		if err != nil {
+			return fmt.Errorf("create telegram adapter: %w", err)
+		}
+		adapter.SetArtifactStore(artifacts)
+		adapter.SetAdmins(cfg.Telegram.Admins)
+		adapter.SetStreaming(!cfg.Telegram.NoStream)
+		adapter.SetBroadcaster(broadcast)
+		adapter.SetMacroStore(macroStore)
+		adapter.SetReminderStore(reminders)
+		adapter.SetModelRegistry(c.models)
+		if cfg.Session.SeedOnNew {
+			adapter.SetSessionSeeder(rt.SeedSession)
+		}
+
+		// Register telegram delivery for cron responses
+		deliveryReg.Register("telegram:", func(sessionKey, message string) error {
+			return adapter.SendTo(sessionKey, message)
+		})
+	} else {
+		slog.Warn("telegram adapter disabled (no token)")
+	}
+	if c.sim != nil {
+		deliveryReg.Register("webpush:", c.sim.Deliver)
+	} else if notifier != nil {
+		deliveryReg.Register("webpush:", notifier.Deliver)
+	}
+	if hooks := newWebhookSinks(cfg); hooks != nil {
+		if c.sim != nil {
+			deliveryReg.Register("http:", func(sessionKey, message string) error {
+				if _, ok := hooks.Sink(sessionKey); !ok {
+					return fmt.Errorf("%w: %s", delivery.ErrNoHandler, sessionKey)
+				}
+				return c.sim.Deliver(sessionKey, message)
+			})
+		} else {
+			deliveryReg.Register("http:", hooks.Deliver)
+		}
+	}
+	// Signal adapter; like Telegram's, it only receives on the leader.
+	var signalAdapter *signalbot.Adapter
+	if cfg.Signal.URL != "" {
+		if c.sim != nil {
+			deliveryReg.Register("signal:", c.sim.Deliver)
+			slog.Warn("simulation mode: signal adapter disabled")
+		} else {
+			if cfg.Signal.Number == "" {
+				return fmt.Errorf("signal.url is set without signal.number")
+			}
+			if len(cfg.Signal.AllowedNumbers) == 0 {
+				slog.Warn("signal.allowed_numbers is empty; anyone who messages the bot's number can use it")
+			}
+			signalAdapter = signalbot.NewAdapter(signalbot.NewClient(cfg.Signal.URL, cfg.Signal.Number), gw, cfg.Signal.AllowedNumbers)
+			deliveryReg.Register("signal:", signalAdapter.SendTo)
+		}
+	}
+	mailSender, mailer, err := newEmail(cfg, gw)
+	if err != nil {
+		return err
+	}
+	if mailSender != nil {
+		if c.sim != nil {
+			deliveryReg.Register("email:", c.sim.Deliver)
+		} else {
+			deliveryReg.Register("email:", mailSender.Deliver)
+		}
+	}
+	if mailer != nil && c.sim != nil {
+		mailer = nil
+		slog.Warn("simulation mode: email poller disabled")
+	}
+
+	// Gateway, resuming unfinished runs now that deliveries are set up.
+	// Their original callers are gone, so replies go out like task results.
+	if resume {
+		gw.SetResume(func(run *gateway.Run) {
+			key := string(run.Event.SessionKey)
+			run.OnComplete = func(response string) {
+				if response == "" {
+					return
+				}
+				if err := outbox.Deliver(key, response, "resumed run"); err != nil && !errors.Is(err, delivery.ErrNoHandler) {
+					slog.Warn("deliver resumed run reply failed", "run_id", string(run.ID), "session_key", key, "error", err)
+				}
+			}
+		})
+	}
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	// Helper: synchronously process an event through the gateway and return the response.
+	processEvent := func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		done := make(chan *gateway.RunResult, 1)
+		if err := gw.HandleInbound(ctx, event, gateway.WithOnResult(func(result *gateway.RunResult) {
+			done <- result
+		})); err != nil {
+			return nil, err
+		}
+		return <-done, nil
+	}
+	processTask := func(sessionKey, prompt string) (string, error) {
+		res, err := processEvent(&types.InboundEvent{
+			Source:     "task",
+			SessionKey: types.SessionKey(sessionKey),
+			UserID:     "system",
+			Text:       prompt,
+		})
+		if err != nil {
+			return "", err
+		}
+		return res.Text, nil
+	}
+
+	// Scheduler
+	sched := scheduler.New(taskStore, func(sessionKey, prompt string) (string, error) {
+		response, err := processTask(sessionKey, prompt)
+		if err != nil {
+			slog.Error("cron task failed", "session_key", sessionKey, "error", err)
+			return "", err
+		}
+		return response, nil // empty: bot decided not to respond
+	})
+	if err := sched.SetTimezone(cfg.Scheduler.Timezone); err != nil {
+		return fmt.Errorf("scheduler: %w", err)
+	}
+	sched.SetDeliverer(func(task *state.Task, response string) error {
+		return delivery.Fanout(task.Targets(), func(target string) error {
+			message, err := delivery.Apply(task, target, response, time.Now())
+			if err != nil {
+				return err
+			}
+			return outbox.Deliver(target, message, "task:"+task.Name)
+		})
+	})
+	pipelines := pipeline.NewStore(filepath.Join(cfg.DataDir, "pipelines"))
+	sched.SetPipelineRunner(func(task *state.Task, llm scheduler.Handler) (string, error) {
+		p, err := pipelines.Get(task.Pipeline)
+		if err != nil {
+			return "", err
+		}
+		return p.Execute(ctx, task.Name, func(prompt string) (string, error) {
+			return llm(task.SessionKey, prompt)
+		})
+	})
+	// Heartbeat check-ins
+	hb, err := newHeartbeat(cfg, taskStore, processEvent, outbox)
+	if err != nil {
+		return err
+	}
+
+	// alertAdmins sends an alert to each of telegram.admins.
+	alertAdmins := func(source, message string) {
+		if len(cfg.Telegram.Admins) == 0 {
+			slog.Error("alert has no recipient; set telegram.admins", "source", source)
+			return
+		}
+		for _, id := range cfg.Telegram.Admins {
+			// An admin's private chat with the bot has the admin's user ID.
+			key := string(types.TelegramKey(id, id))
+			if err := outbox.Deliver(key, message, "alert:"+source); err != nil {
+				slog.Error("alert delivery failed", "source", source, "admin", id, "error", err)
+			}
+		}
+	}
+	sched.SetOnLoad(applyConcurrency)
+	sched.SetAlerter(func(task, message string) {
+		if hb != nil {
+			if err := hb.Flag(message); err != nil {
+				slog.Warn("failed to flag task alert for heartbeat", "task", task, "error", err)
+			}
+		}
+		alertAdmins(task, message)
+	})
+	// Reminders go out with snooze buttons on Telegram; if that fails, or
+	// on other channels, as plain text through the outbox.
+	sched.SetReminders(reminders, func(r *state.Reminder) error {
+		if adapter != nil && types.SessionKey(r.SessionKey).Channel() == "telegram" {
+			err := adapter.SendReminder(r)
+			if err == nil {
+				return nil
+			}
+			slog.Warn("reminder send failed, queueing plain text", "id", r.ID, "error", err)
+		}
+		return outbox.Deliver(r.SessionKey, "Reminder: "+r.Text, "reminder:"+r.ID)
+	})
+	defer sched.Stop()
+
+	// Watchdog: restarts the Telegram poller, scheduler or HTTP server if
+	// one stops responding.
+	var watchdogInterval time.Duration
+	if !cfg.Watchdog.Disabled {
+		watchdogInterval = defaultWatchdogInterval
+		if cfg.Watchdog.Interval != "" {
+			if watchdogInterval, err = time.ParseDuration(cfg.Watchdog.Interval); err != nil {
+				return fmt.Errorf("parse watchdog.interval: %w", err)
+			}
+		}
+	}
+	dog := watchdog.New(func(message string) { alertAdmins("watchdog", message) })
+
+	// Nightly maintenance
+	job, err := newMaintenance(cfg, c, func(report string) error {
+		alertAdmins("maintenance", report)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var webhookSrv *webhook.Server
+	startLeader := func() error {
+		if follower {
+			// The old leader is gone; what it left running won't finish.
+			c.recover(time.Now(), false)
+		}
+		if webhookSrv != nil {
+			webhookSrv.SetReadOnly(false)
+		}
+		if adapter != nil {
+			go adapter.Start(ctx)
+			slog.Info("telegram adapter started")
+			dog.Add(watchdog.Component{
+				Name:    "telegram poller",
+				Check:   watchdog.Stale(adapter.LastPoll, telegramStale),
+				Restart: adapter.Restart,
+			})
+		}
+		if signalAdapter != nil {
+			go signalAdapter.Start(ctx)
+			slog.Info("signal adapter started", "number", cfg.Signal.Number)
+		}
+		if mailer != nil {
+			go mailer.Start(ctx)
+			slog.Info("email poller started", "mailbox", cmp.Or(cfg.Email.IMAP.Mailbox, "INBOX"))
+		}
+		if err := sched.Start(); err != nil {
+			return fmt.Errorf("start scheduler: %w", err)
+		}
+		slog.Info("scheduler started")
+		dog.Add(watchdog.Component{
+			Name:    "scheduler",
+			Check:   watchdog.Stale(sched.LastBeat, schedulerStale),
+			Restart: sched.Reload,
+		})
+		if hb != nil {
+			go hb.Start(ctx)
+			slog.Info("heartbeat started", "interval", cfg.Heartbeat.Interval)
+		}
+		if job != nil {
+			go job.Start(ctx)
+			slog.Info("maintenance scheduled", "at", cfg.Maintenance.At)
+		}
+		go outbox.Run(ctx, retryInterval)
+		return nil
+	}
+
+	// Webhook HTTP server
+	if cfg.HTTP.Enabled {
+		webhookSrv = webhook.NewServer(taskStore, processTask, sessions, events, artifacts)
+		// A follower shares the leader's files without its locks, so it
+		// only serves reads until it takes over.
+		webhookSrv.SetReadOnly(follower)
+		webhookSrv.SetRunHandler(processEvent)
+		webhookSrv.SetScheduler(sched)
+		webhookSrv.SetBroadcaster(broadcast)
+		webhookSrv.SetMacroStore(macroStore)
+		webhookSrv.SetRunStore(c.runs)
+		webhookSrv.SetPromptPreviewer(rt)
+		webhookSrv.SetToolNames(toolNames)
+		webhookSrv.SetModelRegistry(c.models)
+		if pushStore != nil {
+			webhookSrv.SetPush(pushStore, pushKeys.Public)
+		}
+		webhookSrv.SetPublicURL(cfg.HTTP.PublicURL)
+		webhookSrv.SetLinkSecret(cmp.Or(cfg.HTTP.AdminToken, cfg.HTTP.ObserverToken))
+		if adapter != nil && cfg.HTTP.PublicURL != "" {
+			adapter.SetArtifactLinker(webhookSrv.ArtifactURL)
+		}
+		if cfg.HTTP.Pprof {
+			webhookSrv.EnableProfiling()
+			slog.Warn("pprof enabled", "listen", cfg.HTTP.Listen, "path", "/debug/pprof/")
+		}
+		tokens := make(map[string]webhook.Role)
+		if cfg.HTTP.ObserverToken != "" {
+			tokens[cfg.HTTP.ObserverToken] = webhook.RoleObserver
+		}
+		if cfg.HTTP.AdminToken != "" {
+			tokens[cfg.HTTP.AdminToken] = webhook.RoleAdmin
+		}
+		webhookSrv.SetTokens(tokens)
+		tokenStore := state.NewTokenStore(filepath.Join(cfg.DataDir, "tokens.json"))
+		webhookSrv.SetTokenStore(tokenStore)
+		if cfg.HTTP.ObserverToken != "" && cfg.HTTP.AdminToken == "" {
+			slog.Warn("http.observer_token is set without http.admin_token; runs, webhooks and admin endpoints are unreachable over HTTP")
+		}
+		stored, err := tokenStore.List()
+		if err != nil {
+			return fmt.Errorf("load api tokens: %w", err)
+		}
+		if webhook.Exposed(cfg.HTTP.Listen) && len(tokens) == 0 && len(stored) == 0 {
+			slog.Warn("HTTP server is reachable from other machines and has no authentication; anyone who can connect can read sessions and run prompts",
+				"listen", cfg.HTTP.Listen)
+		}
+		var (
+			httpMu     sync.Mutex
+			httpServer *http.Server
+			httpAddr   net.Addr
+		)
+		startHTTP := func() error {
+			ln, err := webhook.Listen(cfg.HTTP.Listen)
+			if err != nil {
+				return fmt.Errorf("start webhook server: %w", err)
+			}
+			srv := &http.Server{Handler: webhookSrv}
+			httpMu.Lock()
+			httpServer, httpAddr = srv, ln.Addr()
+			httpMu.Unlock()
+			go func() {
+				slog.Info("webhook server started", "listen", ln.Addr().String())
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					slog.Error("webhook server error", "error", err)
+				}
+			}()
+			return nil
+		}
+		if err := startHTTP(); err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			httpMu.Lock()
+			httpServer.Close()
+			httpMu.Unlock()
+		}()
+		dog.Add(watchdog.Component{
+			Name: "http server",
+			Check: func(ctx context.Context) error {
+				httpMu.Lock()
+				addr := httpAddr
+				httpMu.Unlock()
+				return probeHTTP(ctx, addr)
+			},
+			Restart: func() error {
+				httpMu.Lock()
+				httpServer.Close()
+				httpMu.Unlock()
+				return startHTTP()
+			},
+		})
+	}
+	if watchdogInterval > 0 {
+		go dog.Run(ctx, watchdogInterval)
+	}
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		leaderErr <- lead(ctx, lease, leaseTTL, startLeader)
+	}()
+	defer func() {
+		// Stop leader work before handing the lease over.
+		cancel()
+		if err := lease.Release(); err != nil {
+			slog.Warn("failed to release leader lease", "error", err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		var sig os.Signal
+		select {
+		case sig = <-sigChan:
+		case err := <-leaderErr:
+			// A deposed leader exits rather than risk double-processing;
+			// the supervisor restarts it as a follower.
+			return err
+		}
+		if sig == syscall.SIGHUP {
+			slog.Info("received SIGHUP, waiting for in-flight requests to complete")
+			if ok := gw.Queue.WaitIdle(30 * time.Second); !ok {
+				slog.Warn("timed out waiting for in-flight requests, restarting anyway")
+			} else {
+				slog.Info("all in-flight requests completed")
+			}
+			execPath, err := os.Executable()
+			if err != nil {
+				slog.Error("failed to get executable path", "error", err)
+				continue
+			}
+			// Clean up PID file before re-exec
+			os.Remove(pidPath)
+			if err := syscall.Exec(execPath, os.Args, os.Environ()); err != nil {
+				slog.Error("failed to re-exec", "error", err)
+				// Re-write PID file since we failed to re-exec
+				if _, writeErr := writePIDFile(cfg.DataDir); writeErr != nil {
+					slog.Error("failed to re-write PID file", "error", writeErr)
+				}
+				continue
+			}
+		}
+		// SIGINT or SIGTERM
+		slog.Info("shutting down", "signal", sig)
+		return nil
+	}
+}
+
+// Watchdog defaults: how often components are probed, and how long the
+// Telegram poller and scheduler may go without a sign of life. An idle
+// poller reports in every 30s and a hung request times out after 90s; the
+// scheduler's ticker beats every 10s.
+const (
+	defaultWatchdogInterval = time.Minute
+	telegramStale           = 3 * time.Minute
+	schedulerStale          = time.Minute
+)
+
+// probeHTTP checks that the webhook server at addr answers /health.
+func probeHTTP(ctx context.Context, addr net.Addr) error {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, addr.Network(), addr.String())
+		},
+	}}
+	defer client.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://gopherclaw/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeTimeout bounds the startup provider check.
+const probeTimeout = 20 * time.Second
+
+// probeModel checks that the configured provider answers for model, asking
+// for a single output token to keep the check cheap.
+func probeModel(cfg *config.Config, model string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	provider, err := newProvider(cfg, &llm.Config{
+		BaseURL:   cfg.LLM.BaseURL,
+		APIKey:    cfg.LLM.APIKey,
+		Model:     model,
+		MaxTokens: 1,
+	})
+	if err != nil {
+		return err
+	}
+	return llm.Probe(ctx, provider)
+}
+
+// chaosInjector builds the fault injector from the chaos config section.
+func chaosInjector(cfg *config.Config) (*chaos.Injector, error) {
+	c := chaos.Config{
+		ErrorRate:     cfg.Chaos.ErrorRate,
+		RateLimitRate: cfg.Chaos.RateLimitRate,
+		Seed:          cfg.Chaos.Seed,
+	}
+	if cfg.Chaos.Latency != "" {
+		d, err := time.ParseDuration(cfg.Chaos.Latency)
+		if err != nil {
+			return nil, fmt.Errorf("parse chaos.latency: %w", err)
+		}
+		c.Latency = d
+	}
+	if cfg.Chaos.Jitter != "" {
+		d, err := time.ParseDuration(cfg.Chaos.Jitter)
+		if err != nil {
+			return nil, fmt.Errorf("parse chaos.jitter: %w", err)
+		}
+		c.Jitter = d
+	}
+	return chaos.New(c), nil
+}
+
+// defaultContextWindow is used when the model is unknown to the registry and
+// llm.max_context_tokens is unset.
+const defaultContextWindow = 128000
+
+// newWebhookSinks builds the "http:" delivery handler from config, or
+// returns nil when no webhooks are configured.
+func newWebhookSinks(cfg *config.Config) *delivery.Webhooks {
+	if len(cfg.Delivery.Webhooks) == 0 && !cfg.Delivery.WebhookURLs {
+		return nil
+	}
+	sinks := make(map[string]delivery.WebhookSink, len(cfg.Delivery.Webhooks))
+	for name, sink := range cfg.Delivery.Webhooks {
+		sinks[name] = delivery.WebhookSink{URL: sink.URL, Secret: sink.Secret, Headers: sink.Headers}
+	}
+	return delivery.NewWebhooks(sinks, cfg.Delivery.WebhookURLs, cfg.Delivery.WebhookSecret)
+}
+
+// newEmail builds the SMTP sender and, if IMAP is configured, the mailbox
+// poller from config. Both are nil when email.smtp is unset.
+func newEmail(cfg *config.Config, gw *gateway.Gateway) (*email.Sender, *email.Adapter, error) {
+	smtpCfg, imapCfg := cfg.Email.SMTP, cfg.Email.IMAP
+	if smtpCfg.Addr == "" {
+		if imapCfg.Addr != "" {
+			return nil, nil, fmt.Errorf("email.imap needs email.smtp to send replies")
+		}
+		return nil, nil, nil
+	}
+	sender, err := email.NewSender(smtpCfg.Addr, smtpCfg.From, smtpCfg.Username, smtpCfg.Password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("email.smtp: %w", err)
+	}
+	if imapCfg.Addr == "" {
+		return sender, nil, nil
+	}
+	if len(cfg.Email.AllowedSenders) == 0 {
+		slog.Warn("email.imap is set without email.allowed_senders; not polling")
+		return sender, nil, nil
+	}
+	interval := time.Minute
+	if imapCfg.PollInterval != "" {
+		if interval, err = time.ParseDuration(imapCfg.PollInterval); err != nil {
+			return nil, nil, fmt.Errorf("parse email.imap.poll_interval: %w", err)
+		}
+	}
+	mailer, err := email.NewAdapter(email.IMAPConfig{
+		Addr:           imapCfg.Addr,
+		Username:       cmp.Or(imapCfg.Username, smtpCfg.Username),
+		Password:       cmp.Or(imapCfg.Password, smtpCfg.Password),
+		Mailbox:        imapCfg.Mailbox,
+		Insecure:       imapCfg.Insecure,
+		Interval:       interval,
+		AllowedSenders: cfg.Email.AllowedSenders,
+	}, gw, sender)
+	if err != nil {
+		return nil, nil, fmt.Errorf("email.imap: %w", err)
+	}
+	return sender, mailer, nil
+}
+
+// newMaintenance builds the nightly maintenance job from config, or returns
+// nil when it is disabled.
+func newMaintenance(cfg *config.Config, c *core, notify maintenance.Notifier) (*maintenance.Job, error) {
+	if !cfg.Maintenance.Enabled {
+		return nil, nil
+	}
+	at, err := time.Parse("15:04", cfg.Maintenance.At)
+	if err != nil {
+		return nil, fmt.Errorf("parse maintenance.at %q: want HH:MM", cfg.Maintenance.At)
+	}
+	mc := maintenance.Config{
+		At:          time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute,
+		KeepBackups: cfg.Maintenance.KeepBackups,
+	}
+	if !cfg.Maintenance.NoBackup {
+		mc.BackupDir = cfg.Maintenance.BackupDir
+		if mc.BackupDir == "" {
+			mc.BackupDir = filepath.Join(cfg.DataDir, "backups")
+		}
+		if mc.Recipients, err = backup.ParseRecipients(cfg.Maintenance.EncryptTo); err != nil {
+			return nil, fmt.Errorf("maintenance.encrypt_to: %w", err)
+		}
+	}
+	return maintenance.New(mc, cfg.DataDir, c.sessions, c.events, notify), nil
+}
+
+// newHeartbeat builds the heartbeat from config, or returns nil when it is
+// disabled. Check-ins run in their own session so "nothing to report" turns
+// stay out of the user's conversation; messages go to heartbeat.session_key.
+func newHeartbeat(cfg *config.Config, tasks *state.TaskStore, process webhook.RunHandler, outbox *delivery.Outbox) (*heartbeat.Heartbeat, error) {
+	if !cfg.Heartbeat.Enabled {
+		return nil, nil
+	}
+	target := cfg.Heartbeat.SessionKey
+	if target == "" && len(cfg.Telegram.Admins) > 0 {
+		id := cfg.Telegram.Admins[0]
+		target = string(types.TelegramKey(id, id))
+	}
+	if target == "" {
+		return nil, fmt.Errorf("heartbeat is enabled but has no recipient; set heartbeat.session_key or telegram.admins")
+	}
+	if _, err := types.ParseSessionKey(target); err != nil {
+		return nil, fmt.Errorf("heartbeat.session_key: %w", err)
+	}
+
+	hc := heartbeat.Config{MaxRuns: cfg.Heartbeat.MaxRuns, MaxMessages: cfg.Heartbeat.MaxMessages}
+	var err error
+	if hc.Interval, err = time.ParseDuration(cfg.Heartbeat.Interval); err != nil || hc.Interval <= 0 {
+		return nil, fmt.Errorf("parse heartbeat.interval %q: %v", cfg.Heartbeat.Interval, err)
+	}
+	if cfg.Heartbeat.MinGap != "" {
+		if hc.MinGap, err = time.ParseDuration(cfg.Heartbeat.MinGap); err != nil {
+			return nil, fmt.Errorf("parse heartbeat.min_gap: %w", err)
+		}
+	}
+	if hc.QuietStart, hc.QuietEnd, err = heartbeat.ParseQuietHours(cfg.Heartbeat.QuietHours); err != nil {
+		return nil, fmt.Errorf("parse heartbeat.quiet_hours: %w", err)
+	}
+	if cfg.Heartbeat.ChecklistPath != "" {
+		data, err := os.ReadFile(cfg.Heartbeat.ChecklistPath)
+		if err != nil {
+			return nil, fmt.Errorf("read heartbeat checklist: %w", err)
+		}
+		hc.Checklist = string(data)
+	}
+
+	hb := heartbeat.New(hc, state.NewHeartbeatStore(filepath.Join(cfg.DataDir, "heartbeat.json")),
+		func(prompt string) (string, error) {
+			res, err := process(&types.InboundEvent{
+				Source:     "heartbeat",
+				SessionKey: types.SessionKey("heartbeat:" + target),
+				UserID:     "system",
+				Text:       prompt,
+			})
+			if err != nil {
+				return "", err
+			}
+			return res.Text, nil
+		},
+		func(message string) error { return outbox.Deliver(target, message, "heartbeat") })
+	hb.SetStatus(func() []string {
+		list, err := tasks.List()
+		if err != nil {
+			return []string{"Could not load scheduled tasks: " + err.Error()}
+		}
+		var lines []string
+		for _, t := range list {
+			if !t.Enabled || t.LastRun == nil {
+				continue
+			}
+			if problem := cmp.Or(t.LastRun.Error, t.LastRun.Violation); problem != "" {
+				lines = append(lines, fmt.Sprintf("Task %q last ran at %s and failed: %s", t.Name, t.LastRun.At.Format(time.RFC1123), problem))
+			}
+		}
+		return lines
+	})
+	return hb, nil
+}
+
+// notifyLongRuns wraps process so that runs taking at least after tell
+// every subscribed browser when they finish. Heartbeat check-ins and runs
+// in webpush: sessions, whose replies are pushed anyway, are left out.
+func notifyLongRuns(process func(*gateway.Run) error, after time.Duration, notifier *webpush.Notifier) func(*gateway.Run) error {
+	return func(run *gateway.Run) error {
+		switch run.Event.SessionKey.Channel() {
+		case "heartbeat", "webpush":
+			return process(run)
+		}
+		start := time.Now()
+		var response string
+		onComplete := run.OnComplete
+		run.OnComplete = func(r string) {
+			response = r
+			if onComplete != nil {
+				onComplete(r)
+			}
+		}
+		err := process(run)
+		if elapsed := time.Since(start); err == nil && elapsed >= after {
+			ctx := context.WithoutCancel(run.Ctx)
+			go func() {
+				if err := notifier.NotifyRunDone(ctx, run.Event.SessionKey, run.SessionID, elapsed, response); err != nil {
+					slog.WarnContext(ctx, "long run push notification failed", "error", err)
+				}
+			}()
+		}
+		return err
+	}
+}
+
+// defaultLeaseTTL is how long a silent leader keeps the lease.
+const defaultLeaseTTL = 15 * time.Second
+
+// lead waits until this instance holds the leader lease, calls start once,
+// and then keeps renewing the lease every third of its TTL. It returns nil
+// when ctx ends and an error if start fails or the lease is lost.
+func lead(ctx context.Context, lease *state.Lease, ttl time.Duration, start func() error) error {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	leading, waiting := false, false
+	var renewed time.Time
+	for {
+		ok, err := lease.Acquire()
+		switch {
+		case err != nil:
+			slog.Warn("leader lease check failed", "error", err)
+			if leading && time.Since(renewed) > ttl {
+				return fmt.Errorf("leader lease expired: %w", err)
+			}
+		case ok && !leading:
+			leading, renewed = true, time.Now()
+			slog.Info("acquired leader lease", "holder", lease.Holder())
+			if err := start(); err != nil {
+				return err
+			}
+		case ok:
+			renewed = time.Now()
+		case leading:
+			return fmt.Errorf("lost leader lease to another instance")
+		case !waiting:
+			waiting = true
+			holder := ""
+			if cur, err := lease.Current(); err == nil && cur != nil {
+				holder = cur.Holder
+			}
+			slog.Info("another instance is leader; serving HTTP only until it steps down", "leader", holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,349 @@
+// Package backup writes session transcripts and agent data to a portable
+// archive and restores them, optionally encrypted to age recipients so
+// backups can be kept in untrusted storage.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// manifestName is the first entry of every archive.
+const manifestName = "manifest.json"
+
+// ageHeader starts every age-encrypted file.
+const ageHeader = "age-encryption.org/v1\n"
+
+// dataFiles are the top-level files of the data dir a full backup
+// includes. config.json is left out: it holds API keys and tokens.
+var dataFiles = []string{"memory.md", "tasks.json", "macros.json"}
+
+// ErrEncrypted is returned when restoring an encrypted backup without an
+// identity.
+var ErrEncrypted = errors.New("backup is encrypted; pass an identity to decrypt it")
+
+// Manifest describes an archive's contents.
+type Manifest struct {
+	Created  time.Time             `json:"created"`
+	Sessions []*types.SessionIndex `json:"sessions"`
+	// Files lists the top-level data files included.
+	Files []string `json:"files,omitempty"`
+}
+
+// Options select what Create writes.
+type Options struct {
+	// Sessions limits the backup to these sessions. Empty backs up every
+	// session plus memory, tasks and macros.
+	Sessions []types.SessionID
+	// Recipients encrypt the archive with age; empty writes it in the
+	// clear.
+	Recipients []age.Recipient
+}
+
+// Create writes a gzipped tar of the selected sessions' index entries,
+// events and artifacts in dataDir to w.
+func Create(ctx context.Context, w io.Writer, dataDir string, opts Options) (*Manifest, error) {
+	all, err := state.NewSessionStore(dataDir).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	manifest := &Manifest{Created: time.Now().UTC(), Sessions: all}
+	if len(opts.Sessions) > 0 {
+		manifest.Sessions = nil
+		for _, id := range opts.Sessions {
+			sess, err := findSession(all, id)
+			if err != nil {
+				return nil, err
+			}
+			manifest.Sessions = append(manifest.Sessions, sess)
+		}
+	} else {
+		for _, name := range dataFiles {
+			if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+				manifest.Files = append(manifest.Files, name)
+			}
+		}
+	}
+
+	out := w
+	var enc io.WriteCloser
+	if len(opts.Recipients) > 0 {
+		if enc, err = age.Encrypt(w, opts.Recipients...); err != nil {
+			return nil, fmt.Errorf("encrypt backup: %w", err)
+		}
+		out = enc
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := writeEntry(tw, manifestName, manifest.Created, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Files {
+		if err := addFile(tw, dataDir, name); err != nil {
+			return nil, err
+		}
+	}
+	for _, sess := range manifest.Sessions {
+		if err := addSession(tw, dataDir, sess.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("close archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("close archive: %w", err)
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("encrypt backup: %w", err)
+		}
+	}
+	return manifest, nil
+}
+
+// findSession returns the session with the given ID from the list.
+func findSession(sessions []*types.SessionIndex, id types.SessionID) (*types.SessionIndex, error) {
+	for _, sess := range sessions {
+		if sess.SessionID == id {
+			return sess, nil
+		}
+	}
+	return nil, fmt.Errorf("session not found: %s", id)
+}
+
+// addSession adds every file under a session's directory.
+func addSession(tw *tar.Writer, dataDir string, id types.SessionID) error {
+	root := filepath.Join(dataDir, "sessions", string(id))
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dataDir, p)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, dataDir, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return fmt.Errorf("back up session %s: %w", id, err)
+	}
+	return nil
+}
+
+// addFile adds dataDir/name to the archive under name.
+func addFile(tw *tar.Writer, dataDir, name string) error {
+	f, err := os.Open(filepath.Join(dataDir, filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", name, err)
+	}
+	// Event logs may grow while we copy; the header size bounds the read.
+	return writeEntry(tw, name, info.ModTime(), f, info.Size())
+}
+
+func writeEntry(tw *tar.Writer, name string, mod time.Time, r io.Reader, size int64) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: mod, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Result summarizes a restore.
+type Result struct {
+	Restored []*types.SessionIndex
+	// Skipped lists sessions whose ID already exists in the data dir.
+	Skipped []*types.SessionIndex
+	// Files lists the top-level data files written. Existing files are
+	// never overwritten.
+	Files []string
+}
+
+// Restore reads an archive written by Create into dataDir. Sessions that
+// already exist are skipped; a session whose key is now used by another
+// one is restored archived. Encrypted archives need a matching identity.
+func Restore(ctx context.Context, r io.Reader, dataDir string, identities []age.Identity) (*Result, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(ageHeader)); string(head) == ageHeader {
+		if len(identities) == 0 {
+			return nil, ErrEncrypted
+		}
+		dec, err := age.Decrypt(br, identities...)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt backup: %w", err)
+		}
+		r = dec
+	} else {
+		r = br
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read backup: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return nil, fmt.Errorf("read backup: missing %s", manifestName)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	// Decide up front which sessions to restore so their files can be
+	// written before the index points at them.
+	sessions := state.NewSessionStore(dataDir)
+	existing, err := sessions.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	result := &Result{}
+	restore := make(map[types.SessionID]bool)
+	for _, sess := range manifest.Sessions {
+		if _, err := findSession(existing, sess.SessionID); err == nil {
+			result.Skipped = append(result.Skipped, sess)
+			continue
+		}
+		restore[sess.SessionID] = true
+	}
+	files := make(map[string]bool)
+	for _, name := range manifest.Files {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); os.IsNotExist(err) {
+			files[name] = true
+		}
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read backup: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !fs.ValidPath(name) {
+			continue
+		}
+		if parts := strings.Split(name, "/"); len(parts) > 2 && parts[0] == "sessions" {
+			if !restore[types.SessionID(parts[1])] {
+				continue
+			}
+		} else if files[name] {
+			result.Files = append(result.Files, name)
+		} else {
+			continue
+		}
+		if err := extract(tr, filepath.Join(dataDir, filepath.FromSlash(name)), hdr.ModTime); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, sess := range manifest.Sessions {
+		if !restore[sess.SessionID] {
+			continue
+		}
+		if _, err := sessions.Restore(ctx, sess); err != nil {
+			return nil, fmt.Errorf("restore session %s: %w", sess.SessionID, err)
+		}
+		result.Restored = append(result.Restored, sess)
+	}
+	return result, nil
+}
+
+// extract writes one archive entry to dst atomically.
+func extract(r io.Reader, dst string, mod time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", dst, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", dst, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename %s: %w", dst, err)
+	}
+	_ = os.Chtimes(dst, mod, mod)
+	return nil
+}
+
+// ParseRecipients parses age public keys ("age1...").
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("parse recipient %q: %w", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// ReadIdentities reads age identity files, as written by age-keygen.
+func ReadIdentities(paths []string) ([]age.Identity, error) {
+	var identities []age.Identity
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("open identity file: %w", err)
+		}
+		ids, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parse identity file %s: %w", p, err)
+		}
+		identities = append(identities, ids...)
+	}
+	return identities, nil
+}
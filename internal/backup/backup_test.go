@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// seed creates a data dir with two sessions, each with one event.
+func seed(t *testing.T) (string, []types.SessionID) {
+	t.Helper()
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	var ids []types.SessionID
+	for _, key := range []string{"telegram:1:1", "http:ops"} {
+		id, err := sessions.ResolveOrCreate(ctx, types.SessionKey(key), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := events.Append(ctx, &types.Event{
+			ID: types.NewEventID(), SessionID: id, Type: "user_message", Source: "test",
+			Payload: []byte(`{"text":"secret plans for ` + key + `"}`),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory.md"), []byte("- likes tea\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, ids
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	src, ids := seed(t)
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := ParseRecipients([]string{identity.Recipient().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := Create(ctx, &buf, src, Options{Recipients: recipients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Sessions) != 2 || len(manifest.Files) != 1 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret plans")) || !strings.HasPrefix(buf.String(), ageHeader) {
+		t.Fatal("backup is not encrypted")
+	}
+
+	dst := t.TempDir()
+	if _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), dst, nil); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("expected ErrEncrypted without an identity, got %v", err)
+	}
+	other, _ := age.GenerateX25519Identity()
+	if _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), dst, []age.Identity{other}); err == nil {
+		t.Fatal("expected an error with the wrong identity")
+	}
+
+	result, err := Restore(ctx, bytes.NewReader(buf.Bytes()), dst, []age.Identity{identity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Restored) != 2 || len(result.Files) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	events, err := state.NewEventStore(dst).Tail(ctx, ids[0], 10)
+	if err != nil || len(events) != 1 || !strings.Contains(string(events[0].Payload), "telegram:1:1") {
+		t.Fatalf("events not restored: %v, %v", events, err)
+	}
+	if sess, err := state.NewSessionStore(dst).Get(ctx, ids[1]); err != nil || sess.SessionKey != "http:ops" {
+		t.Fatalf("session not restored: %+v, %v", sess, err)
+	}
+
+	// Restoring again skips what is already there.
+	result, err = Restore(ctx, bytes.NewReader(buf.Bytes()), dst, []age.Identity{identity})
+	if err != nil || len(result.Restored) != 0 || len(result.Skipped) != 2 || len(result.Files) != 0 {
+		t.Fatalf("expected everything skipped, got %+v, %v", result, err)
+	}
+}
+
+func TestSessionBackup(t *testing.T) {
+	src, ids := seed(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	manifest, err := Create(ctx, &buf, src, Options{Sessions: ids[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Sessions) != 1 || len(manifest.Files) != 0 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	// The target already has a session under the same key, so the restored
+	// one comes back archived.
+	dst := t.TempDir()
+	if _, err := state.NewSessionStore(dst).ResolveOrCreate(ctx, "http:ops", "default"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Restore(ctx, &buf, dst, nil)
+	if err != nil || len(result.Restored) != 1 {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	sess, err := state.NewSessionStore(dst).Get(ctx, ids[1])
+	if err != nil || sess.Status != "archived" || sess.SessionKey != types.SessionKey("archived:"+string(ids[1])) {
+		t.Fatalf("expected an archived session, got %+v, %v", sess, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sessions", string(ids[0]))); !os.IsNotExist(err) {
+		t.Error("unselected session was restored")
+	}
+}
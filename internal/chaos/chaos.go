@@ -0,0 +1,144 @@
+// Package chaos wraps providers and tools with injected latency, errors and
+// rate limits so retry, cancellation and budget handling can be exercised
+// without waiting for a real outage. It is meant for tests and staging.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// ErrInjected is returned (wrapped) for injected failures.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// ErrRateLimited is returned (wrapped) for injected rate limits. The
+// wrapping error mirrors the provider's "API error (status 429)" text and
+// also wraps llm.ErrRateLimited, so retries and failover treat it as a
+// real rate limit.
+var ErrRateLimited = errors.New("chaos: injected rate limit")
+
+// Config controls what is injected. Rates are probabilities in [0, 1]
+// checked on every call; latency is applied before the error checks.
+type Config struct {
+	Latency       time.Duration
+	Jitter        time.Duration
+	ErrorRate     float64
+	RateLimitRate float64
+	// Seed makes injection deterministic when non-zero.
+	Seed int64
+}
+
+// Injector applies a Config to wrapped providers and tools.
+type Injector struct {
+	cfg Config
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates an Injector for cfg.
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (i *Injector) float() float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64()
+}
+
+// inject sleeps for the configured latency, honouring ctx, then decides
+// whether this call fails.
+func (i *Injector) inject(ctx context.Context) error {
+	delay := i.cfg.Latency
+	if i.cfg.Jitter > 0 {
+		delay += time.Duration(i.float() * float64(i.cfg.Jitter))
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if i.cfg.RateLimitRate > 0 && i.float() < i.cfg.RateLimitRate {
+		return fmt.Errorf("API error (status 429): %w: %w", ErrRateLimited, llm.ErrRateLimited)
+	}
+	if i.cfg.ErrorRate > 0 && i.float() < i.cfg.ErrorRate {
+		return ErrInjected
+	}
+	return nil
+}
+
+// Provider wraps p so every call passes through the injector first.
+func (i *Injector) Provider(p llm.Provider) llm.Provider {
+	return &provider{inner: p, inj: i}
+}
+
+type provider struct {
+	inner llm.Provider
+	inj   *Injector
+}
+
+func (p *provider) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	if err := p.inj.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.inner.Complete(ctx, messages, tools)
+}
+
+func (p *provider) Stream(ctx context.Context, messages []llm.Message, tools []llm.Tool) (<-chan llm.Delta, error) {
+	if err := p.inj.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.inner.Stream(ctx, messages, tools)
+}
+
+// Tool wraps t so every execution passes through the injector first. The
+// wrapped tool keeps t's name, description and parameters, and stays a
+// runtime.SessionTool if t is one.
+func (i *Injector) Tool(t runtime.Tool) runtime.Tool {
+	if st, ok := t.(runtime.SessionTool); ok {
+		return &sessionTool{tool: tool{Tool: t, inj: i}, inner: st}
+	}
+	return &tool{Tool: t, inj: i}
+}
+
+type tool struct {
+	runtime.Tool
+	inj *Injector
+}
+
+func (t *tool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	if err := t.inj.inject(ctx); err != nil {
+		return "", fmt.Errorf("%s: %w", t.Name(), err)
+	}
+	return t.Tool.Execute(ctx, args)
+}
+
+type sessionTool struct {
+	tool
+	inner runtime.SessionTool
+}
+
+func (t *sessionTool) ExecuteInSession(ctx context.Context, session *types.SessionIndex, args json.RawMessage) (string, error) {
+	if err := t.inj.inject(ctx); err != nil {
+		return "", fmt.Errorf("%s: %w", t.Name(), err)
+	}
+	return t.inner.ExecuteInSession(ctx, session, args)
+}
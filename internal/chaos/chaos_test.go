@@ -0,0 +1,113 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+type stubProvider struct{ calls int }
+
+func (s *stubProvider) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	s.calls++
+	return &llm.Response{Content: "ok"}, nil
+}
+
+func (s *stubProvider) Stream(ctx context.Context, messages []llm.Message, tools []llm.Tool) (<-chan llm.Delta, error) {
+	s.calls++
+	ch := make(chan llm.Delta)
+	close(ch)
+	return ch, nil
+}
+
+type stubTool struct{}
+
+func (stubTool) Name() string                { return "stub" }
+func (stubTool) Description() string         { return "stub tool" }
+func (stubTool) Parameters() json.RawMessage { return json.RawMessage(`{}`) }
+func (stubTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	return "done", nil
+}
+
+func TestProviderPassThrough(t *testing.T) {
+	inner := &stubProvider{}
+	p := New(Config{}).Provider(inner)
+
+	resp, err := p.Complete(context.Background(), nil, nil)
+	if err != nil || resp.Content != "ok" {
+		t.Fatalf("expected pass-through, got %v, %v", resp, err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected inner provider to be called once, got %d", inner.calls)
+	}
+}
+
+func TestProviderInjectedError(t *testing.T) {
+	inner := &stubProvider{}
+	p := New(Config{ErrorRate: 1}).Provider(inner)
+
+	_, err := p.Complete(context.Background(), nil, nil)
+	if !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected ErrInjected, got %v", err)
+	}
+	if _, err := p.Stream(context.Background(), nil, nil); !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected ErrInjected from Stream, got %v", err)
+	}
+	if inner.calls != 0 {
+		t.Errorf("expected inner provider not to be called, got %d", inner.calls)
+	}
+}
+
+func TestProviderInjectedRateLimit(t *testing.T) {
+	p := New(Config{RateLimitRate: 1}).Provider(&stubProvider{})
+
+	_, err := p.Complete(context.Background(), nil, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Errorf("expected the llm rate limit class, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "status 429") {
+		t.Errorf("expected 429 in error text, got %q", err)
+	}
+}
+
+func TestLatencyHonoursCancellation(t *testing.T) {
+	p := New(Config{Latency: time.Minute}).Provider(&stubProvider{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Complete(ctx, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected latency to be cut short by cancellation")
+	}
+}
+
+func TestToolWrapper(t *testing.T) {
+	inj := New(Config{ErrorRate: 1})
+	wrapped := inj.Tool(stubTool{})
+
+	if wrapped.Name() != "stub" {
+		t.Errorf("expected wrapped tool to keep its name, got %q", wrapped.Name())
+	}
+	_, err := wrapped.Execute(context.Background(), nil)
+	if !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected ErrInjected, got %v", err)
+	}
+
+	out, err := New(Config{}).Tool(stubTool{}).Execute(context.Background(), nil)
+	if err != nil || out != "done" {
+		t.Errorf("expected pass-through, got %q, %v", out, err)
+	}
+}
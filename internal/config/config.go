@@ -5,36 +5,439 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/user/gopherclaw/pkg/llm/anthropic"
 )
 
 type Config struct {
 	DataDir          string `json:"data_dir"`
 	LogLevel         string `json:"log_level"`
+	LogFile          string `json:"log_file,omitempty"` // JSON log for `gopherclaw logs`; default data_dir/logs/gopherclaw.log
 	MaxConcurrent    int    `json:"max_concurrent"`
 	MaxToolRounds    int    `json:"max_tool_rounds"`
 	SystemPromptPath string `json:"system_prompt_path"`
-	LLM           struct {
-		Provider         string  `json:"provider"`
-		BaseURL          string  `json:"base_url"`
-		APIKey           string  `json:"api_key"`
-		Model            string  `json:"model"`
-		MaxTokens        int     `json:"max_tokens"`
-		Temperature      float32 `json:"temperature"`
-		MaxContextTokens int     `json:"max_context_tokens"`
-		OutputReserve    int     `json:"output_reserve"`
+	LLM              struct {
+		Provider    string  `json:"provider"`
+		BaseURL     string  `json:"base_url"`
+		APIKey      string  `json:"api_key"`
+		Model       string  `json:"model"`
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float32 `json:"temperature"`
+		// MaxContextTokens overrides the model's context window; 0 uses
+		// the window from the model registry.
+		MaxContextTokens int `json:"max_context_tokens"`
+		OutputReserve    int `json:"output_reserve"`
+		// ProbeOnStart sends a tiny completion when serve starts so a bad
+		// key, URL or model fails immediately instead of on the first message.
+		ProbeOnStart bool `json:"probe_on_start"`
+		// FallbackModel is used when the probe of Model fails; serve only
+		// refuses to start if the fallback fails too.
+		FallbackModel string `json:"fallback_model,omitempty"`
+		// Failover names model profiles to try in order when a call fails
+		// with a server error, rate limit, timeout or connection error.
+		Failover []string `json:"failover,omitempty"`
+		// RateLimitRetries is how many times the OpenAI client retries a
+		// rate-limited request, waiting as long as the API asks, before
+		// failing it. 0 disables the retries.
+		RateLimitRetries int `json:"rate_limit_retries"`
+		// SummarizeArtifacts has the model summarize tool outputs too large
+		// for the event log, so later rounds see a digest rather than a cut.
+		SummarizeArtifacts bool `json:"summarize_artifacts,omitempty"`
+		// Citations appends a "Sources:" footer listing the web pages
+		// behind a reply. On by default.
+		Citations bool `json:"citations"`
 	} `json:"llm"`
+	// Models overrides or extends the built-in model capability registry,
+	// keyed by model name.
+	Models map[string]ModelConfig `json:"models,omitempty"`
+	// ModelProfiles are named LLM settings, such as "fast" or "smart",
+	// that ModelRoutes send calls to.
+	ModelProfiles map[string]ModelProfile `json:"model_profiles,omitempty"`
+	// ModelRoutes pick a profile for each LLM call: the first route that
+	// matches a call wins, and calls no route matches use llm.
+	ModelRoutes []ModelRoute `json:"model_routes,omitempty"`
+	// Verbosity maps a channel, the session key prefix such as "telegram"
+	// or "http", to a reply length profile.
+	Verbosity map[string]VerbosityConfig `json:"verbosity,omitempty"`
+	// Context controls which session events are replayed into prompts.
+	Context struct {
+		ContextInclusion
+		// Agents overrides the inclusion settings for sessions of the
+		// named agent. Fields an override sets replace the defaults.
+		Agents map[string]ContextInclusion `json:"agents,omitempty"`
+	} `json:"context"`
+	// Runtime bounds how long the agent may work on a message and how
+	// much of it happens at once.
+	Runtime struct {
+		// ToolTimeout is a Go duration limiting each tool call (default
+		// "5m"); ToolTimeouts overrides it by tool name. "0s" means no
+		// limit.
+		ToolTimeout  string            `json:"tool_timeout,omitempty"`
+		ToolTimeouts map[string]string `json:"tool_timeouts,omitempty"`
+		// RunTimeout is a Go duration limiting a whole run, its LLM and
+		// tool calls together (default "30m").
+		RunTimeout string `json:"run_timeout,omitempty"`
+		// ParallelTools is how many of the tool calls the model makes in
+		// one round run at once (default 4). Calls to mutating tools run
+		// in turn regardless; 1 runs every call in turn.
+		ParallelTools int `json:"parallel_tools,omitempty"`
+	} `json:"runtime"`
+	// Tools changes how tools are presented to the model.
+	Tools struct {
+		// Overrides is keyed by a tool's built-in name, e.g. to expose
+		// "bash" as "server_shell" with a sterner description.
+		Overrides map[string]ToolOverride `json:"overrides,omitempty"`
+	} `json:"tools"`
+	// Bash restricts the bash tool. Rejected commands reach the model as
+	// an error and are flagged "denied" on their tool_result event.
+	Bash struct {
+		// Allow, if set, admits only commands matching one of these
+		// regexes; Deny rejects commands matching any, even allowed ones.
+		Allow []string `json:"allow,omitempty"`
+		Deny  []string `json:"deny,omitempty"`
+		// Dir is the working directory commands are jailed to.
+		Dir string `json:"dir,omitempty"`
+		// MaxOutput caps the bytes of output returned to the model.
+		MaxOutput int `json:"max_output,omitempty"`
+		// Wrapper runs each "bash -c <command>" through another command,
+		// e.g. ["sudo", "-u", "agent"] or ["nsjail", "--quiet", "--"].
+		Wrapper []string `json:"wrapper,omitempty"`
+	} `json:"bash"`
+	// HTTPRequest enables the http_request tool for API calls to the
+	// allowed hosts; with none it isn't offered.
+	HTTPRequest struct {
+		// AllowedHosts are host names, "*.example.com" for subdomains or
+		// "host:port" for a single port, optionally after "https://" or
+		// "http://" to allow only that scheme.
+		AllowedHosts []string `json:"allowed_hosts,omitempty"`
+		// Headers are added to every request to a host, keyed by the
+		// AllowedHosts entry, e.g. {"http://ha.local:8123":
+		// {"Authorization": "Bearer ..."}}. The model never sees them. They
+		// are only sent over https unless the entry starts with "http://".
+		Headers map[string]map[string]string `json:"headers,omitempty"`
+		// Timeout is a Go duration bounding each request (default "30s").
+		Timeout string `json:"timeout,omitempty"`
+	} `json:"http_request"`
+	// MCPServers are Model Context Protocol servers whose tools the agent
+	// may use, keyed by a short name. Their tools are offered as
+	// "<name>__<tool>".
+	MCPServers map[string]MCPServer `json:"mcp_servers,omitempty"`
+	// Approval makes the listed tools wait for the user's approval before
+	// each call. Where approval can't be asked for, such as over the HTTP
+	// API, their calls are refused.
+	Approval struct {
+		Tools []string `json:"tools,omitempty"`
+		// Timeout is a Go duration after which an unanswered request is
+		// denied (default "10m"). A waiting run keeps its queue slot, so
+		// "0" means the default rather than no limit.
+		Timeout string `json:"timeout,omitempty"`
+	} `json:"approval"`
 	Brave struct {
 		APIKey string `json:"api_key"`
 	} `json:"brave"`
 	Telegram struct {
 		Token string `json:"token"`
+		// Admins are user IDs allowed to run admin commands; empty allows anyone.
+		Admins []int64 `json:"admins,omitempty"`
+		// NoStream sends replies only once they are complete, instead of
+		// editing a message as the reply is generated.
+		NoStream bool `json:"no_stream,omitempty"`
 	} `json:"telegram"`
+	// Signal bridges a number registered with signal-cli-rest-api (run
+	// in normal or native mode) to the agent.
+	Signal struct {
+		// URL is the API's base URL, such as "http://127.0.0.1:8080".
+		URL string `json:"url,omitempty"`
+		// Number is the bot's registered number, such as "+15550100".
+		Number string `json:"number,omitempty"`
+		// AllowedNumbers are the senders whose messages are answered;
+		// empty allows anyone.
+		AllowedNumbers []string `json:"allowed_numbers,omitempty"`
+	} `json:"signal"`
+	// Email sends responses delivered to "email:<address>" keys over SMTP
+	// and, if IMAP is set, answers mail from AllowedSenders.
+	Email struct {
+		SMTP struct {
+			// Addr is the server's "host:port"; port 465 uses implicit
+			// TLS, others STARTTLS when offered.
+			Addr     string `json:"addr,omitempty"`
+			From     string `json:"from,omitempty"`
+			Username string `json:"username,omitempty"`
+			Password string `json:"password,omitempty"`
+		} `json:"smtp"`
+		IMAP struct {
+			// Addr is the server's "host:port", reached over TLS unless
+			// Insecure. Username and Password default to SMTP's.
+			Addr     string `json:"addr,omitempty"`
+			Username string `json:"username,omitempty"`
+			Password string `json:"password,omitempty"`
+			// Mailbox is polled for unseen mail (default "INBOX").
+			Mailbox  string `json:"mailbox,omitempty"`
+			Insecure bool   `json:"insecure,omitempty"`
+			// PollInterval is a Go duration between polls (default "1m").
+			PollInterval string `json:"poll_interval,omitempty"`
+		} `json:"imap"`
+		// AllowedSenders are the addresses whose mail is answered; the
+		// IMAP poller stays off without any.
+		AllowedSenders []string `json:"allowed_senders,omitempty"`
+	} `json:"email"`
 	HTTP struct {
-		Enabled bool   `json:"enabled"`
-		Listen  string `json:"listen"`
+		Enabled bool `json:"enabled"`
+		// Listen is a TCP address or a unix socket ("unix:/path" or an
+		// absolute path). A bare port binds 127.0.0.1 only.
+		Listen string `json:"listen"`
+		// Pprof serves net/http/pprof under /debug/pprof/ on the HTTP
+		// listener. Admin use only; keep the listener on localhost.
+		Pprof bool `json:"pprof,omitempty"`
+		// AdminToken and ObserverToken require a bearer token on the API
+		// once either is set. The observer token can only read sessions,
+		// events, artifacts, tasks and status.
+		AdminToken    string `json:"admin_token,omitempty"`
+		ObserverToken string `json:"observer_token,omitempty"`
+		// PublicURL is the address users reach the server at (e.g.
+		// "https://bot.example.com"). When set, Telegram replies link to
+		// /artifacts/{id}/view for tool outputs too large to show.
+		PublicURL string `json:"public_url,omitempty"`
 	} `json:"http"`
+	// WebPush lets browsers subscribe to notifications from the web UI.
+	// Deliveries to "webpush:<name>" session keys go to the browsers
+	// subscribed under that name.
+	WebPush struct {
+		Enabled bool `json:"enabled"`
+		// Subject is the contact push services may use about this server,
+		// a "mailto:" or "https:" URL; defaults to http.public_url.
+		Subject string `json:"subject,omitempty"`
+		// NotifyAfter is a Go duration (e.g. "1m"). Runs that take longer
+		// notify every subscribed browser when they finish; empty turns
+		// this off.
+		NotifyAfter string `json:"notify_after,omitempty"`
+	} `json:"webpush"`
+	Session struct {
+		// IdleTimeout is a Go duration (e.g. "24h"). When set, the next
+		// message after this much inactivity starts a fresh session.
+		IdleTimeout string `json:"idle_timeout"`
+		// SeedOnNew seeds the session started by /new with a summary of
+		// the archived conversation.
+		SeedOnNew bool `json:"seed_on_new"`
+		// SummarizeHistory folds events that no longer fit the prompt
+		// into a conversation summary instead of dropping them.
+		SummarizeHistory bool `json:"summarize_history,omitempty"`
+		// InterimAfter is a Go duration (e.g. "20s"). Interactive runs
+		// still going after this long send a "still working" message.
+		InterimAfter string `json:"interim_after"`
+		// Compression is "gzip" or "zstd" to seal each session's
+		// events.jsonl into a compressed segment once it reaches
+		// SegmentSize bytes (default 4 MiB). Empty keeps plain logs.
+		Compression string `json:"compression,omitempty"`
+		SegmentSize int64  `json:"segment_size,omitempty"`
+	} `json:"session"`
+	// Budget caps LLM spend. Once a limit is reached the runtime refuses
+	// further LLM calls until the period rolls over or `gopherclaw budget
+	// reset` starts it over. The top-level limits count all sessions
+	// together; Session limits apply to each session on its own.
+	Budget struct {
+		BudgetLimits
+		Session BudgetLimits `json:"session"`
+	} `json:"budget"`
+	// Leader controls the lease that lets several instances share one
+	// data_dir: every instance serves HTTP, but only the lease holder polls
+	// Telegram and runs the scheduler.
+	Leader struct {
+		// LeaseTTL is a Go duration (default "15s"). A leader that stops
+		// renewing for this long is replaced by a waiting instance.
+		LeaseTTL string `json:"lease_ttl,omitempty"`
+	} `json:"leader"`
+	// Delivery controls the outbox that keeps scheduled task responses,
+	// heartbeat messages and task alerts whose delivery failed, and
+	// retries them, including after a restart.
+	Delivery struct {
+		// RetryInterval is a Go duration between retry passes (default "1m").
+		RetryInterval string `json:"retry_interval,omitempty"`
+		// MaxAge is a Go duration (default "24h"); older undelivered
+		// messages are dropped.
+		MaxAge string `json:"max_age,omitempty"`
+		// Webhooks are named sinks: responses delivered to "http:<name>"
+		// are POSTed as JSON to the sink's URL.
+		Webhooks map[string]WebhookSink `json:"webhooks,omitempty"`
+		// WebhookURLs lets "http:<url>" keys POST to the URL they hold,
+		// signed with WebhookSecret if set.
+		WebhookURLs   bool   `json:"webhook_urls,omitempty"`
+		WebhookSecret string `json:"webhook_secret,omitempty"`
+	} `json:"delivery"`
+	// Scheduler configures the task scheduler.
+	Scheduler struct {
+		// Timezone is the IANA time zone, such as "Europe/Oslo", that
+		// task schedules without their own timezone fire in (default the
+		// server's local time).
+		Timezone string `json:"timezone,omitempty"`
+	} `json:"scheduler"`
+	// Watchdog probes the Telegram poller, the scheduler and the HTTP
+	// server, restarts one that stops responding and alerts
+	// telegram.admins.
+	Watchdog struct {
+		// Disabled turns the probes off.
+		Disabled bool `json:"disabled,omitempty"`
+		// Interval is a Go duration between probes (default "1m").
+		Interval string `json:"interval,omitempty"`
+	} `json:"watchdog"`
+	// Heartbeat runs periodic check-ins where the agent reviews a checklist
+	// and messages the user only if something needs attention.
+	Heartbeat struct {
+		Enabled bool `json:"enabled"`
+		// Interval is a Go duration between check-ins (default "30m").
+		Interval string `json:"interval,omitempty"`
+		// SessionKey receives check-in messages; defaults to the first
+		// telegram admin's private chat.
+		SessionKey string `json:"session_key,omitempty"`
+		// ChecklistPath is a file with the checklist prompt; empty uses
+		// the built-in checklist.
+		ChecklistPath string `json:"checklist_path,omitempty"`
+		// QuietHours is a local time range such as "22:00-07:00" with no
+		// check-ins.
+		QuietHours string `json:"quiet_hours,omitempty"`
+		// MaxRuns and MaxMessages cap check-ins and messages per day.
+		MaxRuns     int `json:"max_runs,omitempty"`
+		MaxMessages int `json:"max_messages,omitempty"`
+		// MinGap is a Go duration; messages closer together are held back.
+		MinGap string `json:"min_gap,omitempty"`
+	} `json:"heartbeat"`
+	// Maintenance runs a nightly job that removes stale temp files, checks
+	// the data directory's integrity, takes a backup and tallies the last
+	// day's activity, then reports to telegram.admins.
+	Maintenance struct {
+		Enabled bool `json:"enabled"`
+		// At is the local time of day to run, "HH:MM" (default "03:30").
+		At string `json:"at,omitempty"`
+		// BackupDir receives the nightly backups (default data_dir/backups).
+		BackupDir string `json:"backup_dir,omitempty"`
+		// KeepBackups is how many nightly backups to keep (default 7).
+		KeepBackups int `json:"keep_backups,omitempty"`
+		// NoBackup skips the backup.
+		NoBackup bool `json:"no_backup,omitempty"`
+		// EncryptTo encrypts the backups to these age public keys.
+		EncryptTo []string `json:"encrypt_to,omitempty"`
+	} `json:"maintenance"`
+	// Chaos injects faults into the LLM provider (and optionally tools).
+	// For testing and staging only.
+	Chaos struct {
+		Enabled bool `json:"enabled"`
+		// Latency and Jitter are Go durations added before every call.
+		Latency       string  `json:"latency,omitempty"`
+		Jitter        string  `json:"jitter,omitempty"`
+		ErrorRate     float64 `json:"error_rate,omitempty"`
+		RateLimitRate float64 `json:"rate_limit_rate,omitempty"`
+		Tools         bool    `json:"tools,omitempty"`
+		Seed          int64   `json:"seed,omitempty"`
+	} `json:"chaos"`
+	// Simulate runs the daemon without external side effects; `serve
+	// --simulate` turns it on. For rehearsing changes on a copy of the data.
+	Simulate struct {
+		Enabled bool `json:"enabled"`
+		// Model replaces llm.model; empty means canned replies and no API calls.
+		Model string `json:"model,omitempty"`
+		// LogFile receives the tool calls and deliveries held back; defaults
+		// to data_dir/simulate.jsonl.
+		LogFile string `json:"log_file,omitempty"`
+	} `json:"simulate"`
+}
+
+// ModelConfig overrides a model's capabilities. Zero or nil fields keep the
+// built-in value, so a partial entry only changes what it sets.
+type ModelConfig struct {
+	ContextWindow  int     `json:"context_window,omitempty"`
+	SupportsTools  *bool   `json:"supports_tools,omitempty"`
+	SupportsVision *bool   `json:"supports_vision,omitempty"`
+	Tokenizer      string  `json:"tokenizer,omitempty"`
+	InputPrice     float64 `json:"input_price,omitempty"`
+	OutputPrice    float64 `json:"output_price,omitempty"`
+}
+
+// BudgetLimits are spend limits per local calendar day and month, in USD
+// (at the model registry's prices) or tokens (input plus output). Zero
+// means no limit.
+type BudgetLimits struct {
+	DailyUSD      float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd,omitempty"`
+	DailyTokens   int     `json:"daily_tokens,omitempty"`
+	MonthlyTokens int     `json:"monthly_tokens,omitempty"`
+}
+
+// ModelProfile is a provider and model LLM calls can be routed to. Fields
+// left empty are taken from llm, except that a profile with a different
+// provider takes neither llm's base_url nor its api_key.
+type ModelProfile struct {
+	Provider    string   `json:"provider,omitempty"`
+	BaseURL     string   `json:"base_url,omitempty"`
+	APIKey      string   `json:"api_key,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	// Failover names other profiles to try in order when this one fails,
+	// as llm.failover does for llm.
+	Failover []string `json:"failover,omitempty"`
+}
+
+// ModelRoute sends the LLM calls it matches to Profile. Purpose is "reply",
+// "shorten", "summarize", "history" or "seed"; Source is the source of the
+// message being answered, such as "telegram", "task" (scheduled and webhook
+// tasks) or "heartbeat"; Channel is the session key's channel. Empty fields
+// match anything.
+type ModelRoute struct {
+	Purpose string `json:"purpose,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Profile string `json:"profile"`
+}
+
+// VerbosityConfig is a channel's reply length profile. Style is "brief",
+// "detailed" or "raw" (empty turns the profile off); brief replies longer
+// than MaxChars are shortened unless the user asked for detail.
+type VerbosityConfig struct {
+	Style    string `json:"style"`
+	MaxChars int    `json:"max_chars,omitempty"`
+}
+
+// ToolOverride replaces a tool's exposed name, description or parameter
+// descriptions (by parameter name). Empty fields keep the tool's own.
+type ToolOverride struct {
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+}
+
+// MCPServer says how to reach an MCP server: Command starts one that
+// speaks over stdio, URL dials one's SSE endpoint.
+type MCPServer struct {
+	Command []string          `json:"command,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Tools, if set, offers only these of the server's tools, by the
+	// server's own names.
+	Tools []string `json:"tools,omitempty"`
+}
+
+// WebhookSink is an external URL that delivered responses are POSTed to.
+// Secret, if set, signs each request with HMAC-SHA256.
+type WebhookSink struct {
+	URL     string            `json:"url"`
+	Secret  string            `json:"secret,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// ContextInclusion decides which events are eligible for a prompt.
+// ExcludeTypes and ExcludeSources leave events out by type (e.g. "error")
+// or source (e.g. "heartbeat"); Caps limits an event type to a share of the
+// event budget, e.g. {"tool_result": 0.3}.
+type ContextInclusion struct {
+	ExcludeTypes   []string           `json:"exclude_types,omitempty"`
+	ExcludeSources []string           `json:"exclude_sources,omitempty"`
+	Caps           map[string]float64 `json:"caps,omitempty"`
 }
 
+// DefaultOpenAIBaseURL is the default llm.base_url.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 func Load(path string) (*Config, error) {
 	cfg := &Config{
 		DataDir:       filepath.Join(os.Getenv("HOME"), ".gopherclaw"),
@@ -43,13 +446,30 @@ func Load(path string) (*Config, error) {
 	cfg.LogLevel = "info"
 	cfg.MaxToolRounds = 10
 	cfg.LLM.Provider = "openai"
-	cfg.LLM.BaseURL = "https://api.openai.com/v1"
+	cfg.LLM.BaseURL = DefaultOpenAIBaseURL
 	cfg.LLM.Model = "gpt-3.5-turbo"
 	cfg.LLM.MaxTokens = 2000
 	cfg.LLM.Temperature = 0.7
-	cfg.LLM.MaxContextTokens = 128000
+	cfg.LLM.RateLimitRetries = 2
 	cfg.LLM.OutputReserve = 4096
+	cfg.LLM.Citations = true
 	cfg.HTTP.Listen = "127.0.0.1:8484"
+	cfg.Verbosity = map[string]VerbosityConfig{
+		"telegram": {Style: "brief", MaxChars: 800},
+		"signal":   {Style: "brief", MaxChars: 800},
+		"email":    {Style: "detailed"},
+		"http":     {Style: "raw"},
+	}
+	cfg.Heartbeat.Interval = "30m"
+	cfg.Heartbeat.MaxRuns = 24
+	cfg.Heartbeat.MaxMessages = 3
+	cfg.Heartbeat.MinGap = "2h"
+	cfg.Runtime.ToolTimeout = "5m"
+	cfg.Runtime.RunTimeout = "30m"
+	cfg.Runtime.ParallelTools = 4
+	cfg.Approval.Timeout = "10m"
+	cfg.Maintenance.At = "03:30"
+	cfg.Maintenance.KeepBackups = 7
 
 	// Load from file if exists, otherwise write defaults
 	if _, err := os.Stat(path); err == nil {
@@ -66,11 +486,21 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
+	// The anthropic provider keeps the OpenAI default base URL from a
+	// config that only switched llm.provider.
+	if cfg.LLM.Provider == "anthropic" && cfg.LLM.BaseURL == DefaultOpenAIBaseURL {
+		cfg.LLM.BaseURL = anthropic.DefaultBaseURL
+	}
+
 	// Override from env (highest precedence)
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+	keyEnv, urlEnv := "OPENAI_API_KEY", "OPENAI_BASE_URL"
+	if cfg.LLM.Provider == "anthropic" {
+		keyEnv, urlEnv = "ANTHROPIC_API_KEY", "ANTHROPIC_BASE_URL"
+	}
+	if apiKey := os.Getenv(keyEnv); apiKey != "" {
 		cfg.LLM.APIKey = apiKey
 	}
-	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+	if baseURL := os.Getenv(urlEnv); baseURL != "" {
 		cfg.LLM.BaseURL = baseURL
 	}
 	if braveKey := os.Getenv("BRAVE_API_KEY"); braveKey != "" {
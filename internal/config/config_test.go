@@ -428,3 +428,24 @@ func TestSave_CreatesDirectory(t *testing.T) {
 		t.Errorf("config file should exist: %v", err)
 	}
 }
+
+func TestLoad_AnthropicProvider(t *testing.T) {
+	path := tempConfigPath(t)
+	if err := os.WriteFile(path, []byte(`{"llm": {"provider": "anthropic", "model": "claude-sonnet-4"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant")
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.BaseURL != "https://api.anthropic.com/v1" {
+		t.Errorf("expected the Anthropic base URL, got %q", cfg.LLM.BaseURL)
+	}
+	if cfg.LLM.APIKey != "sk-ant" {
+		t.Errorf("expected ANTHROPIC_API_KEY to be used, got %q", cfg.LLM.APIKey)
+	}
+}
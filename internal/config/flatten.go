@@ -6,14 +6,33 @@ import (
 
 // secretKeys lists the dot-separated keys whose values should be masked.
 var secretKeys = map[string]bool{
-	"llm.api_key":    true,
-	"brave.api_key":  true,
-	"telegram.token": true,
+	"llm.api_key":             true,
+	"brave.api_key":           true,
+	"telegram.token":          true,
+	"http.admin_token":        true,
+	"http.observer_token":     true,
+	"delivery.webhook_secret": true,
+	"email.smtp.password":     true,
+	"email.imap.password":     true,
 }
 
 // IsSecretKey returns true if the given dot-separated key is a secret.
+// Besides secretKeys, the headers sent to APIs, MCP servers and webhook
+// sinks, the environment of MCP servers and sink secrets are, since they
+// usually carry credentials.
 func IsSecretKey(key string) bool {
-	return secretKeys[key]
+	if secretKeys[key] || strings.HasPrefix(key, "http_request.headers.") {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(key, "delivery.webhooks."); ok {
+		_, field, _ := strings.Cut(rest, ".")
+		return field == "secret" || strings.HasPrefix(field, "headers.")
+	}
+	if rest, ok := strings.CutPrefix(key, "mcp_servers."); ok {
+		_, field, _ := strings.Cut(rest, ".")
+		return strings.HasPrefix(field, "headers.") || strings.HasPrefix(field, "env.")
+	}
+	return false
 }
 
 // Flatten converts a nested map into a flat map with dot-separated keys.
@@ -74,7 +93,7 @@ func Unflatten(flat map[string]any) map[string]any {
 func MaskSecrets(flat map[string]any) map[string]any {
 	out := make(map[string]any, len(flat))
 	for k, v := range flat {
-		if secretKeys[k] {
+		if IsSecretKey(k) {
 			s, ok := v.(string)
 			if ok && s != "" {
 				if len(s) <= 4 {
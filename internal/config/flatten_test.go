@@ -298,3 +298,32 @@ func TestFlatten_MixedTypes(t *testing.T) {
 		t.Errorf("expected nested.val=inside, got %v", got["nested.val"])
 	}
 }
+
+func TestMaskSecrets_CredentialMaps(t *testing.T) {
+	flat := map[string]any{
+		"http_request.headers.ha.local:8123.Authorization": "Bearer abcdefgh",
+		"mcp_servers.github.env.GITHUB_TOKEN":              "ghp_12345678",
+		"mcp_servers.remote.headers.Authorization":         "Bearer wxyz1234",
+		"mcp_servers.github.command":                       "npx",
+		"delivery.webhook_secret":                          "whsec_abcd",
+		"delivery.webhooks.ops.secret":                     "whsec_efgh",
+		"delivery.webhooks.ops.url":                        "https://example.com/hook",
+		"email.smtp.password":                              "app-pass",
+	}
+	got := MaskSecrets(flat)
+	want := map[string]any{
+		"http_request.headers.ha.local:8123.Authorization": "***efgh",
+		"mcp_servers.github.env.GITHUB_TOKEN":              "***5678",
+		"mcp_servers.remote.headers.Authorization":         "***1234",
+		"mcp_servers.github.command":                       "npx",
+		"delivery.webhook_secret":                          "***abcd",
+		"delivery.webhooks.ops.secret":                     "***efgh",
+		"delivery.webhooks.ops.url":                        "https://example.com/hook",
+		"email.smtp.password":                              "***pass",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ArchivePrompt saves a system prompt template as <dir>/<version>.tmpl so
+// the exact prompt behind any recorded run can be looked up later. An
+// existing copy is left alone.
+func ArchivePrompt(dir, source string) (string, error) {
+	version := PromptVersion(source)
+	path := filepath.Join(dir, version+".tmpl")
+	if _, err := os.Stat(path); err == nil {
+		return version, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create prompt archive: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(source), 0o644); err != nil {
+		return "", fmt.Errorf("write archived prompt: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("rename archived prompt: %w", err)
+	}
+	return version, nil
+}
+
+// ArchivedPrompt returns the template source archived under version.
+func ArchivedPrompt(dir, version string) (string, error) {
+	if version == "" || filepath.Base(version) != version {
+		return "", fmt.Errorf("invalid prompt version %q", version)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, version+".tmpl"))
+	if err != nil {
+		return "", fmt.Errorf("read archived prompt: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,46 @@
+package context
+
+import (
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// Overflow reports the events BuildPrompt would leave out for lack of
+// budget, oldest first, along with the conversation summary currently
+// standing in for older history (or ""). A caller that folds them into a
+// new conversation_summary event keeps them in view.
+//
+// Once the budget is exceeded, everything that doesn't fit in half of it is
+// returned, so the history has room to grow before the next fold. It
+// returns nil while everything fits.
+func (e *Engine) Overflow(session *types.SessionIndex, events []*types.Event, toolNames []string) (string, []*types.Event) {
+	sysPrompt := e.buildSystemPrompt(session, toolNames, time.Now())
+	sysTokens := e.countTokens(sysPrompt)
+	if instructions := instructionsMessage(session); instructions != "" {
+		sysTokens += e.countTokens(instructions)
+	}
+	eventBudget := int(float64(e.maxTokens-e.reserve-sysTokens) * 0.7)
+
+	if sel := e.selectEvents(session, events, eventBudget); len(sel.overflow) == 0 {
+		return sel.summary, nil
+	}
+	sel := e.selectEvents(session, events, eventBudget/2)
+	if len(sel.overflow) == 0 {
+		return sel.summary, nil
+	}
+
+	// Don't split a tool call from its results: a prompt can't open with
+	// a result whose call was folded away.
+	through := sel.overflow[len(sel.overflow)-1].Seq
+	for _, ev := range events {
+		if ev.Seq <= through {
+			continue
+		}
+		if ev.Type != "tool_result" {
+			break
+		}
+		sel.overflow = append(sel.overflow, ev)
+	}
+	return sel.summary, sel.overflow
+}
@@ -4,6 +4,8 @@ package context
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/pkoukk/tiktoken-go"
 
+	"github.com/user/gopherclaw/internal/i18n"
 	"github.com/user/gopherclaw/internal/types"
 	"github.com/user/gopherclaw/pkg/llm"
 )
@@ -25,6 +28,15 @@ type Engine struct {
 	reserve    int
 	promptTmpl *template.Template
 	memoryPath string
+	// promptVersion identifies the template source; see PromptVersion.
+	promptVersion string
+	promptSource  string
+	// verbosity holds reply length profiles by channel; see SetVerbosity.
+	verbosity map[string]Verbosity
+	// inclusion and agentInclusion decide which events reach the prompt;
+	// see SetInclusion.
+	inclusion      Inclusion
+	agentInclusion map[string]Inclusion
 }
 
 // PromptData holds the dynamic values injected into the system prompt template.
@@ -34,6 +46,8 @@ type PromptData struct {
 	Tools     string
 	ToolList  []string
 	Memory    string
+	Language  string
+	Verbosity string
 }
 
 // New creates a context engine with the specified token budget.
@@ -52,19 +66,69 @@ func New(model string, maxTokens, reserve int, promptPath string) (*Engine, erro
 		}
 	}
 
-	tmpl, err := loadPromptTemplate(promptPath)
+	source, err := loadPromptSource(promptPath)
+	if err != nil {
+		return nil, fmt.Errorf("load system prompt: %w", err)
+	}
+	tmpl, err := parsePrompt(source)
 	if err != nil {
 		return nil, fmt.Errorf("load system prompt: %w", err)
 	}
 
 	return &Engine{
-		tokenizer:  enc,
-		maxTokens:  maxTokens,
-		reserve:    reserve,
-		promptTmpl: tmpl,
+		tokenizer:     enc,
+		maxTokens:     maxTokens,
+		reserve:       reserve,
+		promptTmpl:    tmpl,
+		promptSource:  source,
+		promptVersion: PromptVersion(source),
 	}, nil
 }
 
+// PromptVersion returns the version of a system prompt template: the first
+// 12 hex digits of the SHA-256 of its source.
+func PromptVersion(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// PromptVersion returns the version of the engine's system prompt template,
+// recorded with each LLM response so behavior changes can be traced to
+// prompt edits.
+func (e *Engine) PromptVersion() string {
+	return e.promptVersion
+}
+
+// PromptSource returns the engine's system prompt template source.
+func (e *Engine) PromptSource() string {
+	return e.promptSource
+}
+
+// WithPrompt returns a copy of the engine that renders the given template
+// source instead, e.g. an archived version for rebuilding past prompts.
+func (e *Engine) WithPrompt(source string) (*Engine, error) {
+	tmpl, err := parsePrompt(source)
+	if err != nil {
+		return nil, err
+	}
+	c := *e
+	c.promptTmpl = tmpl
+	c.promptSource = source
+	c.promptVersion = PromptVersion(source)
+	return &c, nil
+}
+
+// SetTokenizer switches token counting to the named tiktoken encoding
+// (e.g. "o200k_base"), typically taken from the model registry.
+func (e *Engine) SetTokenizer(encoding string) error {
+	enc, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return fmt.Errorf("get tokenizer %q: %w", encoding, err)
+	}
+	e.tokenizer = enc
+	return nil
+}
+
 // SetMemoryPath configures the path to the persistent memory file.
 func (e *Engine) SetMemoryPath(path string) {
 	e.memoryPath = path
@@ -84,54 +148,70 @@ func (e *Engine) BuildPrompt(
 	events []*types.Event,
 	artifacts types.ArtifactStore,
 	toolNames []string,
+) ([]llm.Message, error) {
+	return e.BuildPromptAt(ctx, session, events, artifacts, toolNames, time.Now())
+}
+
+// BuildPromptAt is BuildPrompt with the system prompt's clock set to now,
+// for reconstructing a prompt as it was built in the past.
+func (e *Engine) BuildPromptAt(
+	ctx context.Context,
+	session *types.SessionIndex,
+	events []*types.Event,
+	artifacts types.ArtifactStore,
+	toolNames []string,
+	now time.Time,
 ) ([]llm.Message, error) {
 	inputBudget := e.maxTokens - e.reserve
 
-	// 1. System prompt
-	sysPrompt := e.buildSystemPrompt(session, toolNames)
+	// 1. System prompt, then standing instructions
+	sysPrompt := e.buildSystemPrompt(session, toolNames, now)
 	sysTokens := e.countTokens(sysPrompt)
+	instructions := instructionsMessage(session)
+	if instructions != "" {
+		sysTokens += e.countTokens(instructions)
+	}
 	remaining := inputBudget - sysTokens
 
-	// 70% for events, 10% safety margin (20% artifact budget unused for now)
+	// 70% for events, 20% for artifact excerpts, 10% safety margin
 	eventBudget := int(float64(remaining) * 0.7)
+	artifactBudget := int(float64(remaining) * 0.2)
 
-	// 2. Convert events to messages, walking newest-first to prioritize recent context
-	var eventMessages []llm.Message
-	usedTokens := 0
-
-	for i := len(events) - 1; i >= 0; i-- {
-		msg, err := eventToMessage(events[i])
-		if err != nil {
-			continue
-		}
-
-		msgTokens := e.countTokens(msg.Content)
-		for _, tc := range msg.Tools {
-			msgTokens += e.countTokens(tc.Function.Name)
-			msgTokens += e.countTokens(string(tc.Function.Arguments))
-		}
-
-		if usedTokens+msgTokens > eventBudget {
-			break
-		}
-
-		eventMessages = append(eventMessages, msg)
-		usedTokens += msgTokens
-	}
+	// 2. Convert events to messages, walking newest-first to prioritize
+	// recent context
+	eventMessages := e.selectEvents(session, events, eventBudget).msgs
 
-	// 3. Reverse to chronological order and assemble
-	for i, j := 0, len(eventMessages)-1; i < j; i, j = i+1, j-1 {
-		eventMessages[i], eventMessages[j] = eventMessages[j], eventMessages[i]
-	}
+	// 3. Widen tool results stored as artifacts with the part of the full
+	// output that bears on the latest message
+	e.injectExcerpts(ctx, eventMessages, events, artifacts, artifactBudget)
 
-	messages := make([]llm.Message, 0, 1+len(eventMessages))
+	// 4. Assemble
+	messages := make([]llm.Message, 0, 2+len(eventMessages))
 	messages = append(messages, llm.Message{Role: "system", Content: sysPrompt})
+	if instructions != "" {
+		messages = append(messages, llm.Message{Role: "system", Content: instructions})
+	}
 	messages = append(messages, eventMessages...)
 
 	return messages, nil
 }
 
-func (e *Engine) buildSystemPrompt(session *types.SessionIndex, toolNames []string) string {
+// instructionsMessage renders a session's standing instructions, or ""
+// when it has none.
+func instructionsMessage(session *types.SessionIndex) string {
+	if len(session.Instructions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Standing instructions for this conversation. Follow them in every reply until they are changed:")
+	for _, text := range session.Instructions {
+		b.WriteString("\n- ")
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+func (e *Engine) buildSystemPrompt(session *types.SessionIndex, toolNames []string, now time.Time) string {
 	memory := ""
 	if e.memoryPath != "" {
 		if data, err := os.ReadFile(e.memoryPath); err == nil {
@@ -143,11 +223,15 @@ func (e *Engine) buildSystemPrompt(session *types.SessionIndex, toolNames []stri
 	}
 
 	data := PromptData{
-		Time:      time.Now().Format(time.RFC3339),
+		Time:      now.Format(time.RFC3339),
 		SessionID: string(session.SessionID),
 		ToolList:  toolNames,
 		Tools:     strings.Join(toolNames, ", "),
 		Memory:    memory,
+		Language:  i18n.Name(session.Language),
+	}
+	if v, ok := e.VerbosityFor(session.SessionKey); ok {
+		data.Verbosity = v.Instruction()
 	}
 
 	var buf bytes.Buffer
@@ -183,32 +267,17 @@ func (e *Engine) Summarize(
 ) *ContextSummary {
 	inputBudget := e.maxTokens - e.reserve
 
-	sysPrompt := e.buildSystemPrompt(session, toolNames)
+	sysPrompt := e.buildSystemPrompt(session, toolNames, time.Now())
+	if instructions := instructionsMessage(session); instructions != "" {
+		sysPrompt += "\n\n" + instructions
+	}
 	sysTokens := e.countTokens(sysPrompt)
 	remaining := inputBudget - sysTokens
 
 	eventBudget := int(float64(remaining) * 0.7)
 
-	usedTokens := 0
-	included := 0
-	for i := len(events) - 1; i >= 0; i-- {
-		msg, err := eventToMessage(events[i])
-		if err != nil {
-			continue
-		}
-
-		msgTokens := e.countTokens(msg.Content)
-		for _, tc := range msg.Tools {
-			msgTokens += e.countTokens(tc.Function.Name)
-			msgTokens += e.countTokens(string(tc.Function.Arguments))
-		}
-
-		if usedTokens+msgTokens > eventBudget {
-			break
-		}
-		usedTokens += msgTokens
-		included++
-	}
+	sel := e.selectEvents(session, events, eventBudget)
+	usedTokens, included := sel.used, len(sel.msgs)
 
 	return &ContextSummary{
 		MaxTokens:         e.maxTokens,
@@ -224,37 +293,60 @@ func (e *Engine) Summarize(
 	}
 }
 
-// loadPromptTemplate loads the system prompt template from a file, or returns
+// loadPromptSource reads the system prompt template from a file, or returns
 // the built-in default if the path is empty or the file doesn't exist.
-func loadPromptTemplate(path string) (*template.Template, error) {
+func loadPromptSource(path string) (string, error) {
 	if path != "" {
 		if data, err := os.ReadFile(path); err == nil {
-			tmpl, err := template.New("system").Parse(string(data))
-			if err != nil {
-				return nil, fmt.Errorf("parse prompt template %s: %w", path, err)
-			}
 			slog.Info("loaded system prompt", "path", path)
-			return tmpl, nil
+			return string(data), nil
 		} else if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("read prompt file %s: %w", path, err)
+			return "", fmt.Errorf("read prompt file %s: %w", path, err)
 		}
 		// File doesn't exist — fall through to default
 		slog.Info("system prompt file not found, using default", "path", path)
 	}
+	return DefaultPrompt, nil
+}
 
-	tmpl, err := template.New("system").Parse(DefaultPrompt)
+// parsePrompt parses a system prompt template.
+func parsePrompt(source string) (*template.Template, error) {
+	tmpl, err := template.New("system").Parse(source)
 	if err != nil {
-		return nil, fmt.Errorf("parse default prompt: %w", err)
+		return nil, fmt.Errorf("parse prompt template: %w", err)
 	}
 	return tmpl, nil
 }
 
 type eventPayload struct {
-	Text      string          `json:"text"`
-	Tool      string          `json:"tool"`
-	CallID    string          `json:"call_id"`
-	Arguments json.RawMessage `json:"arguments"`
-	Result    string          `json:"result"`
+	Text        string             `json:"text"`
+	Attachments []types.Attachment `json:"attachments"`
+	Tool        string             `json:"tool"`
+	CallID      string             `json:"call_id"`
+	Arguments   json.RawMessage    `json:"arguments"`
+	Result      string             `json:"result"`
+	Message     string             `json:"message"`
+}
+
+// withAttachments appends a reference line per attachment to a user message
+// so the model knows which artifacts it can inspect with tools.
+func withAttachments(text string, attachments []types.Attachment) string {
+	if len(attachments) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	for _, att := range attachments {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		name := att.Filename
+		if name == "" {
+			name = "unnamed"
+		}
+		fmt.Fprintf(&b, "[attachment: %s (%s, %d bytes), artifact %s]", name, att.ContentType, att.Size, att.ArtifactID)
+	}
+	return b.String()
 }
 
 func eventToMessage(event *types.Event) (llm.Message, error) {
@@ -265,11 +357,17 @@ func eventToMessage(event *types.Event) (llm.Message, error) {
 
 	switch event.Type {
 	case "user_message":
-		return llm.Message{Role: "user", Content: payload.Text}, nil
+		return llm.Message{Role: "user", Content: withAttachments(payload.Text, payload.Attachments)}, nil
 
 	case "assistant_message":
 		return llm.Message{Role: "assistant", Content: payload.Text}, nil
 
+	case "session_summary":
+		return llm.Message{Role: "system", Content: "Summary of the previous conversation:\n" + payload.Text}, nil
+
+	case "error":
+		return llm.Message{Role: "system", Content: "Previous attempt failed: " + payload.Message}, nil
+
 	case "tool_call":
 		return llm.Message{
 			Role: "assistant",
@@ -292,6 +390,32 @@ func eventToMessage(event *types.Event) (llm.Message, error) {
 			}},
 		}, nil
 
+	case "reasoning":
+		// Reasoning traces are kept for inspection, not replayed.
+		return llm.Message{}, fmt.Errorf("reasoning events are not replayed")
+
+	case "conversation_summary":
+		// Leads the prompt in place of the events it covers; see selectEvents.
+		return llm.Message{}, fmt.Errorf("conversation summaries are not replayed in place")
+
+	case "timeout":
+		// Recorded for inspection; a timed-out tool call's result already
+		// tells the model, and a timed-out run ends with an error event.
+		return llm.Message{}, fmt.Errorf("timeouts are not replayed")
+
+	case "approval_request", "approval":
+		// Recorded for inspection; a refused call's result already tells
+		// the model.
+		return llm.Message{}, fmt.Errorf("approvals are not replayed")
+
+	case "usage":
+		// Token accounting for `gopherclaw usage`, not conversation.
+		return llm.Message{}, fmt.Errorf("usage events are not replayed")
+
+	case "system_instruction":
+		// Rendered from the session's Instructions, not replayed in place.
+		return llm.Message{}, fmt.Errorf("system instructions are not replayed")
+
 	default:
 		return llm.Message{}, fmt.Errorf("unknown event type: %s", event.Type)
 	}
@@ -10,7 +10,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/user/gopherclaw/internal/state"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 func TestNewEngine(t *testing.T) {
@@ -251,6 +253,104 @@ func TestBuildPromptIncludesMemory(t *testing.T) {
 	}
 }
 
+func TestBuildPromptIncludesLanguage(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	messages, err := e.BuildPrompt(context.Background(), session, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(messages[0].Content, "Preferred language") {
+		t.Error("system prompt should not mention a language when none is set")
+	}
+
+	session.Language = "es"
+	messages, err = e.BuildPrompt(context.Background(), session, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(messages[0].Content, "Preferred language: Spanish") {
+		t.Error("system prompt should name the session's language")
+	}
+}
+
+func TestBuildPromptStandingInstructions(t *testing.T) {
+	// Tight budget: the instructions must survive while old turns are dropped.
+	e, err := New("gpt-4", 500, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{
+		SessionID: "test-session", Agent: "default", Status: "active",
+		Instructions: []string{"Answer in French."},
+	}
+	events := make([]*types.Event, 50)
+	for i := range events {
+		payload, _ := json.Marshal(map[string]string{"text": "This is a message that takes up tokens in the context window budget."})
+		events[i] = &types.Event{
+			ID: types.EventID(fmt.Sprintf("e%d", i)), Seq: int64(i + 1),
+			Type: "user_message", Source: "test", Payload: payload,
+		}
+	}
+	events = append(events, &types.Event{
+		ID: "sys", Seq: 51, Type: "system_instruction", Source: "telegram",
+		Payload: []byte(`{"action":"add","text":"Answer in French."}`),
+	})
+
+	messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) >= 51 {
+		t.Fatalf("expected truncation, got %d messages", len(messages))
+	}
+	if messages[1].Role != "system" || !strings.Contains(messages[1].Content, "- Answer in French.") {
+		t.Errorf("expected instructions as the second system message, got %+v", messages[1])
+	}
+	for _, msg := range messages[2:] {
+		if msg.Role == "system" {
+			t.Errorf("instruction event should not be replayed, got %+v", msg)
+		}
+	}
+}
+
+func TestBuildPromptIncludesVerbosity(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetVerbosity(map[string]Verbosity{"telegram": {Style: VerbosityBrief, MaxChars: 800}}); err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{SessionID: "s1", SessionKey: "telegram:1:1", Agent: "default", Status: "active"}
+	messages, err := e.BuildPrompt(context.Background(), session, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(messages[0].Content, "Reply style: Keep replies short and conversational, at most 800 characters") {
+		t.Errorf("system prompt should carry the channel's reply style:\n%s", messages[0].Content)
+	}
+
+	session.SessionKey = "http:ops"
+	messages, err = e.BuildPrompt(context.Background(), session, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(messages[0].Content, "Reply style") {
+		t.Error("system prompt should not set a reply style for channels without a profile")
+	}
+
+	if err := e.SetVerbosity(map[string]Verbosity{"http": {Style: "chatty"}}); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+}
+
 func TestBuildPromptNoMemoryFile(t *testing.T) {
 	e, err := New("gpt-4", 128000, 4096, "")
 	if err != nil {
@@ -292,3 +392,419 @@ func TestSummarizeIncludesMemoryTokens(t *testing.T) {
 			withMem.SystemPromptTokens, withoutMem.SystemPromptTokens)
 	}
 }
+
+func TestBuildPromptUserAttachments(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	payload, _ := json.Marshal(map[string]any{
+		"text": "what is in this file?",
+		"attachments": []types.Attachment{
+			{ArtifactID: "art_1", ContentType: "application/pdf", Filename: "report.pdf", Size: 1024},
+		},
+	})
+	events := []*types.Event{
+		{ID: "e1", Seq: 1, Type: "user_message", Source: "telegram", Payload: payload},
+	}
+
+	messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "what is in this file?\n[attachment: report.pdf (application/pdf, 1024 bytes), artifact art_1]"
+	if messages[1].Content != want {
+		t.Errorf("expected %q, got %q", want, messages[1].Content)
+	}
+}
+
+func TestBuildPromptErrorEvents(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	events := []*types.Event{
+		{ID: "e1", Seq: 1, Type: "user_message", Payload: json.RawMessage(`{"text":"dig deeper"}`)},
+		{ID: "e2", Seq: 2, Type: "error", Payload: json.RawMessage(`{"message":"max tool rounds (10) exceeded"}`)},
+	}
+
+	messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected system + user + error note, got %d messages", len(messages))
+	}
+	note := messages[2]
+	if note.Role != "system" || note.Content != "Previous attempt failed: max tool rounds (10) exceeded" {
+		t.Errorf("unexpected error note: %+v", note)
+	}
+}
+
+func TestBuildPromptExcludesReasoning(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	events := []*types.Event{
+		{ID: "e1", Seq: 1, Type: "user_message", Payload: json.RawMessage(`{"text":"6*7?"}`)},
+		{ID: "e2", Seq: 2, Type: "reasoning", Payload: json.RawMessage(`{"text":"6 times 7"}`)},
+		{ID: "e3", Seq: 3, Type: "assistant_message", Payload: json.RawMessage(`{"text":"42"}`)},
+	}
+
+	messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages[1:] {
+		if strings.Contains(m.Content, "6 times 7") {
+			t.Errorf("reasoning leaked into the prompt: %+v", m)
+		}
+	}
+	if len(messages) != 3 {
+		t.Errorf("expected system + user + assistant, got %d messages", len(messages))
+	}
+}
+
+// benchEvents returns n alternating user/assistant events of about 100
+// tokens each.
+func benchEvents(n int) []*types.Event {
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 10)
+	events := make([]*types.Event, n)
+	for i := range events {
+		typ := "user_message"
+		if i%2 == 1 {
+			typ = "assistant_message"
+		}
+		payload, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("%d %s", i, text)})
+		events[i] = &types.Event{ID: types.NewEventID(), Seq: int64(i + 1), Type: typ, At: time.Now(), Payload: payload}
+	}
+	return events
+}
+
+func BenchmarkBuildPrompt(b *testing.B) {
+	for _, size := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("events=%d", size), func(b *testing.B) {
+			e, err := New("gpt-4", 128000, 4096, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			session := &types.SessionIndex{SessionID: "bench", Agent: "default", Status: "active"}
+			events := benchEvents(size)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.BuildPrompt(ctx, session, events, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCountTokens(b *testing.B) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 100)
+	b.SetBytes(int64(len(text)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.countTokens(text)
+	}
+}
+
+func TestPromptVersioning(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.PromptVersion() != PromptVersion(DefaultPrompt) || len(e.PromptVersion()) != 12 {
+		t.Errorf("unexpected default prompt version %q", e.PromptVersion())
+	}
+
+	custom, err := e.WithPrompt("Custom bot for {{.SessionID}}.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.PromptVersion() == e.PromptVersion() {
+		t.Error("expected a different version for a different template")
+	}
+	session := &types.SessionIndex{SessionID: "s1", Agent: "default", Status: "active"}
+	messages, err := custom.BuildPrompt(context.Background(), session, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].Content != "Custom bot for s1." {
+		t.Errorf("unexpected system prompt %q", messages[0].Content)
+	}
+	if _, err := e.WithPrompt("{{.Invalid"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+
+	dir := t.TempDir()
+	version, err := ArchivePrompt(dir, custom.PromptSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != custom.PromptVersion() {
+		t.Errorf("archive version %q, want %q", version, custom.PromptVersion())
+	}
+	source, err := ArchivedPrompt(dir, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != custom.PromptSource() {
+		t.Errorf("archived source %q, want %q", source, custom.PromptSource())
+	}
+	if _, err := ArchivedPrompt(dir, "../"+version); err == nil {
+		t.Error("expected error for a version with a path")
+	}
+}
+
+// toolTurns builds n turns of a user message, a bash call and its result.
+func toolTurns(n int, result string) []*types.Event {
+	var events []*types.Event
+	for i := range n {
+		id := fmt.Sprintf("tc%d", i)
+		user, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("question %d", i)})
+		call, _ := json.Marshal(map[string]any{"tool": "bash", "call_id": id, "arguments": map[string]string{"command": "df -h"}})
+		res, _ := json.Marshal(map[string]any{"tool": "bash", "call_id": id, "result": fmt.Sprintf("%d: %s", i, result)})
+		events = append(events,
+			&types.Event{Type: "user_message", Source: "telegram", Payload: user},
+			&types.Event{Type: "tool_call", Source: "runtime", Payload: call},
+			&types.Event{Type: "tool_result", Source: "runtime", Payload: res},
+		)
+	}
+	return events
+}
+
+func TestBuildPromptCapsToolResults(t *testing.T) {
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	probe, err := New("gpt-4", 128000, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := strings.Repeat("filesystem usage mounted ", 40)
+	size := probe.countTokens(result)
+	sys := probe.Summarize(session, nil, nil).SystemPromptTokens
+
+	// An event budget whose 30% holds about 2.5 results.
+	remaining := int(2.5 * float64(size) / 0.21)
+	e, err := New("gpt-4", sys+100+remaining, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetInclusion(Inclusion{Caps: map[string]float64{"tool_result": 0.3}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := e.BuildPrompt(context.Background(), session, toolTurns(6, result), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users, full, omitted int
+	for _, m := range messages {
+		switch {
+		case m.Role == "user":
+			users++
+		case m.Role == "tool" && m.Content == omittedResult:
+			omitted++
+		case m.Role == "tool":
+			full++
+		}
+	}
+	if users != 6 {
+		t.Errorf("expected every user turn to survive the tool output, got %d", users)
+	}
+	if full != 2 || omitted != 4 {
+		t.Errorf("expected 2 full and 4 omitted tool results, got %d and %d", full, omitted)
+	}
+	if last := messages[len(messages)-1]; last.Content == omittedResult {
+		t.Error("the newest tool result should be kept whole")
+	}
+}
+
+func TestBuildPromptExcludesByTypeAndAgent(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.SetInclusion(
+		Inclusion{ExcludeTypes: []string{"error"}},
+		map[string]Inclusion{"ops": {ExcludeSources: []string{"heartbeat"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []*types.Event{
+		{Type: "user_message", Source: "heartbeat", Payload: json.RawMessage(`{"text":"check-in"}`)},
+		{Type: "error", Source: "runtime", Payload: json.RawMessage(`{"message":"boom"}`)},
+		{Type: "user_message", Source: "telegram", Payload: json.RawMessage(`{"text":"hello"}`)},
+	}
+
+	count := func(agent string) int {
+		session := &types.SessionIndex{SessionID: "s", Agent: agent}
+		messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(messages) - 1 // system prompt
+	}
+	if n := count("default"); n != 2 {
+		t.Errorf("default agent: expected the error to be left out, got %d events", n)
+	}
+	if n := count("ops"); n != 1 {
+		t.Errorf("ops agent: expected heartbeat and error events left out, got %d events", n)
+	}
+}
+
+func TestInclusionValidate(t *testing.T) {
+	for _, in := range []Inclusion{
+		{ExcludeTypes: []string{"tool_results"}},
+		{ExcludeTypes: []string{"tool_call"}},
+		{Caps: map[string]float64{"tool_result": 1.5}},
+		{Caps: map[string]float64{"reasoning": 0.2}},
+	} {
+		if err := in.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", in)
+		}
+	}
+	if err := (Inclusion{ExcludeTypes: []string{"tool_call", "tool_result"}, Caps: map[string]float64{"tool_result": 0.3}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildPromptConversationSummary(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &types.SessionIndex{SessionID: "s", Agent: "default"}
+	events := []*types.Event{
+		{Seq: 1, Type: "user_message", Payload: json.RawMessage(`{"text":"my cat is called Tom"}`)},
+		{Seq: 2, Type: "assistant_message", Payload: json.RawMessage(`{"text":"Nice name"}`)},
+		{Seq: 3, Type: "user_message", Payload: json.RawMessage(`{"text":"what's the weather?"}`)},
+		{Seq: 4, Type: "conversation_summary", Payload: json.RawMessage(`{"text":"The user's cat is Tom.","through_seq":2}`)},
+		{Seq: 5, Type: "assistant_message", Payload: json.RawMessage(`{"text":"Sunny"}`)},
+	}
+
+	messages, err := e.BuildPrompt(context.Background(), session, events, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range messages[1:] {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	want := []string{
+		"system: " + summaryPrefix + "The user's cat is Tom.",
+		"user: what's the weather?",
+		"assistant: Sunny",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	session := &types.SessionIndex{SessionID: "s", Agent: "default"}
+	probe, err := New("gpt-4", 128000, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := strings.Repeat("filesystem usage mounted ", 40)
+	size := probe.countTokens(result)
+	sys := probe.Summarize(session, nil, nil).SystemPromptTokens
+
+	events := toolTurns(6, result)
+	for i, ev := range events {
+		ev.Seq = int64(i + 1)
+	}
+
+	// Room for about four turns: the fold keeps what fits in half of it.
+	e, err := New("gpt-4", sys+100+int(4*float64(size)/0.7), 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, older := e.Overflow(session, events[:9], nil); older != nil {
+		t.Errorf("expected no overflow while three turns fit, got %d events", len(older))
+	}
+	prior, older := e.Overflow(session, events, nil)
+	if prior != "" || len(older) == 0 {
+		t.Fatalf("expected older events to overflow, got %d", len(older))
+	}
+	if older[0].Seq != 1 || older[len(older)-1].Type != "tool_result" {
+		t.Errorf("expected the overflow to start at the oldest event and end on a tool result, got seq %d to %s", older[0].Seq, older[len(older)-1].Type)
+	}
+	if kept := len(events) - len(older); kept > 2*3 {
+		t.Errorf("expected at most two turns kept after a fold, kept %d events", kept)
+	}
+}
+
+func TestBuildPromptArtifactExcerpts(t *testing.T) {
+	e, err := New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	session := &types.SessionIndex{SessionID: "test-session", Agent: "default", Status: "active"}
+	artifacts := state.NewArtifactStore(t.TempDir())
+
+	output := strings.Repeat("filler line\n", 1000) + "kernel: disk quota exceeded on /srv\n" + strings.Repeat("filler line\n", 1000)
+	artID, err := artifacts.Put(ctx, session.SessionID, "r1", "bash", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	turn := func(question string) []*types.Event {
+		user, _ := json.Marshal(map[string]string{"text": question})
+		call, _ := json.Marshal(map[string]any{"tool": "bash", "call_id": "tc1", "arguments": map[string]string{"command": "dmesg"}})
+		res, _ := json.Marshal(map[string]any{"tool": "bash", "call_id": "tc1", "artifact_id": artID,
+			"result": output[:2000] + "\n[truncated, see artifact " + string(artID) + "]"})
+		return []*types.Event{
+			{Seq: 1, Type: "user_message", Source: "telegram", Payload: user},
+			{Seq: 2, Type: "tool_call", Source: "runtime", Payload: call},
+			{Seq: 3, Type: "tool_result", Source: "runtime", Payload: res},
+		}
+	}
+	toolMessage := func(messages []llm.Message) string {
+		for _, m := range messages {
+			if m.Role == "tool" {
+				return m.Content
+			}
+		}
+		t.Fatal("no tool message")
+		return ""
+	}
+
+	messages, err := e.BuildPrompt(ctx, session, turn("why is the quota full?"), artifacts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toolMessage(messages); !strings.Contains(got, "disk quota exceeded") {
+		t.Errorf("expected a relevant excerpt, got %q", got[len(got)-200:])
+	}
+
+	// Nothing in the artifact matches: the result stays as recorded.
+	messages, err = e.BuildPrompt(ctx, session, turn("what about memory?"), artifacts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toolMessage(messages); strings.Contains(got, "Excerpt of artifact") {
+		t.Errorf("unexpected excerpt in %q", got[len(got)-200:])
+	}
+
+	// Without a store, prompts build as before.
+	if _, err := e.BuildPrompt(ctx, session, turn("why is the quota full?"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
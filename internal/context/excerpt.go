@@ -0,0 +1,145 @@
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// minExcerptTokens is the smallest excerpt worth adding to a prompt.
+const minExcerptTokens = 50
+
+// maxQueryTerms bounds how many words of the user's message are looked up
+// in each artifact.
+const maxQueryTerms = 5
+
+// injectExcerpts adds to the tool results in msgs that were stored as
+// artifacts an excerpt of the full output around a term from the latest
+// user message, newest result first, while budget lasts. Results whose
+// artifact doesn't mention any such term are left as they are: their
+// replayed head is all the prompt would gain.
+func (e *Engine) injectExcerpts(ctx context.Context, msgs []llm.Message, events []*types.Event, artifacts types.ArtifactStore, budget int) {
+	terms := queryTerms(events)
+	if artifacts == nil || len(terms) == 0 || budget < minExcerptTokens {
+		return
+	}
+	stored := storedResults(events)
+	left := budget
+	for i := len(msgs) - 1; i >= 0 && left >= minExcerptTokens; i-- {
+		msg := &msgs[i]
+		if msg.Role != "tool" || len(msg.Tools) == 0 || msg.Content == omittedResult {
+			continue
+		}
+		id, ok := stored[msg.Tools[0].ID]
+		if !ok {
+			continue
+		}
+		// No single result takes more than half of the budget.
+		addition, tokens := e.fitExcerpt(ctx, artifacts, id, terms, min(left, budget/2))
+		if addition == "" {
+			continue
+		}
+		msg.Content += addition
+		left -= tokens
+	}
+}
+
+// fitExcerpt returns the text injectExcerpts adds for an artifact and its
+// token count, within limit. ArtifactStore.Excerpt sizes excerpts by an
+// estimate, so an excerpt that comes out too long is fetched again,
+// proportionally shorter.
+func (e *Engine) fitExcerpt(ctx context.Context, artifacts types.ArtifactStore, id types.ArtifactID, terms []string, limit int) (string, int) {
+	maxTokens := limit
+	for range 3 {
+		excerpt := e.relevantExcerpt(ctx, artifacts, id, terms, maxTokens)
+		if excerpt == "" {
+			return "", 0
+		}
+		addition := "\n\nExcerpt of artifact " + string(id) + " relevant to the latest message:\n" + excerpt
+		tokens := e.countTokens(addition)
+		if tokens <= limit {
+			return addition, tokens
+		}
+		maxTokens = maxTokens * limit / tokens * 9 / 10
+		if maxTokens < minExcerptTokens {
+			break
+		}
+	}
+	return "", 0
+}
+
+// relevantExcerpt returns an excerpt of an artifact centred on the first
+// term it mentions, or "" when it mentions none.
+func (e *Engine) relevantExcerpt(ctx context.Context, artifacts types.ArtifactStore, id types.ArtifactID, terms []string, maxTokens int) string {
+	for _, term := range terms {
+		excerpt, err := artifacts.Excerpt(ctx, id, term, maxTokens)
+		if err != nil {
+			slog.WarnContext(ctx, "artifact excerpt failed", "artifact_id", id, "error", err)
+			return ""
+		}
+		// Excerpt falls back to the start of the artifact when the term
+		// isn't found.
+		if strings.Contains(strings.ToLower(excerpt), term) {
+			return excerpt
+		}
+	}
+	return ""
+}
+
+// storedResults maps the call IDs of tool results stored as artifacts to
+// their artifact IDs.
+func storedResults(events []*types.Event) map[string]types.ArtifactID {
+	stored := make(map[string]types.ArtifactID)
+	for _, ev := range events {
+		if ev.Type != "tool_result" {
+			continue
+		}
+		var p struct {
+			CallID     string `json:"call_id"`
+			ArtifactID string `json:"artifact_id"`
+		}
+		if json.Unmarshal(ev.Payload, &p) == nil && p.ArtifactID != "" {
+			stored[p.CallID] = types.ArtifactID(p.ArtifactID)
+		}
+	}
+	return stored
+}
+
+// queryTerms picks the words of the latest user message most likely to
+// locate relevant output: the longest ones of at least four letters,
+// lowercased.
+func queryTerms(events []*types.Event) []string {
+	var text string
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type != "user_message" {
+			continue
+		}
+		var p struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(events[i].Payload, &p)
+		text = p.Text
+		break
+	}
+
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.'
+	}) {
+		word = strings.Trim(word, "-.")
+		if len([]rune(word)) >= 4 && !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	slices.SortStableFunc(terms, func(a, b string) int { return len(b) - len(a) })
+	if len(terms) > maxQueryTerms {
+		terms = terms[:maxQueryTerms]
+	}
+	return terms
+}
@@ -0,0 +1,204 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// replayedTypes are the event types eventToMessage turns into prompt
+// messages, and so the types an Inclusion can name.
+var replayedTypes = []string{"user_message", "assistant_message", "session_summary", "conversation_summary", "error", "tool_call", "tool_result"}
+
+// Inclusion controls which events are eligible for a prompt and how much of
+// the event budget each type may take.
+type Inclusion struct {
+	// ExcludeTypes and ExcludeSources leave matching events out entirely.
+	// tool_call and tool_result must be excluded together, since a prompt
+	// can't hold one without the other.
+	ExcludeTypes   []string
+	ExcludeSources []string
+	// Caps limits an event type to a share of the event budget (0 to 1),
+	// e.g. {"tool_result": 0.3}. Once a type reaches its cap, older tool
+	// results and tool call arguments are replaced by a short placeholder
+	// and older events of other types are skipped.
+	Caps map[string]float64
+}
+
+// Validate reports unknown event types, caps outside (0, 1] and tool events
+// excluded apart.
+func (in Inclusion) Validate() error {
+	for _, typ := range in.ExcludeTypes {
+		if !slices.Contains(replayedTypes, typ) {
+			return fmt.Errorf("exclude_types: unknown event type %q", typ)
+		}
+	}
+	if slices.Contains(in.ExcludeTypes, "tool_call") != slices.Contains(in.ExcludeTypes, "tool_result") {
+		return fmt.Errorf("exclude_types: tool_call and tool_result must be excluded together")
+	}
+	for typ, share := range in.Caps {
+		if !slices.Contains(replayedTypes, typ) {
+			return fmt.Errorf("caps: unknown event type %q", typ)
+		}
+		if share <= 0 || share > 1 {
+			return fmt.Errorf("caps: %s must be between 0 and 1, got %v", typ, share)
+		}
+	}
+	return nil
+}
+
+// SetInclusion sets the inclusion policy for sessions, with overrides by
+// agent name (SessionIndex.Agent). An agent's override replaces the
+// default's ExcludeTypes, ExcludeSources or Caps when it sets them.
+func (e *Engine) SetInclusion(def Inclusion, agents map[string]Inclusion) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	merged := make(map[string]Inclusion, len(agents))
+	for agent, in := range agents {
+		if in.ExcludeTypes == nil {
+			in.ExcludeTypes = def.ExcludeTypes
+		}
+		if in.ExcludeSources == nil {
+			in.ExcludeSources = def.ExcludeSources
+		}
+		if in.Caps == nil {
+			in.Caps = def.Caps
+		}
+		if err := in.Validate(); err != nil {
+			return fmt.Errorf("agent %s: %w", agent, err)
+		}
+		merged[agent] = in
+	}
+	e.inclusion = def
+	e.agentInclusion = merged
+	return nil
+}
+
+// inclusionFor returns the inclusion policy for a session.
+func (e *Engine) inclusionFor(session *types.SessionIndex) Inclusion {
+	if in, ok := e.agentInclusion[session.Agent]; ok {
+		return in
+	}
+	return e.inclusion
+}
+
+// omittedResult replaces a tool result over its type's cap.
+const omittedResult = "[result omitted to save context]"
+
+// omittedArguments replaces tool call arguments over their type's cap.
+var omittedArguments = json.RawMessage(`{"omitted":"arguments dropped to save context"}`)
+
+// selection is the outcome of the budget walk over a session's events.
+type selection struct {
+	// msgs are the replayed events in chronological order, led by the
+	// latest conversation summary if there is one.
+	msgs []llm.Message
+	used int
+	// summary is the text of that conversation summary.
+	summary string
+	// overflow are the eligible events, oldest first, that didn't fit the
+	// budget.
+	overflow []*types.Event
+}
+
+// selectEvents walks events newest-first and keeps those that fit the
+// budget under the session's inclusion policy. The latest
+// conversation_summary event stands in for the events it covers.
+func (e *Engine) selectEvents(session *types.SessionIndex, events []*types.Event, budget int) selection {
+	in := e.inclusionFor(session)
+	var sel selection
+	through := int64(-1)
+	var summaryMsg llm.Message
+	if !slices.Contains(in.ExcludeTypes, "conversation_summary") {
+		if text, seq, ok := latestSummary(events); ok {
+			summaryMsg = llm.Message{Role: "system", Content: summaryPrefix + text}
+			if tokens := e.messageTokens(summaryMsg); tokens <= budget {
+				sel.summary, through, sel.used = text, seq, tokens
+			}
+		}
+	}
+	eligible := func(ev *types.Event) bool {
+		return ev.Type != "conversation_summary" && ev.Seq > through &&
+			!slices.Contains(in.ExcludeTypes, ev.Type) && !slices.Contains(in.ExcludeSources, ev.Source)
+	}
+	usedByType := make(map[string]int)
+
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if !eligible(ev) {
+			continue
+		}
+		msg, err := eventToMessage(ev)
+		if err != nil {
+			continue
+		}
+		tokens := e.messageTokens(msg)
+
+		if share, ok := in.Caps[ev.Type]; ok && usedByType[ev.Type]+tokens > int(float64(budget)*share) {
+			switch ev.Type {
+			case "tool_result":
+				msg.Content = omittedResult
+			case "tool_call":
+				msg.Tools[0].Function.Arguments = omittedArguments
+			default:
+				continue
+			}
+			tokens = e.messageTokens(msg)
+		} else {
+			usedByType[ev.Type] += tokens
+		}
+
+		if sel.used+tokens > budget {
+			for _, old := range events[:i+1] {
+				if _, err := eventToMessage(old); err == nil && eligible(old) {
+					sel.overflow = append(sel.overflow, old)
+				}
+			}
+			break
+		}
+		sel.msgs = append(sel.msgs, msg)
+		sel.used += tokens
+	}
+
+	if sel.summary != "" {
+		sel.msgs = append(sel.msgs, summaryMsg)
+	}
+	slices.Reverse(sel.msgs)
+	return sel
+}
+
+// summaryPrefix introduces a conversation summary in the prompt.
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// latestSummary finds the newest conversation_summary event, returning its
+// text and the sequence number of the last event it covers.
+func latestSummary(events []*types.Event) (string, int64, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type != "conversation_summary" {
+			continue
+		}
+		var p struct {
+			Text       string `json:"text"`
+			ThroughSeq int64  `json:"through_seq"`
+		}
+		if err := json.Unmarshal(events[i].Payload, &p); err != nil || p.Text == "" {
+			continue
+		}
+		return p.Text, p.ThroughSeq, true
+	}
+	return "", 0, false
+}
+
+// messageTokens counts a message's content and tool calls.
+func (e *Engine) messageTokens(msg llm.Message) int {
+	tokens := e.countTokens(msg.Content)
+	for _, tc := range msg.Tools {
+		tokens += e.countTokens(tc.Function.Name)
+		tokens += e.countTokens(string(tc.Function.Arguments))
+	}
+	return tokens
+}
@@ -0,0 +1,67 @@
+package context
+
+import (
+	"fmt"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// Verbosity styles.
+const (
+	// VerbosityBrief asks for short conversational replies, at most
+	// MaxChars characters unless the user asks for more.
+	VerbosityBrief = "brief"
+	// VerbosityDetailed asks for complete answers regardless of length.
+	VerbosityDetailed = "detailed"
+	// VerbosityRaw asks for the requested content only, for replies read
+	// by programs rather than people.
+	VerbosityRaw = "raw"
+)
+
+// Verbosity is a channel's reply length profile.
+type Verbosity struct {
+	Style    string
+	MaxChars int
+}
+
+// Instruction returns the reply-style line added to the system prompt.
+func (v Verbosity) Instruction() string {
+	switch v.Style {
+	case VerbosityBrief:
+		if v.MaxChars > 0 {
+			return fmt.Sprintf("Keep replies short and conversational, at most %d characters, unless the user asks for more detail.", v.MaxChars)
+		}
+		return "Keep replies short and conversational unless the user asks for more detail."
+	case VerbosityDetailed:
+		return "Give complete, well-structured answers with full detail; length is not a concern."
+	case VerbosityRaw:
+		return "Reply with the requested content only: no greeting, preamble, closing remarks or follow-up questions."
+	}
+	return ""
+}
+
+// SetVerbosity sets the reply length profiles by channel, the part of the
+// session key before the first ":" (e.g. "telegram"). Profiles with an
+// empty style are ignored.
+func (e *Engine) SetVerbosity(profiles map[string]Verbosity) error {
+	set := make(map[string]Verbosity, len(profiles))
+	for channel, v := range profiles {
+		switch v.Style {
+		case "":
+			continue
+		case VerbosityBrief, VerbosityDetailed, VerbosityRaw:
+			set[channel] = v
+		default:
+			return fmt.Errorf("verbosity for %s: unknown style %q (want brief, detailed or raw)", channel, v.Style)
+		}
+	}
+	e.verbosity = set
+	return nil
+}
+
+// VerbosityFor returns the reply length profile for a session key's
+// channel, if one is set.
+func (e *Engine) VerbosityFor(key types.SessionKey) (Verbosity, bool) {
+	v, ok := e.verbosity[key.Channel()]
+	return v, ok
+}
@@ -0,0 +1,78 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultBroadcastInterval spaces out broadcast messages to stay well under
+// Telegram's ~30 messages/second bot limit.
+const DefaultBroadcastInterval = 50 * time.Millisecond
+
+// BroadcastResult summarizes a broadcast.
+type BroadcastResult struct {
+	Sent int `json:"sent"`
+	// Skipped counts session keys with no delivery handler (e.g. "http:").
+	Skipped int `json:"skipped"`
+	// Failed maps session keys to their delivery error.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Broadcaster sends a notice to every active session's channel.
+type Broadcaster func(ctx context.Context, message string) (*BroadcastResult, error)
+
+// Broadcast delivers message to each distinct session key, waiting interval
+// between sends. It stops early if ctx is cancelled; keys not reached by
+// then are neither sent nor failed.
+func (r *Registry) Broadcast(ctx context.Context, sessionKeys []string, message string, interval time.Duration) *BroadcastResult {
+	result := &BroadcastResult{}
+	seen := make(map[string]bool, len(sessionKeys))
+	first := true
+	for _, key := range sessionKeys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		handler, ok := r.handlerFor(key)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		if !first && interval > 0 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(interval):
+			}
+		}
+		first = false
+
+		if err := handler(key, message); errors.Is(err, ErrNoHandler) {
+			result.Skipped++
+			continue
+		} else if err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[key] = err.Error()
+			continue
+		}
+		result.Sent++
+	}
+	return result
+}
+
+// handlerFor returns the handler whose prefix matches sessionKey.
+func (r *Registry) handlerFor(sessionKey string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for prefix, handler := range r.handlers {
+		if strings.HasPrefix(sessionKey, prefix) {
+			return handler, true
+		}
+	}
+	return nil, false
+}
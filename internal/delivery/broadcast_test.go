@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryBroadcast(t *testing.T) {
+	reg := NewRegistry()
+
+	var got []string
+	reg.Register("telegram:", func(sessionKey, message string) error {
+		if sessionKey == "telegram:3:3" {
+			return errors.New("chat not found")
+		}
+		got = append(got, sessionKey+"="+message)
+		return nil
+	})
+
+	keys := []string{"telegram:1:1", "http:hook", "telegram:2:2", "telegram:1:1", "telegram:3:3"}
+	start := time.Now()
+	result := reg.Broadcast(context.Background(), keys, "restarting", 10*time.Millisecond)
+
+	if result.Sent != 2 || result.Skipped != 1 || len(result.Failed) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Failed["telegram:3:3"] != "chat not found" {
+		t.Errorf("expected failure to be reported, got %v", result.Failed)
+	}
+	if len(got) != 2 || got[0] != "telegram:1:1=restarting" || got[1] != "telegram:2:2=restarting" {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+	// Three handled sends → at least two intervals
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected sends to be rate limited, took %v", elapsed)
+	}
+}
+
+func TestRegistryBroadcastCancelled(t *testing.T) {
+	reg := NewRegistry()
+	sent := 0
+	reg.Register("telegram:", func(sessionKey, message string) error {
+		sent++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := reg.Broadcast(ctx, []string{"telegram:1:1", "telegram:2:2"}, "hi", time.Hour)
+	if sent != 1 || result.Sent != 1 {
+		t.Errorf("expected broadcast to stop after the first send, sent=%d result=%+v", sent, result)
+	}
+}
@@ -0,0 +1,62 @@
+package delivery
+
+import "errors"
+
+// TargetError is the failure to deliver to one of several targets.
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+func (e *TargetError) Error() string { return e.Target + ": " + e.Err.Error() }
+
+func (e *TargetError) Unwrap() error { return e.Err }
+
+// Fanout calls deliver for each distinct target, carrying on past
+// failures. The returned error joins a *TargetError for each target that
+// failed, or is nil if all succeeded.
+func Fanout(targets []string, deliver func(target string) error) error {
+	var errs []error
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		if err := deliver(target); err != nil {
+			errs = append(errs, &TargetError{Target: target, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TargetErrors returns the per-target failures in an error from Fanout.
+func TargetErrors(err error) []*TargetError {
+	var out []*TargetError
+	var walk func(error)
+	walk = func(err error) {
+		if te, ok := err.(*TargetError); ok {
+			out = append(out, te)
+			return
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+		} else if e := errors.Unwrap(err); e != nil {
+			walk(e)
+		}
+	}
+	if err != nil {
+		walk(err)
+	}
+	return out
+}
+
+// DeliverAll sends message to each target through its handler. See
+// Fanout for how failures are reported.
+func (r *Registry) DeliverAll(targets []string, message string) error {
+	return Fanout(targets, func(target string) error {
+		return r.Deliver(target, message)
+	})
+}
@@ -0,0 +1,153 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/gopherclaw/internal/state"
+)
+
+const (
+	// DefaultRetryInterval is how often the outbox looks for messages due
+	// for another attempt.
+	DefaultRetryInterval = time.Minute
+	// DefaultMaxAge is how long an undelivered message is retried before
+	// it is dropped.
+	DefaultMaxAge = 24 * time.Hour
+
+	// minBackoff and maxBackoff bound the wait between attempts, which
+	// doubles after every failure.
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Outbox delivers messages through a Registry and persists the ones that
+// fail, so a channel outage or a restart doesn't lose them. Queued
+// messages are retried with exponential backoff until they are delivered
+// or older than the maximum age.
+type Outbox struct {
+	reg    *Registry
+	store  *state.OutboxStore
+	maxAge time.Duration
+	now    func() time.Time
+
+	// retrying serializes Retry so a slow pass and the next tick don't
+	// send the same message twice.
+	retrying sync.Mutex
+}
+
+// NewOutbox creates an Outbox delivering through reg and queueing in
+// store. A maxAge of 0 uses DefaultMaxAge.
+func NewOutbox(reg *Registry, store *state.OutboxStore, maxAge time.Duration) *Outbox {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Outbox{reg: reg, store: store, maxAge: maxAge, now: time.Now}
+}
+
+// Deliver sends message to sessionKey. If the send fails the message is
+// queued for retry and the returned error says so; source names what
+// produced it in logs. Messages for keys with no handler are not queued.
+func (o *Outbox) Deliver(sessionKey, message, source string) error {
+	err := o.reg.Deliver(sessionKey, message)
+	if err == nil || errors.Is(err, ErrNoHandler) {
+		return err
+	}
+	now := o.now()
+	entry := &state.OutboxEntry{
+		ID:          uuid.New().String(),
+		SessionKey:  sessionKey,
+		Message:     message,
+		Source:      source,
+		Created:     now,
+		Attempts:    1,
+		NextAttempt: now.Add(backoff(1)),
+		LastError:   err.Error(),
+	}
+	if qerr := o.store.Add(entry); qerr != nil {
+		return fmt.Errorf("%w (queue for retry: %v)", err, qerr)
+	}
+	slog.Warn("delivery failed, queued for retry", "session_key", sessionKey, "source", source, "retry_at", entry.NextAttempt, "error", err)
+	return fmt.Errorf("%w (queued for retry)", err)
+}
+
+// Retry attempts every queued message that is due, dropping those older
+// than the maximum age. It returns how many were delivered.
+func (o *Outbox) Retry(ctx context.Context) (int, error) {
+	o.retrying.Lock()
+	defer o.retrying.Unlock()
+
+	entries, err := o.store.List()
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		now := o.now()
+		if now.Sub(entry.Created) > o.maxAge {
+			slog.Warn("undelivered message expired", "session_key", entry.SessionKey, "source", entry.Source,
+				"created", entry.Created, "attempts", entry.Attempts, "last_error", entry.LastError)
+			if err := o.store.Remove(entry.ID); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if now.Before(entry.NextAttempt) {
+			continue
+		}
+
+		err := o.reg.Deliver(entry.SessionKey, entry.Message)
+		if err == nil {
+			slog.Info("queued message delivered", "session_key", entry.SessionKey, "source", entry.Source, "attempts", entry.Attempts+1)
+			if err := o.store.Remove(entry.ID); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		}
+		entry.Attempts++
+		entry.NextAttempt = now.Add(backoff(entry.Attempts))
+		entry.LastError = err.Error()
+		if err := o.store.Update(entry); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Run retries queued messages immediately and then every interval until
+// ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := o.Retry(ctx); err != nil {
+			slog.Error("outbox retry failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestOutboxRetry(t *testing.T) {
+	store := state.NewOutboxStore(filepath.Join(t.TempDir(), "outbox.json"))
+	reg := NewRegistry()
+	down := true
+	var got []string
+	reg.Register("telegram:", func(sessionKey, message string) error {
+		if down {
+			return errors.New("telegram unreachable")
+		}
+		got = append(got, message)
+		return nil
+	})
+	outbox := NewOutbox(reg, store, 0)
+	now := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+
+	err := outbox.Deliver("telegram:1", "morning briefing", "task:briefing")
+	if err == nil || !strings.Contains(err.Error(), "queued for retry") {
+		t.Fatalf("expected queued error, got %v", err)
+	}
+	if err := outbox.Deliver("http:x", "no handler", "test"); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("expected ErrNoHandler, got %v", err)
+	}
+	entries, _ := store.List()
+	if len(entries) != 1 || entries[0].Source != "task:briefing" {
+		t.Fatalf("queued entries = %+v", entries)
+	}
+
+	// Not due yet.
+	if sent, err := outbox.Retry(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("early retry sent %d, %v", sent, err)
+	}
+
+	// Due but still failing: the backoff doubles.
+	now = now.Add(minBackoff)
+	if _, err := outbox.Retry(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = store.List()
+	if entries[0].Attempts != 2 || !entries[0].NextAttempt.Equal(now.Add(2*minBackoff)) {
+		t.Errorf("after second failure: %+v", entries[0])
+	}
+
+	// A restarted daemon uses a fresh Outbox over the same file.
+	down = false
+	now = now.Add(2 * minBackoff)
+	restarted := NewOutbox(reg, store, 0)
+	restarted.now = outbox.now
+	if sent, err := restarted.Retry(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("retry after recovery sent %d, %v", sent, err)
+	}
+	if len(got) != 1 || got[0] != "morning briefing" {
+		t.Errorf("delivered %v", got)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Errorf("delivered entry still queued: %+v", entries)
+	}
+}
+
+func TestOutboxExpiry(t *testing.T) {
+	store := state.NewOutboxStore(filepath.Join(t.TempDir(), "outbox.json"))
+	reg := NewRegistry()
+	calls := 0
+	reg.Register("telegram:", func(sessionKey, message string) error {
+		calls++
+		return errors.New("down")
+	})
+	outbox := NewOutbox(reg, store, time.Hour)
+	now := time.Now()
+	outbox.now = func() time.Time { return now }
+
+	outbox.Deliver("telegram:1", "stale", "test")
+	now = now.Add(2 * time.Hour)
+	if _, err := outbox.Retry(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("expired message was retried: %d calls", calls)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Errorf("expired entry still queued: %+v", entries)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if backoff(1) != minBackoff || backoff(3) != 4*minBackoff || backoff(30) != maxBackoff {
+		t.Errorf("backoff = %v, %v, %v", backoff(1), backoff(3), backoff(30))
+	}
+}
@@ -2,11 +2,15 @@
 package delivery
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 	"sync"
 )
 
+// ErrNoHandler means no handler is registered for a session key, so the
+// message can never be delivered.
+var ErrNoHandler = errors.New("no delivery handler for session key")
+
 // Handler delivers a message to a session identified by sessionKey.
 type Handler func(sessionKey, message string) error
 
@@ -34,12 +38,9 @@ func (r *Registry) Register(prefix string, handler Handler) {
 // Deliver finds the handler matching the session key prefix and calls it.
 // Returns an error if no handler is registered for the prefix.
 func (r *Registry) Deliver(sessionKey, message string) error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	for prefix, handler := range r.handlers {
-		if strings.HasPrefix(sessionKey, prefix) {
-			return handler(sessionKey, message)
-		}
+	handler, ok := r.handlerFor(sessionKey)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoHandler, sessionKey)
 	}
-	return fmt.Errorf("no delivery handler for session key: %s", sessionKey)
+	return handler(sessionKey, message)
 }
@@ -2,6 +2,8 @@
 package delivery
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -63,3 +65,30 @@ func TestRegistryMultiplePrefixes(t *testing.T) {
 		t.Errorf("expected 1 slack call, got %d", slackCalls)
 	}
 }
+
+func TestRegistryDeliverAll(t *testing.T) {
+	reg := NewRegistry()
+	var got []string
+	reg.Register("telegram:", func(sessionKey, message string) error {
+		got = append(got, sessionKey)
+		return nil
+	})
+	reg.Register("email:", func(sessionKey, message string) error {
+		return errors.New("smtp down")
+	})
+
+	err := reg.DeliverAll([]string{"telegram:1:1", "email:a@example.com", "telegram:1:1", "telegram:2:2", "nowhere:x"}, "hi")
+	if fmt.Sprint(got) != "[telegram:1:1 telegram:2:2]" {
+		t.Errorf("delivered to %v", got)
+	}
+	failed := TargetErrors(err)
+	if len(failed) != 2 || failed[0].Target != "email:a@example.com" || failed[1].Target != "nowhere:x" {
+		t.Fatalf("target errors = %v", failed)
+	}
+	if !errors.Is(err, ErrNoHandler) {
+		t.Error("expected the joined error to wrap ErrNoHandler")
+	}
+	if err := reg.DeliverAll([]string{"telegram:3:3"}, "hi"); err != nil || TargetErrors(err) != nil {
+		t.Errorf("DeliverAll = %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package delivery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+// Format values for a delivery template.
+const (
+	FormatMarkdown = "markdown"
+	FormatPlain    = "plain"
+)
+
+// Channel returns the channel of a session key: the part before the first
+// colon, such as "telegram".
+func Channel(sessionKey string) string {
+	channel, _, _ := strings.Cut(sessionKey, ":")
+	return channel
+}
+
+// TemplateFor picks the task's delivery template for the session key's
+// channel, falling back to the "*" template. It returns nil if neither is
+// set.
+func TemplateFor(task *state.Task, sessionKey string) *state.DeliveryTemplate {
+	if tmpl, ok := task.Delivery[Channel(sessionKey)]; ok {
+		return tmpl
+	}
+	return task.Delivery["*"]
+}
+
+// templateData is what delivery header and footer templates can use.
+type templateData struct {
+	Task    string
+	Channel string
+	Date    string
+}
+
+// Apply shapes a task's response for delivery to sessionKey with the task's
+// template for that channel. Without a template the response is returned
+// unchanged.
+func Apply(task *state.Task, sessionKey, response string, now time.Time) (string, error) {
+	tmpl := TemplateFor(task, sessionKey)
+	if tmpl == nil {
+		return response, nil
+	}
+	data := templateData{Task: task.Name, Channel: Channel(sessionKey), Date: now.Format(time.DateOnly)}
+	header, err := renderPart("header", tmpl.Header, data)
+	if err != nil {
+		return "", err
+	}
+	footer, err := renderPart("footer", tmpl.Footer, data)
+	if err != nil {
+		return "", err
+	}
+
+	body := response
+	if tmpl.Format == FormatPlain {
+		body = StripMarkdown(body)
+	}
+	if tmpl.MaxLength > 0 && len([]rune(body)) > tmpl.MaxLength {
+		body = strings.TrimSpace(string([]rune(body)[:tmpl.MaxLength])) + "…"
+	}
+	if tmpl.Emoji != "" {
+		if header != "" {
+			header = tmpl.Emoji + " " + header
+		} else {
+			body = tmpl.Emoji + " " + body
+		}
+	}
+
+	var parts []string
+	for _, p := range []string{header, body, footer} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// ValidateTemplates checks that every header and footer template parses and
+// every format is known.
+func ValidateTemplates(templates map[string]*state.DeliveryTemplate) error {
+	for channel, tmpl := range templates {
+		if tmpl == nil {
+			return fmt.Errorf("delivery template for %s is empty", channel)
+		}
+		switch tmpl.Format {
+		case "", FormatMarkdown, FormatPlain:
+		default:
+			return fmt.Errorf("delivery template for %s: unknown format %q", channel, tmpl.Format)
+		}
+		for name, text := range map[string]string{"header": tmpl.Header, "footer": tmpl.Footer} {
+			if _, err := template.New(name).Option("missingkey=error").Parse(text); err != nil {
+				return fmt.Errorf("delivery template for %s: parse %s: %w", channel, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renderPart executes a header or footer template.
+func renderPart(name, text string, data templateData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse delivery %s: %w", name, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render delivery %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`(?m)^#{1,6}\s+`)
+	mdFence    = regexp.MustCompile("(?m)^```[^\n]*\n?")
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBullet   = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+	mdEmphasis = []*regexp.Regexp{
+		regexp.MustCompile(`\*\*([^*\n]+)\*\*`),
+		regexp.MustCompile(`__([^_\n]+)__`),
+		regexp.MustCompile(`~~([^~\n]+)~~`),
+		regexp.MustCompile(`\*([^*\s][^*\n]*)\*`),
+		regexp.MustCompile(`\b_([^_\n]+)_\b`),
+	}
+)
+
+// StripMarkdown removes common markdown syntax for channels that show it
+// literally: headings, code fences and spans, emphasis, and links (kept as
+// "text (url)"). List items become "- " lines.
+func StripMarkdown(text string) string {
+	text = mdFence.ReplaceAllString(text, "")
+	text = mdHeading.ReplaceAllString(text, "")
+	text = mdLink.ReplaceAllString(text, "$1 ($2)")
+	text = mdBullet.ReplaceAllString(text, "$1- ")
+	for _, re := range mdEmphasis {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	return strings.ReplaceAll(text, "`", "")
+}
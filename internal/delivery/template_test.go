@@ -0,0 +1,61 @@
+package delivery
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestApply(t *testing.T) {
+	task := &state.Task{
+		Name: "disk-report",
+		Delivery: map[string]*state.DeliveryTemplate{
+			"telegram": {Emoji: "🚨", Format: FormatPlain, MaxLength: 40},
+			"*":        {Header: "# {{.Task}} digest for {{.Date}}", Footer: "Sent to {{.Channel}}"},
+		},
+	}
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	response := "## Disk\n\n**/var** is at [95%](https://grafana/disk) and `growing` fast, clean up soon."
+
+	got, err := Apply(task, "telegram:1:1", response, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "🚨 Disk\n\n/var is at 95% (https://grafana/di…"; got != want {
+		t.Errorf("telegram:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = Apply(task, "email:ops@example.com", response, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "# disk-report digest for 2026-03-02\n\n## Disk") || !strings.HasSuffix(got, "soon.\n\nSent to email") {
+		t.Errorf("email: unexpected message %q", got)
+	}
+
+	if got, _ := Apply(&state.Task{Name: "plain"}, "telegram:1:1", response, now); got != response {
+		t.Errorf("expected the response unchanged without templates, got %q", got)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	if err := ValidateTemplates(map[string]*state.DeliveryTemplate{"telegram": {Header: "{{.Task"}}); err == nil {
+		t.Error("expected error for a malformed header")
+	}
+	if err := ValidateTemplates(map[string]*state.DeliveryTemplate{"telegram": {Format: "html"}}); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+	if err := ValidateTemplates(map[string]*state.DeliveryTemplate{"*": {Header: "{{.Task}}", Format: FormatPlain}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStripMarkdown(t *testing.T) {
+	in := "# Title\n\n```go\nx := 1\n```\n* one\n* two with _emphasis_ and ~~strike~~"
+	want := "Title\n\nx := 1\n- one\n- two with emphasis and strike"
+	if got := StripMarkdown(in); got != want {
+		t.Errorf("StripMarkdown:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookAttempts is how many times one delivery tries a webhook
+	// before leaving the message to the outbox's retries.
+	webhookAttempts = 3
+	// webhookTimeout bounds each attempt.
+	webhookTimeout = 15 * time.Second
+
+	// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of
+	// TimestampHeader's value, a dot and the request body, keyed with the
+	// sink's secret.
+	SignatureHeader = "X-Gopherclaw-Signature"
+	// TimestampHeader carries the Unix time the request was signed at, so
+	// receivers can reject replays.
+	TimestampHeader = "X-Gopherclaw-Timestamp"
+)
+
+// WebhookSink is an external endpoint that messages are POSTed to.
+type WebhookSink struct {
+	URL string
+	// Secret signs each request; empty sends it unsigned.
+	Secret string
+	// Headers are added to each request, e.g. for authentication.
+	Headers map[string]string
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook.
+type WebhookPayload struct {
+	SessionKey string    `json:"session_key"`
+	Message    string    `json:"message"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+// Webhooks delivers messages for "http:" keys by POSTing them as JSON. A
+// key names a configured sink ("http:<name>") or, if allowed, holds the
+// URL itself ("http:https://example.com/hook"). Other http: keys, such as
+// those of HTTP API sessions, get ErrNoHandler.
+type Webhooks struct {
+	sinks map[string]WebhookSink
+	// urls, if set, is used for keys holding a URL, with its URL
+	// replaced by the key's.
+	urls   *WebhookSink
+	client *http.Client
+	wait   time.Duration
+	now    func() time.Time
+}
+
+// NewWebhooks creates a handler for the named sinks. With allowURLs, keys
+// that hold a URL are delivered too, signed with secret.
+func NewWebhooks(sinks map[string]WebhookSink, allowURLs bool, secret string) *Webhooks {
+	w := &Webhooks{
+		sinks:  sinks,
+		client: &http.Client{Timeout: webhookTimeout},
+		wait:   time.Second,
+		now:    time.Now,
+	}
+	if allowURLs {
+		w.urls = &WebhookSink{Secret: secret}
+	}
+	return w
+}
+
+// Sink returns where a key's messages are POSTed, if anywhere.
+func (w *Webhooks) Sink(sessionKey string) (WebhookSink, bool) {
+	name, ok := strings.CutPrefix(sessionKey, "http:")
+	if !ok {
+		return WebhookSink{}, false
+	}
+	if sink, ok := w.sinks[name]; ok {
+		return sink, true
+	}
+	if w.urls != nil {
+		if u, err := url.Parse(name); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			sink := *w.urls
+			sink.URL = name
+			return sink, true
+		}
+	}
+	return WebhookSink{}, false
+}
+
+// Deliver POSTs message to the key's sink, retrying a few times on
+// network errors and 5xx or 429 responses.
+func (w *Webhooks) Deliver(sessionKey, message string) error {
+	sink, ok := w.Sink(sessionKey)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoHandler, sessionKey)
+	}
+	body, err := json.Marshal(WebhookPayload{SessionKey: sessionKey, Message: message, SentAt: w.now().UTC()})
+	if err != nil {
+		return err
+	}
+	wait := w.wait
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(sink, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == webhookAttempts {
+			return err
+		}
+		slog.Warn("webhook delivery failed, retrying", "session_key", sessionKey, "attempt", attempt, "error", err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying.
+func (w *Webhooks) post(sink WebhookSink, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, sink.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gopherclaw")
+	for k, v := range sink.Headers {
+		req.Header.Set(k, v)
+	}
+	if sink.Secret != "" {
+		ts := strconv.FormatInt(w.now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(sink.Secret, ts, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("post webhook: status %d", resp.StatusCode)
+}
+
+// Sign returns the SignatureHeader value for a body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
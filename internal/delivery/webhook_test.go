@@ -0,0 +1,77 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhooksDeliver(t *testing.T) {
+	var calls atomic.Int32
+	var got WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/gone":
+			calls.Add(1)
+			w.WriteHeader(http.StatusGone)
+			return
+		case "/signed":
+			if r.Header.Get("Authorization") != "Bearer t" {
+				t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+			}
+			if want := Sign("s3cret", r.Header.Get(TimestampHeader), body); r.Header.Get(SignatureHeader) != want {
+				t.Errorf("signature = %q, want %q", r.Header.Get(SignatureHeader), want)
+			}
+		default:
+			if r.Header.Get(SignatureHeader) != "" {
+				t.Error("expected an unsigned request")
+			}
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer server.Close()
+
+	hooks := NewWebhooks(map[string]WebhookSink{
+		"ops":   {URL: server.URL + "/signed", Secret: "s3cret", Headers: map[string]string{"Authorization": "Bearer t"}},
+		"flaky": {URL: server.URL + "/flaky"},
+		"gone":  {URL: server.URL + "/gone"},
+	}, false, "")
+	hooks.wait = time.Millisecond
+
+	if err := hooks.Deliver("http:ops", "disk full"); err != nil {
+		t.Fatal(err)
+	}
+	if got.SessionKey != "http:ops" || got.Message != "disk full" || got.SentAt.IsZero() {
+		t.Errorf("payload = %+v", got)
+	}
+
+	if err := hooks.Deliver("http:flaky", "x"); err != nil || calls.Load() != 3 {
+		t.Errorf("flaky sink: %v after %d calls", err, calls.Load())
+	}
+	calls.Store(0)
+	if err := hooks.Deliver("http:gone", "x"); err == nil || calls.Load() != 1 {
+		t.Errorf("gone sink: %v after %d calls, want one failed call", err, calls.Load())
+	}
+
+	// API sessions and, unless allowed, URL keys aren't webhooks.
+	for _, key := range []string{"http:my-app", "http:" + server.URL + "/plain"} {
+		if err := hooks.Deliver(key, "x"); !errors.Is(err, ErrNoHandler) {
+			t.Errorf("Deliver(%s) = %v, want ErrNoHandler", key, err)
+		}
+	}
+	hooks = NewWebhooks(nil, true, "")
+	if err := hooks.Deliver("http:"+server.URL+"/plain", "hello"); err != nil || got.Message != "hello" {
+		t.Errorf("URL key: %v, payload %+v", err, got)
+	}
+}
@@ -0,0 +1,202 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+const (
+	// defaultMailbox is polled when IMAPConfig.Mailbox is empty.
+	defaultMailbox = "INBOX"
+	// defaultInterval is how often the mailbox is polled when
+	// IMAPConfig.Interval is zero.
+	defaultInterval = time.Minute
+	// pollTimeout bounds one poll of the mailbox.
+	pollTimeout = 5 * time.Minute
+)
+
+// IMAPConfig says which mailbox to poll and whose mail to accept.
+type IMAPConfig struct {
+	// Addr is the server's "host:port", reached over TLS unless Insecure.
+	Addr     string
+	Username string
+	Password string
+	Mailbox  string
+	Insecure bool
+	Interval time.Duration
+	// AllowedSenders are the addresses whose mail is handled. Mail from
+	// anyone else is marked seen and ignored.
+	AllowedSenders []string
+}
+
+// Handler starts runs for inbound messages; *gateway.Gateway is one.
+type Handler interface {
+	HandleInbound(ctx context.Context, event *types.InboundEvent, opts ...gateway.RunOption) error
+}
+
+// Adapter polls a mailbox and hands each unseen message from an allowed
+// sender to the gateway, in the session of the sender's address. Responses
+// are sent back as replies.
+type Adapter struct {
+	cfg     IMAPConfig
+	handler Handler
+	sender  *Sender
+	allowed map[string]bool
+}
+
+// NewAdapter creates an adapter that replies through sender.
+func NewAdapter(cfg IMAPConfig, handler Handler, sender *Sender) (*Adapter, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("imap address is required")
+	}
+	if len(cfg.AllowedSenders) == 0 {
+		return nil, errors.New("imap needs at least one allowed sender")
+	}
+	if sender == nil {
+		return nil, errors.New("imap needs smtp to send replies")
+	}
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = defaultMailbox
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	allowed := make(map[string]bool, len(cfg.AllowedSenders))
+	for _, addr := range cfg.AllowedSenders {
+		allowed[strings.ToLower(strings.TrimSpace(addr))] = true
+	}
+	return &Adapter{cfg: cfg, handler: handler, sender: sender, allowed: allowed}, nil
+}
+
+// Start polls the mailbox until ctx is done.
+func (a *Adapter) Start(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.Poll(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("email poll failed", "mailbox", a.cfg.Mailbox, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll handles the mailbox's unseen messages once. A message is marked
+// seen once it has been handed to the gateway, or when it is ignored; a
+// message that fails for another reason stays unseen and is tried again on
+// the next poll.
+func (a *Adapter) Poll(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+	c, err := dialIMAP(ctx, a.cfg.Addr, a.cfg.Insecure)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	defer c.logout()
+	if err := c.login(a.cfg.Username, a.cfg.Password); err != nil {
+		return err
+	}
+	if err := c.selectMailbox(a.cfg.Mailbox); err != nil {
+		return err
+	}
+	uids, err := c.unseen()
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		raw, err := c.fetch(uid)
+		if err != nil {
+			return err
+		}
+		if err := a.handle(ctx, raw); err != nil {
+			slog.Warn("email message not handled; will retry", "uid", uid, "error", err)
+			continue
+		}
+		if err := c.markSeen(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle turns a message into a run. It returns nil for messages that are
+// done with, including ones it ignores.
+func (a *Adapter) handle(ctx context.Context, raw []byte) error {
+	in, err := Parse(raw)
+	if err != nil {
+		slog.Warn("ignoring unparseable email", "error", err)
+		return nil
+	}
+	if !a.allowed[in.From] {
+		slog.Warn("ignoring email from sender not in email.allowed_senders", "from", in.From)
+		return nil
+	}
+	if in.From == strings.ToLower(a.sender.Address()) {
+		// Our own mail, e.g. a bounce copy; answering it would loop.
+		return nil
+	}
+	text := in.Text
+	if in.Subject != "" && !isReply(in.Subject) {
+		text = strings.TrimSpace(in.Subject + "\n\n" + text)
+	}
+	if text == "" {
+		return nil
+	}
+	event := &types.InboundEvent{
+		Source:     "email",
+		SessionKey: types.EmailKey(in.From),
+		UserID:     in.From,
+		Text:       text,
+		Metadata: &types.InboundMeta{
+			Version:   types.InboundSchemaVersion,
+			MessageID: in.MessageID,
+			ChatTitle: in.Subject,
+			Username:  in.Name,
+		},
+	}
+	reply := func(body string) {
+		out := &Outgoing{To: in.From, Subject: replySubject(in.Subject), Body: body, InReplyTo: in.MessageID}
+		if err := a.sender.Send(out); err != nil {
+			slog.Error("send email reply", "to", in.From, "error", err)
+		}
+	}
+	err = a.handler.HandleInbound(ctx, event, gateway.WithOnResult(func(result *gateway.RunResult) {
+		if result.Text != "" {
+			reply(result.Text)
+		}
+	}), gateway.WithOnNotice(reply))
+	var locked *gateway.LockedError
+	if errors.As(err, &locked) {
+		reply(fmt.Sprintf("This conversation is locked and can't take new messages (%s).", locked.Reason))
+		return nil
+	}
+	return err
+}
+
+// isReply reports whether a subject marks its message as a reply.
+func isReply(subject string) bool {
+	return len(subject) >= 3 && strings.EqualFold(subject[:3], "re:")
+}
+
+// replySubject is the subject of a reply to a message with subject.
+func replySubject(subject string) string {
+	switch {
+	case subject == "":
+		return "Re: " + defaultSubject
+	case isReply(subject):
+		return subject
+	default:
+		return "Re: " + subject
+	}
+}
@@ -0,0 +1,186 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLiteral caps a message fetched from the mailbox.
+const maxLiteral = 25 << 20
+
+// literalSuffix matches the "{n}" that announces a literal at the end of a
+// response line.
+var literalSuffix = regexp.MustCompile(`\{(\d+)\}$`)
+
+// imapConn is the little of IMAP4rev1 the adapter needs: log in, select a
+// mailbox, find unseen messages, fetch them and mark them seen.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse is one untagged response line, with any literals it
+// carried.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// dialIMAP connects to addr over TLS, or in plain text if insecure, and
+// reads the server's greeting.
+func dialIMAP(ctx context.Context, addr string, insecure bool) (*imapConn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if insecure {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap dial: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting.line)
+	}
+	return c, nil
+}
+
+func (c *imapConn) close() error { return c.conn.Close() }
+
+// read reads one response line, following literals to the line's end.
+func (c *imapConn) read() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		m := literalSuffix.FindStringSubmatch(line)
+		if m == nil {
+			resp.line += line
+			return resp, nil
+		}
+		n, _ := strconv.Atoi(m[1])
+		if n > maxLiteral {
+			return resp, fmt.Errorf("imap literal of %d bytes is too large", n)
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return resp, err
+		}
+		resp.line += line
+		resp.literals = append(resp.literals, lit)
+	}
+}
+
+// cmd sends a command and returns its untagged responses, or an error if
+// the server doesn't answer OK.
+func (c *imapConn) cmd(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := "g" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, err
+	}
+	var untagged []imapResponse
+	for {
+		resp, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		status, ok := strings.CutPrefix(resp.line, tag+" ")
+		if !ok {
+			untagged = append(untagged, resp)
+			continue
+		}
+		if !strings.HasPrefix(status, "OK") {
+			verb, _, _ := strings.Cut(command, " ")
+			return nil, fmt.Errorf("imap %s: %s", verb, status)
+		}
+		return untagged, nil
+	}
+}
+
+// quote makes s an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (c *imapConn) login(username, password string) error {
+	_, err := c.cmd("LOGIN " + quote(username) + " " + quote(password))
+	return err
+}
+
+func (c *imapConn) selectMailbox(name string) error {
+	_, err := c.cmd("SELECT " + quote(name))
+	return err
+}
+
+// unseen returns the UIDs of the selected mailbox's unseen messages.
+func (c *imapConn) unseen() ([]uint32, error) {
+	resps, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, resp := range resps {
+		rest, ok := strings.CutPrefix(resp.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, f := range strings.Fields(rest) {
+			if uid, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch returns a message's raw bytes without marking it seen.
+func (c *imapConn) fetch(uid uint32) ([]byte, error) {
+	resps, err := c.cmd(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range resps {
+		if strings.Contains(resp.line, "FETCH") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, errors.New("imap fetch: no message body in response")
+}
+
+// markSeen sets the \Seen flag on a message.
+func (c *imapConn) markSeen(uid uint32) error {
+	_, err := c.cmd(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+// logout ends the session politely; errors don't matter by then.
+func (c *imapConn) logout() {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c.cmd("LOGOUT")
+}
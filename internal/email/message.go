@@ -0,0 +1,138 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+)
+
+// maxBody caps the text taken from one message.
+const maxBody = 64 << 10
+
+// quoteHeader matches the line mail clients put above a quoted reply,
+// e.g. "On Mon, 2 Mar 2026 at 08:00, Bot <bot@example.com> wrote:".
+var quoteHeader = regexp.MustCompile(`^On .+ wrote:$`)
+
+// Inbound is a received message, reduced to what the agent needs.
+type Inbound struct {
+	// From is the sender's address, lowercased.
+	From      string
+	Name      string
+	Subject   string
+	MessageID string
+	// Text is the message's new text: the plain-text part, or the HTML
+	// part as markdown, without the quoted conversation below it.
+	Text string
+}
+
+// Parse reads a raw RFC 5322 message.
+func Parse(raw []byte) (*Inbound, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return nil, fmt.Errorf("parse message: no sender: %v", err)
+	}
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	plain, html, err := bodyText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	text := plain
+	if strings.TrimSpace(text) == "" && html != "" {
+		if md, err := htmltomarkdown.ConvertString(html); err == nil {
+			text = md
+		}
+	}
+	return &Inbound{
+		From:      strings.ToLower(from[0].Address),
+		Name:      from[0].Name,
+		Subject:   strings.TrimSpace(subject),
+		MessageID: strings.TrimSpace(msg.Header.Get("Message-ID")),
+		Text:      stripQuoted(text),
+	}, nil
+}
+
+// bodyText returns the first text/plain and text/html parts of a body,
+// looking inside multipart bodies. Attachments are skipped.
+func bodyText(contentType, encoding string, body io.Reader) (plain, html string, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return plain, html, nil
+			}
+			if err != nil {
+				return plain, html, fmt.Errorf("parse message: %w", err)
+			}
+			if disp, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disp == "attachment" {
+				continue
+			}
+			p, h, err := bodyText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return plain, html, err
+			}
+			if plain == "" {
+				plain = p
+			}
+			if html == "" {
+				html = h
+			}
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxBody))
+	if err != nil {
+		return "", "", fmt.Errorf("parse message: %w", err)
+	}
+	text := strings.ToValidUTF8(strings.ReplaceAll(string(data), "\r\n", "\n"), "�")
+	if mediaType == "text/html" {
+		return "", text, nil
+	}
+	return text, "", nil
+}
+
+// stripQuoted drops the quoted conversation a reply carries below (or
+// interleaved with) the new text, and the signature.
+func stripQuoted(text string) string {
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if quoteHeader.MatchString(trimmed) || line == "-- " {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
@@ -0,0 +1,203 @@
+// Package email connects gopherclaw to email: an SMTP sender registered
+// with the delivery registry for "email:<address>" keys, and an adapter
+// that polls an IMAP mailbox and turns mail from allowed senders into
+// inbound messages.
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+const (
+	// dialTimeout bounds connecting to a mail server.
+	dialTimeout = 30 * time.Second
+	// maxSubject is how many characters of a response's first line make
+	// the subject of a message that doesn't reply to another.
+	maxSubject = 70
+	// defaultSubject is used when a response has no usable first line.
+	defaultSubject = "Message from gopherclaw"
+)
+
+// Sender sends mail through an SMTP server.
+type Sender struct {
+	addr     string
+	from     *mail.Address
+	username string
+	password string
+	now      func() time.Time
+}
+
+// NewSender creates a Sender for the server at addr ("host:port"). Port
+// 465 uses implicit TLS; on other ports the connection is upgraded with
+// STARTTLS when the server offers it. Without a username no
+// authentication is attempted.
+func NewSender(addr, from, username, password string) (*Sender, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("smtp address %q: %w", addr, err)
+	}
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("from address %q: %w", from, err)
+	}
+	return &Sender{addr: addr, from: fromAddr, username: username, password: password, now: time.Now}, nil
+}
+
+// Address returns the address mail is sent from.
+func (s *Sender) Address() string { return s.from.Address }
+
+// Outgoing is a plain-text message to send.
+type Outgoing struct {
+	To      string
+	Subject string
+	Body    string
+	// InReplyTo is the Message-ID of the message this one answers.
+	InReplyTo string
+}
+
+// Deliver sends message to the address of an "email:" key, with its first
+// line as the subject. It is the delivery handler for scheduled responses.
+func (s *Sender) Deliver(sessionKey, message string) error {
+	p, err := types.ParseSessionKey(sessionKey)
+	if err != nil {
+		return err
+	}
+	if p.Scheme != "email" {
+		return fmt.Errorf("%w %q: not an email key", types.ErrInvalidSessionKey, sessionKey)
+	}
+	return s.Send(&Outgoing{To: p.Field("address"), Subject: subjectFor(message), Body: message})
+}
+
+// Send sends a message.
+func (s *Sender) Send(m *Outgoing) error {
+	msg, err := s.compose(m)
+	if err != nil {
+		return err
+	}
+	c, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if s.username != "" {
+		host, _, _ := net.SplitHostPort(s.addr)
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// dial connects and says hello, upgrading to TLS where possible.
+func (s *Sender) dial() (*smtp.Client, error) {
+	host, port, _ := net.SplitHostPort(s.addr)
+	var conn net.Conn
+	var err error
+	if port == "465" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", s.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = net.DialTimeout("tcp", s.addr, dialTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp hello: %w", err)
+	}
+	if port != "465" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("smtp starttls: %w", err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// compose renders m as a MIME message with a quoted-printable UTF-8 body.
+func (s *Sender) compose(m *Outgoing) ([]byte, error) {
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("to address %q: %w", m.To, err)
+	}
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", s.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", s.now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+uuid.NewString()+"@"+domain(s.from.Address)+">")
+	if m.InReplyTo != "" {
+		header("In-Reply-To", m.InReplyTo)
+		header("References", m.InReplyTo)
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(m.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// domain returns the part of an address after the @.
+func domain(address string) string {
+	_, d, ok := strings.Cut(address, "@")
+	if !ok || d == "" {
+		return "gopherclaw"
+	}
+	return d
+}
+
+// subjectFor makes a subject from a message's first non-empty line,
+// without markdown decoration.
+func subjectFor(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "#*_` \t\r"))
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > maxSubject {
+			line = string([]rune(line)[:maxSubject-1]) + "…"
+		}
+		return line
+	}
+	return defaultSubject
+}
@@ -0,0 +1,148 @@
+// Package feedback records user ratings of bot responses as events and
+// aggregates them so prompt and model changes can be compared.
+package feedback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// Ratings accepted by Record.
+const (
+	Good = "good"
+	Bad  = "bad"
+)
+
+// ErrNoResponse is returned by Record when the session has no bot response
+// to attach feedback to.
+var ErrNoResponse = errors.New("no response to rate")
+
+// lookback bounds how far back Record searches for the rated response.
+const lookback = 50
+
+// Payload is the payload of a feedback event.
+type Payload struct {
+	Rating  string `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+	// EventID is the assistant_message being rated.
+	EventID types.EventID `json:"event_id"`
+	// Model is copied from the rated response so stats don't need to join.
+	Model string `json:"model,omitempty"`
+}
+
+// Record attaches a rating to the most recent assistant_message in the
+// session, storing it as a feedback event tied to that response's run.
+func Record(ctx context.Context, events types.EventStore, sessionID types.SessionID, source, rating, comment string) error {
+	if rating != Good && rating != Bad {
+		return fmt.Errorf("invalid rating %q", rating)
+	}
+
+	recent, err := events.Tail(ctx, sessionID, lookback)
+	if err != nil {
+		return fmt.Errorf("load events: %w", err)
+	}
+	var target *types.Event
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].Type == "assistant_message" {
+			target = recent[i]
+			break
+		}
+	}
+	if target == nil {
+		return ErrNoResponse
+	}
+
+	var annotations struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(target.Payload, &annotations)
+
+	payload, _ := json.Marshal(Payload{
+		Rating:  rating,
+		Comment: comment,
+		EventID: target.ID,
+		Model:   annotations.Model,
+	})
+	return events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: sessionID,
+		RunID:     target.RunID,
+		Type:      "feedback",
+		Source:    source,
+		At:        time.Now(),
+		Payload:   payload,
+	})
+}
+
+// Stat aggregates feedback for one model.
+type Stat struct {
+	Model string  `json:"model"`
+	Good  int     `json:"good"`
+	Bad   int     `json:"bad"`
+	Score float64 `json:"score"` // fraction of ratings that are good
+}
+
+// Collect scans every session's event log and aggregates feedback per
+// model, sorted by model name. Responses recorded before model annotations
+// existed are grouped under "unknown".
+func Collect(ctx context.Context, sessions types.SessionStore, events types.EventStore) ([]Stat, error) {
+	list, err := sessions.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	byModel := make(map[string]*Stat)
+	for _, sess := range list {
+		count, err := events.Count(ctx, sess.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("count events: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		all, err := events.Tail(ctx, sess.SessionID, int(count))
+		if err != nil {
+			return nil, fmt.Errorf("load events: %w", err)
+		}
+		for _, ev := range all {
+			if ev.Type != "feedback" {
+				continue
+			}
+			var p Payload
+			if err := json.Unmarshal(ev.Payload, &p); err != nil {
+				continue
+			}
+			model := p.Model
+			if model == "" {
+				model = "unknown"
+			}
+			st, ok := byModel[model]
+			if !ok {
+				st = &Stat{Model: model}
+				byModel[model] = st
+			}
+			switch p.Rating {
+			case Good:
+				st.Good++
+			case Bad:
+				st.Bad++
+			}
+		}
+	}
+
+	stats := make([]Stat, 0, len(byModel))
+	for _, st := range byModel {
+		if total := st.Good + st.Bad; total > 0 {
+			st.Score = float64(st.Good) / float64(total)
+		}
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Model < stats[j].Model })
+	return stats, nil
+}
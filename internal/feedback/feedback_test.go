@@ -0,0 +1,80 @@
+package feedback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func appendEvent(t *testing.T, events types.EventStore, sid types.SessionID, run types.RunID, typ string, payload map[string]string) {
+	t.Helper()
+	data, _ := json.Marshal(payload)
+	if err := events.Append(context.Background(), &types.Event{
+		ID: types.NewEventID(), SessionID: sid, RunID: run, Type: typ, At: time.Now(), Payload: data,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndCollect(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	ctx := context.Background()
+
+	sid, err := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Record(ctx, events, sid, "test", Good, ""); !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, got %v", err)
+	}
+
+	run1 := types.NewRunID()
+	appendEvent(t, events, sid, run1, "user_message", map[string]string{"text": "hi"})
+	appendEvent(t, events, sid, run1, "assistant_message", map[string]string{"text": "hello", "model": "model-a"})
+	if err := Record(ctx, events, sid, "test", Good, "nice"); err != nil {
+		t.Fatal(err)
+	}
+
+	run2 := types.NewRunID()
+	appendEvent(t, events, sid, run2, "assistant_message", map[string]string{"text": "meh", "model": "model-b"})
+	if err := Record(ctx, events, sid, "test", Bad, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(ctx, events, sid, "test", Good, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Record(ctx, events, sid, "test", "meh", ""); err == nil {
+		t.Error("expected error for invalid rating")
+	}
+
+	tail, err := events.Tail(ctx, sid, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tail[0].Type != "feedback" || tail[0].RunID != run2 {
+		t.Errorf("expected feedback tied to run %s, got %+v", run2, tail[0])
+	}
+
+	stats, err := Collect(ctx, sessions, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 models, got %+v", stats)
+	}
+	if stats[0].Model != "model-a" || stats[0].Good != 1 || stats[0].Bad != 0 || stats[0].Score != 1 {
+		t.Errorf("unexpected model-a stats: %+v", stats[0])
+	}
+	if stats[1].Model != "model-b" || stats[1].Good != 1 || stats[1].Bad != 1 || stats[1].Score != 0.5 {
+		t.Errorf("unexpected model-b stats: %+v", stats[1])
+	}
+}
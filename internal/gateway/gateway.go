@@ -2,12 +2,114 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 
+	"github.com/user/gopherclaw/internal/i18n"
 	"github.com/user/gopherclaw/internal/types"
 )
 
+// ErrSessionLocked is returned by HandleInbound when the target session has
+// been frozen with SetLocked.
+var ErrSessionLocked = errors.New("session is locked")
+
+// LockedError reports a refused inbound event for a locked session. It
+// matches ErrSessionLocked with errors.Is.
+type LockedError struct {
+	SessionID types.SessionID
+	Reason    string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("session %s is locked", e.SessionID)
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrSessionLocked }
+
+// Notice is the polite message adapters show the sender.
+func (e *LockedError) Notice() string {
+	msg := "This conversation is locked and isn't accepting new messages right now."
+	if e.Reason != "" {
+		msg += " Reason: " + e.Reason
+	}
+	return msg
+}
+
+// SetLocked freezes or unfreezes a session. While locked, HandleInbound
+// refuses new runs for it with ErrSessionLocked.
+func SetLocked(ctx context.Context, sessions types.SessionStore, id types.SessionID, locked bool, reason string) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	sess.Locked = locked
+	sess.LockReason = ""
+	if locked {
+		sess.LockReason = reason
+	}
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// SetLanguage records a session's preferred response language. An empty
+// code clears the preference.
+func SetLanguage(ctx context.Context, sessions types.SessionStore, id types.SessionID, code string) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	sess.Language = code
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// AddInstruction appends a standing system instruction to a session and
+// records it as a system_instruction event.
+func AddInstruction(ctx context.Context, sessions types.SessionStore, events types.EventStore, id types.SessionID, source, text string) error {
+	return updateInstructions(ctx, sessions, events, id, source, "add", text)
+}
+
+// ClearInstructions removes a session's standing instructions and records
+// the change as a system_instruction event.
+func ClearInstructions(ctx context.Context, sessions types.SessionStore, events types.EventStore, id types.SessionID, source string) error {
+	return updateInstructions(ctx, sessions, events, id, source, "clear", "")
+}
+
+func updateInstructions(ctx context.Context, sessions types.SessionStore, events types.EventStore, id types.SessionID, source, action, text string) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if action == "clear" {
+		sess.Instructions = nil
+	} else {
+		sess.Instructions = append(sess.Instructions, text)
+	}
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	payload, _ := json.Marshal(map[string]string{"action": action, "text": text})
+	if err := events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: id,
+		Type:      "system_instruction",
+		Source:    source,
+		At:        time.Now(),
+		Payload:   payload,
+	}); err != nil {
+		return fmt.Errorf("record instruction: %w", err)
+	}
+	return nil
+}
+
 // Gateway orchestrates inbound events into runs. It resolves (or creates)
 // sessions, wraps each event in a Run, and enqueues the run for processing.
 type Gateway struct {
@@ -17,6 +119,15 @@ type Gateway struct {
 	Queue     *Queue
 	retry     *RetryPolicy
 
+	// runs records run lifecycles; prepareResumed, when set, has Start
+	// resume the runs recorded as unfinished.
+	runs           RunJournal
+	prepareResumed func(*Run)
+
+	// idleTimeout rotates a session lazily when the next inbound event
+	// arrives after this much inactivity. Zero disables rotation.
+	idleTimeout time.Duration
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -38,10 +149,14 @@ func New(sessions types.SessionStore, events types.EventStore, artifacts types.A
 	}
 }
 
-// Start initialises the gateway's context and starts the internal queue.
+// Start initialises the gateway's context and starts the internal queue,
+// then resumes unfinished runs if SetResume was called.
 func (g *Gateway) Start(ctx context.Context) {
 	g.ctx, g.cancel = context.WithCancel(ctx)
 	g.Queue.Start(g.ctx)
+	if g.runs != nil && g.prepareResumed != nil {
+		g.resume()
+	}
 }
 
 // Stop cancels the gateway context, stops the queue, and waits for any
@@ -54,24 +169,177 @@ func (g *Gateway) Stop() {
 	g.wg.Wait()
 }
 
+// SetIdleTimeout enables idle-based session rotation: an inbound event for a
+// session that has been inactive longer than d starts a fresh session and
+// archives the old one. Must be called before Start.
+func (g *Gateway) SetIdleTimeout(d time.Duration) {
+	g.idleTimeout = d
+}
+
 // RunOption configures optional behavior on a Run.
 type RunOption func(*Run)
 
-// WithOnComplete sets a callback invoked when the run produces a final response.
+// WithOnResult sets a callback invoked with the run's result when it
+// finishes or fails.
+func WithOnResult(fn func(*RunResult)) RunOption {
+	return func(r *Run) { r.OnResult = fn }
+}
+
+// WithOnComplete sets a callback invoked with just the run's final
+// response; see WithOnResult for the rest of the outcome.
 func WithOnComplete(fn func(string)) RunOption {
 	return func(r *Run) { r.OnComplete = fn }
 }
 
+// WithOnNotice sets a callback for out-of-band notices about the run's
+// session (e.g. that it was rotated), delivered before the run is queued.
+func WithOnNotice(fn func(string)) RunOption {
+	return func(r *Run) { r.OnNotice = fn }
+}
+
+// WithOnPartial streams the run's LLM calls and passes the reply text
+// generated so far to fn as it arrives.
+func WithOnPartial(fn func(string)) RunOption {
+	return func(r *Run) { r.OnPartial = fn }
+}
+
+// WithOnProgress sets a callback invoked as the run starts each round and
+// each tool call.
+func WithOnProgress(fn func(Progress)) RunOption {
+	return func(r *Run) { r.OnProgress = fn }
+}
+
+// WithPriority sets the run's priority instead of the one its source
+// implies.
+func WithPriority(p Priority) RunOption {
+	return func(r *Run) { r.Priority = p }
+}
+
+// WithOnApproval sets the callback that asks the user to approve tool
+// calls that need it.
+func WithOnApproval(fn func(context.Context, ApprovalRequest) (bool, error)) RunOption {
+	return func(r *Run) { r.OnApproval = fn }
+}
+
+// WithFiles stores files as artifacts in the session the run uses, once
+// any idle rotation is done, and adds them to the event's attachments.
+func WithFiles(files ...File) RunOption {
+	return func(r *Run) { r.files = append(r.files, files...) }
+}
+
 // HandleInbound resolves or creates a session for the event, wraps it in a
-// Run, and enqueues it for processing.
+// Run, and enqueues it for processing. Returns an error wrapping
+// types.ErrInvalidSessionKey for a malformed key, or ErrSessionLocked if the
+// session is locked.
 func (g *Gateway) HandleInbound(ctx context.Context, event *types.InboundEvent, opts ...RunOption) error {
+	if _, err := event.SessionKey.Parse(); err != nil {
+		return err
+	}
 	sessionID, err := g.sessions.ResolveOrCreate(ctx, event.SessionKey, "default")
 	if err != nil {
 		return fmt.Errorf("resolve session: %w", err)
 	}
-	run := NewRun(sessionID, event)
+
+	sess, rotated, err := g.touchSession(ctx, event.SessionKey, sessionID)
+	if err != nil {
+		return err
+	}
+
+	run := NewRun(sess.SessionID, event)
+	run.Priority = sourcePriority(event.Source)
 	for _, opt := range opts {
 		opt(run)
 	}
+	if err := g.storeFiles(ctx, run); err != nil {
+		return err
+	}
+	if rotated && run.OnNotice != nil {
+		run.OnNotice(i18n.T(sess.Language, "new_idle", i18n.Duration(sess.Language, g.idleTimeout)))
+	}
 	return g.Queue.Enqueue(run)
 }
+
+// storeFiles stores the run's files in its session and attaches them to
+// its event.
+func (g *Gateway) storeFiles(ctx context.Context, run *Run) error {
+	if len(run.files) == 0 {
+		return nil
+	}
+	if g.artifacts == nil {
+		return errors.New("store attachment: no artifact store")
+	}
+	for _, f := range run.files {
+		id, err := g.artifacts.Put(ctx, run.SessionID, "", "attachment", f.Data)
+		if err != nil {
+			return fmt.Errorf("store attachment: %w", err)
+		}
+		run.Event.Attachments = append(run.Event.Attachments, types.Attachment{
+			ArtifactID:  id,
+			ContentType: f.ContentType,
+			Filename:    f.Filename,
+			Size:        int64(len(f.Data)),
+		})
+	}
+	run.files = nil
+	return nil
+}
+
+// touchSession rotates the session if it has been idle past the configured
+// timeout, then records the current activity time. Returns the session to
+// use and whether a rotation happened.
+func (g *Gateway) touchSession(ctx context.Context, key types.SessionKey, sessionID types.SessionID) (*types.SessionIndex, bool, error) {
+	sess, err := g.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, false, fmt.Errorf("load session: %w", err)
+	}
+	if sess.SessionKey != key {
+		// Rotated since the caller resolved it; use the key's current one.
+		if sessionID, err = g.sessions.ResolveOrCreate(ctx, key, "default"); err != nil {
+			return nil, false, fmt.Errorf("resolve session: %w", err)
+		}
+		if sess, err = g.sessions.Get(ctx, sessionID); err != nil {
+			return nil, false, fmt.Errorf("load session: %w", err)
+		}
+	}
+	if sess.Locked {
+		return nil, false, &LockedError{SessionID: sessionID, Reason: sess.LockReason}
+	}
+
+	if g.idleTimeout > 0 && time.Since(sess.UpdatedAt) > g.idleTimeout {
+		// Only rotate sessions that actually hold history.
+		count, err := g.events.Count(ctx, sessionID)
+		if err != nil {
+			return nil, false, fmt.Errorf("count events: %w", err)
+		}
+		if count > 0 {
+			// Only rotate the session seen idle: a message arriving at the
+			// same time may already have rotated it and be using the new one.
+			old, err := g.sessions.Rotate(ctx, key, sessionID)
+			if err != nil {
+				return nil, false, fmt.Errorf("rotate idle session: %w", err)
+			}
+			language, idle := sess.Language, time.Since(sess.UpdatedAt)
+			sessionID, err = g.sessions.ResolveOrCreate(ctx, key, "default")
+			if err != nil {
+				return nil, false, fmt.Errorf("resolve session: %w", err)
+			}
+			if sess, err = g.sessions.Get(ctx, sessionID); err != nil {
+				return nil, false, fmt.Errorf("load session: %w", err)
+			}
+			if old != "" {
+				slog.Info("rotated idle session", "session_key", string(key), "old_session_id", string(old), "idle", idle.Round(time.Second))
+				// The language preference belongs to the chat, not the conversation.
+				sess.Language = language
+				if err := g.sessions.Update(ctx, sess); err != nil {
+					return nil, false, fmt.Errorf("touch session: %w", err)
+				}
+				return sess, true, nil
+			}
+		}
+	}
+
+	if err := g.sessions.Touch(ctx, sessionID); err != nil {
+		return nil, false, fmt.Errorf("touch session: %w", err)
+	}
+	return sess, false, nil
+}
@@ -2,6 +2,8 @@ package gateway
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +12,30 @@ import (
 	"github.com/user/gopherclaw/internal/types"
 )
 
+func init() {
+	// Tests here use "test:<id>" keys.
+	types.RegisterKeyScheme(types.KeyScheme{Name: "test", Fields: []string{"id"}})
+}
+
+func TestGatewayRejectsMalformedKeys(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	gw := New(sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+	ctx := context.Background()
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	for _, key := range []types.SessionKey{"telegram:alice", "slack:general", ""} {
+		err := gw.HandleInbound(ctx, &types.InboundEvent{Source: "test", SessionKey: key, Text: "hi"})
+		if !errors.Is(err, types.ErrInvalidSessionKey) {
+			t.Errorf("%q: expected ErrInvalidSessionKey, got %v", key, err)
+		}
+	}
+	if list, _ := sessions.List(ctx); len(list) != 0 {
+		t.Errorf("malformed keys created sessions: %+v", list)
+	}
+}
+
 func TestGatewayHandleInbound(t *testing.T) {
 	dir := t.TempDir()
 	sessions := state.NewSessionStore(dir)
@@ -165,3 +191,285 @@ func TestHandleInboundWithOnComplete(t *testing.T) {
 		t.Errorf("expected 'hello from processor', got %q", callbackResult)
 	}
 }
+
+func TestHandleInboundRotatesIdleSession(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	gw := New(sessions, events, artifacts)
+	gw.SetIdleTimeout(20 * time.Millisecond)
+
+	ctx := context.Background()
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	key := types.NewSessionKey("test", "idle")
+	inbound := &types.InboundEvent{Source: "test", SessionKey: key, UserID: "u", Text: "hi"}
+	if err := gw.HandleInbound(ctx, inbound); err != nil {
+		t.Fatal(err)
+	}
+	firstID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Give the session some history so it is worth rotating.
+	if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: firstID, Type: "user_message", At: time.Now(), Payload: []byte(`{"text":"hi"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Activity within the timeout keeps the session.
+	var notices []string
+	onNotice := WithOnNotice(func(n string) { notices = append(notices, n) })
+	if err := gw.HandleInbound(ctx, inbound, onNotice); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 0 {
+		t.Fatalf("expected no rotation while active, got %v", notices)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if err := gw.HandleInbound(ctx, inbound, onNotice); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 {
+		t.Fatalf("expected a rotation notice, got %v", notices)
+	}
+
+	secondID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secondID == firstID {
+		t.Error("expected a new session after idle timeout")
+	}
+	old, err := sessions.Get(ctx, firstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Status != "archived" {
+		t.Errorf("expected old session archived, got %s", old.Status)
+	}
+}
+
+func TestIdleRotationHappensOnce(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	gw := New(sessions, events, state.NewArtifactStore(dir))
+	gw.SetIdleTimeout(20 * time.Millisecond)
+
+	ctx := context.Background()
+	key := types.NewSessionKey("test", "idle-race")
+	firstID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: firstID, Type: "user_message", At: time.Now(), Payload: []byte(`{"text":"hi"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// Two messages that both resolved the idle session before either
+	// rotated it.
+	first, rotated, err := gw.touchSession(ctx, key, firstID)
+	if err != nil || !rotated {
+		t.Fatalf("expected the first message to rotate, got %v, %v", rotated, err)
+	}
+	second, rotated, err := gw.touchSession(ctx, key, firstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated || second.SessionID != first.SessionID {
+		t.Fatalf("expected the second message to join %s, got %s (rotated %v)", first.SessionID, second.SessionID, rotated)
+	}
+	if sess, err := sessions.Get(ctx, first.SessionID); err != nil || sess.Status != types.SessionActive {
+		t.Errorf("expected the fresh session to stay active, got %+v, %v", sess, err)
+	}
+}
+
+func TestSetLanguageSurvivesIdleRotation(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	gw := New(sessions, events, artifacts)
+	gw.SetIdleTimeout(20 * time.Millisecond)
+
+	ctx := context.Background()
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	key := types.NewSessionKey("test", "lang")
+	firstID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLanguage(ctx, sessions, firstID, "es"); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: firstID, Type: "user_message", At: time.Now(), Payload: []byte(`{"text":"hola"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	inbound := &types.InboundEvent{Source: "test", SessionKey: key, UserID: "u", Text: "hola otra vez"}
+	if err := gw.HandleInbound(ctx, inbound); err != nil {
+		t.Fatal(err)
+	}
+	secondID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secondID == firstID {
+		t.Fatal("expected a new session after idle timeout")
+	}
+	sess, err := sessions.Get(ctx, secondID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Language != "es" {
+		t.Errorf("expected language carried over, got %q", sess.Language)
+	}
+}
+
+func TestFilesFollowIdleRotation(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	gw := New(sessions, events, artifacts)
+	gw.SetIdleTimeout(20 * time.Millisecond)
+
+	ctx := context.Background()
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	key := types.NewSessionKey("test", "files")
+	firstID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: firstID, Type: "user_message", At: time.Now(), Payload: []byte(`{"text":"hi"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	inbound := &types.InboundEvent{Source: "test", SessionKey: key, UserID: "u", Text: "see file"}
+	if err := gw.HandleInbound(ctx, inbound, WithFiles(File{Filename: "a.txt", ContentType: "text/plain", Data: []byte("hello")})); err != nil {
+		t.Fatal(err)
+	}
+	if len(inbound.Attachments) != 1 {
+		t.Fatalf("attachments = %+v", inbound.Attachments)
+	}
+	att := inbound.Attachments[0]
+	if att.Filename != "a.txt" || att.ContentType != "text/plain" || att.Size != 5 {
+		t.Errorf("attachment = %+v", att)
+	}
+	meta, err := artifacts.GetMeta(ctx, att.ArtifactID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.SessionID == firstID {
+		t.Error("expected the file stored in the new session, not the idle one")
+	}
+	if current, _ := sessions.ResolveOrCreate(ctx, key, "default"); meta.SessionID != current {
+		t.Errorf("file stored in %s, want %s", meta.SessionID, current)
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	ctx := context.Background()
+
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "sys"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddInstruction(ctx, sessions, events, sid, "test", "Answer in French."); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddInstruction(ctx, sessions, events, sid, "test", "Be brief."); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := sessions.Get(ctx, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.Instructions) != 2 || sess.Instructions[1] != "Be brief." {
+		t.Fatalf("unexpected instructions %v", sess.Instructions)
+	}
+
+	if err := ClearInstructions(ctx, sessions, events, sid, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if sess, _ := sessions.Get(ctx, sid); len(sess.Instructions) != 0 {
+		t.Errorf("expected instructions cleared, got %v", sess.Instructions)
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Type != "system_instruction" || !strings.Contains(string(all[2].Payload), `"clear"`) {
+		t.Errorf("expected three system_instruction events, got %+v", all)
+	}
+
+	if err := AddInstruction(ctx, sessions, events, "missing", "test", "x"); err == nil {
+		t.Error("expected an error for an unknown session")
+	}
+}
+
+func TestHandleInboundRefusesLockedSession(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	gw := New(sessions, events, artifacts)
+
+	processed := make(chan *Run, 2)
+	gw.Queue.SetProcessor(func(run *Run) error {
+		processed <- run
+		return nil
+	})
+
+	ctx := context.Background()
+	gw.Start(ctx)
+	defer gw.Stop()
+
+	key := types.NewSessionKey("test", "frozen")
+	sid, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLocked(ctx, sessions, sid, true, "maintenance"); err != nil {
+		t.Fatal(err)
+	}
+
+	inbound := &types.InboundEvent{Source: "test", SessionKey: key, UserID: "u", Text: "hi"}
+	err = gw.HandleInbound(ctx, inbound)
+	if !errors.Is(err, ErrSessionLocked) {
+		t.Fatalf("expected ErrSessionLocked, got %v", err)
+	}
+	var locked *LockedError
+	if !errors.As(err, &locked) || !strings.Contains(locked.Notice(), "maintenance") {
+		t.Errorf("expected notice with reason, got %v", err)
+	}
+
+	if err := SetLocked(ctx, sessions, sid, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.HandleInbound(ctx, inbound); err != nil {
+		t.Fatalf("expected unlocked session to accept runs, got %v", err)
+	}
+	select {
+	case <-processed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for run")
+	}
+	if n := len(processed); n != 0 {
+		t.Errorf("expected exactly 1 processed run, got %d extra", n)
+	}
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority orders runs waiting for one of the queue's concurrency slots.
+// The zero value is PriorityNormal.
+type Priority int
+
+const (
+	// PriorityLow is for background work nobody is waiting on:
+	// scheduled and webhook tasks, heartbeats.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default.
+	PriorityNormal Priority = 0
+	// PriorityHigh is for a person waiting on a reply in a chat.
+	PriorityHigh Priority = 1
+)
+
+// sourcePriority is the priority of runs from an inbound source, unless a
+// caller sets one with WithPriority.
+func sourcePriority(source string) Priority {
+	switch source {
+	case "telegram", "signal", "cli":
+		return PriorityHigh
+	case "task", "heartbeat":
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// slots is a counting semaphore that, when contended, hands a freed slot
+// to the waiter with the highest priority, and among equals to the one
+// that has waited longest.
+type slots struct {
+	mu      sync.Mutex
+	free    int64
+	waiters []*slotWaiter
+}
+
+type slotWaiter struct {
+	priority Priority
+	ready    chan struct{}
+}
+
+func newSlots(n int64) *slots {
+	return &slots{free: n}
+}
+
+// acquire takes a slot, waiting behind higher-priority and earlier
+// waiters, or returns ctx's error if ctx ends first.
+func (s *slots) acquire(ctx context.Context, p Priority) error {
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiters) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	w := &slotWaiter{priority: p, ready: make(chan struct{})}
+	// Insert after every waiter of the same or higher priority.
+	i := len(s.waiters)
+	for i > 0 && s.waiters[i-1].priority < p {
+		i--
+	}
+	s.waiters = append(s.waiters, nil)
+	copy(s.waiters[i+1:], s.waiters[i:])
+	s.waiters[i] = w
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Granted while giving up: pass the slot on.
+			s.releaseLocked()
+		default:
+			for i, other := range s.waiters {
+				if other == w {
+					s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+					break
+				}
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// release returns a slot, handing it straight to the first waiter.
+func (s *slots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *slots) releaseLocked() {
+	if len(s.waiters) == 0 {
+		s.free++
+		return
+	}
+	w := s.waiters[0]
+	s.waiters = s.waiters[1:]
+	close(w.ready)
+}
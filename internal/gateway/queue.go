@@ -2,26 +2,40 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"golang.org/x/sync/semaphore"
-
+	"github.com/user/gopherclaw/internal/logging"
 	"github.com/user/gopherclaw/internal/types"
 )
 
 // Queue manages per-session lanes with a global concurrency semaphore.
 // Each session gets its own FIFO channel (lane) so that runs within a
 // session are processed sequentially, while the semaphore limits the
-// total number of concurrent run processors across all sessions.
+// total number of concurrent run processors across all sessions. When it
+// is contended, runs with a higher Priority get the next free slot, so
+// chat messages don't wait behind background tasks.
+//
+// A session key may be given a concurrency override with SetConcurrency,
+// in which case its lane is drained by that many workers and runs start in
+// order but may complete out of order. Chats keep the default of 1.
 type Queue struct {
-	lanes     map[types.SessionID]chan *Run
-	semaphore *semaphore.Weighted
-	processor func(*Run) error
-	active    atomic.Int64
+	lanes   map[types.SessionID]chan *Run
+	workers map[types.SessionID]int
+	// keys is the session key each lane serves, for its concurrency limit.
+	keys map[types.SessionID]types.SessionKey
+	// wake is closed, and replaced, to have a lane's idle workers check
+	// their budget after its limit was lowered.
+	wake        map[types.SessionID]chan struct{}
+	concurrency map[types.SessionKey]int
+	semaphore   *slots
+	processor   func(*Run) error
+	active      atomic.Int64
+	runs        RunRecorder
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -33,8 +47,92 @@ type Queue struct {
 // simultaneously across all session lanes.
 func NewQueue(maxConcurrent int64) *Queue {
 	return &Queue{
-		lanes:     make(map[types.SessionID]chan *Run),
-		semaphore: semaphore.NewWeighted(maxConcurrent),
+		lanes:       make(map[types.SessionID]chan *Run),
+		workers:     make(map[types.SessionID]int),
+		keys:        make(map[types.SessionID]types.SessionKey),
+		wake:        make(map[types.SessionID]chan struct{}),
+		concurrency: make(map[types.SessionKey]int),
+		semaphore:   newSlots(maxConcurrent),
+	}
+}
+
+// SetConcurrency sets how many runs for the given session key may execute at
+// once. Values below 1 restore the default of 1. Raising the limit takes
+// effect on the next Enqueue. Lowering it retires the lane's extra workers
+// as they finish their current run; none is interrupted.
+func (q *Queue) SetConcurrency(key types.SessionKey, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n <= 1 {
+		delete(q.concurrency, key)
+	} else {
+		q.concurrency[key] = n
+	}
+	for sessionID, laneKey := range q.keys {
+		if laneKey == key && q.workers[sessionID] > q.limit(sessionID) {
+			close(q.wake[sessionID])
+			q.wake[sessionID] = make(chan struct{})
+		}
+	}
+}
+
+// limit returns how many workers the session's lane may have. Callers hold
+// q.mu.
+func (q *Queue) limit(sessionID types.SessionID) int {
+	if n, ok := q.concurrency[q.keys[sessionID]]; ok {
+		return n
+	}
+	return 1
+}
+
+// Concurrency returns the concurrency limit for the given session key.
+func (q *Queue) Concurrency(key types.SessionKey) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if n, ok := q.concurrency[key]; ok {
+		return n
+	}
+	return 1
+}
+
+// SetRunStore makes the queue record each run's lifecycle: when it is
+// queued, when it starts and how it finished. Must be called before Start.
+func (q *Queue) SetRunStore(runs RunRecorder) {
+	q.runs = runs
+}
+
+// record persists the run's current state. Failures are logged; they never
+// hold up the run.
+func (q *Queue) record(run *Run, result *RunResult) {
+	if q.runs == nil {
+		return
+	}
+	if err := q.runs.Put(run.Record(result)); err != nil {
+		slog.Warn("record run failed", "run_id", string(run.ID), "status", string(run.Status), "error", err)
+	}
+}
+
+// track marks the run as started and wraps its OnResult so the outcome is
+// recorded before callers see it.
+func (q *Queue) track(run *Run) {
+	now := time.Now()
+	run.Status = RunStatusRunning
+	run.StartedAt = &now
+	q.record(run, nil)
+
+	onResult := run.OnResult
+	run.OnResult = func(result *RunResult) {
+		ended := time.Now()
+		run.EndedAt = &ended
+		run.Status = RunStatusComplete
+		if result.Err != nil {
+			run.Status = RunStatusFailed
+			run.Error = result.Err
+		}
+		q.record(run, result)
+		if onResult != nil {
+			onResult(result)
+		}
 	}
 }
 
@@ -58,7 +156,7 @@ func (q *Queue) Stop() {
 }
 
 // Enqueue adds a Run to the session's lane, creating the lane (and its
-// goroutine) on first use. Returns an error if the lane's buffer is full.
+// goroutines) on first use. Returns an error if the lane's buffer is full.
 func (q *Queue) Enqueue(run *Run) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -67,51 +165,91 @@ func (q *Queue) Enqueue(run *Run) error {
 	if !exists {
 		lane = make(chan *Run, 100)
 		q.lanes[run.SessionID] = lane
+		q.wake[run.SessionID] = make(chan struct{})
+	}
+	if run.Event != nil {
+		q.keys[run.SessionID] = run.Event.SessionKey
+	}
+
+	for q.workers[run.SessionID] < q.limit(run.SessionID) {
+		q.workers[run.SessionID]++
 		q.wg.Add(1)
 		go q.processLane(run.SessionID, lane)
 	}
 
+	// Recorded before the run can start, so the queued record never
+	// follows the running one.
+	q.record(run, nil)
 	select {
 	case lane <- run:
 		return nil
 	default:
-		return fmt.Errorf("queue full for session %s", run.SessionID)
+		err := fmt.Errorf("queue full for session %s", run.SessionID)
+		now := time.Now()
+		run.Status, run.Error, run.EndedAt = RunStatusFailed, err, &now
+		q.record(run, nil)
+		return err
 	}
 }
 
 // processLane drains a single session lane, acquiring a semaphore slot
 // before running the processor synchronously. This ensures strict FIFO
 // ordering within a session while the semaphore limits cross-session
-// parallelism.
+// parallelism. Before taking each run, a worker beyond the lane's limit
+// retires.
 func (q *Queue) processLane(sessionID types.SessionID, lane chan *Run) {
 	defer q.wg.Done()
 	for {
+		q.mu.Lock()
+		if q.workers[sessionID] > q.limit(sessionID) {
+			q.workers[sessionID]--
+			q.mu.Unlock()
+			return
+		}
+		wake := q.wake[sessionID]
+		q.mu.Unlock()
+
 		select {
+		case <-wake:
 		case run, ok := <-lane:
 			if !ok {
 				return
 			}
-			if err := q.semaphore.Acquire(q.ctx, 1); err != nil {
+			if err := q.semaphore.acquire(q.ctx, run.Priority); err != nil {
 				return
 			}
 			if q.processor != nil {
 				q.active.Add(1)
-				run.Ctx = q.ctx
+				run.Ctx = logging.WithRun(q.ctx, run.ID, run.SessionID)
+				q.track(run)
 				if err := q.processor(run); err != nil {
-					slog.Error("run failed", "run_id", string(run.ID), "session_id", string(run.SessionID), "error", err)
-					if run.OnComplete != nil {
-						run.OnComplete("Sorry, something went wrong processing your message.")
-					}
+					slog.ErrorContext(run.Ctx, "run failed", "error", err)
+					run.Finish(&RunResult{
+						RunID:     run.ID,
+						SessionID: run.SessionID,
+						Text:      failureText(err),
+						Err:       err,
+					})
 				}
 				q.active.Add(-1)
 			}
-			q.semaphore.Release(1)
+			q.semaphore.release()
 		case <-q.ctx.Done():
 			return
 		}
 	}
 }
 
+// failureText is the reply to a failed run: the notice of an error that
+// has one for the user, such as a spent budget, or a generic apology.
+func failureText(err error) string {
+	var n interface{ Notice() string }
+	if errors.As(err, &n) {
+		return n.Notice()
+	}
+	return "Sorry, something went wrong processing your message."
+}
+
 // WaitIdle blocks until no runs are actively being processed, or the timeout
 // expires. Returns true if idle, false if timed out.
 func (q *Queue) WaitIdle(timeout time.Duration) bool {
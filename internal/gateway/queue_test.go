@@ -147,3 +147,284 @@ func TestQueueNoProcessor(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestQueueSessionConcurrencyOverride(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+	queue.Start(ctx)
+	defer queue.Stop()
+
+	key := types.NewSessionKey("webhook", "batch")
+	queue.SetConcurrency(key, 3)
+	if got := queue.Concurrency(key); got != 3 {
+		t.Fatalf("expected concurrency 3, got %d", got)
+	}
+
+	var running, maxSeen int32
+	var wg sync.WaitGroup
+	queue.processor = func(run *Run) error {
+		defer wg.Done()
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxSeen)
+			if current <= old || atomic.CompareAndSwapInt32(&maxSeen, old, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		run := &Run{
+			ID:        types.NewRunID(),
+			SessionID: "batch-session",
+			Event:     &types.InboundEvent{SessionKey: key},
+			Status:    RunStatusQueued,
+		}
+		if err := queue.Enqueue(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if m := atomic.LoadInt32(&maxSeen); m != 3 {
+		t.Errorf("expected 3 concurrent runs in the session, saw %d", m)
+	}
+
+	queue.SetConcurrency(key, 0)
+	if got := queue.Concurrency(key); got != 1 {
+		t.Errorf("expected default concurrency 1 after reset, got %d", got)
+	}
+}
+
+func TestQueueSessionConcurrencyDecrease(t *testing.T) {
+	queue := NewQueue(10)
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	key := types.NewSessionKey("webhook", "batch")
+	queue.SetConcurrency(key, 3)
+
+	var running, maxSeen int32
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	queue.processor = func(run *Run) error {
+		defer wg.Done()
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxSeen)
+			if current <= old || atomic.CompareAndSwapInt32(&maxSeen, old, current) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	enqueue := func(n int) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			run := &Run{ID: types.NewRunID(), SessionID: "batch-session", Event: &types.InboundEvent{SessionKey: key}, Status: RunStatusQueued}
+			if err := queue.Enqueue(run); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	enqueue(3)
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	queue.SetConcurrency(key, 1)
+	atomic.StoreInt32(&maxSeen, 0)
+	enqueue(3)
+	close(release)
+	wg.Wait()
+
+	if m := atomic.LoadInt32(&maxSeen); m != 1 {
+		t.Errorf("expected 1 concurrent run after lowering the limit, saw %d", m)
+	}
+	queue.mu.RLock()
+	workers := queue.workers["batch-session"]
+	queue.mu.RUnlock()
+	if workers != 1 {
+		t.Errorf("expected 1 worker left, got %d", workers)
+	}
+}
+
+// recordLog collects run records in the order they were written.
+type recordLog struct {
+	mu      sync.Mutex
+	records []*types.RunRecord
+}
+
+func (l *recordLog) Put(rec *types.RunRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, rec)
+	return nil
+}
+
+func (l *recordLog) statuses(id types.RunID) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, rec := range l.records {
+		if rec.ID == id {
+			out = append(out, rec.Status)
+		}
+	}
+	return out
+}
+
+func TestQueueRecordsRuns(t *testing.T) {
+	queue := NewQueue(1)
+	log := &recordLog{}
+	queue.SetRunStore(log)
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	queue.SetProcessor(func(run *Run) error {
+		if run.Event.Text == "fail" {
+			return fmt.Errorf("boom")
+		}
+		run.Finish(&RunResult{RunID: run.ID, ToolRounds: 2, ToolCalls: []ToolCall{{Tool: "bash"}}})
+		return nil
+	})
+
+	done := make(chan *RunResult, 2)
+	ok := NewRun("s1", &types.InboundEvent{SessionKey: "test:1", Source: "test", Text: "hi"})
+	failed := NewRun("s1", &types.InboundEvent{SessionKey: "test:1", Source: "test", Text: "fail"})
+	for _, run := range []*Run{ok, failed} {
+		run.OnResult = func(res *RunResult) { done <- res }
+		if err := queue.Enqueue(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	<-done
+
+	if got := fmt.Sprint(log.statuses(ok.ID)); got != "[queued running complete]" {
+		t.Errorf("successful run statuses = %s", got)
+	}
+	if got := fmt.Sprint(log.statuses(failed.ID)); got != "[queued running failed]" {
+		t.Errorf("failed run statuses = %s", got)
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	for _, rec := range log.records {
+		switch {
+		case rec.ID == ok.ID && rec.Status == "complete":
+			if rec.ToolRounds != 2 || rec.ToolCalls != 1 || rec.SessionKey != "test:1" || rec.Source != "test" || rec.StartedAt == nil || rec.EndedAt == nil {
+				t.Errorf("complete record = %+v", rec)
+			}
+		case rec.ID == failed.ID && rec.Status == "failed":
+			if rec.Error != "boom" {
+				t.Errorf("failed record error = %q", rec.Error)
+			}
+		}
+	}
+}
+
+func TestQueueFailureNotice(t *testing.T) {
+	queue := NewQueue(1)
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	queue.SetProcessor(func(run *Run) error {
+		if run.Event.Text == "locked" {
+			return fmt.Errorf("wrapped: %w", &LockedError{SessionID: run.SessionID})
+		}
+		return fmt.Errorf("boom")
+	})
+
+	for text, want := range map[string]string{
+		"locked": (&LockedError{}).Notice(),
+		"other":  "Sorry, something went wrong processing your message.",
+	} {
+		done := make(chan *RunResult, 1)
+		run := NewRun("s1", &types.InboundEvent{SessionKey: "test:1", Source: "test", Text: text})
+		run.OnResult = func(res *RunResult) { done <- res }
+		if err := queue.Enqueue(run); err != nil {
+			t.Fatal(err)
+		}
+		if res := <-done; res.Text != want {
+			t.Errorf("%s: reply = %q, want %q", text, res.Text, want)
+		}
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	queue := NewQueue(1)
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	queue.SetProcessor(func(run *Run) error {
+		mu.Lock()
+		order = append(order, string(run.SessionID))
+		mu.Unlock()
+		if run.SessionID == "busy" {
+			<-release
+		}
+		return nil
+	})
+
+	enqueue := func(session string, p Priority) {
+		t.Helper()
+		run := &Run{ID: types.NewRunID(), SessionID: types.SessionID(session), Status: RunStatusQueued, Priority: p}
+		if err := queue.Enqueue(run); err != nil {
+			t.Fatal(err)
+		}
+		// Let the lane start waiting for the slot before the next one.
+		time.Sleep(20 * time.Millisecond)
+	}
+	enqueue("busy", PriorityNormal)
+	enqueue("cron-1", PriorityLow)
+	enqueue("cron-2", PriorityLow)
+	enqueue("webhook", PriorityNormal)
+	enqueue("chat", PriorityHigh)
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(order)
+		mu.Unlock()
+		if n == 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := fmt.Sprint(order); got != "[busy chat webhook cron-1 cron-2]" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestSlotsCancel(t *testing.T) {
+	s := newSlots(1)
+	if err := s.acquire(context.Background(), PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, PriorityHigh); err == nil {
+		t.Fatal("expected the wait to end with the context")
+	}
+	s.release()
+	// The abandoned waiter must not hold on to the freed slot.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.acquire(ctx, PriorityLow); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// Limits on resuming runs after a restart. A run that has already been
+// started this many times probably crashes the process, and a reply to a
+// message older than the age would no longer be expected.
+const (
+	maxResumeAttempts = 3
+	maxResumeAge      = time.Hour
+)
+
+// RunJournal is a RunRecorder that can also list the runs a previous
+// process left unfinished; state.RunStore implements it.
+type RunJournal interface {
+	RunRecorder
+	Unfinished() ([]*types.RunRecord, error)
+}
+
+// SetRunStore records every run's lifecycle in runs. Must be called before
+// Start.
+func (g *Gateway) SetRunStore(runs RunJournal) {
+	g.runs = runs
+	g.Queue.SetRunStore(runs)
+}
+
+// SetResume makes Start queue again the runs a previous process left
+// queued or running, keeping their IDs. prepare sets the callbacks of each
+// such run, for example to deliver its reply, before it is queued. Only one
+// process may resume a data directory's runs. Requires SetRunStore.
+func (g *Gateway) SetResume(prepare func(*Run)) {
+	g.prepareResumed = prepare
+}
+
+// resume queues the runs a previous process left unfinished, oldest first
+// so each session keeps its order. Runs it won't retry are marked
+// interrupted.
+func (g *Gateway) resume() {
+	records, err := g.runs.Unfinished()
+	if err != nil {
+		slog.Warn("list unfinished runs failed", "error", err)
+		return
+	}
+	resumed := 0
+	for _, rec := range records {
+		attempts := rec.Attempts
+		if rec.StartedAt != nil {
+			attempts++
+		}
+		reason := ""
+		switch {
+		case rec.Event == nil:
+			reason = "no inbound event recorded"
+		case attempts >= maxResumeAttempts:
+			reason = "started too many times"
+		case time.Since(rec.CreatedAt) > maxResumeAge:
+			reason = "too old"
+		}
+		if reason == "" {
+			run := &Run{
+				ID:        rec.ID,
+				SessionID: rec.SessionID,
+				Event:     rec.Event,
+				Status:    RunStatusQueued,
+				Priority:  sourcePriority(rec.Event.Source),
+				Attempts:  attempts,
+				CreatedAt: rec.CreatedAt,
+				Resumed:   true,
+			}
+			if g.prepareResumed != nil {
+				g.prepareResumed(run)
+			}
+			if err := g.Queue.Enqueue(run); err == nil {
+				resumed++
+				continue
+			}
+			reason = "queue full"
+		}
+		slog.Warn("not resuming unfinished run", "run_id", string(rec.ID), "session_id", string(rec.SessionID), "reason", reason)
+		now := time.Now()
+		rec.Status, rec.EndedAt, rec.Event = types.RunInterrupted, &now, nil
+		if err := g.runs.Put(rec); err != nil {
+			slog.Warn("record run failed", "run_id", string(rec.ID), "error", err)
+		}
+	}
+	if resumed > 0 {
+		slog.Info("resumed unfinished runs", "count", resumed)
+	}
+}
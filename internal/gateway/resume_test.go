@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestGatewayResume(t *testing.T) {
+	dir := t.TempDir()
+	runs := state.NewRunStore(filepath.Join(dir, "runs.jsonl"))
+	now := time.Now()
+	event := &types.InboundEvent{Source: "test", SessionKey: "test:1", Text: "hi"}
+	started := now.Add(-time.Minute)
+	for _, rec := range []*types.RunRecord{
+		{ID: "queued", SessionID: "s1", Status: "queued", CreatedAt: now.Add(-2 * time.Minute), Event: event},
+		{ID: "running", SessionID: "s1", Status: "running", CreatedAt: now.Add(-time.Minute), StartedAt: &started, Event: event},
+		{ID: "stale", SessionID: "s2", Status: "queued", CreatedAt: now.Add(-2 * time.Hour), Event: event},
+		{ID: "crashy", SessionID: "s3", Status: "running", CreatedAt: now, StartedAt: &started, Attempts: 2, Event: event},
+	} {
+		if err := runs.Put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gw := New(state.NewSessionStore(dir), state.NewEventStore(dir), state.NewArtifactStore(dir))
+	gw.SetRunStore(runs)
+	var (
+		mu    sync.Mutex
+		order []types.RunID
+		wg    sync.WaitGroup
+	)
+	wg.Add(2)
+	gw.Queue.SetProcessor(func(run *Run) error {
+		if !run.Resumed {
+			t.Errorf("run %s not marked resumed", run.ID)
+		}
+		mu.Lock()
+		order = append(order, run.ID)
+		mu.Unlock()
+		run.Finish(&RunResult{RunID: run.ID, Text: "done"})
+		return nil
+	})
+	var prepared []types.RunID
+	gw.SetResume(func(run *Run) {
+		prepared = append(prepared, run.ID)
+		run.OnComplete = func(string) { wg.Done() }
+	})
+	gw.Start(context.Background())
+	defer gw.Stop()
+	wg.Wait()
+
+	if len(prepared) != 2 || order[0] != "queued" || order[1] != "running" {
+		t.Errorf("prepared %v, processed %v; want the queued run before the running one", prepared, order)
+	}
+	for id, want := range map[types.RunID]string{"queued": "complete", "running": "complete", "stale": types.RunInterrupted, "crashy": types.RunInterrupted} {
+		rec, err := runs.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Status != want {
+			t.Errorf("%s: status %s, want %s", id, rec.Status, want)
+		}
+		if rec.Event != nil {
+			t.Errorf("%s: finished record still carries its event", id)
+		}
+	}
+	if rec, _ := runs.Get("running"); rec.Attempts != 1 {
+		t.Errorf("running run attempts = %d, want 1", rec.Attempts)
+	}
+}
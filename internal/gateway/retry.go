@@ -1,9 +1,11 @@
 package gateway
 
 import (
+	"errors"
 	"math"
-	"strings"
 	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 // RetryPolicy controls how failed runs are retried with exponential backoff.
@@ -34,31 +36,23 @@ func (p *RetryPolicy) ShouldRetry(err error, attempt int) bool {
 	return p.isRetryable(err)
 }
 
-// isRetryable classifies errors as retryable or permanent based on their message.
-// Transient errors (connection, timeout) are retryable; auth/validation errors are not.
-// Unknown errors default to retryable.
+// isRetryable classifies errors as retryable or permanent by the llm error
+// class they wrap. Transient errors and rate limits are retryable; auth and
+// invalid-request errors are not. A rate limit is not retryable either if
+// the account is out of quota or the provider asks to wait longer than
+// MaxDelay. Errors of no known class default to retryable.
 func (p *RetryPolicy) isRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
-	msg := strings.ToLower(err.Error())
-
-	// Transient / retryable errors
-	if strings.Contains(msg, "connection refused") ||
-		strings.Contains(msg, "connection reset") ||
-		strings.Contains(msg, "timeout") ||
-		strings.Contains(msg, "temporary failure") {
-		return true
+	var rl *llm.RateLimitError
+	if errors.As(err, &rl) {
+		return rl.Temporary() && rl.RetryAfter <= p.MaxDelay
 	}
-
-	// Permanent / non-retryable errors
-	if strings.Contains(msg, "invalid") ||
-		strings.Contains(msg, "unauthorized") ||
-		strings.Contains(msg, "forbidden") {
+	switch llm.Class(err) {
+	case llm.ErrAuth, llm.ErrInvalidRequest:
 		return false
 	}
-
-	// Default: retryable
 	return true
 }
 
@@ -72,8 +66,19 @@ func (p *RetryPolicy) NextDelay(attempt int) time.Duration {
 	return time.Duration(delay)
 }
 
+// delay returns how long to wait after err before the next attempt: the
+// backoff delay, or longer if err is a rate limit that asks for it.
+func (p *RetryPolicy) delay(err error, attempt int) time.Duration {
+	d := p.NextDelay(attempt)
+	var rl *llm.RateLimitError
+	if errors.As(err, &rl) && rl.RetryAfter > d {
+		return rl.RetryAfter
+	}
+	return d
+}
+
 // Execute runs fn up to MaxAttempts times, sleeping between retries with
-// exponential backoff. Returns nil on success or the last error if all
+// exponential backoff, or as long as a rate limit error asks. Returns nil on success or the last error if all
 // attempts fail or the error is non-retryable.
 func (p *RetryPolicy) Execute(fn func() error) error {
 	var lastErr error
@@ -87,7 +92,7 @@ func (p *RetryPolicy) Execute(fn func() error) error {
 			return err
 		}
 		if attempt < p.MaxAttempts {
-			time.Sleep(p.NextDelay(attempt))
+			time.Sleep(p.delay(err, attempt))
 		}
 	}
 	return lastErr
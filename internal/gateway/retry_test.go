@@ -2,16 +2,23 @@ package gateway
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"testing"
 	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 func TestRetryPolicy(t *testing.T) {
 	policy := DefaultRetryPolicy()
 
-	if !policy.ShouldRetry(errors.New("connection refused"), 1) {
+	if !policy.ShouldRetry(fmt.Errorf("sending request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), 1) {
 		t.Error("expected connection error to be retryable")
 	}
+	if !policy.ShouldRetry(&llm.APIError{Status: 503, Message: "overloaded"}, 1) {
+		t.Error("expected server error to be retryable")
+	}
 
 	if policy.ShouldRetry(errors.New("error"), 4) {
 		t.Error("should not retry after max attempts")
@@ -36,14 +43,40 @@ func TestRetryPolicy(t *testing.T) {
 func TestRetryPolicyNonRetryable(t *testing.T) {
 	policy := DefaultRetryPolicy()
 
-	if policy.ShouldRetry(errors.New("invalid request"), 1) {
-		t.Error("expected 'invalid' error to be non-retryable")
+	if policy.ShouldRetry(&llm.APIError{Status: 400, Message: "bad request"}, 1) {
+		t.Error("expected invalid request error to be non-retryable")
 	}
-	if policy.ShouldRetry(errors.New("unauthorized"), 1) {
+	if policy.ShouldRetry(&llm.APIError{Status: 401, Message: "invalid key"}, 1) {
 		t.Error("expected 'unauthorized' error to be non-retryable")
 	}
-	if policy.ShouldRetry(errors.New("forbidden"), 1) {
-		t.Error("expected 'forbidden' error to be non-retryable")
+	if policy.ShouldRetry(fmt.Errorf("%w: query is required", llm.ErrInvalidRequest), 1) {
+		t.Error("expected a wrapped ErrInvalidRequest to be non-retryable")
+	}
+	// Classification goes by error class, not by message.
+	if !policy.ShouldRetry(errors.New("model replied: that is an invalid, forbidden idea"), 1) {
+		t.Error("expected an unclassified error to be retryable whatever its message")
+	}
+}
+
+func TestRetryPolicyRateLimit(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	limited := fmt.Errorf("llm: %w", &llm.RateLimitError{Body: `{"error":{"message":"invalid: too many requests"}}`, RetryAfter: 5 * time.Second})
+	if !policy.ShouldRetry(limited, 1) {
+		t.Error("expected a rate limit to be retryable whatever its message")
+	}
+	if d := policy.delay(limited, 1); d != 5*time.Second {
+		t.Errorf("expected the delay to honor Retry-After, got %v", d)
+	}
+	if d := policy.delay(limited, 4); d != 8*time.Second {
+		t.Errorf("expected a longer backoff to win over Retry-After, got %v", d)
+	}
+
+	if policy.ShouldRetry(&llm.RateLimitError{Quota: true}, 1) {
+		t.Error("expected an exhausted quota to be non-retryable")
+	}
+	if policy.ShouldRetry(&llm.RateLimitError{RetryAfter: time.Hour}, 1) {
+		t.Error("expected a wait beyond MaxDelay to be non-retryable")
 	}
 }
 
@@ -94,7 +127,7 @@ func TestRetryPolicyExecuteNonRetryable(t *testing.T) {
 
 	err := policy.Execute(func() error {
 		calls++
-		return errors.New("invalid request")
+		return &llm.APIError{Status: 422, Message: "invalid request"}
 	})
 
 	if err == nil {
@@ -2,9 +2,12 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 // RunStatus represents the lifecycle state of a Run.
@@ -19,17 +22,156 @@ const (
 
 // Run tracks a single execution of an inbound event against a session.
 type Run struct {
-	ID         types.RunID
-	SessionID  types.SessionID
-	Event      *types.InboundEvent
-	Status     RunStatus
-	Attempts   int
-	CreatedAt  time.Time
-	StartedAt  *time.Time
-	EndedAt    *time.Time
-	Error      error
+	ID        types.RunID
+	SessionID types.SessionID
+	Event     *types.InboundEvent
+	Status    RunStatus
+	Priority  Priority
+	Attempts  int
+	CreatedAt time.Time
+	StartedAt *time.Time
+	EndedAt   *time.Time
+	Error     error
+	// OnResult receives the outcome of the run once it finishes or fails.
+	OnResult func(result *RunResult)
+	// OnComplete receives only the final response text, for callers that
+	// need nothing else. An empty response means the run deliberately
+	// produced no reply and nothing should be delivered.
 	OnComplete func(response string)
+	OnNotice   func(notice string)
+	// OnPartial, when set, makes the runtime stream LLM calls and receives
+	// the reply text generated so far by the current call. Text from an
+	// earlier call that ended in tool calls is not repeated.
+	OnPartial func(text string)
+	// OnProgress receives what the run is doing as each round starts and
+	// before each tool call, so adapters can show that it is working.
+	OnProgress func(p Progress)
+	// OnApproval asks the user whether a tool call may run and blocks
+	// until they answer or ctx is done. Runs without it can't run tools
+	// that need approval.
+	OnApproval func(ctx context.Context, req ApprovalRequest) (bool, error)
 	Ctx        context.Context
+	// Resumed is set on a run a previous process left unfinished and
+	// Gateway.Start queued again. Its user message may already be
+	// recorded.
+	Resumed bool
+	// files are stored and attached to Event by HandleInbound; see
+	// WithFiles.
+	files []File
+}
+
+// File is a file received with an inbound message, not yet stored.
+type File struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ErrApprovalUnavailable is wrapped by an OnApproval error when the call
+// can't be put to the user, such as one too long to show in full. The
+// call is denied, with the error as the reason.
+var ErrApprovalUnavailable = errors.New("approval can't be asked for")
+
+// ApprovalRequest asks the user to approve a tool call before it runs.
+type ApprovalRequest struct {
+	// ID identifies the request; it is the ID of its approval_request
+	// event.
+	ID        types.EventID
+	Tool      string
+	CallID    string
+	Arguments json.RawMessage
+}
+
+// Progress describes what a run is doing.
+type Progress struct {
+	// Round is the 1-based round of the agentic loop.
+	Round int
+	// Tool is the tool about to be called, or "" while waiting on the
+	// model.
+	Tool string
+	// Status describes the step in words, such as "thinking" or "running
+	// commands".
+	Status string
+}
+
+// RunResult is the outcome of a run.
+type RunResult struct {
+	RunID     types.RunID
+	SessionID types.SessionID
+	// Text is the final response. Empty means the run deliberately
+	// produced no reply.
+	Text string
+	// Artifacts are the artifacts the run stored, such as tool outputs
+	// too large for the event log.
+	Artifacts []types.ArtifactID
+	// ToolCalls are the tool calls the run handled, in order.
+	ToolCalls []ToolCall
+	// Usage sums the token usage of the run's LLM calls.
+	Usage llm.Usage
+	// ToolRounds counts the rounds in which the model called tools.
+	ToolRounds int
+	// Err is why the run failed. Text then holds the apology sent instead.
+	Err error
+}
+
+// ToolCall is a tool call handled by a run.
+type ToolCall struct {
+	Tool   string `json:"tool"`
+	CallID string `json:"call_id"`
+	// ArtifactID is set when the output was stored as an artifact.
+	ArtifactID types.ArtifactID `json:"artifact_id,omitempty"`
+	// Error is set when the tool failed or was refused.
+	Error bool `json:"error,omitempty"`
+	// Planned is set when the call awaits the user's confirmation instead
+	// of having run.
+	Planned bool `json:"planned,omitempty"`
+}
+
+// RunRecorder persists run lifecycle records; state.RunStore implements
+// it.
+type RunRecorder interface {
+	Put(rec *types.RunRecord) error
+}
+
+// Record returns the run's lifecycle record, with the outcome from result
+// if it has finished.
+func (r *Run) Record(result *RunResult) *types.RunRecord {
+	rec := &types.RunRecord{
+		ID:        r.ID,
+		SessionID: r.SessionID,
+		Status:    string(r.Status),
+		CreatedAt: r.CreatedAt,
+		StartedAt: r.StartedAt,
+		EndedAt:   r.EndedAt,
+		Attempts:  r.Attempts,
+	}
+	if r.Event != nil {
+		rec.SessionKey = r.Event.SessionKey
+		rec.Source = r.Event.Source
+		if r.EndedAt == nil {
+			rec.Event = r.Event
+		}
+	}
+	if r.Error != nil {
+		rec.Error = r.Error.Error()
+	}
+	if result != nil {
+		rec.InputTokens = result.Usage.InputTokens
+		rec.OutputTokens = result.Usage.OutputTokens
+		rec.ToolRounds = result.ToolRounds
+		rec.ToolCalls = len(result.ToolCalls)
+	}
+	return rec
+}
+
+// Finish reports the run's result to OnResult and its text to OnComplete.
+func (r *Run) Finish(result *RunResult) {
+	if r.OnResult != nil {
+		r.OnResult(result)
+	}
+	if r.OnComplete != nil {
+		r.OnComplete(result.Text)
+	}
 }
 
 // NewRun creates a Run in the Queued state for the given session and event.
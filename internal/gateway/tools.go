@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// dangerousTools can act on the host or the outside world. Adapters only let
+// admins toggle them for a session.
+var dangerousTools = map[string]bool{
+	"bash": true,
+}
+
+// mutatingTools change state on the host, in memory or in outside
+// services. Dry-run sessions plan them instead of running them.
+var mutatingTools = map[string]bool{
+	"bash":          true,
+	"http_request":  true,
+	"memory_save":   true,
+	"memory_delete": true,
+}
+
+// ErrToolDenied is wrapped by the errors of tool calls a tool's own policy
+// refused to run, such as a bash command on its deny list.
+var ErrToolDenied = errors.New("denied by tool policy")
+
+// IsMutatingTool reports whether the tool changes state.
+func IsMutatingTool(name string) bool {
+	return mutatingTools[name]
+}
+
+// NeedsConfirmation reports whether a session must confirm the tool before
+// it runs: every mutating tool in dry-run mode, plus the tools it flagged.
+func NeedsConfirmation(session *types.SessionIndex, tool string) bool {
+	if session.DryRun && IsMutatingTool(tool) {
+		return true
+	}
+	for _, name := range session.ConfirmTools {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDangerousTool reports whether toggling the tool requires an admin.
+func IsDangerousTool(name string) bool {
+	return dangerousTools[name]
+}
+
+// ToolEnabled reports whether a session's policy allows the tool.
+func ToolEnabled(session *types.SessionIndex, tool string) bool {
+	for _, name := range session.DisabledTools {
+		if name == tool {
+			return false
+		}
+	}
+	return true
+}
+
+// SetToolEnabled enables or disables a tool for a session. The policy is
+// stored on the SessionIndex and applied by the runtime on the next round.
+func SetToolEnabled(ctx context.Context, sessions types.SessionStore, id types.SessionID, tool string, enabled bool) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Build a fresh slice: the store's cached copy may share the old one.
+	var disabled []string
+	for _, name := range sess.DisabledTools {
+		if name != tool {
+			disabled = append(disabled, name)
+		}
+	}
+	if !enabled {
+		disabled = append(disabled, tool)
+		sort.Strings(disabled)
+	}
+	sess.DisabledTools = disabled
+
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// SetToolConfirm flags a tool as needing confirmation in a session, or
+// clears the flag.
+func SetToolConfirm(ctx context.Context, sessions types.SessionStore, id types.SessionID, tool string, confirm bool) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var flagged []string
+	for _, name := range sess.ConfirmTools {
+		if name != tool {
+			flagged = append(flagged, name)
+		}
+	}
+	if confirm {
+		flagged = append(flagged, tool)
+		sort.Strings(flagged)
+	}
+	sess.ConfirmTools = flagged
+
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// SetDryRun turns a session's dry-run mode on or off. Turning it off keeps
+// any pending calls so they can still be confirmed.
+func SetDryRun(ctx context.Context, sessions types.SessionStore, id types.SessionID, on bool) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	sess.DryRun = on
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// AddPending appends planned tool calls to a session.
+func AddPending(ctx context.Context, sessions types.SessionStore, id types.SessionID, calls []types.PendingCall) error {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	sess.Pending = append(append([]types.PendingCall(nil), sess.Pending...), calls...)
+	if err := sessions.Update(ctx, sess); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// TakePending removes and returns a session's planned tool calls.
+func TakePending(ctx context.Context, sessions types.SessionStore, id types.SessionID) ([]types.PendingCall, error) {
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	calls := sess.Pending
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	sess.Pending = nil
+	if err := sessions.Update(ctx, sess); err != nil {
+		return nil, fmt.Errorf("update session: %w", err)
+	}
+	return calls, nil
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestSetToolEnabled(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	ctx := context.Background()
+
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("telegram", "1", "1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tool := range []string{"read_url", "bash", "bash"} {
+		if err := SetToolEnabled(ctx, sessions, sid, tool, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sess, err := sessions.Get(ctx, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bash", "read_url"}; !reflect.DeepEqual(sess.DisabledTools, want) {
+		t.Errorf("expected disabled %v, got %v", want, sess.DisabledTools)
+	}
+	if ToolEnabled(sess, "bash") || !ToolEnabled(sess, "memory_save") {
+		t.Error("ToolEnabled disagrees with the stored policy")
+	}
+
+	if err := SetToolEnabled(ctx, sessions, sid, "bash", true); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ = sessions.Get(ctx, sid)
+	if want := []string{"read_url"}; !reflect.DeepEqual(sess.DisabledTools, want) {
+		t.Errorf("expected disabled %v after re-enabling bash, got %v", want, sess.DisabledTools)
+	}
+
+	if err := SetToolEnabled(ctx, sessions, "missing", "bash", false); err == nil {
+		t.Error("expected error for unknown session")
+	}
+}
+
+func TestIsDangerousTool(t *testing.T) {
+	if !IsDangerousTool("bash") {
+		t.Error("expected bash to be dangerous")
+	}
+	if IsDangerousTool("memory_list") {
+		t.Error("expected memory_list not to be dangerous")
+	}
+}
+
+func TestConfirmationPolicyAndPending(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	ctx := context.Background()
+
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("telegram", "1", "1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := sessions.Get(ctx, sid)
+	if NeedsConfirmation(sess, "bash") {
+		t.Error("expected no confirmation outside dry-run mode")
+	}
+
+	if err := SetDryRun(ctx, sessions, sid, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetToolConfirm(ctx, sessions, sid, "read_url", true); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ = sessions.Get(ctx, sid)
+	for tool, want := range map[string]bool{"bash": true, "memory_save": true, "memory_list": false, "read_url": true} {
+		if got := NeedsConfirmation(sess, tool); got != want {
+			t.Errorf("NeedsConfirmation(%s) = %v, want %v", tool, got, want)
+		}
+	}
+
+	calls := []types.PendingCall{{Tool: "bash", Arguments: json.RawMessage(`{"command":"ls"}`)}}
+	if err := AddPending(ctx, sessions, sid, calls); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPending(ctx, sessions, sid, calls); err != nil {
+		t.Fatal(err)
+	}
+	taken, err := TakePending(ctx, sessions, sid)
+	if err != nil || len(taken) != 2 {
+		t.Fatalf("expected 2 pending calls, got %d, %v", len(taken), err)
+	}
+	if taken, _ := TakePending(ctx, sessions, sid); len(taken) != 0 {
+		t.Errorf("expected pending calls to be cleared, got %d", len(taken))
+	}
+
+	if err := SetToolConfirm(ctx, sessions, sid, "read_url", false); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ = sessions.Get(ctx, sid)
+	if NeedsConfirmation(sess, "read_url") || len(sess.ConfirmTools) != 0 {
+		t.Errorf("expected read_url flag cleared, got %v", sess.ConfirmTools)
+	}
+}
@@ -0,0 +1,259 @@
+// Package heartbeat runs periodic proactive check-ins: the agent reviews a
+// checklist against the current state and messages the user only when
+// something needs attention.
+package heartbeat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+// OK is the reply that means nothing needs the user's attention.
+const OK = "HEARTBEAT_OK"
+
+// Outcomes of a check-in, recorded as the state's LastOutcome.
+const (
+	OutcomeSent       = "sent"
+	OutcomeOK         = "ok"
+	OutcomeQuietHours = "quiet_hours"
+	OutcomeBudget     = "budget_exhausted"
+	OutcomeRateLimit  = "rate_limited"
+	OutcomeDuplicate  = "duplicate"
+	OutcomeError      = "error"
+)
+
+// DefaultChecklist is reviewed when no checklist file is configured.
+const DefaultChecklist = `Review the following and decide whether anything needs the user's attention now:
+- Pending reminders or follow-ups you promised in earlier conversations or saved to memory
+- Unfinished or failing scheduled tasks
+- Alerts flagged since the last check-in`
+
+// Runner runs a check-in prompt through the agent and returns its reply.
+type Runner func(prompt string) (string, error)
+
+// Notifier delivers a check-in message to the user.
+type Notifier func(message string) error
+
+// Config controls how often the heartbeat runs and how much it may say.
+type Config struct {
+	// Interval between check-ins.
+	Interval time.Duration
+	// Checklist is the prompt the agent reviews each time.
+	Checklist string
+	// QuietStart and QuietEnd are offsets from local midnight; no check-in
+	// runs between them. Equal values disable quiet hours.
+	QuietStart, QuietEnd time.Duration
+	// MaxRuns caps check-ins (LLM runs) per day; 0 means no cap.
+	MaxRuns int
+	// MaxMessages caps messages sent to the user per day; 0 means no cap.
+	MaxMessages int
+	// MinGap is the least time between two messages to the user.
+	MinGap time.Duration
+}
+
+// Heartbeat schedules check-ins and applies the budget and suppression
+// rules around them.
+type Heartbeat struct {
+	cfg    Config
+	store  *state.HeartbeatStore
+	run    Runner
+	notify Notifier
+	status func() []string
+	now    func() time.Time
+}
+
+// New creates a heartbeat that runs check-ins with run and sends anything
+// worth saying with notify.
+func New(cfg Config, store *state.HeartbeatStore, run Runner, notify Notifier) *Heartbeat {
+	if cfg.Checklist == "" {
+		cfg.Checklist = DefaultChecklist
+	}
+	return &Heartbeat{cfg: cfg, store: store, run: run, notify: notify, now: time.Now}
+}
+
+// SetStatus sets a callback that describes the current state (such as
+// failing tasks) as lines for the check-in prompt.
+func (h *Heartbeat) SetStatus(status func() []string) {
+	h.status = status
+}
+
+// Flag queues an alert for the next check-in to review.
+func (h *Heartbeat) Flag(message string) error {
+	return h.store.Update(func(st *state.HeartbeatState) {
+		st.Flags = append(st.Flags, state.HeartbeatFlag{At: h.now(), Message: message})
+	})
+}
+
+// Start runs a check-in every interval until ctx is done.
+func (h *Heartbeat) Start(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			outcome, err := h.Beat()
+			if err != nil {
+				slog.Warn("heartbeat failed", "error", err)
+				continue
+			}
+			slog.Info("heartbeat", "outcome", outcome)
+		}
+	}
+}
+
+// Beat runs one check-in now and returns its outcome.
+func (h *Heartbeat) Beat() (string, error) {
+	now := h.now()
+	st, err := h.store.Load()
+	if err != nil {
+		return "", err
+	}
+	if day := now.Format(time.DateOnly); st.Day != day {
+		st.Day, st.Runs, st.Sent = day, 0, 0
+	}
+
+	switch {
+	case h.quiet(now):
+		return h.record(now, OutcomeQuietHours, nil)
+	case h.cfg.MaxRuns > 0 && st.Runs >= h.cfg.MaxRuns:
+		return h.record(now, OutcomeBudget, nil)
+	}
+
+	prompt := h.prompt(st.Flags)
+	reviewed := len(st.Flags)
+	if err := h.store.Update(func(s *state.HeartbeatState) {
+		if s.Day != st.Day {
+			s.Day, s.Runs, s.Sent = st.Day, 0, 0
+		}
+		s.Runs++
+		s.LastRun = now
+	}); err != nil {
+		return "", err
+	}
+
+	reply, err := h.run(prompt)
+	if err != nil {
+		h.record(now, OutcomeError, nil)
+		return OutcomeError, fmt.Errorf("run check-in: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" || strings.Contains(reply, OK) {
+		return h.record(now, OutcomeOK, &reviewed)
+	}
+
+	digest := sha256.Sum256([]byte(reply))
+	hash := hex.EncodeToString(digest[:8])
+	switch {
+	case hash == st.LastDigest:
+		return h.record(now, OutcomeDuplicate, &reviewed)
+	case h.cfg.MaxMessages > 0 && st.Sent >= h.cfg.MaxMessages,
+		!st.LastSent.IsZero() && now.Sub(st.LastSent) < h.cfg.MinGap:
+		// Keep the flags so a later check-in can still raise them.
+		return h.record(now, OutcomeRateLimit, nil)
+	}
+
+	if err := h.notify(reply); err != nil {
+		h.record(now, OutcomeError, nil)
+		return OutcomeError, fmt.Errorf("deliver check-in: %w", err)
+	}
+	err = h.store.Update(func(s *state.HeartbeatState) {
+		s.Sent++
+		s.LastSent = now
+		s.LastDigest = hash
+	})
+	if err != nil {
+		return OutcomeSent, err
+	}
+	return h.record(now, OutcomeSent, &reviewed)
+}
+
+// record saves the outcome of a check-in and drops the first reviewed
+// flags, if given; flags raised during the run stay queued.
+func (h *Heartbeat) record(now time.Time, outcome string, reviewed *int) (string, error) {
+	err := h.store.Update(func(st *state.HeartbeatState) {
+		if day := now.Format(time.DateOnly); st.Day != day {
+			st.Day, st.Runs, st.Sent = day, 0, 0
+		}
+		st.LastOutcome = outcome
+		if reviewed != nil {
+			st.Flags = st.Flags[min(*reviewed, len(st.Flags)):]
+		}
+	})
+	return outcome, err
+}
+
+// quiet reports whether now falls in the configured quiet hours.
+func (h *Heartbeat) quiet(now time.Time) bool {
+	start, end := h.cfg.QuietStart, h.cfg.QuietEnd
+	if start == end {
+		return false
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	t := now.Sub(midnight)
+	if start < end {
+		return t >= start && t < end
+	}
+	return t >= start || t < end // spans midnight
+}
+
+// prompt builds the check-in message from the checklist, the current
+// status and the queued flags.
+func (h *Heartbeat) prompt(flags []state.HeartbeatFlag) string {
+	var b strings.Builder
+	b.WriteString("[Heartbeat check-in at " + h.now().Format(time.RFC1123) + "]\n\n")
+	b.WriteString(h.cfg.Checklist)
+	b.WriteString("\n\nCurrent status:\n")
+	var lines []string
+	if h.status != nil {
+		lines = h.status()
+	}
+	for _, f := range flags {
+		lines = append(lines, "Alert at "+f.At.Format(time.RFC1123)+": "+f.Message)
+	}
+	if len(lines) == 0 {
+		b.WriteString("- Nothing flagged.\n")
+	}
+	for _, line := range lines {
+		b.WriteString("- " + line + "\n")
+	}
+	b.WriteString("\nIf nothing needs the user's attention, reply with exactly " + OK +
+		". Otherwise reply with a short message to the user; it will be sent to them as is.")
+	return b.String()
+}
+
+// ParseQuietHours parses a range such as "22:00-07:00" into offsets from
+// midnight. An empty string disables quiet hours.
+func ParseQuietHours(s string) (start, end time.Duration, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	if start, err = clock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = clock(to); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// clock parses HH:MM as an offset from midnight.
+func clock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("parse time of day %q: %w", s, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
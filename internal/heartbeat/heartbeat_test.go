@@ -0,0 +1,115 @@
+package heartbeat
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestBeat(t *testing.T) {
+	store := state.NewHeartbeatStore(filepath.Join(t.TempDir(), "heartbeat.json"))
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	var prompts, sent []string
+	reply := OK
+	var runErr error
+	hb := New(Config{Interval: time.Hour, MaxRuns: 4, MaxMessages: 2, MinGap: 2 * time.Hour}, store,
+		func(prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			return reply, runErr
+		},
+		func(message string) error {
+			sent = append(sent, message)
+			return nil
+		})
+	hb.now = func() time.Time { return now }
+	hb.SetStatus(func() []string { return []string{`Task "backup" failed: disk full`} })
+
+	beat := func(want string) {
+		t.Helper()
+		got, err := hb.Beat()
+		if err != nil && want != OutcomeError {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Beat() = %q, want %q", got, want)
+		}
+	}
+
+	// Nothing to report: no message, the flag was reviewed.
+	if err := hb.Flag("disk at 95%"); err != nil {
+		t.Fatal(err)
+	}
+	beat(OutcomeOK)
+	if len(sent) != 0 {
+		t.Fatalf("expected no message, got %v", sent)
+	}
+	for _, want := range []string{DefaultChecklist, `Task "backup" failed`, "disk at 95%", OK} {
+		if !strings.Contains(prompts[0], want) {
+			t.Errorf("expected %q in prompt:\n%s", want, prompts[0])
+		}
+	}
+	if st, _ := store.Load(); len(st.Flags) != 0 {
+		t.Errorf("expected reviewed flags cleared, got %v", st.Flags)
+	}
+
+	reply = "The backup task is failing: the disk is full."
+	beat(OutcomeSent)
+	if len(sent) != 1 || sent[0] != reply {
+		t.Fatalf("expected the reply to be sent, got %v", sent)
+	}
+
+	// Too soon after the last message, then a repeat of it.
+	now = now.Add(time.Hour)
+	reply = "Still failing."
+	beat(OutcomeRateLimit)
+	now = now.Add(2 * time.Hour)
+	reply = "The backup task is failing: the disk is full."
+	beat(OutcomeDuplicate)
+
+	// The run budget is spent after four check-ins today.
+	reply = "Still failing."
+	beat(OutcomeBudget)
+	if len(prompts) != 4 || len(sent) != 1 {
+		t.Fatalf("expected 4 runs and 1 message, got %d and %d", len(prompts), len(sent))
+	}
+
+	// Counters reset the next day; errors keep flags for the next try.
+	now = now.Add(24 * time.Hour)
+	runErr = errors.New("provider down")
+	hb.Flag("disk at 99%")
+	beat(OutcomeError)
+	runErr = nil
+	beat(OutcomeSent)
+	if !strings.Contains(prompts[len(prompts)-1], "disk at 99%") {
+		t.Error("expected the flag to survive a failed check-in")
+	}
+	st, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Runs != 2 || st.Sent != 1 || st.LastOutcome != OutcomeSent || len(st.Flags) != 0 {
+		t.Errorf("unexpected state %+v", st)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	start, end, err := ParseQuietHours("22:00-07:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb := &Heartbeat{cfg: Config{QuietStart: start, QuietEnd: end}}
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "03:00": true, "07:29": true, "07:30": false, "12:00": false} {
+		at, _ := time.Parse("15:04", clock)
+		if got := hb.quiet(at); got != want {
+			t.Errorf("quiet(%s) = %v, want %v", clock, got, want)
+		}
+	}
+	if _, _, err := ParseQuietHours("late"); err == nil {
+		t.Error("expected error for malformed range")
+	}
+}
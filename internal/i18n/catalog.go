@@ -0,0 +1,200 @@
+package i18n
+
+// catalog holds the built-in user-facing strings by language and key. Every
+// key must exist in English; other languages may be partial.
+var catalog = map[string]map[string]string{
+	"en": {
+		"attachment_failed":     "Sorry, I couldn't download your attachment.",
+		"message_failed":        "Sorry, I encountered an error processing your message.",
+		"locked":                "This conversation is locked and isn't accepting new messages right now.",
+		"locked_reason":         "This conversation is locked and isn't accepting new messages right now. Reason: %s",
+		"start":                 "Hello! I'm Gopherclaw, your AI assistant. Send me a message to get started.",
+		"new_failed":            "Error creating new session.",
+		"new_none":              "No existing session. Send a message to start one.",
+		"new_summarized":        "New session started. Previous conversation has been archived and summarized.",
+		"new_archived":          "New session started. Previous conversation has been archived.",
+		"new_idle":              "New session started (previous conversation archived after %s of inactivity).",
+		"duration_day":          "%d day",
+		"duration_days":         "%d days",
+		"duration_hour":         "%d hour",
+		"duration_hours":        "%d hours",
+		"duration_minute":       "%d minute",
+		"duration_minutes":      "%d minutes",
+		"status_failed":         "Error fetching status.",
+		"status":                "Session: %s\nMessages: %d",
+		"session_failed":        "Error fetching session.",
+		"events_failed":         "Error loading events.",
+		"update_failed":         "Error updating session.",
+		"feedback_failed":       "Error recording feedback.",
+		"feedback_none":         "There's no response to rate yet.",
+		"feedback_thanks":       "Thanks for the feedback.",
+		"lock_admin_only":       "Only admins can lock or unlock this conversation.",
+		"lock_done":             "Conversation locked. New messages will be refused until /unlock.",
+		"unlock_done":           "Conversation unlocked.",
+		"sys_admin_only":        "Only admins can set standing instructions.",
+		"sys_none":              "No standing instructions. Add one with /sys <instruction>.",
+		"sys_header":            "Standing instructions (clear them with /sys clear):",
+		"sys_added":             "Instruction added. I'll follow it for the rest of this conversation.",
+		"sys_cleared":           "Standing instructions cleared.",
+		"tools_usage":           "Usage: /tools, /tools on <tool>, /tools off <tool>, /tools ask <tool>",
+		"tools_header":          "Tools for this conversation:",
+		"tools_footer":          "Toggle with /tools on <tool> or /tools off <tool>. /tools ask <tool> makes me ask before running it.",
+		"tool_on":               "on",
+		"tool_off":              "off",
+		"tool_ask":              "ask first",
+		"tool_ask_set":          "I'll describe %s calls and wait for /confirm before running them.",
+		"tool_unknown":          "Unknown tool %q.",
+		"tool_admin_only":       "Only admins can change %s.",
+		"tool_enabled":          "%s enabled for this conversation.",
+		"tool_disabled":         "%s disabled for this conversation.",
+		"dryrun_is_on":          "Dry-run mode is on: I plan commands and memory changes instead of running them. %d planned call(s) waiting for /confirm. Turn it off with /dryrun off.",
+		"dryrun_is_off":         "Dry-run mode is off. Turn it on with /dryrun on to review commands and memory changes before they run.",
+		"dryrun_on":             "Dry-run mode on. I'll explain commands and memory changes and wait for /confirm before running them.",
+		"dryrun_off":            "Dry-run mode off. Planned calls still wait for /confirm or /cancel.",
+		"dryrun_usage":          "Usage: /dryrun, /dryrun on, /dryrun off",
+		"confirm_none":          "There are no planned tool calls to confirm.",
+		"cancel_done":           "Dropped %d planned tool call(s).",
+		"broadcast_unavailable": "Broadcast is not available.",
+		"broadcast_admin_only":  "Only configured admins can broadcast.",
+		"broadcast_usage":       "Usage: /broadcast <message>",
+		"broadcast_failed":      "Error sending broadcast.",
+		"broadcast_sent":        "Broadcast sent to %d chats.",
+		"broadcast_some_failed": "Broadcast sent to %d chats. %d failed.",
+		"memories_none":         "No memories stored yet.",
+		"memories_header":       "*Stored Memories:*",
+		"language_current":      "Language: %s. Change it with /language <code>, or /language auto to detect it from your messages.",
+		"language_unset":        "No language set; I'll detect it from your messages. Set one with /language <code> (%s).",
+		"language_set":          "Language set to %s.",
+		"language_cleared":      "Language preference cleared. I'll detect it from your messages.",
+		"language_unknown":      "Unsupported language %q. Choose one of: %s.",
+		"macros_unavailable":    "Macros are not available.",
+		"macros_none":           "No macros defined. Add one with: gopherclaw macro add <name> <template>",
+		"macros_header":         "Macros:",
+		"macros_footer":         "Run one with /m <name> key=value ...",
+		"macro_unknown":         "Unknown macro %q. Send /m to list them.",
+		"macro_failed":          "Couldn't expand the macro: %v",
+		"unknown_command":       "Unknown command. Available: %s",
+		"sources":               "Sources:",
+		"full_output":           "Full output (%s): %s",
+		"reminder_fired":        "⏰ Reminder: %s",
+		"reminder_snooze_10m":   "Snooze 10 min",
+		"reminder_snooze_1h":    "Snooze 1 hour",
+		"reminder_tomorrow":     "Tomorrow",
+		"reminder_done":         "Done",
+		"reminder_snoozed":      "Snoozed until %s.",
+		"reminder_dismissed":    "Done.",
+		"reminder_gone":         "This reminder no longer exists.",
+		"approval_request":      "🔐 May I run %s?\n%s",
+		"approval_approve":      "Approve",
+		"approval_deny":         "Deny",
+		"approval_approved":     "Approved.",
+		"approval_denied":       "Denied.",
+		"approval_expired":      "No answer in time, so it didn't run.",
+		"approval_gone":         "This request is no longer waiting for an answer.",
+		"approval_not_yours":    "Only the person who asked or an admin can answer this.",
+		"approval_too_long":     "🔐 A %s call is too long to show here in full, so it didn't run.",
+		"run_not_approved":      "OK, I didn't run %s and stopped there.",
+		"usage_failed":          "Error loading usage.",
+		"usage_none":            "No model usage recorded in this conversation yet.",
+		"usage_report":          "Model usage in this conversation:\nTotal: %s\nToday: %s",
+		"usage_line":            "%d calls, %d tokens in, %d out, $%.4f",
+		"usage_unpriced":        "%d call(s) used models without a price and aren't in the cost.",
+	},
+	"es": {
+		"attachment_failed":     "Lo siento, no pude descargar tu archivo adjunto.",
+		"message_failed":        "Lo siento, ocurrió un error al procesar tu mensaje.",
+		"locked":                "Esta conversación está bloqueada y no acepta mensajes nuevos por ahora.",
+		"locked_reason":         "Esta conversación está bloqueada y no acepta mensajes nuevos por ahora. Motivo: %s",
+		"start":                 "¡Hola! Soy Gopherclaw, tu asistente de IA. Envíame un mensaje para empezar.",
+		"new_failed":            "Error al crear una sesión nueva.",
+		"new_none":              "No hay ninguna sesión. Envía un mensaje para empezar una.",
+		"new_summarized":        "Nueva sesión iniciada. La conversación anterior se archivó y se resumió.",
+		"new_archived":          "Nueva sesión iniciada. La conversación anterior se archivó.",
+		"new_idle":              "Nueva sesión iniciada (la conversación anterior se archivó tras %s de inactividad).",
+		"duration_day":          "%d día",
+		"duration_days":         "%d días",
+		"duration_hour":         "%d hora",
+		"duration_hours":        "%d horas",
+		"duration_minute":       "%d minuto",
+		"duration_minutes":      "%d minutos",
+		"status_failed":         "Error al obtener el estado.",
+		"status":                "Sesión: %s\nMensajes: %d",
+		"session_failed":        "Error al obtener la sesión.",
+		"events_failed":         "Error al cargar los eventos.",
+		"update_failed":         "Error al actualizar la sesión.",
+		"feedback_failed":       "Error al registrar tu valoración.",
+		"feedback_none":         "Todavía no hay ninguna respuesta que valorar.",
+		"feedback_thanks":       "Gracias por tu valoración.",
+		"lock_admin_only":       "Solo los administradores pueden bloquear o desbloquear esta conversación.",
+		"lock_done":             "Conversación bloqueada. Se rechazarán los mensajes nuevos hasta /unlock.",
+		"unlock_done":           "Conversación desbloqueada.",
+		"sys_admin_only":        "Solo los administradores pueden fijar instrucciones permanentes.",
+		"sys_none":              "No hay instrucciones permanentes. Añade una con /sys <instrucción>.",
+		"sys_header":            "Instrucciones permanentes (bórralas con /sys clear):",
+		"sys_added":             "Instrucción añadida. La seguiré durante el resto de esta conversación.",
+		"sys_cleared":           "Instrucciones permanentes borradas.",
+		"tools_usage":           "Uso: /tools, /tools on <herramienta>, /tools off <herramienta>, /tools ask <herramienta>",
+		"tools_header":          "Herramientas de esta conversación:",
+		"tools_footer":          "Cámbialas con /tools on <herramienta> o /tools off <herramienta>. Con /tools ask <herramienta> te pediré confirmación antes de usarla.",
+		"tool_on":               "activada",
+		"tool_off":              "desactivada",
+		"tool_ask":              "con confirmación",
+		"tool_ask_set":          "Describiré las llamadas a %s y esperaré a /confirm antes de ejecutarlas.",
+		"tool_unknown":          "Herramienta desconocida: %q.",
+		"tool_admin_only":       "Solo los administradores pueden cambiar %s.",
+		"tool_enabled":          "%s activada para esta conversación.",
+		"tool_disabled":         "%s desactivada para esta conversación.",
+		"dryrun_is_on":          "El modo de simulación está activado: planifico comandos y cambios de memoria en lugar de ejecutarlos. %d llamada(s) planificada(s) esperan /confirm. Desactívalo con /dryrun off.",
+		"dryrun_is_off":         "El modo de simulación está desactivado. Actívalo con /dryrun on para revisar comandos y cambios de memoria antes de ejecutarlos.",
+		"dryrun_on":             "Modo de simulación activado. Explicaré los comandos y cambios de memoria y esperaré a /confirm antes de ejecutarlos.",
+		"dryrun_off":            "Modo de simulación desactivado. Las llamadas planificadas siguen esperando /confirm o /cancel.",
+		"dryrun_usage":          "Uso: /dryrun, /dryrun on, /dryrun off",
+		"confirm_none":          "No hay llamadas planificadas que confirmar.",
+		"cancel_done":           "Se descartaron %d llamada(s) planificada(s).",
+		"broadcast_unavailable": "La difusión no está disponible.",
+		"broadcast_admin_only":  "Solo los administradores configurados pueden difundir mensajes.",
+		"broadcast_usage":       "Uso: /broadcast <mensaje>",
+		"broadcast_failed":      "Error al enviar la difusión.",
+		"broadcast_sent":        "Difusión enviada a %d chats.",
+		"broadcast_some_failed": "Difusión enviada a %d chats. %d fallaron.",
+		"memories_none":         "Todavía no hay recuerdos guardados.",
+		"memories_header":       "*Recuerdos guardados:*",
+		"language_current":      "Idioma: %s. Cámbialo con /language <código>, o usa /language auto para detectarlo de tus mensajes.",
+		"language_unset":        "No hay idioma configurado; lo detectaré de tus mensajes. Elige uno con /language <código> (%s).",
+		"language_set":          "Idioma configurado: %s.",
+		"language_cleared":      "Preferencia de idioma borrada. Lo detectaré de tus mensajes.",
+		"language_unknown":      "Idioma no disponible: %q. Elige uno de: %s.",
+		"macros_unavailable":    "Las macros no están disponibles.",
+		"macros_none":           "No hay macros definidas. Añade una con: gopherclaw macro add <nombre> <plantilla>",
+		"macros_header":         "Macros:",
+		"macros_footer":         "Ejecuta una con /m <nombre> clave=valor ...",
+		"macro_unknown":         "Macro desconocida: %q. Envía /m para ver la lista.",
+		"macro_failed":          "No pude expandir la macro: %v",
+		"unknown_command":       "Comando desconocido. Disponibles: %s",
+		"sources":               "Fuentes:",
+		"full_output":           "Salida completa (%s): %s",
+		"reminder_fired":        "⏰ Recordatorio: %s",
+		"reminder_snooze_10m":   "Posponer 10 min",
+		"reminder_snooze_1h":    "Posponer 1 hora",
+		"reminder_tomorrow":     "Mañana",
+		"reminder_done":         "Hecho",
+		"reminder_snoozed":      "Pospuesto hasta %s.",
+		"reminder_dismissed":    "Hecho.",
+		"reminder_gone":         "Este recordatorio ya no existe.",
+		"approval_request":      "🔐 ¿Puedo ejecutar %s?\n%s",
+		"approval_approve":      "Aprobar",
+		"approval_deny":         "Rechazar",
+		"approval_approved":     "Aprobado.",
+		"approval_denied":       "Rechazado.",
+		"approval_expired":      "No hubo respuesta a tiempo, así que no se ejecutó.",
+		"approval_gone":         "Esta solicitud ya no espera respuesta.",
+		"approval_not_yours":    "Solo quien lo pidió o un administrador puede responder.",
+		"approval_too_long":     "🔐 Una llamada a %s es demasiado larga para mostrarla aquí completa, así que no se ejecutó.",
+		"run_not_approved":      "De acuerdo, no ejecuté %s y me detuve ahí.",
+		"usage_failed":          "Error al cargar el consumo.",
+		"usage_none":            "Aún no hay consumo del modelo en esta conversación.",
+		"usage_report":          "Consumo del modelo en esta conversación:\nTotal: %s\nHoy: %s",
+		"usage_line":            "%d llamadas, %d tokens de entrada, %d de salida, $%.4f",
+		"usage_unpriced":        "%d llamada(s) usaron modelos sin precio y no cuentan en el coste.",
+	},
+}
@@ -0,0 +1,148 @@
+// Package i18n holds the translations of built-in user-facing strings and a
+// lightweight language detector for session language preferences.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Default is the language used when a session has no preference or a
+// string has no translation.
+const Default = "en"
+
+// names maps supported language codes to the English name given to the
+// model in the system prompt.
+var names = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"pt": "Portuguese",
+	"it": "Italian",
+	"nl": "Dutch",
+}
+
+// Normalize reduces a language tag such as "es-MX" or "ES" to a supported
+// code, reporting false when the language is not supported.
+func Normalize(tag string) (string, bool) {
+	code := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	_, ok := names[code]
+	return code, ok
+}
+
+// Name returns the English name of a language code, or "" if unsupported.
+func Name(code string) string {
+	return names[code]
+}
+
+// Codes returns the supported language codes, sorted.
+func Codes() []string {
+	codes := make([]string, 0, len(names))
+	for code := range names {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// T returns the string for key in the given language, formatted with args.
+// Languages without a translation of key fall back to English.
+func T(lang, key string, args ...any) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// durationUnits are the units Duration counts in, largest first, with the
+// catalog keys of their singular and plural forms.
+var durationUnits = []struct {
+	size         time.Duration
+	one, several string
+}{
+	{24 * time.Hour, "duration_day", "duration_days"},
+	{time.Hour, "duration_hour", "duration_hours"},
+	{time.Minute, "duration_minute", "duration_minutes"},
+}
+
+// Duration formats d for people, such as "1 day" or "90 minutes", in the
+// largest unit that measures it exactly. Anything finer is rounded to
+// whole minutes.
+func Duration(lang string, d time.Duration) string {
+	unit := durationUnits[len(durationUnits)-1]
+	for _, u := range durationUnits {
+		if d >= u.size && d%u.size == 0 {
+			unit = u
+			break
+		}
+	}
+	n := int64(d.Round(unit.size) / unit.size)
+	if n <= 1 {
+		return T(lang, unit.one, 1)
+	}
+	return T(lang, unit.several, n)
+}
+
+// stopwords are frequent short words that are distinctive enough, taken
+// together, to tell the supported languages apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "what", "how", "to", "of", "it", "this", "that", "i", "my", "can", "please", "with", "for", "do", "hello", "thanks"},
+	"es": {"el", "los", "las", "que", "y", "es", "en", "un", "una", "por", "para", "cómo", "qué", "con", "mi", "tu", "hola", "gracias", "está", "puedes", "del", "yo"},
+	"fr": {"le", "les", "des", "est", "et", "je", "vous", "pour", "pas", "une", "avec", "bonjour", "merci", "c'est", "comment", "qui", "du", "tu"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "nicht", "du", "sie", "ein", "eine", "mit", "für", "wie", "was", "hallo", "danke", "bitte", "zu"},
+	"pt": {"os", "as", "que", "e", "é", "não", "um", "uma", "para", "com", "você", "olá", "obrigado", "obrigada", "como", "meu", "do", "da"},
+	"it": {"il", "lo", "che", "e", "è", "di", "per", "non", "un", "una", "con", "ciao", "grazie", "come", "sono", "mi", "questo", "della"},
+	"nl": {"de", "het", "een", "en", "is", "ik", "je", "niet", "van", "dat", "met", "voor", "hoe", "wat", "hallo", "bedankt", "alsjeblieft"},
+}
+
+// Detect guesses the language of a message from its common words. It
+// returns "" when the text is too short or too ambiguous to tell.
+func Detect(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	scores := make(map[string]int, len(stopwords))
+	for lang, list := range stopwords {
+		for _, w := range words {
+			for _, s := range list {
+				if w == s {
+					scores[lang]++
+					break
+				}
+			}
+		}
+	}
+	// Inverted punctuation only occurs in Spanish.
+	if strings.ContainsAny(text, "¿¡") {
+		scores["es"] += 2
+	}
+
+	best, second := "", 0
+	for _, lang := range Codes() {
+		switch score := scores[lang]; {
+		case best == "" || score > scores[best]:
+			second = scores[best]
+			best = lang
+		case score > second:
+			second = score
+		}
+	}
+	if scores[best] < 2 || scores[best] == second {
+		return ""
+	}
+	return best
+}
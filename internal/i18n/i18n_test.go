@@ -0,0 +1,89 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"¿Qué tiempo hace hoy en Madrid?", "es"},
+		{"Hola, ¿puedes ayudarme con la lista de la compra para el fin de semana?", "es"},
+		{"Can you check the disk usage on the server for me?", "en"},
+		{"Bonjour, je voudrais savoir comment ça marche pour la facture", "fr"},
+		{"ok", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{"es": "es", "ES": "es", "es-MX": "es", " pt_BR ": "pt"} {
+		if got, ok := Normalize(tag); !ok || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tag, got, ok, want)
+		}
+	}
+	if _, ok := Normalize("klingon"); ok {
+		t.Error("expected unsupported language to be rejected")
+	}
+}
+
+func TestTFallsBackToEnglish(t *testing.T) {
+	if got := T("es", "start"); !strings.HasPrefix(got, "¡Hola!") {
+		t.Errorf("expected Spanish greeting, got %q", got)
+	}
+	if got, want := T("de", "start"), T("en", "start"); got != want {
+		t.Errorf("expected English fallback %q, got %q", want, got)
+	}
+	if got := T("es", "status", "abc", 3); got != "Sesión: abc\nMensajes: 3" {
+		t.Errorf("unexpected formatted string %q", got)
+	}
+	if got := T("en", "no_such_key"); got != "no_such_key" {
+		t.Errorf("expected key for missing string, got %q", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		lang string
+		d    time.Duration
+		want string
+	}{
+		{"en", 24 * time.Hour, "1 day"},
+		{"en", 48 * time.Hour, "2 days"},
+		{"en", 36 * time.Hour, "36 hours"},
+		{"en", 90 * time.Minute, "90 minutes"},
+		{"en", 30 * time.Second, "1 minute"},
+		{"es", 2 * time.Hour, "2 horas"},
+	} {
+		if got := Duration(tc.lang, tc.d); got != tc.want {
+			t.Errorf("Duration(%q, %s) = %q, want %q", tc.lang, tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestCatalogTranslationsHaveEnglish(t *testing.T) {
+	for lang, strs := range catalog {
+		if _, ok := names[lang]; !ok {
+			t.Errorf("catalog language %q is not supported", lang)
+		}
+		for key, msg := range strs {
+			en, ok := catalog[Default][key]
+			if !ok {
+				t.Errorf("%s key %q has no English string", lang, key)
+				continue
+			}
+			if strings.Count(msg, "%") != strings.Count(en, "%") {
+				t.Errorf("%s key %q has different format verbs than English", lang, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parseChatGPT reads conversations.json from a ChatGPT data export. Each
+// conversation is a tree of message nodes; the visible thread is the path
+// from current_node back to the root.
+func parseChatGPT(data []byte) ([]Conversation, error) {
+	var raw []struct {
+		ID             string  `json:"id"`
+		ConversationID string  `json:"conversation_id"`
+		Title          string  `json:"title"`
+		CreateTime     float64 `json:"create_time"`
+		CurrentNode    string  `json:"current_node"`
+		Mapping        map[string]struct {
+			Parent  string `json:"parent"`
+			Message *struct {
+				Author struct {
+					Role string `json:"role"`
+				} `json:"author"`
+				CreateTime *float64 `json:"create_time"`
+				Content    struct {
+					ContentType string            `json:"content_type"`
+					Parts       []json.RawMessage `json:"parts"`
+				} `json:"content"`
+				Metadata struct {
+					Hidden bool `json:"is_visually_hidden_from_conversation"`
+				} `json:"metadata"`
+			} `json:"message"`
+		} `json:"mapping"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse chatgpt conversations: %w", err)
+	}
+
+	var convs []Conversation
+	for _, c := range raw {
+		conv := Conversation{ID: c.ID, Title: c.Title, Created: unixTime(c.CreateTime)}
+		if conv.ID == "" {
+			conv.ID = c.ConversationID
+		}
+		var thread []Message
+		seen := make(map[string]bool)
+		for id := c.CurrentNode; id != "" && !seen[id]; id = c.Mapping[id].Parent {
+			seen[id] = true
+			m := c.Mapping[id].Message
+			if m == nil || m.Metadata.Hidden {
+				continue
+			}
+			role := m.Author.Role
+			if role != "user" && role != "assistant" {
+				continue
+			}
+			switch m.Content.ContentType {
+			case "text", "multimodal_text":
+			default:
+				continue // code, tool output, browsing results
+			}
+			var parts []string
+			for _, p := range m.Content.Parts {
+				var s string
+				if json.Unmarshal(p, &s) == nil && strings.TrimSpace(s) != "" {
+					parts = append(parts, s)
+				}
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			msg := Message{Role: role, Text: strings.Join(parts, "\n")}
+			if m.CreateTime != nil {
+				msg.At = unixTime(*m.CreateTime)
+			}
+			thread = append(thread, msg)
+		}
+		for i, j := 0, len(thread)-1; i < j; i, j = i+1, j-1 {
+			thread[i], thread[j] = thread[j], thread[i]
+		}
+		conv.Messages = thread
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// parseClaude reads conversations.json from a Claude data export.
+func parseClaude(data []byte) ([]Conversation, error) {
+	var raw []struct {
+		UUID      string    `json:"uuid"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
+		Messages  []struct {
+			Sender    string    `json:"sender"`
+			Text      string    `json:"text"`
+			CreatedAt time.Time `json:"created_at"`
+			Content   []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"chat_messages"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse claude conversations: %w", err)
+	}
+
+	var convs []Conversation
+	for _, c := range raw {
+		conv := Conversation{ID: c.UUID, Title: c.Name, Created: c.CreatedAt}
+		for _, m := range c.Messages {
+			role := m.Sender
+			if role == "human" {
+				role = "user"
+			}
+			if role != "user" && role != "assistant" {
+				continue
+			}
+			text := m.Text
+			if text == "" {
+				var parts []string
+				for _, p := range m.Content {
+					if p.Type == "text" && p.Text != "" {
+						parts = append(parts, p.Text)
+					}
+				}
+				text = strings.Join(parts, "\n")
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			conv.Messages = append(conv.Messages, Message{Role: role, Text: text, At: m.CreatedAt})
+		}
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// parseOpenAIExport reads conversations in OpenAI chat format: a JSON array
+// or JSON lines of {"messages": [{"role": ..., "content": ...}]} objects,
+// as used for fine-tuning data and most chat log exports.
+func parseOpenAIExport(data []byte) ([]Conversation, error) {
+	type record struct {
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		Created  int64  `json:"created"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	var records []record
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, fmt.Errorf("parse openai export: %w", err)
+		}
+	} else {
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+				continue
+			}
+			var r record
+			if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+				return nil, fmt.Errorf("parse openai export line %d: %w", line, err)
+			}
+			records = append(records, r)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read openai export: %w", err)
+		}
+	}
+
+	var convs []Conversation
+	for _, r := range records {
+		conv := Conversation{ID: r.ID, Title: r.Title}
+		if r.Created > 0 {
+			conv.Created = time.Unix(r.Created, 0)
+		}
+		for _, m := range r.Messages {
+			if m.Role != "user" && m.Role != "assistant" {
+				continue
+			}
+			if text := contentText(m.Content); strings.TrimSpace(text) != "" {
+				conv.Messages = append(conv.Messages, Message{Role: m.Role, Text: text})
+			}
+		}
+		if conv.ID == "" {
+			conv.ID = contentID(conv.Messages)
+		}
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// contentText returns the text of an OpenAI message content, which is
+// either a string or an array of typed parts.
+func contentText(content json.RawMessage) string {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(content, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// contentID derives a stable ID for a conversation without one, so that
+// importing the same file twice finds the earlier import.
+func contentID(messages []Message) string {
+	h := sha256.New()
+	for _, m := range messages {
+		h.Write([]byte(m.Role + "\x00" + m.Text + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// unixTime converts fractional Unix seconds, or returns the zero time.
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// sortByCreated orders conversations oldest first; undated ones keep their
+// file order at the end.
+func sortByCreated(convs []Conversation) {
+	sort.SliceStable(convs, func(i, j int) bool {
+		a, b := convs[i].Created, convs[j].Created
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
@@ -0,0 +1,228 @@
+// Package importer converts conversation archives exported from other
+// assistants into gopherclaw sessions, and extracts long-term memories
+// from them.
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{"openai-export", "chatgpt", "claude"}
+
+// Conversation is one imported conversation, oldest message first.
+type Conversation struct {
+	ID       string
+	Title    string
+	Created  time.Time
+	Messages []Message
+}
+
+// Message is a user or assistant turn.
+type Message struct {
+	Role string // "user" or "assistant"
+	Text string
+	At   time.Time
+}
+
+// Parse decodes an export in the given format.
+func Parse(format string, data []byte) ([]Conversation, error) {
+	var convs []Conversation
+	var err error
+	switch format {
+	case "chatgpt":
+		convs, err = parseChatGPT(data)
+	case "claude":
+		convs, err = parseClaude(data)
+	case "openai-export":
+		convs, err = parseOpenAIExport(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sortByCreated(convs)
+	return convs, nil
+}
+
+// ReadFile parses an export file. A .zip archive, as ChatGPT and Claude
+// deliver their data exports, is searched for conversations.json.
+func ReadFile(format, file string) ([]Conversation, error) {
+	var data []byte
+	var err error
+	if strings.EqualFold(path.Ext(file), ".zip") {
+		data, err = readZipConversations(file)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(format, data)
+}
+
+// readZipConversations returns conversations.json from an export archive.
+func readZipConversations(file string) ([]byte, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("open export archive: %w", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if path.Base(f.Name) != "conversations.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no conversations.json in %s", file)
+}
+
+// Result summarizes an import.
+type Result struct {
+	Sessions []types.SessionID
+	Messages int
+	// Skipped counts conversations that were empty or already imported.
+	Skipped int
+}
+
+// SessionKey is the key an imported conversation is stored under. It is
+// stable, so importing the same export twice skips what is already there.
+func SessionKey(format, id string) types.SessionKey {
+	return types.NewSessionKey("import", format, id)
+}
+
+// Import stores each conversation as an archived session whose events are
+// the original messages with their original timestamps.
+func Import(ctx context.Context, sessions types.SessionStore, events types.EventStore, format string, convs []Conversation) (*Result, error) {
+	result := &Result{}
+	for _, conv := range convs {
+		if len(conv.Messages) == 0 {
+			result.Skipped++
+			continue
+		}
+		id, err := sessions.ResolveOrCreate(ctx, SessionKey(format, conv.ID), "default")
+		if err != nil {
+			return result, fmt.Errorf("create session: %w", err)
+		}
+		if n, err := events.Count(ctx, id); err != nil {
+			return result, fmt.Errorf("count events: %w", err)
+		} else if n > 0 {
+			result.Skipped++
+			continue
+		}
+
+		batch := make([]*types.Event, 0, len(conv.Messages))
+		at := conv.Created
+		for _, m := range conv.Messages {
+			if !m.At.IsZero() {
+				at = m.At
+			}
+			fields := map[string]any{"text": m.Text, "imported_from": format}
+			if len(batch) == 0 && conv.Title != "" {
+				fields["title"] = conv.Title
+			}
+			payload, _ := json.Marshal(fields)
+			typ := "user_message"
+			if m.Role == "assistant" {
+				typ = "assistant_message"
+			}
+			batch = append(batch, &types.Event{
+				ID:        types.NewEventID(),
+				SessionID: id,
+				Type:      typ,
+				Source:    "import",
+				At:        orNow(at),
+				Payload:   payload,
+			})
+		}
+		if err := events.AppendBatch(ctx, batch); err != nil {
+			return result, fmt.Errorf("record imported messages: %w", err)
+		}
+
+		sess, err := sessions.Get(ctx, id)
+		if err != nil {
+			return result, fmt.Errorf("load session: %w", err)
+		}
+		sess.Status = "archived"
+		sess.LastEventSeq = batch[len(batch)-1].Seq
+		if !conv.Created.IsZero() {
+			sess.CreatedAt = conv.Created
+		}
+		if err := sessions.Update(ctx, sess); err != nil {
+			return result, fmt.Errorf("update session: %w", err)
+		}
+		result.Sessions = append(result.Sessions, id)
+		result.Messages += len(batch)
+	}
+	return result, nil
+}
+
+// orNow returns t, or the current time if t is zero.
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+// memoryTranscriptLimit caps how much of a conversation is sent to the
+// model when extracting memories.
+const memoryTranscriptLimit = 24000
+
+const memoryPrompt = `Below is a past conversation between the user and another AI assistant. List durable facts about the user worth remembering in future conversations: preferences, personal details they shared, ongoing projects, tools and people they work with. Skip anything temporary, anything about the assistant, and general knowledge. Reply with one fact per line starting with "- ", written as short third-person statements (e.g. "- Prefers metric units"). Reply with NONE if there is nothing worth remembering.`
+
+// ExtractMemories asks the model for long-term facts about the user found
+// in a conversation.
+func ExtractMemories(ctx context.Context, provider llm.Provider, conv Conversation) ([]string, error) {
+	var transcript strings.Builder
+	if conv.Title != "" {
+		fmt.Fprintf(&transcript, "Title: %s\n\n", conv.Title)
+	}
+	for _, m := range conv.Messages {
+		role := "User"
+		if m.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", role, m.Text)
+		if transcript.Len() > memoryTranscriptLimit {
+			break
+		}
+	}
+
+	resp, err := provider.Complete(ctx, []llm.Message{
+		{Role: "system", Content: memoryPrompt},
+		{Role: "user", Content: transcript.String()},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("extract memories: %w", err)
+	}
+	var facts []string
+	for _, line := range strings.Split(resp.Content, "\n") {
+		fact, ok := strings.CutPrefix(strings.TrimSpace(line), "- ")
+		if fact = strings.TrimSpace(fact); ok && fact != "" {
+			facts = append(facts, fact)
+		}
+	}
+	return facts, nil
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+const chatgptExport = `[{
+	"id": "c1", "title": "Trip planning", "create_time": 1700000000.5, "current_node": "n4",
+	"mapping": {
+		"root": {"parent": "", "message": null},
+		"n1": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+		"n2": {"parent": "n1", "message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["I'm vegetarian, find restaurants in Lisbon"]}}},
+		"old": {"parent": "n2", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["abandoned branch"]}}},
+		"n3": {"parent": "n2", "message": {"author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["search results"]}}},
+		"n4": {"parent": "n3", "message": {"author": {"role": "assistant"}, "create_time": 1700000009, "content": {"content_type": "multimodal_text", "parts": ["Try Ao 26.", {"asset_pointer": "file-1"}]}}}
+	}
+}]`
+
+const claudeExport = `[{
+	"uuid": "u1", "name": "Go generics", "created_at": "2024-05-01T10:00:00Z",
+	"chat_messages": [
+		{"sender": "human", "text": "How do I constrain a type parameter?", "created_at": "2024-05-01T10:00:00Z"},
+		{"sender": "assistant", "text": "", "content": [{"type": "text", "text": "Use an interface constraint."}], "created_at": "2024-05-01T10:00:05Z"}
+	]
+}, {"uuid": "u2", "name": "empty", "created_at": "2024-04-01T10:00:00Z", "chat_messages": []}]`
+
+const openAIExport = `{"messages": [{"role": "system", "content": "be brief"}, {"role": "user", "content": "hi"}, {"role": "assistant", "content": [{"type": "text", "text": "hello"}]}]}
+{"id": "x", "messages": [{"role": "user", "content": "second"}]}
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		want   [][]Message
+	}{
+		{"chatgpt", chatgptExport, [][]Message{{
+			{Role: "user", Text: "I'm vegetarian, find restaurants in Lisbon", At: time.Unix(1700000001, 0)},
+			{Role: "assistant", Text: "Try Ao 26.", At: time.Unix(1700000009, 0)},
+		}}},
+		{"claude", claudeExport, [][]Message{
+			nil, // the empty conversation is older
+			{
+				{Role: "user", Text: "How do I constrain a type parameter?", At: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+				{Role: "assistant", Text: "Use an interface constraint.", At: time.Date(2024, 5, 1, 10, 0, 5, 0, time.UTC)},
+			},
+		}},
+		{"openai-export", openAIExport, [][]Message{
+			{{Role: "user", Text: "hi"}, {Role: "assistant", Text: "hello"}},
+			{{Role: "user", Text: "second"}},
+		}},
+	}
+	for _, tt := range tests {
+		convs, err := Parse(tt.format, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if len(convs) != len(tt.want) {
+			t.Fatalf("%s: got %d conversations, want %d", tt.format, len(convs), len(tt.want))
+		}
+		for i, want := range tt.want {
+			got := convs[i].Messages
+			if len(got) != len(want) {
+				t.Fatalf("%s[%d]: got %+v, want %+v", tt.format, i, got, want)
+			}
+			for j := range want {
+				if got[j].Role != want[j].Role || got[j].Text != want[j].Text || !got[j].At.Equal(want[j].At) {
+					t.Errorf("%s[%d][%d]: got %+v, want %+v", tt.format, i, j, got[j], want[j])
+				}
+			}
+		}
+	}
+
+	convs, _ := Parse("openai-export", []byte(openAIExport))
+	if convs[0].ID == "" || convs[1].ID != "x" {
+		t.Errorf("expected a derived ID and the given one, got %q and %q", convs[0].ID, convs[1].ID)
+	}
+	if _, err := Parse("bard", nil); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	ctx := context.Background()
+
+	convs, err := Parse("claude", []byte(claudeExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Import(ctx, sessions, events, "claude", convs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Sessions) != 1 || result.Messages != 2 || result.Skipped != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	sess, err := sessions.Get(ctx, result.Sessions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.SessionKey != "import:claude:u1" || sess.Status != "archived" || !sess.CreatedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected session %+v", sess)
+	}
+	evs, err := events.Tail(ctx, sess.SessionID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first map[string]string
+	json.Unmarshal(evs[0].Payload, &first)
+	if evs[0].Type != "user_message" || evs[1].Type != "assistant_message" || first["title"] != "Go generics" || evs[1].Source != "import" {
+		t.Errorf("unexpected events %s %s %s", evs[0].Type, evs[1].Type, evs[0].Payload)
+	}
+
+	// A second import of the same export adds nothing.
+	again, err := Import(ctx, sessions, events, "claude", convs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Sessions) != 0 || again.Skipped != 2 {
+		t.Errorf("expected re-import to skip everything, got %+v", again)
+	}
+}
+
+type stubProvider struct{ reply string }
+
+func (p *stubProvider) Complete(_ context.Context, _ []llm.Message, _ []llm.Tool) (*llm.Response, error) {
+	return &llm.Response{Content: p.reply}, nil
+}
+
+func (p *stubProvider) Stream(_ context.Context, _ []llm.Message, _ []llm.Tool) (<-chan llm.Delta, error) {
+	return nil, nil
+}
+
+func TestExtractMemories(t *testing.T) {
+	convs, _ := Parse("chatgpt", []byte(chatgptExport))
+	facts, err := ExtractMemories(context.Background(), &stubProvider{reply: "- Is vegetarian\n- \nNotes:\n-  Planning a trip to Lisbon "}, convs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facts) != 2 || facts[0] != "Is vegetarian" || facts[1] != "Planning a trip to Lisbon" {
+		t.Errorf("unexpected facts %q", facts)
+	}
+	if facts, _ := ExtractMemories(context.Background(), &stubProvider{reply: "NONE"}, convs[0]); len(facts) != 0 {
+		t.Errorf("expected no facts, got %q", facts)
+	}
+}
@@ -0,0 +1,246 @@
+// Package logging correlates log records with the run that produced them
+// and queries the daemon's JSON log file.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// ctxKey holds the attributes WithRun stores on a context.
+type ctxKey struct{}
+
+// WithRun returns a context whose log records carry run_id and session_id.
+// Records must be logged with a *Context method (slog.InfoContext and so
+// on) through a Handler to pick them up.
+func WithRun(ctx context.Context, runID types.RunID, sessionID types.SessionID) context.Context {
+	return context.WithValue(ctx, ctxKey{}, []slog.Attr{
+		slog.String("run_id", string(runID)),
+		slog.String("session_id", string(sessionID)),
+	})
+}
+
+// Handler adds the attributes stored by WithRun to each record.
+type Handler struct {
+	inner slog.Handler
+}
+
+// NewHandler wraps inner so records logged with a run context are tagged.
+func NewHandler(inner slog.Handler) *Handler {
+	return &Handler{inner: inner}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name)}
+}
+
+// tee sends each record to every handler that accepts its level.
+type tee []slog.Handler
+
+// Tee returns a handler that writes to all of handlers.
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return tee(handlers)
+}
+
+func (t tee) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t tee) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t tee) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(tee, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t tee) WithGroup(name string) slog.Handler {
+	out := make(tee, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// MaxFileSize is the size past which the log file is rotated.
+const MaxFileSize = 50 << 20
+
+// File is a log file that rotates itself once it grows past its size cap:
+// the current file is moved to path+".1", replacing the previous one, and
+// writing continues in a fresh file at path. It is safe for concurrent use.
+type File struct {
+	path string
+	max  int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenFile opens the log file at path for appending, capped at
+// MaxFileSize. A file already over the cap is rotated before the first
+// write.
+func OpenFile(path string) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	lf := &File{path: path, max: MaxFileSize}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// open opens path for appending and records its current size.
+func (lf *File) open() error {
+	f, err := os.OpenFile(lf.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	lf.f, lf.size = f, info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one.
+func (lf *File) rotate() error {
+	if err := lf.f.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	if err := os.Rename(lf.path, lf.path+".1"); err != nil {
+		// Keep logging to the oversized file rather than not at all.
+		return errors.Join(fmt.Errorf("rotate log file: %w", err), lf.open())
+	}
+	return lf.open()
+}
+
+// Write appends p, rotating first if the file is over its cap. Each slog
+// record is a single Write, so records are never split across files.
+func (lf *File) Write(p []byte) (int, error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.size > lf.max {
+		if err := lf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := lf.f.Write(p)
+	lf.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying file.
+func (lf *File) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.f.Close()
+}
+
+// Record is one decoded JSON log line.
+type Record map[string]any
+
+// Time returns the record's timestamp, or the zero time.
+func (r Record) Time() time.Time {
+	s, _ := r[slog.TimeKey].(string)
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// String returns the value of a string attribute, or "".
+func (r Record) String(key string) string {
+	s, _ := r[key].(string)
+	return s
+}
+
+// Filter selects log records. Zero fields match everything.
+type Filter struct {
+	RunID     string
+	SessionID string
+	// Level is the lowest level to include.
+	Level slog.Level
+	Since time.Time
+}
+
+// Match reports whether the record passes the filter.
+func (f Filter) Match(r Record) bool {
+	if f.RunID != "" && r.String("run_id") != f.RunID {
+		return false
+	}
+	if f.SessionID != "" && r.String("session_id") != f.SessionID {
+		return false
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(r.String(slog.LevelKey))); err == nil && level < f.Level {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time().Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Scan reads JSON log lines from r and calls fn for each record that
+// matches the filter. Lines that aren't JSON are skipped.
+func Scan(r io.Reader, f Filter, fn func(Record)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		if f.Match(rec) {
+			fn(rec)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCorrelation(t *testing.T) {
+	var jsonOut, textOut bytes.Buffer
+	logger := slog.New(NewHandler(Tee(
+		slog.NewJSONHandler(&jsonOut, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		slog.NewTextHandler(&textOut, &slog.HandlerOptions{Level: slog.LevelWarn}),
+	)))
+
+	ctx := WithRun(context.Background(), "run_1", "sess_1")
+	logger.InfoContext(ctx, "calling LLM", "round", 1)
+	logger.With("tool", "bash").WarnContext(ctx, "tool error")
+	logger.InfoContext(WithRun(context.Background(), "run_2", "sess_1"), "other run")
+	logger.Info("no run")
+
+	if strings.Contains(textOut.String(), "calling LLM") || !strings.Contains(textOut.String(), "run_id=run_1") {
+		t.Errorf("expected only the warning, tagged, on the text handler:\n%s", textOut.String())
+	}
+
+	collect := func(f Filter) string {
+		var msgs []string
+		if err := Scan(bytes.NewReader(jsonOut.Bytes()), f, func(r Record) { msgs = append(msgs, r.String("msg")) }); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(msgs, ",")
+	}
+	if got := collect(Filter{RunID: "run_1"}); got != "calling LLM,tool error" {
+		t.Errorf("run_1 lines = %s", got)
+	}
+	if got := collect(Filter{SessionID: "sess_1", Level: slog.LevelWarn}); got != "tool error" {
+		t.Errorf("sess_1 warnings = %s", got)
+	}
+	if got := collect(Filter{}); got != "calling LLM,tool error,other run,no run" {
+		t.Errorf("all lines = %s", got)
+	}
+}
+
+func TestFileRotatesWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gopherclaw.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.max = 10
+
+	for _, line := range []string{"first line\n", "second line\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(old) != "second line\n" || string(cur) != "third\n" {
+		t.Errorf("expected rotation on write, got %q in .1 and %q current", old, cur)
+	}
+}
@@ -0,0 +1,357 @@
+// Package maintenance runs the nightly housekeeping job: it clears out
+// stale files, checks the data directory's integrity, takes a backup and
+// tallies the last day's activity, then sums it all up in a short report
+// for the admins.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/user/gopherclaw/internal/backup"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// staleTempAge is how old a *.tmp file must be before it is taken for the
+// leftover of an interrupted write rather than one in progress.
+const staleTempAge = time.Hour
+
+// BackupPrefix starts the name of every backup the job writes; only files
+// named this way are rotated.
+const BackupPrefix = "gopherclaw-backup-"
+
+// Config controls when the job runs and what it keeps.
+type Config struct {
+	// At is the offset from local midnight to run at.
+	At time.Duration
+	// BackupDir receives the nightly backups; empty skips the backup.
+	BackupDir string
+	// KeepBackups is how many backups to keep in BackupDir; older ones are
+	// removed after a successful backup. 0 keeps them all.
+	KeepBackups int
+	// Recipients encrypt the backups with age; empty writes them in the
+	// clear.
+	Recipients []age.Recipient
+}
+
+// Notifier delivers the report to the admins.
+type Notifier func(report string) error
+
+// Job is the nightly maintenance job for one data directory.
+type Job struct {
+	cfg      Config
+	dataDir  string
+	sessions *state.SessionStore
+	events   *state.EventStore
+	notify   Notifier
+	now      func() time.Time
+}
+
+// New creates a maintenance job for dataDir that sends its reports with
+// notify.
+func New(cfg Config, dataDir string, sessions *state.SessionStore, events *state.EventStore, notify Notifier) *Job {
+	return &Job{cfg: cfg, dataDir: dataDir, sessions: sessions, events: events, notify: notify, now: time.Now}
+}
+
+// Report is what one run of the job did and found.
+type Report struct {
+	Started  time.Time
+	Duration time.Duration
+	// Removed lists the stale files cleaned up, relative to the data
+	// directory or, for old backups, as base names.
+	Removed   []string
+	Integrity *state.IntegrityReport
+	// Backup is the path of the backup written, or "" if none was.
+	Backup         string
+	BackupSize     int64
+	BackupSessions int
+	Usage          Usage
+	// Errors lists the steps that failed; the others still ran.
+	Errors []string
+}
+
+// Usage tallies the data directory and the activity of the last day.
+type Usage struct {
+	Sessions  int
+	Artifacts int
+	DataBytes int64
+	// Active counts the sessions with events in the last day, and Runs,
+	// Events, ToolCalls and Failures what those events hold.
+	Active    int
+	Runs      int
+	Events    int
+	ToolCalls int
+	Failures  int
+}
+
+// Start runs the job every day at the configured time until ctx is done.
+func (j *Job) Start(ctx context.Context) {
+	for {
+		wait := NextRun(j.now(), j.cfg.At).Sub(j.now())
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		report := j.Run(ctx)
+		slog.Info("maintenance finished", "duration", report.Duration, "problems", len(report.Integrity.Problems), "errors", len(report.Errors))
+		if err := j.notify(report.String()); err != nil {
+			slog.Warn("maintenance report delivery failed", "error", err)
+		}
+	}
+}
+
+// NextRun returns the first time after now that is at past local midnight.
+func NextRun(now time.Time, at time.Duration) time.Time {
+	h, m := int(at/time.Hour), int(at%time.Hour/time.Minute)
+	next := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, h, m, 0, 0, now.Location())
+	}
+	return next
+}
+
+// Run runs every step of the job once and returns its report.
+func (j *Job) Run(ctx context.Context) *Report {
+	r := &Report{Started: j.now(), Integrity: &state.IntegrityReport{}}
+	failed := func(step string, err error) {
+		slog.Warn("maintenance step failed", "step", step, "error", err)
+		r.Errors = append(r.Errors, step+": "+err.Error())
+	}
+
+	if err := j.sweep(r); err != nil {
+		failed("storage", err)
+	}
+	if integrity, err := state.VerifyIntegrity(j.dataDir); err != nil {
+		failed("integrity check", err)
+	} else {
+		r.Integrity = integrity
+	}
+	if j.cfg.BackupDir != "" {
+		if err := j.backup(ctx, r); err != nil {
+			failed("backup", err)
+		} else if err := j.rotate(r); err != nil {
+			failed("backup rotation", err)
+		}
+	}
+	if err := j.tally(ctx, r); err != nil {
+		failed("usage", err)
+	}
+	r.Duration = j.now().Sub(r.Started)
+	return r
+}
+
+// sweep removes stale temp files and measures the data directory.
+func (j *Job) sweep(r *Report) error {
+	cutoff := j.now().Add(-staleTempAge)
+	backups := filepath.Clean(j.cfg.BackupDir)
+	return filepath.WalkDir(j.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed since its parent was read
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if j.cfg.BackupDir != "" && path == backups {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed since the directory was read
+		}
+		if strings.HasSuffix(path, ".tmp") && info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("remove temp file: %w", err)
+			}
+			rel, _ := filepath.Rel(j.dataDir, path)
+			r.Removed = append(r.Removed, rel)
+			return nil
+		}
+		r.Usage.DataBytes += info.Size()
+		if filepath.Base(filepath.Dir(path)) == "artifacts" {
+			r.Usage.Artifacts++
+		}
+		return nil
+	})
+}
+
+// backup writes a backup of the whole data directory to BackupDir.
+func (j *Job) backup(ctx context.Context, r *Report) error {
+	if err := os.MkdirAll(j.cfg.BackupDir, 0o700); err != nil {
+		return fmt.Errorf("create backup dir: %w", err)
+	}
+	name := BackupPrefix + j.now().Format("20060102-150405") + ".tar.gz"
+	if len(j.cfg.Recipients) > 0 {
+		name += ".age"
+	}
+	path := filepath.Join(j.cfg.BackupDir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("create backup: %w", err)
+	}
+	manifest, err := backup.Create(ctx, f, j.dataDir, backup.Options{Recipients: j.cfg.Recipients})
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	r.Backup = path
+	r.BackupSessions = len(manifest.Sessions)
+	if info, err := os.Stat(path); err == nil {
+		r.BackupSize = info.Size()
+	}
+	return nil
+}
+
+// rotate removes the oldest backups beyond KeepBackups.
+func (j *Job) rotate(r *Report) error {
+	if j.cfg.KeepBackups <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(j.cfg.BackupDir)
+	if err != nil {
+		return fmt.Errorf("read backup dir: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), BackupPrefix) {
+			names = append(names, e.Name())
+		}
+	}
+	// Names sort by the time they were taken.
+	slices.Sort(names)
+	for len(names) > j.cfg.KeepBackups {
+		if err := os.Remove(filepath.Join(j.cfg.BackupDir, names[0])); err != nil {
+			return fmt.Errorf("remove old backup: %w", err)
+		}
+		r.Removed = append(r.Removed, names[0])
+		names = names[1:]
+	}
+	return nil
+}
+
+// tally counts sessions and the activity of the last day.
+func (j *Job) tally(ctx context.Context, r *Report) error {
+	sessions, err := j.sessions.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+	r.Usage.Sessions = len(sessions)
+	since := j.now().Add(-24 * time.Hour)
+	for _, sess := range sessions {
+		if sess.UpdatedAt.Before(since) {
+			continue
+		}
+		events, err := j.eventsSince(ctx, sess.SessionID, since)
+		if err != nil {
+			return fmt.Errorf("read session %s: %w", sess.SessionID, err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		r.Usage.Active++
+		runs := make(map[types.RunID]bool)
+		for _, ev := range events {
+			r.Usage.Events++
+			if ev.RunID != "" {
+				runs[ev.RunID] = true
+			}
+			switch ev.Type {
+			case "tool_call":
+				r.Usage.ToolCalls++
+			case "error":
+				r.Usage.Failures++
+			}
+		}
+		r.Usage.Runs += len(runs)
+	}
+	return nil
+}
+
+// eventsSince returns a session's events at or after since, reading back
+// from the end of its log in growing chunks.
+func (j *Job) eventsSince(ctx context.Context, id types.SessionID, since time.Time) ([]*types.Event, error) {
+	for limit := 256; ; limit *= 4 {
+		events, err := j.events.Tail(ctx, id, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) < limit || events[0].At.Before(since) {
+			i, _ := slices.BinarySearchFunc(events, since, func(ev *types.Event, t time.Time) int {
+				return ev.At.Compare(t)
+			})
+			return events[i:], nil
+		}
+	}
+}
+
+// String renders the report as a short message for the admins.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Nightly maintenance, %s (took %s)\n", r.Started.Format("2006-01-02 15:04"), r.Duration.Round(100*time.Millisecond))
+
+	u := r.Usage
+	fmt.Fprintf(&b, "Storage: %d sessions, %d artifacts, %s", u.Sessions, u.Artifacts, formatBytes(u.DataBytes))
+	if len(r.Removed) > 0 {
+		fmt.Fprintf(&b, "; removed %d stale files", len(r.Removed))
+	}
+	b.WriteString("\n")
+
+	switch problems := r.Integrity.Problems; len(problems) {
+	case 0:
+		b.WriteString("Integrity: OK\n")
+	default:
+		fmt.Fprintf(&b, "Integrity: %d problems (a restart repairs what is safe to repair)\n", len(problems))
+		for _, p := range problems[:min(len(problems), 3)] {
+			b.WriteString("- " + p + "\n")
+		}
+		if len(problems) > 3 {
+			fmt.Fprintf(&b, "- and %d more\n", len(problems)-3)
+		}
+	}
+
+	if r.Backup != "" {
+		fmt.Fprintf(&b, "Backup: %s (%d sessions, %s)\n", filepath.Base(r.Backup), r.BackupSessions, formatBytes(r.BackupSize))
+	}
+	fmt.Fprintf(&b, "Last 24h: %d runs in %d sessions, %d events, %d tool calls, %d errors\n",
+		u.Runs, u.Active, u.Events, u.ToolCalls, u.Failures)
+	for _, e := range r.Errors {
+		b.WriteString("Failed: " + e + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatBytes renders a size in B, KB, MB or GB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
@@ -0,0 +1,118 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestNextRun(t *testing.T) {
+	at := 3*time.Hour + 30*time.Minute
+	cases := []struct{ now, want string }{
+		{"2026-03-01 01:00", "2026-03-01 03:30"},
+		{"2026-03-01 03:30", "2026-03-02 03:30"},
+		{"2026-03-31 23:59", "2026-04-01 03:30"},
+	}
+	for _, c := range cases {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", c.now, time.Local)
+		if got := NextRun(now, at).Format("2006-01-02 15:04"); got != c.want {
+			t.Errorf("NextRun(%s) = %s, want %s", c.now, got, c.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	now := time.Now()
+
+	sid, err := sessions.ResolveOrCreate(ctx, "telegram:1:1", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artID, err := artifacts.Put(ctx, sid, "r2", "bash", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, _ := json.Marshal(map[string]string{"tool": "bash", "call_id": "c1"})
+	result, _ := json.Marshal(map[string]string{"tool": "bash", "call_id": "c1", "artifact_id": string(artID)})
+	text, _ := json.Marshal(map[string]string{"text": "hi"})
+	for _, ev := range []*types.Event{
+		{Type: "user_message", RunID: "r1", At: now.Add(-48 * time.Hour), Payload: text},
+		{Type: "user_message", RunID: "r2", At: now.Add(-time.Hour), Payload: text},
+		{Type: "tool_call", RunID: "r2", At: now.Add(-time.Hour), Payload: call},
+		{Type: "tool_result", RunID: "r2", At: now.Add(-time.Hour), Payload: result},
+		{Type: "assistant_message", RunID: "r2", At: now.Add(-time.Hour), Payload: text},
+	} {
+		ev.ID = types.NewEventID()
+		ev.SessionID = sid
+		if err := events.Append(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stale := filepath.Join(dir, "tasks.json.tmp")
+	fresh := filepath.Join(dir, "macros.json.tmp")
+	os.WriteFile(stale, []byte("{"), 0o644)
+	os.WriteFile(fresh, []byte("{"), 0o644)
+	old := now.Add(-2 * time.Hour)
+	os.Chtimes(stale, old, old)
+
+	backups := filepath.Join(dir, "backups")
+	os.MkdirAll(backups, 0o700)
+	for _, name := range []string{BackupPrefix + "20260101-033000.tar.gz", BackupPrefix + "20260102-033000.tar.gz"} {
+		os.WriteFile(filepath.Join(backups, name), []byte("old"), 0o600)
+	}
+
+	var sent string
+	job := New(Config{BackupDir: backups, KeepBackups: 2}, dir, sessions, events, func(report string) error {
+		sent = report
+		return nil
+	})
+	r := job.Run(ctx)
+
+	if len(r.Errors) > 0 {
+		t.Fatalf("errors: %v", r.Errors)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("expected the stale temp file to be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("expected the fresh temp file to be kept")
+	}
+	if r.Backup == "" || r.BackupSessions != 1 {
+		t.Errorf("unexpected backup %q with %d sessions", r.Backup, r.BackupSessions)
+	}
+	if _, err := os.Stat(filepath.Join(backups, BackupPrefix+"20260101-033000.tar.gz")); !os.IsNotExist(err) {
+		t.Error("expected the oldest backup to be rotated out")
+	}
+	if len(r.Removed) != 2 {
+		t.Errorf("removed = %v", r.Removed)
+	}
+	if len(r.Integrity.Problems) != 0 {
+		t.Errorf("problems = %v", r.Integrity.Problems)
+	}
+	want := Usage{Sessions: 1, Artifacts: 1, Active: 1, Runs: 1, Events: 4, ToolCalls: 1}
+	got := r.Usage
+	got.DataBytes = 0
+	if got != want || r.Usage.DataBytes == 0 {
+		t.Errorf("usage = %+v, want %+v", r.Usage, want)
+	}
+
+	job.notify(r.String())
+	for _, line := range []string{"Storage: 1 sessions, 1 artifacts", "Integrity: OK", "Backup: " + filepath.Base(r.Backup), "Last 24h: 1 runs in 1 sessions, 4 events, 1 tool calls, 0 errors"} {
+		if !strings.Contains(sent, line) {
+			t.Errorf("report lacks %q:\n%s", line, sent)
+		}
+	}
+}
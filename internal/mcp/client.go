@@ -0,0 +1,239 @@
+// Package mcp is a Model Context Protocol client. It connects to MCP
+// servers over stdio or HTTP with server-sent events, lists their tools and
+// calls them, so they can be registered with the runtime like built-in
+// tools.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProtocolVersion is the MCP revision the client speaks.
+const ProtocolVersion = "2024-11-05"
+
+// connectTimeout bounds starting or dialing a server and the handshake.
+const connectTimeout = 30 * time.Second
+
+// ErrClosed is returned by calls on a connection that has ended.
+var ErrClosed = errors.New("mcp: connection closed")
+
+// ServerConfig says how to reach an MCP server. Exactly one of Command and
+// URL is set.
+type ServerConfig struct {
+	// Command starts a server that speaks over its stdin and stdout.
+	Command []string
+	// Env is added to the environment Command runs in.
+	Env map[string]string
+	// URL is the SSE endpoint of a remote server.
+	URL string
+	// Headers are sent with every request to URL.
+	Headers map[string]string
+	// Tools, if set, limits the tools offered to these, by the server's
+	// own names.
+	Tools []string
+}
+
+// transport carries JSON-RPC messages to and from a server.
+type transport interface {
+	// send writes one message.
+	send(ctx context.Context, msg []byte) error
+	// messages delivers the server's messages and is closed when the
+	// connection ends.
+	messages() <-chan []byte
+	close() error
+}
+
+// Client is a connection to one MCP server.
+type Client struct {
+	name string
+	cfg  ServerConfig
+	t    transport
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[int64]chan *message
+	done    chan struct{}
+}
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Connect starts or dials the server named name and completes the MCP
+// handshake.
+func Connect(ctx context.Context, name string, cfg ServerConfig) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	var (
+		t   transport
+		err error
+	)
+	switch {
+	case len(cfg.Command) > 0 && cfg.URL != "":
+		return nil, fmt.Errorf("mcp server %s: set command or url, not both", name)
+	case len(cfg.Command) > 0:
+		t, err = startStdio(name, cfg.Command, cfg.Env)
+	case cfg.URL != "":
+		t, err = dialSSE(ctx, cfg.URL, cfg.Headers)
+	default:
+		return nil, fmt.Errorf("mcp server %s: command or url is required", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", name, err)
+	}
+
+	c := newClient(name, cfg, t)
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp server %s: %w", name, err)
+	}
+	return c, nil
+}
+
+func newClient(name string, cfg ServerConfig, t transport) *Client {
+	c := &Client{name: name, cfg: cfg, t: t, pending: make(map[int64]chan *message), done: make(chan struct{})}
+	go c.read()
+	return c
+}
+
+// Name returns the name the server was configured under.
+func (c *Client) Name() string { return c.name }
+
+// Close ends the connection, stopping a stdio server.
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "gopherclaw", "version": "1.0"},
+	}
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	slog.Info("mcp server connected", "server", c.name, "name", result.ServerInfo.Name, "version", result.ServerInfo.Version, "protocol", result.ProtocolVersion)
+	return c.notify(ctx, "notifications/initialized")
+}
+
+// call sends a request and decodes its result into result.
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	id := c.nextID.Add(1)
+	reply := make(chan *message, 1)
+	c.mu.Lock()
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encode params: %w", err)
+	}
+	msg, _ := json.Marshal(message{JSONRPC: "2.0", ID: json.RawMessage(strconv.FormatInt(id, 10)), Method: method, Params: raw})
+	if err := c.t.send(ctx, msg); err != nil {
+		return err
+	}
+	select {
+	case resp := <-reply:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification, which gets no response.
+func (c *Client) notify(ctx context.Context, method string) error {
+	msg, _ := json.Marshal(message{JSONRPC: "2.0", Method: method})
+	return c.t.send(ctx, msg)
+}
+
+// read dispatches the server's messages until the connection ends:
+// responses to their callers, and requests to a minimal handler.
+func (c *Client) read() {
+	defer close(c.done)
+	for raw := range c.t.messages() {
+		var msg message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			slog.Warn("mcp: unparseable message", "server", c.name, "error", err)
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			go c.answer(&msg)
+		case msg.Method != "":
+			slog.Debug("mcp notification", "server", c.name, "method", msg.Method)
+		default:
+			id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			reply := c.pending[id]
+			c.mu.Unlock()
+			if reply != nil {
+				reply <- &msg
+			}
+		}
+	}
+}
+
+// answer responds to a request from the server. The client offers no
+// capabilities, so only pings are served.
+func (c *Client) answer(req *message) {
+	resp := message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage(`{}`)
+	} else {
+		resp.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	raw, _ := json.Marshal(resp)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := c.t.send(ctx, raw); err != nil {
+		slog.Warn("mcp: answer server request", "server", c.name, "method", req.Method, "error", err)
+	}
+}
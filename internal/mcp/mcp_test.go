@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// handle answers a request the way a small MCP server with an "echo" and a
+// "fail" tool would. Notifications get no answer.
+func handle(raw []byte) []byte {
+	var req message
+	json.Unmarshal(raw, &req)
+	if req.ID == nil {
+		return nil
+	}
+	resp := message{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		resp.Result = json.RawMessage(`{"protocolVersion":"2024-11-05","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"0.1"}}`)
+	case "tools/list":
+		var p struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(req.Params, &p)
+		if p.Cursor == "" {
+			resp.Result = json.RawMessage(`{"tools":[{"name":"echo","description":"Echoes","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}],"nextCursor":"2"}`)
+		} else {
+			resp.Result = json.RawMessage(`{"tools":[{"name":"fail"},{"name":"hidden.tool"}]}`)
+		}
+	case "tools/call":
+		var p struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &p)
+		if p.Name == "fail" {
+			resp.Result = json.RawMessage(`{"content":[{"type":"text","text":"it broke"}],"isError":true}`)
+			break
+		}
+		text, _ := json.Marshal("echo: " + p.Arguments["text"])
+		resp.Result = json.RawMessage(fmt.Sprintf(`{"content":[{"type":"text","text":%s},{"type":"image","data":"","mimeType":"image/png"}]}`, text))
+	default:
+		resp.Error = &rpcError{Code: -32601, Message: "method not found"}
+	}
+	out, _ := json.Marshal(resp)
+	return out
+}
+
+// TestMain doubles as a stdio MCP server when the stdio test starts the
+// test binary with MCP_TEST_SERVER set.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_SERVER") == "1" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if out := handle(scanner.Bytes()); out != nil {
+				os.Stdout.Write(append(out, '\n'))
+			}
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func exercise(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+	tools, err := c.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name())
+	}
+	if got := strings.Join(names, ","); got != "fake__echo,fake__fail" {
+		t.Fatalf("tools = %s", got)
+	}
+	if !strings.Contains(string(tools[0].Parameters()), `"text"`) || tools[0].Description() != "Echoes" {
+		t.Errorf("unexpected echo tool %s: %s", tools[0].Description(), tools[0].Parameters())
+	}
+
+	result, err := tools[0].Execute(ctx, json.RawMessage(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "echo: hi\n[image content: image/png]" {
+		t.Errorf("result = %q", result)
+	}
+	if _, err := tools[1].Execute(ctx, nil); err == nil || err.Error() != "it broke" {
+		t.Errorf("expected the tool's error, got %v", err)
+	}
+}
+
+func TestStdio(t *testing.T) {
+	c, err := Connect(context.Background(), "fake", ServerConfig{
+		Command: []string{os.Args[0]},
+		Env:     map[string]string{"MCP_TEST_SERVER": "1"},
+		Tools:   []string{"echo", "fail"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	exercise(t, c)
+}
+
+func TestSSE(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		stream chan []byte
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		out := make(chan []byte, 16)
+		mu.Lock()
+		stream = out
+		mu.Unlock()
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case msg := <-out:
+				fmt.Fprintf(w, ": keep-alive\n\nevent: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("POST /messages", func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		json.NewDecoder(r.Body).Decode(&raw)
+		if out := handle(raw); out != nil {
+			mu.Lock()
+			stream <- out
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c, err := Connect(context.Background(), "fake", ServerConfig{
+		URL:     server.URL + "/sse",
+		Headers: map[string]string{"Authorization": "Bearer t"},
+		Tools:   []string{"echo", "fail"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	exercise(t, c)
+}
+
+func TestToolName(t *testing.T) {
+	if got := ToolName("home assistant", "lights.on"); got != "home_assistant__lights_on" {
+		t.Errorf("got %q", got)
+	}
+	if got := ToolName("s", strings.Repeat("x", 100)); len(got) != maxToolName {
+		t.Errorf("got %d characters", len(got))
+	}
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxToolName is the longest tool name LLM APIs accept.
+const maxToolName = 64
+
+// invalidNameChars matches what LLM APIs don't accept in tool names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolInfo describes a tool a server offers.
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// ListTools returns all of the server's tools.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("list tools: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls one of the server's tools and renders its result as text.
+// A result the server flags as an error is returned as an error.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource *struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, item := range result.Content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil && item.Resource.Text != "":
+			parts = append(parts, item.Resource.Text)
+		case item.Type == "resource" && item.Resource != nil:
+			parts = append(parts, "["+item.Resource.URI+"]")
+		default:
+			// Images and audio can't be passed on as text.
+			parts = append(parts, fmt.Sprintf("[%s content: %s]", item.Type, item.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// Tools lists the server's tools, limited to ServerConfig.Tools if set,
+// wrapped for the runtime's registry under namespaced names.
+func (c *Client) Tools(ctx context.Context) ([]*Tool, error) {
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tools []*Tool
+	for _, info := range infos {
+		if len(c.cfg.Tools) > 0 && !slices.Contains(c.cfg.Tools, info.Name) {
+			continue
+		}
+		tools = append(tools, &Tool{client: c, info: info, name: ToolName(c.name, info.Name)})
+	}
+	return tools, nil
+}
+
+// ToolName namespaces a server's tool as "<server>__<tool>", replacing
+// characters LLM APIs reject and cutting it to their length limit.
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server+"__"+tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// Tool is a server's tool as the runtime sees it.
+type Tool struct {
+	client *Client
+	info   ToolInfo
+	name   string
+}
+
+func (t *Tool) Name() string { return t.name }
+
+func (t *Tool) Description() string {
+	if t.info.Description == "" {
+		return fmt.Sprintf("%s (from the %s MCP server)", t.info.Name, t.client.name)
+	}
+	return t.info.Description
+}
+
+func (t *Tool) Parameters() json.RawMessage {
+	if len(t.info.InputSchema) == 0 {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return t.info.InputSchema
+}
+
+func (t *Tool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	return t.client.CallTool(ctx, t.info.Name, args)
+}
@@ -0,0 +1,266 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMessageSize caps one message from a server.
+const maxMessageSize = 16 << 20
+
+// streamTransport exchanges newline-delimited messages over a pair of
+// streams, such as a stdio server's stdout and stdin.
+type streamTransport struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	msgs    chan []byte
+	closeFn func() error
+	once    sync.Once
+	err     error
+}
+
+func newStreamTransport(r io.Reader, w io.WriteCloser, closeFn func() error) *streamTransport {
+	t := &streamTransport{w: w, msgs: make(chan []byte), closeFn: closeFn}
+	go func() {
+		defer close(t.msgs)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxMessageSize)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				t.msgs <- bytes.Clone(line)
+			}
+		}
+	}()
+	return t
+}
+
+func (t *streamTransport) send(_ context.Context, msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.w.Write(append(msg, '\n')); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (t *streamTransport) messages() <-chan []byte { return t.msgs }
+
+func (t *streamTransport) close() error {
+	t.once.Do(func() {
+		t.w.Close()
+		if t.closeFn != nil {
+			t.err = t.closeFn()
+		}
+	})
+	return t.err
+}
+
+// startStdio starts a server process and talks to it over its stdin and
+// stdout. Its stderr is logged.
+func startStdio(name string, command []string, env map[string]string) (transport, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &logWriter{server: name}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", command[0], err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	// Closing stdin asks the server to exit; one that doesn't is killed.
+	return newStreamTransport(stdout, stdin, func() error {
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		return nil
+	}), nil
+}
+
+// logWriter logs a stdio server's stderr line by line.
+type logWriter struct {
+	server string
+	buf    []byte
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		slog.Info("mcp server stderr", "server", l.server, "line", string(l.buf[:i]))
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// sseTransport talks to a remote server over HTTP: the server's messages
+// arrive as "message" events on a long-lived event stream, and the client
+// POSTs its own to the endpoint announced in the stream's first event.
+type sseTransport struct {
+	client   *http.Client
+	headers  map[string]string
+	endpoint string
+	msgs     chan []byte
+	cancel   context.CancelFunc
+}
+
+// dialSSE opens the event stream at rawURL and waits for its endpoint
+// event.
+func dialSSE(ctx context.Context, rawURL string, headers map[string]string) (transport, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	t := &sseTransport{client: &http.Client{}, headers: headers, msgs: make(chan []byte), cancel: cancel}
+
+	type dialed struct {
+		resp *http.Response
+		err  error
+	}
+	result := make(chan dialed, 1)
+	go func() {
+		resp, err := t.client.Do(req)
+		result <- dialed{resp, err}
+	}()
+	var resp *http.Response
+	select {
+	case d := <-result:
+		if d.err != nil {
+			cancel()
+			return nil, fmt.Errorf("open event stream: %w", d.err)
+		}
+		resp = d.resp
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("open event stream: status %d", resp.StatusCode)
+	}
+
+	endpoint := make(chan string, 1)
+	go t.readEvents(resp.Body, base, endpoint)
+	select {
+	case e, ok := <-endpoint:
+		if !ok {
+			cancel()
+			return nil, errors.New("event stream ended before announcing an endpoint")
+		}
+		t.endpoint = e
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+	return t, nil
+}
+
+// readEvents parses the event stream, passing the endpoint event's URL to
+// endpoint and message events to t.msgs.
+func (t *sseTransport) readEvents(body io.ReadCloser, base *url.URL, endpoint chan<- string) {
+	defer body.Close()
+	defer close(t.msgs)
+	announced := false
+	defer func() {
+		if !announced {
+			close(endpoint)
+		}
+	}()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxMessageSize)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			payload := strings.Join(data, "\n")
+			switch {
+			case event == "endpoint" && !announced:
+				if ref, err := base.Parse(payload); err == nil {
+					endpoint <- ref.String()
+					announced = true
+				}
+			case (event == "" || event == "message") && payload != "":
+				t.msgs <- []byte(payload)
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment, e.g. a keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (t *sseTransport) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post message: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post message: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *sseTransport) messages() <-chan []byte { return t.msgs }
+
+func (t *sseTransport) close() error {
+	t.cancel()
+	return nil
+}
@@ -0,0 +1,405 @@
+// Package pipeline runs named pipelines: deterministic steps that gather a
+// task's inputs before the LLM call, and steps that act on its response
+// afterwards, so scheduled jobs spend model calls only on the judgement in
+// the middle.
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Pipeline is a prompt with deterministic steps around it. It is defined
+// in YAML, one file per pipeline.
+type Pipeline struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Steps run before the LLM call; their outputs are available to the
+	// prompt as {{.steps.<name>}}.
+	Steps []Step `yaml:"steps,omitempty"`
+	// Prompt is a template rendered after Steps and sent to the model.
+	Prompt string `yaml:"prompt"`
+	// Response is "text" (default) or "json". A JSON response is parsed so
+	// post steps and Output can use its fields as {{.response.<field>}}.
+	Response string `yaml:"response,omitempty"`
+	// Post steps run after the LLM call, with the response available.
+	Post []Step `yaml:"post,omitempty"`
+	// Output is a template for the message delivered to the task's
+	// session; empty delivers the model's response as is.
+	Output string `yaml:"output,omitempty"`
+}
+
+// Step is one deterministic action. Exactly one of Run, Fetch or Template
+// is set; every field is a template over the pipeline data.
+type Step struct {
+	Name string `yaml:"name,omitempty"`
+	// Run is a bash command; its stdout is the step's output.
+	Run string `yaml:"run,omitempty"`
+	// Fetch is a URL; the response body is the step's output.
+	Fetch  string `yaml:"fetch,omitempty"`
+	Method string `yaml:"method,omitempty"`
+	// Body is the request body for Fetch or stdin for Run.
+	Body string `yaml:"body,omitempty"`
+	// Template is rendered as the step's output.
+	Template string `yaml:"template,omitempty"`
+	// When skips the step unless it renders to something other than "",
+	// "false" or "0".
+	When string `yaml:"when,omitempty"`
+	// Timeout is a Go duration (default 60s) for Run and Fetch.
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// Response formats.
+const (
+	ResponseText = "text"
+	ResponseJSON = "json"
+)
+
+const (
+	defaultStepTimeout = 60 * time.Second
+	// maxStepOutput caps what a run or fetch step keeps of its output.
+	maxStepOutput = 1 << 20
+)
+
+// Validate checks the pipeline's structure and templates.
+func (p *Pipeline) Validate() error {
+	if strings.TrimSpace(p.Prompt) == "" {
+		return fmt.Errorf("pipeline %s: prompt is required", p.Name)
+	}
+	switch p.Response {
+	case "", ResponseText, ResponseJSON:
+	default:
+		return fmt.Errorf("pipeline %s: unknown response format %q", p.Name, p.Response)
+	}
+	texts := []string{p.Prompt, p.Output}
+	names := make(map[string]bool)
+	for i, step := range append(append([]Step{}, p.Steps...), p.Post...) {
+		set := 0
+		for _, action := range []string{step.Run, step.Fetch, step.Template} {
+			if action != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("pipeline %s: step %d needs exactly one of run, fetch or template", p.Name, i+1)
+		}
+		if step.Name != "" {
+			if names[step.Name] {
+				return fmt.Errorf("pipeline %s: duplicate step name %q", p.Name, step.Name)
+			}
+			names[step.Name] = true
+		}
+		if step.Timeout != "" {
+			if _, err := time.ParseDuration(step.Timeout); err != nil {
+				return fmt.Errorf("pipeline %s: step %s: parse timeout: %w", p.Name, step.label(i), err)
+			}
+		}
+		texts = append(texts, step.Run, step.Fetch, step.Body, step.Template, step.When)
+	}
+	for _, text := range texts {
+		if _, err := parse(text); err != nil {
+			return fmt.Errorf("pipeline %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Prepare runs the pre-LLM steps and renders the prompt, without calling
+// the model. It is used to check a pipeline by hand.
+func (p *Pipeline) Prepare(ctx context.Context, task string) (string, error) {
+	e := p.newExecution(task)
+	return e.prepare(ctx)
+}
+
+// Execute runs the whole pipeline for task: steps, the prompt through complete,
+// then post steps. It returns the message to deliver, which is empty when
+// the model chose not to reply.
+func (p *Pipeline) Execute(ctx context.Context, task string, complete func(prompt string) (string, error)) (string, error) {
+	e := p.newExecution(task)
+	prompt, err := e.prepare(ctx)
+	if err != nil {
+		return "", err
+	}
+	text, err := complete(prompt)
+	if err != nil || strings.TrimSpace(text) == "" {
+		return "", err
+	}
+
+	e.data["text"] = text
+	e.data["response"] = text
+	if p.Response == ResponseJSON {
+		var parsed any
+		if err := json.Unmarshal([]byte(llm.StripFence(text)), &parsed); err != nil {
+			return "", fmt.Errorf("pipeline %s: response is not JSON: %w", p.Name, err)
+		}
+		e.data["response"] = parsed
+	}
+	if err := e.runSteps(ctx, p.Post); err != nil {
+		return "", err
+	}
+	if p.Output == "" {
+		return text, nil
+	}
+	return e.render("output", p.Output)
+}
+
+// execution is the state of one pipeline run.
+type execution struct {
+	p     *Pipeline
+	data  map[string]any
+	steps map[string]any
+}
+
+func (p *Pipeline) newExecution(task string) *execution {
+	steps := make(map[string]any)
+	return &execution{p: p, steps: steps, data: map[string]any{
+		"task":  task,
+		"date":  time.Now().Format(time.DateOnly),
+		"steps": steps,
+	}}
+}
+
+func (e *execution) prepare(ctx context.Context) (string, error) {
+	if err := e.runSteps(ctx, e.p.Steps); err != nil {
+		return "", err
+	}
+	return e.render("prompt", e.p.Prompt)
+}
+
+// runSteps runs steps in order, recording each named step's output.
+func (e *execution) runSteps(ctx context.Context, steps []Step) error {
+	for i, step := range steps {
+		out, ran, err := e.runStep(ctx, step)
+		if err != nil {
+			return fmt.Errorf("pipeline %s: step %s: %w", e.p.Name, step.label(i), err)
+		}
+		if ran && step.Name != "" {
+			e.steps[step.Name] = out
+		}
+	}
+	return nil
+}
+
+// runStep runs one step, reporting whether its When condition let it run.
+func (e *execution) runStep(ctx context.Context, step Step) (string, bool, error) {
+	if step.When != "" {
+		cond, err := e.render("when", step.When)
+		if err != nil {
+			return "", false, err
+		}
+		switch strings.TrimSpace(cond) {
+		case "", "false", "0", "<no value>":
+			return "", false, nil
+		}
+	}
+	if step.Template != "" {
+		out, err := e.render("template", step.Template)
+		return out, true, err
+	}
+
+	timeout := defaultStepTimeout
+	if step.Timeout != "" {
+		timeout, _ = time.ParseDuration(step.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	body, err := e.render("body", step.Body)
+	if err != nil {
+		return "", true, err
+	}
+	if step.Run != "" {
+		command, err := e.render("run", step.Run)
+		if err != nil {
+			return "", true, err
+		}
+		out, err := runCommand(ctx, command, body)
+		return out, true, err
+	}
+	url, err := e.render("fetch", step.Fetch)
+	if err != nil {
+		return "", true, err
+	}
+	out, err := fetch(ctx, step.Method, strings.TrimSpace(url), body)
+	return out, true, err
+}
+
+func (e *execution) render(name, text string) (string, error) {
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, e.data); err != nil {
+		return "", fmt.Errorf("render %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// label names a step in errors.
+func (s Step) label(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+// funcs are the template helpers available in pipeline templates.
+var funcs = template.FuncMap{
+	// json parses a step's JSON output so its fields can be used.
+	"json": func(s string) (any, error) {
+		var v any
+		if err := json.Unmarshal([]byte(llm.StripFence(s)), &v); err != nil {
+			return nil, fmt.Errorf("parse JSON: %w", err)
+		}
+		return v, nil
+	},
+	"toJSON": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"trim": strings.TrimSpace,
+}
+
+func parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("pipeline").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// runCommand runs a bash command with stdin and returns its stdout.
+func runCommand(ctx context.Context, command, stdin string) (string, error) {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("run command: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return truncate(stdout.String()), nil
+}
+
+var httpClient = &http.Client{}
+
+// fetch requests url and returns the response body. Non-2xx statuses are
+// errors.
+func fetch(ctx context.Context, method, url, body string) (string, error) {
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, reader)
+	if err != nil {
+		return "", fmt.Errorf("build request: %w", err)
+	}
+	if body != "" && json.Valid([]byte(body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxStepOutput))
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("fetch %s: status %d", url, resp.StatusCode)
+	}
+	return string(data), nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxStepOutput {
+		return s[:maxStepOutput]
+	}
+	return s
+}
+
+// Store loads pipelines from <dir>/<name>.yaml (or .yml).
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store for the pipelines in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Get loads and validates the named pipeline.
+func (s *Store) Get(name string) (*Pipeline, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid pipeline name %q", name)
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		data, err := os.ReadFile(filepath.Join(s.dir, name+ext))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read pipeline: %w", err)
+		}
+		return Parse(name, data)
+	}
+	return nil, fmt.Errorf("pipeline not found: %s", name)
+}
+
+// List returns the names of the pipelines in the store, sorted.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("read pipelines dir: %w", err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ext))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Parse decodes and validates a pipeline definition. The name defaults to
+// the given one, normally the file name.
+func Parse(name string, data []byte) (*Pipeline, error) {
+	var p Pipeline
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse pipeline %s: %w", name, err)
+	}
+	if p.Name == "" {
+		p.Name = name
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"open": 3, "title": "Nightly build broken"}`)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+
+	def := fmt.Sprintf(`
+description: triage open issues
+steps:
+  - name: issues
+    fetch: "%s/issues"
+  - name: count
+    run: "echo -n {{(json .steps.issues).open}}"
+  - name: skipped
+    when: "{{eq .steps.count \"0\"}}"
+    run: "exit 1"
+prompt: |
+  {{.task}}: {{.steps.count}} open, latest "{{(json .steps.issues).title}}"
+response: json
+post:
+  - run: "cat > %s"
+    body: "{{.response.summary}}"
+    when: "{{.response.urgent}}"
+output: "{{if .response.urgent}}URGENT: {{end}}{{.response.summary}}"
+`, srv.URL, notes)
+	p, err := Parse("triage", []byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent string
+	out, err := p.Execute(context.Background(), "issues", func(prompt string) (string, error) {
+		sent = prompt
+		return "```json\n{\"urgent\": true, \"summary\": \"build is red\"}\n```", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "issues: 3 open, latest \"Nightly build broken\"\n"; sent != want {
+		t.Errorf("prompt:\ngot  %q\nwant %q", sent, want)
+	}
+	if out != "URGENT: build is red" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if data, err := os.ReadFile(notes); err != nil || string(data) != "build is red" {
+		t.Errorf("post step did not run: %q, %v", data, err)
+	}
+
+	// An empty reply skips post steps and delivers nothing.
+	os.Remove(notes)
+	out, err = p.Execute(context.Background(), "issues", func(string) (string, error) { return "", nil })
+	if err != nil || out != "" {
+		t.Errorf("expected no output for an empty reply, got %q, %v", out, err)
+	}
+	if _, err := os.Stat(notes); !os.IsNotExist(err) {
+		t.Error("post step ran for an empty reply")
+	}
+
+	// A failing step stops the pipeline before the model is called.
+	p.Steps[1].Run = "echo oops >&2; exit 2"
+	_, err = p.Execute(context.Background(), "issues", func(string) (string, error) {
+		t.Error("model called after a failed step")
+		return "", nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "step count") || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected the failed step in the error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, def := range map[string]string{
+		"no prompt":       "steps: [{run: date}]",
+		"two actions":     "prompt: hi\nsteps: [{run: date, fetch: 'http://x'}]",
+		"no action":       "prompt: hi\nsteps: [{name: empty}]",
+		"duplicate names": "prompt: hi\nsteps: [{name: a, run: date}]\npost: [{name: a, run: date}]",
+		"bad template":    "prompt: '{{.task'",
+		"bad response":    "prompt: hi\nresponse: xml",
+		"unknown field":   "prompt: hi\nprompts: hi",
+	} {
+		if _, err := Parse("p", []byte(def)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	if names, err := store.List(); err != nil || len(names) != 0 {
+		t.Fatalf("expected no pipelines, got %v, %v", names, err)
+	}
+	os.WriteFile(filepath.Join(dir, "daily.yaml"), []byte("prompt: summarize {{.date}}"), 0o644)
+	os.WriteFile(filepath.Join(dir, "weekly.yml"), []byte("name: weekly-review\nprompt: review"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a pipeline"), 0o644)
+
+	names, err := store.List()
+	if err != nil || strings.Join(names, ",") != "daily,weekly" {
+		t.Fatalf("unexpected pipelines %v, %v", names, err)
+	}
+	p, err := store.Get("weekly")
+	if err != nil || p.Name != "weekly-review" {
+		t.Fatalf("unexpected pipeline %+v, %v", p, err)
+	}
+	if p, err := store.Get("daily"); err != nil || p.Name != "daily" {
+		t.Fatalf("expected the name to default to the file name, got %+v, %v", p, err)
+	}
+	for _, name := range []string{"missing", "../daily", ""} {
+		if _, err := store.Get(name); err == nil {
+			t.Errorf("Get(%q): expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Package reminder parses the natural-language times reminders are set
+// with and computes when recurring reminders fire next.
+package reminder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repeat rules. A rule of the form "every <duration>" (e.g. "every 2h0m0s")
+// repeats at a fixed interval.
+const (
+	Daily    = "daily"
+	Weekdays = "weekdays"
+	Weekly   = "weekly"
+	Monthly  = "monthly"
+)
+
+// minInterval is the shortest fixed repeat interval.
+const minInterval = time.Minute
+
+// defaultHour is the time of day used when an expression names a day but
+// no time, e.g. "tomorrow" or "every monday".
+const defaultHour = 9
+
+var (
+	durationRe = regexp.MustCompile(`^(\d+)\s*(seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w)$`)
+	clockRe    = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?\s*(am|pm)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// Parse reads a reminder time relative to now and returns when it first
+// fires and its repeat rule ("" for a one-off). It understands:
+//
+//	in 20 minutes, in 1h30m, in 3 days
+//	at 9, 9am, 17:30, today at 5pm, tomorrow, tomorrow at 8:15
+//	monday, next friday at 15:00
+//	2026-10-20, 2026-10-20 14:00, RFC 3339 timestamps
+//	every day at 8am, daily at 8, every weekday at 9, every monday at 10,
+//	every week, every month, every 2 hours
+//
+// Times of day are in now's location; a time already past today means
+// tomorrow.
+func Parse(expr string, now time.Time) (time.Time, string, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(expr), " "))
+	s = strings.TrimSuffix(s, ".")
+	if s == "" {
+		return time.Time{}, "", fmt.Errorf("empty time")
+	}
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(s)); err == nil {
+		return t, "", nil
+	}
+
+	if rest, ok := cutWord(s, "every"); ok {
+		return parseRecurring(rest, now)
+	}
+	for _, prefix := range []string{Daily, Weekdays, Weekly, Monthly} {
+		if rest, ok := cutWord(s, prefix); ok {
+			return parseRecurring(map[string]string{Daily: "day", Weekdays: "weekday", Weekly: "week", Monthly: "month"}[prefix]+" "+rest, now)
+		}
+	}
+
+	if rest, ok := cutWord(s, "in"); ok {
+		d, err := parseDuration(rest)
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		return now.Add(d), "", nil
+	}
+	t, err := parseDay(s, now)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return t, "", nil
+}
+
+// parseRecurring reads what follows "every".
+func parseRecurring(s string, now time.Time) (time.Time, string, error) {
+	s = strings.TrimSpace(s)
+	if d, err := parseDuration(s); err == nil {
+		if d < minInterval {
+			return time.Time{}, "", fmt.Errorf("repeat interval must be at least %s", minInterval)
+		}
+		return now.Add(d), "every " + d.String(), nil
+	}
+	// "every hour" and "every minute" are one unit; whole days are
+	// handled below so they keep a time of day.
+	if d, err := parseDuration("1 " + s); err == nil && d >= minInterval && d < 24*time.Hour {
+		return now.Add(d), "every " + d.String(), nil
+	}
+
+	unit, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	switch {
+	case unit == "day" || unit == "morning" || unit == "evening":
+		if rest == "" {
+			rest = map[string]string{"day": "9:00", "morning": "8:00", "evening": "19:00"}[unit]
+		}
+		t, err := nextClock(rest, now, func(time.Time) bool { return true })
+		return t, Daily, err
+	case unit == "weekday" || unit == "weekdays":
+		t, err := nextClock(orDefault(rest), now, isWeekday)
+		return t, Weekdays, err
+	case unit == "week":
+		t, err := nextClock(orDefault(rest), now, func(time.Time) bool { return true })
+		return t, Weekly, err
+	case unit == "month":
+		t, err := nextClock(orDefault(rest), now, func(time.Time) bool { return true })
+		return t, Monthly, err
+	}
+	if day, ok := weekdays[strings.TrimSuffix(unit, "s")]; ok {
+		t, err := nextClock(orDefault(rest), now, func(t time.Time) bool { return t.Weekday() == day })
+		return t, Weekly, err
+	}
+	return time.Time{}, "", fmt.Errorf("cannot understand repeat %q", "every "+s)
+}
+
+// parseDay reads a one-off day and time of day.
+func parseDay(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t.Add(defaultHour * time.Hour), nil
+	}
+
+	day, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	switch day {
+	case "today", "tonight":
+		if rest == "" && day == "tonight" {
+			rest = "20:00"
+		}
+		if rest == "" {
+			return time.Time{}, fmt.Errorf("today needs a time, e.g. \"today at 17:00\"")
+		}
+		hour, min, err := parseClock(rest)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := atClock(now, hour, min)
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("%s has already passed", rest)
+		}
+		return t, nil
+	case "tomorrow":
+		hour, min, err := parseClock(orDefault(rest))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return atClock(now.AddDate(0, 0, 1), hour, min), nil
+	case "next", "on", "this":
+		day, rest, _ = strings.Cut(rest, " ")
+		rest = strings.TrimSpace(rest)
+	}
+	if wd, ok := weekdays[day]; ok {
+		hour, min, err := parseClock(orDefault(rest))
+		if err != nil {
+			return time.Time{}, err
+		}
+		// The next such weekday after today.
+		days := (int(wd)-int(now.Weekday())+6)%7 + 1
+		return atClock(now.AddDate(0, 0, days), hour, min), nil
+	}
+	return nextClock(s, now, func(time.Time) bool { return true })
+}
+
+// nextClock returns the first time after now at the clock time in s on a
+// day accepted by ok.
+func nextClock(s string, now time.Time, ok func(time.Time) bool) (time.Time, error) {
+	hour, min, err := parseClock(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := 0; i < 8; i++ {
+		t := atClock(now.AddDate(0, 0, i), hour, min)
+		if t.After(now) && ok(t) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no matching day for %q", s)
+}
+
+// parseClock reads a time of day like "9", "9am", "at 9:30 pm" or "17:30".
+func parseClock(s string) (hour, min int, err error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "at "))
+	switch s {
+	case "noon", "midday":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+	m := clockRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("cannot understand time %q", s)
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		min, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" && (hour < 1 || hour > 12) {
+		return 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+	switch m[3] {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || min > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour, min, nil
+}
+
+// parseDuration reads a duration like "20 minutes", "1h30m", "2 hours
+// 15 minutes" or "3 days".
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(strings.ReplaceAll(s, " ", "")); err == nil && d > 0 {
+		return d, nil
+	}
+	if s == "half an hour" {
+		return 30 * time.Minute, nil
+	}
+	s = strings.ReplaceAll(strings.ReplaceAll(s, ",", " "), " and ", " ")
+
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if f == "a" || f == "an" {
+			fields[i] = "1"
+		}
+	}
+	var total time.Duration
+	for i := 0; i < len(fields); i++ {
+		part := fields[i]
+		if i+1 < len(fields) && isNumber(part) {
+			part += fields[i+1]
+			i++
+		}
+		m := durationRe.FindStringSubmatch(part)
+		if m == nil {
+			return 0, fmt.Errorf("cannot understand duration %q", s)
+		}
+		n, _ := strconv.Atoi(m[1])
+		var unit time.Duration
+		switch m[2][0] {
+		case 's':
+			unit = time.Second
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		total += time.Duration(n) * unit
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("cannot understand duration %q", s)
+	}
+	return total, nil
+}
+
+// Next returns the first time after now that a reminder with the given
+// repeat rule, last due at prev, fires again. Occurrences missed while the
+// daemon was down are skipped.
+func Next(repeat string, prev, now time.Time) (time.Time, error) {
+	step, err := stepper(repeat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := step(prev)
+	for !next.After(now) {
+		next = step(next)
+	}
+	return next, nil
+}
+
+// ValidRepeat reports whether repeat is a rule Next understands.
+func ValidRepeat(repeat string) bool {
+	_, err := stepper(repeat)
+	return err == nil
+}
+
+// stepper returns the function that advances a repeat rule by one
+// occurrence.
+func stepper(repeat string) (func(time.Time) time.Time, error) {
+	switch repeat {
+	case Daily:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, nil
+	case Weekdays:
+		return func(t time.Time) time.Time {
+			t = t.AddDate(0, 0, 1)
+			for !isWeekday(t) {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t
+		}, nil
+	case Weekly:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, nil
+	case Monthly:
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, nil
+	}
+	if rest, ok := strings.CutPrefix(repeat, "every "); ok {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < minInterval {
+			return nil, fmt.Errorf("invalid repeat %q", repeat)
+		}
+		return func(t time.Time) time.Time { return t.Add(d) }, nil
+	}
+	return nil, fmt.Errorf("invalid repeat %q", repeat)
+}
+
+// Describe returns a repeat rule in words, e.g. "every weekday".
+func Describe(repeat string) string {
+	switch repeat {
+	case "":
+		return "once"
+	case Daily:
+		return "every day"
+	case Weekdays:
+		return "every weekday"
+	case Weekly:
+		return "every week"
+	case Monthly:
+		return "every month"
+	}
+	return repeat
+}
+
+func atClock(day time.Time, hour, min int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, day.Location())
+}
+
+func isWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+func orDefault(clock string) string {
+	if clock == "" {
+		return strconv.Itoa(defaultHour) + ":00"
+	}
+	return clock
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// cutWord removes a leading word from s.
+func cutWord(s, word string) (string, bool) {
+	if s == word {
+		return "", true
+	}
+	rest, ok := strings.CutPrefix(s, word+" ")
+	return rest, ok
+}
@@ -0,0 +1,95 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	// Wednesday 2026-10-14 10:30.
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, loc)
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, loc) }
+
+	tests := []struct {
+		expr   string
+		want   time.Time
+		repeat string
+	}{
+		{"in 20 minutes", now.Add(20 * time.Minute), ""},
+		{"in 1h30m", now.Add(90 * time.Minute), ""},
+		{"in 2 hours 15 minutes", now.Add(135 * time.Minute), ""},
+		{"in an hour", now.Add(time.Hour), ""},
+		{"in half an hour", now.Add(30 * time.Minute), ""},
+		{"in 3 days", now.Add(72 * time.Hour), ""},
+		{"at 17:30", at(14, 17, 30), ""},
+		{"9am", at(15, 9, 0), ""},
+		{"at 9:15 pm", at(14, 21, 15), ""},
+		{"today at 5pm", at(14, 17, 0), ""},
+		{"tonight", at(14, 20, 0), ""},
+		{"tomorrow", at(15, 9, 0), ""},
+		{"Tomorrow at 8:15", at(15, 8, 15), ""},
+		{"friday", at(16, 9, 0), ""},
+		{"next wednesday at 15:00", at(21, 15, 0), ""},
+		{"on monday at noon", at(19, 12, 0), ""},
+		{"2026-10-20 14:00", at(20, 14, 0), ""},
+		{"2026-10-20", at(20, 9, 0), ""},
+		{"2026-10-20T14:00:00Z", time.Date(2026, 10, 20, 14, 0, 0, 0, time.UTC), ""},
+		{"every day at 8am", at(15, 8, 0), Daily},
+		{"daily at 18", at(14, 18, 0), Daily},
+		{"every morning", at(15, 8, 0), Daily},
+		{"every weekday at 9", at(15, 9, 0), Weekdays},
+		{"every monday at 10", at(19, 10, 0), Weekly},
+		{"every week", at(15, 9, 0), Weekly},
+		{"every month at 12:00", at(14, 12, 0), Monthly},
+		{"every 2 hours", now.Add(2 * time.Hour), "every 2h0m0s"},
+		{"every hour", now.Add(time.Hour), "every 1h0m0s"},
+	}
+	for _, tt := range tests {
+		got, repeat, err := Parse(tt.expr, now)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if !got.Equal(tt.want) || repeat != tt.repeat {
+			t.Errorf("Parse(%q) = %v, %q; want %v, %q", tt.expr, got, repeat, tt.want, tt.repeat)
+		}
+	}
+
+	for _, bad := range []string{"", "someday", "at 25:00", "13pm", "today at 9", "every 10 seconds", "in 2 months"} {
+		if _, _, err := Parse(bad, now); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Friday 2026-10-16 09:00.
+	prev := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		repeat string
+		now    time.Time
+		want   time.Time
+	}{
+		{Daily, prev, prev.AddDate(0, 0, 1)},
+		{Weekdays, prev, prev.AddDate(0, 0, 3)},
+		{Weekly, prev, prev.AddDate(0, 0, 7)},
+		{Monthly, prev, prev.AddDate(0, 1, 0)},
+		{"every 2h0m0s", prev, prev.Add(2 * time.Hour)},
+		// Occurrences missed while the daemon was down are skipped.
+		{Daily, prev.AddDate(0, 0, 3).Add(time.Hour), prev.AddDate(0, 0, 4)},
+	}
+	for _, tt := range tests {
+		got, err := Next(tt.repeat, prev, tt.now)
+		if err != nil {
+			t.Errorf("Next(%q): %v", tt.repeat, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.repeat, got, tt.want)
+		}
+	}
+	if _, err := Next("fortnightly", prev, prev); err == nil {
+		t.Error("expected an unknown repeat to fail")
+	}
+}
@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// DefaultApprovalTimeout is how long a call waits for approval when no
+// other timeout is set. A waiting run holds its queue slot, so the wait is
+// always bounded.
+const DefaultApprovalTimeout = 10 * time.Minute
+
+// SetApprovalTools makes calls to the named tools wait for the user's
+// approval before they run. A call not answered within timeout is
+// denied; zero uses DefaultApprovalTimeout.
+func (rt *Runtime) SetApprovalTools(tools []string, timeout time.Duration) {
+	rt.approvalTools = make(map[string]bool, len(tools))
+	for _, name := range tools {
+		rt.approvalTools[name] = true
+	}
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	rt.approvalTimeout = timeout
+}
+
+// approve asks the user to approve the round's calls that need it, in
+// order. At the first call they don't approve it stops asking: that call
+// and the round's other unsettled calls are refused with an error wrapping
+// gateway.ErrToolDenied, and the tool is returned so the run can end
+// there. An empty tool means every call may run.
+func (rt *Runtime) approve(ctx context.Context, run *gateway.Run, act *activity, calls []*toolRun, tcs []llm.ToolCall) (denied string, err error) {
+	for i, call := range calls {
+		if call.planned || call.err != nil || !rt.approvalTools[call.name] {
+			continue
+		}
+		reason, err := rt.askApproval(ctx, run, act, call, tcs[i])
+		if err != nil {
+			return "", err
+		}
+		if reason == "" {
+			continue
+		}
+		call.err = fmt.Errorf("%w: %s", gateway.ErrToolDenied, reason)
+		for _, other := range calls {
+			if !other.planned && other.err == nil {
+				other.err = fmt.Errorf("%w: not run, since the user did not approve another call", gateway.ErrToolDenied)
+			}
+		}
+		for _, c := range calls {
+			if c.err != nil {
+				c.result = "error: " + c.err.Error()
+			}
+		}
+		return tcs[i].Function.Name, nil
+	}
+	return "", nil
+}
+
+// askApproval records an approval_request event for a call, asks the
+// user through the run's OnApproval, and records their answer as an
+// approval event. It returns why the call may not run, or "" if it may.
+func (rt *Runtime) askApproval(ctx context.Context, run *gateway.Run, act *activity, call *toolRun, tc llm.ToolCall) (reason string, err error) {
+	fields := map[string]any{"tool": tc.Function.Name, "call_id": tc.ID, "arguments": tc.Function.Arguments}
+	payload, _ := json.Marshal(fields)
+	req := &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "approval_request",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}
+	if err := rt.events.Append(ctx, req); err != nil {
+		return "", fmt.Errorf("record approval request: %w", err)
+	}
+
+	if run.OnApproval == nil {
+		reason = "approval can't be asked for in this conversation"
+	} else {
+		act.set("waiting for your approval")
+		askCtx, cancel := context.WithTimeout(ctx, cmp.Or(rt.approvalTimeout, DefaultApprovalTimeout))
+		defer cancel()
+		approved, err := run.OnApproval(askCtx, gateway.ApprovalRequest{
+			ID:        req.ID,
+			Tool:      tc.Function.Name,
+			CallID:    tc.ID,
+			Arguments: call.args,
+		})
+		switch {
+		case ctx.Err() != nil:
+			return "", context.Cause(ctx)
+		case errors.Is(err, context.DeadlineExceeded):
+			reason = fmt.Sprintf("the user did not answer within %s", cmp.Or(rt.approvalTimeout, DefaultApprovalTimeout))
+		case errors.Is(err, gateway.ErrApprovalUnavailable):
+			reason = err.Error()
+		case err != nil:
+			return "", fmt.Errorf("ask for approval: %w", err)
+		case !approved:
+			reason = "the user did not approve the call"
+		}
+	}
+	slog.InfoContext(ctx, "tool call approval", "tool", call.name, "approved", reason == "", "reason", reason)
+
+	fields = map[string]any{"tool": tc.Function.Name, "call_id": tc.ID, "approved": reason == ""}
+	if reason != "" {
+		fields["reason"] = reason
+	}
+	payload, _ = json.Marshal(fields)
+	if err := rt.events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "approval",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}); err != nil {
+		return "", fmt.Errorf("record approval: %w", err)
+	}
+	return reason, nil
+}
+
+// finishDenied ends a run whose tool call the user did not approve,
+// without asking the model for more.
+func (rt *Runtime) finishDenied(ctx context.Context, run *gateway.Run, session *types.SessionIndex, res *gateway.RunResult, tool string) error {
+	text := i18n.T(session.Language, "run_not_approved", tool)
+	payload, _ := json.Marshal(map[string]any{"text": text})
+	if err := rt.events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "assistant_message",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}); err != nil {
+		return fmt.Errorf("record assistant message: %w", err)
+	}
+	res.Text = text
+	run.Finish(res)
+	return nil
+}
@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestApproval(t *testing.T) {
+	cases := []struct {
+		name     string
+		answer   *bool // nil leaves OnApproval unset
+		response string
+		kinds    string
+		result   string
+		err      error // returned by OnApproval
+	}{
+		{"approved", ptr(true), "ran it",
+			"user_message,approval_request,approval,tool_call,tool_result,tool_call,tool_result,assistant_message", "hi", nil},
+		{"denied", ptr(false), "OK, I didn't run echo and stopped there.",
+			"user_message,approval_request,approval,tool_call,tool_result,tool_call,tool_result,assistant_message", "did not approve the call", nil},
+		{"no channel", nil, "OK, I didn't run echo and stopped there.",
+			"user_message,approval_request,approval,tool_call,tool_result,tool_call,tool_result,assistant_message", "can't be asked for", nil},
+		{"too long", ptr(false), "OK, I didn't run echo and stopped there.",
+			"user_message,approval_request,approval,tool_call,tool_result,tool_call,tool_result,assistant_message", "too long to show", fmt.Errorf("%w: too long to show", gateway.ErrApprovalUnavailable)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			slow := &pacedTool{name: "slow"}
+			provider := &mockProvider{responses: []*llm.Response{
+				{ToolCalls: []llm.ToolCall{
+					{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "echo", Arguments: json.RawMessage(`{"text":"hi"}`)}},
+					{ID: "tc2", Type: "function", Function: llm.FunctionCall{Name: "slow", Arguments: json.RawMessage(`{"n":"1"}`)}},
+				}},
+				{Content: "ran it"},
+			}}
+			rt, events, sid := newTimeoutRuntime(t, provider, &echoTool{}, slow)
+			rt.SetApprovalTools([]string{"echo"}, 0)
+
+			var asked []gateway.ApprovalRequest
+			var response string
+			run := &gateway.Run{
+				ID:         types.NewRunID(),
+				SessionID:  sid,
+				Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+				OnComplete: func(r string) { response = r },
+			}
+			if c.answer != nil {
+				run.OnApproval = func(_ context.Context, req gateway.ApprovalRequest) (bool, error) {
+					asked = append(asked, req)
+					return *c.answer, c.err
+				}
+			}
+			if err := rt.ProcessRun(run); err != nil {
+				t.Fatal(err)
+			}
+			if response != c.response {
+				t.Errorf("response = %q, want %q", response, c.response)
+			}
+			if c.answer != nil && (len(asked) != 1 || asked[0].Tool != "echo" || asked[0].CallID != "tc1") {
+				t.Errorf("asked = %+v", asked)
+			}
+			denied := c.answer == nil || !*c.answer
+			if denied == (len(slow.started) == 1) {
+				t.Errorf("slow ran %d times", len(slow.started))
+			}
+
+			evts, err := events.Tail(context.Background(), sid, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			var first struct {
+				Result string `json:"result"`
+				Denied bool   `json:"denied"`
+			}
+			for _, ev := range evts {
+				kinds = append(kinds, ev.Type)
+				if ev.Type == "tool_result" && first.Result == "" {
+					json.Unmarshal(ev.Payload, &first)
+				}
+			}
+			if got := strings.Join(kinds, ","); got != c.kinds {
+				t.Errorf("events = %s", got)
+			}
+			if !strings.Contains(first.Result, c.result) || first.Denied != denied {
+				t.Errorf("first result = %+v", first)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
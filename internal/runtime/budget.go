@@ -0,0 +1,21 @@
+package runtime
+
+import (
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+)
+
+// SetBudget makes every LLM call first check the spend budgets, failing
+// with a *usage.ExceededError once one is spent, and counts each call's
+// usage against them. Nil turns the checks off.
+func (rt *Runtime) SetBudget(b *usage.Budget) {
+	rt.budget = b
+}
+
+// allowCall returns an error if the session may not make another LLM call.
+func (rt *Runtime) allowCall(sessionID types.SessionID) error {
+	if rt.budget == nil {
+		return nil
+	}
+	return rt.budget.Allow(sessionID)
+}
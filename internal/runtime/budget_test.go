@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestBudgetRefusesCalls(t *testing.T) {
+	reply := &llm.Response{Content: "ok", Model: "gpt-4o", Usage: llm.Usage{InputTokens: 100, OutputTokens: 10}}
+	provider := &mockProvider{responses: []*llm.Response{reply, reply, reply}}
+	rt, events, sid := newTimeoutRuntime(t, provider)
+	resets := state.NewBudgetStore(filepath.Join(t.TempDir(), "budget.json"))
+	rt.SetBudget(usage.NewBudget(usage.Limits{}, usage.Limits{DailyTokens: 150}, nil, resets))
+
+	process := func() error {
+		return rt.ProcessRun(&gateway.Run{
+			ID:        types.NewRunID(),
+			SessionID: sid,
+			Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "hi"},
+		})
+	}
+	// The second run starts under the limit, which its reply then passes.
+	for i := 0; i < 2; i++ {
+		if err := process(); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	err := process()
+	var exceeded *usage.ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != usage.ScopeSession || exceeded.Period != usage.Daily || exceeded.Limit != "150 tokens" {
+		t.Fatalf("expected a session budget error, got %v", err)
+	}
+	if provider.callCount != 2 {
+		t.Errorf("expected no LLM call over budget, got %d calls", provider.callCount)
+	}
+	all, _ := events.Tail(context.Background(), sid, 100)
+	if last := all[len(all)-1]; last.Type != "error" || all[len(all)-2].Type != "user_message" {
+		t.Errorf("expected the message and an error event, got %s then %s", all[len(all)-2].Type, last.Type)
+	}
+
+	if err := resets.Reset(sid, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := process(); err != nil {
+		t.Errorf("expected a reset to allow calls again, got %v", err)
+	}
+}
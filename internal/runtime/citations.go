@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"net/url"
+	"strings"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// cite records the sources a reply drew on in its event fields and returns
+// the reply to deliver, with a "Sources:" footer when citations are on. The
+// stored text stays without the footer so later prompts don't repeat it.
+// Channels with the raw verbosity style get no footer.
+func (rt *Runtime) cite(session *types.SessionIndex, text string, sources []types.Source, fields map[string]any) string {
+	if !rt.citations {
+		return text
+	}
+	cited := citedSources(text, sources)
+	if len(cited) == 0 {
+		return text
+	}
+	fields["sources"] = cited
+	if v, ok := rt.engine.VerbosityFor(session.SessionKey); ok && v.Style == ctxengine.VerbosityRaw {
+		return text
+	}
+	var sb strings.Builder
+	sb.WriteString(text)
+	sb.WriteString("\n\n")
+	sb.WriteString(i18n.T(session.Language, "sources"))
+	for _, s := range cited {
+		sb.WriteString("\n- ")
+		sb.WriteString(s.URL)
+	}
+	return sb.String()
+}
+
+// citedSources returns the sources a reply actually used: pages a tool
+// fetched, and search results whose URL or host the reply mentions. Each
+// URL is listed once, in the order the tools found them.
+func citedSources(text string, sources []types.Source) []types.Source {
+	lower := strings.ToLower(text)
+	seen := make(map[string]bool)
+	var cited []types.Source
+	for _, s := range sources {
+		if s.URL == "" || seen[s.URL] {
+			continue
+		}
+		if s.Fetched || strings.Contains(text, s.URL) || mentionsHost(lower, s.URL) {
+			seen[s.URL] = true
+			cited = append(cited, s)
+		}
+	}
+	return cited
+}
+
+// mentionsHost reports whether lowercased text names the URL's host, with
+// or without a leading "www.".
+func mentionsHost(text, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	return strings.Contains(text, host)
+}
@@ -0,0 +1,86 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// mustConfirm reports whether a tool call has to be planned rather than
+// run. Unknown and disabled tools are left to fail as usual.
+func (rt *Runtime) mustConfirm(session *types.SessionIndex, tool string) bool {
+	if _, ok := rt.registry.Get(tool); !ok || !gateway.ToolEnabled(session, tool) {
+		return false
+	}
+	return gateway.NeedsConfirmation(session, tool)
+}
+
+// plannedResult is the tool result the model sees for a planned call.
+func plannedResult(tool string) string {
+	return fmt.Sprintf("Not executed: this conversation requires confirmation before %s runs. "+
+		"The call has been recorded. Explain exactly what it would do and why, then ask the user "+
+		"to confirm (/confirm runs all recorded calls, /cancel drops them). Do not claim it has run.", tool)
+}
+
+// runPending executes the session's planned tool calls for a confirmation
+// run, recording each as a tool_call and tool_result so the model can
+// report the outcome. It returns the sources the calls drew on.
+func (rt *Runtime) runPending(ctx context.Context, run *gateway.Run, act *activity, res *gateway.RunResult) ([]types.Source, error) {
+	calls, err := gateway.TakePending(ctx, rt.sessions, run.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("load planned tool calls: %w", err)
+	}
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	session, err := rt.sessions.Get(ctx, run.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+
+	slog.InfoContext(ctx, "running confirmed tool calls", "calls", len(calls))
+	var events []*types.Event
+	var sources []types.Source
+	for _, call := range calls {
+		callID := "confirmed_" + string(types.NewEventID())
+		exposed := rt.registry.ExposedName(call.Tool)
+		tcPayload, _ := json.Marshal(map[string]any{
+			"tool":      exposed,
+			"call_id":   callID,
+			"arguments": call.Arguments,
+			"confirmed": true,
+		})
+		events = append(events, &types.Event{
+			ID:        types.NewEventID(),
+			SessionID: run.SessionID,
+			RunID:     run.ID,
+			Type:      "tool_call",
+			Source:    "runtime",
+			At:        time.Now(),
+			Payload:   tcPayload,
+		})
+
+		act.set(toolActivity(call.Tool))
+		result, found, err := rt.execTool(ctx, session, call.Tool, call.Arguments)
+		trPayload := map[string]any{"tool": exposed, "call_id": callID}
+		if len(found) > 0 {
+			trPayload["sources"] = found
+			sources = append(sources, found...)
+		}
+		timedOut := markFailure(trPayload, err)
+		events = append(events, rt.toolResultEvent(ctx, run, act, trPayload, result))
+		if timedOut {
+			events = append(events, timeoutEvent(run, map[string]any{"tool": exposed, "call_id": callID}, rt.toolTimeoutFor(call.Tool), result))
+		}
+		recordTool(res, trPayload, result)
+	}
+	if err := rt.events.AppendBatch(ctx, events); err != nil {
+		return nil, fmt.Errorf("record confirmed tool calls: %w", err)
+	}
+	return sources, nil
+}
@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// foldResultLimit caps how much of each tool result the summarizer sees.
+const foldResultLimit = 500
+
+const foldPrompt = `You maintain a running summary of a conversation between a user and an assistant, for the assistant's own reference once the older messages below are no longer shown to it. ` +
+	`Merge the existing summary, if any, with the messages below into one summary of at most 300 words. Keep the user's goals and preferences, decisions made, facts and results found by tools, open questions and in-progress work. Write only the summary.`
+
+// foldHistory records a conversation_summary event covering the events
+// that no longer fit the prompt, merged with the summary they follow. It
+// reports whether a summary was recorded.
+func (rt *Runtime) foldHistory(ctx context.Context, run *gateway.Run, session *types.SessionIndex, events []*types.Event, toolNames []string) (bool, error) {
+	prior, older := rt.engine.Overflow(session, events, toolNames)
+	if len(older) == 0 {
+		return false, nil
+	}
+
+	var input strings.Builder
+	if prior != "" {
+		fmt.Fprintf(&input, "Existing summary:\n%s\n\nMessages:\n\n", prior)
+	}
+	for _, ev := range older {
+		if line := transcriptLine(ev); line != "" {
+			input.WriteString(line)
+			input.WriteString("\n\n")
+		}
+	}
+
+	if err := rt.allowCall(run.SessionID); err != nil {
+		return false, fmt.Errorf("summarize history: %w", err)
+	}
+	resp, err := rt.provider(run, usage.History).Complete(ctx, []llm.Message{
+		{Role: "system", Content: foldPrompt},
+		{Role: "user", Content: input.String()},
+	}, nil)
+	if err != nil {
+		return false, fmt.Errorf("summarize history: %w", err)
+	}
+	rt.recordUsage(ctx, run.SessionID, run.ID, usage.History, resp)
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return false, fmt.Errorf("summarize history: empty summary")
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"text":        summary,
+		"through_seq": older[len(older)-1].Seq,
+		"events":      len(older),
+	})
+	if err := rt.events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "conversation_summary",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}); err != nil {
+		return false, fmt.Errorf("record conversation summary: %w", err)
+	}
+	return true, nil
+}
+
+// transcriptLine renders an event for the history summarizer, or "" for
+// events it doesn't need.
+func transcriptLine(ev *types.Event) string {
+	var p struct {
+		Text    string `json:"text"`
+		Message string `json:"message"`
+		Tool    string `json:"tool"`
+		Result  string `json:"result"`
+	}
+	if err := json.Unmarshal(ev.Payload, &p); err != nil {
+		return ""
+	}
+	switch ev.Type {
+	case "user_message":
+		return "User: " + p.Text
+	case "assistant_message":
+		return "Assistant: " + p.Text
+	case "session_summary":
+		return "Summary of an earlier session: " + p.Text
+	case "error":
+		return "Error: " + p.Message
+	case "tool_result":
+		return fmt.Sprintf("Tool %s returned: %s", p.Tool, truncate(p.Result, foldResultLimit))
+	}
+	return ""
+}
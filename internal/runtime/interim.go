@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+)
+
+// activity tracks what a run is doing so a delayed interim message can say
+// something more useful than "please wait".
+type activity struct {
+	mu      sync.Mutex
+	current string
+}
+
+func (a *activity) set(what string) {
+	a.mu.Lock()
+	a.current = what
+	a.mu.Unlock()
+}
+
+func (a *activity) get() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current
+}
+
+// toolActivity describes a tool call in the words of an interim message.
+func toolActivity(tool string) string {
+	switch {
+	case tool == "brave_search":
+		return "running web searches"
+	case tool == "read_url":
+		return "reading web pages"
+	case tool == "bash":
+		return "running commands"
+	case strings.HasPrefix(tool, "memory_"):
+		return "updating my notes"
+	default:
+		return "using " + tool
+	}
+}
+
+// reportProgress passes the run's current step to its OnProgress callback.
+func reportProgress(run *gateway.Run, round int, tool string) {
+	if run.OnProgress == nil {
+		return
+	}
+	status := "thinking"
+	if tool != "" {
+		status = toolActivity(tool)
+	}
+	run.OnProgress(gateway.Progress{Round: round, Tool: tool, Status: status})
+}
+
+// interimMessage is sent once when an interactive run outlives the
+// interim threshold.
+func interimMessage(what string) string {
+	if what == "" {
+		return "Still working on it…"
+	}
+	return "Still working on it — " + what + "…"
+}
+
+// startInterim arranges for notify to receive one interim message if the
+// run is still going after d. The returned stop function cancels it and,
+// if the message is being sent, waits for it so it can't arrive after the
+// final response.
+func startInterim(d time.Duration, act *activity, notify func(string)) (stop func()) {
+	if d <= 0 || notify == nil {
+		return func() {}
+	}
+	var mu sync.Mutex
+	stopped := false
+	timer := time.AfterFunc(d, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			notify(interimMessage(act.get()))
+		}
+	})
+	return func() {
+		timer.Stop()
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,40 @@
+package runtime
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/i18n"
+)
+
+// detectWindow is how many events into a session language detection keeps
+// trying. Past that, a session without a preference keeps the model's
+// default of mirroring the user.
+const detectWindow = 6
+
+// detectLanguage sets the session's preferred language from the user's
+// message when none is set and the conversation has just started. Task and
+// heartbeat prompts are skipped since they are written by the operator, not
+// the person reading the replies.
+func (rt *Runtime) detectLanguage(ctx context.Context, run *gateway.Run) {
+	if run.Event.Source == "task" || run.Event.Source == "heartbeat" || run.Event.Text == "" {
+		return
+	}
+	session, err := rt.sessions.Get(ctx, run.SessionID)
+	if err != nil || session.Language != "" {
+		return
+	}
+	if n, err := rt.events.Count(ctx, run.SessionID); err != nil || n > detectWindow {
+		return
+	}
+	lang := i18n.Detect(run.Event.Text)
+	if lang == "" {
+		return
+	}
+	if err := gateway.SetLanguage(ctx, rt.sessions, run.SessionID, lang); err != nil {
+		slog.WarnContext(ctx, "set detected language failed", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "detected session language", "language", lang)
+}
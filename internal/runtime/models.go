@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Call describes an LLM call the runtime is about to make, for a
+// ModelSelector to pick its provider by.
+type Call struct {
+	// Purpose is what the call is for, one of the usage purposes such as
+	// usage.Reply or usage.Summarize.
+	Purpose string
+	// Source is the source of the message the run answers, such as
+	// "telegram", "task" or "heartbeat"; empty outside a run.
+	Source string
+	// Channel is the session key's channel, such as "telegram" or "http".
+	Channel string
+}
+
+// ModelSelector picks the provider, and so the model, for each LLM call.
+type ModelSelector interface {
+	Select(call Call) llm.Provider
+}
+
+type singleModel struct{ provider llm.Provider }
+
+func (s singleModel) Select(Call) llm.Provider { return s.provider }
+
+// SingleModel returns a ModelSelector that sends every call to provider.
+func SingleModel(provider llm.Provider) ModelSelector {
+	return singleModel{provider}
+}
+
+// Route sends the LLM calls it matches to the named profile. Empty fields
+// match anything, so a route with only a Profile matches every call.
+type Route struct {
+	Purpose string
+	Source  string
+	Channel string
+	Profile string
+}
+
+func (r Route) matches(call Call) bool {
+	return (r.Purpose == "" || r.Purpose == call.Purpose) &&
+		(r.Source == "" || r.Source == call.Source) &&
+		(r.Channel == "" || r.Channel == call.Channel)
+}
+
+// Router is a ModelSelector that sends each call to the profile of the
+// first route matching it, or to its default provider if none does.
+type Router struct {
+	fallback llm.Provider
+	profiles map[string]llm.Provider
+	routes   []Route
+}
+
+// purposes are the call purposes a route may match.
+var purposes = map[string]bool{
+	usage.Reply: true, usage.Shorten: true, usage.Summarize: true, usage.History: true, usage.Seed: true,
+}
+
+// NewRouter creates a Router over the named profiles. It fails if a route
+// names a profile that doesn't exist or a purpose the runtime never uses.
+func NewRouter(fallback llm.Provider, profiles map[string]llm.Provider, routes []Route) (*Router, error) {
+	for i, r := range routes {
+		if _, ok := profiles[r.Profile]; !ok {
+			return nil, fmt.Errorf("route %d: unknown profile %q", i+1, r.Profile)
+		}
+		if r.Purpose != "" && !purposes[r.Purpose] {
+			return nil, fmt.Errorf("route %d: unknown purpose %q (want reply, shorten, summarize, history or seed)", i+1, r.Purpose)
+		}
+	}
+	return &Router{fallback: fallback, profiles: profiles, routes: routes}, nil
+}
+
+// Select returns the provider of the first route matching call.
+func (r *Router) Select(call Call) llm.Provider {
+	for _, route := range r.routes {
+		if route.matches(call) {
+			return r.profiles[route.Profile]
+		}
+	}
+	return r.fallback
+}
+
+// provider picks the provider for a call of the run made for purpose.
+func (rt *Runtime) provider(run *gateway.Run, purpose string) llm.Provider {
+	call := Call{Purpose: purpose}
+	if run.Event != nil {
+		call.Source = run.Event.Source
+		call.Channel = run.Event.SessionKey.Channel()
+	}
+	return rt.models.Select(call)
+}
+
+// sessionProvider picks the provider for a call made for purpose outside a
+// run, by the session's channel.
+func (rt *Runtime) sessionProvider(ctx context.Context, sessionID types.SessionID, purpose string) llm.Provider {
+	call := Call{Purpose: purpose}
+	if sess, err := rt.sessions.Get(ctx, sessionID); err == nil {
+		call.Channel = sess.SessionKey.Channel()
+	}
+	return rt.models.Select(call)
+}
@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestRouter(t *testing.T) {
+	def, fast, smart := &mockProvider{}, &mockProvider{}, &mockProvider{}
+	profiles := map[string]llm.Provider{"fast": fast, "smart": smart}
+	router, err := NewRouter(def, profiles, []Route{
+		{Purpose: usage.Summarize, Profile: "fast"},
+		{Source: "task", Profile: "fast"},
+		{Channel: "telegram", Purpose: usage.Reply, Profile: "smart"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		call Call
+		want llm.Provider
+	}{
+		{Call{Purpose: usage.Summarize, Source: "telegram", Channel: "telegram"}, fast},
+		{Call{Purpose: usage.Reply, Source: "task", Channel: "telegram"}, fast},
+		{Call{Purpose: usage.Reply, Source: "telegram", Channel: "telegram"}, smart},
+		{Call{Purpose: usage.Shorten, Source: "telegram", Channel: "telegram"}, def},
+		{Call{Purpose: usage.Reply, Source: "http", Channel: "http"}, def},
+	} {
+		if got := router.Select(tc.call); got != tc.want {
+			t.Errorf("Select(%+v) picked the wrong provider", tc.call)
+		}
+	}
+
+	if _, err := NewRouter(def, profiles, []Route{{Profile: "huge"}}); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+	if _, err := NewRouter(def, profiles, []Route{{Purpose: "chat", Profile: "fast"}}); err == nil {
+		t.Error("expected an error for an unknown purpose")
+	}
+}
+
+func TestProcessRunRoutesModel(t *testing.T) {
+	def := &mockProvider{responses: []*llm.Response{{Content: "default"}}}
+	fast := &mockProvider{responses: []*llm.Response{{Content: "fast"}}}
+	router, err := NewRouter(def, map[string]llm.Provider{"fast": fast}, []Route{{Source: "task", Profile: "fast"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, _, sid := newTimeoutRuntime(t, nil)
+	rt.models = router
+
+	for _, source := range []string{"task", "telegram"} {
+		var reply string
+		if err := rt.ProcessRun(&gateway.Run{
+			ID:         types.NewRunID(),
+			SessionID:  sid,
+			Event:      &types.InboundEvent{Source: source, SessionKey: "test:user1", Text: "hi"},
+			OnComplete: func(text string) { reply = text },
+		}); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"task": "fast", "telegram": "default"}[source]
+		if reply != want {
+			t.Errorf("%s run replied %q, want %q", source, reply, want)
+		}
+	}
+}
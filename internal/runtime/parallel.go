@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// toolRun is one tool call of a round on its way through execution.
+type toolRun struct {
+	// name is the registered name; the call itself keeps the one the model
+	// used.
+	name string
+	args json.RawMessage
+	// event is the call's tool_call event.
+	event *types.Event
+	// planned calls await confirmation and aren't executed.
+	planned bool
+
+	// result, found and err are the outcome; calls refused before they
+	// ran start out with result and err set.
+	result string
+	found  []types.Source
+	err    error
+}
+
+// SetToolParallelism bounds how many of a round's tool calls run at once.
+// Calls to mutating tools still run one after another, in the order the
+// model made them. 1 or less runs every call in turn.
+func (rt *Runtime) SetToolParallelism(n int) {
+	rt.toolParallelism = n
+}
+
+// runTools executes a round's calls that are neither planned nor refused,
+// up to the tool parallelism at a time, and fills in their results. Calls
+// start in order, each reporting its progress as it does; results are left
+// for the caller to record in call order.
+func (rt *Runtime) runTools(ctx context.Context, run *gateway.Run, act *activity, session *types.SessionIndex, round int, calls []*toolRun) {
+	slots := make(chan struct{}, max(rt.toolParallelism, 1))
+	var wg sync.WaitGroup
+	// prev is closed when the previous mutating call is done.
+	var prev chan struct{}
+	for _, call := range calls {
+		if call.planned || call.err != nil {
+			continue
+		}
+		slots <- struct{}{}
+		act.set(toolActivity(call.name))
+		if call.name != NoReplyTool {
+			reportProgress(run, round, call.name)
+		}
+		var after, done chan struct{}
+		if gateway.IsMutatingTool(call.name) {
+			after, done = prev, make(chan struct{})
+			prev = done
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if done != nil {
+				defer close(done)
+			}
+			if after != nil {
+				<-after
+			}
+			call.result, call.found, call.err = rt.execTool(ctx, session, call.name, call.args)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,134 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// pacedTool takes a while and records how many calls overlap and the order
+// they started in.
+type pacedTool struct {
+	name  string
+	delay time.Duration
+
+	mu      sync.Mutex
+	running int
+	peak    int
+	started []string
+}
+
+func (s *pacedTool) Name() string        { return s.name }
+func (s *pacedTool) Description() string { return "Takes a while" }
+func (s *pacedTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"n":{"type":"string"}}}`)
+}
+func (s *pacedTool) Execute(_ context.Context, args json.RawMessage) (string, error) {
+	var p struct {
+		N string `json:"n"`
+	}
+	json.Unmarshal(args, &p)
+	s.mu.Lock()
+	s.running++
+	s.peak = max(s.peak, s.running)
+	s.started = append(s.started, p.N)
+	s.mu.Unlock()
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	return s.name + " " + p.N, nil
+}
+
+func slowCalls(tool string, n int) []llm.ToolCall {
+	calls := make([]llm.ToolCall, n)
+	for i := range calls {
+		calls[i] = llm.ToolCall{
+			ID:       fmt.Sprintf("tc%d", i+1),
+			Type:     "function",
+			Function: llm.FunctionCall{Name: tool, Arguments: json.RawMessage(fmt.Sprintf(`{"n":"%d"}`, i+1))},
+		}
+	}
+	return calls
+}
+
+func TestParallelToolCalls(t *testing.T) {
+	slow := &pacedTool{name: "slow", delay: 50 * time.Millisecond}
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: slowCalls("slow", 3)},
+		{Content: "done"},
+	}}
+	rt, events, sid := newTimeoutRuntime(t, provider, slow)
+	rt.SetToolParallelism(2)
+
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if slow.peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", slow.peak)
+	}
+
+	// Calls and results alternate in the order the model made the calls.
+	evts, err := events.Tail(context.Background(), sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range evts {
+		if ev.Type != "tool_call" && ev.Type != "tool_result" {
+			continue
+		}
+		var p struct {
+			CallID string `json:"call_id"`
+			Result string `json:"result"`
+		}
+		json.Unmarshal(ev.Payload, &p)
+		got = append(got, strings.TrimSpace(ev.Type+" "+p.CallID+" "+p.Result))
+	}
+	want := []string{
+		"tool_call tc1", "tool_result tc1 slow 1",
+		"tool_call tc2", "tool_result tc2 slow 2",
+		"tool_call tc3", "tool_result tc3 slow 3",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestParallelMutatingToolsRunInTurn(t *testing.T) {
+	bash := &pacedTool{name: "bash", delay: 10 * time.Millisecond}
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: slowCalls("bash", 4)},
+		{Content: "done"},
+	}}
+	rt, _, sid := newTimeoutRuntime(t, provider, bash)
+	rt.SetToolParallelism(4)
+
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if bash.peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", bash.peak)
+	}
+	if fmt.Sprint(bash.started) != "[1 2 3 4]" {
+		t.Errorf("started = %v, want [1 2 3 4]", bash.started)
+	}
+}
@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// PromptPreview is a prompt rebuilt as of a point in a session's history.
+type PromptPreview struct {
+	SessionID types.SessionID `json:"session_id"`
+	// Seq is the last event the prompt includes.
+	Seq   int64       `json:"seq"`
+	RunID types.RunID `json:"run_id,omitempty"`
+	At    time.Time   `json:"at"`
+	// PromptVersion is the system prompt template the preview used.
+	PromptVersion string        `json:"prompt_version,omitempty"`
+	Messages      []llm.Message `json:"messages"`
+	// Notes list the inputs taken from the present rather than the past.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Notes describing what a preview could not reconstruct.
+const (
+	noteCurrentState  = "memory, tool list and language are current, not as of the event"
+	noteCurrentPrompt = "prompt template is current: the original version is unknown or not archived"
+)
+
+// PromptAt rebuilds the prompt the runtime would have sent right after the
+// session's event seq, with the system prompt's clock set to that event's
+// time. To see what the model saw before it wrote an event, ask for the
+// seq before it.
+func (rt *Runtime) PromptAt(ctx context.Context, sessionID types.SessionID, seq int64) (*PromptPreview, error) {
+	all, err := rt.history(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	cut := -1
+	for i, ev := range all {
+		if ev.Seq <= seq {
+			cut = i
+		}
+	}
+	if cut < 0 {
+		return nil, fmt.Errorf("no event at or before seq %d", seq)
+	}
+	// The prompt built here produced the next LLM-annotated event.
+	version := ""
+	for _, ev := range all[cut+1:] {
+		if version = promptVersionOf(ev); version != "" {
+			break
+		}
+	}
+	return rt.preview(ctx, sessionID, all[:cut+1], "", version)
+}
+
+// PromptForRun rebuilds the prompt of a run's last LLM call: everything up
+// to the run's final event, excluding the reply that call produced.
+func (rt *Runtime) PromptForRun(ctx context.Context, sessionID types.SessionID, runID types.RunID) (*PromptPreview, error) {
+	all, err := rt.history(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	last := -1
+	for i, ev := range all {
+		if ev.RunID == runID {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("run %s not found in session", runID)
+	}
+	version := promptVersionOf(all[last])
+	switch all[last].Type {
+	case "assistant_message", "no_reply":
+		last--
+	}
+	return rt.preview(ctx, sessionID, all[:last+1], runID, version)
+}
+
+// promptVersionOf returns the prompt version recorded on an event, if any.
+func promptVersionOf(ev *types.Event) string {
+	var p struct {
+		PromptVersion string `json:"prompt_version"`
+	}
+	json.Unmarshal(ev.Payload, &p)
+	return p.PromptVersion
+}
+
+// history loads every event in a session.
+func (rt *Runtime) history(ctx context.Context, sessionID types.SessionID) ([]*types.Event, error) {
+	n, err := rt.events.Count(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("count events: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("session has no events")
+	}
+	events, err := rt.events.Tail(ctx, sessionID, int(n))
+	if err != nil {
+		return nil, fmt.Errorf("load events: %w", err)
+	}
+	return events, nil
+}
+
+// preview builds the prompt from the tail of events, as processRun does,
+// with the archived template of the given version when there is one.
+func (rt *Runtime) preview(ctx context.Context, sessionID types.SessionID, events []*types.Event, runID types.RunID, version string) (*PromptPreview, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events before the run")
+	}
+	session, err := rt.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	if len(events) > historyLimit {
+		events = events[len(events)-historyLimit:]
+	}
+	engine := rt.engine
+	notes := []string{noteCurrentState}
+	switch {
+	case version == engine.PromptVersion():
+	case version != "" && rt.promptArchive != "":
+		source, err := ctxengine.ArchivedPrompt(rt.promptArchive, version)
+		if err == nil {
+			engine, err = engine.WithPrompt(source)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load prompt version %s: %w", version, err)
+		}
+	default:
+		notes = append(notes, noteCurrentPrompt)
+	}
+
+	last := events[len(events)-1]
+	messages, err := engine.BuildPromptAt(ctx, session, events, rt.artifacts, rt.toolNames(session), last.At)
+	if err != nil {
+		return nil, fmt.Errorf("build prompt: %w", err)
+	}
+	return &PromptPreview{
+		SessionID:     sessionID,
+		Seq:           last.Seq,
+		RunID:         runID,
+		At:            last.At,
+		PromptVersion: engine.PromptVersion(),
+		Messages:      messages,
+		Notes:         notes,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestPromptPreview(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{responses: []*llm.Response{{Content: "first answer"}, {Content: "second answer"}}}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	var runs []types.RunID
+	for _, text := range []string{"first question", "second question"} {
+		run := &gateway.Run{ID: types.NewRunID(), SessionID: sid, Event: &types.InboundEvent{Source: "test", Text: text}}
+		if err := rt.ProcessRun(run); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, run.ID)
+	}
+
+	// The first run's prompt ends at its question and knows nothing later.
+	preview, err := rt.PromptForRun(ctx, sid, runs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := preview.Messages[len(preview.Messages)-1]
+	if last.Role != "user" || last.Content != "first question" {
+		t.Errorf("expected prompt to end at the first question, got %+v", last)
+	}
+	for _, m := range preview.Messages {
+		if strings.Contains(m.Content, "first answer") || strings.Contains(m.Content, "second") {
+			t.Errorf("prompt includes later events: %q", m.Content)
+		}
+	}
+	if !strings.Contains(preview.Messages[0].Content, preview.At.Format(time.RFC3339)) {
+		t.Error("expected system prompt clock set to the event time")
+	}
+
+	// By seq: right after the first answer (seq 2), before the second question.
+	preview, err = rt.PromptAt(ctx, sid, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Seq != 2 || len(preview.Messages) != 3 {
+		t.Fatalf("expected system + 2 events at seq 2, got seq %d with %d messages", preview.Seq, len(preview.Messages))
+	}
+	if got := preview.Messages[2].Content; got != "first answer" {
+		t.Errorf("expected last message to be the first answer, got %q", got)
+	}
+
+	if _, err := rt.PromptForRun(ctx, sid, "missing"); err == nil {
+		t.Error("expected error for unknown run")
+	}
+	if _, err := rt.PromptAt(ctx, sid, 0); err == nil {
+		t.Error("expected error before the first event")
+	}
+}
+
+func TestPromptPreviewUsesArchivedTemplate(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldEngine, err := base.WithPrompt("Old prompt.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "prompts")
+	if _, err := ctxengine.ArchivePrompt(archive, oldEngine.PromptSource()); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{responses: []*llm.Response{{Content: "answer"}}}
+	rt := New(SingleModel(provider), oldEngine, sessions, events, artifacts, NewRegistry(), 10)
+	rt.SetPromptArchive(archive)
+	run := &gateway.Run{ID: types.NewRunID(), SessionID: sid, Event: &types.InboundEvent{Source: "test", Text: "question"}}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	evs, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	json.Unmarshal(evs[len(evs)-1].Payload, &payload)
+	if payload["prompt_version"] != oldEngine.PromptVersion() {
+		t.Errorf("expected response to record prompt version %s, got %v", oldEngine.PromptVersion(), payload["prompt_version"])
+	}
+
+	// The template changes; the preview still renders the one the run used.
+	rt.engine = base
+	preview, err := rt.PromptForRun(ctx, sid, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.PromptVersion != oldEngine.PromptVersion() || preview.Messages[0].Content != "Old prompt." {
+		t.Errorf("expected archived template, got version %s: %q", preview.PromptVersion, preview.Messages[0].Content)
+	}
+
+	// Without the archive the current template is used and flagged.
+	rt.SetPromptArchive("")
+	preview, err = rt.PromptForRun(ctx, sid, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.PromptVersion != base.PromptVersion() || len(preview.Notes) != 2 {
+		t.Errorf("expected current template with a note, got version %s, notes %v", preview.PromptVersion, preview.Notes)
+	}
+}
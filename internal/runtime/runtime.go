@@ -3,30 +3,56 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	ctxengine "github.com/user/gopherclaw/internal/context"
 	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/logging"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
 	"github.com/user/gopherclaw/pkg/llm"
 )
 
 // Runtime implements the agentic turn loop.
 type Runtime struct {
-	provider  llm.Provider
+	models    ModelSelector
 	engine    *ctxengine.Engine
 	sessions  types.SessionStore
 	events    types.EventStore
 	artifacts types.ArtifactStore
 	registry  *Registry
 	maxRounds int
+
+	interimAfter       time.Duration
+	summarizeArtifacts bool
+	summarizeHistory   bool
+	promptArchive      string
+	citations          bool
+	// toolTimeout, toolTimeouts and runTimeout bound tool calls and runs;
+	// see SetToolTimeout and SetRunTimeout.
+	toolTimeout  time.Duration
+	toolTimeouts map[string]time.Duration
+	runTimeout   time.Duration
+	// toolParallelism bounds how many of a round's tool calls run at once;
+	// see SetToolParallelism.
+	toolParallelism int
+	// approvalTools need the user's approval to run; see
+	// SetApprovalTools.
+	approvalTools   map[string]bool
+	approvalTimeout time.Duration
+	// budget, if set, refuses LLM calls once a spend budget is spent; see
+	// SetBudget.
+	budget *usage.Budget
 }
 
-// New creates a Runtime with the given dependencies.
+// New creates a Runtime with the given dependencies. models picks the
+// provider for each LLM call; SingleModel sends them all to one.
 func New(
-	provider llm.Provider,
+	models ModelSelector,
 	engine *ctxengine.Engine,
 	sessions types.SessionStore,
 	events types.EventStore,
@@ -35,7 +61,7 @@ func New(
 	maxRounds int,
 ) *Runtime {
 	return &Runtime{
-		provider:  provider,
+		models:    models,
 		engine:    engine,
 		sessions:  sessions,
 		events:    events,
@@ -45,141 +71,366 @@ func New(
 	}
 }
 
+// SetInterimAfter makes interactive runs (those with an OnNotice callback)
+// send a one-off "still working" notice, naming the current activity, once
+// they have been running for d. Zero disables it.
+func (rt *Runtime) SetInterimAfter(d time.Duration) {
+	rt.interimAfter = d
+}
+
+// SetCitations toggles the "Sources:" footer listing the web pages a reply
+// drew on.
+func (rt *Runtime) SetCitations(on bool) {
+	rt.citations = on
+}
+
+// SetPromptArchive sets the directory of archived system prompt templates
+// (see context.ArchivePrompt), letting prompt previews render a past run
+// with the template it actually used.
+func (rt *Runtime) SetPromptArchive(dir string) {
+	rt.promptArchive = dir
+}
+
+// SetSummarizeArtifacts makes tool results too large for the event log get
+// a short LLM summary, stored on the artifact and used in place of the cut
+// result. It costs one extra completion per large result.
+func (rt *Runtime) SetSummarizeArtifacts(on bool) {
+	rt.summarizeArtifacts = on
+}
+
+// SetSummarizeHistory makes events that no longer fit the prompt get
+// folded into a conversation summary instead of being dropped. It costs one
+// extra completion each time the history outgrows the budget.
+func (rt *Runtime) SetSummarizeHistory(on bool) {
+	rt.summarizeHistory = on
+}
+
 const artifactThreshold = 2000
 
+// historyLimit is how many recent events each LLM call is built from.
+const historyLimit = 100
+
+// NoReplyTool is the name of the tool the model calls to end a run without
+// a response. When it appears in a round, the round's other tool calls still
+// execute, a no_reply event is recorded, and the run's result has no text.
+const NoReplyTool = "no_reply"
+
 // ProcessRun executes the agentic turn loop for a single run.
-// This is the function passed to Queue.SetProcessor.
+// This is the function passed to Queue.SetProcessor. A failed run leaves an
+// error event in the session so later runs can see what went wrong.
 func (rt *Runtime) ProcessRun(run *gateway.Run) error {
-	ctx := run.Ctx
-	if ctx == nil {
-		ctx = context.Background()
+	if rt.runTimeout > 0 {
+		parent := run.Ctx
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeoutCause(parent, rt.runTimeout, ErrRunTimeout)
+		defer cancel()
+		run.Ctx = ctx
+	}
+	err := rt.processRun(run)
+	if err != nil && run.Ctx != nil && context.Cause(run.Ctx) == ErrRunTimeout {
+		err = fmt.Errorf("%w after %s", ErrRunTimeout, rt.runTimeout)
+		if aerr := rt.events.Append(context.Background(), timeoutEvent(run, map[string]any{}, rt.runTimeout, err.Error())); aerr != nil {
+			slog.Warn("record timeout event failed", "run_id", string(run.ID), "error", aerr)
+		}
 	}
+	if err != nil {
+		rt.recordError(run, err.Error())
+	}
+	return err
+}
 
-	log := slog.With("run_id", string(run.ID), "session_id", string(run.SessionID))
+// runProgress is what a resumed run recorded before the restart.
+type runProgress struct {
+	userMessage bool
+	// finished is set when the run recorded its reply, or chose not to
+	// reply.
+	finished bool
+	reply    string
+}
 
-	// 1. Record user_message event
-	userPayload, _ := json.Marshal(map[string]string{"text": run.Event.Text})
-	if err := rt.events.Append(ctx, &types.Event{
+// resumedProgress finds what a resumed run already recorded, so its user
+// message isn't recorded twice and a reply it already made isn't made
+// again. Runs that weren't resumed have recorded nothing.
+func (rt *Runtime) resumedProgress(ctx context.Context, run *gateway.Run) (runProgress, error) {
+	var p runProgress
+	if !run.Resumed {
+		return p, nil
+	}
+	events, err := rt.events.Tail(ctx, run.SessionID, historyLimit)
+	if err != nil {
+		return p, fmt.Errorf("load events: %w", err)
+	}
+	for _, ev := range events {
+		if ev.RunID != run.ID {
+			continue
+		}
+		switch ev.Type {
+		case "user_message":
+			p.userMessage = true
+		case "assistant_message":
+			var payload struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(ev.Payload, &payload)
+			p.finished, p.reply = true, payload.Text
+		case "no_reply":
+			p.finished = true
+		}
+	}
+	return p, nil
+}
+
+// recordError appends an error event for the run. It uses a fresh context
+// since the run's own context may be what failed.
+func (rt *Runtime) recordError(run *gateway.Run, message string) {
+	payload, _ := json.Marshal(map[string]string{"message": message})
+	if err := rt.events.Append(context.Background(), &types.Event{
 		ID:        types.NewEventID(),
 		SessionID: run.SessionID,
 		RunID:     run.ID,
-		Type:      "user_message",
-		Source:    run.Event.Source,
+		Type:      "error",
+		Source:    "runtime",
 		At:        time.Now(),
-		Payload:   userPayload,
+		Payload:   payload,
 	}); err != nil {
-		return fmt.Errorf("record user message: %w", err)
+		slog.Warn("record error event failed", "run_id", string(run.ID), "session_id", string(run.SessionID), "error", err)
 	}
+}
 
-	// Collect tool names for system prompt
-	var toolNames []string
-	for _, t := range rt.registry.All() {
-		toolNames = append(toolNames, t.Name())
+func (rt *Runtime) processRun(run *gateway.Run) error {
+	ctx := run.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Tag every log line of the run, including those from tools.
+	ctx = logging.WithRun(ctx, run.ID, run.SessionID)
+
+	res := &gateway.RunResult{RunID: run.ID, SessionID: run.SessionID}
+	act := &activity{}
+	stopInterim := startInterim(rt.interimAfter, act, run.OnNotice)
+	defer stopInterim()
+
+	// 1. Record user_message event
+	userFields := map[string]any{"text": run.Event.Text}
+	if len(run.Event.Attachments) > 0 {
+		userFields["attachments"] = run.Event.Attachments
+	}
+	if meta := run.Event.Metadata; meta != nil {
+		stamped := *meta
+		if stamped.Version == 0 {
+			stamped.Version = types.InboundSchemaVersion
+		}
+		userFields["metadata"] = stamped
+	}
+	userPayload, _ := json.Marshal(userFields)
+	progress, err := rt.resumedProgress(ctx, run)
+	if err != nil {
+		return err
+	}
+	if progress.finished {
+		// It got as far as its reply before the restart, which is
+		// recorded before anything is delivered.
+		slog.InfoContext(ctx, "resumed run already answered", "run_id", string(run.ID))
+		res.Text = progress.reply
+		run.Finish(res)
+		return nil
+	}
+	if !progress.userMessage {
+		if err := rt.events.Append(ctx, &types.Event{
+			ID:        types.NewEventID(),
+			SessionID: run.SessionID,
+			RunID:     run.ID,
+			Type:      "user_message",
+			Source:    run.Event.Source,
+			At:        time.Now(),
+			Payload:   userPayload,
+		}); err != nil {
+			return fmt.Errorf("record user message: %w", err)
+		}
+	}
+	rt.detectLanguage(ctx, run)
+	// Web pages the run's tools drew on, for citations.
+	var sources []types.Source
+
+	if run.Event.Confirm {
+		found, err := rt.runPending(ctx, run, act, res)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, found...)
 	}
 
 	for round := 0; round < rt.maxRounds; round++ {
-		// 2. Load session
+		// 2. Load session. Its tool policy is re-read every round so a
+		// toggle takes effect mid-run.
 		session, err := rt.sessions.Get(ctx, run.SessionID)
 		if err != nil {
 			return fmt.Errorf("load session: %w", err)
 		}
+		toolNames := rt.toolNames(session)
 
 		// 3. Load recent events
-		events, err := rt.events.Tail(ctx, run.SessionID, 100)
+		events, err := rt.events.Tail(ctx, run.SessionID, historyLimit)
 		if err != nil {
 			return fmt.Errorf("load events: %w", err)
 		}
 
+		if rt.summarizeHistory {
+			if folded, err := rt.foldHistory(ctx, run, session, events, toolNames); err != nil {
+				slog.WarnContext(ctx, "summarize history failed, dropping older events", "error", err)
+			} else if folded {
+				if events, err = rt.events.Tail(ctx, run.SessionID, historyLimit); err != nil {
+					return fmt.Errorf("load events: %w", err)
+				}
+			}
+		}
+
 		// 4. Build prompt
 		messages, err := rt.engine.BuildPrompt(ctx, session, events, rt.artifacts, toolNames)
 		if err != nil {
 			return fmt.Errorf("build prompt: %w", err)
 		}
 
-		log.Info("calling LLM", "round", round+1, "max_rounds", rt.maxRounds, "messages", len(messages))
+		slog.InfoContext(ctx, "calling LLM", "round", round+1, "max_rounds", rt.maxRounds, "messages", len(messages))
 
 		// 5. Call LLM
-		resp, err := rt.provider.Complete(ctx, messages, rt.registry.AsLLMTools())
+		act.set("")
+		reportProgress(run, round+1, "")
+		start := time.Now()
+		resp, err := rt.complete(ctx, run, messages, rt.registry.AsLLMToolsExcept(session.DisabledTools))
 		if err != nil {
 			return fmt.Errorf("LLM call: %w", err)
 		}
+		latency := time.Since(start)
+		addUsage(&res.Usage, resp.Usage)
+		rt.recordUsage(ctx, run.SessionID, run.ID, usage.Reply, resp)
 
-		log.Info("LLM responded", "round", round+1, "content_len", len(resp.Content), "tool_calls", len(resp.ToolCalls))
+		slog.InfoContext(ctx, "LLM responded", "round", round+1, "content_len", len(resp.Content), "tool_calls", len(resp.ToolCalls))
 
-		// 6. If tool calls, execute them
+		// 6. If tool calls, execute them. Events for the round are collected
+		// and flushed together so a crash can't leave a tool_call without
+		// its tool_result.
 		if len(resp.ToolCalls) > 0 {
-			for _, tc := range resp.ToolCalls {
-				// Record tool_call event
-				tcPayload, _ := json.Marshal(map[string]any{
+			res.ToolRounds++
+			var roundEvents []*types.Event
+			if ev := reasoningEvent(run, resp); ev != nil {
+				roundEvents = append(roundEvents, ev)
+			}
+			noReply := false
+			var noReplyReason string
+			var planned []types.PendingCall
+			calls := make([]*toolRun, len(resp.ToolCalls))
+			for i, tc := range resp.ToolCalls {
+				// Events keep the name the model called the tool by;
+				// policies and execution go by the registered name.
+				tcPayload, _ := json.Marshal(rt.annotate(map[string]any{
 					"tool":      tc.Function.Name,
 					"call_id":   tc.ID,
 					"arguments": tc.Function.Arguments,
-				})
-				if err := rt.events.Append(ctx, &types.Event{
-					ID:        types.NewEventID(),
-					SessionID: run.SessionID,
-					RunID:     run.ID,
-					Type:      "tool_call",
-					Source:    "runtime",
-					At:        time.Now(),
-					Payload:   tcPayload,
-				}); err != nil {
-					return fmt.Errorf("record tool call: %w", err)
+				}, resp, latency))
+				call := &toolRun{
+					name: rt.registry.Resolve(tc.Function.Name),
+					args: normalizeArgs(tc.Function.Arguments),
+					event: &types.Event{
+						ID:        types.NewEventID(),
+						SessionID: run.SessionID,
+						RunID:     run.ID,
+						Type:      "tool_call",
+						Source:    "runtime",
+						At:        time.Now(),
+						Payload:   tcPayload,
+					},
 				}
-
-				// Execute tool
-				args := normalizeArgs(tc.Function.Arguments)
-				log.Debug("tool call", "round", round+1, "tool", tc.Function.Name, "args", string(args))
-				tool, ok := rt.registry.Get(tc.Function.Name)
-				var result string
-				if !ok {
-					result = fmt.Sprintf("error: unknown tool %q", tc.Function.Name)
-					log.Warn("unknown tool", "round", round+1, "tool", tc.Function.Name)
-				} else {
-					var execErr error
-					result, execErr = tool.Execute(ctx, args)
-					if execErr != nil {
-						result = fmt.Sprintf("error: %v", execErr)
-						log.Warn("tool error", "round", round+1, "tool", tc.Function.Name, "error", execErr)
+				calls[i] = call
+				if call.name == NoReplyTool {
+					noReply = true
+					var p struct {
+						Reason string `json:"reason"`
 					}
+					json.Unmarshal(call.args, &p)
+					noReplyReason = p.Reason
+				}
+				slog.DebugContext(ctx, "tool call", "round", round+1, "tool", tc.Function.Name, "args", string(call.args))
+				if rt.mustConfirm(session, call.name) {
+					call.planned = true
+					call.result = plannedResult(tc.Function.Name)
+					planned = append(planned, types.PendingCall{Tool: call.name, Arguments: call.args, RunID: run.ID, At: time.Now()})
+					slog.InfoContext(ctx, "tool call planned, awaiting confirmation", "round", round+1, "tool", call.name)
 				}
-				log.Debug("tool result", "round", round+1, "tool", tc.Function.Name, "result_len", len(result), "result_preview", truncate(result, 200))
+			}
+			denied, err := rt.approve(ctx, run, act, calls, resp.ToolCalls)
+			if err != nil {
+				return err
+			}
+			rt.runTools(ctx, run, act, session, round+1, calls)
 
-				// Store as artifact if large
+			// Record each call and its result in the order the model made
+			// them, whatever order they finished in.
+			for i, tc := range resp.ToolCalls {
+				call := calls[i]
+				roundEvents = append(roundEvents, call.event)
 				trPayload := map[string]any{
 					"tool":    tc.Function.Name,
 					"call_id": tc.ID,
-					"result":  result,
 				}
-				if len(result) > artifactThreshold {
-					artID, err := rt.artifacts.Put(ctx, run.SessionID, run.ID, tc.Function.Name, result)
-					if err == nil {
-						trPayload["artifact_id"] = string(artID)
-						trPayload["result"] = result[:artifactThreshold] + "\n[truncated, see artifact " + string(artID) + "]"
-					}
+				if call.planned {
+					trPayload["planned"] = true
 				}
-
-				trPayloadJSON, _ := json.Marshal(trPayload)
-				if err := rt.events.Append(ctx, &types.Event{
+				sources = append(sources, call.found...)
+				if len(call.found) > 0 {
+					trPayload["sources"] = call.found
+				}
+				timedOut := markFailure(trPayload, call.err)
+				slog.DebugContext(ctx, "tool result", "round", round+1, "tool", tc.Function.Name, "result_len", len(call.result), "result_preview", truncate(call.result, 200))
+				roundEvents = append(roundEvents, rt.toolResultEvent(ctx, run, act, trPayload, call.result))
+				if timedOut {
+					roundEvents = append(roundEvents, timeoutEvent(run, map[string]any{"tool": tc.Function.Name, "call_id": tc.ID}, rt.toolTimeoutFor(call.name), call.result))
+				}
+				recordTool(res, trPayload, call.result)
+			}
+			if noReply {
+				nrPayload, _ := json.Marshal(map[string]string{"reason": noReplyReason})
+				roundEvents = append(roundEvents, &types.Event{
 					ID:        types.NewEventID(),
 					SessionID: run.SessionID,
 					RunID:     run.ID,
-					Type:      "tool_result",
+					Type:      "no_reply",
 					Source:    "runtime",
 					At:        time.Now(),
-					Payload:   trPayloadJSON,
-				}); err != nil {
-					return fmt.Errorf("record tool result: %w", err)
+					Payload:   nrPayload,
+				})
+			}
+			if err := rt.events.AppendBatch(ctx, roundEvents); err != nil {
+				return fmt.Errorf("record tool round: %w", err)
+			}
+			if len(planned) > 0 {
+				if err := gateway.AddPending(ctx, rt.sessions, run.SessionID, planned); err != nil {
+					return fmt.Errorf("record planned tool calls: %w", err)
 				}
 			}
+			if denied != "" {
+				slog.InfoContext(ctx, "run stopped, tool call not approved", "round", round+1, "tool", denied)
+				return rt.finishDenied(ctx, run, session, res, denied)
+			}
+			if noReply {
+				slog.InfoContext(ctx, "run complete (no reply)", "round", round+1, "reason", noReplyReason)
+				run.Finish(res)
+				return nil
+			}
 			continue // Loop back for next LLM call
 		}
 
 		// 7. Text response -- done
 		if resp.Content != "" {
-			log.Info("run complete", "round", round+1, "response_len", len(resp.Content))
-			aPayload, _ := json.Marshal(map[string]string{"text": resp.Content})
-			if err := rt.events.Append(ctx, &types.Event{
+			slog.InfoContext(ctx, "run complete", "round", round+1, "response_len", len(resp.Content))
+			fields := map[string]any{"text": resp.Content}
+			text := rt.shape(ctx, run, session, resp.Content, fields)
+			reply := rt.cite(session, text, sources, fields)
+			aPayload, _ := json.Marshal(rt.annotate(fields, resp, latency))
+			if err := rt.events.AppendBatch(ctx, withReasoning(run, resp, &types.Event{
 				ID:        types.NewEventID(),
 				SessionID: run.SessionID,
 				RunID:     run.ID,
@@ -187,53 +438,59 @@ func (rt *Runtime) ProcessRun(run *gateway.Run) error {
 				Source:    "runtime",
 				At:        time.Now(),
 				Payload:   aPayload,
-			}); err != nil {
+			})); err != nil {
 				return fmt.Errorf("record assistant message: %w", err)
 			}
-			if run.OnComplete != nil {
-				run.OnComplete(resp.Content)
-			}
+			res.Text = reply
+			run.Finish(res)
 			return nil
 		}
 
 		// Empty response (no content, no tool calls) -- treat as done
-		log.Warn("empty LLM response", "round", round+1)
-		if run.OnComplete != nil {
-			run.OnComplete("")
-		}
+		slog.WarnContext(ctx, "empty LLM response", "round", round+1)
+		run.Finish(res)
 		return nil
 	}
 
 	// Max rounds exhausted — make one final LLM call without tools to force
 	// a text summary instead of dropping the conversation with an error.
-	log.Warn("max tool rounds reached, forcing final response", "max_rounds", rt.maxRounds)
+	slog.WarnContext(ctx, "max tool rounds reached, forcing final response", "max_rounds", rt.maxRounds)
+	rt.recordError(run, fmt.Sprintf("max tool rounds (%d) exceeded", rt.maxRounds))
 
 	session, err := rt.sessions.Get(ctx, run.SessionID)
 	if err != nil {
 		return fmt.Errorf("load session for final response: %w", err)
 	}
-	events, err := rt.events.Tail(ctx, run.SessionID, 100)
+	events, err := rt.events.Tail(ctx, run.SessionID, historyLimit)
 	if err != nil {
 		return fmt.Errorf("load events for final response: %w", err)
 	}
-	messages, err := rt.engine.BuildPrompt(ctx, session, events, rt.artifacts, toolNames)
+	messages, err := rt.engine.BuildPrompt(ctx, session, events, rt.artifacts, rt.toolNames(session))
 	if err != nil {
 		return fmt.Errorf("build prompt for final response: %w", err)
 	}
 
-	resp, err := rt.provider.Complete(ctx, messages, nil) // no tools
+	reportProgress(run, rt.maxRounds+1, "")
+	start := time.Now()
+	resp, err := rt.complete(ctx, run, messages, nil) // no tools
 	if err != nil {
 		return fmt.Errorf("final LLM call: %w", err)
 	}
+	latency := time.Since(start)
+	addUsage(&res.Usage, resp.Usage)
+	rt.recordUsage(ctx, run.SessionID, run.ID, usage.Reply, resp)
 
 	content := resp.Content
 	if content == "" {
 		content = "I ran out of steps before I could finish. Here's what I got done so far — please send a follow-up message if you'd like me to continue."
 	}
 
-	log.Info("run complete (forced final response)", "response_len", len(content))
-	aPayload, _ := json.Marshal(map[string]string{"text": content})
-	if err := rt.events.Append(ctx, &types.Event{
+	slog.InfoContext(ctx, "run complete (forced final response)", "response_len", len(content))
+	fields := map[string]any{"text": content}
+	content = rt.shape(ctx, run, session, content, fields)
+	reply := rt.cite(session, content, sources, fields)
+	aPayload, _ := json.Marshal(rt.annotate(fields, resp, latency))
+	if err := rt.events.AppendBatch(ctx, withReasoning(run, resp, &types.Event{
 		ID:        types.NewEventID(),
 		SessionID: run.SessionID,
 		RunID:     run.ID,
@@ -241,15 +498,176 @@ func (rt *Runtime) ProcessRun(run *gateway.Run) error {
 		Source:    "runtime",
 		At:        time.Now(),
 		Payload:   aPayload,
-	}); err != nil {
+	})); err != nil {
 		return fmt.Errorf("record final assistant message: %w", err)
 	}
-	if run.OnComplete != nil {
-		run.OnComplete(content)
-	}
+	res.Text = reply
+	run.Finish(res)
 	return nil
 }
 
+// execTool runs a tool call under the session's tool policy and the
+// tool's timeout. Failures are returned as "error: ..." results for the
+// model to see; err is the error the call failed with, if any.
+func (rt *Runtime) execTool(ctx context.Context, session *types.SessionIndex, name string, args json.RawMessage) (result string, found []types.Source, err error) {
+	tool, ok := rt.registry.Get(name)
+	if !ok {
+		slog.WarnContext(ctx, "unknown tool", "tool", name)
+		return fmt.Sprintf("error: unknown tool %q", name), nil, nil
+	}
+	if !gateway.ToolEnabled(session, name) {
+		slog.WarnContext(ctx, "disabled tool", "tool", name)
+		return fmt.Sprintf("error: tool %q is disabled for this conversation", name), nil, nil
+	}
+	result, found, err = rt.callTool(ctx, name, func(ctx context.Context) (string, []types.Source, error) {
+		if st, ok := tool.(SourcedTool); ok {
+			return st.ExecuteWithSources(ctx, args)
+		}
+		var (
+			result string
+			err    error
+		)
+		if st, ok := tool.(SessionTool); ok {
+			result, err = st.ExecuteInSession(ctx, session, args)
+		} else {
+			result, err = tool.Execute(ctx, args)
+		}
+		return result, nil, err
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "tool error", "tool", name, "error", err)
+		return fmt.Sprintf("error: %v", err), found, err
+	}
+	return result, found, nil
+}
+
+// markFailure flags the tool_result payload of a call that timed out or
+// that the tool's policy denied, and reports whether it timed out.
+func markFailure(trPayload map[string]any, err error) (timedOut bool) {
+	switch {
+	case errors.Is(err, errToolTimeout):
+		trPayload["timed_out"] = true
+		return true
+	case errors.Is(err, gateway.ErrToolDenied):
+		trPayload["denied"] = true
+	}
+	return false
+}
+
+// toolResultEvent builds a tool_result event from its payload fields and
+// result, storing results too large for the event log as an artifact.
+func (rt *Runtime) toolResultEvent(ctx context.Context, run *gateway.Run, act *activity, trPayload map[string]any, result string) *types.Event {
+	tool, _ := trPayload["tool"].(string)
+	trPayload["result"] = result
+	if len(result) > artifactThreshold {
+		artID, err := rt.artifacts.Put(ctx, run.SessionID, run.ID, tool, result)
+		if err == nil {
+			trPayload["artifact_id"] = string(artID)
+			summary := ""
+			if rt.summarizeArtifacts {
+				act.set("summarizing a long result")
+				summary, err = rt.summarizeArtifact(ctx, run, artID, tool, result)
+				if err != nil {
+					slog.WarnContext(ctx, "artifact summary failed", "artifact_id", artID, "error", err)
+				} else {
+					trPayload["summary"] = summary
+				}
+			}
+			trPayload["result"] = truncatedResult(result, artID, summary)
+		}
+	}
+
+	trPayloadJSON, _ := json.Marshal(trPayload)
+	return &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "tool_result",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   trPayloadJSON,
+	}
+}
+
+// reasoningEvent records a response's reasoning trace as its own event, or
+// returns nil if there is none. The context engine leaves reasoning events
+// out of prompts, so traces are kept for inspection without being replayed.
+func reasoningEvent(run *gateway.Run, resp *llm.Response) *types.Event {
+	if resp.Reasoning == "" {
+		return nil
+	}
+	payload, _ := json.Marshal(map[string]any{"text": resp.Reasoning, "model": resp.Model})
+	return &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "reasoning",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}
+}
+
+// recordUsage records the token usage of an LLM call as a usage event.
+// Accounting never fails the work it accounts for, so errors are logged.
+func (rt *Runtime) recordUsage(ctx context.Context, sessionID types.SessionID, runID types.RunID, purpose string, resp *llm.Response) {
+	ev := usage.Event(sessionID, runID, purpose, resp)
+	if ev == nil {
+		return
+	}
+	if rt.budget != nil {
+		rt.budget.Add(sessionID, resp.Model, resp.Usage)
+	}
+	if err := rt.events.Append(ctx, ev); err != nil {
+		slog.WarnContext(ctx, "record usage failed", "purpose", purpose, "error", err)
+	}
+}
+
+// withReasoning returns event, preceded by the response's reasoning event
+// if it has one, for a single AppendBatch.
+func withReasoning(run *gateway.Run, resp *llm.Response, event *types.Event) []*types.Event {
+	if ev := reasoningEvent(run, resp); ev != nil {
+		return []*types.Event{ev, event}
+	}
+	return []*types.Event{event}
+}
+
+// toolNames lists the exposed names of the registered tools the session's
+// policy allows, for the system prompt.
+func (rt *Runtime) toolNames(session *types.SessionIndex) []string {
+	var names []string
+	for _, t := range rt.registry.All() {
+		if gateway.ToolEnabled(session, t.Name()) {
+			names = append(names, rt.registry.ExposedName(t.Name()))
+		}
+	}
+	return names
+}
+
+// annotate adds the model, provider, failover backend, latency and finish
+// reason of the LLM response that produced an event to its payload, along
+// with the system prompt version, so history can tell which model said what
+// under which prompt.
+func (rt *Runtime) annotate(payload map[string]any, resp *llm.Response, latency time.Duration) map[string]any {
+	if v := rt.engine.PromptVersion(); v != "" {
+		payload["prompt_version"] = v
+	}
+	if resp.Model != "" {
+		payload["model"] = resp.Model
+	}
+	if resp.Provider != "" {
+		payload["provider"] = resp.Provider
+	}
+	if resp.Backend != "" {
+		payload["backend"] = resp.Backend
+	}
+	if resp.FinishReason != "" {
+		payload["finish_reason"] = resp.FinishReason
+	}
+	payload["latency_ms"] = latency.Milliseconds()
+	return payload
+}
+
 // normalizeArgs unwraps double-encoded JSON arguments.
 // Some LLM APIs return tool arguments as a JSON string containing JSON
 // (e.g. "{\"command\": \"ls\"}") instead of a raw JSON object.
@@ -263,6 +681,29 @@ func normalizeArgs(args json.RawMessage) json.RawMessage {
 	return args
 }
 
+// recordTool adds a handled tool call to the run's result, given its
+// tool_result payload.
+func recordTool(res *gateway.RunResult, trPayload map[string]any, result string) {
+	call := gateway.ToolCall{
+		Error:   strings.HasPrefix(result, "error:"),
+		Planned: trPayload["planned"] == true,
+	}
+	call.Tool, _ = trPayload["tool"].(string)
+	call.CallID, _ = trPayload["call_id"].(string)
+	if id, ok := trPayload["artifact_id"].(string); ok {
+		call.ArtifactID = types.ArtifactID(id)
+		res.Artifacts = append(res.Artifacts, call.ArtifactID)
+	}
+	res.ToolCalls = append(res.ToolCalls, call)
+}
+
+// addUsage adds an LLM call's token usage to a running total.
+func addUsage(total *llm.Usage, u llm.Usage) {
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	total.TotalTokens += u.TotalTokens
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
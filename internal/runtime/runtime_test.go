@@ -3,14 +3,21 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	ctxengine "github.com/user/gopherclaw/internal/context"
 	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/runtime/tools"
 	"github.com/user/gopherclaw/internal/state"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
 	"github.com/user/gopherclaw/pkg/llm"
 )
 
@@ -60,7 +67,7 @@ func TestProcessRunSimpleResponse(t *testing.T) {
 	}
 
 	registry := NewRegistry()
-	rt := New(provider, engine, sessions, events, artifacts, registry, 10)
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
 
 	var callbackResult string
 	done := make(chan struct{})
@@ -131,9 +138,12 @@ func TestProcessRunWithToolCall(t *testing.T) {
 						Arguments: json.RawMessage(`{"text":"world"}`),
 					},
 				}},
+				Provider:     "mock",
+				Model:        "mock-model",
+				FinishReason: "tool_calls",
 			},
 			// Second call: LLM gives final response
-			{Content: "The echo returned: world"},
+			{Content: "The echo returned: world", Provider: "mock", Model: "mock-model", FinishReason: "stop"},
 		},
 	}
 
@@ -145,7 +155,7 @@ func TestProcessRunWithToolCall(t *testing.T) {
 	registry := NewRegistry()
 	registry.Register(&echoTool{})
 
-	rt := New(provider, engine, sessions, events, artifacts, registry, 10)
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
 
 	var callbackResult string
 	done := make(chan struct{})
@@ -186,6 +196,33 @@ func TestProcessRunWithToolCall(t *testing.T) {
 	if count != 4 {
 		t.Errorf("expected 4 events, got %d", count)
 	}
+
+	// tool_call and assistant_message carry the model that produced them
+	all, err := events.Tail(ctx, sid, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []*types.Event{all[1], all[3]} {
+		var p struct {
+			Model        string `json:"model"`
+			Provider     string `json:"provider"`
+			FinishReason string `json:"finish_reason"`
+			LatencyMS    *int64 `json:"latency_ms"`
+		}
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Model != "mock-model" || p.Provider != "mock" || p.LatencyMS == nil {
+			t.Errorf("%s: missing annotations: %s", ev.Type, ev.Payload)
+		}
+	}
+	var p struct {
+		FinishReason string `json:"finish_reason"`
+	}
+	json.Unmarshal(all[1].Payload, &p)
+	if p.FinishReason != "tool_calls" {
+		t.Errorf("expected tool_calls finish reason, got %q", p.FinishReason)
+	}
 }
 
 func TestProcessRunMaxRounds(t *testing.T) {
@@ -217,7 +254,7 @@ func TestProcessRunMaxRounds(t *testing.T) {
 	registry := NewRegistry()
 	registry.Register(&echoTool{})
 
-	rt := New(infProvider, engine, sessions, events, artifacts, registry, 3) // max 3 rounds
+	rt := New(SingleModel(infProvider), engine, sessions, events, artifacts, registry, 3) // max 3 rounds
 
 	run := &gateway.Run{
 		ID:        types.NewRunID(),
@@ -237,4 +274,1195 @@ func TestProcessRunMaxRounds(t *testing.T) {
 	if completedWith == "" {
 		t.Fatal("expected OnComplete to be called with a fallback message")
 	}
+
+	// The exhausted rounds are recorded as an error event
+	all, err := events.Tail(ctx, sid, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, ev := range all {
+		if ev.Type == "error" && strings.Contains(string(ev.Payload), "max tool rounds (3) exceeded") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected an error event for exhausted tool rounds")
+	}
+}
+
+// failingProvider always returns an error.
+type failingProvider struct{}
+
+func (failingProvider) Complete(context.Context, []llm.Message, []llm.Tool) (*llm.Response, error) {
+	return nil, errors.New("API error (status 500): upstream down")
+}
+
+func (failingProvider) Stream(context.Context, []llm.Message, []llm.Tool) (<-chan llm.Delta, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestProcessRunRecordsErrorEvent(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(failingProvider{}), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:u1", UserID: "u1", Text: "hi"},
+	}
+	if err := rt.ProcessRun(run); err == nil {
+		t.Fatal("expected run to fail")
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[1].Type != "error" || all[1].RunID != run.ID {
+		t.Fatalf("expected user_message followed by error event, got %+v", all)
+	}
+	var p struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(all[1].Payload, &p)
+	if !strings.Contains(p.Message, "upstream down") {
+		t.Errorf("expected error message in payload, got %q", p.Message)
+	}
+}
+
+func TestProcessRunNoReply(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("task", "check"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{
+		responses: []*llm.Response{{
+			ToolCalls: []llm.ToolCall{{
+				ID:   "tc1",
+				Type: "function",
+				Function: llm.FunctionCall{
+					Name:      NoReplyTool,
+					Arguments: json.RawMessage(`{"reason":"no new alerts"}`),
+				},
+			}},
+		}},
+	}
+
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(tools.NewNoReply())
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+
+	response := "unset"
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "task", SessionKey: types.NewSessionKey("task", "check"), Text: "check alerts"},
+		Status:     gateway.RunStatusRunning,
+		CreatedAt:  time.Now(),
+		OnComplete: func(resp string) { response = resp },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if response != "" {
+		t.Errorf("expected empty response for no_reply, got %q", response)
+	}
+	if provider.callCount != 1 {
+		t.Errorf("expected a single LLM call, got %d", provider.callCount)
+	}
+
+	// user_message + tool_call + tool_result + no_reply
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || all[3].Type != "no_reply" {
+		t.Fatalf("expected trailing no_reply event, got %d events", len(all))
+	}
+	var p struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(all[3].Payload, &p)
+	if p.Reason != "no new alerts" {
+		t.Errorf("expected reason recorded, got %q", p.Reason)
+	}
+}
+
+// slowTool blocks until its context is done or the delay passes.
+type slowTool struct{ delay time.Duration }
+
+func (s *slowTool) Name() string                { return "brave_search" }
+func (s *slowTool) Description() string         { return "slow search" }
+func (s *slowTool) Parameters() json.RawMessage { return json.RawMessage(`{"type":"object"}`) }
+func (s *slowTool) Execute(ctx context.Context, _ json.RawMessage) (string, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+	}
+	return "results", nil
+}
+
+func TestProcessRunInterimNotice(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{ToolCalls: []llm.ToolCall{{
+				ID:       "tc1",
+				Type:     "function",
+				Function: llm.FunctionCall{Name: "brave_search", Arguments: json.RawMessage(`{}`)},
+			}}},
+			{Content: "found it"},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&slowTool{delay: 200 * time.Millisecond})
+
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+	rt.SetInterimAfter(50 * time.Millisecond)
+
+	var mu sync.Mutex
+	var notices []string
+	var response string
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: types.NewSessionKey("test", "user1"), Text: "search"},
+		OnComplete: func(resp string) {
+			mu.Lock()
+			response = resp
+			mu.Unlock()
+		},
+		OnNotice: func(n string) {
+			mu.Lock()
+			notices = append(notices, n)
+			mu.Unlock()
+		},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if response != "found it" {
+		t.Errorf("expected final response, got %q", response)
+	}
+	if len(notices) != 1 {
+		t.Fatalf("expected exactly one interim notice, got %v", notices)
+	}
+	if !strings.Contains(notices[0], "running web searches") {
+		t.Errorf("expected notice to name the activity, got %q", notices[0])
+	}
+}
+
+// toolsRecorder wraps mockProvider and records the tools offered per call.
+type toolsRecorder struct {
+	mockProvider
+	offered [][]string
+}
+
+func (r *toolsRecorder) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	var names []string
+	for _, t := range tools {
+		names = append(names, t.Function.Name)
+	}
+	r.offered = append(r.offered, names)
+	return r.mockProvider.Complete(ctx, messages, tools)
+}
+
+func TestProcessRunDisabledTool(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetToolEnabled(ctx, sessions, sid, "echo", false); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &toolsRecorder{mockProvider: mockProvider{
+		responses: []*llm.Response{
+			// The model calls the tool anyway (e.g. from earlier history)
+			{ToolCalls: []llm.ToolCall{{
+				ID:       "tc1",
+				Type:     "function",
+				Function: llm.FunctionCall{Name: "echo", Arguments: json.RawMessage(`{"text":"x"}`)},
+			}}},
+			{Content: "ok"},
+		},
+	}}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+	registry.Register(tools.NewNoReply())
+
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "echo x"},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, names := range provider.offered {
+		for _, name := range names {
+			if name == "echo" {
+				t.Errorf("call %d: disabled tool was offered to the model", i+1)
+			}
+		}
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	for _, ev := range all {
+		if ev.Type == "tool_result" {
+			json.Unmarshal(ev.Payload, &result)
+		}
+	}
+	if !strings.Contains(result.Result, "disabled") {
+		t.Errorf("expected the call to be refused, got result %q", result.Result)
+	}
+}
+
+func TestProcessRunRecordsReasoning(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{Content: "42", Reasoning: "6 times 7", Model: "deepseek-reasoner"},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	var response string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "6*7?"},
+		OnComplete: func(resp string) { response = resp },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if response != "42" {
+		t.Errorf("expected reasoning to stay out of the reply, got %q", response)
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[1].Type != "reasoning" || all[2].Type != "assistant_message" {
+		t.Fatalf("expected user_message, reasoning, assistant_message; got %d events", len(all))
+	}
+	var p struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(all[1].Payload, &p)
+	if p.Text != "6 times 7" {
+		t.Errorf("unexpected reasoning payload: %s", all[1].Payload)
+	}
+}
+
+func TestProcessRunSummarizesLargeResult(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("line of output\n", 300)
+	args, _ := json.Marshal(map[string]string{"text": long})
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{ToolCalls: []llm.ToolCall{{
+				ID:       "tc1",
+				Type:     "function",
+				Function: llm.FunctionCall{Name: "echo", Arguments: args},
+			}}},
+			{Content: "300 identical lines of output."}, // summary
+			{Content: "done"},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+	rt.SetSummarizeArtifacts(true)
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "echo a lot"},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Result     string `json:"result"`
+		Summary    string `json:"summary"`
+		ArtifactID string `json:"artifact_id"`
+	}
+	for _, ev := range all {
+		if ev.Type == "tool_result" {
+			json.Unmarshal(ev.Payload, &result)
+		}
+	}
+	if result.ArtifactID == "" {
+		t.Fatal("expected large result to be stored as an artifact")
+	}
+	if result.Summary != "300 identical lines of output." {
+		t.Errorf("unexpected summary %q", result.Summary)
+	}
+	if !strings.Contains(result.Result, result.Summary) || strings.Contains(result.Result, long[:100]) {
+		t.Errorf("expected tool_result to carry the summary instead of a cut, got %q", result.Result)
+	}
+	meta, err := artifacts.GetMeta(ctx, types.ArtifactID(result.ArtifactID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Summary != result.Summary {
+		t.Errorf("expected summary on artifact meta, got %q", meta.Summary)
+	}
+}
+
+func TestProcessRunPersistsInboundMetadata(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(&mockProvider{}), engine, sessions, events, artifacts, NewRegistry(), 10)
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event: &types.InboundEvent{
+			Source:     "test",
+			SessionKey: "test:user1",
+			Text:       "hi",
+			Metadata: &types.InboundMeta{
+				MessageID: "42",
+				ReplyTo:   &types.ReplyRef{MessageID: "41"},
+			},
+		},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p struct {
+		Metadata *types.InboundMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(all[0].Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Metadata == nil || p.Metadata.MessageID != "42" || p.Metadata.ReplyTo == nil {
+		t.Fatalf("expected metadata in user_message payload, got %s", all[0].Payload)
+	}
+	if p.Metadata.Version != types.InboundSchemaVersion {
+		t.Errorf("expected schema version stamped, got %d", p.Metadata.Version)
+	}
+}
+
+func TestProcessRunDetectsLanguage(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(&mockProvider{}), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	run := func(key types.SessionKey, source, text string) *types.SessionIndex {
+		sid, err := sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rt.ProcessRun(&gateway.Run{
+			ID:        types.NewRunID(),
+			SessionID: sid,
+			Event:     &types.InboundEvent{Source: source, SessionKey: key, Text: text},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sess, err := sessions.Get(ctx, sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sess
+	}
+
+	if sess := run("test:es", "test", "Hola, ¿qué tal? ¿Puedes ayudarme con la cena?"); sess.Language != "es" {
+		t.Errorf("expected Spanish detected, got %q", sess.Language)
+	}
+	// An explicit preference is never overridden.
+	if sess := run("test:es", "test", "Can you answer in English for this one?"); sess.Language != "es" {
+		t.Errorf("expected preference kept, got %q", sess.Language)
+	}
+	if sess := run("test:task", "task", "Hola, ¿qué tal? ¿Puedes ayudarme con la cena?"); sess.Language != "" {
+		t.Errorf("expected task prompts ignored, got %q", sess.Language)
+	}
+}
+
+// webTool returns canned search results as sources.
+type webTool struct{}
+
+func (webTool) Name() string                { return "web" }
+func (webTool) Description() string         { return "canned web results" }
+func (webTool) Parameters() json.RawMessage { return json.RawMessage(`{"type":"object"}`) }
+func (w webTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	result, _, err := w.ExecuteWithSources(ctx, args)
+	return result, err
+}
+func (webTool) ExecuteWithSources(ctx context.Context, args json.RawMessage) (string, []types.Source, error) {
+	return "results", []types.Source{
+		{URL: "https://www.example.com/a", Title: "A"},
+		{URL: "https://other.org/b", Title: "B"},
+		{URL: "https://docs.test/c", Fetched: true},
+		{URL: "https://www.example.com/a", Title: "A"},
+	}, nil
+}
+
+func TestProcessRunCitesSources(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "web", Arguments: json.RawMessage(`{}`)}}}},
+		{Content: "According to example.com, it works."},
+	}}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(webTool{})
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+	rt.SetCitations(true)
+
+	var reply string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", Text: "does it work?"},
+		OnComplete: func(resp string) { reply = resp },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	// The search result the reply names and the fetched page are cited;
+	// the unmentioned result and the duplicate are not.
+	want := "According to example.com, it works.\n\nSources:\n- https://www.example.com/a\n- https://docs.test/c"
+	if reply != want {
+		t.Errorf("reply = %q, want %q", reply, want)
+	}
+
+	all, err := events.Tail(ctx, sid, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tr struct {
+		Sources []types.Source `json:"sources"`
+	}
+	json.Unmarshal(all[2].Payload, &tr)
+	if len(tr.Sources) != 4 {
+		t.Errorf("expected tool_result to carry all 4 sources, got %s", all[2].Payload)
+	}
+	var am struct {
+		Text    string         `json:"text"`
+		Sources []types.Source `json:"sources"`
+	}
+	json.Unmarshal(all[3].Payload, &am)
+	if am.Text != "According to example.com, it works." || len(am.Sources) != 2 {
+		t.Errorf("expected stored reply without footer and 2 cited sources, got %s", all[3].Payload)
+	}
+}
+
+func TestProcessRunDryRun(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetToolConfirm(ctx, sessions, sid, "echo", true); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "echo", Arguments: json.RawMessage(`{"text":"done it"}`)}}}},
+		{Content: "I would echo 'done it'. Reply /confirm to run it."},
+		{Content: "Echoed: done it"},
+	}}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+
+	run := &gateway.Run{ID: types.NewRunID(), SessionID: sid, Event: &types.InboundEvent{Source: "test", Text: "echo done it"}}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call was planned, not run: the model saw a "not executed" result.
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var planned struct {
+		Result  string `json:"result"`
+		Planned bool   `json:"planned"`
+	}
+	json.Unmarshal(all[2].Payload, &planned)
+	if !planned.Planned || !strings.HasPrefix(planned.Result, "Not executed") {
+		t.Errorf("expected planned tool result, got %s", all[2].Payload)
+	}
+	sess, _ := sessions.Get(ctx, sid)
+	if len(sess.Pending) != 1 || sess.Pending[0].Tool != "echo" || string(sess.Pending[0].Arguments) != `{"text":"done it"}` {
+		t.Fatalf("expected one pending echo call, got %+v", sess.Pending)
+	}
+
+	// Confirming runs the recorded call before the model reports back.
+	var reply string
+	confirm := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", Text: "Confirmed.", Confirm: true},
+		OnComplete: func(resp string) { reply = resp },
+	}
+	if err := rt.ProcessRun(confirm); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Echoed: done it" {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	all, err = events.Tail(ctx, sid, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[1].Type != "tool_call" || all[2].Type != "tool_result" {
+		t.Fatalf("expected confirmed tool_call and tool_result, got %s, %s", all[1].Type, all[2].Type)
+	}
+	var executed struct {
+		Result string `json:"result"`
+	}
+	json.Unmarshal(all[2].Payload, &executed)
+	if executed.Result != "done it" {
+		t.Errorf("expected the echo to run, got %s", all[2].Payload)
+	}
+	if sess, _ := sessions.Get(ctx, sid); len(sess.Pending) != 0 {
+		t.Errorf("expected pending calls cleared, got %+v", sess.Pending)
+	}
+}
+
+func TestProcessRunVerbosity(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetVerbosity(map[string]ctxengine.Verbosity{
+		"telegram": {Style: ctxengine.VerbosityBrief, MaxChars: 40},
+		"http":     {Style: ctxengine.VerbosityRaw},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	essay := "The deploy finished at noon. Every service is healthy and the error rate is back to normal."
+
+	run := func(key types.SessionKey, text string, responses ...*llm.Response) (string, map[string]any) {
+		sid, err := sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply string
+		rt := New(SingleModel(&mockProvider{responses: responses}), engine, sessions, events, artifacts, NewRegistry(), 10)
+		if err := rt.ProcessRun(&gateway.Run{
+			ID:         types.NewRunID(),
+			SessionID:  sid,
+			Event:      &types.InboundEvent{Source: "test", SessionKey: key, Text: text},
+			OnComplete: func(resp string) { reply = resp },
+		}); err != nil {
+			t.Fatal(err)
+		}
+		last, err := events.Tail(ctx, sid, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload map[string]any
+		json.Unmarshal(last[0].Payload, &payload)
+		return reply, payload
+	}
+
+	// Too long for a brief channel: the model's rewrite is used.
+	reply, payload := run("telegram:1:1", "how did the deploy go?", &llm.Response{Content: essay}, &llm.Response{Content: "Deploy done at noon; all healthy."})
+	if reply != "Deploy done at noon; all healthy." || payload["text"] != reply || payload["shortened_from"] == nil {
+		t.Errorf("expected the shortened reply stored and delivered, got %q / %v", reply, payload)
+	}
+	// A rewrite that is still too long falls back to a cut at a sentence.
+	reply, _ = run("telegram:1:1", "how did the deploy go?", &llm.Response{Content: essay}, &llm.Response{Content: essay})
+	if reply != "The deploy finished at noon.…" {
+		t.Errorf("expected a cut reply, got %q", reply)
+	}
+	// Asking for detail lifts the limit.
+	if reply, _ = run("telegram:1:1", "Explain the deploy in detail", &llm.Response{Content: essay}); reply != essay {
+		t.Errorf("expected the full reply, got %q", reply)
+	}
+	// Raw channels get the content without a wrapping fence.
+	if reply, _ = run("http:report", "dump it", &llm.Response{Content: "```json\n{\"ok\":true}\n```"}); reply != `{"ok":true}` {
+		t.Errorf("expected the unwrapped reply, got %q", reply)
+	}
+	// Channels without a profile are untouched.
+	if reply, _ = run("test:x", "how did the deploy go?", &llm.Response{Content: essay}); reply != essay {
+		t.Errorf("expected the reply unchanged, got %q", reply)
+	}
+}
+
+// streamProvider streams its reply one word per delta and fails Complete,
+// so tests can tell which path the runtime took.
+type streamProvider struct{ reply string }
+
+func (streamProvider) Complete(context.Context, []llm.Message, []llm.Tool) (*llm.Response, error) {
+	return nil, errors.New("unexpected Complete")
+}
+
+func (p streamProvider) Stream(context.Context, []llm.Message, []llm.Tool) (<-chan llm.Delta, error) {
+	words := strings.SplitAfter(p.reply, " ")
+	ch := make(chan llm.Delta, len(words)+1)
+	for _, w := range words {
+		ch <- llm.Delta{Content: w}
+	}
+	ch <- llm.Delta{FinishReason: "stop", Provider: "stub", Model: "stub-1"}
+	close(ch)
+	return ch, nil
+}
+
+func TestProcessRunStreamsPartials(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(streamProvider{reply: "Hello there friend"}), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	var partials []string
+	var response string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "hi"},
+		OnComplete: func(resp string) { response = resp },
+		OnPartial:  func(text string) { partials = append(partials, text) },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Hello ", "Hello there ", "Hello there friend"}
+	if strings.Join(partials, "|") != strings.Join(want, "|") {
+		t.Errorf("partials = %q, want %q", partials, want)
+	}
+	if response != "Hello there friend" {
+		t.Errorf("response = %q", response)
+	}
+
+	all, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || !strings.Contains(string(all[1].Payload), `"stub-1"`) {
+		t.Errorf("expected an annotated assistant message, got %d events", len(all))
+	}
+}
+
+func TestProcessRunReportsProgress(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{ToolCalls: []llm.ToolCall{{
+				ID:       "tc1",
+				Type:     "function",
+				Function: llm.FunctionCall{Name: "echo", Arguments: json.RawMessage(`{"text":"world"}`)},
+			}}},
+			{Content: "done"},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+
+	var steps []gateway.Progress
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "echo world"},
+		OnProgress: func(p gateway.Progress) { steps = append(steps, p) },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	want := []gateway.Progress{
+		{Round: 1, Status: "thinking"},
+		{Round: 1, Tool: "echo", Status: "using echo"},
+		{Round: 2, Status: "thinking"},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("progress = %+v, want %+v", steps, want)
+	}
+}
+
+func TestProcessRunResult(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, _ := json.Marshal(map[string]string{"text": strings.Repeat("x", artifactThreshold+1)})
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{
+				ToolCalls: []llm.ToolCall{
+					{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "echo", Arguments: long}},
+					{ID: "tc2", Type: "function", Function: llm.FunctionCall{Name: "missing", Arguments: json.RawMessage(`{}`)}},
+				},
+				Usage: llm.Usage{InputTokens: 100, OutputTokens: 10, TotalTokens: 110},
+			},
+			{Content: "done", Usage: llm.Usage{InputTokens: 200, OutputTokens: 5, TotalTokens: 205}},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+
+	var result *gateway.RunResult
+	var text string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "echo"},
+		OnResult:   func(r *gateway.RunResult) { result = r },
+		OnComplete: func(response string) { text = response },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || text != "done" {
+		t.Fatalf("result = %+v, text = %q", result, text)
+	}
+	if result.RunID != run.ID || result.SessionID != sid || result.Text != "done" || result.Err != nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if want := (llm.Usage{InputTokens: 300, OutputTokens: 15, TotalTokens: 315}); result.Usage != want {
+		t.Errorf("usage = %+v, want %+v", result.Usage, want)
+	}
+	if len(result.ToolCalls) != 2 {
+		t.Fatalf("tool calls = %+v", result.ToolCalls)
+	}
+	echo, missing := result.ToolCalls[0], result.ToolCalls[1]
+	if echo.Tool != "echo" || echo.CallID != "tc1" || echo.Error || echo.ArtifactID == "" {
+		t.Errorf("unexpected echo call %+v", echo)
+	}
+	if missing.Tool != "missing" || !missing.Error || missing.ArtifactID != "" {
+		t.Errorf("unexpected missing call %+v", missing)
+	}
+	if len(result.Artifacts) != 1 || result.Artifacts[0] != echo.ArtifactID {
+		t.Errorf("artifacts = %v", result.Artifacts)
+	}
+
+	// Each LLM call is recorded as a usage event.
+	all, err := events.Tail(ctx, sid, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded []usage.Payload
+	for _, ev := range all {
+		if ev.Type == "usage" {
+			var p usage.Payload
+			json.Unmarshal(ev.Payload, &p)
+			recorded = append(recorded, p)
+		}
+	}
+	if len(recorded) != 2 || recorded[0].Purpose != usage.Reply || recorded[0].InputTokens != 100 || recorded[1].OutputTokens != 5 {
+		t.Errorf("usage events = %+v", recorded)
+	}
+}
+
+func TestProcessRunToolAlias(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{
+		responses: []*llm.Response{
+			{ToolCalls: []llm.ToolCall{{
+				ID:       "tc1",
+				Type:     "function",
+				Function: llm.FunctionCall{Name: "parrot", Arguments: json.RawMessage(`{"text":"polly"}`)},
+			}}},
+			{Content: "done"},
+		},
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Register(&echoTool{})
+	if err := registry.SetOverrides(map[string]ToolOverride{"echo": {Name: "parrot"}}); err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
+
+	var steps []gateway.Progress
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "say polly"},
+		OnProgress: func(p gateway.Progress) { steps = append(steps, p) },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	evts, err := events.Tail(ctx, sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Tool   string `json:"tool"`
+		Result string `json:"result"`
+	}
+	for _, ev := range evts {
+		if ev.Type == "tool_result" {
+			json.Unmarshal(ev.Payload, &result)
+		}
+	}
+	if result.Tool != "parrot" || result.Result != "polly" {
+		t.Errorf("unexpected tool result %+v", result)
+	}
+	if len(steps) < 2 || steps[1].Tool != "echo" {
+		t.Errorf("expected progress under the registered name, got %+v", steps)
+	}
+}
+
+func TestProcessRunSummarizesHistory(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sessions.Get(ctx, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe, err := ctxengine.New("gpt-4", 128000, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys := probe.Summarize(session, nil, nil).SystemPromptTokens
+	engine, err := ctxengine.New("gpt-4", sys+100+1000, 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := strings.Repeat("the quick brown fox jumps over the lazy dog ", 5)
+	for i := range 20 {
+		typ := "user_message"
+		if i%2 == 1 {
+			typ = "assistant_message"
+		}
+		payload, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("%d %s", i, line)})
+		if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, Type: typ, Source: "test", At: time.Now(), Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider := &mockProvider{responses: []*llm.Response{
+		{Content: "The user has been chatting about foxes."},
+		{Content: "Still about foxes?"},
+	}}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, NewRegistry(), 10)
+	rt.SetSummarizeHistory(true)
+
+	var response string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "and now?"},
+		OnComplete: func(resp string) { response = resp },
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if response != "Still about foxes?" || provider.callCount != 2 {
+		t.Fatalf("expected a summary call then the reply, got %q after %d calls", response, provider.callCount)
+	}
+
+	all, err := events.Tail(ctx, sid, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Text       string `json:"text"`
+		ThroughSeq int64  `json:"through_seq"`
+	}
+	for _, ev := range all {
+		if ev.Type == "conversation_summary" {
+			json.Unmarshal(ev.Payload, &summary)
+		}
+	}
+	if summary.Text != "The user has been chatting about foxes." || summary.ThroughSeq < 1 || summary.ThroughSeq >= 20 {
+		t.Errorf("unexpected conversation summary %+v", summary)
+	}
+}
+
+func TestProcessRunDeniedTool(t *testing.T) {
+	bash := tools.NewBash(tools.BashPolicy{Deny: []*regexp.Regexp{regexp.MustCompile(`rm`)}})
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "bash", Arguments: json.RawMessage(`{"command":"rm -rf /"}`)}}}},
+		{Content: "not allowed"},
+	}}
+	rt, events, sid := newTimeoutRuntime(t, provider, bash)
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+	}
+	if err := rt.ProcessRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	evts, err := events.Tail(context.Background(), sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Result string `json:"result"`
+		Denied bool   `json:"denied"`
+	}
+	for _, ev := range evts {
+		if ev.Type == "tool_result" {
+			json.Unmarshal(ev.Payload, &result)
+		}
+	}
+	if !result.Denied || !strings.Contains(result.Result, "bash.deny") {
+		t.Errorf("unexpected tool result %+v", result)
+	}
+}
+
+func TestProcessRunResumed(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	ctx := context.Background()
+	key := types.NewSessionKey("test", "user1")
+	sid, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{responses: []*llm.Response{{Content: "First answer"}}}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	// The previous process recorded the user message, then died.
+	runID := types.NewRunID()
+	userPayload, _ := json.Marshal(map[string]string{"text": "hi"})
+	events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, RunID: runID, Type: "user_message", Source: "test", At: time.Now(), Payload: userPayload})
+
+	resumed := func() (string, error) {
+		var reply string
+		err := rt.ProcessRun(&gateway.Run{
+			ID:         runID,
+			SessionID:  sid,
+			Event:      &types.InboundEvent{Source: "test", SessionKey: key, UserID: "user1", Text: "hi"},
+			Resumed:    true,
+			OnComplete: func(resp string) { reply = resp },
+		})
+		return reply, err
+	}
+	reply, err := resumed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "First answer" {
+		t.Errorf("reply = %q", reply)
+	}
+	if n, _ := events.Count(ctx, sid); n != 2 {
+		t.Errorf("expected the user message once and the reply, got %d events", n)
+	}
+
+	// Resumed again after the reply was recorded: no second LLM call.
+	reply, err = resumed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "First answer" || provider.callCount != 1 {
+		t.Errorf("reply = %q after %d LLM calls, want the recorded reply without a new call", reply, provider.callCount)
+	}
+	if n, _ := events.Count(ctx, sid); n != 2 {
+		t.Errorf("expected no new events, got %d", n)
+	}
 }
@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// seedHistory is how many events of the archived session are summarized.
+const seedHistory = 100
+
+const seedPrompt = `Summarize the conversation below in one short paragraph for the assistant's own future reference. Capture the user's goals, decisions made, open questions and any in-progress work so the conversation can continue in a fresh session. Write only the summary.`
+
+// SeedSession summarizes the conversation in session from and records the
+// summary as a session_summary event in session to, so a rotated session
+// keeps mid-project continuity. It is a no-op if from has no messages.
+// Long-term memories are not included since the system prompt already
+// carries them into every session.
+func (rt *Runtime) SeedSession(ctx context.Context, from, to types.SessionID) error {
+	events, err := rt.events.Tail(ctx, from, seedHistory)
+	if err != nil {
+		return fmt.Errorf("load archived events: %w", err)
+	}
+
+	var transcript strings.Builder
+	for _, ev := range events {
+		var role string
+		switch ev.Type {
+		case "user_message":
+			role = "User"
+		case "assistant_message":
+			role = "Assistant"
+		case "session_summary":
+			role = "Earlier summary"
+		case "error":
+			role = "Error"
+		default:
+			continue
+		}
+		var p struct {
+			Text    string `json:"text"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			continue
+		}
+		text := p.Text
+		if ev.Type == "error" {
+			text = p.Message
+		}
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", role, text)
+	}
+	if transcript.Len() == 0 {
+		return nil
+	}
+
+	if err := rt.allowCall(to); err != nil {
+		return fmt.Errorf("summarize session: %w", err)
+	}
+	resp, err := rt.sessionProvider(ctx, to, usage.Seed).Complete(ctx, []llm.Message{
+		{Role: "system", Content: seedPrompt},
+		{Role: "user", Content: transcript.String()},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("summarize session: %w", err)
+	}
+	rt.recordUsage(ctx, to, "", usage.Seed, resp)
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return nil
+	}
+
+	payload, _ := json.Marshal(map[string]string{
+		"text":         summary,
+		"from_session": string(from),
+	})
+	if err := rt.events.Append(ctx, &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: to,
+		Type:      "session_summary",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}); err != nil {
+		return fmt.Errorf("record session summary: %w", err)
+	}
+	return nil
+}
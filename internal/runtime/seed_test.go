@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestSeedSession(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	ctx := context.Background()
+	key := types.NewSessionKey("test", "user1")
+	oldSID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []struct{ typ, text string }{
+		{"user_message", "let's plan the migration"},
+		{"assistant_message", "step one is the schema"},
+	} {
+		payload, _ := json.Marshal(map[string]string{"text": ev.text})
+		if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: oldSID, Type: ev.typ, At: time.Now(), Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sessions.Rotate(ctx, key, ""); err != nil {
+		t.Fatal(err)
+	}
+	newSID, err := sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockProvider{responses: []*llm.Response{{Content: "Planning a migration; schema is step one."}}}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := New(SingleModel(provider), engine, sessions, events, artifacts, NewRegistry(), 10)
+
+	if err := rt.SeedSession(ctx, oldSID, newSID); err != nil {
+		t.Fatal(err)
+	}
+
+	seeded, err := events.Tail(ctx, newSID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeded) != 1 || seeded[0].Type != "session_summary" {
+		t.Fatalf("expected one session_summary event, got %+v", seeded)
+	}
+	var p struct {
+		Text        string `json:"text"`
+		FromSession string `json:"from_session"`
+	}
+	if err := json.Unmarshal(seeded[0].Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Text != "Planning a migration; schema is step one." || p.FromSession != string(oldSID) {
+		t.Errorf("unexpected summary payload: %s", seeded[0].Payload)
+	}
+
+	// The summary is part of the next prompt
+	session, err := sessions.Get(ctx, newSID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := engine.BuildPrompt(ctx, session, seeded, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[1].Role != "system" {
+		t.Fatalf("expected summary as a system message, got %+v", messages)
+	}
+}
+
+func TestSeedSessionEmptyHistory(t *testing.T) {
+	dir := t.TempDir()
+	provider := &mockProvider{}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := state.NewEventStore(dir)
+	rt := New(SingleModel(provider), engine, state.NewSessionStore(dir), events, state.NewArtifactStore(dir), NewRegistry(), 10)
+
+	if err := rt.SeedSession(context.Background(), "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if provider.callCount != 0 {
+		t.Errorf("expected no LLM call for an empty session, got %d", provider.callCount)
+	}
+	if n, _ := events.Count(context.Background(), "new"); n != 0 {
+		t.Errorf("expected no events in new session, got %d", n)
+	}
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"context"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// complete makes one LLM call answering the run, with the provider its
+// model selector picks for a reply. Runs with an OnPartial callback
+// are streamed, and the callback receives the reply text accumulated so
+// far each time more content arrives; the deltas are then collected into
+// the same Response a plain Complete would return. It fails without a call
+// when the run's session is over budget.
+func (rt *Runtime) complete(ctx context.Context, run *gateway.Run, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	if err := rt.allowCall(run.SessionID); err != nil {
+		return nil, err
+	}
+	provider := rt.provider(run, usage.Reply)
+	if run.OnPartial == nil {
+		return provider.Complete(ctx, messages, tools)
+	}
+	deltas, err := provider.Stream(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buffered []llm.Delta
+		text     string
+	)
+	for d := range deltas {
+		buffered = append(buffered, d)
+		if d.Content != "" {
+			text += d.Content
+			run.OnPartial(text)
+		}
+	}
+	replay := make(chan llm.Delta, len(buffered))
+	for _, d := range buffered {
+		replay <- d
+	}
+	close(replay)
+	return llm.Collect(replay)
+}
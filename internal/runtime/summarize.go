@@ -0,0 +1,58 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// summaryInputLimit caps how much of a large tool output is sent to the
+// model for summarization.
+const summaryInputLimit = 12000
+
+const summarizePrompt = `Summarize the following output of the %q tool in at most 150 words. ` +
+	`Keep concrete facts, numbers, names, errors and anything a follow-up step would need. ` +
+	`Reply with the summary only.`
+
+// summarizeArtifact asks the model for a short summary of a large tool
+// result and records it on the artifact.
+func (rt *Runtime) summarizeArtifact(ctx context.Context, run *gateway.Run, id types.ArtifactID, tool, result string) (string, error) {
+	input := result
+	if len(input) > summaryInputLimit {
+		input = input[:summaryInputLimit] + "\n[output continues]"
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: fmt.Sprintf(summarizePrompt, tool)},
+		{Role: "user", Content: input},
+	}
+	if err := rt.allowCall(run.SessionID); err != nil {
+		return "", fmt.Errorf("summarize artifact: %w", err)
+	}
+	resp, err := rt.provider(run, usage.Summarize).Complete(ctx, messages, nil)
+	if err != nil {
+		return "", fmt.Errorf("summarize artifact: %w", err)
+	}
+	rt.recordUsage(ctx, run.SessionID, run.ID, usage.Summarize, resp)
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("summarize artifact: empty summary")
+	}
+	if err := rt.artifacts.SetSummary(ctx, id, summary); err != nil {
+		return "", fmt.Errorf("save artifact summary: %w", err)
+	}
+	return summary, nil
+}
+
+// truncatedResult is the tool_result text for an output stored as an
+// artifact: the summary when there is one, otherwise a plain prefix.
+func truncatedResult(result string, id types.ArtifactID, summary string) string {
+	if summary != "" {
+		return fmt.Sprintf("Summary of full output (%d chars, see artifact %s):\n%s", len(result), id, summary)
+	}
+	return result[:artifactThreshold] + "\n[truncated, see artifact " + string(id) + "]"
+}
@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// ErrRunTimeout is the cause of a run's context being cancelled when the run
+// outlasts its timeout.
+var ErrRunTimeout = errors.New("run timed out")
+
+// errToolTimeout is the cause of a tool call's context being cancelled when
+// the call outlasts its timeout.
+var errToolTimeout = errors.New("tool timed out")
+
+// SetToolTimeout bounds each tool call to d, or to the duration in perTool
+// for the tools named there. A call that runs over is abandoned and the
+// model sees a "timed out" result. Zero means no limit.
+func (rt *Runtime) SetToolTimeout(d time.Duration, perTool map[string]time.Duration) {
+	rt.toolTimeout = d
+	rt.toolTimeouts = perTool
+}
+
+// SetRunTimeout bounds each run, all of its LLM calls and tool calls
+// together, to d. A run that runs over fails. Zero means no limit.
+func (rt *Runtime) SetRunTimeout(d time.Duration) {
+	rt.runTimeout = d
+}
+
+// toolTimeoutFor returns the timeout for a tool, or zero for none.
+func (rt *Runtime) toolTimeoutFor(tool string) time.Duration {
+	if d, ok := rt.toolTimeouts[tool]; ok {
+		return d
+	}
+	return rt.toolTimeout
+}
+
+// callTool runs call under the tool's timeout. A tool that ignores its
+// context's cancellation is left to finish in the background, so it can't
+// hold up the session's lane; its result is discarded.
+func (rt *Runtime) callTool(ctx context.Context, tool string, call func(ctx context.Context) (string, []types.Source, error)) (string, []types.Source, error) {
+	limit := rt.toolTimeoutFor(tool)
+	if limit <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, limit, errToolTimeout)
+	defer cancel()
+
+	type outcome struct {
+		result string
+		found  []types.Source
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, found, err := call(ctx)
+		done <- outcome{result, found, err}
+	}()
+	select {
+	case o := <-done:
+		if context.Cause(ctx) == errToolTimeout {
+			return "", nil, fmt.Errorf("%w after %s", errToolTimeout, limit)
+		}
+		return o.result, o.found, o.err
+	case <-ctx.Done():
+		if context.Cause(ctx) == errToolTimeout {
+			return "", nil, fmt.Errorf("%w after %s", errToolTimeout, limit)
+		}
+		return "", nil, context.Cause(ctx)
+	}
+}
+
+// timeoutEvent builds a timeout event for a run or one of its tool calls.
+func timeoutEvent(run *gateway.Run, fields map[string]any, limit time.Duration, message string) *types.Event {
+	fields["after_ms"] = limit.Milliseconds()
+	fields["message"] = message
+	payload, _ := json.Marshal(fields)
+	return &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Type:      "timeout",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}
+}
@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// stuckTool ignores cancellation and never returns on its own.
+type stuckTool struct{ release chan struct{} }
+
+func (s *stuckTool) Name() string        { return "stuck" }
+func (s *stuckTool) Description() string { return "Hangs" }
+func (s *stuckTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{}}`)
+}
+func (s *stuckTool) Execute(context.Context, json.RawMessage) (string, error) {
+	<-s.release
+	return "too late", nil
+}
+
+// blockingProvider answers only once its context is done.
+type blockingProvider struct{ mockProvider }
+
+func (b *blockingProvider) Complete(ctx context.Context, _ []llm.Message, _ []llm.Tool) (*llm.Response, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func newTimeoutRuntime(t *testing.T, provider llm.Provider, tools ...Tool) (*Runtime, *state.EventStore, types.SessionID) {
+	t.Helper()
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	sid, err := sessions.ResolveOrCreate(context.Background(), types.NewSessionKey("test", "user1"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := ctxengine.New("gpt-4", 128000, 4096, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	for _, tool := range tools {
+		registry.Register(tool)
+	}
+	return New(SingleModel(provider), engine, sessions, events, state.NewArtifactStore(dir), registry, 10), events, sid
+}
+
+func TestToolTimeout(t *testing.T) {
+	stuck := &stuckTool{release: make(chan struct{})}
+	defer close(stuck.release)
+	provider := &mockProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "tc1", Type: "function", Function: llm.FunctionCall{Name: "stuck", Arguments: json.RawMessage(`{}`)}}}},
+		{Content: "it hung"},
+	}}
+	rt, events, sid := newTimeoutRuntime(t, provider, stuck, &echoTool{})
+	rt.SetToolTimeout(time.Hour, map[string]time.Duration{"stuck": 20 * time.Millisecond})
+
+	var response string
+	run := &gateway.Run{
+		ID:         types.NewRunID(),
+		SessionID:  sid,
+		Event:      &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+		OnComplete: func(r string) { response = r },
+	}
+	done := make(chan error, 1)
+	go func() { done <- rt.ProcessRun(run) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run hung on a stuck tool")
+	}
+	if response != "it hung" {
+		t.Errorf("response = %q", response)
+	}
+
+	evts, err := events.Tail(context.Background(), sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	var result struct {
+		Result   string `json:"result"`
+		TimedOut bool   `json:"timed_out"`
+	}
+	for _, ev := range evts {
+		kinds = append(kinds, ev.Type)
+		if ev.Type == "tool_result" {
+			json.Unmarshal(ev.Payload, &result)
+		}
+	}
+	if got := strings.Join(kinds, ","); got != "user_message,tool_call,tool_result,timeout,assistant_message" {
+		t.Errorf("events = %s", got)
+	}
+	if !result.TimedOut || !strings.Contains(result.Result, "tool timed out after 20ms") {
+		t.Errorf("unexpected tool result %+v", result)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	rt, events, sid := newTimeoutRuntime(t, &blockingProvider{})
+	rt.SetRunTimeout(20 * time.Millisecond)
+
+	run := &gateway.Run{
+		ID:        types.NewRunID(),
+		SessionID: sid,
+		Event:     &types.InboundEvent{Source: "test", SessionKey: "test:user1", Text: "go"},
+	}
+	err := rt.ProcessRun(run)
+	if !errors.Is(err, ErrRunTimeout) {
+		t.Fatalf("expected a run timeout, got %v", err)
+	}
+
+	evts, err := events.Tail(context.Background(), sid, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 3 || evts[1].Type != "timeout" || evts[2].Type != "error" {
+		t.Fatalf("unexpected events %+v", evts)
+	}
+	if !strings.Contains(string(evts[2].Payload), "run timed out after 20ms") {
+		t.Errorf("unexpected error event %s", evts[2].Payload)
+	}
+}
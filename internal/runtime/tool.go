@@ -3,7 +3,10 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"regexp"
 
+	"github.com/user/gopherclaw/internal/types"
 	"github.com/user/gopherclaw/pkg/llm"
 )
 
@@ -15,11 +18,48 @@ type Tool interface {
 	Execute(ctx context.Context, args json.RawMessage) (string, error)
 }
 
+// SourcedTool is a Tool whose results come from web pages. The runtime
+// calls ExecuteWithSources instead of Execute and records the sources on
+// the tool_result event for citations.
+type SourcedTool interface {
+	Tool
+	ExecuteWithSources(ctx context.Context, args json.RawMessage) (string, []types.Source, error)
+}
+
+// SessionTool is a Tool that acts on the conversation it is called from,
+// such as setting a reminder for it. The runtime calls ExecuteInSession
+// instead of Execute.
+type SessionTool interface {
+	Tool
+	ExecuteInSession(ctx context.Context, session *types.SessionIndex, args json.RawMessage) (string, error)
+}
+
 // Registry holds registered tools and provides lookup.
 type Registry struct {
 	tools map[string]Tool
+	// overrides change how tools are presented to the model, by registered
+	// name; aliases maps the names they expose back. See SetOverrides.
+	overrides map[string]ToolOverride
+	aliases   map[string]string
+}
+
+// ToolOverride changes how a tool is presented to the model without
+// changing what it does. Empty fields keep the tool's own.
+type ToolOverride struct {
+	// Name is the name the model sees and calls the tool by. Events record
+	// calls under it; tool policies keep using the registered name.
+	Name        string
+	Description string
+	// Parameters replaces the descriptions of the named parameters in the
+	// tool's schema.
+	Parameters map[string]string
+
+	schema json.RawMessage
 }
 
+// toolNamePattern is what providers accept as a function name.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
 // NewRegistry creates an empty tool registry.
 func NewRegistry() *Registry {
 	return &Registry{tools: make(map[string]Tool)}
@@ -30,12 +70,84 @@ func (r *Registry) Register(t Tool) {
 	r.tools[t.Name()] = t
 }
 
-// Get returns a tool by name.
+// Get returns a tool by its registered or exposed name.
 func (r *Registry) Get(name string) (Tool, bool) {
-	t, ok := r.tools[name]
+	t, ok := r.tools[r.Resolve(name)]
 	return t, ok
 }
 
+// SetOverrides sets how tools are presented to the model, keyed by
+// registered name. It checks them against the tools registered so far, so
+// call it once registration is done.
+func (r *Registry) SetOverrides(overrides map[string]ToolOverride) error {
+	aliases := make(map[string]string)
+	resolved := make(map[string]ToolOverride, len(overrides))
+	for name, o := range overrides {
+		t, ok := r.tools[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown tool", name)
+		}
+		if o.Name != "" && o.Name != name {
+			if !toolNamePattern.MatchString(o.Name) {
+				return fmt.Errorf("%s: invalid name %q: use up to 64 letters, digits, _ or -", name, o.Name)
+			}
+			if _, taken := r.tools[o.Name]; taken {
+				return fmt.Errorf("%s: name %q is another tool's", name, o.Name)
+			}
+			if other, taken := aliases[o.Name]; taken {
+				return fmt.Errorf("%s: name %q is already used by %s", name, o.Name, other)
+			}
+			aliases[o.Name] = name
+		}
+		if len(o.Parameters) > 0 {
+			schema, err := describeParameters(t.Parameters(), o.Parameters)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			o.schema = schema
+		}
+		resolved[name] = o
+	}
+	r.overrides = resolved
+	r.aliases = aliases
+	return nil
+}
+
+// Resolve returns the registered name of a tool given the name the model
+// called it by. Names that aren't aliases are returned as they are.
+func (r *Registry) Resolve(name string) string {
+	if registered, ok := r.aliases[name]; ok {
+		return registered
+	}
+	return name
+}
+
+// ExposedName returns the name the model knows a registered tool by.
+func (r *Registry) ExposedName(name string) string {
+	if o := r.overrides[name]; o.Name != "" {
+		return o.Name
+	}
+	return name
+}
+
+// describeParameters returns a JSON schema with the descriptions of the
+// named properties replaced.
+func describeParameters(schema json.RawMessage, descriptions map[string]string) (json.RawMessage, error) {
+	var s map[string]any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("parse parameters: %w", err)
+	}
+	props, _ := s["properties"].(map[string]any)
+	for param, desc := range descriptions {
+		prop, ok := props[param].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %q", param)
+		}
+		prop["description"] = desc
+	}
+	return json.Marshal(s)
+}
+
 // All returns all registered tools.
 func (r *Registry) All() []Tool {
 	out := make([]Tool, 0, len(r.tools))
@@ -47,16 +159,39 @@ func (r *Registry) All() []Tool {
 
 // AsLLMTools converts registered tools to the LLM provider format.
 func (r *Registry) AsLLMTools() []llm.Tool {
+	return r.AsLLMToolsExcept(nil)
+}
+
+// AsLLMToolsExcept converts registered tools to the LLM provider format
+// with their overrides applied, leaving out the named tools (by registered
+// name).
+func (r *Registry) AsLLMToolsExcept(exclude []string) []llm.Tool {
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
 	out := make([]llm.Tool, 0, len(r.tools))
 	for _, t := range r.tools {
-		out = append(out, llm.Tool{
-			Type: "function",
-			Function: llm.Function{
-				Name:        t.Name(),
-				Description: t.Description(),
-				Parameters:  t.Parameters(),
-			},
-		})
+		if skip[t.Name()] {
+			continue
+		}
+		fn := llm.Function{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  t.Parameters(),
+		}
+		if o, ok := r.overrides[t.Name()]; ok {
+			if o.Name != "" {
+				fn.Name = o.Name
+			}
+			if o.Description != "" {
+				fn.Description = o.Description
+			}
+			if o.schema != nil {
+				fn.Parameters = o.schema
+			}
+		}
+		out = append(out, llm.Tool{Type: "function", Function: fn})
 	}
 	return out
 }
@@ -65,3 +65,54 @@ func TestRegistryAsLLMTools(t *testing.T) {
 		t.Errorf("expected type 'function', got %q", llmTools[0].Type)
 	}
 }
+
+func TestRegistryOverrides(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&echoTool{})
+	err := r.SetOverrides(map[string]ToolOverride{
+		"echo": {Name: "parrot", Description: "Repeats text verbatim", Parameters: map[string]string{"text": "What to repeat"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn := r.AsLLMTools()[0].Function
+	if fn.Name != "parrot" || fn.Description != "Repeats text verbatim" {
+		t.Errorf("unexpected function %+v", fn)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(fn.Parameters, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if p := schema.Properties["text"]; p.Type != "string" || p.Description != "What to repeat" || len(schema.Required) != 1 {
+		t.Errorf("unexpected schema %s", fn.Parameters)
+	}
+
+	if r.Resolve("parrot") != "echo" || r.Resolve("echo") != "echo" || r.ExposedName("echo") != "parrot" {
+		t.Error("alias not resolved")
+	}
+	if _, ok := r.Get("parrot"); !ok {
+		t.Error("expected to find echo by its exposed name")
+	}
+	if len(r.AsLLMToolsExcept([]string{"echo"})) != 0 {
+		t.Error("expected exclusion by registered name")
+	}
+
+	for name, o := range map[string]ToolOverride{
+		"missing": {Name: "x"},
+		"echo":    {Name: "has space"},
+	} {
+		if err := r.SetOverrides(map[string]ToolOverride{name: o}); err == nil {
+			t.Errorf("%s %+v: expected error", name, o)
+		}
+	}
+	if err := r.SetOverrides(map[string]ToolOverride{"echo": {Parameters: map[string]string{"nope": "x"}}}); err == nil {
+		t.Error("expected error for unknown parameter")
+	}
+}
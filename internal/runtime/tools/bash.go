@@ -1,18 +1,64 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+)
+
+// BashPolicy restricts what the bash tool runs and how. The zero policy
+// runs any command as the gopherclaw process.
+type BashPolicy struct {
+	// Allow, if set, admits only commands matching at least one pattern.
+	Allow []*regexp.Regexp
+	// Deny rejects commands matching any pattern, even allowed ones.
+	Deny []*regexp.Regexp
+	// Dir jails commands to a working directory: they start there with
+	// HOME pointing at it, and commands naming an absolute path outside it
+	// or a ".." path are rejected. This guards against mistakes, not a
+	// determined shell; use Wrapper for isolation.
+	Dir string
+	// MaxOutput caps the bytes of output returned to the model; 0 means
+	// no cap.
+	MaxOutput int
+	// Wrapper is a command line that runs "bash -c <command>" on the
+	// commands' behalf, e.g. ["sudo", "-u", "agent"] or
+	// ["docker", "exec", "-i", "sandbox"].
+	Wrapper []string
+}
+
+// devicePaths may be named by jailed commands wherever the jail is.
+var devicePaths = map[string]bool{"/dev/null": true, "/dev/stdin": true, "/dev/stdout": true, "/dev/stderr": true}
+
+var (
+	// absPath matches a word, or the part of one after a redirection or an
+	// assignment, that starts with a slash.
+	absPath = regexp.MustCompile(`(?:^|[\s=<>|;&('"])(/[^\s'";|&<>()]*)`)
+	// parentPath matches a ".." path component.
+	parentPath = regexp.MustCompile(`(?:^|[\s=<>|;&('"/])\.\.(?:$|[\s'";|&<>()/])`)
 )
 
 // Bash executes shell commands on the host.
-type Bash struct{}
+type Bash struct {
+	policy BashPolicy
+}
 
-// NewBash creates a new Bash tool.
-func NewBash() *Bash { return &Bash{} }
+// NewBash creates a new Bash tool that runs commands under policy.
+func NewBash(policy BashPolicy) *Bash {
+	if policy.Dir != "" {
+		policy.Dir = filepath.Clean(policy.Dir)
+	}
+	return &Bash{policy: policy}
+}
 
 func (b *Bash) Name() string        { return "bash" }
 func (b *Bash) Description() string { return "Execute a bash command on the host machine" }
@@ -33,10 +79,13 @@ func (b *Bash) Execute(ctx context.Context, args json.RawMessage) (string, error
 		TimeoutSeconds int    `json:"timeout_seconds"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
-		return "", fmt.Errorf("parse args: %w", err)
+		return "", invalidArgs("parse args: %w", err)
 	}
 	if params.Command == "" {
-		return "", fmt.Errorf("command is required")
+		return "", invalidArgs("command is required")
+	}
+	if err := b.check(params.Command); err != nil {
+		return "", err
 	}
 
 	timeout := 120 * time.Second
@@ -47,10 +96,82 @@ func (b *Bash) Execute(ctx context.Context, args json.RawMessage) (string, error
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", params.Command)
-	output, err := cmd.CombinedOutput()
+	argv := append(append([]string{}, b.policy.Wrapper...), "bash", "-c", params.Command)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if b.policy.Dir != "" {
+		cmd.Dir = b.policy.Dir
+		cmd.Env = append(os.Environ(), "HOME="+b.policy.Dir)
+	}
+	out := &cappedBuffer{max: b.policy.MaxOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	output := out.String()
 	if err != nil {
-		return string(output), fmt.Errorf("command failed: %w\nOutput: %s", err, string(output))
+		return output, fmt.Errorf("command failed: %w\nOutput: %s", err, output)
+	}
+	return output, nil
+}
+
+// check applies the policy's allow and deny lists and its jail to a
+// command, returning an error wrapping gateway.ErrToolDenied if the
+// command may not run.
+func (b *Bash) check(command string) error {
+	for _, re := range b.policy.Deny {
+		if re.MatchString(command) {
+			return fmt.Errorf("%w: command matches bash.deny pattern %q", gateway.ErrToolDenied, re.String())
+		}
+	}
+	if len(b.policy.Allow) > 0 {
+		allowed := false
+		for _, re := range b.policy.Allow {
+			if re.MatchString(command) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: command matches no bash.allow pattern", gateway.ErrToolDenied)
+		}
+	}
+	if b.policy.Dir == "" {
+		return nil
+	}
+	if parentPath.MatchString(command) {
+		return fmt.Errorf("%w: \"..\" paths are not allowed; commands run in %s", gateway.ErrToolDenied, b.policy.Dir)
+	}
+	for _, m := range absPath.FindAllStringSubmatch(command, -1) {
+		path := filepath.Clean(m[1])
+		if devicePaths[path] || path == b.policy.Dir || strings.HasPrefix(path, b.policy.Dir+"/") {
+			continue
+		}
+		return fmt.Errorf("%w: %s is outside %s, the only directory commands may use", gateway.ErrToolDenied, m[1], b.policy.Dir)
+	}
+	return nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and counts the
+// rest. A max of 0 keeps everything.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	max     int
+	dropped int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if c.max > 0 {
+		if room := c.max - c.buf.Len(); len(p) > room {
+			c.dropped += len(p) - max(room, 0)
+			c.buf.Write(p[:max(room, 0)])
+			return len(p), nil
+		}
+	}
+	return c.buf.Write(p)
+}
+
+func (c *cappedBuffer) String() string {
+	if c.dropped == 0 {
+		return c.buf.String()
 	}
-	return string(output), nil
+	return fmt.Sprintf("%s\n[output cut: %d more bytes]", c.buf.String(), c.dropped)
 }
@@ -3,20 +3,24 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
 )
 
 func TestBashName(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	if b.Name() != "bash" {
 		t.Errorf("expected 'bash', got %q", b.Name())
 	}
 }
 
 func TestBashExecuteSimple(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	args, _ := json.Marshal(map[string]string{"command": "echo hello"})
 	result, err := b.Execute(context.Background(), args)
 	if err != nil {
@@ -28,7 +32,7 @@ func TestBashExecuteSimple(t *testing.T) {
 }
 
 func TestBashExecuteStderr(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	args, _ := json.Marshal(map[string]string{"command": "echo err >&2"})
 	result, err := b.Execute(context.Background(), args)
 	if err != nil {
@@ -40,7 +44,7 @@ func TestBashExecuteStderr(t *testing.T) {
 }
 
 func TestBashExecuteTimeout(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	args, _ := json.Marshal(map[string]any{"command": "sleep 10", "timeout_seconds": 1})
 	start := time.Now()
 	_, err := b.Execute(context.Background(), args)
@@ -54,7 +58,7 @@ func TestBashExecuteTimeout(t *testing.T) {
 }
 
 func TestBashExecuteExitCode(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	args, _ := json.Marshal(map[string]string{"command": "exit 1"})
 	_, err := b.Execute(context.Background(), args)
 	if err == nil {
@@ -63,7 +67,7 @@ func TestBashExecuteExitCode(t *testing.T) {
 }
 
 func TestBashParameters(t *testing.T) {
-	b := NewBash()
+	b := NewBash(BashPolicy{})
 	var schema map[string]any
 	if err := json.Unmarshal(b.Parameters(), &schema); err != nil {
 		t.Fatal(err)
@@ -72,3 +76,64 @@ func TestBashParameters(t *testing.T) {
 		t.Errorf("expected object schema, got %v", schema["type"])
 	}
 }
+
+func TestBashPolicy(t *testing.T) {
+	dir := t.TempDir()
+	b := NewBash(BashPolicy{
+		Allow: []*regexp.Regexp{regexp.MustCompile(`^(echo|cat|ls|pwd)\b`)},
+		Deny:  []*regexp.Regexp{regexp.MustCompile(`\bsecret\b`)},
+		Dir:   dir,
+	})
+	cases := []struct {
+		command string
+		denied  bool
+	}{
+		{"echo hi > /dev/null", false},
+		{"ls " + dir + "/sub", false},
+		{"rm -rf x", true},
+		{"echo secret", true},
+		{"cat /etc/passwd", true},
+		{"cat ../x", true},
+		{"echo x>/tmp/y", true},
+	}
+	for _, c := range cases {
+		args, _ := json.Marshal(map[string]string{"command": c.command})
+		_, err := b.Execute(context.Background(), args)
+		if denied := errors.Is(err, gateway.ErrToolDenied); denied != c.denied {
+			t.Errorf("%q: denied = %v (err %v), want %v", c.command, denied, err, c.denied)
+		}
+	}
+
+	args, _ := json.Marshal(map[string]string{"command": "pwd; echo ~"})
+	result, err := b.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dir + "\n" + dir + "\n"; result != want {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
+
+func TestBashMaxOutput(t *testing.T) {
+	b := NewBash(BashPolicy{MaxOutput: 10})
+	args, _ := json.Marshal(map[string]string{"command": "printf '%050d' 0"})
+	result, err := b.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0000000000\n[output cut: 40 more bytes]"; result != want {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
+
+func TestBashWrapper(t *testing.T) {
+	b := NewBash(BashPolicy{Wrapper: []string{"env", "WRAPPED=yes"}})
+	args, _ := json.Marshal(map[string]string{"command": "echo $WRAPPED"})
+	result, err := b.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(result) != "yes" {
+		t.Errorf("expected the wrapper's environment, got %q", result)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 // BraveSearch searches the web via Brave Search API.
@@ -55,15 +58,21 @@ type braveResult struct {
 }
 
 func (b *BraveSearch) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	result, _, err := b.ExecuteWithSources(ctx, args)
+	return result, err
+}
+
+// ExecuteWithSources runs the search and also returns each result as a source.
+func (b *BraveSearch) ExecuteWithSources(ctx context.Context, args json.RawMessage) (string, []types.Source, error) {
 	var params struct {
 		Query string `json:"query"`
 		Count int    `json:"count"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
-		return "", fmt.Errorf("parse args: %w", err)
+		return "", nil, invalidArgs("parse args: %w", err)
 	}
 	if params.Query == "" {
-		return "", fmt.Errorf("query is required")
+		return "", nil, invalidArgs("query is required")
 	}
 	if params.Count <= 0 {
 		params.Count = 5
@@ -80,38 +89,40 @@ func (b *BraveSearch) Execute(ctx context.Context, args json.RawMessage) (string
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return "", nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-Subscription-Token", b.apiKey)
 
 	resp, err := b.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("search request: %w", err)
+		return "", nil, fmt.Errorf("search request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1 MB limit
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return "", nil, fmt.Errorf("read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Brave API error (status %d): %s", resp.StatusCode, string(body))
+		return "", nil, fmt.Errorf("Brave %w", &llm.APIError{Status: resp.StatusCode, Message: string(body)})
 	}
 
 	var result braveResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("parse response: %w", err)
+		return "", nil, fmt.Errorf("parse response: %w", err)
 	}
 
 	if len(result.Web.Results) == 0 {
-		return "No results found.", nil
+		return "No results found.", nil, nil
 	}
 
 	var sb strings.Builder
+	sources := make([]types.Source, 0, len(result.Web.Results))
 	for i, r := range result.Web.Results {
 		fmt.Fprintf(&sb, "%d. %s\n   %s\n   %s\n\n", i+1, r.Title, r.URL, r.Description)
+		sources = append(sources, types.Source{URL: r.URL, Title: r.Title})
 	}
-	return sb.String(), nil
+	return sb.String(), sources, nil
 }
@@ -3,10 +3,13 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 func TestBraveSearchName(t *testing.T) {
@@ -49,6 +52,14 @@ func TestBraveSearchExecute(t *testing.T) {
 	if !strings.Contains(result, "https://go.dev/testing") {
 		t.Errorf("expected URL in result, got %q", result)
 	}
+
+	_, sources, err := b.ExecuteWithSources(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].URL != "https://go.dev/testing" || sources[0].Title != "Go Testing" || sources[0].Fetched {
+		t.Errorf("unexpected sources %+v", sources)
+	}
 }
 
 func TestBraveSearchNoResults(t *testing.T) {
@@ -69,3 +80,23 @@ func TestBraveSearchNoResults(t *testing.T) {
 		t.Errorf("expected 'No results', got %q", result)
 	}
 }
+
+func TestBraveSearchErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"ErrorResponse"}`))
+	}))
+	defer server.Close()
+
+	b := NewBraveSearch("test-key")
+	b.baseURL = server.URL
+
+	if _, err := b.Execute(context.Background(), json.RawMessage(`{}`)); !errors.Is(err, llm.ErrInvalidRequest) {
+		t.Errorf("expected a missing query to be an invalid request, got %v", err)
+	}
+	args, _ := json.Marshal(map[string]string{"query": "golang"})
+	_, err := b.Execute(context.Background(), args)
+	if !errors.Is(err, llm.ErrRateLimited) || !strings.Contains(err.Error(), "Brave API error (status 429)") {
+		t.Errorf("expected a classified Brave rate limit, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// invalidArgs returns an error for arguments the model got wrong. It wraps
+// llm.ErrInvalidRequest, since the same call would fail again.
+func invalidArgs(format string, a ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{llm.ErrInvalidRequest}, a...)...)
+}
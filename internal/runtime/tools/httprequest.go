@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxHTTPResponseChars = 50000
+
+// HTTPRequestConfig controls which APIs the http_request tool may call.
+type HTTPRequestConfig struct {
+	// AllowedHosts are the hosts requests may go to: "api.example.com",
+	// "*.example.com" for its subdomains, or "host:port" for one port.
+	// A "https://" or "http://" prefix limits an entry to that scheme.
+	AllowedHosts []string
+	// Headers are added to every request to a host, keyed like
+	// AllowedHosts, so credentials stay out of the conversation. They are
+	// only sent over https, unless the entry starts with "http://".
+	Headers map[string]map[string]string
+	// Timeout bounds each request; zero means 30 seconds.
+	Timeout time.Duration
+}
+
+// HTTPRequest calls HTTP APIs on the allowed hosts.
+type HTTPRequest struct {
+	cfg    HTTPRequestConfig
+	client *http.Client
+}
+
+// NewHTTPRequest creates a new HTTPRequest tool.
+func NewHTTPRequest(cfg HTTPRequestConfig) *HTTPRequest {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	h := &HTTPRequest{cfg: cfg}
+	h.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if _, ok := h.match(req.URL); !ok {
+				return fmt.Errorf("redirect to %s: host not allowed", req.URL.Host)
+			}
+			// The client copies headers onto the redirect, so one that
+			// carries configured headers stays on its host and scheme.
+			first := via[0].URL
+			if pattern, _ := h.match(first); len(h.cfg.Headers[pattern]) > 0 &&
+				(req.URL.Scheme != first.Scheme || !strings.EqualFold(req.URL.Host, first.Host)) {
+				return fmt.Errorf("redirect to %s://%s: the request carries configured headers for %s, so it only follows redirects on %s://%s", req.URL.Scheme, req.URL.Host, pattern, first.Scheme, first.Host)
+			}
+			return nil
+		},
+	}
+	return h
+}
+
+func (h *HTTPRequest) Name() string { return "http_request" }
+func (h *HTTPRequest) Description() string {
+	return "Make an HTTP request to an API and return the status, content type and body. Allowed hosts: " + strings.Join(h.cfg.AllowedHosts, ", ")
+}
+func (h *HTTPRequest) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"url": {"type": "string", "description": "The URL to request"},
+			"method": {"type": "string", "description": "HTTP method (default: GET)"},
+			"headers": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Request headers"},
+			"json": {"description": "A JSON body, sent with Content-Type: application/json"},
+			"body": {"type": "string", "description": "A raw body, for non-JSON requests"},
+			"response": {"type": "string", "enum": ["raw", "json"], "description": "raw returns the body as is (default); json pretty-prints it and fails if it isn't JSON"}
+		},
+		"required": ["url"]
+	}`)
+}
+
+func (h *HTTPRequest) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		URL      string            `json:"url"`
+		Method   string            `json:"method"`
+		Headers  map[string]string `json:"headers"`
+		JSON     json.RawMessage   `json:"json"`
+		Body     string            `json:"body"`
+		Response string            `json:"response"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", invalidArgs("parse args: %w", err)
+	}
+	if params.URL == "" {
+		return "", invalidArgs("url is required")
+	}
+	if len(params.JSON) > 0 && params.Body != "" {
+		return "", invalidArgs("set json or body, not both")
+	}
+	if params.Response != "" && params.Response != "raw" && params.Response != "json" {
+		return "", invalidArgs("response must be raw or json")
+	}
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", invalidArgs("url must be an absolute http or https URL")
+	}
+	pattern, ok := h.match(u)
+	if !ok {
+		return "", fmt.Errorf("host %s is not allowed; allowed hosts: %s", u.Host, strings.Join(h.cfg.AllowedHosts, ", "))
+	}
+	headers := h.cfg.Headers[pattern]
+	if len(headers) > 0 && u.Scheme != "https" && !hasScheme(pattern, "http") {
+		return "", fmt.Errorf("%s has configured headers, which are only sent over https", pattern)
+	}
+
+	method := strings.ToUpper(params.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	switch {
+	case len(params.JSON) > 0:
+		body = bytes.NewReader(params.JSON)
+	case params.Body != "":
+		body = strings.NewReader(params.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Gopherclaw/1.0")
+	if len(params.JSON) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range params.Headers {
+		req.Header.Set(k, v)
+	}
+	// Configured headers win, so the model can't swap out credentials.
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseChars+1))
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+
+	out := string(data)
+	if params.Response == "json" {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, data, "", "  "); err != nil {
+			if len(data) > maxHTTPResponseChars {
+				return "", fmt.Errorf("response is longer than %d bytes, too long to parse as JSON; use raw mode", maxHTTPResponseChars)
+			}
+			return "", fmt.Errorf("response is not JSON (status %d): %.200s", resp.StatusCode, data)
+		}
+		out = pretty.String()
+	}
+	if len(out) > maxHTTPResponseChars {
+		out = out[:maxHTTPResponseChars] + "\n\n[Content truncated]"
+	}
+	// Error statuses are results too: an API's error body tells the model
+	// what went wrong.
+	return fmt.Sprintf("%s %s\nContent-Type: %s\n\n%s", resp.Proto, resp.Status, resp.Header.Get("Content-Type"), out), nil
+}
+
+// match returns the allowed host pattern u falls under.
+func (h *HTTPRequest) match(u *url.URL) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+	patterns := append([]string{}, h.cfg.AllowedHosts...)
+	// More specific patterns first, so their headers apply.
+	sort.SliceStable(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	for _, pattern := range patterns {
+		p := strings.ToLower(pattern)
+		if scheme, rest, ok := strings.Cut(p, "://"); ok {
+			if scheme != u.Scheme {
+				continue
+			}
+			p = rest
+		}
+		if strings.Contains(p, ":") {
+			if p == strings.ToLower(u.Host) {
+				return pattern, true
+			}
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return pattern, true
+			}
+			continue
+		}
+		if p == host {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// hasScheme reports whether an allowed host pattern is limited to scheme.
+func hasScheme(pattern, scheme string) bool {
+	return strings.HasPrefix(strings.ToLower(pattern), scheme+"://")
+}
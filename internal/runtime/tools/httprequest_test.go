@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, "http://elsewhere.test/", http.StatusFound)
+			return
+		case "/text":
+			w.Write([]byte("not json"))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"method":       r.Method,
+			"body":         string(body),
+			"content_type": r.Header.Get("Content-Type"),
+			"auth":         r.Header.Get("Authorization"),
+			"trace":        r.Header.Get("X-Trace"),
+		})
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	h := NewHTTPRequest(HTTPRequestConfig{
+		AllowedHosts: []string{"http://" + host},
+		Headers:      map[string]map[string]string{"http://" + host: {"Authorization": "Bearer secret"}},
+	})
+	call := func(params map[string]any) (string, error) {
+		args, _ := json.Marshal(params)
+		return h.Execute(context.Background(), args)
+	}
+
+	result, err := call(map[string]any{
+		"url":      server.URL + "/things",
+		"method":   "post",
+		"headers":  map[string]string{"Authorization": "Bearer forged", "X-Trace": "1"},
+		"json":     map[string]int{"n": 1},
+		"response": "json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"HTTP/1.1 201 Created", "Content-Type: application/json",
+		`"method": "POST"`, `"body": "{\"n\":1}"`, `"content_type": "application/json"`,
+		`"auth": "Bearer secret"`, `"trace": "1"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("result lacks %q:\n%s", want, result)
+		}
+	}
+
+	if _, err := call(map[string]any{"url": server.URL + "/text", "response": "json"}); err == nil || !strings.Contains(err.Error(), "not JSON") {
+		t.Errorf("expected a JSON error, got %v", err)
+	}
+	if result, err := call(map[string]any{"url": server.URL + "/text"}); err != nil || !strings.HasSuffix(result, "\n\nnot json") {
+		t.Errorf("raw result = %q, %v", result, err)
+	}
+	if _, err := call(map[string]any{"url": "http://elsewhere.test/"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected a host error, got %v", err)
+	}
+	if _, err := call(map[string]any{"url": server.URL + "/away"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected a redirect error, got %v", err)
+	}
+}
+
+func TestHTTPRequestConfiguredHeaders(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("key=" + r.Header.Get("X-Api-Key")))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, other.URL, http.StatusFound)
+			return
+		}
+		w.Write([]byte("key=" + r.Header.Get("X-Api-Key")))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	otherHost := strings.TrimPrefix(other.URL, "http://")
+
+	call := func(h *HTTPRequest, rawURL string) (string, error) {
+		args, _ := json.Marshal(map[string]string{"url": rawURL})
+		return h.Execute(context.Background(), args)
+	}
+	key := map[string]string{"X-Api-Key": "secret"}
+
+	// Without "http://" in the entry, configured headers need https.
+	h := NewHTTPRequest(HTTPRequestConfig{AllowedHosts: []string{host}, Headers: map[string]map[string]string{host: key}})
+	if _, err := call(h, server.URL+"/"); err == nil || !strings.Contains(err.Error(), "only sent over https") {
+		t.Errorf("expected an https error, got %v", err)
+	}
+
+	// A redirect to another allowed host must not take the headers along.
+	h = NewHTTPRequest(HTTPRequestConfig{
+		AllowedHosts: []string{"http://" + host, otherHost},
+		Headers:      map[string]map[string]string{"http://" + host: key},
+	})
+	if result, err := call(h, server.URL+"/"); err != nil || !strings.HasSuffix(result, "key=secret") {
+		t.Errorf("result = %q, %v", result, err)
+	}
+	if result, err := call(h, server.URL+"/away"); err == nil || !strings.Contains(err.Error(), "configured headers") {
+		t.Errorf("expected a redirect error, got %q, %v", result, err)
+	}
+
+	// Without configured headers, the same redirect is followed.
+	h = NewHTTPRequest(HTTPRequestConfig{AllowedHosts: []string{host, otherHost}})
+	if result, err := call(h, server.URL+"/away"); err != nil || !strings.HasSuffix(result, "key=") {
+		t.Errorf("result = %q, %v", result, err)
+	}
+}
+
+func TestHTTPRequestMatch(t *testing.T) {
+	h := NewHTTPRequest(HTTPRequestConfig{AllowedHosts: []string{"api.example.com", "*.example.org", "ha.local:8123", "https://secure.example.net"}})
+	cases := map[string]bool{
+		"https://api.example.com/v1":  true,
+		"https://API.example.com/v1":  true,
+		"https://www.example.com/":    false,
+		"https://ci.example.org/jobs": true,
+		"https://example.org/":        false,
+		"http://ha.local:8123/api":    true,
+		"http://ha.local:8124/api":    false,
+		"http://ha.local/api":         false,
+		"https://secure.example.net/": true,
+		"http://secure.example.net/":  false,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if _, got := h.match(u); got != want {
+			t.Errorf("match(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
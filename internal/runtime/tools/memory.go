@@ -3,7 +3,6 @@ package tools
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -48,10 +47,10 @@ func (m *MemorySave) Execute(_ context.Context, args json.RawMessage) (string, e
 		Content string `json:"content"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
-		return "", fmt.Errorf("parse args: %w", err)
+		return "", invalidArgs("parse args: %w", err)
 	}
 	if params.Content == "" {
-		return "", fmt.Errorf("content is required")
+		return "", invalidArgs("content is required")
 	}
 
 	memoryMu.Lock()
@@ -103,10 +102,10 @@ func (m *MemoryDelete) Execute(_ context.Context, args json.RawMessage) (string,
 		Content string `json:"content"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
-		return "", fmt.Errorf("parse args: %w", err)
+		return "", invalidArgs("parse args: %w", err)
 	}
 	if params.Content == "" {
-		return "", fmt.Errorf("content is required")
+		return "", invalidArgs("content is required")
 	}
 
 	memoryMu.Lock()
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// NoReply lets the model end a turn without sending anything to the user.
+// The runtime recognizes calls to it by name and finishes the run with no
+// response; Execute only produces the tool_result recorded for the call.
+type NoReply struct{}
+
+// NewNoReply creates a new NoReply tool.
+func NewNoReply() *NoReply { return &NoReply{} }
+
+func (n *NoReply) Name() string { return "no_reply" }
+func (n *NoReply) Description() string {
+	return "End this turn without sending any message to the user. Use when an automated or scheduled prompt has nothing worth reporting."
+}
+func (n *NoReply) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"reason": {"type": "string", "description": "Why no reply is needed (recorded, not sent)"}
+		}
+	}`)
+}
+
+func (n *NoReply) Execute(_ context.Context, _ json.RawMessage) (string, error) {
+	return "ok: no reply will be sent", nil
+}
@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestNoReply(t *testing.T) {
+	tool := NewNoReply()
+	if tool.Name() != "no_reply" {
+		t.Errorf("expected name no_reply, got %q", tool.Name())
+	}
+	if !json.Valid(tool.Parameters()) {
+		t.Error("expected valid parameters schema")
+	}
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"reason":"nothing new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == "" {
+		t.Error("expected non-empty result")
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/user/gopherclaw/internal/types"
 )
 
 const maxReadURLChars = 50000
@@ -38,45 +42,64 @@ func (r *ReadURL) Parameters() json.RawMessage {
 }
 
 func (r *ReadURL) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	result, _, err := r.ExecuteWithSources(ctx, args)
+	return result, err
+}
+
+// ExecuteWithSources fetches the page and also returns it as a source, under
+// its final URL after redirects.
+func (r *ReadURL) ExecuteWithSources(ctx context.Context, args json.RawMessage) (string, []types.Source, error) {
 	var params struct {
 		URL string `json:"url"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
-		return "", fmt.Errorf("parse args: %w", err)
+		return "", nil, invalidArgs("parse args: %w", err)
 	}
 	if params.URL == "" {
-		return "", fmt.Errorf("url is required")
+		return "", nil, invalidArgs("url is required")
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params.URL, nil)
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return "", nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Gopherclaw/1.0")
 
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetch URL: %w", err)
+		return "", nil, fmt.Errorf("fetch URL: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP error: status %d", resp.StatusCode)
+		return "", nil, fmt.Errorf("HTTP error: status %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReadURLChars*2))
 	if err != nil {
-		return "", fmt.Errorf("read body: %w", err)
+		return "", nil, fmt.Errorf("read body: %w", err)
 	}
 
 	md, err := htmltomarkdown.ConvertString(string(body))
 	if err != nil {
-		return "", fmt.Errorf("convert to markdown: %w", err)
+		return "", nil, fmt.Errorf("convert to markdown: %w", err)
 	}
 
 	if len(md) > maxReadURLChars {
 		md = md[:maxReadURLChars] + "\n\n[Content truncated]"
 	}
 
-	return md, nil
+	source := types.Source{URL: resp.Request.URL.String(), Title: pageTitle(body), Fetched: true}
+	return md, []types.Source{source}, nil
+}
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// pageTitle returns the HTML document's <title>, or "" if it has none.
+func pageTitle(body []byte) string {
+	m := titlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
 }
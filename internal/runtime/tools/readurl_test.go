@@ -19,7 +19,8 @@ func TestReadURLName(t *testing.T) {
 func TestReadURLExecute(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(`<html><body><h1>Hello World</h1><p>This is a test.</p></body></html>`))
+		w.Write([]byte(`<html><head><title>Greeting &amp;
+  Test</title></head><body><h1>Hello World</h1><p>This is a test.</p></body></html>`))
 	}))
 	defer server.Close()
 
@@ -35,6 +36,14 @@ func TestReadURLExecute(t *testing.T) {
 	if !strings.Contains(result, "This is a test") {
 		t.Errorf("expected 'This is a test' in result, got %q", result)
 	}
+
+	_, sources, err := r.ExecuteWithSources(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].URL != server.URL || sources[0].Title != "Greeting & Test" || !sources[0].Fetched {
+		t.Errorf("unexpected sources %+v", sources)
+	}
 }
 
 func TestReadURLMissingURL(t *testing.T) {
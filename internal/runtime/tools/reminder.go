@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/reminder"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// reminderTimeLayout formats reminder times in tool results.
+const reminderTimeLayout = "Mon 2 Jan 2006 15:04 MST"
+
+// errNoSession is returned when a reminder tool runs outside a conversation.
+var errNoSession = fmt.Errorf("reminders need a conversation")
+
+// ReminderSet schedules a reminder for the current conversation.
+type ReminderSet struct {
+	store *state.ReminderStore
+	now   func() time.Time
+}
+
+func NewReminderSet(store *state.ReminderStore) *ReminderSet {
+	return &ReminderSet{store: store, now: time.Now}
+}
+
+func (r *ReminderSet) Name() string { return "reminder_set" }
+func (r *ReminderSet) Description() string {
+	return "Set a reminder that is sent to this conversation at a given time, once or repeating. " +
+		"Pass the time as the user said it in English, e.g. \"in 20 minutes\", \"tomorrow at 9am\", \"friday 15:00\", " +
+		"\"2026-10-20 14:00\", \"every weekday at 8:30\", \"every monday at 10\" or \"every 2 hours\"."
+}
+func (r *ReminderSet) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"text": {"type": "string", "description": "What to remind the user of, written as the reminder message"},
+			"when": {"type": "string", "description": "When to send it, in natural language"}
+		},
+		"required": ["text", "when"]
+	}`)
+}
+
+func (r *ReminderSet) Execute(context.Context, json.RawMessage) (string, error) {
+	return "", errNoSession
+}
+
+func (r *ReminderSet) ExecuteInSession(_ context.Context, session *types.SessionIndex, args json.RawMessage) (string, error) {
+	var params struct {
+		Text string `json:"text"`
+		When string `json:"when"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", invalidArgs("parse args: %w", err)
+	}
+	if strings.TrimSpace(params.Text) == "" {
+		return "", invalidArgs("text is required")
+	}
+	now := r.now()
+	due, repeat, err := reminder.Parse(params.When, now)
+	if err != nil {
+		return "", err
+	}
+	if !due.After(now) {
+		return "", fmt.Errorf("%s is in the past", due.Format(reminderTimeLayout))
+	}
+	rem := &state.Reminder{SessionKey: string(session.SessionKey), Text: strings.TrimSpace(params.Text), Due: due, Repeat: repeat}
+	if err := r.store.Add(rem); err != nil {
+		return "", err
+	}
+	if repeat == "" {
+		return fmt.Sprintf("Reminder %s set for %s.", rem.ID, due.Format(reminderTimeLayout)), nil
+	}
+	return fmt.Sprintf("Reminder %s set %s, first at %s.", rem.ID, reminder.Describe(repeat), due.Format(reminderTimeLayout)), nil
+}
+
+// ReminderList lists the current conversation's pending reminders.
+type ReminderList struct{ store *state.ReminderStore }
+
+func NewReminderList(store *state.ReminderStore) *ReminderList {
+	return &ReminderList{store: store}
+}
+
+func (r *ReminderList) Name() string { return "reminder_list" }
+func (r *ReminderList) Description() string {
+	return "List the pending reminders for this conversation"
+}
+func (r *ReminderList) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type": "object", "properties": {}}`)
+}
+
+func (r *ReminderList) Execute(context.Context, json.RawMessage) (string, error) {
+	return "", errNoSession
+}
+
+func (r *ReminderList) ExecuteInSession(_ context.Context, session *types.SessionIndex, _ json.RawMessage) (string, error) {
+	reminders, err := r.store.List(string(session.SessionKey))
+	if err != nil {
+		return "", err
+	}
+	if len(reminders) == 0 {
+		return "No reminders set.", nil
+	}
+	var b strings.Builder
+	for _, rem := range reminders {
+		fmt.Fprintf(&b, "- %s: %q at %s", rem.ID, rem.Text, rem.Due.Format(reminderTimeLayout))
+		if rem.Repeat != "" {
+			fmt.Fprintf(&b, ", repeating %s", reminder.Describe(rem.Repeat))
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// ReminderCancel cancels one of the current conversation's reminders.
+type ReminderCancel struct{ store *state.ReminderStore }
+
+func NewReminderCancel(store *state.ReminderStore) *ReminderCancel {
+	return &ReminderCancel{store: store}
+}
+
+func (r *ReminderCancel) Name() string { return "reminder_cancel" }
+func (r *ReminderCancel) Description() string {
+	return "Cancel a reminder by the ID shown by reminder_list; a repeating reminder stops for good"
+}
+func (r *ReminderCancel) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "description": "The reminder ID"}
+		},
+		"required": ["id"]
+	}`)
+}
+
+func (r *ReminderCancel) Execute(context.Context, json.RawMessage) (string, error) {
+	return "", errNoSession
+}
+
+func (r *ReminderCancel) ExecuteInSession(_ context.Context, session *types.SessionIndex, args json.RawMessage) (string, error) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", invalidArgs("parse args: %w", err)
+	}
+	rem, err := r.store.Get(params.ID)
+	// Other conversations' reminders are reported as missing.
+	if err != nil || rem.SessionKey != string(session.SessionKey) {
+		return "", fmt.Errorf("reminder not found: %s", params.ID)
+	}
+	if err := r.store.Remove(rem.ID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Reminder %s (%q) cancelled.", rem.ID, rem.Text), nil
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestReminderTools(t *testing.T) {
+	store := state.NewReminderStore(filepath.Join(t.TempDir(), "reminders.json"))
+	set := NewReminderSet(store)
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	set.now = func() time.Time { return now }
+	list := NewReminderList(store)
+	cancel := NewReminderCancel(store)
+
+	ctx := context.Background()
+	mine := &types.SessionIndex{SessionKey: "telegram:1:1"}
+	theirs := &types.SessionIndex{SessionKey: "telegram:2:2"}
+
+	if _, err := set.Execute(ctx, json.RawMessage(`{"text":"x","when":"in 1 hour"}`)); err == nil {
+		t.Error("expected Execute without a session to fail")
+	}
+	out, err := set.ExecuteInSession(ctx, mine, json.RawMessage(`{"text":"call the dentist","when":"tomorrow at 9am"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Thu 15 Oct 2026 09:00") {
+		t.Errorf("unexpected result %q", out)
+	}
+	if _, err := set.ExecuteInSession(ctx, mine, json.RawMessage(`{"text":"stand-up","when":"every weekday at 9:30"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.ExecuteInSession(ctx, mine, json.RawMessage(`{"text":"x","when":"whenever"}`)); err == nil {
+		t.Error("expected an unparseable time to fail")
+	}
+
+	out, err = list.ExecuteInSession(ctx, mine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "call the dentist") || !strings.Contains(out, "repeating every weekday") {
+		t.Errorf("unexpected list %q", out)
+	}
+	if out, _ := list.ExecuteInSession(ctx, theirs, nil); out != "No reminders set." {
+		t.Errorf("other session sees %q", out)
+	}
+
+	reminders, _ := store.List("telegram:1:1")
+	id := reminders[0].ID
+	if _, err := cancel.ExecuteInSession(ctx, theirs, json.RawMessage(`{"id":"`+id+`"}`)); err == nil {
+		t.Error("expected cancelling another conversation's reminder to fail")
+	}
+	if _, err := cancel.ExecuteInSession(ctx, mine, json.RawMessage(`{"id":"`+id+`"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reminders, _ := store.List("telegram:1:1"); len(reminders) != 1 {
+		t.Errorf("expected one reminder left, got %+v", reminders)
+	}
+}
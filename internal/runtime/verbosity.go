@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+const shortenPrompt = `Rewrite the following reply in at most %d characters. ` +
+	`Keep the answer, concrete facts and anything the reader must act on; drop background and repetition. ` +
+	`Keep the reply's language and tone. Reply with the rewritten text only.`
+
+// detailCues are phrases that mean the user asked for a long answer, which
+// lifts a brief channel's length limit for that run.
+var detailCues = []string{
+	"detail", "in depth", "in-depth", "elaborate", "thorough", "comprehensive",
+	"step by step", "step-by-step", "explain", "longer", "full report",
+}
+
+// shape applies the session channel's verbosity profile to a final reply
+// and returns the text to store and deliver. A brief reply over the limit
+// is rewritten by the model, or cut at a sentence boundary if that fails,
+// unless the run's message asked for detail; a raw reply loses a wrapping code
+// fence. The original length is recorded in fields when a reply is
+// shortened.
+func (rt *Runtime) shape(ctx context.Context, run *gateway.Run, session *types.SessionIndex, text string, fields map[string]any) string {
+	v, ok := rt.engine.VerbosityFor(session.SessionKey)
+	if !ok {
+		return text
+	}
+	switch v.Style {
+	case ctxengine.VerbosityBrief:
+		if v.MaxChars <= 0 || utf8.RuneCountInString(text) <= v.MaxChars || asksForDetail(run.Event.Text) {
+			return text
+		}
+		short, err := rt.shorten(ctx, run, text, v.MaxChars)
+		if err != nil {
+			slog.WarnContext(ctx, "shorten reply failed, cutting instead", "error", err)
+			short = cutReply(text, v.MaxChars)
+		}
+		fields["text"] = short
+		fields["shortened_from"] = utf8.RuneCountInString(text)
+		return short
+	case ctxengine.VerbosityRaw:
+		raw := llm.UnwrapFence(text)
+		fields["text"] = raw
+		return raw
+	}
+	return text
+}
+
+// shorten asks the model to rewrite text within limit characters.
+func (rt *Runtime) shorten(ctx context.Context, run *gateway.Run, text string, limit int) (string, error) {
+	messages := []llm.Message{
+		{Role: "system", Content: fmt.Sprintf(shortenPrompt, limit)},
+		{Role: "user", Content: text},
+	}
+	if err := rt.allowCall(run.SessionID); err != nil {
+		return "", fmt.Errorf("shorten reply: %w", err)
+	}
+	resp, err := rt.provider(run, usage.Shorten).Complete(ctx, messages, nil)
+	if err != nil {
+		return "", fmt.Errorf("shorten reply: %w", err)
+	}
+	rt.recordUsage(ctx, run.SessionID, run.ID, usage.Shorten, resp)
+	short := strings.TrimSpace(resp.Content)
+	if short == "" {
+		return "", fmt.Errorf("shorten reply: empty reply")
+	}
+	if utf8.RuneCountInString(short) > limit {
+		return "", fmt.Errorf("shorten reply: still %d characters", utf8.RuneCountInString(short))
+	}
+	return short, nil
+}
+
+// asksForDetail reports whether a request asks for a long answer.
+func asksForDetail(request string) bool {
+	lower := strings.ToLower(request)
+	for _, cue := range detailCues {
+		if strings.Contains(lower, cue) {
+			return true
+		}
+	}
+	return false
+}
+
+// cutReply trims text to at most limit characters, ending at the last
+// paragraph or sentence break in the second half when there is one, and
+// marks the cut with an ellipsis.
+func cutReply(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit-1])
+	for _, sep := range []string{"\n\n", ". ", "\n", "! ", "? "} {
+		if i := strings.LastIndex(cut, sep); i > len(cut)/2 {
+			cut = cut[:i+len(strings.TrimRight(sep, " \n"))]
+			break
+		}
+	}
+	return strings.TrimSpace(cut) + "…"
+}
@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Check validates a task response against its expectation, returning an
+// error describing the first violation. A nil expectation always passes.
+func Check(expect *state.TaskExpect, response string) error {
+	if expect == nil {
+		return nil
+	}
+	if expect.NonEmpty && strings.TrimSpace(response) == "" {
+		return fmt.Errorf("response is empty")
+	}
+	if expect.Contains != "" && !strings.Contains(response, expect.Contains) {
+		return fmt.Errorf("response does not contain %q", expect.Contains)
+	}
+	if len(expect.JSONSchema) > 0 {
+		var schema map[string]any
+		if err := json.Unmarshal(expect.JSONSchema, &schema); err != nil {
+			return fmt.Errorf("invalid json_schema: %w", err)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(llm.StripFence(response)), &v); err != nil {
+			return fmt.Errorf("response is not JSON: %w", err)
+		}
+		if err := validate(schema, v, "$"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks v against a subset of JSON Schema: type, properties,
+// required, items and enum.
+func validate(schema map[string]any, v any, path string) error {
+	if want, ok := schema["type"].(string); ok && !hasType(v, want) {
+		return fmt.Errorf("%s: expected %s, got %s", path, want, typeOf(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := v[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]any); ok {
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				sub, ok := props[name].(map[string]any)
+				val, present := v[name]
+				if !ok || !present {
+					continue
+				}
+				if err := validate(sub, val, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasType reports whether a decoded JSON value has the named schema type.
+func hasType(v any, want string) bool {
+	got := typeOf(v)
+	if want == "number" && got == "integer" {
+		return true
+	}
+	return got == want
+}
+
+// typeOf names the JSON Schema type of a decoded JSON value.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestCheck(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"required": ["status", "checks"],
+		"properties": {
+			"status": {"enum": ["ok", "degraded"]},
+			"checks": {"type": "array", "items": {"type": "integer"}}
+		}
+	}`)
+	tests := []struct {
+		name     string
+		expect   *state.TaskExpect
+		response string
+		wantErr  string
+	}{
+		{"nil expectation", nil, "", ""},
+		{"non-empty ok", &state.TaskExpect{NonEmpty: true}, "all good", ""},
+		{"non-empty violated", &state.TaskExpect{NonEmpty: true}, "  \n", "empty"},
+		{"contains ok", &state.TaskExpect{Contains: "OK"}, "backup OK", ""},
+		{"contains violated", &state.TaskExpect{Contains: "OK"}, "backup failed", `contain "OK"`},
+		{"any JSON", &state.TaskExpect{JSONSchema: json.RawMessage(`{}`)}, `[1, 2]`, ""},
+		{"not JSON", &state.TaskExpect{JSONSchema: json.RawMessage(`{}`)}, "Sure! Here you go", "not JSON"},
+		{"schema ok", &state.TaskExpect{JSONSchema: schema}, `{"status":"ok","checks":[1,2]}`, ""},
+		{"schema ok in fence", &state.TaskExpect{JSONSchema: schema}, "```json\n{\"status\":\"ok\",\"checks\":[]}\n```", ""},
+		{"missing required", &state.TaskExpect{JSONSchema: schema}, `{"status":"ok"}`, `missing required property "checks"`},
+		{"enum violated", &state.TaskExpect{JSONSchema: schema}, `{"status":"bad","checks":[]}`, "$.status: value not in enum"},
+		{"item type violated", &state.TaskExpect{JSONSchema: schema}, `{"status":"ok","checks":[1,1.5]}`, "$.checks[1]: expected integer, got number"},
+		{"root type violated", &state.TaskExpect{JSONSchema: schema}, `"ok"`, "expected object, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.expect, tt.response)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected pass, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
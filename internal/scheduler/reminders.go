@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/user/gopherclaw/internal/reminder"
+	"github.com/user/gopherclaw/internal/state"
+)
+
+// ReminderFirer sends a due reminder to its conversation.
+type ReminderFirer func(r *state.Reminder) error
+
+// reminderSchedule is how often the scheduler looks for due reminders.
+const reminderSchedule = "@every 30s"
+
+// firedRetention is how long a fired one-off reminder is kept so its
+// snooze buttons keep working.
+const firedRetention = 24 * time.Hour
+
+// SetReminders makes the scheduler fire reminders from store through fire.
+// Call it before Start.
+func (s *Scheduler) SetReminders(store *state.ReminderStore, fire ReminderFirer) {
+	s.reminders = store
+	s.fireReminder = fire
+}
+
+// startReminders registers the reminder check with cron.
+func (s *Scheduler) startReminders() {
+	if s.reminders == nil {
+		return
+	}
+	if _, err := s.cron.AddFunc(reminderSchedule, func() { s.fireReminders(time.Now()) }); err != nil {
+		slog.Error("schedule reminder checks failed", "error", err)
+	}
+}
+
+// fireReminders sends every reminder due at now. A recurring reminder
+// moves to its next occurrence after now, so occurrences missed while the
+// daemon was down fire once; a one-off is marked fired. A failed send is
+// logged and not retried here: delivery retries are the outbox's job.
+func (s *Scheduler) fireReminders(now time.Time) {
+	if !s.remindMu.TryLock() {
+		return // the previous check is still sending
+	}
+	defer s.remindMu.Unlock()
+
+	due, err := s.reminders.Due(now)
+	if err != nil {
+		slog.Error("load due reminders failed", "error", err)
+		return
+	}
+	for _, r := range due {
+		slog.Info("firing reminder", "id", r.ID, "session_key", r.SessionKey)
+		if err := s.fireReminder(r); err != nil {
+			slog.Error("reminder delivery failed", "id", r.ID, "session_key", r.SessionKey, "error", err)
+		}
+		if r.Repeat == "" {
+			fired := now
+			r.FiredAt = &fired
+		} else {
+			next, err := reminder.Next(r.Repeat, r.Due, now)
+			if err != nil {
+				slog.Error("invalid reminder repeat, removing reminder", "id", r.ID, "repeat", r.Repeat, "error", err)
+				if err := s.reminders.Remove(r.ID); err != nil {
+					slog.Warn("remove reminder failed", "id", r.ID, "error", err)
+				}
+				continue
+			}
+			r.Due = next
+		}
+		if err := s.reminders.Update(r); err != nil {
+			slog.Warn("update fired reminder failed", "id", r.ID, "error", err)
+		}
+	}
+	if err := s.reminders.PruneFired(now.Add(-firedRetention)); err != nil {
+		slog.Warn("prune fired reminders failed", "error", err)
+	}
+}
@@ -2,21 +2,86 @@
 package scheduler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/robfig/cron/v3"
+	"github.com/user/gopherclaw/internal/delivery"
 	"github.com/user/gopherclaw/internal/state"
 )
 
-// Handler is the callback invoked when a scheduled task fires.
-type Handler func(sessionKey, prompt string)
+// Handler is the callback invoked when a scheduled task fires. Its response
+// and error are recorded as the task's last run.
+type Handler func(sessionKey, prompt string) (string, error)
+
+// Alerter notifies admins that a scheduled task failed its expectation.
+type Alerter func(task, message string)
+
+// Deliverer sends a scheduled task's non-empty response to the task's
+// targets. An error from delivery.Fanout is recorded per target.
+type Deliverer func(task *state.Task, response string) error
+
+// PipelineRunner runs a task that names a pipeline. llm sends a prompt to
+// the task's session; the returned text is the response to deliver.
+type PipelineRunner func(task *state.Task, llm Handler) (string, error)
 
 // Scheduler evaluates cron expressions from the task store and fires tasks
 // through a handler callback.
 type Scheduler struct {
 	store   *state.TaskStore
 	handler Handler
+	alert   Alerter
+	deliver Deliverer
+	pipe    PipelineRunner
+	onLoad  func(tasks []*state.Task)
+	tz      string
 	cron    *cron.Cron
+
+	reminders    *state.ReminderStore
+	fireReminder ReminderFirer
+	remindMu     sync.Mutex
+
+	// lastBeat is when the cron ticker last ran the liveness job (Unix
+	// nanoseconds).
+	lastBeat atomic.Int64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// loaded fingerprints the task definitions the entries came from.
+	loaded string
+}
+
+// entry is the scheduler's bookkeeping for one loaded task.
+type entry struct {
+	id       cron.EntryID
+	schedule string
+	tz       string
+	key      string
+	err      string
+	running  bool
+	lastRun  *state.TaskRun
+}
+
+// Entry is a point-in-time view of a task as the running scheduler sees it.
+type Entry struct {
+	Name       string         `json:"name"`
+	Schedule   string         `json:"schedule"`
+	Timezone   string         `json:"timezone,omitempty"`
+	SessionKey string         `json:"session_key"`
+	Next       *time.Time     `json:"next,omitempty"`
+	Prev       *time.Time     `json:"prev,omitempty"`
+	Running    bool           `json:"running"`
+	LastRun    *state.TaskRun `json:"last_run,omitempty"`
+	// Error is set when the task's schedule could not be registered.
+	Error string `json:"error,omitempty"`
 }
 
 // cronParser accepts both standard 5-field cron expressions and 6-field
@@ -32,47 +97,388 @@ func New(store *state.TaskStore, handler Handler) *Scheduler {
 		store:   store,
 		handler: handler,
 		cron:    cron.New(cron.WithParser(cronParser)),
+		entries: make(map[string]*entry),
+	}
+}
+
+// SetAlerter sets the callback used when a run of a task with an Expect
+// fails or returns a response that violates it.
+func (s *Scheduler) SetAlerter(alert Alerter) {
+	s.alert = alert
+}
+
+// SetDeliverer sets the callback that delivers scheduled responses. A
+// failed delivery is recorded as the run's error. Without a deliverer the
+// handler is expected to deliver.
+func (s *Scheduler) SetDeliverer(deliver Deliverer) {
+	s.deliver = deliver
+}
+
+// SetPipelineRunner sets the callback used for tasks with a Pipeline.
+// Without one, such tasks fail when they fire.
+func (s *Scheduler) SetPipelineRunner(run PipelineRunner) {
+	s.pipe = run
+}
+
+// SetTimezone sets the IANA time zone that schedules of tasks without a
+// Timezone fire in. Empty, the default, means the server's local time.
+// Call it before Start.
+func (s *Scheduler) SetTimezone(tz string) error {
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("timezone %q: %w", tz, err)
+	}
+	s.tz = tz
+	return nil
+}
+
+// Timezone returns the time zone a task's schedule fires in, empty for the
+// server's local time.
+func (s *Scheduler) Timezone(task *state.Task) string {
+	if task.Timezone != "" {
+		return task.Timezone
+	}
+	return s.tz
+}
+
+// Spec returns the cron spec for schedule in time zone tz. A schedule that
+// names its own zone with a CRON_TZ= or TZ= prefix, or an empty tz, leaves
+// the schedule as is.
+func Spec(schedule, tz string) string {
+	if tz == "" || strings.HasPrefix(schedule, "CRON_TZ=") || strings.HasPrefix(schedule, "TZ=") {
+		return schedule
 	}
+	return "CRON_TZ=" + tz + " " + schedule
+}
+
+// SetOnLoad sets a callback that is given the tasks each time Start or a
+// reload loads them, so settings read from tasks outside the scheduler
+// can follow changes too.
+func (s *Scheduler) SetOnLoad(onLoad func(tasks []*state.Task)) {
+	s.onLoad = onLoad
 }
 
 // Start loads tasks from the store, registers enabled tasks that have a
-// schedule as cron entries, and starts the cron ticker.
+// schedule as cron entries, and starts the cron ticker. While it runs, the
+// scheduler reloads itself when the tasks in the store change.
 func (s *Scheduler) Start() error {
 	tasks, err := s.store.List()
 	if err != nil {
 		return err
 	}
 
+	s.mu.Lock()
+	previous := s.entries
+	s.entries = make(map[string]*entry)
+	s.loaded = tasksFingerprint(tasks)
+	s.mu.Unlock()
+
 	for _, task := range tasks {
 		if task.Schedule == "" || !task.Enabled {
 			continue
 		}
 
-		// Capture loop variables for the closure.
-		sessionKey := task.SessionKey
-		prompt := task.Prompt
-		schedule := task.Schedule
 		name := task.Name
+		schedule := task.Schedule
+		tz := s.Timezone(task)
 
-		_, err := s.cron.AddFunc(schedule, func() {
-			slog.Info("cron firing task", "name", name, "session_key", sessionKey)
-			s.handler(sessionKey, prompt)
+		// A task that was already loaded keeps its entry, so a run still
+		// in progress across a reload is reported when it finishes.
+		e := previous[name]
+		if e == nil {
+			e = &entry{lastRun: task.LastRun}
+		}
+		s.mu.Lock()
+		e.schedule, e.tz, e.key, e.err = schedule, tz, task.SessionKey, ""
+		s.mu.Unlock()
+		id, err := s.cron.AddFunc(Spec(schedule, tz), func() {
+			if err := s.store.RecordFire(name, time.Now()); err != nil {
+				slog.Warn("record task fire failed", "name", name, "error", err)
+			}
+			s.run(task, e, "schedule")
 		})
+		s.mu.Lock()
 		if err != nil {
-			slog.Error("invalid cron schedule", "name", name, "schedule", schedule, "error", err)
-			continue
+			slog.Error("invalid cron schedule", "name", name, "schedule", schedule, "timezone", tz, "error", err)
+			e.err = err.Error()
+		} else {
+			e.id = id
+			slog.Info("scheduled task", "name", name, "schedule", schedule, "timezone", tz)
+		}
+		s.entries[name] = e
+		s.mu.Unlock()
+		if err == nil {
+			s.catchUp(task, e, Spec(schedule, tz), time.Now())
 		}
-		slog.Info("scheduled task", "name", name, "schedule", schedule)
 	}
 
+	if s.onLoad != nil {
+		s.onLoad(tasks)
+	}
+
+	s.startReminders()
+	s.lastBeat.Store(time.Now().UnixNano())
+	if _, err := s.cron.AddFunc(beatSchedule, func() { s.lastBeat.Store(time.Now().UnixNano()) }); err != nil {
+		return fmt.Errorf("schedule liveness beat: %w", err)
+	}
+	if _, err := s.cron.AddFunc(taskCheckSchedule, s.checkTasks); err != nil {
+		return fmt.Errorf("schedule task checks: %w", err)
+	}
 	s.cron.Start()
 	return nil
 }
 
+// run fires a task once and records the outcome. trigger is "schedule"
+// or "catch-up".
+func (s *Scheduler) run(task *state.Task, e *entry, trigger string) {
+	name, sessionKey := task.Name, task.SessionKey
+	slog.Info("cron firing task", "name", name, "session_key", sessionKey, "trigger", trigger)
+	s.mu.Lock()
+	e.running = true
+	prev := e.lastRun
+	s.mu.Unlock()
+	run := state.TaskRun{At: time.Now(), Trigger: trigger}
+	resp, err := s.fire(task)
+	if err == nil && resp != "" && task.Dedupe {
+		run.ResponseHash = responseHash(resp)
+		run.Duplicate = prev != nil && prev.ResponseHash == run.ResponseHash
+	}
+	if run.Duplicate {
+		slog.Info("skipping duplicate task response", "name", name, "session_key", sessionKey)
+	} else if err == nil && resp != "" && s.deliver != nil {
+		if derr := s.deliver(task, resp); derr != nil {
+			slog.Error("task delivery failed", "name", name, "session_key", sessionKey, "error", derr)
+			err = fmt.Errorf("deliver: %w", derr)
+			for _, te := range delivery.TargetErrors(derr) {
+				if run.DeliveryErrors == nil {
+					run.DeliveryErrors = make(map[string]string)
+				}
+				run.DeliveryErrors[te.Target] = te.Err.Error()
+			}
+		}
+	}
+	run.Response = resp
+	if err != nil {
+		run.Error = err.Error()
+		// Not delivered: the next response must not count as a repeat.
+		run.ResponseHash = ""
+	} else if err := Check(task.Expect, resp); err != nil {
+		run.Violation = err.Error()
+	}
+	s.checkFailed(name, task.Expect, run)
+	if err := s.store.RecordRun(name, run); err != nil {
+		slog.Warn("record task run failed", "name", name, "error", err)
+	}
+	s.mu.Lock()
+	e.running = false
+	e.lastRun = &run
+	s.mu.Unlock()
+}
+
+// maxCatchUp caps the runs CatchUpAll makes up for at once, so a task
+// that fires every minute doesn't run a thousand times after a long outage.
+const maxCatchUp = 24
+
+// catchUp applies the task's CatchUp policy to the fires of spec between
+// the task's LastFire and now. A task that has never fired starts counting
+// from now. Missed runs happen in the background, one after another.
+func (s *Scheduler) catchUp(task *state.Task, e *entry, spec string, now time.Time) {
+	if task.LastFire == nil {
+		if err := s.store.RecordFire(task.Name, now); err != nil {
+			slog.Warn("record task fire failed", "name", task.Name, "error", err)
+		}
+		return
+	}
+	missed, last := missedFires(spec, *task.LastFire, now)
+	if missed == 0 {
+		return
+	}
+	runs := 0
+	switch task.CatchUp {
+	case state.CatchUpOnce:
+		runs = 1
+	case state.CatchUpAll:
+		runs = min(missed, maxCatchUp)
+	case "", state.CatchUpSkip:
+	default:
+		slog.Warn("unknown task catch_up policy, skipping missed fires", "name", task.Name, "catch_up", task.CatchUp)
+	}
+	slog.Info("task missed scheduled fires", "name", task.Name, "missed", missed, "since", *task.LastFire, "catch_up_runs", runs)
+	if err := s.store.RecordFire(task.Name, last); err != nil {
+		slog.Warn("record task fire failed", "name", task.Name, "error", err)
+	}
+	if runs == 0 {
+		return
+	}
+	go func() {
+		for range runs {
+			s.run(task, e, "catch-up")
+		}
+	}()
+}
+
+// missedFires counts the times the cron spec fired after since and up to
+// now, and returns the last of them.
+func missedFires(spec string, since, now time.Time) (int, time.Time) {
+	sched, err := cronParser.Parse(spec)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	n, last := 0, time.Time{}
+	for at := sched.Next(since); !at.IsZero() && !at.After(now); at = sched.Next(at) {
+		n, last = n+1, at
+	}
+	return n, last
+}
+
+// beatSchedule is how often the cron ticker proves it is still running.
+const beatSchedule = "@every 10s"
+
+// taskCheckSchedule is how often the scheduler looks for task changes,
+// such as ones made with "gopherclaw task add" while the daemon runs.
+const taskCheckSchedule = "@every 5s"
+
+// checkTasks reloads the scheduler if the task definitions in the store
+// differ from the loaded ones. Recorded runs don't count as changes.
+func (s *Scheduler) checkTasks() {
+	tasks, err := s.store.List()
+	if err != nil {
+		slog.Warn("check tasks for changes failed", "error", err)
+		return
+	}
+	s.mu.Lock()
+	changed := tasksFingerprint(tasks) != s.loaded
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+	slog.Info("tasks changed, reloading scheduler")
+	if err := s.Reload(); err != nil {
+		slog.Error("reload scheduler failed", "error", err)
+	}
+}
+
+// tasksFingerprint hashes task definitions without their last runs and
+// fires.
+func tasksFingerprint(tasks []*state.Task) string {
+	defs := make([]state.Task, len(tasks))
+	for i, task := range tasks {
+		defs[i] = *task
+		defs[i].LastRun = nil
+		defs[i].LastFire = nil
+	}
+	data, _ := json.Marshal(defs)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// LastBeat returns when the cron ticker last ran its liveness job. If it
+// falls far behind, the ticker has stopped and Reload re-registers every
+// job on a fresh one.
+func (s *Scheduler) LastBeat() time.Time {
+	return time.Unix(0, s.lastBeat.Load())
+}
+
+// fire runs a task once, through its pipeline if it names one.
+func (s *Scheduler) fire(task *state.Task) (string, error) {
+	if task.Pipeline == "" {
+		return s.handler(task.SessionKey, task.Prompt)
+	}
+	if s.pipe == nil {
+		return "", fmt.Errorf("pipeline %s: no pipeline runner", task.Pipeline)
+	}
+	return s.pipe(task, s.handler)
+}
+
+// responseHash fingerprints a response for Task.Dedupe. Case and runs of
+// whitespace are ignored so trivial reformatting still counts as a repeat.
+func responseHash(resp string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(resp), " "))))
+	return hex.EncodeToString(sum[:])
+}
+
+// alertResponseLimit caps how much of a violating response an alert quotes.
+const alertResponseLimit = 300
+
+// checkFailed raises an alert when a run of a task with an expectation
+// errored or violated it. Tasks without an Expect only log failures.
+func (s *Scheduler) checkFailed(name string, expect *state.TaskExpect, run state.TaskRun) {
+	if expect == nil || (run.Error == "" && run.Violation == "") {
+		return
+	}
+	var msg string
+	if run.Error != "" {
+		msg = fmt.Sprintf("Task %q failed: %s", name, run.Error)
+	} else {
+		resp := run.Response
+		if len(resp) > alertResponseLimit {
+			resp = resp[:alertResponseLimit] + "..."
+		}
+		msg = fmt.Sprintf("Task %q returned an unexpected result: %s\n\nResponse:\n%s", name, run.Violation, resp)
+	}
+	slog.Warn("task check failed", "name", name, "error", run.Error, "violation", run.Violation)
+	if s.alert != nil {
+		s.alert(name, msg)
+	}
+}
+
+// Snapshot returns the tasks loaded by the last Start or Reload, sorted by
+// name, with their next and previous fire times and the outcome of their
+// most recent run. Tasks added to the store in the last few seconds are
+// not included yet.
+func (s *Scheduler) Snapshot() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Entry, 0, len(s.entries))
+	for name, e := range s.entries {
+		snap := Entry{
+			Name:       name,
+			Schedule:   e.schedule,
+			Timezone:   e.tz,
+			SessionKey: e.key,
+			Running:    e.running,
+			Error:      e.err,
+		}
+		if e.lastRun != nil {
+			run := *e.lastRun
+			snap.LastRun = &run
+		}
+		if e.err == "" {
+			ce := s.cron.Entry(e.id)
+			if !ce.Next.IsZero() {
+				next := ce.Next
+				snap.Next = &next
+			} else if next, err := NextFire(Spec(e.schedule, e.tz), time.Now()); err == nil {
+				// Not started yet: cron only computes Next once running.
+				snap.Next = &next
+			}
+			if !ce.Prev.IsZero() {
+				prev := ce.Prev
+				snap.Prev = &prev
+			}
+		}
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// NextFire returns the next time after from that the cron schedule fires.
+func NextFire(schedule string, from time.Time) (time.Time, error) {
+	sched, err := cronParser.Parse(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(from), nil
+}
+
 // Reload stops the existing cron, creates a new one, and calls Start() again.
+// Runs already in progress finish.
 func (s *Scheduler) Reload() error {
 	s.cron.Stop()
+	s.mu.Lock()
 	s.cron = cron.New(cron.WithParser(cronParser))
+	s.mu.Unlock()
 	return s.Start()
 }
 
@@ -2,11 +2,14 @@
 package scheduler
 
 import (
+	"errors"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/user/gopherclaw/internal/delivery"
 	"github.com/user/gopherclaw/internal/state"
 )
 
@@ -26,8 +29,9 @@ func TestSchedulerFiresTask(t *testing.T) {
 	}
 
 	var fires atomic.Int32
-	handler := func(sessionKey, prompt string) {
+	handler := func(sessionKey, prompt string) (string, error) {
 		fires.Add(1)
+		return "ok", nil
 	}
 
 	sched := New(store, handler)
@@ -69,8 +73,9 @@ func TestSchedulerSkipsDisabled(t *testing.T) {
 	}
 
 	var fires atomic.Int32
-	handler := func(sessionKey, prompt string) {
+	handler := func(sessionKey, prompt string) (string, error) {
 		fires.Add(1)
+		return "ok", nil
 	}
 
 	sched := New(store, handler)
@@ -102,8 +107,9 @@ func TestSchedulerNoScheduleTasks(t *testing.T) {
 	}
 
 	var fires atomic.Int32
-	handler := func(sessionKey, prompt string) {
+	handler := func(sessionKey, prompt string) (string, error) {
 		fires.Add(1)
+		return "ok", nil
 	}
 
 	sched := New(store, handler)
@@ -118,3 +124,593 @@ func TestSchedulerNoScheduleTasks(t *testing.T) {
 		t.Errorf("expected 0 fires for task with no schedule, got %d", n)
 	}
 }
+
+func TestSchedulerRecordsLastRun(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "report",
+		Prompt:     "report",
+		Schedule:   "* * * * * *",
+		SessionKey: "telegram:123",
+		Enabled:    true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		return "all good", nil
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	deadline := time.After(2500 * time.Millisecond)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline:
+			t.Fatal("last run not recorded within 2.5s")
+		case <-ticker.C:
+			task, err := store.Get("report")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if task.LastRun == nil {
+				continue
+			}
+			if task.LastRun.Trigger != "schedule" || task.LastRun.Response != "all good" {
+				t.Errorf("unexpected last run: %+v", task.LastRun)
+			}
+			return
+		}
+	}
+}
+
+func TestSchedulerAlertsOnViolation(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "backup-check",
+		Prompt:     "check the backup",
+		Schedule:   "* * * * * *",
+		SessionKey: "telegram:123",
+		Enabled:    true,
+		Expect:     &state.TaskExpect{Contains: "OK"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		return "I could not find the backup", nil
+	})
+	alerts := make(chan string, 10)
+	sched.SetAlerter(func(task, message string) {
+		alerts <- task + ": " + message
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	select {
+	case alert := <-alerts:
+		if !strings.Contains(alert, "backup-check") || !strings.Contains(alert, `"OK"`) || !strings.Contains(alert, "could not find") {
+			t.Errorf("unexpected alert %q", alert)
+		}
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("no alert within 2.5s")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		task, err := store.Get("backup-check")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.LastRun != nil {
+			if task.LastRun.Violation == "" {
+				t.Errorf("expected violation recorded, got %+v", task.LastRun)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("last run not recorded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNextFire(t *testing.T) {
+	from := time.Date(2025, 1, 1, 7, 30, 0, 0, time.UTC)
+	next, err := NextFire("0 8 * * *", from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("expected %v, got %v", want, next)
+	}
+	if _, err := NextFire("not a schedule", from); err == nil {
+		t.Error("expected error for invalid schedule")
+	}
+}
+
+func TestSchedulerSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+
+	for _, task := range []*state.Task{
+		{Name: "every-second", Prompt: "p", Schedule: "* * * * * *", SessionKey: "telegram:1", Enabled: true},
+		{Name: "broken", Prompt: "p", Schedule: "not a schedule", SessionKey: "telegram:2", Enabled: true},
+		{Name: "off", Prompt: "p", Schedule: "* * * * * *", SessionKey: "telegram:3", Enabled: false},
+	} {
+		if err := store.Add(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		return "fired", nil
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	var snap []Entry
+	deadline := time.After(2500 * time.Millisecond)
+	for {
+		snap = sched.Snapshot()
+		if len(snap) == 2 && snap[1].LastRun != nil {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("task did not fire within 2.5s, snapshot=%+v", snap)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	broken, every := snap[0], snap[1]
+	if broken.Name != "broken" || broken.Error == "" || broken.Next != nil {
+		t.Errorf("expected broken entry with error and no next fire, got %+v", broken)
+	}
+	if every.Name != "every-second" || every.Next == nil || every.Prev == nil {
+		t.Errorf("expected next and prev fire times, got %+v", every)
+	}
+	if every.LastRun.Response != "fired" || every.LastRun.Trigger != "schedule" {
+		t.Errorf("unexpected last run: %+v", every.LastRun)
+	}
+}
+
+func TestSchedulerDeliverer(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "alert",
+		Prompt:     "check",
+		Schedule:   "* * * * * *",
+		SessionKey: "telegram:123",
+		Enabled:    true,
+		Delivery:   map[string]*state.DeliveryTemplate{"telegram": {Emoji: "🚨"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := make(chan *state.Task, 1)
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		return "disk full", nil
+	})
+	sched.SetDeliverer(func(task *state.Task, response string) error {
+		select {
+		case delivered <- task:
+		default:
+		}
+		return errors.New("chat not found")
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	select {
+	case task := <-delivered:
+		if task.Name != "alert" || task.Delivery["telegram"].Emoji != "🚨" {
+			t.Errorf("deliverer got unexpected task %+v", task)
+		}
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("deliverer not called within 2.5s")
+	}
+
+	deadline := time.After(2500 * time.Millisecond)
+	for {
+		task, err := store.Get("alert")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.LastRun != nil {
+			if task.LastRun.Error != "deliver: chat not found" || task.LastRun.Response != "disk full" {
+				t.Errorf("unexpected last run: %+v", task.LastRun)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("last run not recorded within 2.5s")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestSchedulerPipelineRunner(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "triage",
+		Pipeline:   "issues",
+		Schedule:   "* * * * * *",
+		SessionKey: "telegram:123",
+		Enabled:    true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		return "reply to " + prompt, nil
+	})
+	sched.SetPipelineRunner(func(task *state.Task, llm Handler) (string, error) {
+		resp, err := llm(task.SessionKey, task.Pipeline+" prompt")
+		return strings.ToUpper(resp), err
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	deadline := time.After(2500 * time.Millisecond)
+	for {
+		task, err := store.Get("triage")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.LastRun != nil {
+			if task.LastRun.Response != "REPLY TO ISSUES PROMPT" {
+				t.Errorf("unexpected last run: %+v", task.LastRun)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("last run not recorded within 2.5s")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestSchedulerFiresReminders(t *testing.T) {
+	dir := t.TempDir()
+	reminders := state.NewReminderStore(filepath.Join(dir, "reminders.json"))
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	once := &state.Reminder{SessionKey: "telegram:1", Text: "call mum", Due: now.Add(-time.Minute)}
+	daily := &state.Reminder{SessionKey: "telegram:1", Text: "stand-up", Due: now.Add(-49 * time.Hour), Repeat: "daily"}
+	later := &state.Reminder{SessionKey: "telegram:1", Text: "later", Due: now.Add(time.Hour)}
+	for _, r := range []*state.Reminder{once, daily, later} {
+		if err := reminders.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var fired []string
+	sched := New(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil)
+	sched.SetReminders(reminders, func(r *state.Reminder) error {
+		fired = append(fired, r.Text)
+		return errors.New("chat not found")
+	})
+	sched.fireReminders(now)
+
+	// Missed occurrences of a recurring reminder fire once; failures don't retry.
+	if strings.Join(fired, ",") != "stand-up,call mum" {
+		t.Errorf("fired %v", fired)
+	}
+	got, err := reminders.Get(once.ID)
+	if err != nil || got.FiredAt == nil {
+		t.Errorf("one-off not marked fired: %+v, %v", got, err)
+	}
+	got, err = reminders.Get(daily.ID)
+	if err != nil || !got.Due.Equal(now.Add(23*time.Hour)) {
+		t.Errorf("daily not moved to the next occurrence: %+v, %v", got, err)
+	}
+
+	fired = nil
+	sched.fireReminders(now.Add(time.Minute))
+	if len(fired) != 0 {
+		t.Errorf("fired again: %v", fired)
+	}
+	sched.fireReminders(now.Add(25 * time.Hour))
+	if strings.Join(fired, ",") != "later,stand-up" {
+		t.Errorf("fired %v", fired)
+	}
+	if _, err := reminders.Get(once.ID); err == nil {
+		t.Error("expected the fired one-off to be pruned after a day")
+	}
+}
+
+func TestSchedulerDedupe(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "alerts",
+		Prompt:     "any alerts?",
+		Schedule:   "* * * * * *",
+		SessionKey: "telegram:123",
+		Enabled:    true,
+		Dedupe:     true,
+		LastRun:    &state.TaskRun{Trigger: "schedule", ResponseHash: responseHash("All clear.")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := []string{"all  clear.\n", "Disk full"}
+	var fired atomic.Int32
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		n := int(fired.Add(1)) - 1
+		return responses[min(n, len(responses)-1)], nil
+	})
+	delivered := make(chan string, 4)
+	sched.SetDeliverer(func(task *state.Task, response string) error {
+		delivered <- response
+		return nil
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	select {
+	case resp := <-delivered:
+		if resp != "Disk full" {
+			t.Errorf("expected the repeated all-clear to be skipped, delivered %q", resp)
+		}
+	case <-time.After(3500 * time.Millisecond):
+		t.Fatal("changed response not delivered within 3.5s")
+	}
+	sched.Stop()
+	if fired.Load() < 2 {
+		t.Errorf("expected the repeat to run before the change, fired %d times", fired.Load())
+	}
+
+	deadline := time.After(2500 * time.Millisecond)
+	for {
+		task, err := store.Get("alerts")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run := task.LastRun; run != nil && run.Response == "Disk full" {
+			if run.Duplicate || run.ResponseHash != responseHash("disk   FULL") {
+				t.Errorf("unexpected last run: %+v", run)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("last run not recorded within 2.5s")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestSchedulerPicksUpTaskChanges(t *testing.T) {
+	store := state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err := store.Add(&state.Task{Name: "daily", Prompt: "p", Schedule: "0 8 * * *", SessionKey: "telegram:1", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	sched := New(store, func(sessionKey, prompt string) (string, error) { return "", nil })
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	names := func() string {
+		var out []string
+		for _, e := range sched.Snapshot() {
+			out = append(out, e.Name+"="+e.Schedule)
+		}
+		return strings.Join(out, ",")
+	}
+
+	// A recorded run is not a change.
+	loaded := sched.loaded
+	if err := store.RecordRun("daily", state.TaskRun{At: time.Now(), Trigger: "webhook"}); err != nil {
+		t.Fatal(err)
+	}
+	sched.checkTasks()
+	if sched.loaded != loaded {
+		t.Error("recording a run reloaded the scheduler")
+	}
+
+	if err := store.Add(&state.Task{Name: "hourly", Prompt: "p", Schedule: "0 * * * *", SessionKey: "telegram:1", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetEnabled("daily", false); err != nil {
+		t.Fatal(err)
+	}
+	sched.checkTasks()
+	if got := names(); got != "hourly=0 * * * *" {
+		t.Errorf("after changes, loaded %q", got)
+	}
+	if e := sched.Snapshot()[0]; e.Next == nil {
+		t.Error("reloaded task has no next fire time")
+	}
+}
+
+func TestSchedulerTimezone(t *testing.T) {
+	store := state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	for _, task := range []*state.Task{
+		{Name: "default", Prompt: "p", Schedule: "0 8 * * *", SessionKey: "telegram:1", Enabled: true},
+		{Name: "own", Prompt: "p", Schedule: "0 8 * * *", Timezone: "Asia/Tokyo", SessionKey: "telegram:1", Enabled: true},
+		{Name: "prefixed", Prompt: "p", Schedule: "CRON_TZ=UTC 0 8 * * *", SessionKey: "telegram:1", Enabled: true},
+		{Name: "bogus", Prompt: "p", Schedule: "0 8 * * *", Timezone: "Nowhere/Special", SessionKey: "telegram:1", Enabled: true},
+	} {
+		if err := store.Add(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sched := New(store, func(sessionKey, prompt string) (string, error) { return "", nil })
+	if err := sched.SetTimezone("Nowhere/Special"); err == nil {
+		t.Error("expected an unknown timezone to be rejected")
+	}
+	if err := sched.SetTimezone("America/New_York"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	want := map[string]string{"default": "America/New_York", "own": "Asia/Tokyo", "prefixed": "UTC"}
+	for _, e := range sched.Snapshot() {
+		if e.Name == "bogus" {
+			if e.Error == "" {
+				t.Error("expected the unknown task timezone to be an entry error")
+			}
+			continue
+		}
+		if e.Next == nil {
+			t.Fatalf("%s has no next fire time", e.Name)
+		}
+		loc, _ := time.LoadLocation(want[e.Name])
+		if at := e.Next.In(loc); at.Hour() != 8 || at.Minute() != 0 {
+			t.Errorf("%s fires at %s, want 08:00 in %s", e.Name, at, loc)
+		}
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	down := time.Now().Add(-3*time.Hour - 30*time.Minute)
+	for policy, want := range map[string]int32{
+		"":                  0,
+		state.CatchUpSkip:   0,
+		state.CatchUpOnce:   1,
+		state.CatchUpAll:    3,
+		"something-unknown": 0,
+	} {
+		t.Run(policy, func(t *testing.T) {
+			store := state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+			if err := store.Add(&state.Task{Name: "hourly", Prompt: "p", Schedule: "@every 1h", SessionKey: "telegram:1", Enabled: true, CatchUp: policy}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.RecordFire("hourly", down); err != nil {
+				t.Fatal(err)
+			}
+			var runs atomic.Int32
+			sched := New(store, func(sessionKey, prompt string) (string, error) {
+				runs.Add(1)
+				return "", nil
+			})
+			if err := sched.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer sched.Stop()
+
+			deadline := time.Now().Add(time.Second)
+			for runs.Load() < want && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if got := runs.Load(); got != want {
+				t.Errorf("ran %d times, want %d", got, want)
+			}
+
+			task, err := store.Get("hourly")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if task.LastFire == nil || !task.LastFire.After(time.Now().Add(-time.Hour)) {
+				t.Errorf("LastFire = %v, want the last missed fire", task.LastFire)
+			}
+			if want > 0 && (task.LastRun == nil || task.LastRun.Trigger != "catch-up") {
+				t.Errorf("LastRun = %+v, want a catch-up run", task.LastRun)
+			}
+		})
+	}
+}
+
+func TestSchedulerCatchUpStartsCounting(t *testing.T) {
+	store := state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err := store.Add(&state.Task{Name: "new", Prompt: "p", Schedule: "@every 1h", SessionKey: "telegram:1", Enabled: true, CatchUp: state.CatchUpAll}); err != nil {
+		t.Fatal(err)
+	}
+	sched := New(store, func(sessionKey, prompt string) (string, error) {
+		t.Error("a task that never fired has nothing to catch up on")
+		return "", nil
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if task, _ := store.Get("new"); task.LastFire == nil {
+		t.Error("expected Start to record where counting starts")
+	}
+}
+
+func TestSchedulerDeliveryErrorsPerTarget(t *testing.T) {
+	store := state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err := store.Add(&state.Task{
+		Name:       "report",
+		Prompt:     "p",
+		Schedule:   "@every 1h",
+		SessionKey: "http:reports",
+		DeliverTo:  []string{"telegram:1:1", "email:ops@example.com"},
+		Enabled:    true,
+		CatchUp:    state.CatchUpOnce,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A missed fire makes the run happen right away.
+	if err := store.RecordFire("report", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sched := New(store, func(sessionKey, prompt string) (string, error) { return "numbers", nil })
+	var sent atomic.Int32
+	sched.SetDeliverer(func(task *state.Task, response string) error {
+		return delivery.Fanout(task.Targets(), func(target string) error {
+			if strings.HasPrefix(target, "email:") {
+				return errors.New("smtp down")
+			}
+			sent.Add(1)
+			return nil
+		})
+	})
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		task, err := store.Get("report")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run := task.LastRun; run != nil {
+			if got := run.DeliveryErrors; len(got) != 1 || got["email:ops@example.com"] != "smtp down" {
+				t.Errorf("delivery errors = %v", got)
+			}
+			if run.Error != "deliver: email:ops@example.com: smtp down" {
+				t.Errorf("error = %q", run.Error)
+			}
+			if sent.Load() != 1 {
+				t.Errorf("delivered to %d targets, want 1", sent.Load())
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run not recorded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,212 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// sendTimeout bounds sending one message.
+const sendTimeout = 30 * time.Second
+
+// phoneNumber matches an E.164 phone number.
+var phoneNumber = regexp.MustCompile(`^\+[1-9][0-9]{4,14}$`)
+
+func init() {
+	// signal:<number>:<chat>, where chat is the sender's number for a
+	// direct message or "group.<id>" for a group.
+	types.RegisterKeyScheme(types.KeyScheme{Name: "signal", Fields: []string{"number", "chat"}, Validate: func(fields []string) error {
+		if !phoneNumber.MatchString(fields[0]) {
+			return fmt.Errorf("%q is not a phone number like +15550100", fields[0])
+		}
+		if !phoneNumber.MatchString(fields[1]) && !strings.HasPrefix(fields[1], "group.") {
+			return fmt.Errorf("signal chat must be a phone number or group.<id>, got %q", fields[1])
+		}
+		return nil
+	}})
+}
+
+// Key returns the key of a sender's session in a chat: their direct
+// messages if chat is their number, or a group.
+func Key(number, chat string) types.SessionKey {
+	return types.NewSessionKey("signal", number, chat)
+}
+
+// Recipient returns where messages for a signal: key go.
+func Recipient(sessionKey string) (string, error) {
+	p, err := types.ParseSessionKey(sessionKey)
+	if err != nil {
+		return "", err
+	}
+	if p.Scheme != "signal" {
+		return "", fmt.Errorf("%w %q: not a signal key", types.ErrInvalidSessionKey, sessionKey)
+	}
+	return p.Field("chat"), nil
+}
+
+// Handler starts runs for inbound messages; *gateway.Gateway is one.
+type Handler interface {
+	HandleInbound(ctx context.Context, event *types.InboundEvent, opts ...gateway.RunOption) error
+}
+
+// Adapter receives Signal messages and answers them through the gateway.
+type Adapter struct {
+	client  *Client
+	handler Handler
+	allowed map[string]bool
+}
+
+// NewAdapter creates an adapter. With allowedNumbers set, messages from
+// other numbers are ignored; empty allows anyone.
+func NewAdapter(client *Client, handler Handler, allowedNumbers []string) *Adapter {
+	allowed := make(map[string]bool, len(allowedNumbers))
+	for _, n := range allowedNumbers {
+		allowed[n] = true
+	}
+	return &Adapter{client: client, handler: handler, allowed: allowed}
+}
+
+// Start receives messages until ctx is done, backing off while the API is
+// unreachable.
+func (a *Adapter) Start(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		if err := a.Poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("signal receive failed", "error", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// Poll receives pending messages once and dispatches each.
+func (a *Adapter) Poll(ctx context.Context) error {
+	envs, err := a.client.receive(ctx)
+	if err != nil {
+		return err
+	}
+	for _, env := range envs {
+		a.handle(ctx, env)
+	}
+	return nil
+}
+
+// handle turns a data message into a run whose response goes back to the
+// chat it came from.
+func (a *Adapter) handle(ctx context.Context, env envelope) {
+	msg := env.DataMessage
+	if msg == nil {
+		return
+	}
+	from := env.SourceNumber
+	if from == "" {
+		from = env.Source
+	}
+	if !phoneNumber.MatchString(from) {
+		// Senders who hide their number only have a UUID, which can't
+		// make a key.
+		slog.Warn("ignoring signal message without a sender number", "source", env.Source)
+		return
+	}
+	if len(a.allowed) > 0 && !a.allowed[from] {
+		slog.Warn("ignoring signal message from number not in signal.allowed_numbers", "from", from)
+		return
+	}
+	text := messageText(msg)
+	if text == "" {
+		return // reactions, stickers and the like
+	}
+	chat, chatType := from, "private"
+	if msg.GroupInfo != nil && msg.GroupInfo.GroupID != "" {
+		chat, chatType = "group."+base64.StdEncoding.EncodeToString([]byte(msg.GroupInfo.GroupID)), "group"
+	}
+	meta := &types.InboundMeta{
+		Version:   types.InboundSchemaVersion,
+		MessageID: strconv.FormatInt(msg.Timestamp, 10),
+		ChatID:    chat,
+		ChatType:  chatType,
+		Username:  env.SourceName,
+	}
+	if q := msg.Quote; q != nil {
+		meta.ReplyTo = &types.ReplyRef{MessageID: strconv.FormatInt(q.ID, 10), UserID: q.Author, Text: q.Text}
+	}
+	send := func(response string) {
+		if err := a.send(chat, response); err != nil {
+			slog.Error("signal reply failed", "chat", chat, "error", err)
+		}
+	}
+	err := a.handler.HandleInbound(ctx, &types.InboundEvent{
+		Source:     "signal",
+		SessionKey: Key(from, chat),
+		UserID:     from,
+		Text:       text,
+		Metadata:   meta,
+	}, gateway.WithOnResult(func(result *gateway.RunResult) {
+		if result.Text != "" {
+			send(result.Text)
+		}
+	}), gateway.WithOnNotice(send))
+	if err != nil {
+		var locked *gateway.LockedError
+		if errors.As(err, &locked) {
+			send("This conversation is locked and can't take new messages.")
+			return
+		}
+		slog.Error("signal handle inbound", "from", from, "error", err)
+		send("Sorry, your message couldn't be processed. Please try again.")
+	}
+}
+
+// messageText is a message's text followed by a note for each attachment.
+// Attachments aren't downloaded; the note tells the agent one was sent.
+func messageText(msg *dataMessage) string {
+	parts := []string{}
+	if t := strings.TrimSpace(msg.Message); t != "" {
+		parts = append(parts, t)
+	}
+	for _, att := range msg.Attachments {
+		name := att.Filename
+		if name == "" {
+			name = "unnamed"
+		}
+		parts = append(parts, fmt.Sprintf("[Attachment: %s (%s, %s); its contents are not available]", name, att.ContentType, formatSize(att.Size)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (a *Adapter) send(recipient, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return a.client.Send(ctx, recipient, message)
+}
+
+// SendTo sends message to the chat of a signal: key. It is the delivery
+// handler for scheduled responses.
+func (a *Adapter) SendTo(sessionKey, message string) error {
+	recipient, err := Recipient(sessionKey)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return nil // bot decided not to respond
+	}
+	return a.send(recipient, message)
+}
@@ -0,0 +1,154 @@
+// Package signal bridges Signal to the gateway through signal-cli-rest-api
+// (https://github.com/bbernhard/signal-cli-rest-api). It polls the API for
+// messages to the bot's number, hands them to the gateway and sends the
+// responses back, and delivers "signal:" keys for scheduled tasks.
+package signal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// receiveTimeout is how long the API holds a receive request open waiting
+// for messages.
+const receiveTimeout = 10 * time.Second
+
+// Client talks to signal-cli-rest-api on behalf of one registered number.
+type Client struct {
+	baseURL string
+	number  string
+	http    *http.Client
+}
+
+// NewClient creates a client for the API at baseURL, such as
+// "http://127.0.0.1:8080", acting as number.
+func NewClient(baseURL, number string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		number:  number,
+		http:    &http.Client{Timeout: receiveTimeout + 30*time.Second},
+	}
+}
+
+// Number returns the bot's phone number.
+func (c *Client) Number() string { return c.number }
+
+// envelope is one received item. Only data messages carry anything for
+// the agent; receipts, typing notices and the like have DataMessage nil.
+type envelope struct {
+	Source       string       `json:"source"`
+	SourceNumber string       `json:"sourceNumber"`
+	SourceName   string       `json:"sourceName"`
+	Timestamp    int64        `json:"timestamp"`
+	DataMessage  *dataMessage `json:"dataMessage"`
+}
+
+type dataMessage struct {
+	Timestamp   int64        `json:"timestamp"`
+	Message     string       `json:"message"`
+	GroupInfo   *groupInfo   `json:"groupInfo"`
+	Attachments []attachment `json:"attachments"`
+	Quote       *quote       `json:"quote"`
+}
+
+type groupInfo struct {
+	GroupID string `json:"groupId"`
+}
+
+type attachment struct {
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	ID          string `json:"id"`
+	Size        int64  `json:"size"`
+}
+
+type quote struct {
+	ID     int64  `json:"id"`
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+// receive fetches the messages that arrived since the last call, waiting
+// up to receiveTimeout for one. It needs the API in normal or native mode;
+// json-rpc mode only offers a websocket.
+func (c *Client) receive(ctx context.Context) ([]envelope, error) {
+	u := fmt.Sprintf("%s/v1/receive/%s?timeout=%d", c.baseURL, url.PathEscape(c.number), int(receiveTimeout.Seconds()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var items []struct {
+		Envelope envelope `json:"envelope"`
+	}
+	if err := c.do(req, &items); err != nil {
+		return nil, fmt.Errorf("signal receive: %w", err)
+	}
+	envs := make([]envelope, len(items))
+	for i, item := range items {
+		envs[i] = item.Envelope
+	}
+	return envs, nil
+}
+
+// Send sends a text message to a phone number or a "group.<id>" recipient.
+func (c *Client) Send(ctx context.Context, recipient, message string) error {
+	body, _ := json.Marshal(map[string]any{
+		"number":     c.number,
+		"recipients": []string{recipient},
+		"message":    message,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v2/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("signal send: %w", err)
+	}
+	return nil
+}
+
+// do sends req and decodes a JSON response into out, if not nil.
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// formatSize renders a byte count for an attachment note.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MB"
+	case n >= 1<<10:
+		return strconv.FormatInt(n>>10, 10) + " KB"
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
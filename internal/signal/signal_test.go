@@ -0,0 +1,123 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// fakeAPI serves one batch of envelopes and records what is sent.
+type fakeAPI struct {
+	mu   sync.Mutex
+	sent []map[string]any
+}
+
+func (f *fakeAPI) server(t *testing.T, envelopes string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/receive/{number}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("number") != "+15550100" {
+			http.Error(w, `{"error":"unknown number"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(envelopes))
+	})
+	mux.HandleFunc("POST /v2/send", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.sent = append(f.sent, body)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"timestamp":"1"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeHandler answers every event with its text.
+type fakeHandler struct {
+	events []*types.InboundEvent
+}
+
+func (h *fakeHandler) HandleInbound(_ context.Context, event *types.InboundEvent, opts ...gateway.RunOption) error {
+	h.events = append(h.events, event)
+	run := gateway.NewRun("s1", event)
+	for _, opt := range opts {
+		opt(run)
+	}
+	run.OnResult(&gateway.RunResult{Text: "echo: " + event.Text})
+	return nil
+}
+
+func TestAdapterPoll(t *testing.T) {
+	api := &fakeAPI{}
+	server := api.server(t, `[
+		{"envelope":{"sourceNumber":"+15550111","sourceName":"Alice","dataMessage":{"timestamp":1700000000000,"message":"Hi there",
+			"quote":{"id":1699999999000,"author":"+15550100","text":"Earlier reply"}}}},
+		{"envelope":{"sourceNumber":"+15550111","dataMessage":{"timestamp":1700000000001,"message":"Look",
+			"groupInfo":{"groupId":"abc"},"attachments":[{"contentType":"image/jpeg","filename":"cat.jpg","size":2048}]}}},
+		{"envelope":{"sourceNumber":"+15550199","dataMessage":{"timestamp":1700000000002,"message":"Let me in"}}},
+		{"envelope":{"sourceNumber":"+15550111","typingMessage":{"action":"STARTED"}}},
+		{"envelope":{"sourceNumber":"+15550111","dataMessage":{"timestamp":1700000000003,"reaction":{"emoji":"👍"}}}}
+	]`)
+	h := &fakeHandler{}
+	a := NewAdapter(NewClient(server.URL, "+15550100"), h, []string{"+15550111"})
+	if err := a.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.events) != 2 {
+		t.Fatalf("handled %d events, want 2", len(h.events))
+	}
+
+	dm := h.events[0]
+	if dm.Source != "signal" || dm.SessionKey != "signal:+15550111:+15550111" || dm.Text != "Hi there" {
+		t.Errorf("direct message = %+v", dm)
+	}
+	if m := dm.Metadata; m.ChatType != "private" || m.Username != "Alice" || m.MessageID != "1700000000000" || m.ReplyTo == nil || m.ReplyTo.Text != "Earlier reply" {
+		t.Errorf("direct message metadata = %+v", m)
+	}
+
+	group := h.events[1]
+	if group.SessionKey != "signal:+15550111:group.YWJj" || group.Metadata.ChatType != "group" {
+		t.Errorf("group message = %+v", group)
+	}
+	if want := "Look\n[Attachment: cat.jpg (image/jpeg, 2 KB); its contents are not available]"; group.Text != want {
+		t.Errorf("group text = %q", group.Text)
+	}
+
+	if len(api.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(api.sent))
+	}
+	if r := api.sent[1]["recipients"].([]any); r[0] != "group.YWJj" || api.sent[1]["number"] != "+15550100" {
+		t.Errorf("group reply = %v", api.sent[1])
+	}
+}
+
+func TestSendTo(t *testing.T) {
+	api := &fakeAPI{}
+	a := NewAdapter(NewClient(api.server(t, `[]`).URL, "+15550100"), &fakeHandler{}, nil)
+	if err := a.SendTo("signal:+15550111:+15550111", "Daily report"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.sent) != 1 || api.sent[0]["message"] != "Daily report" {
+		t.Errorf("sent = %v", api.sent)
+	}
+	for _, key := range []string{"telegram:1:1", "signal:15550111:+15550111", "signal:+15550111:general"} {
+		if err := a.SendTo(key, "x"); !errors.Is(err, types.ErrInvalidSessionKey) {
+			t.Errorf("%s: expected ErrInvalidSessionKey, got %v", key, err)
+		}
+	}
+
+	bad := NewAdapter(NewClient(api.server(t, `[]`).URL, "+15550199"), &fakeHandler{}, nil)
+	if err := bad.Poll(context.Background()); err == nil || err.Error() != "signal receive: status 400: unknown number" {
+		t.Errorf("expected the API's error, got %v", err)
+	}
+}
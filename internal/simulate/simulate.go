@@ -0,0 +1,171 @@
+// Package simulate runs the daemon without external side effects: a canned
+// LLM provider, record-only wrappers for mutating tools, and a delivery
+// handler that writes messages to a local log instead of sending them. It
+// lets config and prompt changes be rehearsed on a copy of production data.
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Entry is one line of the simulation log.
+type Entry struct {
+	At   time.Time `json:"at"`
+	Kind string    `json:"kind"` // "tool" or "delivery"
+	// Tool and Arguments describe a tool call that was recorded instead of run.
+	Tool      string          `json:"tool,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	// SessionKey and Message describe a delivery that was not sent. Tool
+	// entries carry the session key when the tool knows it.
+	SessionKey types.SessionKey `json:"session_key,omitempty"`
+	Message    string           `json:"message,omitempty"`
+}
+
+// Recorder appends what simulation mode held back to a JSON lines file.
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewRecorder creates a Recorder writing to path, creating its directory.
+func NewRecorder(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create simulation log dir: %w", err)
+	}
+	return &Recorder{path: path}, nil
+}
+
+// Path returns the log file's path.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+func (r *Recorder) record(e Entry) error {
+	e.At = time.Now()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal simulation entry: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open simulation log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write simulation log: %w", err)
+	}
+	return nil
+}
+
+// Deliver is a delivery.Handler that logs the message instead of sending it.
+func (r *Recorder) Deliver(sessionKey, message string) error {
+	return r.record(Entry{Kind: "delivery", SessionKey: types.SessionKey(sessionKey), Message: message})
+}
+
+// Entries reads the log back, oldest first.
+func (r *Recorder) Entries() ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read simulation log: %w", err)
+	}
+	var entries []Entry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("parse simulation log: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Tool wraps t so executions are recorded instead of run. The wrapped tool
+// keeps t's name, description and parameters, and stays a
+// runtime.SessionTool if t is one.
+func (r *Recorder) Tool(t runtime.Tool) runtime.Tool {
+	if _, ok := t.(runtime.SessionTool); ok {
+		return &sessionTool{tool{Tool: t, rec: r}}
+	}
+	return &tool{Tool: t, rec: r}
+}
+
+type tool struct {
+	runtime.Tool
+	rec *Recorder
+}
+
+func (t *tool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	return t.execute("", args)
+}
+
+func (t *tool) execute(key types.SessionKey, args json.RawMessage) (string, error) {
+	if err := t.rec.record(Entry{Kind: "tool", Tool: t.Name(), Arguments: args, SessionKey: key}); err != nil {
+		return "", fmt.Errorf("%s: %w", t.Name(), err)
+	}
+	return fmt.Sprintf("[simulation] %s was not run; the call was recorded. Assume it succeeded.", t.Name()), nil
+}
+
+type sessionTool struct {
+	tool
+}
+
+func (t *sessionTool) ExecuteInSession(ctx context.Context, session *types.SessionIndex, args json.RawMessage) (string, error) {
+	return t.execute(session.SessionKey, args)
+}
+
+// Provider returns an llm.Provider that answers every request with a canned
+// reply quoting the last user message, without calling any API.
+func Provider() llm.Provider {
+	return cannedProvider{}
+}
+
+type cannedProvider struct{}
+
+func (cannedProvider) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	last := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = messages[i].Content
+			break
+		}
+	}
+	if r := []rune(last); len(r) > 80 {
+		last = string(r[:80]) + "…"
+	}
+	return &llm.Response{
+		Content:      fmt.Sprintf("[simulated reply to %q]", last),
+		Provider:     "simulate",
+		Model:        "canned",
+		FinishReason: "stop",
+	}, nil
+}
+
+func (p cannedProvider) Stream(ctx context.Context, messages []llm.Message, tools []llm.Tool) (<-chan llm.Delta, error) {
+	resp, err := p.Complete(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan llm.Delta, 1)
+	ch <- llm.Delta{Content: resp.Content, FinishReason: resp.FinishReason, Provider: resp.Provider, Model: resp.Model}
+	close(ch)
+	return ch, nil
+}
@@ -0,0 +1,97 @@
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+type stubTool struct{ ran bool }
+
+func (*stubTool) Name() string                { return "bash" }
+func (*stubTool) Description() string         { return "run a command" }
+func (*stubTool) Parameters() json.RawMessage { return json.RawMessage(`{}`) }
+func (s *stubTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	s.ran = true
+	return "ran", nil
+}
+
+type stubSessionTool struct{ stubTool }
+
+func (s *stubSessionTool) ExecuteInSession(ctx context.Context, session *types.SessionIndex, args json.RawMessage) (string, error) {
+	s.ran = true
+	return "ran", nil
+}
+
+func TestRecorderToolsAndDeliveries(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join(t.TempDir(), "simulate", "log.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	inner := &stubTool{}
+	wrapped := rec.Tool(inner)
+	if wrapped.Name() != "bash" {
+		t.Errorf("wrapped name = %q", wrapped.Name())
+	}
+	out, err := wrapped.Execute(ctx, json.RawMessage(`{"command":"rm -rf /tmp/x"}`))
+	if err != nil || inner.ran || !strings.Contains(out, "was not run") {
+		t.Errorf("Execute = %q, %v (ran %v)", out, err, inner.ran)
+	}
+
+	innerSession := &stubSessionTool{}
+	st, ok := rec.Tool(innerSession).(runtime.SessionTool)
+	if !ok {
+		t.Fatal("session tool lost its ExecuteInSession")
+	}
+	if _, err := st.ExecuteInSession(ctx, &types.SessionIndex{SessionKey: "telegram:1:1"}, json.RawMessage(`{}`)); err != nil || innerSession.ran {
+		t.Errorf("ExecuteInSession: %v (ran %v)", err, innerSession.ran)
+	}
+
+	if err := rec.Deliver("telegram:1:1", "good morning"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := rec.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	var args map[string]string
+	json.Unmarshal(entries[0].Arguments, &args)
+	if entries[0].Kind != "tool" || entries[0].Tool != "bash" || args["command"] != "rm -rf /tmp/x" {
+		t.Errorf("unexpected tool entry %+v", entries[0])
+	}
+	if entries[1].SessionKey != "telegram:1:1" {
+		t.Errorf("session tool entry lost its key: %+v", entries[1])
+	}
+	if entries[2].Kind != "delivery" || entries[2].Message != "good morning" || entries[2].SessionKey != "telegram:1:1" {
+		t.Errorf("unexpected delivery entry %+v", entries[2])
+	}
+}
+
+func TestCannedProvider(t *testing.T) {
+	p := Provider()
+	messages := []llm.Message{{Role: "system", Content: "be nice"}, {Role: "user", Content: "what's the weather?"}}
+	resp, err := p.Complete(context.Background(), messages, nil)
+	if err != nil || !strings.Contains(resp.Content, "what's the weather?") || len(resp.ToolCalls) != 0 {
+		t.Errorf("Complete = %+v, %v", resp, err)
+	}
+	deltas, err := p.Stream(context.Background(), messages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := llm.Collect(deltas)
+	if err != nil || streamed.Content != resp.Content || streamed.Model != "canned" {
+		t.Errorf("Stream = %+v, %v", streamed, err)
+	}
+}
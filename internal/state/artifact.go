@@ -144,6 +144,38 @@ func (a *ArtifactStore) GetMeta(_ context.Context, id types.ArtifactID) (*types.
 	return wrapper.Meta, nil
 }
 
+// SetSummary records a short summary of the artifact in its metadata.
+func (a *ArtifactStore) SetSummary(_ context.Context, id types.ArtifactID, summary string) error {
+	path, err := a.findArtifact(id)
+	if err != nil {
+		return err
+	}
+
+	wrapper, err := a.readWrapper(path)
+	if err != nil {
+		return err
+	}
+	if wrapper.Meta == nil {
+		wrapper.Meta = &types.ArtifactMeta{ID: id}
+	}
+	wrapper.Meta.Summary = summary
+
+	content, err := json.MarshalIndent(wrapper, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal artifact wrapper: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("write temp artifact: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp artifact: %w", err)
+	}
+	return nil
+}
+
 // Excerpt returns a truncated text representation of the artifact data,
 // optionally highlighting around a query substring.
 func (a *ArtifactStore) Excerpt(_ context.Context, id types.ArtifactID, query string, maxTokens int) (string, error) {
@@ -0,0 +1,108 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// BudgetResets records when spend budgets were last reset. Spending before
+// a reset no longer counts against the budgets it reset: Global resets
+// them all, an entry in Sessions only that session's.
+type BudgetResets struct {
+	Global   time.Time                     `json:"global,omitempty"`
+	Sessions map[types.SessionID]time.Time `json:"sessions,omitempty"`
+}
+
+// Since returns when the session's budget last started counting: its own
+// reset or the global one, whichever is later.
+func (r *BudgetResets) Since(sessionID types.SessionID) time.Time {
+	if at := r.Sessions[sessionID]; at.After(r.Global) {
+		return at
+	}
+	return r.Global
+}
+
+// BudgetStore is a JSON-file-backed store for budget resets. The daemon
+// reads it before every LLM call, so a reset made by the CLI applies
+// without a restart.
+type BudgetStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewBudgetStore creates a new file-backed BudgetStore at the given file
+// path.
+func NewBudgetStore(path string) *BudgetStore {
+	return &BudgetStore{path: path}
+}
+
+// Load returns the recorded resets, or none if the file doesn't exist.
+func (s *BudgetStore) Load() (*BudgetResets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Reset starts the budgets of sessionID over at at, or all budgets when
+// sessionID is empty.
+func (s *BudgetStore) Reset(sessionID types.SessionID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.load()
+	if err != nil {
+		return err
+	}
+	if sessionID == "" {
+		// Everything before a global reset is forgotten anyway.
+		r.Global, r.Sessions = at, nil
+	} else {
+		if r.Sessions == nil {
+			r.Sessions = make(map[types.SessionID]time.Time)
+		}
+		r.Sessions[sessionID] = at
+	}
+	return s.save(r)
+}
+
+// load reads the JSON file. Returns no resets if the file doesn't exist.
+func (s *BudgetStore) load() (*BudgetResets, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &BudgetResets{}, nil
+		}
+		return nil, fmt.Errorf("read budget file: %w", err)
+	}
+	var r BudgetResets
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("unmarshal budget resets: %w", err)
+	}
+	return &r, nil
+}
+
+// save writes the resets using atomic write (temp file + rename).
+func (s *BudgetStore) save(r *BudgetResets) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create budget dir: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal budget resets: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp budget file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename budget file: %w", err)
+	}
+	return nil
+}
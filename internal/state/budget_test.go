@@ -0,0 +1,37 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBudgetStore(t *testing.T) {
+	store := NewBudgetStore(filepath.Join(t.TempDir(), "budget.json"))
+	r, err := store.Load()
+	if err != nil || !r.Global.IsZero() || len(r.Sessions) != 0 {
+		t.Fatalf("expected no resets for a missing file, got %+v, %v", r, err)
+	}
+
+	t1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	if err := store.Reset("s1", t2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reset("", t1); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = NewBudgetStore(store.path).Load()
+	if !r.Global.Equal(t1) || len(r.Sessions) != 0 {
+		t.Errorf("expected a global reset to clear session resets, got %+v", r)
+	}
+
+	store.Reset("s1", t2)
+	r, _ = store.Load()
+	if got := r.Since("s1"); !got.Equal(t2) {
+		t.Errorf("Since(s1) = %v, want its own reset", got)
+	}
+	if got := r.Since("s2"); !got.Equal(t1) {
+		t.Errorf("Since(s2) = %v, want the global reset", got)
+	}
+}
@@ -0,0 +1,34 @@
+package state_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/state/statetest"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestSessionStoreConformance(t *testing.T) {
+	statetest.TestSessionStore(t, func(t *testing.T) types.SessionStore {
+		return state.NewSessionStore(t.TempDir())
+	})
+}
+
+func TestEventStoreConformance(t *testing.T) {
+	statetest.TestEventStore(t, func(t *testing.T) types.EventStore {
+		return state.NewEventStore(t.TempDir())
+	})
+}
+
+func TestArtifactStoreConformance(t *testing.T) {
+	statetest.TestArtifactStore(t, func(t *testing.T) types.ArtifactStore {
+		return state.NewArtifactStore(t.TempDir())
+	})
+}
+
+func TestTaskStoreConformance(t *testing.T) {
+	statetest.TestTaskStore(t, func(t *testing.T) statetest.TaskStore {
+		return state.NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	})
+}
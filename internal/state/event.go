@@ -3,9 +3,11 @@ package state
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,10 +17,30 @@ import (
 
 // EventStore is a JSONL-backed append-only event store.
 // Events are stored per-session in sessions/<sessionID>/events.jsonl.
+//
+// With compression enabled, an events.jsonl that grows past the segment
+// size is compressed into a sealed segment named after the sequence numbers
+// it holds (events-<first>-<last>.jsonl.gz or .zst) and a new events.jsonl
+// is started. Reads decompress segments as needed whatever the current
+// setting, so compression can be turned on or off at any time.
+//
+// The next sequence number comes from a per-session mark (the last
+// sequence number and the size of events.jsonl after it) cached in memory
+// and in events.idx, so appends don't rescan the log. A log that grew
+// behind the mark's back, e.g. through another instance sharing the data
+// dir, is counted from the mark's offset; one that shrank is recounted.
 type EventStore struct {
 	root  string
 	mu    sync.Mutex
 	locks map[types.SessionID]*sync.Mutex
+	marks map[types.SessionID]seqMark
+
+	codec       string
+	segmentSize int64
+
+	// subs are the live subscribers by session; see Subscribe.
+	subMu sync.Mutex
+	subs  map[types.SessionID]map[*subscriber]bool
 }
 
 // NewEventStore creates a new file-backed EventStore rooted at the given directory.
@@ -26,6 +48,7 @@ func NewEventStore(root string) *EventStore {
 	return &EventStore{
 		root:  root,
 		locks: make(map[types.SessionID]*sync.Mutex),
+		marks: make(map[types.SessionID]seqMark),
 	}
 }
 
@@ -42,70 +65,172 @@ func (e *EventStore) getLock(sessionID types.SessionID) *sync.Mutex {
 	return lock
 }
 
+// DefaultSegmentSize is the events.jsonl size at which it is compressed
+// into a segment when no other size is set.
+const DefaultSegmentSize = 4 << 20
+
+// SetCompression enables compression of event log segments with codec
+// ("gzip" or "zstd"; "" disables it). The active events.jsonl is sealed
+// into a segment once it reaches segmentSize bytes; 0 uses
+// DefaultSegmentSize.
+func (e *EventStore) SetCompression(codec string, segmentSize int64) error {
+	if codec != "" && segmentExt(codec) == "" {
+		return fmt.Errorf("unknown event compression %q (want gzip or zstd)", codec)
+	}
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.codec = codec
+	e.segmentSize = segmentSize
+	return nil
+}
+
 func (e *EventStore) eventsPath(sessionID types.SessionID) string {
 	return filepath.Join(e.root, "sessions", string(sessionID), "events.jsonl")
 }
 
-// count reads the event file and counts lines. Caller must hold the session lock.
+// count returns the number of events in the session's log: the last
+// sequence number sealed in a segment plus the lines of events.jsonl.
+// Caller must hold the session lock.
 func (e *EventStore) count(sessionID types.SessionID) (int64, error) {
+	segments, err := listSegments(filepath.Dir(e.eventsPath(sessionID)))
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if len(segments) > 0 {
+		count = segments[len(segments)-1].last
+	}
+
+	lines, err := countLinesFrom(e.eventsPath(sessionID), 0)
+	if err != nil {
+		return 0, err
+	}
+	return count + lines, nil
+}
+
+// readAll reads and parses every event in the session's log, segments
+// first. Returns nil if the log doesn't exist. Caller must hold the session
+// lock.
+func (e *EventStore) readAll(sessionID types.SessionID) ([]*types.Event, error) {
+	segments, err := listSegments(filepath.Dir(e.eventsPath(sessionID)))
+	if err != nil {
+		return nil, err
+	}
+	var events []*types.Event
+	for _, seg := range segments {
+		lines, err := seg.lines()
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseEvents(lines)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, parsed...)
+	}
+
 	f, err := os.Open(e.eventsPath(sessionID))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, nil
+			return events, nil
 		}
-		return 0, fmt.Errorf("open events file: %w", err)
+		return nil, fmt.Errorf("open events file: %w", err)
 	}
 	defer f.Close()
 
-	var count int64
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		count++
+		var event types.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("unmarshal event: %w", err)
+		}
+		events = append(events, &event)
 	}
 	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("scan events file: %w", err)
+		return nil, fmt.Errorf("scan events file: %w", err)
 	}
-	return count, nil
+	return events, nil
 }
 
 // Append adds an event to the session's event log with an auto-incremented sequence number.
-func (e *EventStore) Append(_ context.Context, event *types.Event) error {
-	lock := e.getLock(event.SessionID)
+func (e *EventStore) Append(ctx context.Context, event *types.Event) error {
+	return e.AppendBatch(ctx, []*types.Event{event})
+}
+
+// AppendBatch adds a group of events to a single session's event log in one
+// write, assigning consecutive sequence numbers. It is used to flush all
+// events of a tool round together so a crash cannot separate a tool_call
+// from its tool_result. All events must share the same SessionID.
+func (e *EventStore) AppendBatch(_ context.Context, events []*types.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	sessionID := events[0].SessionID
+	for _, event := range events[1:] {
+		if event.SessionID != sessionID {
+			return fmt.Errorf("batch spans multiple sessions: %s and %s", sessionID, event.SessionID)
+		}
+	}
+
+	lock := e.getLock(sessionID)
 	lock.Lock()
 	defer lock.Unlock()
 
 	// Ensure the session directory exists
-	dir := filepath.Dir(e.eventsPath(event.SessionID))
+	dir := filepath.Dir(e.eventsPath(sessionID))
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create session dir: %w", err)
 	}
 
-	// Count existing events to determine sequence number
-	existing, err := e.count(event.SessionID)
+	existing, err := e.lastSeq(sessionID)
 	if err != nil {
 		return err
 	}
-	event.Seq = existing + 1
 
-	// Marshal the event to JSON
-	data, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("marshal event: %w", err)
+	// Marshal every event up front so a marshal failure writes nothing
+	var buf []byte
+	for i, event := range events {
+		event.Seq = existing + int64(i) + 1
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
 	}
 
-	// Append to the events file
-	f, err := os.OpenFile(e.eventsPath(event.SessionID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	// Append to the events file in a single write
+	f, err := os.OpenFile(e.eventsPath(sessionID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return fmt.Errorf("open events file: %w", err)
 	}
 	defer f.Close()
 
-	data = append(data, '\n')
-	if _, err := f.Write(data); err != nil {
-		return fmt.Errorf("write event: %w", err)
+	if _, err := f.Write(buf); err != nil {
+		return fmt.Errorf("write events: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat events file: %w", err)
 	}
+	last := existing + int64(len(events))
 
-	return nil
+	e.publish(sessionID, events)
+
+	e.mu.Lock()
+	codec, segmentSize := e.codec, e.segmentSize
+	e.mu.Unlock()
+	if codec == "" || info.Size() < segmentSize {
+		return e.setMark(sessionID, seqMark{Seq: last, Offset: info.Size()})
+	}
+	f.Close()
+	if err := e.seal(sessionID, codec, last); err != nil {
+		return err
+	}
+	return e.setMark(sessionID, seqMark{Seq: last})
 }
 
 // Tail returns the last N events for the given session.
@@ -114,34 +239,118 @@ func (e *EventStore) Tail(_ context.Context, sessionID types.SessionID, limit in
 	lock.Lock()
 	defer lock.Unlock()
 
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var lines [][]byte
 	f, err := os.Open(e.eventsPath(sessionID))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+	switch {
+	case err == nil:
+		lines, err = readLastLines(f, limit)
+		f.Close()
+		if err != nil {
+			return nil, err
 		}
+	case !os.IsNotExist(err):
 		return nil, fmt.Errorf("open events file: %w", err)
 	}
-	defer f.Close()
 
-	var events []*types.Event
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
+	// Fill the rest from the newest segments.
+	if len(lines) < limit {
+		segments, err := listSegments(filepath.Dir(e.eventsPath(sessionID)))
+		if err != nil {
+			return nil, err
+		}
+		for i := len(segments) - 1; i >= 0 && len(lines) < limit; i-- {
+			older, err := segments[i].lines()
+			if err != nil {
+				return nil, err
+			}
+			if need := limit - len(lines); len(older) > need {
+				older = older[len(older)-need:]
+			}
+			lines = append(older, lines...)
+		}
+	}
+	return parseEvents(lines)
+}
+
+// parseEvents unmarshals one event per line.
+func parseEvents(lines [][]byte) ([]*types.Event, error) {
+	events := make([]*types.Event, 0, len(lines))
+	for _, line := range lines {
 		var event types.Event
-		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+		if err := json.Unmarshal(line, &event); err != nil {
 			return nil, fmt.Errorf("unmarshal event: %w", err)
 		}
 		events = append(events, &event)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan events file: %w", err)
+	return events, nil
+}
+
+// tailBlockSize is the chunk size used when scanning an event log backwards.
+const tailBlockSize = 64 * 1024
+
+// readLastLines returns up to n non-empty lines from the end of f, in file
+// order. It reads the file backwards in fixed-size blocks so the cost is
+// proportional to the bytes covered by the requested lines rather than the
+// size of the whole file. Each block is scanned once and the blocks are
+// joined once at the end.
+func readLastLines(f *os.File, n int) ([][]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat events file: %w", err)
 	}
 
-	// Return last N events
-	if len(events) > limit {
-		events = events[len(events)-limit:]
+	// A line is known to be complete once the newline before it has been
+	// read, so count newlines followed by a non-newline byte; blank lines
+	// and newlines at the end of the file don't start a line.
+	var blocks [][]byte
+	var total, starts int
+	pos := info.Size()
+	for pos > 0 && starts < n {
+		size := int64(tailBlockSize)
+		if size > pos {
+			size = pos
+		}
+		pos -= size
+		block := make([]byte, size)
+		if _, err := f.ReadAt(block, pos); err != nil {
+			return nil, fmt.Errorf("read events file: %w", err)
+		}
+		for i, c := range block {
+			if c != '\n' {
+				continue
+			}
+			if i+1 < len(block) {
+				if block[i+1] != '\n' {
+					starts++
+				}
+			} else if len(blocks) > 0 && blocks[len(blocks)-1][0] != '\n' {
+				starts++
+			}
+		}
+		blocks = append(blocks, block)
+		total += len(block)
 	}
 
-	return events, nil
+	data := make([]byte, 0, total)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		data = append(data, blocks[i]...)
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	// When the scan stopped mid-file the first line may be partial; it is
+	// always beyond the n lines we keep, so trimming to n discards it.
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
 }
 
 // Count returns the number of events for the given session.
@@ -150,5 +359,354 @@ func (e *EventStore) Count(_ context.Context, sessionID types.SessionID) (int64,
 	lock.Lock()
 	defer lock.Unlock()
 
-	return e.count(sessionID)
+	return e.lastSeq(sessionID)
+}
+
+// repairedResult is the tool_result text written for tool calls whose result
+// was never recorded.
+const repairedResult = "error: run was interrupted before this tool call completed"
+
+// Repair finds tool_call events in the session's active events.jsonl that
+// have no matching tool_result in the same run and inserts a synthetic
+// error result directly after each one. Tool rounds are appended in one
+// batch, so a call can only be left dangling by a crash; a log whose
+// events.idx mark matches events.jsonl and whose last event isn't a
+// tool_call is taken as clean without reading the rest. Sealed segments
+// are never read or rewritten, so their sequence numbers stay fixed; only
+// events after an inserted result in events.jsonl are renumbered. The file
+// is rewritten atomically. Returns the number of tool calls repaired.
+func (e *EventStore) Repair(_ context.Context, sessionID types.SessionID) (int, error) {
+	lock := e.getLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	path := e.eventsPath(sessionID)
+	clean, err := e.tailClean(sessionID)
+	if err != nil || clean {
+		return 0, err
+	}
+	events, err := e.readActive(sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	type callKey struct {
+		run    types.RunID
+		callID string
+	}
+	answered := make(map[callKey]bool)
+	for _, event := range events {
+		if event.Type != "tool_result" {
+			continue
+		}
+		var p struct {
+			CallID string `json:"call_id"`
+		}
+		if err := json.Unmarshal(event.Payload, &p); err == nil {
+			answered[callKey{event.RunID, p.CallID}] = true
+		}
+	}
+
+	repaired := 0
+	out := make([]*types.Event, 0, len(events))
+	for _, event := range events {
+		out = append(out, event)
+		if event.Type != "tool_call" {
+			continue
+		}
+		var p struct {
+			Tool   string `json:"tool"`
+			CallID string `json:"call_id"`
+		}
+		if err := json.Unmarshal(event.Payload, &p); err != nil {
+			continue
+		}
+		if answered[callKey{event.RunID, p.CallID}] {
+			continue
+		}
+		payload, _ := json.Marshal(map[string]string{
+			"tool":    p.Tool,
+			"call_id": p.CallID,
+			"result":  repairedResult,
+		})
+		out = append(out, &types.Event{
+			ID:        types.NewEventID(),
+			SessionID: sessionID,
+			RunID:     event.RunID,
+			Type:      "tool_result",
+			Source:    "repair",
+			At:        event.At,
+			Payload:   payload,
+		})
+		repaired++
+	}
+
+	if repaired == 0 {
+		return 0, nil
+	}
+
+	// Number on from the last sealed event, as AppendBatch did.
+	segments, err := listSegments(filepath.Dir(path))
+	if err != nil {
+		return 0, err
+	}
+	var base int64
+	if len(segments) > 0 {
+		base = segments[len(segments)-1].last
+	}
+	var buf []byte
+	for i, event := range out {
+		event.Seq = base + int64(i) + 1
+		data, err := json.Marshal(event)
+		if err != nil {
+			return 0, fmt.Errorf("marshal event: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	// The mark no longer matches the rewritten log; drop it first so a
+	// crash before the new one is written leads to a recount.
+	if err := e.dropMark(sessionID); err != nil {
+		return 0, err
+	}
+
+	// Atomic write: write to temp file then rename
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return 0, fmt.Errorf("write temp events file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("rename temp events file: %w", err)
+	}
+	if err := e.setMark(sessionID, seqMark{Seq: base + int64(len(out)), Offset: int64(len(buf))}); err != nil {
+		return 0, err
+	}
+	return repaired, nil
+}
+
+// tailClean reports whether the session's events.jsonl needs no repair:
+// it is missing, or its persisted mark matches its size and its last event
+// is not a tool_call. Only the mark and the last line are read. Caller
+// must hold the session lock.
+func (e *EventStore) tailClean(sessionID types.SessionID) (bool, error) {
+	f, err := os.Open(e.eventsPath(sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("open events file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("stat events file: %w", err)
+	}
+	if mark, ok := e.readMark(sessionID); !ok || mark.Offset != info.Size() {
+		return false, nil
+	}
+	lines, err := readLastLines(f, 1)
+	if err != nil {
+		return false, err
+	}
+	if len(lines) == 0 {
+		return true, nil
+	}
+	var last struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(lines[0], &last); err != nil {
+		return false, nil
+	}
+	return last.Type != "tool_call", nil
+}
+
+// readActive parses the events in the session's events.jsonl, leaving
+// sealed segments alone. Caller must hold the session lock.
+func (e *EventStore) readActive(sessionID types.SessionID) ([]*types.Event, error) {
+	data, err := os.ReadFile(e.eventsPath(sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read events file: %w", err)
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return parseEvents(lines)
+}
+
+// RepairAll runs Repair on every session with an active events.jsonl and
+// returns the total number of tool calls repaired.
+func (e *EventStore) RepairAll(ctx context.Context) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(e.root, "sessions", "*", "events.jsonl"))
+	if err != nil {
+		return 0, fmt.Errorf("glob event logs: %w", err)
+	}
+	total := 0
+	for _, path := range matches {
+		sessionID := types.SessionID(filepath.Base(filepath.Dir(path)))
+		n, err := e.Repair(ctx, sessionID)
+		if err != nil {
+			return total, fmt.Errorf("repair session %s: %w", sessionID, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// seqMark records that a session's events.jsonl was Offset bytes long
+// when the log's last event had sequence number Seq.
+type seqMark struct {
+	Seq    int64 `json:"seq"`
+	Offset int64 `json:"offset"`
+}
+
+// indexPath returns the path of the session's persisted seqMark.
+func (e *EventStore) indexPath(sessionID types.SessionID) string {
+	return filepath.Join(e.root, "sessions", string(sessionID), "events.idx")
+}
+
+// lastSeq returns the sequence number of the session's last event, using
+// the cached or persisted mark when it still matches events.jsonl. Caller
+// must hold the session lock.
+func (e *EventStore) lastSeq(sessionID types.SessionID) (int64, error) {
+	path := e.eventsPath(sessionID)
+	var size int64
+	info, err := os.Stat(path)
+	exists := err == nil
+	switch {
+	case exists:
+		size = info.Size()
+	case !os.IsNotExist(err):
+		return 0, fmt.Errorf("stat events file: %w", err)
+	}
+
+	e.mu.Lock()
+	mark, ok := e.marks[sessionID]
+	e.mu.Unlock()
+	if !ok {
+		mark, ok = e.readMark(sessionID)
+	}
+	if ok && mark.Offset == size {
+		e.mu.Lock()
+		e.marks[sessionID] = mark
+		e.mu.Unlock()
+		return mark.Seq, nil
+	}
+
+	var seq int64
+	if ok && mark.Offset < size {
+		added, err := countLinesFrom(path, mark.Offset)
+		if err != nil {
+			return 0, err
+		}
+		seq = mark.Seq + added
+	} else if seq, err = e.count(sessionID); err != nil {
+		return 0, err
+	}
+	mark = seqMark{Seq: seq, Offset: size}
+	if !exists {
+		// Without events.jsonl the session directory may not exist
+		// either; keep the mark in memory until the next append.
+		e.mu.Lock()
+		e.marks[sessionID] = mark
+		e.mu.Unlock()
+		return seq, nil
+	}
+	if err := e.setMark(sessionID, mark); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// readMark loads the session's persisted mark. A missing or unreadable
+// index reports false, which leads to a recount.
+func (e *EventStore) readMark(sessionID types.SessionID) (seqMark, bool) {
+	data, err := os.ReadFile(e.indexPath(sessionID))
+	if err != nil {
+		return seqMark{}, false
+	}
+	var mark seqMark
+	if err := json.Unmarshal(data, &mark); err != nil || mark.Seq < 0 || mark.Offset < 0 {
+		return seqMark{}, false
+	}
+	return mark, true
+}
+
+// setMark caches mark and persists it to the session's index using atomic
+// write (temp file + rename). Caller must hold the session lock.
+func (e *EventStore) setMark(sessionID types.SessionID, mark seqMark) error {
+	e.mu.Lock()
+	e.marks[sessionID] = mark
+	e.mu.Unlock()
+
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return fmt.Errorf("marshal event index: %w", err)
+	}
+	path := e.indexPath(sessionID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp event index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp event index: %w", err)
+	}
+	return nil
+}
+
+// dropMark forgets the session's mark in memory and on disk. Caller must
+// hold the session lock.
+func (e *EventStore) dropMark(sessionID types.SessionID) error {
+	e.mu.Lock()
+	delete(e.marks, sessionID)
+	e.mu.Unlock()
+	if err := os.Remove(e.indexPath(sessionID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove event index: %w", err)
+	}
+	return nil
+}
+
+// countLinesFrom counts the lines of the file at path from byte offset on,
+// including a final line without a newline. A missing file has none.
+func countLinesFrom(path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("open events file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek events file: %w", err)
+	}
+
+	var count int64
+	buf := make([]byte, tailBlockSize)
+	last := byte('\n')
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read events file: %w", err)
+		}
+	}
+	if last != '\n' {
+		count++
+	}
+	return count, nil
 }
@@ -2,8 +2,14 @@
 package state
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,3 +60,537 @@ func TestEventStore(t *testing.T) {
 		t.Errorf("expected count 1, got %d", count)
 	}
 }
+
+func TestEventStoreAppendBatch(t *testing.T) {
+	dir := t.TempDir()
+	store := NewEventStore(dir)
+	ctx := context.Background()
+
+	sessionID := types.NewSessionID()
+	runID := types.NewRunID()
+
+	if err := store.Append(ctx, &types.Event{
+		ID: types.NewEventID(), SessionID: sessionID, RunID: runID,
+		Type: "user_message", Source: "test", At: time.Now(),
+		Payload: json.RawMessage(`{"text":"hi"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []*types.Event{
+		{ID: types.NewEventID(), SessionID: sessionID, RunID: runID, Type: "tool_call", Source: "test", At: time.Now(), Payload: json.RawMessage(`{"tool":"echo","call_id":"c1"}`)},
+		{ID: types.NewEventID(), SessionID: sessionID, RunID: runID, Type: "tool_result", Source: "test", At: time.Now(), Payload: json.RawMessage(`{"tool":"echo","call_id":"c1","result":"ok"}`)},
+	}
+	if err := store.AppendBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Seq != 2 || batch[1].Seq != 3 {
+		t.Errorf("expected seqs 2,3, got %d,%d", batch[0].Seq, batch[1].Seq)
+	}
+
+	events, err := store.Tail(ctx, sessionID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if events[2].Type != "tool_result" {
+		t.Errorf("expected last event tool_result, got %s", events[2].Type)
+	}
+}
+
+func TestEventStoreAppendBatchRejectsMixedSessions(t *testing.T) {
+	store := NewEventStore(t.TempDir())
+	batch := []*types.Event{
+		{ID: types.NewEventID(), SessionID: types.NewSessionID(), Type: "user_message", Payload: json.RawMessage(`{}`)},
+		{ID: types.NewEventID(), SessionID: types.NewSessionID(), Type: "user_message", Payload: json.RawMessage(`{}`)},
+	}
+	if err := store.AppendBatch(context.Background(), batch); err == nil {
+		t.Fatal("expected error for batch spanning sessions")
+	}
+}
+
+func TestEventStoreRepair(t *testing.T) {
+	dir := t.TempDir()
+	store := NewEventStore(dir)
+	ctx := context.Background()
+
+	sessionID := types.NewSessionID()
+	runID := types.NewRunID()
+	appendEvent := func(typ, payload string) {
+		t.Helper()
+		if err := store.Append(ctx, &types.Event{
+			ID: types.NewEventID(), SessionID: sessionID, RunID: runID,
+			Type: typ, Source: "test", At: time.Now(), Payload: json.RawMessage(payload),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendEvent("user_message", `{"text":"hi"}`)
+	appendEvent("tool_call", `{"tool":"bash","call_id":"c1"}`)
+
+	// A clean log whose last event is a result isn't touched.
+	clean := types.NewSessionID()
+	if err := store.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: clean, Type: "tool_result", Payload: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	cleanInfo, err := os.Stat(store.eventsPath(clean))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// Simulate a crash that left the mark behind the log.
+	appendEvent("user_message", `{"text":"hello?"}`)
+	if err := os.Remove(store.indexPath(sessionID)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.RepairAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 repaired call, got %d", n)
+	}
+
+	events, err := store.Tail(ctx, sessionID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events after repair, got %d", len(events))
+	}
+	if events[2].Type != "tool_result" {
+		t.Errorf("expected synthetic tool_result after tool_call, got %s", events[2].Type)
+	}
+	for i, ev := range events {
+		if ev.Seq != int64(i+1) {
+			t.Errorf("expected seq %d, got %d", i+1, ev.Seq)
+		}
+	}
+
+	// A second pass finds nothing to repair.
+	n, err = store.Repair(ctx, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected idempotent repair, got %d", n)
+	}
+
+	if info, err := os.Stat(store.eventsPath(clean)); err != nil || !info.ModTime().Equal(cleanInfo.ModTime()) {
+		t.Errorf("expected the clean log to be left alone, got %v", err)
+	}
+}
+
+// writeEventLog writes n events directly to a session's log, bypassing Append
+// so large fixtures can be built quickly.
+func writeEventLog(tb testing.TB, store *EventStore, sessionID types.SessionID, n int) {
+	tb.Helper()
+	path := store.eventsPath(sessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	payload := json.RawMessage(fmt.Sprintf(`{"text":%q}`, strings.Repeat("x", 200)))
+	for i := 1; i <= n; i++ {
+		data, _ := json.Marshal(&types.Event{
+			ID: types.NewEventID(), SessionID: sessionID, Seq: int64(i),
+			Type: "user_message", Source: "test", At: time.Now(), Payload: payload,
+		})
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestEventStoreTailAcrossBlocks(t *testing.T) {
+	store := NewEventStore(t.TempDir())
+	ctx := context.Background()
+	sessionID := types.NewSessionID()
+
+	// ~300 bytes per event, so 1000 events span several tail blocks.
+	writeEventLog(t, store, sessionID, 1000)
+
+	for _, limit := range []int{1, 100, 500, 1000, 5000} {
+		events, err := store.Tail(ctx, sessionID, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := limit
+		if want > 1000 {
+			want = 1000
+		}
+		if len(events) != want {
+			t.Fatalf("limit %d: expected %d events, got %d", limit, want, len(events))
+		}
+		if events[len(events)-1].Seq != 1000 {
+			t.Errorf("limit %d: expected last seq 1000, got %d", limit, events[len(events)-1].Seq)
+		}
+		if events[0].Seq != int64(1000-want+1) {
+			t.Errorf("limit %d: expected first seq %d, got %d", limit, 1000-want+1, events[0].Seq)
+		}
+	}
+}
+
+func TestReadLastLinesLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	long := strings.Repeat("x", tailBlockSize+100)
+	// No trailing newline after the last line, and a blank run before it.
+	content := "first\n" + long + "\n" + "middle\n\n\n" + long + "y"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for n, want := range map[int][]string{
+		1: {long + "y"},
+		2: {"middle", long + "y"},
+		3: {long, "middle", long + "y"},
+		4: {"first", long, "middle", long + "y"},
+		9: {"first", long, "middle", long + "y"},
+	} {
+		lines, err := readLastLines(f, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, string(l))
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("n=%d: got %d lines %.40q, want %d", n, len(got), got, len(want))
+		}
+	}
+}
+
+func BenchmarkEventStoreTail(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("events=%d", size), func(b *testing.B) {
+			store := NewEventStore(b.TempDir())
+			sessionID := types.NewSessionID()
+			writeEventLog(b, store, sessionID, size)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Tail(ctx, sessionID, 100); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEventStoreAppend(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("events=%d", size), func(b *testing.B) {
+			store := NewEventStore(b.TempDir())
+			sessionID := types.NewSessionID()
+			writeEventLog(b, store, sessionID, size)
+			ctx := context.Background()
+			payload := json.RawMessage(`{"text":"hello"}`)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.Append(ctx, &types.Event{
+					ID: types.NewEventID(), SessionID: sessionID,
+					Type: "user_message", Source: "bench", At: time.Now(), Payload: payload,
+				}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEventStoreCompression(t *testing.T) {
+	for _, codec := range []string{"gzip", "zstd"} {
+		t.Run(codec, func(t *testing.T) {
+			dir := t.TempDir()
+			store := NewEventStore(dir)
+			if err := store.SetCompression(codec, 1024); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			sessionID := types.NewSessionID()
+
+			for i := 0; i < 60; i++ {
+				payload, _ := json.Marshal(map[string]string{"text": strings.Repeat(fmt.Sprint(i), 40)})
+				if err := store.Append(ctx, &types.Event{
+					ID: types.NewEventID(), SessionID: sessionID, Type: "user_message", Source: "test", Payload: payload,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			segments, err := listSegments(filepath.Join(dir, "sessions", string(sessionID)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(segments) < 2 || segments[0].first != 1 || segments[0].codec != codec {
+				t.Fatalf("expected several %s segments, got %+v", codec, segments)
+			}
+
+			if count, err := store.Count(ctx, sessionID); err != nil || count != 60 {
+				t.Fatalf("expected count 60, got %d, %v", count, err)
+			}
+			for _, limit := range []int{3, 25, 100} {
+				events, err := store.Tail(ctx, sessionID, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := min(limit, 60)
+				if len(events) != want {
+					t.Fatalf("Tail(%d): expected %d events, got %d", limit, want, len(events))
+				}
+				for i, event := range events {
+					if event.Seq != int64(60-want+i+1) {
+						t.Fatalf("Tail(%d): event %d has seq %d", limit, i, event.Seq)
+					}
+				}
+			}
+
+			// Turning compression off keeps the segments readable.
+			if err := store.SetCompression("", 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sessionID, Type: "user_message", Source: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			all, err := store.readAll(sessionID)
+			if err != nil || len(all) != 61 || all[60].Seq != 61 {
+				t.Fatalf("expected 61 events in order, got %d, %v", len(all), err)
+			}
+		})
+	}
+
+	if err := NewEventStore(t.TempDir()).SetCompression("lz4", 0); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func TestEventStoreRepairAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	store := NewEventStore(dir)
+	if err := store.SetCompression("gzip", 200); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sessionID := types.NewSessionID()
+	runID := types.NewRunID()
+
+	if err := store.Append(ctx, &types.Event{
+		ID: types.NewEventID(), SessionID: sessionID, RunID: runID, Type: "tool_call", Source: "test",
+		Payload: json.RawMessage(`{"tool":"bash","call_id":"c1","arguments":{"command":"sleep 1000"}}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := store.Append(ctx, &types.Event{
+			ID: types.NewEventID(), SessionID: sessionID, Type: "user_message", Source: "test",
+			Payload: json.RawMessage(`{"text":"are you still there? this message pads the log"}`),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessionDir := filepath.Join(dir, "sessions", string(sessionID))
+	before, err := listSegments(sessionDir)
+	if err != nil || len(before) == 0 {
+		t.Fatalf("expected sealed segments, got %+v, %v", before, err)
+	}
+
+	// A dangling call already sealed into a segment is left alone.
+	if repaired, err := store.RepairAll(ctx); err != nil || repaired != 0 {
+		t.Fatalf("expected sealed segments untouched, got %d, %v", repaired, err)
+	}
+
+	// A crash mid-round leaves a call in events.jsonl behind the mark.
+	dangling, _ := json.Marshal(&types.Event{
+		ID: types.NewEventID(), SessionID: sessionID, RunID: types.NewRunID(), Seq: 7, Type: "tool_call", Source: "test",
+		Payload: json.RawMessage(`{"tool":"bash","call_id":"c2"}`),
+	})
+	f, err := os.OpenFile(store.eventsPath(sessionID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(append(dangling, '\n'))
+	f.Close()
+
+	repaired, err := store.RepairAll(ctx)
+	if err != nil || repaired != 1 {
+		t.Fatalf("expected 1 repaired call, got %d, %v", repaired, err)
+	}
+	if after, _ := listSegments(sessionDir); !reflect.DeepEqual(after, before) {
+		t.Errorf("expected segments unchanged, got %+v, want %+v", after, before)
+	}
+	events, err := store.Tail(ctx, sessionID, 10)
+	if err != nil || len(events) != 8 {
+		t.Fatalf("unexpected repaired log: %d events, %v", len(events), err)
+	}
+	if events[0].Type != "tool_call" || events[1].Seq != 2 || events[6].Seq != 7 || events[7].Type != "tool_result" || events[7].Seq != 8 {
+		t.Errorf("expected sealed seqs kept and the result numbered after them, got %+v", events)
+	}
+}
+
+func TestEventStoreSeqIndex(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessionID := types.NewSessionID()
+	newEvent := func() *types.Event {
+		return &types.Event{ID: types.NewEventID(), SessionID: sessionID, Type: "user_message", At: time.Now(), Payload: json.RawMessage(`{}`)}
+	}
+
+	store := NewEventStore(dir)
+	for range 5 {
+		if err := store.Append(ctx, newEvent()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := store.indexPath(sessionID)
+	mark, ok := store.readMark(sessionID)
+	if !ok || mark.Seq != 5 {
+		t.Fatalf("index mark = %+v, %v; want seq 5", mark, ok)
+	}
+
+	// A second instance appends behind the first one's cached mark; the
+	// first counts only the new lines.
+	other := NewEventStore(dir)
+	if err := other.Append(ctx, newEvent()); err != nil {
+		t.Fatal(err)
+	}
+	event := newEvent()
+	if err := store.Append(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Seq != 7 {
+		t.Errorf("seq after concurrent writer = %d, want 7", event.Seq)
+	}
+
+	// A restarted store trusts the index without reading the log: a mark
+	// whose offset matches the file is taken as is.
+	data, err := os.ReadFile(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(idx, []byte(strings.Replace(string(data), `"seq":7`, `"seq":70`, 1)), 0o644)
+	if n, err := NewEventStore(dir).Count(ctx, sessionID); err != nil || n != 70 {
+		t.Errorf("count from index = %d, %v; want 70", n, err)
+	}
+
+	// A corrupt index or a log shorter than the mark leads to a recount.
+	os.WriteFile(idx, []byte("{not json"), 0o644)
+	if n, err := NewEventStore(dir).Count(ctx, sessionID); err != nil || n != 7 {
+		t.Errorf("count with corrupt index = %d, %v; want 7", n, err)
+	}
+	os.WriteFile(idx, []byte(`{"seq":99,"offset":99999999}`), 0o644)
+	if n, err := NewEventStore(dir).Count(ctx, sessionID); err != nil || n != 7 {
+		t.Errorf("count with stale index = %d, %v; want 7", n, err)
+	}
+}
+
+func TestEventStoreSeqIndexAfterSealAndRepair(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessionID := types.NewSessionID()
+	store := NewEventStore(dir)
+	if err := store.SetCompression("gzip", 200); err != nil {
+		t.Fatal(err)
+	}
+
+	run := types.NewRunID()
+	for range 6 {
+		event := &types.Event{ID: types.NewEventID(), SessionID: sessionID, RunID: run, Type: "user_message", At: time.Now(),
+			Payload: json.RawMessage(`{"text":"padding padding padding padding padding padding"}`)}
+		if err := store.Append(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := NewEventStore(dir).Count(ctx, sessionID); err != nil || n != 6 {
+		t.Fatalf("count across segments = %d, %v; want 6", n, err)
+	}
+
+	// A call written without its mark, as a crash mid-append would leave.
+	dangling, _ := json.Marshal(&types.Event{ID: types.NewEventID(), SessionID: sessionID, RunID: run, Seq: 7, Type: "tool_call", At: time.Now(),
+		Payload: json.RawMessage(`{"tool":"bash","call_id":"c1"}`)})
+	if err := os.WriteFile(store.eventsPath(sessionID), append(dangling, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if repaired, err := store.Repair(ctx, sessionID); err != nil || repaired != 1 {
+		t.Fatalf("Repair = %d, %v", repaired, err)
+	}
+	event := &types.Event{ID: types.NewEventID(), SessionID: sessionID, Type: "user_message", At: time.Now(), Payload: json.RawMessage(`{}`)}
+	if err := store.Append(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Seq != 9 {
+		t.Errorf("seq after repair = %d, want 9", event.Seq)
+	}
+	if n, err := NewEventStore(dir).Count(ctx, sessionID); err != nil || n != 9 {
+		t.Errorf("count after repair = %d, %v; want 9", n, err)
+	}
+}
+
+func TestEventStoreSubscribe(t *testing.T) {
+	store := NewEventStore(t.TempDir())
+	ctx := context.Background()
+	sessionID, other := types.NewSessionID(), types.NewSessionID()
+	event := func(sid types.SessionID) *types.Event {
+		return &types.Event{ID: types.NewEventID(), SessionID: sid, Type: "user_message", At: time.Now(), Payload: json.RawMessage(`{}`)}
+	}
+
+	live, cancel := store.Subscribe(sessionID)
+	if err := store.AppendBatch(ctx, []*types.Event{event(sessionID), event(sessionID)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append(ctx, event(other)); err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if got := <-live; got.Seq != want || got.SessionID != sessionID {
+			t.Errorf("got %+v, want seq %d", got, want)
+		}
+	}
+	select {
+	case got := <-live:
+		t.Errorf("unexpected event %+v", got)
+	default:
+	}
+	cancel()
+	if _, ok := <-live; ok {
+		t.Error("expected the channel to close on cancel")
+	}
+	store.Append(ctx, event(sessionID)) // no subscriber left to block
+
+	// A subscriber that falls too far behind is dropped.
+	slow, cancel := store.Subscribe(sessionID)
+	defer cancel()
+	for range subscriberBuffer + 1 {
+		if err := store.Append(ctx, event(sessionID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d events before the close, want %d", n, subscriberBuffer)
+	}
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HeartbeatState is the heartbeat's memory between check-ins: today's
+// budget counters, what it last sent, and alerts waiting to be reviewed.
+type HeartbeatState struct {
+	// Day is the local date (YYYY-MM-DD) that Runs and Sent count.
+	Day  string `json:"day"`
+	Runs int    `json:"runs"`
+	Sent int    `json:"sent"`
+
+	LastRun     time.Time `json:"last_run,omitempty"`
+	LastOutcome string    `json:"last_outcome,omitempty"`
+	LastSent    time.Time `json:"last_sent,omitempty"`
+	// LastDigest is a hash of the last message sent, to suppress repeats.
+	LastDigest string `json:"last_digest,omitempty"`
+
+	// Flags are alerts raised since the last check-in that reviewed them.
+	Flags []HeartbeatFlag `json:"flags,omitempty"`
+}
+
+// HeartbeatFlag is an alert queued for the next check-in.
+type HeartbeatFlag struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
+}
+
+// HeartbeatStore is a JSON-file-backed store for the heartbeat state.
+type HeartbeatStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHeartbeatStore creates a new file-backed HeartbeatStore at the given
+// file path.
+func NewHeartbeatStore(path string) *HeartbeatStore {
+	return &HeartbeatStore{path: path}
+}
+
+// Load returns the stored state, or a zero state if the file doesn't exist.
+func (s *HeartbeatStore) Load() (*HeartbeatState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Update loads the state, applies fn and saves the result.
+func (s *HeartbeatStore) Update(fn func(*HeartbeatState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	fn(st)
+	return s.save(st)
+}
+
+// load reads the JSON file. Returns a zero state if the file doesn't exist.
+func (s *HeartbeatStore) load() (*HeartbeatState, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &HeartbeatState{}, nil
+		}
+		return nil, fmt.Errorf("read heartbeat file: %w", err)
+	}
+	var st HeartbeatState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("unmarshal heartbeat state: %w", err)
+	}
+	return &st, nil
+}
+
+// save writes the state using atomic write (temp file + rename).
+func (s *HeartbeatStore) save(st *HeartbeatState) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create heartbeat dir: %w", err)
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal heartbeat state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp heartbeat file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename heartbeat file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHeartbeatStore(t *testing.T) {
+	store := NewHeartbeatStore(filepath.Join(t.TempDir(), "heartbeat.json"))
+	st, err := store.Load()
+	if err != nil || st.Runs != 0 || len(st.Flags) != 0 {
+		t.Fatalf("expected zero state for a missing file, got %+v, %v", st, err)
+	}
+	if err := store.Update(func(st *HeartbeatState) {
+		st.Runs++
+		st.Flags = append(st.Flags, HeartbeatFlag{Message: "disk full"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	st, err = NewHeartbeatStore(store.path).Load()
+	if err != nil || st.Runs != 1 || len(st.Flags) != 1 || st.Flags[0].Message != "disk full" {
+		t.Fatalf("expected the update to persist, got %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,250 @@
+// internal/state/integrity.go
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// IntegrityReport lists what CheckIntegrity fixed and what it found but
+// left alone because repairing it could lose data.
+type IntegrityReport struct {
+	Repaired []string
+	Problems []string
+}
+
+func (r *IntegrityReport) repaired(format string, args ...any) {
+	r.Repaired = append(r.Repaired, fmt.Sprintf(format, args...))
+}
+
+func (r *IntegrityReport) problem(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// CheckIntegrity scans the data directory for damage left by crashes and
+// repairs what is safe to repair:
+//
+//   - orphaned *.tmp files from interrupted atomic writes are removed
+//   - a truncated final line in an events.jsonl is cut off
+//   - an events.jsonl left behind by a seal interrupted after writing
+//     its segment is removed
+//   - index entries without a session directory get an empty directory
+//   - session directories missing from the index are re-added as archived
+//
+// Unparseable lines in the middle of an event log, an events.jsonl that
+// otherwise overlaps the sealed segments, and artifacts referenced by
+// events but missing on disk are reported only. It must run before the
+// stores are used, since it edits files without taking their locks.
+func CheckIntegrity(root string) (*IntegrityReport, error) {
+	return checkIntegrity(root, true)
+}
+
+// VerifyIntegrity runs the checks of CheckIntegrity without repairing
+// anything, reporting what it would repair as problems, so it is safe while
+// the stores are in use. Temp files and a partial final event may belong
+// to a write in progress, so they are not reported.
+func VerifyIntegrity(root string) (*IntegrityReport, error) {
+	return checkIntegrity(root, false)
+}
+
+func checkIntegrity(root string, repair bool) (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+	sessionsDir := filepath.Join(root, "sessions")
+	if _, err := os.Stat(sessionsDir); os.IsNotExist(err) {
+		return report, nil
+	}
+
+	// 1. Orphaned temp files
+	if repair {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".tmp") {
+				if err := os.Remove(path); err != nil {
+					return fmt.Errorf("remove temp file: %w", err)
+				}
+				report.repaired("removed orphaned temp file %s", path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scan data dir: %w", err)
+		}
+	}
+
+	// 2. Index entries vs. session directories
+	store := NewSessionStore(root)
+	store.mu.Lock()
+	index, err := store.loadIndex()
+	if err != nil {
+		store.mu.Unlock()
+		return nil, err
+	}
+	indexed := make(map[types.SessionID]bool, len(index))
+	for _, sess := range index {
+		indexed[sess.SessionID] = true
+		dir := store.sessionDir(sess.SessionID)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if !repair {
+				report.problem("session %s has no directory", sess.SessionID)
+				continue
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				store.mu.Unlock()
+				return nil, fmt.Errorf("create session dir: %w", err)
+			}
+			report.repaired("created missing directory for session %s", sess.SessionID)
+		}
+	}
+
+	entries, err := os.ReadDir(sessionsDir)
+	if err != nil {
+		store.mu.Unlock()
+		return nil, fmt.Errorf("read sessions dir: %w", err)
+	}
+	recovered := 0
+	for _, entry := range entries {
+		id := types.SessionID(entry.Name())
+		if !entry.IsDir() || indexed[id] {
+			continue
+		}
+		if !repair {
+			report.problem("session directory %s is missing from the index", id)
+			continue
+		}
+		created := time.Now()
+		if info, err := entry.Info(); err == nil {
+			created = info.ModTime()
+		}
+		key := types.SessionKey("archived:" + string(id))
+		index[key] = &types.SessionIndex{
+			SessionID:  id,
+			SessionKey: key,
+			Agent:      "default",
+			Status:     "archived",
+			CreatedAt:  created,
+			UpdatedAt:  time.Now(),
+		}
+		indexed[id] = true
+		recovered++
+		report.repaired("re-indexed orphaned session directory %s as archived", id)
+	}
+	if recovered > 0 {
+		if err := store.saveIndex(index); err != nil {
+			store.mu.Unlock()
+			return nil, err
+		}
+	}
+	store.mu.Unlock()
+
+	// 3. Event logs: truncated tails, corrupt lines, missing artifacts
+	for id := range indexed {
+		if err := checkEventLog(root, id, repair, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// checkEventLog repairs a truncated final line in a session's event log and
+// reports corrupt lines and dangling artifact references.
+func checkEventLog(root string, id types.SessionID, repair bool, report *IntegrityReport) error {
+	path := filepath.Join(root, "sessions", string(id), "events.jsonl")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read events file: %w", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Sealing writes the segment before removing events.jsonl, so a crash
+	// in between leaves the newest segment's events in both places. Any
+	// other overlap has no known cause, so it is left for a person to sort
+	// out rather than guessed at.
+	segments, err := listSegments(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if len(segments) > 0 {
+		newest := segments[len(segments)-1]
+		var first types.Event
+		line, _, _ := bytes.Cut(data, []byte{'\n'})
+		if json.Unmarshal(line, &first) == nil && first.Seq <= newest.last {
+			if !repair || first.Seq != newest.first {
+				report.problem("session %s: events.jsonl starts at seq %d, which is already sealed (segments end at %d)", id, first.Seq, newest.last)
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("remove sealed events file: %w", err)
+			}
+			report.repaired("removed events file already sealed into a segment in session %s", id)
+			return nil
+		}
+	}
+
+	// A crash mid-append leaves a final line without its newline. Cut it
+	// back to the last complete line.
+	if data[len(data)-1] != '\n' {
+		keep := bytes.LastIndexByte(data, '\n') + 1
+		if repair {
+			if err := os.Truncate(path, int64(keep)); err != nil {
+				return fmt.Errorf("truncate events file: %w", err)
+			}
+			report.repaired("truncated partial final event in session %s", id)
+		}
+		data = data[:keep]
+	}
+
+	for i, line := range bytes.Split(bytes.TrimRight(data, "\n"), []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var event types.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			report.problem("session %s: event line %d is not valid JSON", id, i+1)
+			continue
+		}
+		for _, ref := range artifactRefs(event.Payload) {
+			pattern := filepath.Join(root, "sessions", "*", "artifacts", string(ref)+".json")
+			if matches, _ := filepath.Glob(pattern); len(matches) == 0 {
+				report.problem("session %s: event %d references missing artifact %s", id, event.Seq, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// artifactRefs extracts artifact IDs referenced by an event payload, either
+// directly (tool results) or through attachments (user messages).
+func artifactRefs(payload json.RawMessage) []types.ArtifactID {
+	var p struct {
+		ArtifactID  types.ArtifactID   `json:"artifact_id"`
+		Attachments []types.Attachment `json:"attachments"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil
+	}
+	var refs []types.ArtifactID
+	if p.ArtifactID != "" {
+		refs = append(refs, p.ArtifactID)
+	}
+	for _, att := range p.Attachments {
+		if att.ArtifactID != "" {
+			refs = append(refs, att.ArtifactID)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,239 @@
+// internal/state/integrity_test.go
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := NewSessionStore(dir)
+	events := NewEventStore(dir)
+
+	// A healthy session with a truncated final event and a dangling artifact ref
+	sid, err := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(map[string]string{"tool": "bash", "call_id": "c1", "result": "x", "artifact_id": "art_missing"})
+	if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, Type: "tool_result", At: time.Now(), Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "sessions", string(sid), "events.jsonl")
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"evt_partial","session_id":`)
+	f.Close()
+
+	// An index entry whose directory is gone
+	gone, err := sessions.ResolveOrCreate(ctx, "test:gone", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "sessions", string(gone))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A session directory missing from the index, and leftover temp files
+	orphan := types.NewSessionID()
+	if err := os.MkdirAll(filepath.Join(dir, "sessions", string(orphan)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "sessions", "sessions.json.tmp"), []byte("{"), 0o644)
+	os.WriteFile(logPath+".tmp", []byte("{"), 0o644)
+
+	report, err := CheckIntegrity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repaired) != 5 {
+		t.Errorf("expected 5 repairs, got %d: %v", len(report.Repaired), report.Repaired)
+	}
+	if len(report.Problems) != 1 || !strings.Contains(report.Problems[0], "art_missing") {
+		t.Errorf("expected missing artifact problem, got %v", report.Problems)
+	}
+
+	// Partial tail was trimmed; the complete event remains readable
+	n, err := events.Count(ctx, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 event after truncation, got %d", n)
+	}
+	if _, err := events.Tail(ctx, sid, 10); err != nil {
+		t.Errorf("expected readable log after repair, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "sessions", string(gone))); err != nil {
+		t.Errorf("expected recreated session dir: %v", err)
+	}
+	if _, err := os.Stat(logPath + ".tmp"); !os.IsNotExist(err) {
+		t.Error("expected temp file removed")
+	}
+
+	recovered, err := NewSessionStore(dir).Get(ctx, orphan)
+	if err != nil {
+		t.Fatalf("expected orphan re-indexed: %v", err)
+	}
+	if recovered.Status != "archived" {
+		t.Errorf("expected orphan archived, got %s", recovered.Status)
+	}
+
+	// A second pass finds nothing new to repair
+	report, err = CheckIntegrity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repaired) != 0 {
+		t.Errorf("expected idempotent check, got %v", report.Repaired)
+	}
+}
+
+func TestCheckIntegrityInterruptedSeal(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := NewSessionStore(dir)
+	events := NewEventStore(dir)
+	if err := events.SetCompression("gzip", 1); err != nil {
+		t.Fatal(err)
+	}
+	sid, err := sessions.ResolveOrCreate(ctx, "test:seal", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, Type: "user_message", At: time.Now(), Payload: json.RawMessage(`{"text":"hi"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate a crash after the second segment was written but before
+	// events.jsonl was removed.
+	segments, err := listSegments(filepath.Join(dir, "sessions", string(sid)))
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %+v, %v", segments, err)
+	}
+	lines, err := segments[1].lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "sessions", string(sid), "events.jsonl")
+	if err := os.WriteFile(logPath, append(lines[0], '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := CheckIntegrity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repaired) != 1 || !strings.Contains(report.Repaired[0], "already sealed") {
+		t.Fatalf("expected the duplicate events file removed, got %+v", report)
+	}
+	if count, err := NewEventStore(dir).Count(ctx, sid); err != nil || count != 2 {
+		t.Errorf("expected count 2 after repair, got %d, %v", count, err)
+	}
+}
+
+func TestCheckIntegrityKeepsOverlappingSegments(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := NewSessionStore(dir)
+	events := NewEventStore(dir)
+	if err := events.SetCompression("gzip", 1); err != nil {
+		t.Fatal(err)
+	}
+	sid, err := sessions.ResolveOrCreate(ctx, "test:overlap", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, Type: "user_message", At: time.Now(), Payload: json.RawMessage(`{"text":"hi"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An events.jsonl that starts over at seq 1, as a restore or manual
+	// copy might leave, is not a known crash state.
+	sessionDir := filepath.Join(dir, "sessions", string(sid))
+	segments, err := listSegments(sessionDir)
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %+v, %v", segments, err)
+	}
+	lines, err := segments[0].lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionDir, "events.jsonl"), append(lines[0], '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := CheckIntegrity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repaired) != 0 || len(report.Problems) != 1 || !strings.Contains(report.Problems[0], "already sealed") {
+		t.Fatalf("expected the overlap reported, got %+v", report)
+	}
+	if after, _ := listSegments(sessionDir); len(after) != 2 {
+		t.Errorf("expected sealed segments kept, got %+v", after)
+	}
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	sessions := NewSessionStore(dir)
+
+	// An index entry whose directory is gone, a session directory missing
+	// from the index, a partial final event and a temp file, any of which
+	// a write in progress could explain except the first two.
+	gone, err := sessions.ResolveOrCreate(ctx, "test:gone", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Join(dir, "sessions", string(gone)))
+	orphan := types.NewSessionID()
+	os.MkdirAll(filepath.Join(dir, "sessions", string(orphan)), 0o755)
+	sid, err := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "sessions", string(sid), "events.jsonl")
+	os.WriteFile(logPath, []byte(`{"id":"evt_partial"`), 0o644)
+	tmp := filepath.Join(dir, "sessions", "sessions.json.tmp")
+	os.WriteFile(tmp, []byte("{"), 0o644)
+
+	report, err := VerifyIntegrity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repaired) != 0 || len(report.Problems) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// Nothing was touched.
+	if _, err := os.Stat(filepath.Join(dir, "sessions", string(gone))); !os.IsNotExist(err) {
+		t.Error("expected the missing directory to stay missing")
+	}
+	if list, _ := sessions.List(ctx); len(list) != 2 {
+		t.Errorf("expected the index to be unchanged, got %d sessions", len(list))
+	}
+	if data, _ := os.ReadFile(logPath); string(data) != `{"id":"evt_partial"` {
+		t.Errorf("expected the event log to be unchanged, got %q", data)
+	}
+	if _, err := os.Stat(tmp); err != nil {
+		t.Error("expected the temp file to be kept")
+	}
+}
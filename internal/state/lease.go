@@ -0,0 +1,145 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LeaseInfo is the leadership claim stored in the lease file.
+type LeaseInfo struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lease is a time-limited leadership claim in a file shared by every
+// instance that points at the same data directory. The holder must renew it
+// within the TTL or another instance may take it over.
+type Lease struct {
+	path   string
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewLease creates a lease on the file at path, claimed as holder.
+func NewLease(path, holder string, ttl time.Duration) *Lease {
+	return &Lease{path: path, holder: holder, ttl: ttl, now: time.Now}
+}
+
+// Holder returns the name this lease claims leadership under.
+func (l *Lease) Holder() string {
+	return l.holder
+}
+
+// Acquire takes the lease if it is free or expired, or renews it if this
+// holder already has it. It reports whether the caller holds the lease.
+func (l *Lease) Acquire() (bool, error) {
+	unlock, err := l.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	now := l.now()
+	cur, err := l.read()
+	if err != nil {
+		return false, err
+	}
+	if cur != nil && cur.Holder != l.holder && now.Before(cur.Expires) {
+		return false, nil
+	}
+	if err := l.write(&LeaseInfo{Holder: l.holder, Expires: now.Add(l.ttl)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release gives the lease up if this holder has it, so another instance can
+// take over without waiting for it to expire.
+func (l *Lease) Release() error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := l.read()
+	if err != nil || cur == nil || cur.Holder != l.holder {
+		return err
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove lease file: %w", err)
+	}
+	return nil
+}
+
+// Current returns the stored claim, or nil if nobody has claimed the lease.
+func (l *Lease) Current() (*LeaseInfo, error) {
+	return l.read()
+}
+
+// lock serializes lease updates across processes with an exclusively
+// created lock file. A lock older than the TTL is left over from a crashed
+// process and is broken.
+func (l *Lease) lock() (func(), error) {
+	lockPath := l.path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+		return nil, fmt.Errorf("create lease dir: %w", err)
+	}
+	deadline := l.now().Add(l.ttl)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lease lock: %w", err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && l.now().Sub(info.ModTime()) > l.ttl {
+			os.Remove(lockPath)
+			continue
+		}
+		if l.now().After(deadline) {
+			return nil, fmt.Errorf("lease lock %s is held", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// read loads the lease file. Returns nil if it doesn't exist.
+func (l *Lease) read() (*LeaseInfo, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read lease file: %w", err)
+	}
+	var info LeaseInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("unmarshal lease: %w", err)
+	}
+	return &info, nil
+}
+
+// write saves the lease file using atomic write (temp file + rename).
+func (l *Lease) write(info *LeaseInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal lease: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp lease file: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp lease file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.json")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	a := NewLease(path, "a", 15*time.Second)
+	b := NewLease(path, "b", 15*time.Second)
+	a.now, b.now = clock, clock
+
+	if ok, err := a.Acquire(); err != nil || !ok {
+		t.Fatalf("expected a to acquire a free lease, got %v, %v", ok, err)
+	}
+	if ok, err := b.Acquire(); err != nil || ok {
+		t.Fatalf("expected b to be refused while a holds the lease, got %v, %v", ok, err)
+	}
+
+	// a renews; b still can't take it just before the old expiry.
+	now = now.Add(10 * time.Second)
+	if ok, _ := a.Acquire(); !ok {
+		t.Fatal("expected a to renew its lease")
+	}
+	now = now.Add(10 * time.Second)
+	if ok, _ := b.Acquire(); ok {
+		t.Fatal("expected renewal to extend the lease")
+	}
+
+	// a stops renewing; b takes over after the TTL.
+	now = now.Add(10 * time.Second)
+	if ok, _ := b.Acquire(); !ok {
+		t.Fatal("expected b to take over an expired lease")
+	}
+	if ok, _ := a.Acquire(); ok {
+		t.Fatal("expected a to have lost the lease")
+	}
+	cur, err := a.Current()
+	if err != nil || cur.Holder != "b" {
+		t.Fatalf("expected b as current holder, got %+v, %v", cur, err)
+	}
+
+	// Release by a non-holder is a no-op; by the holder frees the lease.
+	if err := a.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := a.Acquire(); ok {
+		t.Fatal("release by a non-holder should not free the lease")
+	}
+	if err := b.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := a.Acquire(); !ok {
+		t.Fatal("expected a to acquire a released lease")
+	}
+}
@@ -0,0 +1,233 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+// Macro is a named prompt skeleton. Its Template is a text/template whose
+// data is the invocation's key=value arguments, plus "text" for any words
+// that aren't key=value pairs.
+type Macro struct {
+	Name        string `json:"name"`
+	Template    string `json:"template"`
+	Description string `json:"description,omitempty"`
+	// Defaults supply values for arguments the invocation leaves out.
+	Defaults map[string]string `json:"defaults,omitempty"`
+}
+
+// Parse checks that the macro's template is valid.
+func (m *Macro) Parse() (*template.Template, error) {
+	tmpl, err := template.New(m.Name).Option("missingkey=error").Parse(m.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parse macro template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render expands the macro with the given arguments layered over its
+// defaults. An argument the template uses but nobody supplied is an error.
+func (m *Macro) Render(args map[string]string) (string, error) {
+	tmpl, err := m.Parse()
+	if err != nil {
+		return "", err
+	}
+	data := make(map[string]string, len(m.Defaults)+len(args))
+	for k, v := range m.Defaults {
+		data[k] = v
+	}
+	for k, v := range args {
+		data[k] = v
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("expand macro %s: %w", m.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// Expand renders the macro from an argument line such as
+// `week=12 team="platform ops" focus on incidents`.
+func (m *Macro) Expand(line string) (string, error) {
+	return m.Render(ParseMacroArgs(line))
+}
+
+// ParseMacroArgs splits an argument line into key=value pairs. Values may
+// be double-quoted to include spaces. Words that aren't pairs are joined
+// under the "text" key.
+func ParseMacroArgs(line string) map[string]string {
+	args := make(map[string]string)
+	var text []string
+	for _, word := range splitArgs(line) {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || key == "" || strings.ContainsFunc(key, unicode.IsSpace) {
+			text = append(text, word)
+			continue
+		}
+		args[key] = value
+	}
+	if len(text) > 0 {
+		args["text"] = strings.Join(text, " ")
+	}
+	return args
+}
+
+// splitArgs splits on whitespace outside double quotes, dropping the quotes.
+func splitArgs(line string) []string {
+	var words []string
+	var cur strings.Builder
+	quoted, inWord := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
+
+// MacroStore is a JSON-file-backed store for macros.
+type MacroStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+// NewMacroStore creates a new file-backed MacroStore at the given file path.
+func NewMacroStore(path string) *MacroStore {
+	return &MacroStore{path: path}
+}
+
+// List returns all macros sorted by name. Returns an empty slice if the
+// file doesn't exist.
+func (s *MacroStore) List() ([]*Macro, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	macros, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(macros, func(i, j int) bool { return macros[i].Name < macros[j].Name })
+	if macros == nil {
+		return []*Macro{}, nil
+	}
+	return macros, nil
+}
+
+// Get finds a macro by name. Returns an error if not found.
+func (s *MacroStore) Get(name string) (*Macro, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	macros, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range macros {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("macro not found: %s", name)
+}
+
+// Put adds a macro, replacing any existing macro with the same name. The
+// template must parse.
+func (s *MacroStore) Put(macro *Macro) error {
+	if macro.Name == "" {
+		return fmt.Errorf("macro name is required")
+	}
+	if _, err := macro.Parse(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	macros, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, existing := range macros {
+		if existing.Name == macro.Name {
+			macros[i] = macro
+			return s.save(macros)
+		}
+	}
+	return s.save(append(macros, macro))
+}
+
+// Remove deletes a macro by name. Returns an error if not found.
+func (s *MacroStore) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	macros, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, m := range macros {
+		if m.Name == name {
+			return s.save(append(macros[:i], macros[i+1:]...))
+		}
+	}
+	return fmt.Errorf("macro not found: %s", name)
+}
+
+// load reads the JSON file and returns the macro list. Returns nil if the file doesn't exist.
+func (s *MacroStore) load() ([]*Macro, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read macros file: %w", err)
+	}
+
+	var macros []*Macro
+	if err := json.Unmarshal(data, &macros); err != nil {
+		return nil, fmt.Errorf("unmarshal macros: %w", err)
+	}
+	return macros, nil
+}
+
+// save writes the macro list to disk using atomic write (temp file + rename).
+func (s *MacroStore) save(macros []*Macro) error {
+	data, err := json.MarshalIndent(macros, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal macros: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create macros dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp macros file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp macros file: %w", err)
+	}
+	return nil
+}
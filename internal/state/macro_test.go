@@ -0,0 +1,80 @@
+package state
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMacroArgs(t *testing.T) {
+	got := ParseMacroArgs(`week=12 team="platform ops" focus on incidents`)
+	want := map[string]string{"week": "12", "team": "platform ops", "text": "focus on incidents"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMacroArgs = %v, want %v", got, want)
+	}
+	if got := ParseMacroArgs(""); len(got) != 0 {
+		t.Errorf("expected no args, got %v", got)
+	}
+}
+
+func TestMacroExpand(t *testing.T) {
+	m := &Macro{
+		Name:     "report",
+		Template: "Weekly report for week {{.week}} ({{.team}}). {{.text}}",
+		Defaults: map[string]string{"team": "ops", "text": ""},
+	}
+	got, err := m.Expand("week=12 keep it short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Weekly report for week 12 (ops). keep it short" {
+		t.Errorf("unexpected expansion %q", got)
+	}
+
+	if _, err := m.Expand("team=dev"); err == nil || !strings.Contains(err.Error(), "week") {
+		t.Errorf("expected error naming the missing argument, got %v", err)
+	}
+}
+
+func TestMacroStore(t *testing.T) {
+	store := NewMacroStore(filepath.Join(t.TempDir(), "macros.json"))
+
+	if err := store.Put(&Macro{Name: "bad", Template: "{{.oops"}); err == nil {
+		t.Error("expected invalid template to be rejected")
+	}
+	if err := store.Put(&Macro{Name: "standup", Template: "Standup notes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(&Macro{Name: "report", Template: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(&Macro{Name: "report", Template: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	macros, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(macros) != 2 || macros[0].Name != "report" || macros[1].Name != "standup" {
+		t.Fatalf("expected report and standup sorted, got %+v", macros)
+	}
+	m, err := store.Get("report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Template != "v2" {
+		t.Errorf("expected Put to replace, got %q", m.Template)
+	}
+
+	if err := store.Remove("report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("report"); err == nil {
+		t.Error("expected removed macro to be gone")
+	}
+	if err := store.Remove("report"); err == nil {
+		t.Error("expected error removing a missing macro")
+	}
+}
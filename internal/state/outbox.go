@@ -0,0 +1,141 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OutboxEntry is a message whose delivery failed and is waiting to be
+// retried.
+type OutboxEntry struct {
+	ID         string `json:"id"`
+	SessionKey string `json:"session_key"`
+	Message    string `json:"message"`
+	// Source says what produced the message, e.g. "task:briefing".
+	Source      string    `json:"source,omitempty"`
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// OutboxStore persists undelivered messages as a JSON file so they survive
+// a restart.
+type OutboxStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewOutboxStore creates an OutboxStore that reads and writes the given file path.
+func NewOutboxStore(path string) *OutboxStore {
+	return &OutboxStore{path: path}
+}
+
+// Add queues an entry. Its ID must be set and unique.
+func (s *OutboxStore) Add(entry *OutboxEntry) error {
+	if entry.ID == "" {
+		return fmt.Errorf("outbox entry ID is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	return s.save(append(entries, entry))
+}
+
+// List returns all queued entries, oldest first. Returns an empty slice if
+// the file doesn't exist.
+func (s *OutboxStore) List() ([]*OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	if entries == nil {
+		return []*OutboxEntry{}, nil
+	}
+	return entries, nil
+}
+
+// Update replaces the entry with the same ID. Returns an error if not found.
+func (s *OutboxStore) Update(entry *OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if e.ID == entry.ID {
+			entries[i] = entry
+			return s.save(entries)
+		}
+	}
+	return fmt.Errorf("outbox entry not found: %s", entry.ID)
+}
+
+// Remove deletes an entry by ID. Removing a missing entry is not an error.
+func (s *OutboxStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if e.ID == id {
+			return s.save(append(entries[:i], entries[i+1:]...))
+		}
+	}
+	return nil
+}
+
+// load reads the JSON file and returns the entries. Returns nil if the file doesn't exist.
+func (s *OutboxStore) load() ([]*OutboxEntry, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read outbox file: %w", err)
+	}
+
+	var entries []*OutboxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unmarshal outbox: %w", err)
+	}
+	return entries, nil
+}
+
+// save writes the entries to disk using atomic write (temp file + rename).
+func (s *OutboxStore) save(entries []*OutboxEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal outbox: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create outbox dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp outbox file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp outbox file: %w", err)
+	}
+	return nil
+}
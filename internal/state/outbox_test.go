@@ -0,0 +1,44 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxStore(t *testing.T) {
+	store := NewOutboxStore(filepath.Join(t.TempDir(), "outbox.json"))
+	now := time.Now()
+	if err := store.Add(&OutboxEntry{ID: "b", SessionKey: "telegram:1", Message: "second", Created: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(&OutboxEntry{ID: "a", SessionKey: "telegram:1", Message: "first", Created: now.Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(&OutboxEntry{Message: "no id"}); err == nil {
+		t.Error("expected entry without ID to fail")
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != "a" {
+		t.Fatalf("List = %+v, want oldest first", entries)
+	}
+
+	entries[0].Attempts = 3
+	if err := store.Update(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Update(&OutboxEntry{ID: "missing"}); err == nil {
+		t.Error("expected updating a missing entry to fail")
+	}
+	if err := store.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = store.List()
+	if len(entries) != 1 || entries[0].Attempts != 3 {
+		t.Errorf("after update and remove: %+v", entries)
+	}
+}
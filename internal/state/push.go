@@ -0,0 +1,148 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PushSubscription is a browser that asked the web UI for push
+// notifications. Name groups a person's browsers: deliveries to the session
+// key "webpush:<name>" go to all of them.
+type PushSubscription struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+	// P256dh and Auth are the browser's encryption key and auth secret,
+	// base64url, as PushSubscription.toJSON() returns them.
+	P256dh    string    `json:"p256dh"`
+	Auth      string    `json:"auth"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PushStore is a JSON-file-backed store for push subscriptions, keyed by
+// endpoint.
+type PushStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+// NewPushStore creates a new file-backed PushStore at the given file path.
+func NewPushStore(path string) *PushStore {
+	return &PushStore{path: path}
+}
+
+// List returns all subscriptions, oldest first. Returns an empty slice if
+// the file doesn't exist.
+func (s *PushStore) List() ([]*PushSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if subs == nil {
+		return []*PushSubscription{}, nil
+	}
+	return subs, nil
+}
+
+// ForName returns the subscriptions registered under name.
+func (s *PushStore) ForName(name string) ([]*PushSubscription, error) {
+	subs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matched []*PushSubscription
+	for _, sub := range subs {
+		if sub.Name == name {
+			matched = append(matched, sub)
+		}
+	}
+	return matched, nil
+}
+
+// Put adds a subscription, replacing any existing one with the same
+// endpoint, such as a browser re-subscribing under another name.
+func (s *PushStore) Put(sub *PushSubscription) error {
+	if sub.Name == "" || sub.Endpoint == "" || sub.P256dh == "" || sub.Auth == "" {
+		return fmt.Errorf("push subscription needs a name, endpoint, p256dh and auth")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, existing := range subs {
+		if existing.Endpoint == sub.Endpoint {
+			subs[i] = sub
+			return s.save(subs)
+		}
+	}
+	return s.save(append(subs, sub))
+}
+
+// Remove deletes the subscription with the given endpoint. It reports
+// whether there was one.
+func (s *PushStore) Remove(endpoint string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	for i, sub := range subs {
+		if sub.Endpoint == endpoint {
+			return true, s.save(append(subs[:i], subs[i+1:]...))
+		}
+	}
+	return false, nil
+}
+
+// load reads the JSON file and returns the subscriptions. Returns nil if
+// the file doesn't exist.
+func (s *PushStore) load() ([]*PushSubscription, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read push subscriptions file: %w", err)
+	}
+
+	var subs []*PushSubscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("unmarshal push subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// save writes the subscriptions to disk using atomic write (temp file +
+// rename). The file holds each browser's auth secret, so it is private.
+func (s *PushStore) save(subs []*PushSubscription) error {
+	data, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal push subscriptions: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create push subscriptions dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write temp push subscriptions file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp push subscriptions file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPushStore(t *testing.T) {
+	store := NewPushStore(filepath.Join(t.TempDir(), "push.json"))
+
+	if subs, err := store.List(); err != nil || len(subs) != 0 {
+		t.Fatalf("List on a missing file = %v, %v", subs, err)
+	}
+	if err := store.Put(&PushSubscription{Name: "alice", Endpoint: "https://push.example/1"}); err == nil {
+		t.Error("expected a subscription without keys to be rejected")
+	}
+
+	for _, sub := range []*PushSubscription{
+		{Name: "alice", Endpoint: "https://push.example/1", P256dh: "k1", Auth: "a1"},
+		{Name: "bob", Endpoint: "https://push.example/2", P256dh: "k2", Auth: "a2"},
+		{Name: "bob", Endpoint: "https://push.example/1", P256dh: "k3", Auth: "a3"},
+	} {
+		if err := store.Put(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bob, err := store.ForName("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bob) != 2 || bob[0].P256dh != "k3" {
+		t.Errorf("expected the re-subscribed endpoint to move to bob, got %+v", bob)
+	}
+	if alice, _ := store.ForName("alice"); len(alice) != 0 {
+		t.Errorf("expected alice to have no subscriptions left, got %+v", alice)
+	}
+
+	if ok, err := store.Remove("https://push.example/2"); err != nil || !ok {
+		t.Fatalf("Remove = %v, %v", ok, err)
+	}
+	if ok, _ := store.Remove("https://push.example/2"); ok {
+		t.Error("expected removing twice to report nothing removed")
+	}
+	if subs, _ := store.List(); len(subs) != 1 {
+		t.Errorf("expected one subscription left, got %d", len(subs))
+	}
+}
@@ -0,0 +1,227 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reminder is a message the bot sends to a conversation at a set time,
+// once or on a repeat rule (see package reminder).
+type Reminder struct {
+	ID         string    `json:"id"`
+	SessionKey string    `json:"session_key"`
+	Text       string    `json:"text"`
+	Due        time.Time `json:"due"`
+	Repeat     string    `json:"repeat,omitempty"`
+	Created    time.Time `json:"created"`
+	// FiredAt is set on a one-off reminder once it has fired. It is kept
+	// for a while so its snooze buttons still work.
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+}
+
+// ReminderStore manages reminders persisted as a JSON file.
+type ReminderStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewReminderStore creates a ReminderStore that reads and writes the given file path.
+func NewReminderStore(path string) *ReminderStore {
+	return &ReminderStore{path: path}
+}
+
+// Add stores a new reminder, assigning its ID and creation time.
+func (s *ReminderStore) Add(r *Reminder) error {
+	if r.SessionKey == "" || r.Text == "" {
+		return fmt.Errorf("reminder needs a session key and text")
+	}
+	raw := make([]byte, 4)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("generate reminder ID: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return err
+	}
+	r.ID = hex.EncodeToString(raw)
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	return s.save(append(reminders, r))
+}
+
+// List returns the pending reminders for a session key, or for every
+// session if sessionKey is empty, soonest first. Fired one-off reminders
+// are left out.
+func (s *ReminderStore) List(sessionKey string) ([]*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	out := []*Reminder{}
+	for _, r := range reminders {
+		if r.FiredAt == nil && (sessionKey == "" || r.SessionKey == sessionKey) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	return out, nil
+}
+
+// Get finds a reminder by ID, including a fired one. Returns an error if
+// not found.
+func (s *ReminderStore) Get(id string) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reminders {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("reminder not found: %s", id)
+}
+
+// Due returns the pending reminders due at or before now, soonest first.
+func (s *ReminderStore) Due(now time.Time) ([]*Reminder, error) {
+	all, err := s.List("")
+	if err != nil {
+		return nil, err
+	}
+	var due []*Reminder
+	for _, r := range all {
+		if !r.Due.After(now) {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+// Update replaces the reminder with the same ID. Returns an error if not
+// found.
+func (s *ReminderStore) Update(r *Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, existing := range reminders {
+		if existing.ID == r.ID {
+			reminders[i] = r
+			return s.save(reminders)
+		}
+	}
+	return fmt.Errorf("reminder not found: %s", r.ID)
+}
+
+// Remove deletes a reminder by ID. Returns an error if not found.
+func (s *ReminderStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, r := range reminders {
+		if r.ID == id {
+			return s.save(append(reminders[:i], reminders[i+1:]...))
+		}
+	}
+	return fmt.Errorf("reminder not found: %s", id)
+}
+
+// Snooze makes a reminder fire again at until. A fired one-off reminder
+// is rescheduled; a recurring one keeps its schedule and gets a one-off
+// copy, which is returned.
+func (s *ReminderStore) Snooze(id string, until time.Time) (*Reminder, error) {
+	r, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Repeat == "" {
+		r.Due = until
+		r.FiredAt = nil
+		return r, s.Update(r)
+	}
+	snoozed := &Reminder{SessionKey: r.SessionKey, Text: r.Text, Due: until}
+	return snoozed, s.Add(snoozed)
+}
+
+// PruneFired removes one-off reminders that fired before cutoff.
+func (s *ReminderStore) PruneFired(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := reminders[:0]
+	for _, r := range reminders {
+		if r.FiredAt == nil || r.FiredAt.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(reminders) {
+		return nil
+	}
+	return s.save(kept)
+}
+
+// load reads the JSON file and returns the reminders. Returns nil if the file doesn't exist.
+func (s *ReminderStore) load() ([]*Reminder, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read reminders file: %w", err)
+	}
+
+	var reminders []*Reminder
+	if err := json.Unmarshal(data, &reminders); err != nil {
+		return nil, fmt.Errorf("unmarshal reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// save writes the reminders to disk using atomic write (temp file + rename).
+func (s *ReminderStore) save(reminders []*Reminder) error {
+	data, err := json.MarshalIndent(reminders, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal reminders: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create reminders dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp reminders file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp reminders file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReminderStore(t *testing.T) {
+	store := NewReminderStore(filepath.Join(t.TempDir(), "reminders.json"))
+	now := time.Now()
+
+	once := &Reminder{SessionKey: "telegram:1:1", Text: "call mum", Due: now.Add(time.Hour)}
+	daily := &Reminder{SessionKey: "telegram:1:1", Text: "stand-up", Due: now.Add(-time.Minute), Repeat: "daily"}
+	other := &Reminder{SessionKey: "telegram:2:2", Text: "water plants", Due: now.Add(2 * time.Hour)}
+	for _, r := range []*Reminder{once, daily, other} {
+		if err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if once.ID == "" || once.ID == daily.ID {
+		t.Fatalf("expected distinct IDs, got %q and %q", once.ID, daily.ID)
+	}
+	if err := store.Add(&Reminder{SessionKey: "telegram:1:1"}); err == nil {
+		t.Error("expected a reminder without text to fail")
+	}
+
+	mine, err := store.List("telegram:1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 2 || mine[0].ID != daily.ID {
+		t.Errorf("List = %+v, want stand-up then call mum", mine)
+	}
+	due, err := store.Due(now)
+	if err != nil || len(due) != 1 || due[0].ID != daily.ID {
+		t.Errorf("Due = %+v, %v", due, err)
+	}
+
+	// A fired one-off leaves the list but can still be snoozed.
+	fired := now
+	once.FiredAt = &fired
+	if err := store.Update(once); err != nil {
+		t.Fatal(err)
+	}
+	if mine, _ := store.List("telegram:1:1"); len(mine) != 1 {
+		t.Errorf("fired reminder still listed: %+v", mine)
+	}
+	snoozed, err := store.Snooze(once.ID, now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snoozed.ID != once.ID || snoozed.FiredAt != nil {
+		t.Errorf("snoozed one-off = %+v", snoozed)
+	}
+
+	// Snoozing a recurring reminder adds a one-off copy.
+	copied, err := store.Snooze(daily.ID, now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.ID == daily.ID || copied.Repeat != "" || copied.Text != "stand-up" {
+		t.Errorf("snoozed recurring = %+v", copied)
+	}
+	if mine, _ := store.List("telegram:1:1"); len(mine) != 3 {
+		t.Errorf("expected 3 pending reminders, got %+v", mine)
+	}
+
+	old := now.Add(-48 * time.Hour)
+	other.FiredAt = &old
+	store.Update(other)
+	if err := store.PruneFired(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(other.ID); err == nil {
+		t.Error("expected the old fired reminder to be pruned")
+	}
+	if err := store.Remove(daily.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove(daily.ID); err == nil {
+		t.Error("expected removing a missing reminder to fail")
+	}
+}
@@ -0,0 +1,203 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// RunFilter narrows RunStore.List. Zero fields match everything.
+type RunFilter struct {
+	SessionID types.SessionID
+	Status    string
+	// Limit caps the number of records returned, newest first.
+	Limit int
+}
+
+// RunStore persists run records as a JSON Lines file. Every change appends
+// the run's whole record, and the last line for an ID wins, so writes never
+// rewrite the file and instances sharing a data directory can append side
+// by side.
+type RunStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewRunStore creates a RunStore that reads and appends to the given file path.
+func NewRunStore(path string) *RunStore {
+	return &RunStore{path: path}
+}
+
+// Put records the current state of a run.
+func (s *RunStore) Put(rec *types.RunRecord) error {
+	if rec.ID == "" {
+		return fmt.Errorf("run ID is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append([]*types.RunRecord{rec})
+}
+
+// Get returns the latest record for a run. Returns an error if not found.
+func (s *RunStore) Get(id types.RunID) (*types.RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return nil, fmt.Errorf("run not found: %s", id)
+}
+
+// List returns the latest record of each run matching the filter, newest
+// first. Returns an empty slice if the file doesn't exist.
+func (s *RunStore) List(filter RunFilter) ([]*types.RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	out := []*types.RunRecord{}
+	for _, rec := range records {
+		if filter.SessionID != "" && rec.SessionID != filter.SessionID {
+			continue
+		}
+		if filter.Status != "" && rec.Status != filter.Status {
+			continue
+		}
+		out = append(out, rec)
+		if filter.Limit > 0 && len(out) == filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Unfinished returns the runs that were queued or running when their
+// process stopped, oldest first.
+func (s *RunStore) Unfinished() ([]*types.RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var out []*types.RunRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].EndedAt == nil {
+			out = append(out, records[i])
+		}
+	}
+	return out, nil
+}
+
+// Recover marks runs created before the given time that never finished as
+// interrupted, and returns how many it marked. Call it at startup with the
+// process's start time, so runs a crash or restart cut short don't stay
+// queued or running forever.
+func (s *RunStore) Recover(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var stale []*types.RunRecord
+	for _, rec := range records {
+		if rec.EndedAt != nil || !rec.CreatedAt.Before(before) {
+			continue
+		}
+		rec.Status = types.RunInterrupted
+		rec.EndedAt = &now
+		stale = append(stale, rec)
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	return len(stale), s.append(stale)
+}
+
+// load reads the file and returns the latest record of each run, newest
+// first. Returns nil if the file doesn't exist.
+func (s *RunStore) load() ([]*types.RunRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open runs file: %w", err)
+	}
+	defer f.Close()
+
+	latest := make(map[types.RunID]*types.RunRecord)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec types.RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A line cut short by a crash; later lines are still good.
+			continue
+		}
+		latest[rec.ID] = &rec
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read runs file: %w", err)
+	}
+
+	records := make([]*types.RunRecord, 0, len(latest))
+	for _, rec := range latest {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.After(records[j].CreatedAt) })
+	return records, nil
+}
+
+// append writes records to the end of the file, one per line, in a single
+// write.
+func (s *RunStore) append(records []*types.RunRecord) error {
+	var buf []byte
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("marshal run: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create runs dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("open runs file: %w", err)
+	}
+	// Start on a fresh line if a crash left the last one unfinished.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf = append([]byte{'\n'}, buf...)
+		}
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return fmt.Errorf("append run: %w", err)
+	}
+	return f.Close()
+}
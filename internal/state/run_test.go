@@ -0,0 +1,81 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestRunStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	store := NewRunStore(path)
+	start := time.Now()
+	old := &types.RunRecord{ID: "old", SessionID: "s1", Status: "running", CreatedAt: start.Add(-time.Hour), StartedAt: &start}
+	done := &types.RunRecord{ID: "done", SessionID: "s2", Status: "queued", CreatedAt: start.Add(-time.Minute)}
+	for _, rec := range []*types.RunRecord{old, done} {
+		if err := store.Put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ended := start.Add(time.Second)
+	done.Status, done.EndedAt, done.ToolRounds = "complete", &ended, 2
+	if err := store.Put(done); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(&types.RunRecord{}); err == nil {
+		t.Error("expected a record without ID to fail")
+	}
+
+	got, err := store.Get("done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "complete" || got.ToolRounds != 2 {
+		t.Errorf("Get = %+v, want the last record", got)
+	}
+	if _, err := store.Get("missing"); err == nil {
+		t.Error("expected a missing run to fail")
+	}
+
+	runs, _ := store.List(RunFilter{})
+	if len(runs) != 2 || runs[0].ID != "done" {
+		t.Fatalf("List = %+v, want two runs newest first", runs)
+	}
+	if runs, _ := store.List(RunFilter{SessionID: "s1"}); len(runs) != 1 || runs[0].ID != "old" {
+		t.Errorf("session filter = %+v", runs)
+	}
+	if runs, _ := store.List(RunFilter{Limit: 1}); len(runs) != 1 {
+		t.Errorf("limit = %d runs", len(runs))
+	}
+
+	// A crash cut the last line short.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"id":"torn","sta`)
+	f.Close()
+
+	n, err := store.Recover(start)
+	if err != nil || n != 1 {
+		t.Fatalf("Recover = %d, %v; want the one unfinished run", n, err)
+	}
+	if got, _ := store.Get("old"); got.Status != types.RunInterrupted || got.EndedAt == nil {
+		t.Errorf("old run = %+v, want interrupted", got)
+	}
+	if runs, _ := store.List(RunFilter{Status: types.RunInterrupted}); len(runs) != 1 {
+		t.Errorf("status filter = %+v", runs)
+	}
+	if n, _ := store.Recover(start); n != 0 {
+		t.Errorf("second Recover marked %d runs", n)
+	}
+}
+
+func TestRunStoreRecoverSkipsNewRuns(t *testing.T) {
+	store := NewRunStore(filepath.Join(t.TempDir(), "runs.jsonl"))
+	started := time.Now()
+	store.Put(&types.RunRecord{ID: "live", Status: "running", CreatedAt: started.Add(time.Second)})
+	if n, err := store.Recover(started); err != nil || n != 0 {
+		t.Errorf("Recover = %d, %v; runs created after startup belong to this process", n, err)
+	}
+}
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// segment is a sealed, compressed part of a session's event log holding
+// events first through last.
+type segment struct {
+	path        string
+	codec       string
+	first, last int64
+}
+
+// segmentExt returns the file extension for codec, or "" if it is unknown.
+func segmentExt(codec string) string {
+	switch codec {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	}
+	return ""
+}
+
+// listSegments returns the event segments in a session directory ordered
+// by sequence number.
+func listSegments(dir string) ([]segment, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "events-*-*.jsonl.*"))
+	if err != nil {
+		return nil, fmt.Errorf("glob event segments: %w", err)
+	}
+	var segments []segment
+	for _, path := range matches {
+		name := filepath.Base(path)
+		seg := segment{path: path}
+		switch {
+		case strings.HasSuffix(name, ".jsonl.gz"):
+			seg.codec = "gzip"
+		case strings.HasSuffix(name, ".jsonl.zst"):
+			seg.codec = "zstd"
+		default:
+			continue
+		}
+		if _, err := fmt.Sscanf(name, "events-%d-%d.jsonl", &seg.first, &seg.last); err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+	return segments, nil
+}
+
+// lines decompresses the segment and returns its non-empty lines.
+func (s segment) lines() ([][]byte, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("open event segment: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader
+	switch s.codec {
+	case "gzip":
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open event segment %s: %w", filepath.Base(s.path), err)
+		}
+		defer zr.Close()
+		r = zr
+	case "zstd":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open event segment %s: %w", filepath.Base(s.path), err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompress event segment %s: %w", filepath.Base(s.path), err)
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// seal compresses the session's events.jsonl, whose last event has
+// sequence number last, into a segment and removes it. The segment is
+// written atomically before events.jsonl goes away; CheckIntegrity removes
+// an events.jsonl left behind by a crash in between. Caller must hold the
+// session lock.
+func (e *EventStore) seal(sessionID types.SessionID, codec string, last int64) error {
+	path := e.eventsPath(sessionID)
+	dir := filepath.Dir(path)
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+	first := int64(1)
+	if len(segments) > 0 {
+		first = segments[len(segments)-1].last + 1
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read events file: %w", err)
+	}
+	var buf bytes.Buffer
+	switch codec {
+	case "gzip":
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("compress events: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compress events: %w", err)
+		}
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return fmt.Errorf("compress events: %w", err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("compress events: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compress events: %w", err)
+		}
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("events-%08d-%08d.jsonl%s", first, last, segmentExt(codec)))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write temp event segment: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp event segment: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove sealed events file: %w", err)
+	}
+	return nil
+}
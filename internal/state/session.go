@@ -19,6 +19,37 @@ import (
 type SessionStore struct {
 	root string
 	mu   sync.RWMutex
+
+	cacheMu sync.Mutex
+	cache   *sessionCache
+}
+
+// sessionCache is a parsed snapshot of sessions.json. It is keyed by both
+// SessionKey and SessionID so lookups don't scan, and is invalidated when
+// the file's mtime or size changes (e.g. the CLI writing from another
+// process). Entries are never handed out directly; callers get copies.
+type sessionCache struct {
+	byKey   map[types.SessionKey]*types.SessionIndex
+	byID    map[types.SessionID]*types.SessionIndex
+	modTime time.Time
+	size    int64
+}
+
+func newSessionCache(sessions []*types.SessionIndex, info os.FileInfo) *sessionCache {
+	c := &sessionCache{
+		byKey: make(map[types.SessionKey]*types.SessionIndex, len(sessions)),
+		byID:  make(map[types.SessionID]*types.SessionIndex, len(sessions)),
+	}
+	if info != nil {
+		c.modTime = info.ModTime()
+		c.size = info.Size()
+	}
+	for _, sess := range sessions {
+		cp := *sess
+		c.byKey[cp.SessionKey] = &cp
+		c.byID[cp.SessionID] = &cp
+	}
+	return c
 }
 
 // NewSessionStore creates a new file-backed SessionStore rooted at the given directory.
@@ -38,24 +69,46 @@ func (s *SessionStore) sessionDir(id types.SessionID) string {
 	return filepath.Join(s.root, "sessions", string(id))
 }
 
-// loadIndex reads sessions.json and returns a map keyed by SessionKey.
-func (s *SessionStore) loadIndex() (map[types.SessionKey]*types.SessionIndex, error) {
-	data, err := os.ReadFile(s.indexPath())
+// cached returns the current index snapshot, re-reading sessions.json only
+// when it has changed on disk since the last read.
+func (s *SessionStore) cached() (*sessionCache, error) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	info, err := os.Stat(s.indexPath())
 	if err != nil {
 		if os.IsNotExist(err) {
-			return make(map[types.SessionKey]*types.SessionIndex), nil
+			s.cache = nil
+			return newSessionCache(nil, nil), nil
 		}
-		return nil, fmt.Errorf("read session index: %w", err)
+		return nil, fmt.Errorf("stat session index: %w", err)
+	}
+	if s.cache != nil && s.cache.modTime.Equal(info.ModTime()) && s.cache.size == info.Size() {
+		return s.cache, nil
 	}
 
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		return nil, fmt.Errorf("read session index: %w", err)
+	}
 	var sessions []*types.SessionIndex
 	if err := json.Unmarshal(data, &sessions); err != nil {
 		return nil, fmt.Errorf("unmarshal session index: %w", err)
 	}
+	s.cache = newSessionCache(sessions, info)
+	return s.cache, nil
+}
 
-	index := make(map[types.SessionKey]*types.SessionIndex, len(sessions))
-	for _, sess := range sessions {
-		index[sess.SessionKey] = sess
+// loadIndex returns a mutable copy of the session index keyed by SessionKey.
+func (s *SessionStore) loadIndex() (map[types.SessionKey]*types.SessionIndex, error) {
+	c, err := s.cached()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[types.SessionKey]*types.SessionIndex, len(c.byKey))
+	for key, sess := range c.byKey {
+		cp := *sess
+		index[key] = &cp
 	}
 	return index, nil
 }
@@ -86,6 +139,17 @@ func (s *SessionStore) saveIndex(index map[types.SessionKey]*types.SessionIndex)
 		os.Remove(tmp)
 		return fmt.Errorf("rename temp index: %w", err)
 	}
+
+	// Refresh the cache from what we just wrote so the next read doesn't
+	// need to re-parse the file.
+	info, err := os.Stat(s.indexPath())
+	s.cacheMu.Lock()
+	if err == nil {
+		s.cache = newSessionCache(sessions, info)
+	} else {
+		s.cache = nil
+	}
+	s.cacheMu.Unlock()
 	return nil
 }
 
@@ -103,19 +167,23 @@ func (s *SessionStore) ResolveOrCreate(_ context.Context, key types.SessionKey,
 		return existing.SessionID, nil
 	}
 
+	return s.create(index, key, agent)
+}
+
+// create adds a fresh active session under key to index, saves it and
+// creates its directory. The caller holds s.mu.
+func (s *SessionStore) create(index map[types.SessionKey]*types.SessionIndex, key types.SessionKey, agent string) (types.SessionID, error) {
 	now := time.Now()
 	id := types.NewSessionID()
-	session := &types.SessionIndex{
+	index[key] = &types.SessionIndex{
 		SessionID:  id,
 		SessionKey: key,
 		Agent:      agent,
-		Status:     "active",
+		Status:     types.SessionActive,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
-	index[key] = session
-
 	if err := s.saveIndex(index); err != nil {
 		return "", err
 	}
@@ -124,26 +192,36 @@ func (s *SessionStore) ResolveOrCreate(_ context.Context, key types.SessionKey,
 	if err := os.MkdirAll(s.sessionDir(id), 0o755); err != nil {
 		return "", fmt.Errorf("create session dir: %w", err)
 	}
-
 	return id, nil
 }
 
+// archive marks sess archived and moves it to the "archived:<id>" key,
+// remembering the key it held.
+func archive(sess *types.SessionIndex, at time.Time) {
+	sess.Status = types.SessionArchived
+	if sess.ArchivedFrom == "" {
+		sess.ArchivedFrom = sess.SessionKey
+	}
+	sess.ArchivedAt = &at
+	sess.SessionKey = types.SessionKey("archived:" + string(sess.SessionID))
+}
+
 // Get returns the session with the given ID.
 func (s *SessionStore) Get(_ context.Context, id types.SessionID) (*types.SessionIndex, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	index, err := s.loadIndex()
+	c, err := s.cached()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, sess := range index {
-		if sess.SessionID == id {
-			return sess, nil
-		}
+	sess, ok := c.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", id)
 	}
-	return nil, fmt.Errorf("session not found: %s", id)
+	cp := *sess
+	return &cp, nil
 }
 
 // List returns all sessions.
@@ -163,10 +241,23 @@ func (s *SessionStore) List(_ context.Context) ([]*types.SessionIndex, error) {
 	return sessions, nil
 }
 
-// Rotate archives the current session for the given key and removes the
-// key mapping so the next ResolveOrCreate creates a fresh session.
-// Returns the old session ID (empty if no session existed).
-func (s *SessionStore) Rotate(_ context.Context, key types.SessionKey) (types.SessionID, error) {
+// ListFiltered returns the sessions f selects.
+func (s *SessionStore) ListFiltered(ctx context.Context, f types.SessionFilter) ([]*types.SessionIndex, error) {
+	all, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return types.FilterSessions(all, f), nil
+}
+
+// Rotate archives the current session for the given key and creates a
+// fresh session for the same agent under the key. The archived session
+// keeps its ID and history, and moves to the key "archived:<id>" with
+// ArchivedFrom set to the key it held. With expected set, only that session
+// is rotated, so two callers that both saw it go idle rotate it once.
+// Returns the old session ID, or "" and changes nothing if no session (or
+// not the expected one) holds the key.
+func (s *SessionStore) Rotate(_ context.Context, key types.SessionKey, expected types.SessionID) (types.SessionID, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -176,27 +267,25 @@ func (s *SessionStore) Rotate(_ context.Context, key types.SessionKey) (types.Se
 	}
 
 	existing, ok := index[key]
-	if !ok {
+	if !ok || (expected != "" && existing.SessionID != expected) {
 		return "", nil
 	}
 
-	existing.Status = "archived"
-	existing.UpdatedAt = time.Now()
-
-	// Remove the key mapping so next resolve creates a new session.
-	// Keep the session in the index under a namespaced key for history.
-	archiveKey := types.SessionKey("archived:" + string(existing.SessionID))
-	existing.SessionKey = archiveKey
-	index[archiveKey] = existing
+	now := time.Now()
+	archive(existing, now)
+	existing.UpdatedAt = now
+	index[existing.SessionKey] = existing
 	delete(index, key)
 
-	if err := s.saveIndex(index); err != nil {
+	if _, err := s.create(index, key, existing.Agent); err != nil {
 		return "", err
 	}
 	return existing.SessionID, nil
 }
 
 // Update persists changes to the given session, setting UpdatedAt to now.
+// It fails if the session no longer holds its key, so a stale copy read
+// before a Rotate can't displace the session that replaced it.
 func (s *SessionStore) Update(_ context.Context, session *types.SessionIndex) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -206,12 +295,67 @@ func (s *SessionStore) Update(_ context.Context, session *types.SessionIndex) er
 		return err
 	}
 
-	if _, ok := index[session.SessionKey]; !ok {
+	existing, ok := index[session.SessionKey]
+	if !ok {
 		return fmt.Errorf("session not found: %s", session.SessionKey)
 	}
+	if existing.SessionID != session.SessionID {
+		return fmt.Errorf("session %s no longer holds key %s", session.SessionID, session.SessionKey)
+	}
 
 	session.UpdatedAt = time.Now()
 	index[session.SessionKey] = session
 
 	return s.saveIndex(index)
 }
+
+// Touch sets the session's UpdatedAt to now without rewriting any other
+// field, so it can't clobber changes made since the caller read it.
+func (s *SessionStore) Touch(_ context.Context, id types.SessionID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+	for _, sess := range index {
+		if sess.SessionID == id {
+			sess.UpdatedAt = time.Now()
+			return s.saveIndex(index)
+		}
+	}
+	return fmt.Errorf("session not found: %s", id)
+}
+
+// Restore adds a session from a backup with its original ID and
+// timestamps. It reports false if a session with that ID already exists.
+// If another session holds the key, the restored one is archived under
+// "archived:<id>", as Rotate would have left it.
+func (s *SessionStore) Restore(_ context.Context, session *types.SessionIndex) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, err := s.loadIndex()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range index {
+		if existing.SessionID == session.SessionID {
+			return false, nil
+		}
+	}
+
+	cp := *session
+	if _, taken := index[cp.SessionKey]; taken {
+		archive(&cp, time.Now())
+	}
+	index[cp.SessionKey] = &cp
+	if err := s.saveIndex(index); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(s.sessionDir(cp.SessionID), 0o755); err != nil {
+		return false, fmt.Errorf("create session dir: %w", err)
+	}
+	return true, nil
+}
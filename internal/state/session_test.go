@@ -3,6 +3,7 @@ package state
 
 import (
 	"context"
+	"strconv"
 	"testing"
 
 	"github.com/user/gopherclaw/internal/types"
@@ -41,3 +42,85 @@ func TestSessionStore(t *testing.T) {
 		t.Error("expected same session ID for same key")
 	}
 }
+
+func TestSessionStoreCacheInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSessionStore(dir)
+	ctx := context.Background()
+
+	id, err := store.ResolveOrCreate(ctx, types.NewSessionKey("test", "cache"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mutating a returned session must not leak into the cache.
+	sess, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Status = "mutated"
+	again, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Status != "active" {
+		t.Errorf("expected cached status active, got %s", again.Status)
+	}
+
+	// A second store writing the same file (e.g. the CLI) is picked up.
+	other := NewSessionStore(dir)
+	otherID, err := other.ResolveOrCreate(ctx, types.NewSessionKey("test", "other"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, otherID); err != nil {
+		t.Errorf("expected session written by another store to be visible: %v", err)
+	}
+}
+
+func BenchmarkSessionStoreGet(b *testing.B) {
+	dir := b.TempDir()
+	store := NewSessionStore(dir)
+	ctx := context.Background()
+
+	index := make(map[types.SessionKey]*types.SessionIndex)
+	var ids []types.SessionID
+	for i := 0; i < 500; i++ {
+		key := types.NewSessionKey("bench", strconv.Itoa(i))
+		id := types.NewSessionID()
+		index[key] = &types.SessionIndex{SessionID: id, SessionKey: key, Agent: "default", Status: "active"}
+		ids = append(ids, id)
+	}
+	if err := store.saveIndex(index); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Get(ctx, ids[i%len(ids)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSessionStoreResolveOrCreate(b *testing.B) {
+	dir := b.TempDir()
+	store := NewSessionStore(dir)
+	ctx := context.Background()
+
+	var keys []types.SessionKey
+	for i := 0; i < 500; i++ {
+		key := types.NewSessionKey("bench", strconv.Itoa(i))
+		if _, err := store.ResolveOrCreate(ctx, key, "default"); err != nil {
+			b.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ResolveOrCreate(ctx, keys[i%len(keys)], "default"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
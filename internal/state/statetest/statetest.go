@@ -0,0 +1,772 @@
+// Package statetest provides conformance tests that any storage backend must
+// pass. Each Test* function takes a constructor returning a fresh, empty
+// store and exercises it against the semantics the rest of gopherclaw relies
+// on (ordering, idempotency, not-found behavior, concurrency).
+//
+// Backends run the suite from their own tests:
+//
+//	func TestSessionStoreConformance(t *testing.T) {
+//		statetest.TestSessionStore(t, func(t *testing.T) types.SessionStore {
+//			return state.NewSessionStore(t.TempDir())
+//		})
+//	}
+package statetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// TaskStore is the method set the scheduler, webhook server, and CLI use on
+// task storage.
+type TaskStore interface {
+	List() ([]*state.Task, error)
+	Get(name string) (*state.Task, error)
+	Add(task *state.Task) error
+	Remove(name string) error
+	SetEnabled(name string, enabled bool) error
+	RecordRun(name string, run state.TaskRun) error
+	RecordFire(name string, at time.Time) error
+}
+
+// TestSessionStore runs the SessionStore conformance suite.
+func TestSessionStore(t *testing.T, newStore func(t *testing.T) types.SessionStore) {
+	ctx := context.Background()
+
+	t.Run("ResolveOrCreateIsIdempotent", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "idem")
+		id1, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		id2, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id1 == "" || id1 != id2 {
+			t.Errorf("expected stable non-empty ID, got %q and %q", id1, id2)
+		}
+	})
+
+	t.Run("DistinctKeysGetDistinctSessions", func(t *testing.T) {
+		store := newStore(t)
+		a, err := store.ResolveOrCreate(ctx, types.NewSessionKey("test", "a"), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := store.ResolveOrCreate(ctx, types.NewSessionKey("test", "b"), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a == b {
+			t.Error("expected different session IDs for different keys")
+		}
+		list, err := store.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 {
+			t.Errorf("expected 2 sessions, got %d", len(list))
+		}
+	})
+
+	t.Run("GetReturnsSession", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "get")
+		id, err := store.ResolveOrCreate(ctx, key, "agent-x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sess.SessionID != id || sess.SessionKey != key || sess.Agent != "agent-x" {
+			t.Errorf("unexpected session: %+v", sess)
+		}
+		if sess.Status != "active" {
+			t.Errorf("expected new session to be active, got %q", sess.Status)
+		}
+		if sess.CreatedAt.IsZero() {
+			t.Error("expected CreatedAt to be set")
+		}
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		store := newStore(t)
+		if _, err := store.Get(ctx, types.NewSessionID()); err == nil {
+			t.Error("expected error for unknown session")
+		}
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		store := newStore(t)
+		list, err := store.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 0 {
+			t.Errorf("expected empty list, got %d", len(list))
+		}
+	})
+
+	t.Run("UpdatePersists", func(t *testing.T) {
+		store := newStore(t)
+		id, err := store.ResolveOrCreate(ctx, types.NewSessionKey("test", "upd"), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := sess.UpdatedAt
+		sess.LastEventSeq = 42
+		time.Sleep(time.Millisecond)
+		if err := store.Update(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.LastEventSeq != 42 {
+			t.Errorf("expected LastEventSeq 42, got %d", got.LastEventSeq)
+		}
+		if !got.UpdatedAt.After(before) {
+			t.Error("expected Update to advance UpdatedAt")
+		}
+	})
+
+	t.Run("UpdateNotFound", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Update(ctx, &types.SessionIndex{
+			SessionID:  types.NewSessionID(),
+			SessionKey: types.NewSessionKey("test", "missing"),
+		})
+		if err == nil {
+			t.Error("expected error updating unknown session")
+		}
+	})
+
+	t.Run("TouchKeepsOtherFields", func(t *testing.T) {
+		store := newStore(t)
+		id, err := store.ResolveOrCreate(ctx, types.NewSessionKey("test", "touch"), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess.Language = "es"
+		if err := store.Update(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+		before := sess.UpdatedAt
+		time.Sleep(time.Millisecond)
+		if err := store.Touch(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Language != "es" {
+			t.Errorf("expected Touch to keep Language, got %q", got.Language)
+		}
+		if !got.UpdatedAt.After(before) {
+			t.Error("expected Touch to advance UpdatedAt")
+		}
+		if err := store.Touch(ctx, types.NewSessionID()); err == nil {
+			t.Error("expected error touching unknown session")
+		}
+	})
+
+	t.Run("RotateArchivesAndFreesKey", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "rot")
+		oldID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated, err := store.Rotate(ctx, key, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated != oldID {
+			t.Errorf("expected Rotate to return %s, got %s", oldID, rotated)
+		}
+		newID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newID == oldID {
+			t.Error("expected a fresh session after rotation")
+		}
+		old, err := store.Get(ctx, oldID)
+		if err != nil {
+			t.Fatalf("expected archived session to remain readable: %v", err)
+		}
+		if old.Status != "archived" {
+			t.Errorf("expected archived status, got %q", old.Status)
+		}
+	})
+
+	t.Run("RotateCreatesFreshSession", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "fresh")
+		oldID, err := store.ResolveOrCreate(ctx, key, "agent-x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Rotate(ctx, key, ""); err != nil {
+			t.Fatal(err)
+		}
+		list, err := store.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		active := types.FilterSessions(list, types.SessionFilter{Status: types.SessionActive, Key: key})
+		if len(active) != 1 || active[0].SessionID == oldID || active[0].SessionKey != key || active[0].Agent != "agent-x" {
+			t.Fatalf("expected one fresh session for the same agent under the key, got %+v", active)
+		}
+		archived := types.FilterSessions(list, types.SessionFilter{Status: types.SessionArchived, Channel: "test"})
+		if len(archived) != 1 || archived[0].SessionID != oldID || archived[0].ArchivedFrom != key || archived[0].ArchivedAt == nil {
+			t.Fatalf("expected the old session archived from the key, got %+v", archived)
+		}
+		if archived[0].SessionKey == key {
+			t.Error("expected the archived session to give up the key")
+		}
+	})
+
+	t.Run("UpdateStaleAfterRotate", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "stale")
+		oldID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale, err := store.Get(ctx, oldID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Rotate(ctx, key, ""); err != nil {
+			t.Fatal(err)
+		}
+		newID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale.LastEventSeq = 7
+		if err := store.Update(ctx, stale); err == nil {
+			t.Error("expected error updating a session that was rotated out of its key")
+		}
+		if got, err := store.ResolveOrCreate(ctx, key, "default"); err != nil || got != newID {
+			t.Errorf("expected key to stay with %s, got %s (%v)", newID, got, err)
+		}
+		old, err := store.Get(ctx, oldID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if old.Status != "archived" || old.LastEventSeq == 7 {
+			t.Errorf("expected archived session untouched, got %+v", old)
+		}
+	})
+
+	t.Run("RotateExpectedOnce", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "cas")
+		oldID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated, err := store.Rotate(ctx, key, oldID); err != nil || rotated != oldID {
+			t.Fatalf("expected first rotation of %s, got %s, %v", oldID, rotated, err)
+		}
+		newID, err := store.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated, err := store.Rotate(ctx, key, oldID); err != nil || rotated != "" {
+			t.Fatalf("expected a second rotation of %s to do nothing, got %s, %v", oldID, rotated, err)
+		}
+		if got, err := store.ResolveOrCreate(ctx, key, "default"); err != nil || got != newID {
+			t.Errorf("expected %s to keep the key, got %s, %v", newID, got, err)
+		}
+	})
+
+	t.Run("RotateUnknownKey", func(t *testing.T) {
+		store := newStore(t)
+		id, err := store.Rotate(ctx, types.NewSessionKey("test", "none"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "" {
+			t.Errorf("expected empty ID rotating unknown key, got %s", id)
+		}
+	})
+
+	t.Run("ConcurrentResolveOrCreate", func(t *testing.T) {
+		store := newStore(t)
+		key := types.NewSessionKey("test", "race")
+		var wg sync.WaitGroup
+		ids := make([]types.SessionID, 10)
+		errs := make([]error, 10)
+		for i := range ids {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ids[i], errs[i] = store.ResolveOrCreate(ctx, key, "default")
+			}(i)
+		}
+		wg.Wait()
+		for i := range ids {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			if ids[i] != ids[0] {
+				t.Fatalf("expected a single session for concurrent resolves, got %s and %s", ids[0], ids[i])
+			}
+		}
+	})
+}
+
+func newEvent(sessionID types.SessionID, typ, text string) *types.Event {
+	payload, _ := json.Marshal(map[string]string{"text": text})
+	return &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: sessionID,
+		RunID:     types.NewRunID(),
+		Type:      typ,
+		Source:    "statetest",
+		At:        time.Now(),
+		Payload:   payload,
+	}
+}
+
+// TestEventStore runs the EventStore conformance suite.
+func TestEventStore(t *testing.T, newStore func(t *testing.T) types.EventStore) {
+	ctx := context.Background()
+
+	t.Run("AppendAssignsSequentialSeq", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		for i := 1; i <= 3; i++ {
+			ev := newEvent(sid, "user_message", fmt.Sprint(i))
+			if err := store.Append(ctx, ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Seq != int64(i) {
+				t.Errorf("expected seq %d, got %d", i, ev.Seq)
+			}
+		}
+	})
+
+	t.Run("TailReturnsChronologicalSuffix", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		for i := 1; i <= 5; i++ {
+			if err := store.Append(ctx, newEvent(sid, "user_message", fmt.Sprint(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		events, err := store.Tail(ctx, sid, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(events))
+		}
+		for i, ev := range events {
+			if ev.Seq != int64(i+3) {
+				t.Errorf("expected seq %d at position %d, got %d", i+3, i, ev.Seq)
+			}
+		}
+		all, err := store.Tail(ctx, sid, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 5 {
+			t.Errorf("expected all 5 events when limit exceeds count, got %d", len(all))
+		}
+	})
+
+	t.Run("TailPreservesFields", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		ev := newEvent(sid, "assistant_message", "hello")
+		if err := store.Append(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+		events, err := store.Tail(ctx, sid, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		got := events[0]
+		if got.ID != ev.ID || got.RunID != ev.RunID || got.Type != ev.Type || got.Source != ev.Source {
+			t.Errorf("event fields not preserved: %+v", got)
+		}
+		if !strings.Contains(string(got.Payload), "hello") {
+			t.Errorf("payload not preserved: %s", got.Payload)
+		}
+	})
+
+	t.Run("TailUnknownSession", func(t *testing.T) {
+		store := newStore(t)
+		events, err := store.Tail(ctx, types.NewSessionID(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 0 {
+			t.Errorf("expected no events, got %d", len(events))
+		}
+	})
+
+	t.Run("CountTracksAppends", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		n, err := store.Count(ctx, sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("expected 0 for unknown session, got %d", n)
+		}
+		for i := 0; i < 4; i++ {
+			if err := store.Append(ctx, newEvent(sid, "user_message", "x")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		n, err = store.Count(ctx, sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 4 {
+			t.Errorf("expected 4, got %d", n)
+		}
+	})
+
+	t.Run("AppendBatchIsContiguous", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		if err := store.Append(ctx, newEvent(sid, "user_message", "first")); err != nil {
+			t.Fatal(err)
+		}
+		batch := []*types.Event{
+			newEvent(sid, "tool_call", "a"),
+			newEvent(sid, "tool_result", "b"),
+		}
+		if err := store.AppendBatch(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+		events, err := store.Tail(ctx, sid, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 || events[1].Type != "tool_call" || events[2].Type != "tool_result" {
+			t.Fatalf("unexpected events after batch: %d", len(events))
+		}
+		if events[1].Seq != 2 || events[2].Seq != 3 {
+			t.Errorf("expected seqs 2,3, got %d,%d", events[1].Seq, events[2].Seq)
+		}
+	})
+
+	t.Run("SessionsAreIsolated", func(t *testing.T) {
+		store := newStore(t)
+		a, b := types.NewSessionID(), types.NewSessionID()
+		if err := store.Append(ctx, newEvent(a, "user_message", "a")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Append(ctx, newEvent(b, "user_message", "b")); err != nil {
+			t.Fatal(err)
+		}
+		events, err := store.Tail(ctx, b, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Seq != 1 {
+			t.Errorf("expected session b to have its own sequence, got %d events", len(events))
+		}
+	})
+
+	t.Run("ConcurrentAppendsGetUniqueSeq", func(t *testing.T) {
+		store := newStore(t)
+		sid := types.NewSessionID()
+		const n = 20
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := store.Append(ctx, newEvent(sid, "user_message", "c")); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		events, err := store.Tail(ctx, sid, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != n {
+			t.Fatalf("expected %d events, got %d", n, len(events))
+		}
+		for i, ev := range events {
+			if ev.Seq != int64(i+1) {
+				t.Errorf("expected seq %d, got %d", i+1, ev.Seq)
+			}
+		}
+	})
+}
+
+// TestArtifactStore runs the ArtifactStore conformance suite.
+func TestArtifactStore(t *testing.T, newStore func(t *testing.T) types.ArtifactStore) {
+	ctx := context.Background()
+
+	t.Run("PutGetRoundTrip", func(t *testing.T) {
+		store := newStore(t)
+		sid, rid := types.NewSessionID(), types.NewRunID()
+		id, err := store.Put(ctx, sid, rid, "tool-x", map[string]any{"k": "v"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == "" {
+			t.Fatal("expected non-empty artifact ID")
+		}
+		raw, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["k"] != "v" {
+			t.Errorf("data not preserved: %s", raw)
+		}
+		meta, err := store.GetMeta(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.ID != id || meta.SessionID != sid || meta.RunID != rid || meta.Tool != "tool-x" {
+			t.Errorf("unexpected meta: %+v", meta)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		store := newStore(t)
+		missing := types.NewArtifactID()
+		if _, err := store.Get(ctx, missing); err == nil {
+			t.Error("expected Get error for unknown artifact")
+		}
+		if _, err := store.GetMeta(ctx, missing); err == nil {
+			t.Error("expected GetMeta error for unknown artifact")
+		}
+		if _, err := store.Excerpt(ctx, missing, "", 10); err == nil {
+			t.Error("expected Excerpt error for unknown artifact")
+		}
+	})
+
+	t.Run("ExcerptRespectsBudgetAndQuery", func(t *testing.T) {
+		store := newStore(t)
+		text := strings.Repeat("a", 1000) + "NEEDLE" + strings.Repeat("b", 1000)
+		id, err := store.Put(ctx, types.NewSessionID(), types.NewRunID(), "t", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ex, err := store.Excerpt(ctx, id, "needle", 25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(ex, "NEEDLE") {
+			t.Errorf("expected excerpt around query, got %q", ex)
+		}
+		if len(ex) > 25*4 {
+			t.Errorf("expected excerpt within budget, got %d chars", len(ex))
+		}
+	})
+
+	t.Run("SetSummaryPersists", func(t *testing.T) {
+		store := newStore(t)
+		id, err := store.Put(ctx, types.NewSessionID(), types.NewRunID(), "t", "long output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetSummary(ctx, id, "short"); err != nil {
+			t.Fatal(err)
+		}
+		meta, err := store.GetMeta(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Summary != "short" {
+			t.Errorf("expected summary %q, got %q", "short", meta.Summary)
+		}
+		raw, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != `"long output"` {
+			t.Errorf("data changed by SetSummary: %s", raw)
+		}
+		if err := store.SetSummary(ctx, types.NewArtifactID(), "x"); err == nil {
+			t.Error("expected SetSummary error for unknown artifact")
+		}
+	})
+}
+
+// TestTaskStore runs the TaskStore conformance suite.
+func TestTaskStore(t *testing.T, newStore func(t *testing.T) TaskStore) {
+	task := func(name string) *state.Task {
+		return &state.Task{Name: name, Prompt: "p", Schedule: "0 9 * * *", SessionKey: "test:1", Enabled: true}
+	}
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		store := newStore(t)
+		tasks, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tasks == nil || len(tasks) != 0 {
+			t.Errorf("expected empty non-nil list, got %v", tasks)
+		}
+	})
+
+	t.Run("AddGetList", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Add(task("a")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(task("b")); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "a" || got.Schedule != "0 9 * * *" || !got.Enabled {
+			t.Errorf("unexpected task: %+v", got)
+		}
+		tasks, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 2 || tasks[0].Name != "a" || tasks[1].Name != "b" {
+			t.Errorf("expected tasks in insertion order, got %v", tasks)
+		}
+	})
+
+	t.Run("DuplicateRejected", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Add(task("dup")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(task("dup")); err == nil {
+			t.Error("expected error adding duplicate task")
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		store := newStore(t)
+		if _, err := store.Get("missing"); err == nil {
+			t.Error("expected Get error")
+		}
+		if err := store.Remove("missing"); err == nil {
+			t.Error("expected Remove error")
+		}
+		if err := store.SetEnabled("missing", true); err == nil {
+			t.Error("expected SetEnabled error")
+		}
+		if err := store.RecordRun("missing", state.TaskRun{}); err == nil {
+			t.Error("expected RecordRun error")
+		}
+	})
+
+	t.Run("RecordRun", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Add(task("r")); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Truncate(time.Second)
+		if err := store.RecordRun("r", state.TaskRun{At: at, Trigger: "schedule", Response: "done"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get("r")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.LastRun == nil || !got.LastRun.At.Equal(at) || got.LastRun.Trigger != "schedule" || got.LastRun.Response != "done" {
+			t.Errorf("unexpected last run: %+v", got.LastRun)
+		}
+		if got.Prompt != "p" || !got.Enabled {
+			t.Errorf("RecordRun changed other fields: %+v", got)
+		}
+	})
+
+	t.Run("RemoveAndSetEnabled", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Add(task("x")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetEnabled("x", false); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get("x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Enabled {
+			t.Error("expected task disabled")
+		}
+		if err := store.Remove("x"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get("x"); err == nil {
+			t.Error("expected task to be gone after Remove")
+		}
+	})
+
+	t.Run("RecordFire", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Add(task("f")); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Truncate(time.Second)
+		if err := store.RecordFire("f", at); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Get("f"); err != nil || got.LastFire == nil || !got.LastFire.Equal(at) {
+			t.Fatalf("LastFire = %v, %v", got, err)
+		}
+		// Re-enabling forgets the last fire, so the fires skipped while
+		// disabled don't count as missed.
+		if err := store.SetEnabled("f", false); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := store.Get("f"); got.LastFire == nil {
+			t.Error("disabling cleared LastFire")
+		}
+		if err := store.SetEnabled("f", true); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := store.Get("f"); got.LastFire != nil {
+			t.Errorf("LastFire = %v after re-enabling", got.LastFire)
+		}
+		if err := store.RecordFire("missing", at); err == nil {
+			t.Error("expected RecordFire error")
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped.
+const subscriberBuffer = 256
+
+// subscriber receives a session's events as they are appended.
+type subscriber struct {
+	ch   chan *types.Event
+	once sync.Once
+}
+
+func (s *subscriber) close() { s.once.Do(func() { close(s.ch) }) }
+
+// Subscribe returns a channel that receives copies of the session's events
+// as this store appends them, and a function that ends the subscription.
+// The channel is closed when the subscription ends, including when the
+// subscriber falls more than subscriberBuffer events behind; a subscriber
+// that sees it close early should catch up from the log. Appends made by
+// other processes sharing the data directory are not seen.
+func (e *EventStore) Subscribe(sessionID types.SessionID) (<-chan *types.Event, func()) {
+	sub := &subscriber{ch: make(chan *types.Event, subscriberBuffer)}
+	e.subMu.Lock()
+	if e.subs == nil {
+		e.subs = make(map[types.SessionID]map[*subscriber]bool)
+	}
+	if e.subs[sessionID] == nil {
+		e.subs[sessionID] = make(map[*subscriber]bool)
+	}
+	e.subs[sessionID][sub] = true
+	e.subMu.Unlock()
+	return sub.ch, func() { e.unsubscribe(sessionID, sub) }
+}
+
+func (e *EventStore) unsubscribe(sessionID types.SessionID, sub *subscriber) {
+	e.subMu.Lock()
+	delete(e.subs[sessionID], sub)
+	if len(e.subs[sessionID]) == 0 {
+		delete(e.subs, sessionID)
+	}
+	e.subMu.Unlock()
+	sub.close()
+}
+
+// publish passes appended events to the session's subscribers without
+// blocking the append. Called with the session's lock held, so events
+// arrive in sequence order.
+func (e *EventStore) publish(sessionID types.SessionID, events []*types.Event) {
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
+	for sub := range e.subs[sessionID] {
+		for _, event := range events {
+			cp := *event
+			select {
+			case sub.ch <- &cp:
+				continue
+			default:
+			}
+			delete(e.subs[sessionID], sub)
+			sub.close()
+			break
+		}
+	}
+}
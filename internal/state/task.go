@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Task represents a named prompt that can be triggered on a schedule or via webhook.
@@ -15,9 +16,110 @@ type Task struct {
 	Prompt     string `json:"prompt"`
 	Schedule   string `json:"schedule,omitempty"`
 	SessionKey string `json:"session_key"`
-	Enabled    bool   `json:"enabled"`
+	// DeliverTo lists where scheduled responses go, as keys in session
+	// key form ("telegram:<user>:<chat>", "email:<address>", ...). Empty
+	// delivers to SessionKey.
+	DeliverTo []string `json:"deliver_to,omitempty"`
+	Enabled   bool     `json:"enabled"`
+	// Timezone is the IANA time zone Schedule fires in. Empty uses the
+	// scheduler's default.
+	Timezone string `json:"timezone,omitempty"`
+	// Concurrency lets runs in this task's session execute in parallel.
+	// Zero or one keeps the default FIFO behavior.
+	Concurrency int `json:"concurrency,omitempty"`
+	// PayloadTemplate is a text/template rendered with the decoded JSON
+	// body of a webhook trigger to build the prompt. Empty uses Prompt.
+	PayloadTemplate string `json:"payload_template,omitempty"`
+	// Expect declares what a scheduled run's response must look like. A
+	// run that violates it raises an admin alert.
+	Expect *TaskExpect `json:"expect,omitempty"`
+	// Delivery shapes scheduled responses per channel before they are
+	// delivered, keyed by channel ("telegram", "email", ...) or "*" for
+	// any channel without its own template.
+	Delivery map[string]*DeliveryTemplate `json:"delivery,omitempty"`
+	// Pipeline names a pipeline in the data dir's pipelines/ directory.
+	// When set, scheduled runs go through the pipeline instead of sending
+	// Prompt directly.
+	Pipeline string `json:"pipeline,omitempty"`
+	// Dedupe skips delivering a scheduled response that is the same as the
+	// previous run's, ignoring case and whitespace.
+	Dedupe bool `json:"dedupe,omitempty"`
+	// CatchUp says what to do about scheduled fires missed while the
+	// daemon was down: CatchUpSkip (the default), CatchUpOnce or CatchUpAll.
+	CatchUp string `json:"catch_up,omitempty"`
+	// LastRun records the outcome of the most recent trigger.
+	LastRun *TaskRun `json:"last_run,omitempty"`
+	// LastFire is when the schedule last fired, which is where looking
+	// for missed fires starts.
+	LastFire *time.Time `json:"last_fire,omitempty"`
 }
 
+// Task catch-up policies.
+const (
+	// CatchUpSkip drops missed fires.
+	CatchUpSkip = "skip"
+	// CatchUpOnce runs the task once at start if any fire was missed.
+	CatchUpOnce = "run-once-on-start"
+	// CatchUpAll runs the task once per missed fire, up to a limit.
+	CatchUpAll = "run-all-missed"
+)
+
+// Targets returns the keys the task's scheduled responses are delivered
+// to.
+func (t *Task) Targets() []string {
+	if len(t.DeliverTo) > 0 {
+		return t.DeliverTo
+	}
+	return []string{t.SessionKey}
+}
+
+// DeliveryTemplate wraps a task's response for one channel. Header and
+// Footer are text/templates with .Task, .Channel and .Date.
+type DeliveryTemplate struct {
+	Header string `json:"header,omitempty"`
+	Footer string `json:"footer,omitempty"`
+	// Emoji is put in front of the header, or of the response if there is
+	// no header.
+	Emoji string `json:"emoji,omitempty"`
+	// Format is "markdown" (the default, response as written) or "plain"
+	// (markdown syntax removed).
+	Format string `json:"format,omitempty"`
+	// MaxLength cuts the response to this many characters; 0 keeps it whole.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// TaskExpect is an assertion on a task's response. All set fields must hold.
+type TaskExpect struct {
+	NonEmpty bool   `json:"non_empty,omitempty"`
+	Contains string `json:"contains,omitempty"`
+	// JSONSchema requires the response to be JSON matching the schema. The
+	// supported keywords are type, properties, required, items and enum;
+	// "{}" accepts any JSON.
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+}
+
+// TaskRun is the outcome of one task trigger.
+type TaskRun struct {
+	At       time.Time `json:"at"`
+	Trigger  string    `json:"trigger"` // "schedule", "catch-up" or "webhook"
+	Response string    `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Violation describes how the response failed the task's Expect.
+	Violation string `json:"violation,omitempty"`
+	// ResponseHash fingerprints the full response of a task with Dedupe,
+	// so the next run can be compared against it.
+	ResponseHash string `json:"response_hash,omitempty"`
+	// Duplicate is set when the response matched the previous run's and
+	// was not delivered.
+	Duplicate bool `json:"duplicate,omitempty"`
+	// DeliveryErrors maps each target the response could not be
+	// delivered to to the error. The other targets got it.
+	DeliveryErrors map[string]string `json:"delivery_errors,omitempty"`
+}
+
+// maxTaskRunResponse bounds the response excerpt kept in LastRun.
+const maxTaskRunResponse = 500
+
 // TaskStore is a JSON-file-backed store for tasks.
 type TaskStore struct {
 	path string
@@ -118,6 +220,10 @@ func (s *TaskStore) SetEnabled(name string, enabled bool) error {
 
 	for _, task := range tasks {
 		if task.Name == name {
+			if enabled && !task.Enabled {
+				// Fires skipped while disabled are not missed.
+				task.LastFire = nil
+			}
 			task.Enabled = enabled
 			return s.save(tasks)
 		}
@@ -125,6 +231,50 @@ func (s *TaskStore) SetEnabled(name string, enabled bool) error {
 	return fmt.Errorf("task not found: %s", name)
 }
 
+// RecordFire stores when the task's schedule last fired. Returns an error
+// if the task is not found.
+func (s *TaskStore) RecordFire(name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if task.Name == name {
+			task.LastFire = &at
+			return s.save(tasks)
+		}
+	}
+	return fmt.Errorf("task not found: %s", name)
+}
+
+// RecordRun stores the outcome of a task trigger as the task's LastRun. The
+// response is truncated to keep tasks.json small. Returns an error if the
+// task is not found.
+func (s *TaskStore) RecordRun(name string, run TaskRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if len(run.Response) > maxTaskRunResponse {
+		run.Response = run.Response[:maxTaskRunResponse] + "..."
+	}
+	for _, task := range tasks {
+		if task.Name == name {
+			task.LastRun = &run
+			return s.save(tasks)
+		}
+	}
+	return fmt.Errorf("task not found: %s", name)
+}
+
 // load reads the JSON file and returns the task list. Returns nil if the file doesn't exist.
 func (s *TaskStore) load() ([]*Task, error) {
 	data, err := os.ReadFile(s.path)
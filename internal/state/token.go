@@ -0,0 +1,180 @@
+package state
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tokenPrefix marks gopherclaw API token secrets so they are easy to spot
+// in logs and secret scanners.
+const tokenPrefix = "gct_"
+
+// APIToken is a named HTTP API credential. Only the SHA-256 of its secret
+// is stored; the secret itself is shown once, when the token is created.
+type APIToken struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+}
+
+// TokenStore manages API tokens persisted as a JSON file. Every read goes
+// to disk, so a running daemon sees tokens created or revoked by the CLI
+// without a restart.
+type TokenStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+// NewTokenStore creates a TokenStore that reads and writes the given file path.
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Create adds a token with the given name and scopes and returns its
+// secret. Names must be unique.
+func (s *TokenStore) Create(name string, scopes []string) (string, *APIToken, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("token needs at least one scope")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", nil, fmt.Errorf("token already exists: %s", name)
+		}
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("generate token: %w", err)
+	}
+	secret := tokenPrefix + hex.EncodeToString(raw)
+	token := &APIToken{
+		Name:    name,
+		Hash:    hashToken(secret),
+		Scopes:  append([]string(nil), scopes...),
+		Created: time.Now().UTC(),
+	}
+	if err := s.save(append(tokens, token)); err != nil {
+		return "", nil, err
+	}
+	return secret, token, nil
+}
+
+// List returns all tokens sorted by name. Returns an empty slice if the
+// file doesn't exist.
+func (s *TokenStore) List() ([]*APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	if tokens == nil {
+		return []*APIToken{}, nil
+	}
+	return tokens, nil
+}
+
+// Revoke deletes a token by name. Returns an error if not found.
+func (s *TokenStore) Revoke(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if t.Name == name {
+			return s.save(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return fmt.Errorf("token not found: %s", name)
+}
+
+// Lookup returns the token whose secret is secret, or nil if there is none.
+func (s *TokenStore) Lookup(secret string) (*APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	hash := []byte(hashToken(secret))
+	// Compare against every token so timing doesn't reveal a prefix match.
+	var match *APIToken
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), hash) == 1 {
+			match = t
+		}
+	}
+	return match, nil
+}
+
+// hashToken returns the hex SHA-256 of a token secret.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the JSON file and returns the token list. Returns nil if the file doesn't exist.
+func (s *TokenStore) load() ([]*APIToken, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read tokens file: %w", err)
+	}
+
+	var tokens []*APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("unmarshal tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// save writes the token list to disk using atomic write (temp file + rename).
+// The file is private to the owner: hashes are not secrets, but the list
+// of names and scopes is nobody else's business.
+func (s *TokenStore) save(tokens []*APIToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create tokens dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write temp tokens file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename temp tokens file: %w", err)
+	}
+	return nil
+}
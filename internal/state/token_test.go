@@ -0,0 +1,62 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store := NewTokenStore(path)
+
+	secret, token, err := store.Create("dashboard", []string{"sessions:read", "tasks:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) || token.Name != "dashboard" {
+		t.Errorf("unexpected token %q %+v", secret, token)
+	}
+	if _, _, err := store.Create("dashboard", []string{"chat"}); err == nil {
+		t.Error("expected duplicate name to fail")
+	}
+	if _, _, err := store.Create("empty", nil); err == nil {
+		t.Error("expected a token without scopes to fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("tokens file must not contain the secret")
+	}
+
+	// A second store on the same file sees the token, as the daemon does
+	// after the CLI creates one.
+	found, err := NewTokenStore(path).Lookup(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.Name != "dashboard" || len(found.Scopes) != 2 {
+		t.Errorf("Lookup = %+v", found)
+	}
+	if found, _ := store.Lookup(secret + "x"); found != nil {
+		t.Errorf("wrong secret matched %+v", found)
+	}
+
+	if err := store.Revoke("dashboard"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.Lookup(secret); found != nil {
+		t.Error("revoked token still matches")
+	}
+	if err := store.Revoke("dashboard"); err == nil {
+		t.Error("expected revoking a missing token to fail")
+	}
+	tokens, err := store.List()
+	if err != nil || len(tokens) != 0 {
+		t.Errorf("List = %v, %v", tokens, err)
+	}
+}
@@ -2,22 +2,43 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/feedback"
 	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/state"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 const maxTelegramMessage = 4096
 
+// pollTimeout is the long-poll timeout for getUpdates, in seconds.
+const pollTimeout = 30
+
+// apiTimeout bounds every Bot API request, so a connection that silently
+// dies can't hang the poll loop; it must exceed pollTimeout.
+const apiTimeout = 90 * time.Second
+
+// maxAttachmentSize matches the Bot API's download limit.
+const maxAttachmentSize = 20 << 20
+
 // Adapter bridges Telegram to the gateway.
 type Adapter struct {
 	bot       *tgbotapi.BotAPI
@@ -27,11 +48,44 @@ type Adapter struct {
 	engine     *ctxengine.Engine
 	toolNames  []string
 	memoryPath string
+	artifacts  types.ArtifactStore
+	seed       SessionSeeder
+	admins     map[int64]bool
+	broadcast  delivery.Broadcaster
+	macros     *state.MacroStore
+	artifactURL func(types.ArtifactID) string
+	reminders  *state.ReminderStore
+	models     *llm.Registry
+	streaming  bool
+
+	// Polling state: the context Start was given, the cancel func of the
+	// current poll loop, the next update offset and when the loop last
+	// showed signs of life (Unix nanoseconds).
+	pollMu   sync.Mutex
+	ctx      context.Context
+	stopPoll context.CancelFunc
+	offset   atomic.Int64
+	lastPoll atomic.Int64
+
+	// Tool calls waiting for a press on their Approve or Deny button.
+	approvalMu sync.Mutex
+	approvals  map[types.EventID]*pendingApproval
 }
 
+// SessionSeeder carries context from an archived session into its
+// replacement, e.g. by recording a summary of the old conversation.
+type SessionSeeder func(ctx context.Context, from, to types.SessionID) error
+
 // New creates a Telegram adapter.
 func New(token string, gw *gateway.Gateway, events types.EventStore, sessions types.SessionStore, engine *ctxengine.Engine, toolNames []string, memoryPath string) (*Adapter, error) {
-	bot, err := tgbotapi.NewBotAPI(token)
+	return NewWithAPIEndpoint(tgbotapi.APIEndpoint, token, gw, events, sessions, engine, toolNames, memoryPath)
+}
+
+// NewWithAPIEndpoint creates a Telegram adapter that talks to a different
+// Bot API server, such as a self-hosted one or a fake in tests. endpoint is
+// a format string taking the token and method, like tgbotapi.APIEndpoint.
+func NewWithAPIEndpoint(endpoint, token string, gw *gateway.Gateway, events types.EventStore, sessions types.SessionStore, engine *ctxengine.Engine, toolNames []string, memoryPath string) (*Adapter, error) {
+	bot, err := tgbotapi.NewBotAPIWithClient(token, endpoint, &http.Client{Timeout: apiTimeout})
 	if err != nil {
 		return nil, fmt.Errorf("create bot: %w", err)
 	}
@@ -46,25 +100,142 @@ func New(token string, gw *gateway.Gateway, events types.EventStore, sessions ty
 	}, nil
 }
 
-// Start begins long-polling for Telegram updates.
+// SetArtifactStore enables file attachments. Documents and photos sent to the
+// bot are stored as artifacts and attached to the inbound event; without a
+// store they are ignored.
+func (a *Adapter) SetArtifactStore(artifacts types.ArtifactStore) {
+	a.artifacts = artifacts
+}
+
+// SetMacroStore enables /m, which expands a stored macro and sends the
+// result as a message.
+func (a *Adapter) SetMacroStore(macros *state.MacroStore) {
+	a.macros = macros
+}
+
+// SetArtifactLinker makes replies end with "full output" links for the
+// large tool outputs behind them, so users can read what was cut. link
+// returns a viewer URL for an artifact, or "" to leave it out.
+func (a *Adapter) SetArtifactLinker(link func(types.ArtifactID) string) {
+	a.artifactURL = link
+}
+
+// SetSessionSeeder enables seeding the session started by /new with context
+// from the archived one.
+func (a *Adapter) SetSessionSeeder(seed SessionSeeder) {
+	a.seed = seed
+}
+
+// SetAdmins restricts admin commands (/lock, /unlock, /broadcast) to the
+// given Telegram user IDs. With no admins configured, any user may run them
+// except /broadcast.
+func (a *Adapter) SetAdmins(ids []int64) {
+	a.admins = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		a.admins[id] = true
+	}
+}
+
+// SetBroadcaster enables the admin /broadcast command. Because it reaches
+// every chat, it is refused unless admins are configured explicitly.
+func (a *Adapter) SetBroadcaster(b delivery.Broadcaster) {
+	a.broadcast = b
+}
+
+// SetStreaming makes replies appear while they are generated: a message
+// is sent with the first partial text and edited as more arrives.
+func (a *Adapter) SetStreaming(on bool) {
+	a.streaming = on
+}
+
+// isAdmin reports whether the user may run admin commands.
+func (a *Adapter) isAdmin(userID int64) bool {
+	return len(a.admins) == 0 || a.admins[userID]
+}
+
+// Start begins long-polling for Telegram updates and blocks until ctx is
+// cancelled.
 func (a *Adapter) Start(ctx context.Context) {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 30
+	a.pollMu.Lock()
+	a.ctx = ctx
+	a.pollMu.Unlock()
+	if err := a.Restart(); err != nil {
+		log.Printf("start polling error: %v", err)
+		return
+	}
+	<-ctx.Done()
+}
 
-	updates := a.bot.GetUpdatesChan(u)
+// Restart abandons the current poll loop, which may be stuck in a request
+// or a handler, and starts a new one from the last offset. The old loop
+// exits without handling anything once it unblocks.
+func (a *Adapter) Restart() error {
+	a.pollMu.Lock()
+	defer a.pollMu.Unlock()
+	if a.ctx == nil {
+		return fmt.Errorf("telegram adapter not started")
+	}
+	if a.stopPoll != nil {
+		a.stopPoll()
+	}
+	pollCtx, cancel := context.WithCancel(a.ctx)
+	a.stopPoll = cancel
+	a.lastPoll.Store(time.Now().UnixNano())
+	go a.poll(pollCtx, a.ctx)
+	return nil
+}
 
-	for {
-		select {
-		case update := <-updates:
-			if update.Message == nil || update.Message.Text == "" {
-				continue
+// LastPoll returns when the poll loop last finished a getUpdates call or
+// handled an update. An idle loop still reports in every pollTimeout.
+func (a *Adapter) LastPoll() time.Time {
+	return time.Unix(0, a.lastPoll.Load())
+}
+
+// poll fetches and handles updates until pollCtx is cancelled. Handlers
+// run with ctx, so replacing the loop doesn't cancel their work.
+func (a *Adapter) poll(pollCtx, ctx context.Context) {
+	for pollCtx.Err() == nil {
+		u := tgbotapi.NewUpdate(int(a.offset.Load()))
+		u.Timeout = pollTimeout
+		updates, err := a.bot.GetUpdates(u)
+		if pollCtx.Err() != nil {
+			return // replaced or shutting down; the new loop refetches
+		}
+		a.lastPoll.Store(time.Now().UnixNano())
+		if err != nil {
+			log.Printf("get updates error: %v", err)
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-time.After(3 * time.Second):
 			}
-			a.handleMessage(ctx, update.Message)
-		case <-ctx.Done():
-			a.bot.StopReceivingUpdates()
+			continue
+		}
+		for _, update := range updates {
+			if pollCtx.Err() != nil {
+				return
+			}
+			a.offset.Store(int64(update.UpdateID) + 1)
+			a.handleUpdate(ctx, update)
+			a.lastPoll.Store(time.Now().UnixNano())
+		}
+	}
+}
+
+// handleUpdate routes one update to the callback or message handler.
+func (a *Adapter) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		if strings.HasPrefix(update.CallbackQuery.Data, approvalCallbackPrefix) {
+			a.handleApprovalCallback(update.CallbackQuery)
 			return
 		}
+		a.handleCallback(ctx, update.CallbackQuery)
+		return
 	}
+	if update.Message == nil || !hasContent(update.Message) {
+		return
+	}
+	a.handleMessage(ctx, update.Message)
 }
 
 func (a *Adapter) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
@@ -80,72 +251,278 @@ func (a *Adapter) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
 	typingCtx, stopTyping := context.WithCancel(ctx)
 	go a.sendTyping(typingCtx, chatID)
 
-	event := &types.InboundEvent{
+	key := types.TelegramKey(msg.From.ID, msg.Chat.ID)
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+	lang := a.language(ctx, key)
+	files, err := a.collectAttachments(ctx, msg)
+	if err != nil {
+		log.Printf("attachment error: %v", err)
+		a.sendResponse(chatID, i18n.T(lang, "attachment_failed"))
+	}
+	if text == "" && len(files) == 0 {
+		stopTyping()
+		return
+	}
+
+	a.dispatch(ctx, chatID, lang, stopTyping, &types.InboundEvent{
 		Source:     "telegram",
-		SessionKey: buildSessionKey(msg.From.ID, msg.Chat.ID),
+		SessionKey: key,
 		UserID:     strconv.FormatInt(msg.From.ID, 10),
-		Text:       msg.Text,
-	}
+		Text:       text,
+		Metadata:   messageMeta(msg),
+	}, gateway.WithFiles(files...))
+}
 
-	err := a.gateway.HandleInbound(ctx, event, gateway.WithOnComplete(func(response string) {
+// dispatch hands an inbound event to the gateway and sends the reply,
+// calling stopTyping once the run finishes or fails to start.
+func (a *Adapter) dispatch(ctx context.Context, chatID int64, lang string, stopTyping func(), event *types.InboundEvent, extra ...gateway.RunOption) {
+	var stream *replyStream
+	opts := []gateway.RunOption{gateway.WithOnResult(func(result *gateway.RunResult) {
 		stopTyping()
+		response := result.Text
+		if response != "" {
+			response = a.withArtifactLinks(lang, response, result.ToolCalls)
+		}
+		if stream != nil {
+			if rest, ok := stream.finish(response); ok {
+				for _, part := range rest {
+					a.sendResponse(chatID, part)
+				}
+				return
+			}
+		}
+		if response == "" {
+			return // bot decided not to respond
+		}
 		a.sendResponse(chatID, response)
+	}), gateway.WithOnNotice(func(notice string) {
+		a.sendResponse(chatID, notice)
+	})}
+	if a.streaming {
+		stream = a.newReplyStream(chatID)
+		opts = append(opts, gateway.WithOnPartial(stream.update))
+	}
+	if userID, err := strconv.ParseInt(event.UserID, 10, 64); err == nil {
+		opts = append(opts, gateway.WithOnApproval(func(ctx context.Context, req gateway.ApprovalRequest) (bool, error) {
+			return a.askApproval(ctx, chatID, userID, lang, req)
+		}))
+	}
+	opts = append(opts, gateway.WithOnProgress(func(p gateway.Progress) {
+		if stream != nil {
+			stream.progress(p.Status)
+			return
+		}
+		// Refresh the indicator right away: Telegram clears it whenever the
+		// bot sends a message, such as an interim notice.
+		a.bot.Send(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
 	}))
+	err := a.gateway.HandleInbound(ctx, event, append(opts, extra...)...)
 	if err != nil {
+		stopTyping()
+		var locked *gateway.LockedError
+		if errors.As(err, &locked) {
+			a.sendResponse(chatID, lockedNotice(lang, locked))
+			return
+		}
 		log.Printf("handle inbound error: %v", err)
-		a.sendResponse(chatID, "Sorry, I encountered an error processing your message.")
+		a.sendResponse(chatID, i18n.T(lang, "message_failed"))
+	}
+}
+
+// withArtifactLinks appends viewer links for the artifacts stored by a
+// run's tool calls, i.e. the tool outputs too large for the event log.
+func (a *Adapter) withArtifactLinks(lang, response string, calls []gateway.ToolCall) string {
+	if a.artifactURL == nil {
+		return response
+	}
+	var links []string
+	for _, call := range calls {
+		if call.ArtifactID == "" {
+			continue
+		}
+		if link := a.artifactURL(call.ArtifactID); link != "" {
+			links = append(links, i18n.T(lang, "full_output", call.Tool, link))
+		}
+	}
+	if len(links) == 0 {
+		return response
+	}
+	return response + "\n\n" + strings.Join(links, "\n")
+}
+
+// hasContent reports whether a message carries text or a file worth handling.
+func hasContent(msg *tgbotapi.Message) bool {
+	return msg.Text != "" || msg.Document != nil || len(msg.Photo) > 0
+}
+
+// replyTextLimit caps how much of a replied-to message is kept in metadata.
+const replyTextLimit = 200
+
+// messageMeta captures a message's Telegram provenance.
+func messageMeta(msg *tgbotapi.Message) *types.InboundMeta {
+	meta := &types.InboundMeta{
+		Version:   types.InboundSchemaVersion,
+		MessageID: strconv.Itoa(msg.MessageID),
+	}
+	if msg.Chat != nil {
+		meta.ChatID = strconv.FormatInt(msg.Chat.ID, 10)
+		meta.ChatTitle = msg.Chat.Title
+		meta.ChatType = msg.Chat.Type
+	}
+	if msg.From != nil {
+		meta.Username = msg.From.UserName
+	}
+	if reply := msg.ReplyToMessage; reply != nil {
+		ref := &types.ReplyRef{MessageID: strconv.Itoa(reply.MessageID)}
+		if reply.From != nil {
+			ref.UserID = strconv.FormatInt(reply.From.ID, 10)
+		}
+		text := reply.Text
+		if text == "" {
+			text = reply.Caption
+		}
+		if len(text) > replyTextLimit {
+			text = text[:replyTextLimit]
+		}
+		ref.Text = text
+		meta.ReplyTo = ref
+	}
+	return meta
+}
+
+// collectAttachments downloads the message's document or photo. The
+// gateway stores it in the session the message ends up in, which idle
+// rotation may replace.
+func (a *Adapter) collectAttachments(ctx context.Context, msg *tgbotapi.Message) ([]gateway.File, error) {
+	if a.artifacts == nil {
+		return nil, nil
+	}
+
+	var fileID, filename, contentType string
+	switch {
+	case msg.Document != nil:
+		fileID = msg.Document.FileID
+		filename = msg.Document.FileName
+		contentType = msg.Document.MimeType
+	case len(msg.Photo) > 0:
+		// Photos arrive in several sizes; the last is the largest.
+		photo := msg.Photo[len(msg.Photo)-1]
+		fileID = photo.FileID
+		contentType = "image/jpeg"
+	default:
+		return nil, nil
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	data, err := a.download(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return []gateway.File{{Filename: filename, ContentType: contentType, Data: data}}, nil
+}
+
+// download fetches a file from Telegram's file API.
+func (a *Adapter) download(ctx context.Context, fileID string) ([]byte, error) {
+	url, err := a.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("get file URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
 	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxAttachmentSize)
+	}
+	return data, nil
 }
 
 func (a *Adapter) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
+	key := types.TelegramKey(msg.From.ID, msg.Chat.ID)
+	lang := a.language(ctx, key)
 
 	switch msg.Command() {
 	case "start":
-		a.sendResponse(chatID, "Hello! I'm Gopherclaw, your AI assistant. Send me a message to get started.")
+		a.sendResponse(chatID, i18n.T(lang, "start"))
 
 	case "new":
-		key := buildSessionKey(msg.From.ID, msg.Chat.ID)
-		oldSID, err := a.sessions.Rotate(ctx, key)
+		oldSID, err := a.sessions.Rotate(ctx, key, "")
 		if err != nil {
-			a.sendResponse(chatID, "Error creating new session.")
+			a.sendResponse(chatID, i18n.T(lang, "new_failed"))
 			return
 		}
 		if oldSID == "" {
-			a.sendResponse(chatID, "No existing session. Send a message to start one.")
-		} else {
-			a.sendResponse(chatID, "New session started. Previous conversation has been archived.")
+			a.sendResponse(chatID, i18n.T(lang, "new_none"))
+			return
+		}
+		// The language preference belongs to the chat, not the conversation.
+		if lang != "" {
+			newSID, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+			if err == nil {
+				err = gateway.SetLanguage(ctx, a.sessions, newSID, lang)
+			}
+			if err != nil {
+				log.Printf("carry over language error: %v", err)
+			}
 		}
+		if a.seed != nil {
+			newSID, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+			if err == nil {
+				err = a.seed(ctx, oldSID, newSID)
+			}
+			if err == nil {
+				a.sendResponse(chatID, i18n.T(lang, "new_summarized"))
+				return
+			}
+			log.Printf("seed session error: %v", err)
+		}
+		a.sendResponse(chatID, i18n.T(lang, "new_archived"))
 
 	case "status":
-		key := buildSessionKey(msg.From.ID, msg.Chat.ID)
 		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
 		if err != nil {
-			a.sendResponse(chatID, "Error fetching status.")
+			a.sendResponse(chatID, i18n.T(lang, "status_failed"))
 			return
 		}
 		count, err := a.events.Count(ctx, sid)
 		if err != nil {
-			a.sendResponse(chatID, "Error fetching status.")
+			a.sendResponse(chatID, i18n.T(lang, "status_failed"))
 			return
 		}
-		a.sendResponse(chatID, fmt.Sprintf("Session: %s\nMessages: %d", sid, count))
+		a.sendResponse(chatID, i18n.T(lang, "status", sid, count))
 
 	case "context":
-		key := buildSessionKey(msg.From.ID, msg.Chat.ID)
 		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
 		if err != nil {
-			a.sendResponse(chatID, "Error fetching session.")
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
 			return
 		}
 		session, err := a.sessions.Get(ctx, sid)
 		if err != nil {
-			a.sendResponse(chatID, "Error fetching session.")
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
 			return
 		}
 		events, err := a.events.Tail(ctx, sid, 100)
 		if err != nil {
-			a.sendResponse(chatID, "Error loading events.")
+			a.sendResponse(chatID, i18n.T(lang, "events_failed"))
 			return
 		}
 		summary := a.engine.Summarize(session, events, a.toolNames)
@@ -167,20 +544,407 @@ func (a *Adapter) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		)
 		a.sendResponse(chatID, text)
 
+	case "good", "bad":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "feedback_failed"))
+			return
+		}
+		err = feedback.Record(ctx, a.events, sid, "telegram", msg.Command(), strings.TrimSpace(msg.CommandArguments()))
+		if errors.Is(err, feedback.ErrNoResponse) {
+			a.sendResponse(chatID, i18n.T(lang, "feedback_none"))
+			return
+		}
+		if err != nil {
+			log.Printf("record feedback error: %v", err)
+			a.sendResponse(chatID, i18n.T(lang, "feedback_failed"))
+			return
+		}
+		a.sendResponse(chatID, i18n.T(lang, "feedback_thanks"))
+
+	case "lock", "unlock":
+		if !a.isAdmin(msg.From.ID) {
+			a.sendResponse(chatID, i18n.T(lang, "lock_admin_only"))
+			return
+		}
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		locked := msg.Command() == "lock"
+		if err := gateway.SetLocked(ctx, a.sessions, sid, locked, strings.TrimSpace(msg.CommandArguments())); err != nil {
+			log.Printf("set session lock error: %v", err)
+			a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+			return
+		}
+		if locked {
+			a.sendResponse(chatID, i18n.T(lang, "lock_done"))
+		} else {
+			a.sendResponse(chatID, i18n.T(lang, "unlock_done"))
+		}
+
+	case "sys":
+		if !a.isAdmin(msg.From.ID) {
+			a.sendResponse(chatID, i18n.T(lang, "sys_admin_only"))
+			return
+		}
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		arg := strings.TrimSpace(msg.CommandArguments())
+		switch {
+		case arg == "":
+			sess, err := a.sessions.Get(ctx, sid)
+			if err != nil {
+				a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+				return
+			}
+			if len(sess.Instructions) == 0 {
+				a.sendResponse(chatID, i18n.T(lang, "sys_none"))
+				return
+			}
+			var b strings.Builder
+			b.WriteString(i18n.T(lang, "sys_header"))
+			for i, text := range sess.Instructions {
+				fmt.Fprintf(&b, "\n%d. %s", i+1, text)
+			}
+			a.sendResponse(chatID, b.String())
+		case strings.EqualFold(arg, "clear"):
+			if err := gateway.ClearInstructions(ctx, a.sessions, a.events, sid, "telegram"); err != nil {
+				log.Printf("clear instructions error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(lang, "sys_cleared"))
+		default:
+			if err := gateway.AddInstruction(ctx, a.sessions, a.events, sid, "telegram", arg); err != nil {
+				log.Printf("add instruction error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(lang, "sys_added"))
+		}
+
+	case "tools":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		args := strings.Fields(msg.CommandArguments())
+		if len(args) == 0 {
+			sess, err := a.sessions.Get(ctx, sid)
+			if err != nil {
+				a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+				return
+			}
+			a.sendResponse(chatID, a.formatTools(sess, lang))
+			return
+		}
+		if len(args) != 2 || (args[0] != "on" && args[0] != "off" && args[0] != "ask") {
+			a.sendResponse(chatID, i18n.T(lang, "tools_usage"))
+			return
+		}
+		tool := args[1]
+		if !a.hasTool(tool) {
+			a.sendResponse(chatID, i18n.T(lang, "tool_unknown", tool))
+			return
+		}
+		// Asking first only restricts a tool, so anyone may set it.
+		if args[0] == "ask" {
+			if err := gateway.SetToolConfirm(ctx, a.sessions, sid, tool, true); err != nil {
+				log.Printf("set tool policy error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(lang, "tool_ask_set", tool))
+			return
+		}
+		if gateway.IsDangerousTool(tool) && !a.isAdmin(msg.From.ID) {
+			a.sendResponse(chatID, i18n.T(lang, "tool_admin_only", tool))
+			return
+		}
+		enabled := args[0] == "on"
+		err = gateway.SetToolEnabled(ctx, a.sessions, sid, tool, enabled)
+		if err == nil && enabled {
+			err = gateway.SetToolConfirm(ctx, a.sessions, sid, tool, false)
+		}
+		if err != nil {
+			log.Printf("set tool policy error: %v", err)
+			a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+			return
+		}
+		if enabled {
+			a.sendResponse(chatID, i18n.T(lang, "tool_enabled", tool))
+		} else {
+			a.sendResponse(chatID, i18n.T(lang, "tool_disabled", tool))
+		}
+
+	case "dryrun":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		switch arg := strings.TrimSpace(msg.CommandArguments()); arg {
+		case "":
+			sess, err := a.sessions.Get(ctx, sid)
+			if err != nil {
+				a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+				return
+			}
+			if sess.DryRun {
+				a.sendResponse(chatID, i18n.T(lang, "dryrun_is_on", len(sess.Pending)))
+			} else {
+				a.sendResponse(chatID, i18n.T(lang, "dryrun_is_off"))
+			}
+		case "on", "off":
+			if err := gateway.SetDryRun(ctx, a.sessions, sid, arg == "on"); err != nil {
+				log.Printf("set dry run error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(lang, "dryrun_"+arg))
+		default:
+			a.sendResponse(chatID, i18n.T(lang, "dryrun_usage"))
+		}
+
+	case "confirm":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		sess, err := a.sessions.Get(ctx, sid)
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		if len(sess.Pending) == 0 {
+			a.sendResponse(chatID, i18n.T(lang, "confirm_none"))
+			return
+		}
+		typingCtx, stopTyping := context.WithCancel(ctx)
+		go a.sendTyping(typingCtx, chatID)
+		a.dispatch(ctx, chatID, lang, stopTyping, &types.InboundEvent{
+			Source:     "telegram",
+			SessionKey: key,
+			UserID:     strconv.FormatInt(msg.From.ID, 10),
+			Text:       "Confirmed. Run the planned tool calls and report the results.",
+			Metadata:   messageMeta(msg),
+			Confirm:    true,
+		})
+
+	case "cancel":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		dropped, err := gateway.TakePending(ctx, a.sessions, sid)
+		if err != nil {
+			log.Printf("cancel pending error: %v", err)
+			a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+			return
+		}
+		if len(dropped) == 0 {
+			a.sendResponse(chatID, i18n.T(lang, "confirm_none"))
+			return
+		}
+		a.sendResponse(chatID, i18n.T(lang, "cancel_done", len(dropped)))
+
+	case "broadcast":
+		if a.broadcast == nil {
+			a.sendResponse(chatID, i18n.T(lang, "broadcast_unavailable"))
+			return
+		}
+		if len(a.admins) == 0 || !a.isAdmin(msg.From.ID) {
+			a.sendResponse(chatID, i18n.T(lang, "broadcast_admin_only"))
+			return
+		}
+		notice := strings.TrimSpace(msg.CommandArguments())
+		if notice == "" {
+			a.sendResponse(chatID, i18n.T(lang, "broadcast_usage"))
+			return
+		}
+		// Sending is rate limited; don't hold up the update loop.
+		go func() {
+			result, err := a.broadcast(ctx, notice)
+			if err != nil {
+				log.Printf("broadcast error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "broadcast_failed"))
+				return
+			}
+			reply := i18n.T(lang, "broadcast_sent", result.Sent)
+			if len(result.Failed) > 0 {
+				reply = i18n.T(lang, "broadcast_some_failed", result.Sent, len(result.Failed))
+			}
+			a.sendResponse(chatID, reply)
+		}()
+
+	case "language":
+		sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "session_failed"))
+			return
+		}
+		arg := strings.TrimSpace(msg.CommandArguments())
+		codes := strings.Join(i18n.Codes(), ", ")
+		switch {
+		case arg == "" && lang == "":
+			a.sendResponse(chatID, i18n.T(lang, "language_unset", codes))
+		case arg == "":
+			a.sendResponse(chatID, i18n.T(lang, "language_current", i18n.Name(lang)))
+		case strings.EqualFold(arg, "auto"):
+			if err := gateway.SetLanguage(ctx, a.sessions, sid, ""); err != nil {
+				log.Printf("set language error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(i18n.Default, "language_cleared"))
+		default:
+			code, ok := i18n.Normalize(arg)
+			if !ok {
+				a.sendResponse(chatID, i18n.T(lang, "language_unknown", arg, codes))
+				return
+			}
+			if err := gateway.SetLanguage(ctx, a.sessions, sid, code); err != nil {
+				log.Printf("set language error: %v", err)
+				a.sendResponse(chatID, i18n.T(lang, "update_failed"))
+				return
+			}
+			a.sendResponse(chatID, i18n.T(code, "language_set", i18n.Name(code)))
+		}
+
+	case "m":
+		if a.macros == nil {
+			a.sendResponse(chatID, i18n.T(lang, "macros_unavailable"))
+			return
+		}
+		name, args, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+		if name == "" {
+			a.sendResponse(chatID, a.formatMacros(lang))
+			return
+		}
+		macro, err := a.macros.Get(name)
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "macro_unknown", name))
+			return
+		}
+		prompt, err := macro.Expand(args)
+		if err != nil {
+			a.sendResponse(chatID, i18n.T(lang, "macro_failed", err))
+			return
+		}
+		// Handle the expansion as if the user had typed it.
+		expanded := *msg
+		expanded.Text = prompt
+		expanded.Entities = nil
+		a.handleMessage(ctx, &expanded)
+
+	case "usage":
+		a.sendResponse(chatID, a.formatUsage(ctx, key, lang))
+
 	case "memories":
 		data, err := os.ReadFile(a.memoryPath)
 		if err != nil || strings.TrimSpace(string(data)) == "" {
-			a.sendResponse(chatID, "No memories stored yet.")
+			a.sendResponse(chatID, i18n.T(lang, "memories_none"))
 			return
 		}
-		a.sendResponse(chatID, fmt.Sprintf("*Stored Memories:*\n```\n%s```", string(data)))
+		a.sendResponse(chatID, fmt.Sprintf("%s\n```\n%s```", i18n.T(lang, "memories_header"), string(data)))
 
 	default:
-		a.sendResponse(chatID, "Unknown command. Available: /start, /new, /status, /context, /memories")
+		a.sendResponse(chatID, i18n.T(lang, "unknown_command", "/start, /new, /status, /context, /usage, /memories, /good, /bad, /tools, /dryrun, /confirm, /cancel, /lock, /unlock, /sys, /broadcast, /language, /m"))
+	}
+}
+
+// language returns the preferred language of the chat's current session,
+// or "" when none is set. It looks the session up without creating one.
+func (a *Adapter) language(ctx context.Context, key types.SessionKey) string {
+	sessions, err := a.sessions.List(ctx)
+	if err != nil {
+		return ""
+	}
+	for _, sess := range sessions {
+		if sess.SessionKey == key {
+			return sess.Language
+		}
+	}
+	return ""
+}
+
+// lockedNotice renders a LockedError in the chat's language.
+func lockedNotice(lang string, locked *gateway.LockedError) string {
+	if locked.Reason != "" {
+		return i18n.T(lang, "locked_reason", locked.Reason)
+	}
+	return i18n.T(lang, "locked")
+}
+
+// formatMacros lists the stored macros for /m.
+func (a *Adapter) formatMacros(lang string) string {
+	macros, err := a.macros.List()
+	if err != nil {
+		log.Printf("list macros error: %v", err)
+		return i18n.T(lang, "macros_unavailable")
+	}
+	if len(macros) == 0 {
+		return i18n.T(lang, "macros_none")
 	}
+	var b strings.Builder
+	b.WriteString(i18n.T(lang, "macros_header") + "\n")
+	for _, m := range macros {
+		b.WriteString("/m " + m.Name)
+		if m.Description != "" {
+			b.WriteString(" — " + m.Description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n" + i18n.T(lang, "macros_footer"))
+	return b.String()
+}
+
+// hasTool reports whether a tool with the given name is registered.
+func (a *Adapter) hasTool(name string) bool {
+	for _, t := range a.toolNames {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTools renders the session's tool policy for /tools.
+func (a *Adapter) formatTools(sess *types.SessionIndex, lang string) string {
+	names := append([]string(nil), a.toolNames...)
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(i18n.T(lang, "tools_header") + "\n")
+	for _, name := range names {
+		state := i18n.T(lang, "tool_on")
+		if !gateway.ToolEnabled(sess, name) {
+			state = i18n.T(lang, "tool_off")
+		} else if gateway.NeedsConfirmation(sess, name) {
+			state = i18n.T(lang, "tool_ask")
+		}
+		fmt.Fprintf(&b, "%s: %s", name, state)
+		if gateway.IsDangerousTool(name) {
+			b.WriteString(" (admin)")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n" + i18n.T(lang, "tools_footer"))
+	return b.String()
 }
 
-func (a *Adapter) sendResponse(chatID int64, text string) {
+// sendResponse sends text in as many messages as it takes. It returns the
+// first send error after logging it; callers replying to a user can ignore it.
+func (a *Adapter) sendResponse(chatID int64, text string) error {
+	var firstErr error
 	parts := splitMessage(text)
 	for _, part := range parts {
 		msg := tgbotapi.NewMessage(chatID, part)
@@ -190,9 +954,13 @@ func (a *Adapter) sendResponse(chatID int64, text string) {
 			msg.ParseMode = ""
 			if _, err := a.bot.Send(msg); err != nil {
 				log.Printf("send message error: %v", err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("send message: %w", err)
+				}
 			}
 		}
 	}
+	return firstErr
 }
 
 // sendTyping sends "typing..." indicator every 4 seconds until ctx is cancelled.
@@ -215,19 +983,14 @@ func (a *Adapter) sendTyping(ctx context.Context, chatID int64) {
 // SendTo delivers a message to a Telegram chat identified by session key.
 // Session key format: "telegram:<userID>:<chatID>"
 func (a *Adapter) SendTo(sessionKey, message string) error {
-	parts := strings.Split(sessionKey, ":")
-	if len(parts) != 3 || parts[0] != "telegram" {
-		return fmt.Errorf("invalid telegram session key: %s", sessionKey)
-	}
-	chatID, err := strconv.ParseInt(parts[2], 10, 64)
+	_, chatID, err := types.SessionKey(sessionKey).TelegramChat()
 	if err != nil {
-		return fmt.Errorf("parse chat ID: %w", err)
+		return err
 	}
 	if message == "" {
 		return nil // bot decided not to respond
 	}
-	a.sendResponse(chatID, message)
-	return nil
+	return a.sendResponse(chatID, message)
 }
 
 func splitMessage(text string) []string {
@@ -245,10 +1008,3 @@ func splitMessage(text string) []string {
 	}
 	return parts
 }
-
-func buildSessionKey(userID, chatID int64) types.SessionKey {
-	return types.NewSessionKey("telegram",
-		strconv.FormatInt(userID, 10),
-		strconv.FormatInt(chatID, 10),
-	)
-}
@@ -1,8 +1,14 @@
 package telegram
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/types"
 )
 
 func TestSplitMessage(t *testing.T) {
@@ -27,9 +33,104 @@ func TestSplitMessageLong(t *testing.T) {
 	}
 }
 
-func TestBuildSessionKey(t *testing.T) {
-	key := buildSessionKey(12345, 67890)
+func TestSessionKey(t *testing.T) {
+	key := types.TelegramKey(12345, 67890)
 	if string(key) != "telegram:12345:67890" {
 		t.Errorf("expected 'telegram:12345:67890', got %q", key)
 	}
 }
+
+func TestMessageMeta(t *testing.T) {
+	msg := &tgbotapi.Message{
+		MessageID: 42,
+		From:      &tgbotapi.User{ID: 7, UserName: "alice"},
+		Chat:      &tgbotapi.Chat{ID: -100, Title: "Team", Type: "group"},
+		Text:      "what about this?",
+		ReplyToMessage: &tgbotapi.Message{
+			MessageID: 41,
+			From:      &tgbotapi.User{ID: 9},
+			Text:      strings.Repeat("x", 500),
+		},
+	}
+	meta := messageMeta(msg)
+	if meta.Version != types.InboundSchemaVersion {
+		t.Errorf("expected version %d, got %d", types.InboundSchemaVersion, meta.Version)
+	}
+	if meta.MessageID != "42" || meta.ChatID != "-100" || meta.ChatTitle != "Team" || meta.ChatType != "group" || meta.Username != "alice" {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+	if meta.ReplyTo == nil || meta.ReplyTo.MessageID != "41" || meta.ReplyTo.UserID != "9" {
+		t.Fatalf("unexpected reply ref: %+v", meta.ReplyTo)
+	}
+	if len(meta.ReplyTo.Text) != replyTextLimit {
+		t.Errorf("expected reply text capped at %d, got %d", replyTextLimit, len(meta.ReplyTo.Text))
+	}
+}
+
+func TestParseReminderCallback(t *testing.T) {
+	tests := []struct {
+		data   string
+		action string
+		id     string
+		delay  time.Duration
+		ok     bool
+	}{
+		{"rem:done:ab12cd34", "done", "ab12cd34", 0, true},
+		{"rem:snooze:ab12cd34:10m", "snooze", "ab12cd34", 10 * time.Minute, true},
+		{"rem:snooze:ab12cd34:1d", "snooze", "ab12cd34", 24 * time.Hour, true},
+		{"rem:snooze:ab12cd34:3w", "", "", 0, false},
+		{"rem:done:", "", "", 0, false},
+		{"other:done:ab12cd34", "", "", 0, false},
+	}
+	for _, tt := range tests {
+		action, id, delay, ok := parseReminderCallback(tt.data)
+		if action != tt.action || id != tt.id || delay != tt.delay || ok != tt.ok {
+			t.Errorf("parseReminderCallback(%q) = %q, %q, %v, %v", tt.data, action, id, delay, ok)
+		}
+	}
+}
+
+func TestParseApprovalCallback(t *testing.T) {
+	tests := []struct {
+		data     string
+		approved bool
+		id       types.EventID
+		ok       bool
+	}{
+		{"apv:yes:ab12cd34", true, "ab12cd34", true},
+		{"apv:no:ab12cd34", false, "ab12cd34", true},
+		{"apv:maybe:ab12cd34", false, "", false},
+		{"apv:yes:", false, "", false},
+		{"rem:done:ab12cd34", false, "", false},
+	}
+	for _, tt := range tests {
+		approved, id, ok := parseApprovalCallback(tt.data)
+		if approved != tt.approved || id != tt.id || ok != tt.ok {
+			t.Errorf("parseApprovalCallback(%q) = %v, %q, %v", tt.data, approved, id, ok)
+		}
+	}
+}
+
+func TestDescribeCall(t *testing.T) {
+	if got := describeCall(json.RawMessage(`{"command":"df -h","timeout_seconds":5}`)); got != "df -h" {
+		t.Errorf("got %q", got)
+	}
+	if got := describeCall(json.RawMessage(`{"text":"note"}`)); got != `{"text":"note"}` {
+		t.Errorf("got %q", got)
+	}
+	long := strings.Repeat("x", 2000)
+	if got := describeCall(json.RawMessage(`{"command":"` + long + `"}`)); got != long {
+		t.Errorf("long command shown as %d bytes, want all 2000", len(got))
+	}
+}
+
+func TestFitsApproval(t *testing.T) {
+	for _, lang := range []string{"en", "es"} {
+		if !fitsApproval(lang, i18n.T(lang, "approval_request", "bash", strings.Repeat("x", 3900))) {
+			t.Errorf("%s: a 3900-byte command should fit", lang)
+		}
+		if fitsApproval(lang, i18n.T(lang, "approval_request", "bash", strings.Repeat("x", maxTelegramMessage))) {
+			t.Errorf("%s: a command as long as a message should not fit", lang)
+		}
+	}
+}
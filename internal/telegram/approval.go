@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// approvalCallbackPrefix marks inline button data answering a tool call's
+// approval request.
+const approvalCallbackPrefix = "apv:"
+
+// pendingApproval is an approval request waiting for a button press.
+type pendingApproval struct {
+	chatID int64
+	// userID sent the message behind the run; they and the admins may
+	// answer.
+	userID int64
+	lang   string
+	text   string
+	answer chan bool
+}
+
+// askApproval sends a tool call's approval request to the chat with
+// Approve and Deny buttons, and waits for a press or for ctx to be done.
+// A call too long to show in full, with room for its outcome, is not put
+// to the user: they are told so and the call is denied.
+func (a *Adapter) askApproval(ctx context.Context, chatID, userID int64, lang string, req gateway.ApprovalRequest) (bool, error) {
+	text := i18n.T(lang, "approval_request", req.Tool, describeCall(req.Arguments))
+	if !fitsApproval(lang, text) {
+		if _, err := a.bot.Send(tgbotapi.NewMessage(chatID, i18n.T(lang, "approval_too_long", req.Tool))); err != nil {
+			log.Printf("send approval notice error: %v", err)
+		}
+		return false, fmt.Errorf("%w: the %s call is too long to show the user in full", gateway.ErrApprovalUnavailable, req.Tool)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "approval_approve"), approvalCallbackPrefix+"yes:"+string(req.ID)),
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "approval_deny"), approvalCallbackPrefix+"no:"+string(req.ID)),
+	))
+
+	p := &pendingApproval{chatID: chatID, userID: userID, lang: lang, text: text, answer: make(chan bool, 1)}
+	a.approvalMu.Lock()
+	if a.approvals == nil {
+		a.approvals = make(map[types.EventID]*pendingApproval)
+	}
+	a.approvals[req.ID] = p
+	a.approvalMu.Unlock()
+	defer a.takeApproval(req.ID)
+
+	sent, err := a.bot.Send(msg)
+	if err != nil {
+		return false, fmt.Errorf("send approval request: %w", err)
+	}
+	select {
+	case approved := <-p.answer:
+		return approved, nil
+	case <-ctx.Done():
+		// Take it first, so a late press finds it gone.
+		if a.takeApproval(req.ID) != nil {
+			a.closeApproval(p, sent.MessageID, i18n.T(lang, "approval_expired"))
+		}
+		return false, ctx.Err()
+	}
+}
+
+// takeApproval removes and returns a pending approval request, or nil if
+// it was already answered or abandoned.
+func (a *Adapter) takeApproval(id types.EventID) *pendingApproval {
+	a.approvalMu.Lock()
+	defer a.approvalMu.Unlock()
+	p := a.approvals[id]
+	delete(a.approvals, id)
+	return p
+}
+
+// handleApprovalCallback handles a press on an approval request's button.
+// Only the user whose message started the run, or an admin, may answer.
+func (a *Adapter) handleApprovalCallback(cb *tgbotapi.CallbackQuery) {
+	answer, id, ok := parseApprovalCallback(cb.Data)
+	if !ok || cb.Message == nil {
+		a.answerCallback(cb.ID, "")
+		return
+	}
+	a.approvalMu.Lock()
+	p := a.approvals[id]
+	if p != nil && p.chatID == cb.Message.Chat.ID && (cb.From.ID == p.userID || a.isAdmin(cb.From.ID)) {
+		delete(a.approvals, id)
+	}
+	a.approvalMu.Unlock()
+
+	switch {
+	case p == nil || p.chatID != cb.Message.Chat.ID:
+		a.answerCallback(cb.ID, i18n.T(a.language(context.Background(), types.TelegramKey(cb.From.ID, cb.Message.Chat.ID)), "approval_gone"))
+		return
+	case cb.From.ID != p.userID && !a.isAdmin(cb.From.ID):
+		a.answerCallback(cb.ID, i18n.T(p.lang, "approval_not_yours"))
+		return
+	}
+	p.answer <- answer
+	status := i18n.T(p.lang, "approval_denied")
+	if answer {
+		status = i18n.T(p.lang, "approval_approved")
+	}
+	a.answerCallback(cb.ID, status)
+	a.closeApproval(p, cb.Message.MessageID, status)
+}
+
+// fitsApproval reports whether an approval request's text fits in one
+// message, along with the longest outcome closeApproval may add to it.
+func fitsApproval(lang, text string) bool {
+	status := 0
+	for _, key := range []string{"approval_approved", "approval_denied", "approval_expired"} {
+		status = max(status, len(i18n.T(lang, key)))
+	}
+	return len(text)+len("\n")+status <= maxTelegramMessage
+}
+
+// closeApproval replaces an approval request's buttons with its outcome.
+func (a *Adapter) closeApproval(p *pendingApproval, messageID int, status string) {
+	edit := tgbotapi.NewEditMessageText(p.chatID, messageID, p.text+"\n"+status)
+	if _, err := a.bot.Request(edit); err != nil {
+		log.Printf("edit approval message error: %v", err)
+	}
+}
+
+// parseApprovalCallback decodes approval button data: "apv:yes:<id>" or
+// "apv:no:<id>".
+func parseApprovalCallback(data string) (approved bool, id types.EventID, ok bool) {
+	rest, found := strings.CutPrefix(data, approvalCallbackPrefix)
+	if !found {
+		return false, "", false
+	}
+	answer, rawID, found := strings.Cut(rest, ":")
+	if !found || rawID == "" || (answer != "yes" && answer != "no") {
+		return false, "", false
+	}
+	return answer == "yes", types.EventID(rawID), true
+}
+
+// describeCall renders a tool call's arguments for an approval request:
+// a command as it is, anything else as its JSON.
+func describeCall(args json.RawMessage) string {
+	var p struct {
+		Command string `json:"command"`
+	}
+	json.Unmarshal(args, &p)
+	text := string(args)
+	if p.Command != "" {
+		text = p.Command
+	}
+	return text
+}
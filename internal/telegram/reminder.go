@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// reminderCallbackPrefix marks inline button data belonging to reminders.
+const reminderCallbackPrefix = "rem:"
+
+// snoozeOptions are the snooze buttons under a fired reminder, in order.
+var snoozeOptions = []struct {
+	code  string
+	key   string
+	delay time.Duration
+}{
+	{"10m", "reminder_snooze_10m", 10 * time.Minute},
+	{"1h", "reminder_snooze_1h", time.Hour},
+	{"1d", "reminder_tomorrow", 24 * time.Hour},
+}
+
+// SetReminderStore enables the snooze and done buttons on reminders sent
+// with SendReminder.
+func (a *Adapter) SetReminderStore(reminders *state.ReminderStore) {
+	a.reminders = reminders
+}
+
+// SendReminder delivers a fired reminder to its chat with buttons to snooze
+// or dismiss it.
+func (a *Adapter) SendReminder(r *state.Reminder) error {
+	_, chatID, err := types.SessionKey(r.SessionKey).TelegramChat()
+	if err != nil {
+		return err
+	}
+	lang := a.language(context.Background(), types.SessionKey(r.SessionKey))
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, opt := range snoozeOptions {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, opt.key), reminderCallbackPrefix+"snooze:"+r.ID+":"+opt.code))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "reminder_done"), reminderCallbackPrefix+"done:"+r.ID))
+
+	msg := tgbotapi.NewMessage(chatID, i18n.T(lang, "reminder_fired", r.Text))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	if _, err := a.bot.Send(msg); err != nil {
+		return fmt.Errorf("send reminder: %w", err)
+	}
+	return nil
+}
+
+// handleCallback handles a press on a reminder's inline button. The
+// reminder must belong to the chat the button was pressed in.
+func (a *Adapter) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
+	action, id, delay, ok := parseReminderCallback(cb.Data)
+	if !ok || a.reminders == nil || cb.Message == nil {
+		a.answerCallback(cb.ID, "")
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	lang := a.language(ctx, types.TelegramKey(cb.From.ID, chatID))
+
+	r, err := a.reminders.Get(id)
+	if err != nil {
+		a.answerCallback(cb.ID, i18n.T(lang, "reminder_gone"))
+		return
+	}
+	if _, owner, err := types.SessionKey(r.SessionKey).TelegramChat(); err != nil || owner != chatID {
+		a.answerCallback(cb.ID, i18n.T(lang, "reminder_gone"))
+		return
+	}
+
+	var status string
+	switch action {
+	case "snooze":
+		snoozed, err := a.reminders.Snooze(id, time.Now().Add(delay))
+		if err != nil {
+			log.Printf("snooze reminder error: %v", err)
+			a.answerCallback(cb.ID, i18n.T(lang, "update_failed"))
+			return
+		}
+		status = i18n.T(lang, "reminder_snoozed", snoozed.Due.Format("Mon 15:04"))
+	case "done":
+		// A recurring reminder keeps its schedule; done only dismisses this
+		// occurrence.
+		if r.Repeat == "" {
+			if err := a.reminders.Remove(id); err != nil {
+				log.Printf("remove reminder error: %v", err)
+			}
+		}
+		status = i18n.T(lang, "reminder_dismissed")
+	}
+	a.answerCallback(cb.ID, status)
+
+	edit := tgbotapi.NewEditMessageText(chatID, cb.Message.MessageID, i18n.T(lang, "reminder_fired", r.Text)+"\n"+status)
+	if _, err := a.bot.Request(edit); err != nil {
+		log.Printf("edit reminder message error: %v", err)
+	}
+}
+
+func (a *Adapter) answerCallback(id, text string) {
+	if _, err := a.bot.Request(tgbotapi.NewCallback(id, text)); err != nil {
+		log.Printf("answer callback error: %v", err)
+	}
+}
+
+// parseReminderCallback decodes reminder button data: "rem:done:<id>" or
+// "rem:snooze:<id>:<option>".
+func parseReminderCallback(data string) (action, id string, delay time.Duration, ok bool) {
+	rest, found := strings.CutPrefix(data, reminderCallbackPrefix)
+	if !found {
+		return "", "", 0, false
+	}
+	parts := strings.Split(rest, ":")
+	switch {
+	case len(parts) == 2 && parts[0] == "done" && parts[1] != "":
+		return "done", parts[1], 0, true
+	case len(parts) == 3 && parts[0] == "snooze" && parts[1] != "":
+		for _, opt := range snoozeOptions {
+			if opt.code == parts[2] {
+				return "snooze", parts[1], opt.delay, true
+			}
+		}
+	}
+	return "", "", 0, false
+}
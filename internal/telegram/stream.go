@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// streamEditInterval is the minimum time between edits of a streamed reply.
+// Telegram rate-limits edits per chat, and more frequent updates wouldn't
+// read any better.
+const streamEditInterval = 1500 * time.Millisecond
+
+// streamCursor marks a streamed reply as still being written.
+const streamCursor = " …"
+
+// streamProgressMark starts a progress line shown in a streamed message.
+const streamProgressMark = "⏳ "
+
+// replyStream shows a reply while it is generated: the first partial text
+// is sent as a new message, which later partials edit at most once per
+// interval. Partials are sent without parse mode, since half-written
+// Markdown often fails to parse; finish applies the final formatting.
+type replyStream struct {
+	send     func(text string) (int, error)
+	edit     func(id int, text string, markdown bool) error
+	del      func(id int)
+	interval time.Duration
+
+	mu     sync.Mutex
+	id     int // message ID, 0 until the first partial is sent
+	latest string
+	shown  string
+	last   time.Time
+	timer  *time.Timer
+	closed bool
+}
+
+// newReplyStream creates a replyStream for a chat.
+func (a *Adapter) newReplyStream(chatID int64) *replyStream {
+	return &replyStream{
+		send: func(text string) (int, error) {
+			m, err := a.bot.Send(tgbotapi.NewMessage(chatID, text))
+			return m.MessageID, err
+		},
+		edit: func(id int, text string, markdown bool) error {
+			e := tgbotapi.NewEditMessageText(chatID, id, text)
+			if markdown {
+				e.ParseMode = "Markdown"
+			}
+			_, err := a.bot.Request(e)
+			return err
+		},
+		del: func(id int) {
+			if _, err := a.bot.Request(tgbotapi.NewDeleteMessage(chatID, id)); err != nil {
+				log.Printf("delete streamed message error: %v", err)
+			}
+		},
+		interval: streamEditInterval,
+	}
+}
+
+// update records the reply text generated so far and shows it, immediately
+// for the first partial and otherwise once the interval since the last
+// edit has passed.
+func (s *replyStream) update(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.latest = text
+	if s.id == 0 {
+		s.flushLocked()
+		return
+	}
+	if s.timer != nil {
+		return // an edit is already scheduled and will pick up latest
+	}
+	wait := s.interval - time.Since(s.last)
+	if wait <= 0 {
+		s.flushLocked()
+		return
+	}
+	s.timer = time.AfterFunc(wait, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.timer = nil
+		if !s.closed {
+			s.flushLocked()
+		}
+	})
+}
+
+// progress shows what the run is doing in place of reply text, e.g. while
+// a tool runs. The next partial replaces it.
+func (s *replyStream) progress(status string) {
+	s.update(streamProgressMark + status)
+}
+
+func (s *replyStream) flushLocked() {
+	text := streamPreview(s.latest)
+	if text == s.shown {
+		return
+	}
+	if s.id == 0 {
+		id, err := s.send(text)
+		if err != nil {
+			log.Printf("send streamed message error: %v", err)
+			s.closed = true // fall back to a normal reply
+			return
+		}
+		s.id = id
+	} else if err := s.edit(s.id, text, false); err != nil {
+		log.Printf("edit streamed message error: %v", err)
+	}
+	s.shown = text
+	s.last = time.Now()
+}
+
+// finish replaces the streamed message with the final reply and returns
+// the parts that didn't fit in it, to be sent as further messages. An
+// empty reply deletes the message. It reports false if nothing was
+// streamed, in which case the caller should send the reply normally.
+func (s *replyStream) finish(final string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.id == 0 {
+		return nil, false
+	}
+	if final == "" {
+		s.del(s.id)
+		return nil, true
+	}
+	parts := splitMessage(final)
+	if err := s.edit(s.id, parts[0], true); err != nil {
+		// Retry without markdown if it fails
+		if err := s.edit(s.id, parts[0], false); err != nil {
+			log.Printf("edit streamed message error: %v", err)
+		}
+	}
+	return parts[1:], true
+}
+
+// streamPreview is text as shown while streaming: with a cursor, and cut
+// to fit a single message.
+func streamPreview(text string) string {
+	if n := maxTelegramMessage - len(streamCursor); len(text) > n {
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+	return text + streamCursor
+}
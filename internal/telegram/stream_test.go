@@ -0,0 +1,123 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChat records what a replyStream sends, edits and deletes.
+type fakeChat struct {
+	mu      sync.Mutex
+	sent    []string
+	edits   []string
+	deleted bool
+}
+
+func (c *fakeChat) stream(interval time.Duration) *replyStream {
+	return &replyStream{
+		send: func(text string) (int, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.sent = append(c.sent, text)
+			return 42, nil
+		},
+		edit: func(id int, text string, markdown bool) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if id != 42 {
+				return errors.New("unknown message")
+			}
+			c.edits = append(c.edits, text)
+			return nil
+		},
+		del: func(id int) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.deleted = true
+		},
+		interval: interval,
+	}
+}
+
+func (c *fakeChat) snapshot() (sent, edits []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.sent...), append([]string(nil), c.edits...)
+}
+
+func TestReplyStreamThrottlesEdits(t *testing.T) {
+	chat := &fakeChat{}
+	s := chat.stream(50 * time.Millisecond)
+
+	s.update("Hello")
+	s.update("Hello there")
+	s.update("Hello there, friend")
+	sent, edits := chat.snapshot()
+	if len(sent) != 1 || sent[0] != "Hello"+streamCursor || len(edits) != 0 {
+		t.Fatalf("expected only the placeholder at first, got sent %q edits %q", sent, edits)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	_, edits = chat.snapshot()
+	if len(edits) != 1 || edits[0] != "Hello there, friend"+streamCursor {
+		t.Fatalf("expected one throttled edit with the latest text, got %q", edits)
+	}
+
+	rest, ok := s.finish("Hello there, friend!")
+	if !ok || len(rest) != 0 {
+		t.Fatalf("finish = %q, %v", rest, ok)
+	}
+	s.update("late partial")
+	time.Sleep(100 * time.Millisecond)
+	sent, edits = chat.snapshot()
+	if len(sent) != 1 || len(edits) != 2 || edits[1] != "Hello there, friend!" {
+		t.Errorf("expected the final edit last, got sent %q edits %q", sent, edits)
+	}
+}
+
+func TestReplyStreamFinish(t *testing.T) {
+	chat := &fakeChat{}
+	if _, ok := chat.stream(time.Second).finish("reply"); ok {
+		t.Error("finish without partials should leave the reply to the caller")
+	}
+
+	s := chat.stream(time.Second)
+	s.update("Let me check")
+	if _, ok := s.finish(""); !ok || !chat.deleted {
+		t.Error("an empty reply should delete the streamed message")
+	}
+
+	chat = &fakeChat{}
+	s = chat.stream(time.Second)
+	s.update("Writing")
+	long := strings.Repeat("a", maxTelegramMessage+10)
+	rest, ok := s.finish(long)
+	_, edits := chat.snapshot()
+	if !ok || len(rest) != 1 || len(rest[0]) != 10 || len(edits) != 1 || len(edits[0]) != maxTelegramMessage {
+		t.Errorf("expected the first part edited in and the rest returned, got %d edits, rest %d", len(edits), len(rest))
+	}
+}
+
+func TestReplyStreamProgress(t *testing.T) {
+	chat := &fakeChat{}
+	s := chat.stream(0)
+	s.progress("running commands")
+	s.update("Disk usage is fine")
+	sent, edits := chat.snapshot()
+	if len(sent) != 1 || sent[0] != "⏳ running commands"+streamCursor {
+		t.Errorf("expected the progress line as the placeholder, sent %q", sent)
+	}
+	if len(edits) != 1 || edits[0] != "Disk usage is fine"+streamCursor {
+		t.Errorf("expected reply text to replace the progress line, edits %q", edits)
+	}
+}
+
+func TestStreamPreviewFitsOneMessage(t *testing.T) {
+	got := streamPreview(strings.Repeat("é", maxTelegramMessage))
+	if len(got) > maxTelegramMessage || !strings.HasSuffix(got, streamCursor) || !strings.HasPrefix(got, "é") {
+		t.Errorf("preview of %d bytes", len(got))
+	}
+}
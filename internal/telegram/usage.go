@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/user/gopherclaw/internal/i18n"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// SetModelRegistry prices the token usage /usage reports; without it
+// every call counts as unpriced.
+func (a *Adapter) SetModelRegistry(models *llm.Registry) {
+	a.models = models
+}
+
+// formatUsage reports the model usage of the chat's current session, in
+// total and today.
+func (a *Adapter) formatUsage(ctx context.Context, key types.SessionKey, lang string) string {
+	sid, err := a.sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		return i18n.T(lang, "session_failed")
+	}
+	report, err := usage.Collect(ctx, a.sessions, a.events, a.models, usage.Filter{SessionID: sid})
+	if err != nil {
+		log.Printf("collect usage error: %v", err)
+		return i18n.T(lang, "usage_failed")
+	}
+	if report.Total.Calls == 0 {
+		return i18n.T(lang, "usage_none")
+	}
+	var today usage.Totals
+	if n := len(report.Days); n > 0 && report.Days[n-1].Key == time.Now().Format(time.DateOnly) {
+		today = report.Days[n-1].Totals
+	}
+	line := func(t usage.Totals) string {
+		return i18n.T(lang, "usage_line", t.Calls, t.InputTokens, t.OutputTokens, t.CostUSD)
+	}
+	text := i18n.T(lang, "usage_report", line(report.Total), line(today))
+	if report.Total.Unpriced > 0 {
+		text += "\n" + i18n.T(lang, "usage_unpriced", report.Total.Unpriced)
+	}
+	return text
+}
@@ -37,3 +37,10 @@ func NewAutomationID() AutomationID {
 func NewSessionKey(parts ...string) SessionKey {
 	return SessionKey(strings.Join(parts, ":"))
 }
+
+// Channel returns the part of the key before the first ":", which names
+// the channel the session talks through (e.g. "telegram", "http").
+func (k SessionKey) Channel() string {
+	channel, _, _ := strings.Cut(string(k), ":")
+	return channel
+}
@@ -11,11 +11,13 @@ type SessionStore interface {
 	Get(ctx context.Context, id SessionID) (*SessionIndex, error)
 	List(ctx context.Context) ([]*SessionIndex, error)
 	Update(ctx context.Context, session *SessionIndex) error
-	Rotate(ctx context.Context, key SessionKey) (SessionID, error)
+	Touch(ctx context.Context, id SessionID) error
+	Rotate(ctx context.Context, key SessionKey, expected SessionID) (SessionID, error)
 }
 
 type EventStore interface {
 	Append(ctx context.Context, event *Event) error
+	AppendBatch(ctx context.Context, events []*Event) error
 	Tail(ctx context.Context, sessionID SessionID, limit int) ([]*Event, error)
 	Count(ctx context.Context, sessionID SessionID) (int64, error)
 }
@@ -25,4 +27,5 @@ type ArtifactStore interface {
 	Get(ctx context.Context, id ArtifactID) (json.RawMessage, error)
 	GetMeta(ctx context.Context, id ArtifactID) (*ArtifactMeta, error)
 	Excerpt(ctx context.Context, id ArtifactID, query string, maxTokens int) (string, error)
+	SetSummary(ctx context.Context, id ArtifactID, summary string) error
 }
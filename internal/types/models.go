@@ -26,6 +26,78 @@ type SessionIndex struct {
 	UpdatedAt    time.Time  `json:"updated_at"`
 	LastRunID    RunID      `json:"last_run_id,omitempty"`
 	LastEventSeq int64      `json:"last_event_seq"`
+	// Locked sessions accept no new runs until unlocked.
+	Locked     bool   `json:"locked,omitempty"`
+	LockReason string `json:"lock_reason,omitempty"`
+	// DisabledTools are tool names the runtime withholds from this session.
+	DisabledTools []string `json:"disabled_tools,omitempty"`
+	// Language is the preferred response language as an ISO 639-1 code,
+	// set by the user or detected from the first messages.
+	Language string `json:"language,omitempty"`
+	// Instructions are standing system-level instructions added with /sys
+	// or the instructions API. The context engine renders them as a system
+	// message after the system prompt, so they never fall out of budget.
+	Instructions []string `json:"instructions,omitempty"`
+	// DryRun makes the runtime plan mutating tool calls instead of running
+	// them; ConfirmTools does the same for the named tools in any mode.
+	// Planned calls wait in Pending until the user confirms or cancels.
+	DryRun       bool          `json:"dry_run,omitempty"`
+	ConfirmTools []string      `json:"confirm_tools,omitempty"`
+	Pending      []PendingCall `json:"pending,omitempty"`
+	// ArchivedFrom is the key an archived session held before rotation
+	// moved it to "archived:<id>", and ArchivedAt when that happened.
+	ArchivedFrom SessionKey `json:"archived_from,omitempty"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+}
+
+// Session statuses.
+const (
+	SessionActive   = "active"
+	SessionArchived = "archived"
+)
+
+// OriginKey returns the key the session was created under: ArchivedFrom
+// for a rotated session, SessionKey otherwise.
+func (s *SessionIndex) OriginKey() SessionKey {
+	if s.ArchivedFrom != "" {
+		return s.ArchivedFrom
+	}
+	return s.SessionKey
+}
+
+// SessionFilter selects sessions from a list. Empty fields match any
+// session. Key and Channel match a rotated session by its OriginKey.
+type SessionFilter struct {
+	Status  string
+	Channel string
+	Key     SessionKey
+}
+
+// Match reports whether f selects s.
+func (f SessionFilter) Match(s *SessionIndex) bool {
+	return (f.Status == "" || f.Status == s.Status) &&
+		(f.Channel == "" || f.Channel == s.OriginKey().Channel()) &&
+		(f.Key == "" || f.Key == s.OriginKey())
+}
+
+// FilterSessions returns the sessions in list that f selects.
+func FilterSessions(list []*SessionIndex, f SessionFilter) []*SessionIndex {
+	var out []*SessionIndex
+	for _, s := range list {
+		if f.Match(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// PendingCall is a tool call recorded in dry-run mode, to be executed when
+// the user confirms.
+type PendingCall struct {
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+	RunID     RunID           `json:"run_id"`
+	At        time.Time       `json:"at"`
 }
 
 type ArtifactMeta struct {
@@ -35,12 +107,103 @@ type ArtifactMeta struct {
 	Tool      string     `json:"tool"`
 	CreatedAt time.Time  `json:"created_at"`
 	MimeType  string     `json:"mime_type,omitempty"`
+	// Summary is a short LLM-written digest of the data, set when the
+	// runtime summarizes large tool outputs.
+	Summary string `json:"summary,omitempty"`
+}
+
+// Source is a web page behind a tool result, recorded on tool_result events
+// so replies can cite where their claims came from.
+type Source struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	// Fetched is set when the tool read the page itself rather than only
+	// listing it as a search result.
+	Fetched bool `json:"fetched,omitempty"`
 }
 
 type InboundEvent struct {
-	Source     string          `json:"source"`
-	SessionKey SessionKey     `json:"session_key"`
-	UserID     string         `json:"user_id"`
-	Text       string         `json:"text"`
-	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	Source      string       `json:"source"`
+	SessionKey  SessionKey   `json:"session_key"`
+	UserID      string       `json:"user_id"`
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Metadata    *InboundMeta `json:"metadata,omitempty"`
+	// Confirm executes the session's pending tool calls before the model
+	// is called.
+	Confirm bool `json:"confirm,omitempty"`
+}
+
+// InboundSchemaVersion is the version of InboundMeta written into
+// user_message payloads. Bump it when a field changes meaning; readers must
+// accept older versions.
+const InboundSchemaVersion = 1
+
+// InboundMeta records where an inbound message came from, as far as the
+// channel can tell. Adapters fill in what they know and leave the rest
+// empty; the runtime persists it with the user_message event.
+type InboundMeta struct {
+	Version   int    `json:"version"`
+	MessageID string `json:"message_id,omitempty"`
+	ChatID    string `json:"chat_id,omitempty"`
+	ChatTitle string `json:"chat_title,omitempty"`
+	ChatType  string `json:"chat_type,omitempty"`
+	Username  string `json:"username,omitempty"`
+	// ReplyTo is set when the message replies to an earlier one.
+	ReplyTo *ReplyRef `json:"reply_to,omitempty"`
+	// Task is the named task a webhook or schedule fired.
+	Task string `json:"task,omitempty"`
+	// Headers are the HTTP request headers of a webhook or API call, minus
+	// credentials.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// ReplyRef identifies the message an inbound message replies to.
+type ReplyRef struct {
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id,omitempty"`
+	// Text is a prefix of the replied-to message, for context.
+	Text string `json:"text,omitempty"`
+}
+
+// Attachment is a file received alongside an inbound message. Adapters store
+// the file contents in the ArtifactStore and reference it here, so every
+// channel hands files to the runtime the same way.
+type Attachment struct {
+	ArtifactID  ArtifactID `json:"artifact_id"`
+	ContentType string     `json:"content_type"`
+	Filename    string     `json:"filename,omitempty"`
+	Size        int64      `json:"size"`
 }
+
+// RunRecord is the persisted lifecycle of a run, written when it is queued,
+// starts and finishes. Status is one of gateway's run statuses, or
+// RunInterrupted for a run the process stopped before it finished.
+type RunRecord struct {
+	ID         RunID      `json:"id"`
+	SessionID  SessionID  `json:"session_id"`
+	SessionKey SessionKey `json:"session_key,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Token usage summed over the run's LLM calls.
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+	// ToolRounds counts the rounds in which the model called tools, and
+	// ToolCalls the calls across them.
+	ToolRounds int `json:"tool_rounds,omitempty"`
+	ToolCalls  int `json:"tool_calls,omitempty"`
+	// Attempts counts the starts the run lost to restarts before it was
+	// resumed.
+	Attempts int `json:"attempts,omitempty"`
+	// Event is the inbound event, kept until the run finishes so it can
+	// be resumed after a restart.
+	Event *InboundEvent `json:"event,omitempty"`
+}
+
+// RunInterrupted is the status of a run that was still queued or running
+// when the process that owned it stopped.
+const RunInterrupted = "interrupted"
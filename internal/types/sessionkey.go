@@ -0,0 +1,179 @@
+// internal/types/sessionkey.go
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidSessionKey means a session key has an unknown scheme or
+// malformed fields.
+var ErrInvalidSessionKey = errors.New("invalid session key")
+
+// KeyScheme describes one kind of session key, "<scheme>:<field>:...".
+type KeyScheme struct {
+	Name string
+	// Fields names the parts after the scheme. The last field takes the
+	// rest of the key, colons included.
+	Fields []string
+	// Validate checks the parsed fields. Fields are already known to be
+	// present and non-empty.
+	Validate func(fields []string) error
+}
+
+// ParsedSessionKey is a session key split into its scheme and fields.
+type ParsedSessionKey struct {
+	Scheme string
+	Fields []string
+	scheme *KeyScheme
+}
+
+// Field returns the named field, or "" if the scheme has no such field.
+func (p ParsedSessionKey) Field(name string) string {
+	for i, f := range p.scheme.Fields {
+		if f == name {
+			return p.Fields[i]
+		}
+	}
+	return ""
+}
+
+// Key joins the parsed key back into a SessionKey.
+func (p ParsedSessionKey) Key() SessionKey {
+	return NewSessionKey(append([]string{p.Scheme}, p.Fields...)...)
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = make(map[string]*KeyScheme)
+)
+
+// RegisterKeyScheme adds or replaces a session key scheme. Adapters for new
+// channels register theirs at init.
+func RegisterKeyScheme(s KeyScheme) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[s.Name] = &s
+}
+
+// KeySchemes returns the registered scheme names, sorted.
+func KeySchemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSessionKey splits a session key by its registered scheme and
+// validates its fields. Errors wrap ErrInvalidSessionKey.
+func ParseSessionKey(key string) (ParsedSessionKey, error) {
+	name, rest, _ := strings.Cut(key, ":")
+	schemesMu.RLock()
+	scheme, ok := schemes[name]
+	schemesMu.RUnlock()
+	if !ok {
+		return ParsedSessionKey{}, fmt.Errorf("%w %q: unknown scheme %q (want one of %s)", ErrInvalidSessionKey, key, name, strings.Join(KeySchemes(), ", "))
+	}
+
+	fields := strings.SplitN(rest, ":", len(scheme.Fields))
+	if len(fields) != len(scheme.Fields) || rest == "" {
+		return ParsedSessionKey{}, fmt.Errorf("%w %q: want %s", ErrInvalidSessionKey, key, scheme.format())
+	}
+	for i, f := range fields {
+		if f == "" {
+			return ParsedSessionKey{}, fmt.Errorf("%w %q: empty %s", ErrInvalidSessionKey, key, scheme.Fields[i])
+		}
+	}
+	if scheme.Validate != nil {
+		if err := scheme.Validate(fields); err != nil {
+			return ParsedSessionKey{}, fmt.Errorf("%w %q: %v", ErrInvalidSessionKey, key, err)
+		}
+	}
+	return ParsedSessionKey{Scheme: name, Fields: fields, scheme: scheme}, nil
+}
+
+// format describes the scheme's key layout for error messages.
+func (s *KeyScheme) format() string {
+	parts := []string{s.Name}
+	for _, f := range s.Fields {
+		parts = append(parts, "<"+f+">")
+	}
+	return strings.Join(parts, ":")
+}
+
+// Parse is ParseSessionKey for a SessionKey.
+func (k SessionKey) Parse() (ParsedSessionKey, error) {
+	return ParseSessionKey(string(k))
+}
+
+func init() {
+	RegisterKeyScheme(KeyScheme{Name: "telegram", Fields: []string{"user", "chat"}, Validate: func(fields []string) error {
+		for _, f := range fields {
+			if _, err := strconv.ParseInt(f, 10, 64); err != nil {
+				return fmt.Errorf("telegram IDs must be integers, got %q", f)
+			}
+		}
+		return nil
+	}})
+	RegisterKeyScheme(KeyScheme{Name: "http", Fields: []string{"name"}})
+	RegisterKeyScheme(KeyScheme{Name: "cli", Fields: []string{"user"}})
+	RegisterKeyScheme(KeyScheme{Name: "email", Fields: []string{"address"}, Validate: func(fields []string) error {
+		addr, err := mail.ParseAddress(fields[0])
+		if err != nil || addr.Address != fields[0] {
+			return fmt.Errorf("%q is not a bare email address", fields[0])
+		}
+		return nil
+	}})
+	RegisterKeyScheme(KeyScheme{Name: "webpush", Fields: []string{"name"}})
+
+	// Keys the daemon makes for itself.
+	RegisterKeyScheme(KeyScheme{Name: "heartbeat", Fields: []string{"target"}, Validate: func(fields []string) error {
+		_, err := ParseSessionKey(fields[0])
+		return err
+	}})
+	RegisterKeyScheme(KeyScheme{Name: "import", Fields: []string{"format", "id"}})
+	RegisterKeyScheme(KeyScheme{Name: "archived", Fields: []string{"session_id"}})
+}
+
+// TelegramKey returns the key of a user's session in a Telegram chat.
+func TelegramKey(userID, chatID int64) SessionKey {
+	return NewSessionKey("telegram", strconv.FormatInt(userID, 10), strconv.FormatInt(chatID, 10))
+}
+
+// TelegramChat returns the user and chat IDs of a telegram: key.
+func (k SessionKey) TelegramChat() (userID, chatID int64, err error) {
+	p, err := k.Parse()
+	if err != nil {
+		return 0, 0, err
+	}
+	if p.Scheme != "telegram" {
+		return 0, 0, fmt.Errorf("%w %q: not a telegram key", ErrInvalidSessionKey, k)
+	}
+	userID, _ = strconv.ParseInt(p.Fields[0], 10, 64)
+	chatID, _ = strconv.ParseInt(p.Fields[1], 10, 64)
+	return userID, chatID, nil
+}
+
+// HTTPKey returns the key of a named HTTP API session.
+func HTTPKey(name string) SessionKey {
+	return NewSessionKey("http", name)
+}
+
+// CLIKey returns the key of a user's `gopherclaw chat` session.
+func CLIKey(user string) SessionKey {
+	return NewSessionKey("cli", user)
+}
+
+// EmailKey returns the key of the session with an email address.
+func EmailKey(address string) SessionKey {
+	return NewSessionKey("email", address)
+}
@@ -0,0 +1,86 @@
+// internal/types/sessionkey_test.go
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSessionKey(t *testing.T) {
+	valid := map[string][]string{
+		"telegram:42:-100123":         {"42", "-100123"},
+		"http:reports":                {"reports"},
+		"http:backfill:2024":          {"backfill:2024"},
+		"cli:alice":                   {"alice"},
+		"email:alice@example.com":     {"alice@example.com"},
+		"webpush:alice":               {"alice"},
+		"heartbeat:telegram:1:1":      {"telegram:1:1"},
+		"import:chatgpt:conv-1":       {"chatgpt", "conv-1"},
+		"archived:0b9c2f6e-session-1": {"0b9c2f6e-session-1"},
+	}
+	for key, fields := range valid {
+		p, err := ParseSessionKey(key)
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if len(p.Fields) != len(fields) || p.Fields[0] != fields[0] || p.Key() != SessionKey(key) {
+			t.Errorf("%s: got %+v", key, p)
+		}
+	}
+
+	for _, key := range []string{
+		"",
+		"telegram",
+		"telegram:42",
+		"telegram:42:",
+		"telegram:alice:42",
+		"telegram:1:2:3",
+		"http:",
+		"slack:general",
+		"email:not an address",
+		"email:Alice <alice@example.com>",
+		"heartbeat:slack:general",
+	} {
+		if _, err := ParseSessionKey(key); !errors.Is(err, ErrInvalidSessionKey) {
+			t.Errorf("%q: expected ErrInvalidSessionKey, got %v", key, err)
+		}
+	}
+}
+
+func TestSessionKeyHelpers(t *testing.T) {
+	key := TelegramKey(42, -100123)
+	if key != "telegram:42:-100123" {
+		t.Fatalf("TelegramKey = %s", key)
+	}
+	user, chat, err := key.TelegramChat()
+	if err != nil || user != 42 || chat != -100123 {
+		t.Errorf("TelegramChat = %d, %d, %v", user, chat, err)
+	}
+	if _, _, err := HTTPKey("reports").TelegramChat(); !errors.Is(err, ErrInvalidSessionKey) {
+		t.Errorf("http key as telegram: %v", err)
+	}
+
+	p, err := CLIKey("alice").Parse()
+	if err != nil || p.Scheme != "cli" || p.Field("user") != "alice" || p.Field("chat") != "" {
+		t.Errorf("CLIKey parse = %+v, %v", p, err)
+	}
+	if p, err := EmailKey("bob@example.com").Parse(); err != nil || p.Field("address") != "bob@example.com" {
+		t.Errorf("EmailKey parse = %+v, %v", p, err)
+	}
+}
+
+func TestRegisterKeyScheme(t *testing.T) {
+	if _, err := ParseSessionKey("signal:+15550100"); err == nil {
+		t.Fatal("expected unknown scheme to fail before registering")
+	}
+	RegisterKeyScheme(KeyScheme{Name: "signal", Fields: []string{"number"}})
+	defer func() {
+		schemesMu.Lock()
+		delete(schemes, "signal")
+		schemesMu.Unlock()
+	}()
+	if _, err := ParseSessionKey("signal:+15550100"); err != nil {
+		t.Errorf("registered scheme: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Limits are spend limits per local calendar day and month, in USD or
+// tokens (input plus output). Zero means no limit. It converts to and from
+// config.BudgetLimits.
+type Limits struct {
+	DailyUSD      float64
+	MonthlyUSD    float64
+	DailyTokens   int
+	MonthlyTokens int
+}
+
+// IsZero reports whether l sets no limit at all.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Budget scopes and periods, as used in Spend and ExceededError.
+const (
+	ScopeGlobal  = "global"
+	ScopeSession = "session"
+	Daily        = "daily"
+	Monthly      = "monthly"
+)
+
+// ExceededError reports an LLM call refused because a budget is spent.
+// Until is when the budget's period rolls over.
+type ExceededError struct {
+	Scope  string
+	Period string
+	Limit  string
+	Until  time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s %s budget of %s spent", e.Period, e.Scope, e.Limit)
+}
+
+// Notice is the polite message adapters show the sender.
+func (e *ExceededError) Notice() string {
+	who := "I've"
+	if e.Scope == ScopeSession {
+		who = "this conversation has"
+	}
+	return fmt.Sprintf("Sorry, %s used up the %s budget (%s), so I can't answer until it resets on %s.",
+		who, e.Period, e.Limit, e.Until.Local().Format("Jan 2 15:04"))
+}
+
+// Spend is what one budget has spent of its limits in the current period,
+// since the start of the period or the budget's last reset.
+type Spend struct {
+	Scope       string    `json:"scope"`
+	Period      string    `json:"period"`
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	Tokens      int       `json:"tokens"`
+	CostUSD     float64   `json:"cost_usd"`
+	LimitTokens int       `json:"limit_tokens,omitempty"`
+	LimitUSD    float64   `json:"limit_usd,omitempty"`
+	Unpriced    int       `json:"unpriced_calls,omitempty"`
+}
+
+// Exceeded returns the limit s has reached, formatted for a message, or ""
+// if it is within its limits.
+func (s Spend) Exceeded() string {
+	if s.LimitUSD > 0 && s.CostUSD >= s.LimitUSD {
+		return fmt.Sprintf("$%.2f", s.LimitUSD)
+	}
+	if s.LimitTokens > 0 && s.Tokens >= s.LimitTokens {
+		return fmt.Sprintf("%d tokens", s.LimitTokens)
+	}
+	return ""
+}
+
+// Budget tracks the spend of this month's LLM calls against the global and
+// per-session limits. Calls to models without a price count their tokens
+// but no dollars.
+type Budget struct {
+	global  Limits
+	session Limits
+	models  *llm.Registry
+	resets  *state.BudgetStore
+	now     func() time.Time
+
+	mu    sync.Mutex
+	calls []call
+}
+
+type call struct {
+	session types.SessionID
+	at      time.Time
+	tokens  int
+	cost    float64
+	priced  bool
+}
+
+// NewBudget creates a Budget enforcing global limits on all sessions
+// together and session limits on each session. resets, which may be nil,
+// records when budgets were started over.
+func NewBudget(global, session Limits, models *llm.Registry, resets *state.BudgetStore) *Budget {
+	return &Budget{global: global, session: session, models: models, resets: resets, now: time.Now}
+}
+
+// Load counts the usage events recorded so far this month, replacing the
+// calls counted before. Call it before the runtime makes any.
+func (b *Budget) Load(ctx context.Context, sessions types.SessionStore, events types.EventStore) error {
+	since := monthStart(b.now())
+	var calls []call
+	err := scan(ctx, sessions, events, Filter{Since: since}, func(_ *types.SessionIndex, ev *types.Event, p Payload) {
+		calls = append(calls, b.call(ev.SessionID, ev.At, p.Model, p.Usage))
+	})
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.calls = calls
+	b.mu.Unlock()
+	return nil
+}
+
+// Add counts an LLM call the session just made.
+func (b *Budget) Add(sessionID types.SessionID, model string, u llm.Usage) {
+	now := b.now()
+	c := b.call(sessionID, now, model, u)
+	since := monthStart(now)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Drop the calls of past months, which no budget counts any more.
+	keep := b.calls[:0]
+	for _, old := range b.calls {
+		if !old.at.Before(since) {
+			keep = append(keep, old)
+		}
+	}
+	b.calls = append(keep, c)
+}
+
+// Allow returns an *ExceededError if the session's or the global budget is
+// spent, checking the session's before the global one and the daily limit
+// before the monthly one.
+func (b *Budget) Allow(sessionID types.SessionID) error {
+	for _, s := range b.Status(sessionID) {
+		if limit := s.Exceeded(); limit != "" {
+			return &ExceededError{Scope: s.Scope, Period: s.Period, Limit: limit, Until: s.Until}
+		}
+	}
+	return nil
+}
+
+// Status returns the spend of every budget with a limit that applies to
+// the session: its own daily and monthly ones first, then the global ones.
+// An empty sessionID returns only the global ones.
+func (b *Budget) Status(sessionID types.SessionID) []Spend {
+	now := b.now()
+	resets := &state.BudgetResets{}
+	if b.resets != nil {
+		r, err := b.resets.Load()
+		if err != nil {
+			slog.Warn("load budget resets failed", "error", err)
+		} else {
+			resets = r
+		}
+	}
+
+	var out []Spend
+	add := func(scope string, l Limits, reset time.Time) {
+		day, month := dayStart(now), monthStart(now)
+		if l.DailyUSD > 0 || l.DailyTokens > 0 {
+			out = append(out, b.spend(scope, Daily, sessionID, later(day, reset), day.AddDate(0, 0, 1), l.DailyUSD, l.DailyTokens))
+		}
+		if l.MonthlyUSD > 0 || l.MonthlyTokens > 0 {
+			out = append(out, b.spend(scope, Monthly, sessionID, later(month, reset), month.AddDate(0, 1, 0), l.MonthlyUSD, l.MonthlyTokens))
+		}
+	}
+	if sessionID != "" {
+		add(ScopeSession, b.session, resets.Since(sessionID))
+	}
+	add(ScopeGlobal, b.global, resets.Global)
+	return out
+}
+
+func (b *Budget) spend(scope, period string, sessionID types.SessionID, since, until time.Time, usd float64, tokens int) Spend {
+	s := Spend{Scope: scope, Period: period, Since: since, Until: until, LimitUSD: usd, LimitTokens: tokens}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.calls {
+		if c.at.Before(since) || scope == ScopeSession && c.session != sessionID {
+			continue
+		}
+		s.Tokens += c.tokens
+		s.CostUSD += c.cost
+		if !c.priced {
+			s.Unpriced++
+		}
+	}
+	return s
+}
+
+func (b *Budget) call(sessionID types.SessionID, at time.Time, model string, u llm.Usage) call {
+	cost, priced := price(b.models, model, u)
+	return call{session: sessionID, at: at, tokens: u.InputTokens + u.OutputTokens, cost: cost, priced: priced}
+}
+
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func monthStart(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
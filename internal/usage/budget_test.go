@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestBudget(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+
+	a, _ := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	b, _ := sessions.ResolveOrCreate(ctx, "test:b", "default")
+	// $2.50 of input each; last month's call counts toward nothing.
+	record(t, events, a, "r1", now.AddDate(0, -1, 0), "gpt-4o", 1_000_000, 0)
+	record(t, events, a, "r2", now.AddDate(0, 0, -3), "gpt-4o", 1_000_000, 0)
+	record(t, events, b, "r3", now.Add(-time.Hour), "gpt-4o", 1_000_000, 0)
+
+	resets := state.NewBudgetStore(filepath.Join(dir, "budget.json"))
+	budget := NewBudget(Limits{MonthlyUSD: 6}, Limits{DailyUSD: 3}, llm.NewRegistry(), resets)
+	budget.now = func() time.Time { return now }
+	if err := budget.Load(ctx, sessions, events); err != nil {
+		t.Fatal(err)
+	}
+
+	status := budget.Status(b)
+	if len(status) != 2 || status[0].Scope != ScopeSession || status[0].CostUSD != 2.5 || status[1].Scope != ScopeGlobal || status[1].CostUSD != 5 {
+		t.Fatalf("status = %+v", status)
+	}
+	if err := budget.Allow(b); err != nil {
+		t.Errorf("expected b within its budgets, got %v", err)
+	}
+
+	budget.Add(b, "gpt-4o", llm.Usage{InputTokens: 400_000})
+	var exceeded *ExceededError
+	if err := budget.Allow(b); !errors.As(err, &exceeded) || exceeded.Scope != ScopeSession || exceeded.Period != Daily || exceeded.Limit != "$3.00" {
+		t.Fatalf("expected b's daily budget spent, got %v", err)
+	}
+	if !exceeded.Until.Equal(time.Date(2026, 3, 16, 0, 0, 0, 0, time.Local)) || !strings.Contains(exceeded.Notice(), "this conversation has used up the daily budget ($3.00)") {
+		t.Errorf("until = %v, notice = %q", exceeded.Until, exceeded.Notice())
+	}
+	if err := budget.Allow(a); !errors.As(err, &exceeded) || exceeded.Scope != ScopeGlobal || exceeded.Period != Monthly {
+		t.Errorf("expected the global monthly budget spent, got %v", err)
+	}
+
+	// A global reset starts every budget over.
+	reset := now.Add(time.Second)
+	resets.Reset("", reset)
+	if err := budget.Allow(b); err != nil {
+		t.Errorf("expected no budget spent after a reset, got %v", err)
+	}
+	if s := budget.Status(""); len(s) != 1 || s[0].CostUSD != 0 || !s[0].Since.Equal(reset) {
+		t.Errorf("status after reset = %+v", s)
+	}
+}
@@ -0,0 +1,242 @@
+// Package usage records the token usage of LLM calls as events and
+// aggregates it per run, session, day and model, priced from the model
+// registry.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Purposes of the LLM calls the runtime makes.
+const (
+	Reply     = "reply"     // a round of answering a message
+	Shorten   = "shorten"   // rewriting a reply for a brief channel
+	Summarize = "summarize" // summarizing a large tool result
+	History   = "history"   // folding overflowing history into a summary
+	Seed      = "seed"      // summarizing an archived session for its successor
+)
+
+// Payload is the payload of a usage event. Backend and Failed say which
+// failover backend served the call and which failed it first.
+type Payload struct {
+	Purpose  string `json:"purpose"`
+	Model    string `json:"model,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	llm.Usage
+	Backend string        `json:"backend,omitempty"`
+	Failed  []llm.Attempt `json:"failed,omitempty"`
+}
+
+// Event returns a usage event recording resp's token usage and failover
+// backend, or nil if it has neither.
+func Event(sessionID types.SessionID, runID types.RunID, purpose string, resp *llm.Response) *types.Event {
+	if resp.Usage.InputTokens == 0 && resp.Usage.OutputTokens == 0 && resp.Backend == "" {
+		return nil
+	}
+	payload, _ := json.Marshal(Payload{
+		Purpose:  purpose,
+		Model:    resp.Model,
+		Provider: resp.Provider,
+		Usage:    resp.Usage,
+		Backend:  resp.Backend,
+		Failed:   resp.Failed,
+	})
+	return &types.Event{
+		ID:        types.NewEventID(),
+		SessionID: sessionID,
+		RunID:     runID,
+		Type:      "usage",
+		Source:    "runtime",
+		At:        time.Now(),
+		Payload:   payload,
+	}
+}
+
+// Totals sums the usage of a group of LLM calls. CostUSD is priced from
+// the model registry; Unpriced counts the calls whose model has no price
+// there and so add nothing to it.
+type Totals struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	Unpriced     int     `json:"unpriced_calls,omitempty"`
+}
+
+func (t *Totals) add(u llm.Usage, cost float64, priced bool) {
+	t.Calls++
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.CostUSD += cost
+	if !priced {
+		t.Unpriced++
+	}
+}
+
+// Group is the totals for one run, session, day or model. Name is the
+// session key of a session's group.
+type Group struct {
+	Key  string `json:"key"`
+	Name string `json:"name,omitempty"`
+	Totals
+}
+
+// Report is the usage Collect found.
+type Report struct {
+	Total    Totals  `json:"total"`
+	Days     []Group `json:"days"`
+	Sessions []Group `json:"sessions"`
+	Models   []Group `json:"models"`
+	// Runs is only filled for a report on one session.
+	Runs []Group `json:"runs,omitempty"`
+}
+
+// Filter narrows what Collect counts. Zero values count everything.
+type Filter struct {
+	Since     time.Time
+	SessionID types.SessionID
+}
+
+// Since returns the start of the local day days-1 days before now, so
+// that a Filter with it covers the last days days including today. It
+// returns the zero time, counting everything, for days <= 0.
+func Since(days int, now time.Time) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d-days+1, 0, 0, 0, 0, now.Location())
+}
+
+// Collect scans the event logs of the sessions filter allows and
+// aggregates their usage events. Days are in local time and sorted
+// oldest first; sessions are sorted by cost, then tokens, highest first;
+// models by name; runs in the order they happened. models prices the
+// calls; a nil registry leaves every call unpriced.
+func Collect(ctx context.Context, sessions types.SessionStore, events types.EventStore, models *llm.Registry, filter Filter) (*Report, error) {
+	report := &Report{}
+	days := make(map[string]*Group)
+	bySession := make(map[string]*Group)
+	byModel := make(map[string]*Group)
+	byRun := make(map[string]*Group)
+	var runOrder []string
+	group := func(m map[string]*Group, key string) *Group {
+		g, ok := m[key]
+		if !ok {
+			g = &Group{Key: key}
+			m[key] = g
+		}
+		return g
+	}
+
+	err := scan(ctx, sessions, events, filter, func(sess *types.SessionIndex, ev *types.Event, p Payload) {
+		cost, priced := price(models, p.Model, p.Usage)
+
+		report.Total.add(p.Usage, cost, priced)
+		group(days, ev.At.Local().Format(time.DateOnly)).add(p.Usage, cost, priced)
+		g := group(bySession, string(sess.SessionID))
+		g.Name = string(sess.SessionKey)
+		g.add(p.Usage, cost, priced)
+		model := p.Model
+		if model == "" {
+			model = "unknown"
+		}
+		group(byModel, model).add(p.Usage, cost, priced)
+		if filter.SessionID != "" && ev.RunID != "" {
+			if _, ok := byRun[string(ev.RunID)]; !ok {
+				runOrder = append(runOrder, string(ev.RunID))
+			}
+			group(byRun, string(ev.RunID)).add(p.Usage, cost, priced)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Days = sorted(days, func(a, b Group) bool { return a.Key < b.Key })
+	report.Sessions = sorted(bySession, func(a, b Group) bool {
+		if a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		if at, bt := a.InputTokens+a.OutputTokens, b.InputTokens+b.OutputTokens; at != bt {
+			return at > bt
+		}
+		return a.Key < b.Key
+	})
+	report.Models = sorted(byModel, func(a, b Group) bool { return a.Key < b.Key })
+	for _, id := range runOrder {
+		report.Runs = append(report.Runs, *byRun[id])
+	}
+	return report, nil
+}
+
+// scan calls fn with every usage event of the sessions filter allows.
+func scan(ctx context.Context, sessions types.SessionStore, events types.EventStore, filter Filter, fn func(*types.SessionIndex, *types.Event, Payload)) error {
+	var list []*types.SessionIndex
+	if filter.SessionID != "" {
+		sess, err := sessions.Get(ctx, filter.SessionID)
+		if err != nil {
+			return err
+		}
+		list = []*types.SessionIndex{sess}
+	} else {
+		var err error
+		if list, err = sessions.List(ctx); err != nil {
+			return fmt.Errorf("list sessions: %w", err)
+		}
+	}
+
+	for _, sess := range list {
+		count, err := events.Count(ctx, sess.SessionID)
+		if err != nil {
+			return fmt.Errorf("count events: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		all, err := events.Tail(ctx, sess.SessionID, int(count))
+		if err != nil {
+			return fmt.Errorf("load events: %w", err)
+		}
+		for _, ev := range all {
+			if ev.Type != "usage" || ev.At.Before(filter.Since) {
+				continue
+			}
+			var p Payload
+			if err := json.Unmarshal(ev.Payload, &p); err != nil {
+				continue
+			}
+			fn(sess, ev, p)
+		}
+	}
+	return nil
+}
+
+// price returns the cost of a call to model and whether the model has a
+// price in the registry.
+func price(models *llm.Registry, model string, u llm.Usage) (float64, bool) {
+	if models == nil || model == "" {
+		return 0, false
+	}
+	caps, ok := models.Lookup(model)
+	if !ok || caps.InputPrice == 0 && caps.OutputPrice == 0 {
+		return 0, false
+	}
+	return caps.Cost(u), true
+}
+
+func sorted(m map[string]*Group, less func(a, b Group) bool) []Group {
+	groups := make([]Group, 0, len(m))
+	for _, g := range m {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return less(groups[i], groups[j]) })
+	return groups
+}
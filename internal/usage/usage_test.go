@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func record(t *testing.T, events types.EventStore, sid types.SessionID, run types.RunID, at time.Time, model string, in, out int) {
+	t.Helper()
+	ev := Event(sid, run, Reply, &llm.Response{Model: model, Usage: llm.Usage{InputTokens: in, OutputTokens: out, TotalTokens: in + out}})
+	ev.At = at
+	if err := events.Append(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	ctx := context.Background()
+
+	if ev := Event("s", "r", Reply, &llm.Response{Model: "gpt-4o"}); ev != nil {
+		t.Errorf("expected no event without usage, got %+v", ev)
+	}
+	if ev := Event("s", "r", Reply, &llm.Response{Model: "gpt-4o", Backend: "backup"}); ev == nil {
+		t.Error("expected an event naming the failover backend")
+	}
+
+	a, _ := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	b, _ := sessions.ResolveOrCreate(ctx, "test:b", "default")
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	run1, run2 := types.NewRunID(), types.NewRunID()
+	record(t, events, a, run1, day1, "gpt-4o-2024-08-06", 1_000_000, 100_000)
+	record(t, events, a, run1, day1, "gpt-4o", 1_000_000, 0)
+	record(t, events, a, run2, day2, "local-llama", 500, 50)
+	record(t, events, b, types.NewRunID(), day2, "gpt-4o-mini", 1_000_000, 1_000_000)
+
+	models := llm.NewRegistry()
+	report, err := Collect(ctx, sessions, events, models, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gpt-4o: 2 × $2.50 in + 0.1 × $10 out; gpt-4o-mini: $0.15 + $0.60.
+	if got := report.Total; got.Calls != 4 || got.InputTokens != 3_000_500 || got.OutputTokens != 1_100_050 || got.Unpriced != 1 || math.Abs(got.CostUSD-6.75) > 1e-9 {
+		t.Errorf("total = %+v", got)
+	}
+	if len(report.Days) != 2 || report.Days[0].Key != "2026-03-01" || report.Days[0].Calls != 2 || report.Days[1].Calls != 2 {
+		t.Errorf("days = %+v", report.Days)
+	}
+	if len(report.Sessions) != 2 || report.Sessions[0].Key != string(a) || report.Sessions[0].Name != "test:a" {
+		t.Errorf("sessions = %+v", report.Sessions)
+	}
+	if len(report.Models) != 4 || report.Models[0].Key != "gpt-4o" || report.Models[2].Key != "gpt-4o-mini" {
+		t.Errorf("models = %+v", report.Models)
+	}
+	if report.Runs != nil {
+		t.Errorf("expected no runs across sessions, got %+v", report.Runs)
+	}
+
+	report, err = Collect(ctx, sessions, events, models, Filter{SessionID: a, Since: day2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Calls != 1 || len(report.Runs) != 1 || report.Runs[0].Key != string(run2) || report.Runs[0].Unpriced != 1 {
+		t.Errorf("filtered report = %+v", report)
+	}
+}
+
+func TestSince(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 4, 0, 0, time.UTC)
+	if got := Since(1, now); !got.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Since(1) = %v", got)
+	}
+	if got := Since(7, now); !got.Equal(time.Date(2026, 2, 24, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Since(7) = %v", got)
+	}
+	if got := Since(0, now); !got.IsZero() {
+		t.Errorf("Since(0) = %v", got)
+	}
+}
@@ -0,0 +1,121 @@
+// Package watchdog probes long-running daemon components and restarts the
+// ones that stop responding, so a goroutine that silently died doesn't
+// leave the daemon looking healthy.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds a single Check call.
+const probeTimeout = 10 * time.Second
+
+// Component is a part of the daemon the watchdog keeps alive.
+type Component struct {
+	Name string
+	// Check returns an error when the component is wedged.
+	Check func(ctx context.Context) error
+	// Restart replaces the component's goroutines.
+	Restart func() error
+}
+
+// Alerter notifies admins of a wedged component.
+type Alerter func(message string)
+
+// Watchdog checks components periodically and restarts failing ones.
+type Watchdog struct {
+	alert Alerter
+
+	mu         sync.Mutex
+	components []Component
+	// failing holds the names of components whose last check failed, so
+	// a long outage alerts once when it starts and once when it ends.
+	failing map[string]bool
+}
+
+// New creates a Watchdog. alert may be nil, in which case failures are only
+// logged.
+func New(alert Alerter) *Watchdog {
+	return &Watchdog{alert: alert, failing: make(map[string]bool)}
+}
+
+// Add registers a component.
+func (w *Watchdog) Add(c Component) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.components = append(w.components, c)
+}
+
+// Run checks every component each interval until ctx is cancelled.
+func (w *Watchdog) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check probes every component once, restarting those that fail.
+func (w *Watchdog) Check(ctx context.Context) {
+	w.mu.Lock()
+	components := append([]Component(nil), w.components...)
+	w.mu.Unlock()
+
+	for _, c := range components {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := c.Check(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.mu.Lock()
+		wasFailing := w.failing[c.Name]
+		w.failing[c.Name] = err != nil
+		w.mu.Unlock()
+
+		if err == nil {
+			if wasFailing {
+				slog.Info("watchdog: component recovered", "component", c.Name)
+				w.notify(fmt.Sprintf("Watchdog: %s is responding again.", c.Name))
+			}
+			continue
+		}
+
+		slog.Error("watchdog: component unresponsive, restarting", "component", c.Name, "error", err)
+		msg := fmt.Sprintf("Watchdog: %s was unresponsive (%v) and has been restarted.", c.Name, err)
+		if rerr := c.Restart(); rerr != nil {
+			slog.Error("watchdog: restart failed", "component", c.Name, "error", rerr)
+			msg = fmt.Sprintf("Watchdog: %s is unresponsive (%v) and restarting it failed: %v", c.Name, err, rerr)
+		}
+		if !wasFailing {
+			w.notify(msg)
+		}
+	}
+}
+
+func (w *Watchdog) notify(message string) {
+	if w.alert != nil {
+		w.alert(message)
+	}
+}
+
+// Stale returns a Check that fails when last reports a time more than max
+// ago, for components that record their own signs of life.
+func Stale(last func() time.Time, max time.Duration) func(context.Context) error {
+	return func(context.Context) error {
+		if since := time.Since(last()); since > max {
+			return fmt.Errorf("no sign of life for %s", since.Round(time.Second))
+		}
+		return nil
+	}
+}
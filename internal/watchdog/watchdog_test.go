@@ -0,0 +1,75 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogRestartsAndAlertsOnce(t *testing.T) {
+	var alerts []string
+	w := New(func(msg string) { alerts = append(alerts, msg) })
+
+	healthy := false
+	restarts := 0
+	w.Add(Component{
+		Name: "telegram poller",
+		Check: func(context.Context) error {
+			if healthy {
+				return nil
+			}
+			return errors.New("no poll for 3m")
+		},
+		Restart: func() error { restarts++; return nil },
+	})
+	w.Add(Component{
+		Name:    "scheduler",
+		Check:   func(context.Context) error { return nil },
+		Restart: func() error { t.Error("healthy component restarted"); return nil },
+	})
+
+	ctx := context.Background()
+	w.Check(ctx)
+	w.Check(ctx)
+	if restarts != 2 {
+		t.Errorf("expected a restart per failed check, got %d", restarts)
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "telegram poller was unresponsive (no poll for 3m)") {
+		t.Fatalf("expected one alert for the outage, got %q", alerts)
+	}
+
+	healthy = true
+	w.Check(ctx)
+	w.Check(ctx)
+	if len(alerts) != 2 || !strings.Contains(alerts[1], "responding again") {
+		t.Errorf("expected one recovery alert, got %q", alerts)
+	}
+}
+
+func TestWatchdogRestartFailure(t *testing.T) {
+	var alerts []string
+	w := New(func(msg string) { alerts = append(alerts, msg) })
+	w.Add(Component{
+		Name:    "http server",
+		Check:   func(context.Context) error { return errors.New("connection refused") },
+		Restart: func() error { return errors.New("address in use") },
+	})
+	w.Check(context.Background())
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "restarting it failed: address in use") {
+		t.Errorf("unexpected alerts %q", alerts)
+	}
+}
+
+func TestStale(t *testing.T) {
+	last := time.Now()
+	check := Stale(func() time.Time { return last }, time.Minute)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("fresh: %v", err)
+	}
+	last = time.Now().Add(-2 * time.Minute)
+	if err := check(context.Background()); err == nil || !strings.Contains(err.Error(), "no sign of life for 2m0s") {
+		t.Errorf("stale: %v", err)
+	}
+}
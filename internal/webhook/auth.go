@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+// Role is what a token from the config file is allowed to do.
+type Role string
+
+const (
+	// RoleAdmin may call every endpoint.
+	RoleAdmin Role = "admin"
+	// RoleObserver may read sessions, events, artifacts, tasks and status,
+	// but cannot trigger runs, change sessions or tasks, or profile.
+	RoleObserver Role = "observer"
+)
+
+// ValidRole reports whether r is a known role.
+func ValidRole(r Role) bool {
+	return r == RoleAdmin || r == RoleObserver
+}
+
+// Scope is a permission carried by an API token. Each endpoint requires
+// one scope.
+type Scope string
+
+const (
+	// ScopeChat may run prompts: ad-hoc webhooks, session messages,
+	// uploads with a prompt, batches and macros. It also manages push
+	// subscriptions, which choose where replies are delivered.
+	ScopeChat Scope = "chat"
+	// ScopeSessionsRead may read sessions, events, prompts, tools,
+	// instructions, artifacts, feedback and usage, and the push key.
+	ScopeSessionsRead Scope = "sessions:read"
+	// ScopeTasksRead may list tasks and read the daemon status.
+	ScopeTasksRead Scope = "tasks:read"
+	// ScopeTasksWrite may trigger tasks through their named webhooks.
+	ScopeTasksWrite Scope = "tasks:write"
+	// ScopeAdmin may call every endpoint, including session locks, tool
+	// and instruction changes, broadcasts and the profiler.
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes lists every scope, in the order they are documented.
+var Scopes = []Scope{ScopeChat, ScopeSessionsRead, ScopeTasksRead, ScopeTasksWrite, ScopeAdmin}
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s Scope) bool {
+	return slices.Contains(Scopes, s)
+}
+
+// roleScopes returns the scopes granted to a config file token's role.
+func roleScopes(r Role) []Scope {
+	if r == RoleAdmin {
+		return []Scope{ScopeAdmin}
+	}
+	return []Scope{ScopeSessionsRead, ScopeTasksRead}
+}
+
+// SetTokens requires a bearer token on every request except the dashboard
+// page and its service worker and manifest, /health and signed artifact
+// links. tokens maps each token to its
+// role. With no tokens here or in the token store the server stays open,
+// as before.
+func (s *Server) SetTokens(tokens map[string]Role) {
+	s.tokens = make(map[string]Role, len(tokens))
+	for token, role := range tokens {
+		s.tokens[token] = role
+	}
+}
+
+// SetTokenStore accepts the scoped tokens managed with "gopherclaw token"
+// alongside those from SetTokens. Any token in the store turns
+// authentication on.
+func (s *Server) SetTokenStore(store *state.TokenStore) {
+	s.tokenStore = store
+}
+
+// route registers a handler that requires scope. An empty scope makes the
+// route public.
+func (s *Server) route(pattern string, scope Scope, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, handler)
+	s.scopes[pattern] = scope
+}
+
+// scopeFor returns the scope a request needs, or "" if it needs none.
+// Requests that match no scoped route, including unknown paths, need
+// admin so they can't be used to probe the server.
+func (s *Server) scopeFor(r *http.Request) Scope {
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		// The dashboard page is static; its API calls carry the token.
+		return ""
+	}
+	_, pattern := s.mux.Handler(r)
+	if scope, ok := s.scopes[pattern]; ok {
+		return scope
+	}
+	return ScopeAdmin
+}
+
+// authorize checks the request's bearer token against the scope its route
+// needs, writing a 401 or 403 and returning false when it may not proceed.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	need := s.scopeFor(r)
+	if need == "" || s.signedLink(r) {
+		return true
+	}
+	scopes, ok, err := s.scopesFor(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "check api token", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return false
+	}
+	if scopes == nil {
+		// Authentication is off: no tokens are configured.
+		return true
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gopherclaw"`)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return false
+	}
+	if !slices.Contains(scopes, ScopeAdmin) && !slices.Contains(scopes, need) {
+		http.Error(w, fmt.Sprintf(`{"error":"forbidden: token lacks the %s scope"}`, need), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// scopesFor returns the scopes of the request's bearer token and whether
+// it matched a token. With no tokens configured anywhere it returns nil
+// scopes; otherwise scopes is non-nil even when nothing matched.
+func (s *Server) scopesFor(r *http.Request) ([]Scope, bool, error) {
+	var stored []*state.APIToken
+	if s.tokenStore != nil {
+		var err error
+		if stored, err = s.tokenStore.List(); err != nil {
+			return nil, false, err
+		}
+	}
+	if len(s.tokens) == 0 && len(stored) == 0 {
+		return nil, false, nil
+	}
+
+	none := []Scope{}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return none, false, nil
+	}
+	// Compare against every token so timing doesn't reveal a prefix match.
+	var match Role
+	for known, role := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			match = role
+		}
+	}
+	if match != "" {
+		return roleScopes(match), true, nil
+	}
+	if len(stored) == 0 {
+		return none, false, nil
+	}
+	found, err := s.tokenStore.Lookup(token)
+	if err != nil || found == nil {
+		return none, false, err
+	}
+	scopes := make([]Scope, len(found.Scopes))
+	for i, scope := range found.Scopes {
+		scopes[i] = Scope(scope)
+	}
+	return scopes, true, nil
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestTokenRoles(t *testing.T) {
+	srv := setupServer(t, &mockGateway{response: "done"})
+	srv.SetTokens(map[string]Role{"admin-secret": RoleAdmin, "watch-secret": RoleObserver})
+
+	do := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+	adHoc := `{"prompt":"hi","session_key":"http:x"}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"health is public", http.MethodGet, "/health", "", "", http.StatusOK},
+		{"dashboard is public", http.MethodGet, "/", "", "", http.StatusOK},
+		{"api needs a token", http.MethodGet, "/api/tasks", "", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/api/tasks", "nope", "", http.StatusUnauthorized},
+		{"observer reads", http.MethodGet, "/api/tasks", "watch-secret", "", http.StatusOK},
+		{"observer cannot run", http.MethodPost, "/webhook", "watch-secret", adHoc, http.StatusForbidden},
+		{"observer cannot broadcast", http.MethodPost, "/api/admin/broadcast", "watch-secret", `{"message":"x"}`, http.StatusForbidden},
+		{"observer cannot profile", http.MethodGet, "/debug/pprof/", "watch-secret", "", http.StatusForbidden},
+		{"admin reads", http.MethodGet, "/api/tasks", "admin-secret", "", http.StatusOK},
+		{"admin runs", http.MethodPost, "/webhook", "admin-secret", adHoc, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.path, tt.token, tt.body); got != tt.want {
+			t.Errorf("%s: %s %s got %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNoTokensLeavesAPIOpen(t *testing.T) {
+	srv := setupServer(t, &mockGateway{response: "done"})
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"prompt":"hi","session_key":"http:x"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected open API without tokens, got %d", w.Code)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	srv := setupServer(t, &mockGateway{response: "done"})
+	store := state.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	srv.SetTokenStore(store)
+
+	// An empty store leaves the API open, like no config tokens.
+	if code := doRequest(srv, http.MethodGet, "/api/tasks", "", ""); code != http.StatusOK {
+		t.Fatalf("empty token store: got %d, want 200", code)
+	}
+
+	dashboard, _, err := store.Create("dashboard", []string{"sessions:read", "tasks:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, _, err := store.Create("bot", []string{"chat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, _, err := store.Create("ci", []string{"tasks:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _, err := store.Create("root", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adHoc := `{"prompt":"hi","session_key":"http:x"}`
+	// setupServer has no session store, so a session read that passes
+	// authorization gets 503.
+	passed := http.StatusServiceUnavailable
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"api needs a token", http.MethodGet, "/api/sessions", "", "", http.StatusUnauthorized},
+		{"dashboard reads sessions", http.MethodGet, "/api/sessions", dashboard, "", passed},
+		{"dashboard reads tasks", http.MethodGet, "/api/tasks", dashboard, "", http.StatusOK},
+		{"dashboard reads status", http.MethodGet, "/api/admin/status", dashboard, "", http.StatusOK},
+		{"dashboard cannot chat", http.MethodPost, "/webhook", dashboard, adHoc, http.StatusForbidden},
+		{"dashboard cannot lock", http.MethodPost, "/api/sessions/s1/lock", dashboard, "", http.StatusForbidden},
+		{"dashboard cannot subscribe to push", http.MethodPost, "/api/push/subscriptions", dashboard, "", http.StatusForbidden},
+		{"dashboard cannot unsubscribe from push", http.MethodDelete, "/api/push/subscriptions", dashboard, "", http.StatusForbidden},
+		{"bot chats", http.MethodPost, "/webhook", bot, adHoc, http.StatusOK},
+		{"bot subscribes to push", http.MethodPost, "/api/push/subscriptions", bot, "", http.StatusServiceUnavailable},
+		{"bot cannot read sessions", http.MethodGet, "/api/sessions", bot, "", http.StatusForbidden},
+		{"bot cannot read tasks", http.MethodGet, "/api/tasks", bot, "", http.StatusForbidden},
+		{"bot cannot trigger tasks", http.MethodPost, "/webhook/deploy", bot, "", http.StatusForbidden},
+		{"ci cannot read tasks", http.MethodGet, "/api/tasks", ci, "", http.StatusForbidden},
+		{"ci cannot chat", http.MethodPost, "/webhook", ci, adHoc, http.StatusForbidden},
+		{"admin reads", http.MethodGet, "/api/sessions", root, "", passed},
+		{"admin chats", http.MethodPost, "/webhook", root, adHoc, http.StatusOK},
+		{"unknown path needs admin", http.MethodGet, "/nope", dashboard, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := doRequest(srv, tt.method, tt.path, tt.token, tt.body); got != tt.want {
+			t.Errorf("%s: %s %s got %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if err := store.Revoke("bot"); err != nil {
+		t.Fatal(err)
+	}
+	if code := doRequest(srv, http.MethodPost, "/webhook", bot, adHoc); code != http.StatusUnauthorized {
+		t.Errorf("revoked token: got %d, want 401", code)
+	}
+}
+
+func doRequest(srv *Server, method, path, token, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w.Code
+}
@@ -0,0 +1,183 @@
+// internal/webhook/batch.go
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBatchItems bounds a single POST /api/batch request.
+	maxBatchItems = 1000
+	// batchWorkers is how many items of a job are in flight at once. The
+	// gateway queue still orders runs within a session.
+	batchWorkers = 4
+	// maxBatchJobs is how many jobs are kept for polling; the oldest
+	// finished jobs are dropped first.
+	maxBatchJobs = 100
+)
+
+// batchItem is one prompt in a batch and, once processed, its outcome.
+type batchItem struct {
+	SessionKey string `json:"session_key"`
+	Prompt     string `json:"prompt"`
+	Status     string `json:"status"` // pending, done, failed
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// batchJob tracks a batch submitted to POST /api/batch.
+type batchJob struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"` // running, done
+	Total      int         `json:"total"`
+	Completed  int         `json:"completed"`
+	Failed     int         `json:"failed"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Items      []batchItem `json:"items"`
+}
+
+// batchJobs holds jobs in memory; they do not survive a restart.
+type batchJobs struct {
+	mu    sync.Mutex
+	jobs  map[string]*batchJob
+	order []string
+}
+
+func newBatchJobs() *batchJobs {
+	return &batchJobs{jobs: make(map[string]*batchJob)}
+}
+
+// add registers a job, evicting the oldest finished jobs over the limit.
+func (b *batchJobs) add(job *batchJob) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jobs[job.ID] = job
+	b.order = append(b.order, job.ID)
+	for i := 0; len(b.jobs) > maxBatchJobs && i < len(b.order); {
+		id := b.order[i]
+		if b.jobs[id].Status == "done" {
+			delete(b.jobs, id)
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// snapshot returns a copy of the job safe to encode without the lock.
+func (b *batchJobs) snapshot(id string) (*batchJob, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	job, ok := b.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *job
+	cp.Items = append([]batchItem(nil), job.Items...)
+	return &cp, true
+}
+
+// finishItem records the outcome of item i.
+func (b *batchJobs) finishItem(job *batchJob, i int, resp string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	item := &job.Items[i]
+	if err != nil {
+		item.Status = "failed"
+		item.Error = err.Error()
+		job.Failed++
+	} else {
+		item.Status = "done"
+		item.Response = resp
+	}
+	job.Completed++
+	if job.Completed == job.Total {
+		now := time.Now()
+		job.Status = "done"
+		job.FinishedAt = &now
+	}
+}
+
+// batchRequest is the JSON body for POST /api/batch.
+type batchRequest struct {
+	Items []struct {
+		SessionKey string `json:"session_key"`
+		Prompt     string `json:"prompt"`
+	} `json:"items"`
+}
+
+func (s *Server) handleAPIBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, `{"error":"items are required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		http.Error(w, `{"error":"too many items"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	job := &batchJob{
+		ID:        uuid.New().String(),
+		Status:    "running",
+		Total:     len(req.Items),
+		CreatedAt: time.Now(),
+		Items:     make([]batchItem, len(req.Items)),
+	}
+	for i, it := range req.Items {
+		if it.SessionKey == "" || it.Prompt == "" {
+			http.Error(w, `{"error":"each item needs session_key and prompt"}`, http.StatusBadRequest)
+			return
+		}
+		job.Items[i] = batchItem{SessionKey: it.SessionKey, Prompt: it.Prompt, Status: "pending"}
+	}
+	s.batches.add(job)
+	go s.runBatch(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"id": job.ID, "total": job.Total})
+}
+
+// runBatch processes a job's items through the task handler with bounded
+// parallelism.
+func (s *Server) runBatch(job *batchJob) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < batchWorkers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				item := job.Items[i]
+				resp, err := s.handler(item.SessionKey, item.Prompt)
+				s.batches.finishItem(job, i, resp, err)
+			}
+		}()
+	}
+	for i := range job.Items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+func (s *Server) handleAPIBatchStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.batches.snapshot(r.PathValue("id"))
+	if !ok {
+		http.Error(w, `{"error":"batch not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// runManifest is manifest.json in a run's artifact bundle.
+type runManifest struct {
+	RunID      types.RunID      `json:"run_id"`
+	SessionID  types.SessionID  `json:"session_id"`
+	SessionKey types.SessionKey `json:"session_key,omitempty"`
+	Started    time.Time        `json:"started"`
+	Finished   time.Time        `json:"finished"`
+	ToolCalls  []manifestCall   `json:"tool_calls"`
+	Artifacts  []manifestFile   `json:"artifacts"`
+}
+
+// manifestCall is one tool call of the run and what became of it.
+type manifestCall struct {
+	Tool      string          `json:"tool"`
+	CallID    string          `json:"call_id,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	At        time.Time       `json:"at"`
+	// Result is the result as the model saw it; for large outputs that is
+	// the truncated text, and File names the full output in the bundle.
+	Result  string `json:"result,omitempty"`
+	File    string `json:"file,omitempty"`
+	Planned bool   `json:"planned,omitempty"`
+}
+
+// manifestFile is one artifact in the bundle.
+type manifestFile struct {
+	ID      types.ArtifactID `json:"id"`
+	Tool    string           `json:"tool"`
+	File    string           `json:"file"`
+	Created time.Time        `json:"created_at"`
+	Summary string           `json:"summary,omitempty"`
+	Size    int              `json:"size"`
+}
+
+// bundleFile is a file waiting to be written to the zip.
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// handleAPIRunArtifacts serves every artifact a run produced, plus a
+// manifest of its tool calls, as one zip. ?session= names the run's
+// session and skips the search for it.
+func (s *Server) handleAPIRunArtifacts(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.events == nil || s.artifacts == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	ctx := r.Context()
+	runID := types.RunID(r.PathValue("id"))
+
+	sess, events, err := s.findRun(ctx, runID, types.SessionID(r.URL.Query().Get("session")))
+	if err != nil {
+		slog.Error("find run failed", "run_id", runID, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	if sess == nil {
+		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
+		return
+	}
+
+	manifest, files, err := s.runBundle(ctx, sess, runID, events)
+	if err != nil {
+		slog.Error("bundle run artifacts failed", "run_id", runID, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%s.zip"`, safeName(string(runID))))
+	zw := zip.NewWriter(w)
+	for _, f := range append([]bundleFile{{"manifest.json", manifestJSON}}, files...) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: manifest.Finished})
+		if err == nil {
+			_, err = fw.Write(f.data)
+		}
+		if err != nil {
+			// The status is already sent; a truncated zip is all we can do.
+			slog.Warn("write run bundle failed", "run_id", runID, "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		slog.Warn("write run bundle failed", "run_id", runID, "error", err)
+	}
+}
+
+// findRun returns the session a run belongs to and the run's events, or a
+// nil session if no session has it. Without a hint, sessions whose last
+// run it was are tried first, then the rest, most recently updated first.
+func (s *Server) findRun(ctx context.Context, runID types.RunID, hint types.SessionID) (*types.SessionIndex, []*types.Event, error) {
+	var candidates []*types.SessionIndex
+	if hint != "" {
+		sess, err := s.sessions.Get(ctx, hint)
+		if err != nil {
+			return nil, nil, nil
+		}
+		candidates = []*types.SessionIndex{sess}
+	} else {
+		all, err := s.sessions.List(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list sessions: %w", err)
+		}
+		sort.SliceStable(all, func(i, j int) bool {
+			if (all[i].LastRunID == runID) != (all[j].LastRunID == runID) {
+				return all[i].LastRunID == runID
+			}
+			return all[i].UpdatedAt.After(all[j].UpdatedAt)
+		})
+		candidates = all
+	}
+
+	for _, sess := range candidates {
+		count, err := s.events.Count(ctx, sess.SessionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("count events: %w", err)
+		}
+		all, err := s.events.Tail(ctx, sess.SessionID, int(count))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read events: %w", err)
+		}
+		var events []*types.Event
+		for _, ev := range all {
+			if ev.RunID == runID {
+				events = append(events, ev)
+			}
+		}
+		if len(events) > 0 {
+			return sess, events, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// runBundle builds the manifest and artifact files for a run's events.
+func (s *Server) runBundle(ctx context.Context, sess *types.SessionIndex, runID types.RunID, events []*types.Event) (*runManifest, []bundleFile, error) {
+	manifest := &runManifest{
+		RunID:      runID,
+		SessionID:  sess.SessionID,
+		SessionKey: sess.SessionKey,
+		Started:    events[0].At,
+		Finished:   events[len(events)-1].At,
+		ToolCalls:  []manifestCall{},
+		Artifacts:  []manifestFile{},
+	}
+
+	var files []bundleFile
+	calls := make(map[string]int) // call ID -> index in ToolCalls
+	for _, ev := range events {
+		switch ev.Type {
+		case "tool_call":
+			var p struct {
+				Tool      string          `json:"tool"`
+				CallID    string          `json:"call_id"`
+				Arguments json.RawMessage `json:"arguments"`
+			}
+			if err := json.Unmarshal(ev.Payload, &p); err != nil {
+				continue
+			}
+			// Arguments are recorded as the model's JSON string.
+			var args string
+			if json.Unmarshal(p.Arguments, &args) == nil && json.Valid([]byte(args)) {
+				p.Arguments = json.RawMessage(args)
+			}
+			calls[p.CallID] = len(manifest.ToolCalls)
+			manifest.ToolCalls = append(manifest.ToolCalls, manifestCall{Tool: p.Tool, CallID: p.CallID, Arguments: p.Arguments, At: ev.At})
+		case "tool_result":
+			var p struct {
+				Tool       string `json:"tool"`
+				CallID     string `json:"call_id"`
+				Result     string `json:"result"`
+				ArtifactID string `json:"artifact_id"`
+				Planned    bool   `json:"planned"`
+			}
+			if err := json.Unmarshal(ev.Payload, &p); err != nil {
+				continue
+			}
+			i, ok := calls[p.CallID]
+			if !ok {
+				i = len(manifest.ToolCalls)
+				manifest.ToolCalls = append(manifest.ToolCalls, manifestCall{Tool: p.Tool, CallID: p.CallID, At: ev.At})
+			}
+			call := &manifest.ToolCalls[i]
+			call.Result, call.Planned = p.Result, p.Planned
+			if p.ArtifactID == "" {
+				continue
+			}
+			file, err := s.artifactFile(ctx, types.ArtifactID(p.ArtifactID), len(files)+1)
+			if err != nil {
+				slog.Warn("run artifact missing from bundle", "artifact_id", p.ArtifactID, "error", err)
+				continue
+			}
+			call.File = file.File
+			manifest.Artifacts = append(manifest.Artifacts, file.manifestFile)
+			files = append(files, file.bundleFile)
+		}
+	}
+	return manifest, files, nil
+}
+
+// artifactEntry is an artifact ready for the bundle.
+type artifactEntry struct {
+	manifestFile
+	bundleFile
+}
+
+// artifactFile loads an artifact and names it for the bundle, numbered in
+// run order. Text is written as-is; structured data as JSON.
+func (s *Server) artifactFile(ctx context.Context, id types.ArtifactID, n int) (*artifactEntry, error) {
+	meta, err := s.artifacts.GetMeta(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.artifacts.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, ext := []byte(data), ".json"
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		switch raw, ok := fileBytes(meta, text); {
+		case ok:
+			content, ext = raw, ".bin"
+		default:
+			content = []byte(text)
+			switch detectKind(meta.Tool, text) {
+			case viewJSON:
+				ext = ".json"
+			case viewMarkdown:
+				ext = ".md"
+			default:
+				ext = ".txt"
+			}
+		}
+	}
+	name := fmt.Sprintf("artifacts/%02d-%s-%s%s", n, safeName(meta.Tool), safeName(string(id)), ext)
+	return &artifactEntry{
+		manifestFile: manifestFile{ID: id, Tool: meta.Tool, File: name, Created: meta.CreatedAt, Summary: meta.Summary, Size: len(content)},
+		bundleFile:   bundleFile{name: name, data: content},
+	}, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeName makes s usable as a file name inside the zip or a header.
+func safeName(s string) string {
+	return unsafeNameChars.ReplaceAllString(s, "_")
+}
@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestRunArtifactsZip(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	ctx := context.Background()
+
+	// The run lives in an older session, so the search has to go past the
+	// most recently updated one.
+	sid, err := sessions.ResolveOrCreate(ctx, "http:reports", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.ResolveOrCreate(ctx, "http:other", "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	runID := types.NewRunID()
+	report := "# Q3 report\n\nRevenue is up."
+	artID, err := artifacts.Put(ctx, sid, runID, "bash", report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := func(typ string, payload map[string]any) *types.Event {
+		raw, _ := json.Marshal(payload)
+		return &types.Event{ID: types.NewEventID(), SessionID: sid, RunID: runID, Type: typ, Source: "runtime", At: time.Now(), Payload: raw}
+	}
+	if err := events.AppendBatch(ctx, []*types.Event{
+		{ID: types.NewEventID(), SessionID: sid, RunID: types.NewRunID(), Type: "user_message", Source: "http", At: time.Now(), Payload: json.RawMessage(`{"text":"earlier"}`)},
+		event("user_message", map[string]any{"text": "generate the reports"}),
+		event("tool_call", map[string]any{"tool": "bash", "call_id": "c1", "arguments": `{"command":"make report"}`}),
+		event("tool_result", map[string]any{"tool": "bash", "call_id": "c1", "result": "# Q3 report…", "artifact_id": string(artID)}),
+		event("tool_call", map[string]any{"tool": "memory_list", "call_id": "c2", "arguments": `{}`}),
+		event("tool_result", map[string]any{"tool": "memory_list", "call_id": "c2", "result": "no memories"}),
+		event("assistant_message", map[string]any{"text": "Done."}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil, sessions, events, artifacts)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/runs/" + string(runID) + "/artifacts.zip")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	var manifest runManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("manifest: %v (files %v)", err, files)
+	}
+	if manifest.SessionID != sid || len(manifest.ToolCalls) != 2 || len(manifest.Artifacts) != 1 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	call := manifest.ToolCalls[0]
+	var args bytes.Buffer
+	json.Compact(&args, call.Arguments)
+	if call.Tool != "bash" || args.String() != `{"command":"make report"}` || call.File == "" {
+		t.Errorf("unexpected call %+v", call)
+	}
+	if !strings.HasSuffix(call.File, ".md") || files[call.File] != report {
+		t.Errorf("artifact file %q = %q", call.File, files[call.File])
+	}
+	if manifest.ToolCalls[1].Result != "no memories" || manifest.ToolCalls[1].File != "" {
+		t.Errorf("unexpected call %+v", manifest.ToolCalls[1])
+	}
+
+	if w := get("/api/runs/" + string(runID) + "/artifacts.zip?session=" + string(sid)); w.Code != http.StatusOK {
+		t.Errorf("with session hint: got %d", w.Code)
+	}
+	if w := get("/api/runs/" + string(types.NewRunID()) + "/artifacts.zip"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown run: got %d", w.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SocketPath reports whether an http.listen value names a unix socket,
+// written either as "unix:<path>" or as an absolute path, and returns the
+// path.
+func SocketPath(listen string) (string, bool) {
+	if path, ok := strings.CutPrefix(listen, "unix:"); ok {
+		return path, true
+	}
+	if strings.HasPrefix(listen, "/") {
+		return listen, true
+	}
+	return "", false
+}
+
+// Listen opens the listener for an http.listen value. Unix sockets are
+// created owner-only, replacing a stale socket left by a crash but not one
+// another process still listens on. A TCP
+// address without a host, such as ":8484", binds 127.0.0.1 only; to listen
+// on every interface, say so with "0.0.0.0:8484".
+func Listen(listen string) (net.Listener, error) {
+	if path, ok := SocketPath(listen); ok {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			conn, err := net.DialTimeout("unix", path, time.Second)
+			switch {
+			case err == nil:
+				conn.Close()
+				return nil, fmt.Errorf("listen on unix socket: %s is in use by another process", path)
+			case !errors.Is(err, syscall.ECONNREFUSED):
+				return nil, fmt.Errorf("check existing unix socket: %w", err)
+			}
+			os.Remove(path)
+		}
+		// The umask keeps the socket from ever being open to others; the
+		// chmod below only narrows it further. Files created meanwhile by
+		// other goroutines lose nothing but group and other access.
+		mask := syscall.Umask(0o077)
+		ln, err := net.Listen("unix", path)
+		syscall.Umask(mask)
+		if err != nil {
+			return nil, fmt.Errorf("listen on unix socket: %w", err)
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("restrict unix socket: %w", err)
+		}
+		return ln, nil
+	}
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, fmt.Errorf("parse listen address: %w", err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return ln, nil
+}
+
+// Exposed reports whether an http.listen value accepts connections from
+// other machines: anything but a unix socket, a bare port, or a loopback
+// address.
+func Exposed(listen string) bool {
+	if _, ok := SocketPath(listen); ok {
+		return false
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil || host == "" || host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// NewClient returns an HTTP client and base URL for talking to a daemon
+// listening on an http.listen value, over its unix socket if it has one.
+func NewClient(listen string, timeout time.Duration) (*http.Client, string) {
+	path, ok := SocketPath(listen)
+	if !ok {
+		host, port, err := net.SplitHostPort(listen)
+		if err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+			listen = net.JoinHostPort("127.0.0.1", port)
+		}
+		return &http.Client{Timeout: timeout}, "http://" + listen
+	}
+	var dialer net.Dialer
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, "http://gopherclaw"
+}
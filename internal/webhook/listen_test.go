@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gc.sock")
+	// A stale socket from a crashed daemon must not block startup.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected owner-only socket, got %v", perm)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client, base := NewClient(path, time.Second)
+	resp, err := client.Get(base + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestListenKeepsLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gc.sock")
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	if ln, err := Listen("unix:" + path); err == nil {
+		ln.Close()
+		t.Fatal("expected an error for a socket in use")
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("the live socket was removed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenBarePortIsLoopback(t *testing.T) {
+	ln, err := Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().(*net.TCPAddr)
+	if !addr.IP.IsLoopback() {
+		t.Errorf("expected loopback bind, got %s", addr)
+	}
+}
+
+func TestExposed(t *testing.T) {
+	for listen, want := range map[string]bool{
+		"127.0.0.1:8484":    false,
+		"localhost:8484":    false,
+		"[::1]:8484":        false,
+		":8484":             false,
+		"unix:/run/gc.sock": false,
+		"/run/gc.sock":      false,
+		"0.0.0.0:8484":      true,
+		"[::]:8484":         true,
+		"192.168.1.5:8484":  true,
+		"myhost:8484":       true,
+	} {
+		if got := Exposed(listen); got != want {
+			t.Errorf("Exposed(%q) = %v, want %v", listen, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// SetMacroStore enables GET /api/macros and POST /api/macros/{name}/run.
+func (s *Server) SetMacroStore(macros *state.MacroStore) {
+	s.macros = macros
+}
+
+func (s *Server) handleAPIMacros(w http.ResponseWriter, r *http.Request) {
+	if s.macros == nil {
+		http.Error(w, `{"error":"macros not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	macros, err := s.macros.List()
+	if err != nil {
+		slog.Error("list macros failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(macros)
+}
+
+// macroRunRequest is the JSON body for POST /api/macros/{name}/run.
+type macroRunRequest struct {
+	SessionKey string            `json:"session_key"`
+	Args       map[string]string `json:"args"`
+}
+
+// macroRunResponse reports the expanded prompt alongside the run's reply.
+type macroRunResponse struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	*RunDetails
+}
+
+func (s *Server) handleAPIMacroRun(w http.ResponseWriter, r *http.Request) {
+	if s.macros == nil || s.runs == nil {
+		http.Error(w, `{"error":"macros not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req macroRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.SessionKey == "" {
+		http.Error(w, `{"error":"session_key is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	macro, err := s.macros.Get(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, `{"error":"macro not found"}`, http.StatusNotFound)
+		return
+	}
+	prompt, err := macro.Render(req.Args)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	res, err := s.runs(&types.InboundEvent{
+		Source:     "http",
+		SessionKey: types.SessionKey(req.SessionKey),
+		UserID:     "http",
+		Text:       prompt,
+		Metadata: &types.InboundMeta{
+			Version: types.InboundSchemaVersion,
+			Headers: requestHeaders(r),
+		},
+	})
+	if errors.Is(err, gateway.ErrSessionLocked) {
+		writeLocked(w, err)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidSessionKey) {
+		writeInvalidKey(w, err)
+		return
+	}
+	if err != nil {
+		slog.Error("macro run failed", "macro", macro.Name, "session_key", req.SessionKey, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(macroRunResponse{Prompt: prompt, Response: res.Text, RunDetails: newRunDetails(res)})
+}
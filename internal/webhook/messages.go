@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// messageRequest is the JSON body for POST /api/sessions/{id}/messages.
+type messageRequest struct {
+	Text string `json:"text"`
+	// UserID names the sender in the event log (default "http").
+	UserID string `json:"user_id,omitempty"`
+}
+
+// messageResponse is the reply to a message sent into a session.
+type messageResponse struct {
+	SessionID  types.SessionID  `json:"session_id"`
+	SessionKey types.SessionKey `json:"session_key"`
+	Response   string           `json:"response"`
+	*RunDetails
+}
+
+// handleAPIMessage runs a message in an existing conversation and returns
+// the response. {id} is a session ID or a session key; a key without a
+// session starts one, while an archived session ID is refused, since its
+// key now leads to a different conversation.
+func (s *Server) handleAPIMessage(w http.ResponseWriter, r *http.Request) {
+	if s.runs == nil || s.sessions == nil {
+		http.Error(w, `{"error":"runs not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, `{"error":"text is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	ref := r.PathValue("id")
+	key := types.SessionKey(ref)
+	if strings.Contains(ref, ":") {
+		if _, err := key.Parse(); err != nil {
+			writeInvalidKey(w, err)
+			return
+		}
+	} else {
+		sess, err := s.sessions.Get(r.Context(), types.SessionID(ref))
+		if err != nil {
+			http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+			return
+		}
+		if sess.Status == "archived" {
+			http.Error(w, `{"error":"session is archived; send to its session key to continue the conversation"}`, http.StatusConflict)
+			return
+		}
+		key = sess.SessionKey
+	}
+	userID := req.UserID
+	if userID == "" {
+		userID = "http"
+	}
+
+	res, err := s.runs(&types.InboundEvent{
+		Source:     "http",
+		SessionKey: key,
+		UserID:     userID,
+		Text:       req.Text,
+		Metadata: &types.InboundMeta{
+			Version: types.InboundSchemaVersion,
+			Headers: requestHeaders(r),
+		},
+	})
+	if errors.Is(err, gateway.ErrSessionLocked) {
+		writeLocked(w, err)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidSessionKey) {
+		writeInvalidKey(w, err)
+		return
+	}
+	if err != nil {
+		slog.Error("session message run failed", "session_key", key, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messageResponse{
+		SessionID:  res.SessionID,
+		SessionKey: key,
+		Response:   res.Text,
+		RunDetails: newRunDetails(res),
+	})
+}
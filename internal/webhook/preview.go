@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// PromptPreviewer rebuilds past prompts; *runtime.Runtime implements it.
+type PromptPreviewer interface {
+	PromptAt(ctx context.Context, sessionID types.SessionID, seq int64) (*runtime.PromptPreview, error)
+	PromptForRun(ctx context.Context, sessionID types.SessionID, runID types.RunID) (*runtime.PromptPreview, error)
+}
+
+// SetPromptPreviewer enables GET /api/sessions/{id}/prompt.
+func (s *Server) SetPromptPreviewer(p PromptPreviewer) {
+	s.previewer = p
+}
+
+// handleAPIPrompt returns the prompt a session would have sent as of
+// ?seq=N (right after event N) or for ?run=ID (the run's last LLM call).
+func (s *Server) handleAPIPrompt(w http.ResponseWriter, r *http.Request) {
+	if s.previewer == nil {
+		http.Error(w, `{"error":"prompt preview not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	sessionID := types.SessionID(r.PathValue("id"))
+	q := r.URL.Query()
+
+	var (
+		preview *runtime.PromptPreview
+		err     error
+	)
+	switch {
+	case q.Get("run") != "":
+		preview, err = s.previewer.PromptForRun(r.Context(), sessionID, types.RunID(q.Get("run")))
+	case q.Get("seq") != "":
+		seq, convErr := strconv.ParseInt(q.Get("seq"), 10, 64)
+		if convErr != nil {
+			http.Error(w, `{"error":"seq must be an integer"}`, http.StatusBadRequest)
+			return
+		}
+		preview, err = s.previewer.PromptAt(r.Context(), sessionID, seq)
+	default:
+		http.Error(w, `{"error":"seq or run is required"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+//go:embed static/sw.js
+var serviceWorkerJS []byte
+
+//go:embed static/manifest.webmanifest
+var webManifest []byte
+
+// SetPush enables the push subscription API for the web UI. publicKey is
+// the VAPID public key browsers subscribe with.
+func (s *Server) SetPush(store *state.PushStore, publicKey string) {
+	s.push = store
+	s.pushKey = publicKey
+}
+
+// handleServiceWorker serves the worker that shows push notifications. It
+// is public: browsers fetch it without the dashboard's token.
+func (s *Server) handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(serviceWorkerJS)
+}
+
+// handleManifest serves the web app manifest that lets the dashboard be
+// installed as an app.
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Write(webManifest)
+}
+
+func (s *Server) handleAPIPushKey(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		http.Error(w, `{"error":"web push not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"public_key": s.pushKey})
+}
+
+// pushSubscribeRequest is the JSON body for POST /api/push/subscriptions:
+// a name and the browser's PushSubscription.toJSON().
+type pushSubscribeRequest struct {
+	Name         string `json:"name"`
+	Subscription struct {
+		Endpoint string `json:"endpoint"`
+		Keys     struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	} `json:"subscription"`
+}
+
+func (s *Server) handleAPIPushSubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		http.Error(w, `{"error":"web push not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req pushSubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	key, err := types.ParseSessionKey("webpush:" + req.Name)
+	if err != nil {
+		writeInvalidKey(w, err)
+		return
+	}
+	sub := req.Subscription
+	if u, err := url.Parse(sub.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+		http.Error(w, `{"error":"subscription endpoint must be an https URL"}`, http.StatusBadRequest)
+		return
+	}
+	if sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		http.Error(w, `{"error":"subscription keys are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.push.Put(&state.PushSubscription{
+		Name:      req.Name,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.Keys.P256dh,
+		Auth:      sub.Keys.Auth,
+		UserAgent: r.UserAgent(),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		slog.Error("save push subscription failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"session_key": string(key.Key())})
+}
+
+// pushUnsubscribeRequest is the JSON body for DELETE /api/push/subscriptions.
+type pushUnsubscribeRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+func (s *Server) handleAPIPushUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		http.Error(w, `{"error":"web push not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req pushUnsubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+		http.Error(w, `{"error":"endpoint is required"}`, http.StatusBadRequest)
+		return
+	}
+	removed, err := s.push.Remove(req.Endpoint)
+	if err != nil {
+		slog.Error("remove push subscription failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"removed": removed})
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+func TestAPIPushSubscriptions(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/api/push/key", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without web push, got %d", w.Code)
+	}
+
+	store := state.NewPushStore(filepath.Join(t.TempDir(), "push.json"))
+	srv.SetPush(store, "BPublicKey")
+
+	w := do(http.MethodGet, "/api/push/key", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"public_key":"BPublicKey"`) {
+		t.Errorf("GET /api/push/key = %d %s", w.Code, w.Body)
+	}
+
+	sub := `{"endpoint":"https://push.example/abc","keys":{"p256dh":"key","auth":"secret"}}`
+	if w := do(http.MethodPost, "/api/push/subscriptions", `{"name":"","subscription":`+sub+`}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty name, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/push/subscriptions", `{"name":"alice","subscription":{"endpoint":"http://10.0.0.1/x","keys":{"p256dh":"key","auth":"secret"}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-https endpoint, got %d", w.Code)
+	}
+	w = do(http.MethodPost, "/api/push/subscriptions", `{"name":"alice","subscription":`+sub+`}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"session_key":"webpush:alice"`) {
+		t.Fatalf("subscribe = %d %s", w.Code, w.Body)
+	}
+	if subs, _ := store.ForName("alice"); len(subs) != 1 || subs[0].Auth != "secret" {
+		t.Errorf("expected the subscription to be stored, got %+v", subs)
+	}
+
+	w = do(http.MethodDelete, "/api/push/subscriptions", `{"endpoint":"https://push.example/abc"}`)
+	var resp map[string]bool
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp["removed"] {
+		t.Errorf("unsubscribe = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServiceWorkerIsPublic(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+	srv.SetPush(state.NewPushStore(filepath.Join(t.TempDir(), "push.json")), "BPublicKey")
+	srv.SetTokens(map[string]Role{"admin-secret": RoleAdmin})
+
+	for path, want := range map[string]int{
+		"/sw.js":                http.StatusOK,
+		"/manifest.webmanifest": http.StatusOK,
+		"/api/push/key":         http.StatusUnauthorized,
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s without a token = %d, want %d", path, w.Code, want)
+		}
+	}
+}
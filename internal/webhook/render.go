@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// renderMarkdown renders the common subset of markdown that tool output
+// uses: headings, fenced code, lists, quotes, rules, paragraphs, and inline
+// code, emphasis and links. Everything is HTML-escaped first.
+func renderMarkdown(text string) template.HTML {
+	var out strings.Builder
+	var para []string
+	list := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + inlineMarkdown(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			closeList()
+			out.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushPara()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString(`<pre class="code">` + highlight(strings.Join(code, "\n")) + "</pre>\n")
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case headingLevel(trimmed) > 0:
+			flushPara()
+			closeList()
+			level := headingLevel(trimmed)
+			tag := string(rune('0' + level))
+			out.WriteString("<h" + tag + ">" + inlineMarkdown(strings.TrimSpace(trimmed[level:])) + "</h" + tag + ">\n")
+		case trimmed == "---" || trimmed == "***":
+			flushPara()
+			closeList()
+			out.WriteString("<hr>\n")
+		case strings.HasPrefix(trimmed, "> "):
+			flushPara()
+			closeList()
+			out.WriteString("<blockquote>" + inlineMarkdown(trimmed[2:]) + "</blockquote>\n")
+		case bulletItem(trimmed) != "":
+			flushPara()
+			openList("ul")
+			out.WriteString("<li>" + inlineMarkdown(bulletItem(trimmed)) + "</li>\n")
+		case orderedItem.MatchString(trimmed):
+			flushPara()
+			openList("ol")
+			out.WriteString("<li>" + inlineMarkdown(orderedItem.ReplaceAllString(trimmed, "")) + "</li>\n")
+		default:
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	closeList()
+	return template.HTML(out.String())
+}
+
+var (
+	orderedItem = regexp.MustCompile(`^\d+[.)] `)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdBold      = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdItalic    = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// headingLevel returns the level of an ATX heading line, or 0.
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || n >= len(line) || line[n] != ' ' {
+		return 0
+	}
+	return n
+}
+
+// bulletItem returns the text of an unordered list item, or "".
+func bulletItem(line string) string {
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if item, ok := strings.CutPrefix(line, marker); ok {
+			return item
+		}
+	}
+	return ""
+}
+
+// inlineMarkdown escapes text and renders code spans, bold, italics and
+// http(s) links. Code spans are left untouched by the other rules.
+func inlineMarkdown(text string) string {
+	parts := strings.Split(text, "`")
+	var out strings.Builder
+	for i, part := range parts {
+		escaped := template.HTMLEscapeString(part)
+		if i%2 == 1 && i < len(parts)-1 {
+			out.WriteString("<code>" + escaped + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			out.WriteString("`") // unmatched backtick
+		}
+		escaped = mdLink.ReplaceAllString(escaped, `<a href="$2" rel="noopener noreferrer">$1</a>`)
+		escaped = mdBold.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = mdItalic.ReplaceAllString(escaped, "<em>$1</em>")
+		out.WriteString(escaped)
+	}
+	return out.String()
+}
+
+// highlightKeywords are the keywords of the languages tools usually print:
+// Go, Python, JavaScript and shell.
+var highlightKeywords = map[string]bool{
+	"func": true, "return": true, "if": true, "else": true, "for": true, "while": true,
+	"range": true, "import": true, "package": true, "def": true, "class": true,
+	"const": true, "let": true, "var": true, "type": true, "struct": true,
+	"interface": true, "true": true, "false": true, "nil": true, "null": true,
+	"None": true, "True": true, "False": true, "switch": true, "case": true,
+	"break": true, "continue": true, "go": true, "defer": true, "from": true,
+	"as": true, "try": true, "except": true, "catch": true, "finally": true,
+	"new": true, "in": true, "then": true, "fi": true, "do": true, "done": true,
+	"function": true, "async": true, "await": true, "export": true, "with": true,
+}
+
+// highlight escapes source code and wraps strings, comments, numbers and
+// keywords in spans for the page's stylesheet. It is a lexer-free
+// approximation that works across common languages and JSON.
+func highlight(code string) string {
+	var out strings.Builder
+	span := func(class, text string) {
+		out.WriteString(`<span class="` + class + `">` + template.HTMLEscapeString(text) + `</span>`)
+	}
+	rs := []rune(code)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '"' || r == '\'' || r == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != r && (r == '`' || rs[j] != '\n') {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(rs))
+			span("s", string(rs[i:j]))
+			i = j
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/', r == '#' && (i == 0 || unicode.IsSpace(rs[i-1])):
+			j := i
+			for j < len(rs) && rs[j] != '\n' {
+				j++
+			}
+			span("c", string(rs[i:j]))
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			span("n", string(rs[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			if word := string(rs[i:j]); highlightKeywords[word] {
+				span("k", word)
+			} else {
+				out.WriteString(template.HTMLEscapeString(word))
+			}
+			i = j
+		default:
+			out.WriteString(template.HTMLEscapeString(string(r)))
+			i++
+		}
+	}
+	return out.String()
+}
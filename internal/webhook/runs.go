@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// defaultRunLimit is how many runs GET /api/runs returns without ?limit=.
+const defaultRunLimit = 50
+
+// SetRunStore enables GET /api/runs and GET /api/runs/{id}.
+func (s *Server) SetRunStore(runs *state.RunStore) {
+	s.runStore = runs
+}
+
+// handleAPIRuns lists run records, newest first, filtered by ?session=
+// and ?status= and capped by ?limit=.
+func (s *Server) handleAPIRuns(w http.ResponseWriter, r *http.Request) {
+	if s.runStore == nil {
+		http.Error(w, `{"error":"run records not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	query := r.URL.Query()
+	filter := state.RunFilter{
+		SessionID: types.SessionID(query.Get("session")),
+		Status:    query.Get("status"),
+		Limit:     defaultRunLimit,
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	runs, err := s.runStore.List(filter)
+	if err != nil {
+		slog.Error("list runs failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (s *Server) handleAPIRun(w http.ResponseWriter, r *http.Request) {
+	if s.runStore == nil {
+		http.Error(w, `{"error":"run records not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	run, err := s.runStore.Get(types.RunID(r.PathValue("id")))
+	if err != nil {
+		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestAPIRuns(t *testing.T) {
+	dir := t.TempDir()
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil, state.NewSessionStore(dir), state.NewEventStore(dir), state.NewArtifactStore(dir))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	if w := get("/api/runs"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a run store, got %d", w.Code)
+	}
+
+	runs := state.NewRunStore(filepath.Join(dir, "runs.jsonl"))
+	srv.SetRunStore(runs)
+	now := time.Now()
+	runs.Put(&types.RunRecord{ID: "r1", SessionID: "s1", Status: "complete", CreatedAt: now.Add(-time.Minute), InputTokens: 120})
+	runs.Put(&types.RunRecord{ID: "r2", SessionID: "s2", Status: "failed", CreatedAt: now, Error: "LLM call: boom"})
+
+	var list []types.RunRecord
+	w := get("/api/runs?status=complete")
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != "r1" || list[0].InputTokens != 120 {
+		t.Errorf("filtered runs = %+v", list)
+	}
+	w = get("/api/runs?limit=1")
+	list = nil
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].ID != "r2" {
+		t.Errorf("limited runs = %+v, want the newest", list)
+	}
+	if w := get("/api/runs?limit=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit=0, got %d", w.Code)
+	}
+
+	var run types.RunRecord
+	w = get("/api/runs/r2")
+	if err := json.NewDecoder(w.Body).Decode(&run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Error != "LLM call: boom" {
+		t.Errorf("run = %+v", run)
+	}
+	if w := get("/api/runs/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
@@ -4,14 +4,26 @@ package webhook
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/feedback"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/scheduler"
 	"github.com/user/gopherclaw/internal/state"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 //go:embed static/index.html
@@ -20,14 +32,76 @@ var indexHTML []byte
 // TaskHandler is a callback that processes a prompt within the given session.
 type TaskHandler func(sessionKey, prompt string) (string, error)
 
+// RunHandler is a callback that processes a full inbound event, including
+// attachments, and returns the run's result.
+type RunHandler func(event *types.InboundEvent) (*gateway.RunResult, error)
+
+// RunDetails is what a run did besides replying, returned by the endpoints
+// that wait for a run.
+type RunDetails struct {
+	RunID     types.RunID        `json:"run_id"`
+	Artifacts []types.ArtifactID `json:"artifacts,omitempty"`
+	ToolCalls []gateway.ToolCall `json:"tool_calls,omitempty"`
+	Usage     llm.Usage          `json:"usage"`
+	// Error is why the run failed; the response is then an apology.
+	Error string `json:"error,omitempty"`
+}
+
+func newRunDetails(res *gateway.RunResult) *RunDetails {
+	d := &RunDetails{
+		RunID:     res.RunID,
+		Artifacts: res.Artifacts,
+		ToolCalls: res.ToolCalls,
+		Usage:     res.Usage,
+	}
+	if res.Err != nil {
+		d.Error = res.Err.Error()
+	}
+	return d
+}
+
+// runResponse is the JSON body of an endpoint that waits for a run.
+type runResponse struct {
+	Response string `json:"response"`
+	*RunDetails
+}
+
+// maxUploadSize bounds the multipart body accepted by the upload endpoint.
+const maxUploadSize = 32 << 20
+
+// maxWebhookBody bounds the JSON body accepted by named task webhooks.
+const maxWebhookBody = 1 << 20
+
 // Server is a lightweight HTTP handler for webhook endpoints.
 type Server struct {
-	store     *state.TaskStore
-	handler   TaskHandler
-	sessions  types.SessionStore
-	events    types.EventStore
-	artifacts types.ArtifactStore
-	mux       *http.ServeMux
+	store      *state.TaskStore
+	handler    TaskHandler
+	sessions   types.SessionStore
+	events     types.EventStore
+	artifacts  types.ArtifactStore
+	runs       RunHandler
+	runStore   *state.RunStore
+	scheduler  *scheduler.Scheduler
+	broadcast  delivery.Broadcaster
+	macros     *state.MacroStore
+	push       *state.PushStore
+	pushKey    string
+	tokens     map[string]Role
+	tokenStore *state.TokenStore
+	scopes     map[string]Scope
+	previewer  PromptPreviewer
+	toolNames  []string
+	models     *llm.Registry
+	batches    *batchJobs
+	started    time.Time
+	mux        *http.ServeMux
+
+	// readOnly refuses requests that could change state; see SetReadOnly.
+	readOnly atomic.Bool
+
+	// publicURL and linkSecret build signed artifact viewer links.
+	publicURL  string
+	linkSecret []byte
 }
 
 // NewServer creates a new webhook Server with the given task store, handler callback, and stores.
@@ -38,20 +112,103 @@ func NewServer(store *state.TaskStore, handler TaskHandler, sessions types.Sessi
 		sessions:  sessions,
 		events:    events,
 		artifacts: artifacts,
+		batches:   newBatchJobs(),
+		started:   time.Now(),
 		mux:       http.NewServeMux(),
+		scopes:    make(map[string]Scope),
 	}
-	s.mux.HandleFunc("GET /health", s.handleHealth)
-	s.mux.HandleFunc("POST /webhook", s.handleAdHoc)
-	s.mux.HandleFunc("POST /webhook/", s.handleNamedTask)
-	s.mux.HandleFunc("GET /api/sessions", s.handleAPISessions)
-	s.mux.HandleFunc("GET /api/sessions/", s.handleAPISessionEvents)
-	s.mux.HandleFunc("GET /api/artifacts/", s.handleAPIArtifact)
+	s.route("GET /health", "", s.handleHealth)
+	s.route("POST /webhook", ScopeChat, s.handleAdHoc)
+	s.route("POST /webhook/", ScopeTasksWrite, s.handleNamedTask)
+	s.route("POST /api/chat", ScopeChat, s.handleAPIChat)
+	s.route("GET /api/sessions", ScopeSessionsRead, s.handleAPISessions)
+	s.route("GET /api/sessions/", ScopeSessionsRead, s.handleAPISessionEvents)
+	s.route("POST /api/sessions/{key}/files", ScopeChat, s.handleAPIUpload)
+	s.route("POST /api/sessions/{id}/messages", ScopeChat, s.handleAPIMessage)
+	s.route("POST /api/sessions/{id}/lock", ScopeAdmin, s.handleAPILock)
+	s.route("POST /api/sessions/{id}/unlock", ScopeAdmin, s.handleAPILock)
+	s.route("GET /api/sessions/{id}/prompt", ScopeSessionsRead, s.handleAPIPrompt)
+	s.route("GET /api/sessions/{id}/stream", ScopeSessionsRead, s.handleAPIStream)
+	s.route("GET /api/sessions/{id}/tools", ScopeSessionsRead, s.handleAPITools)
+	s.route("POST /api/sessions/{id}/tools", ScopeAdmin, s.handleAPISetTool)
+	s.route("GET /api/sessions/{id}/instructions", ScopeSessionsRead, s.handleAPIInstructions)
+	s.route("POST /api/sessions/{id}/instructions", ScopeAdmin, s.handleAPIAddInstruction)
+	s.route("DELETE /api/sessions/{id}/instructions", ScopeAdmin, s.handleAPIClearInstructions)
+	s.route("GET /api/runs", ScopeSessionsRead, s.handleAPIRuns)
+	s.route("GET /api/runs/{id}", ScopeSessionsRead, s.handleAPIRun)
+	s.route("GET /api/runs/{id}/artifacts.zip", ScopeSessionsRead, s.handleAPIRunArtifacts)
+	s.route("GET /api/artifacts/", ScopeSessionsRead, s.handleAPIArtifact)
+	s.route("GET /artifacts/{id}/view", ScopeSessionsRead, s.handleArtifactView)
+	s.route("GET /api/feedback", ScopeSessionsRead, s.handleAPIFeedback)
+	s.route("GET /api/usage", ScopeSessionsRead, s.handleAPIUsage)
+	s.route("GET /api/tasks", ScopeTasksRead, s.handleAPITasks)
+	s.route("GET /api/tasks/{name}", ScopeTasksRead, s.handleAPITask)
+	s.route("POST /api/batch", ScopeChat, s.handleAPIBatch)
+	s.route("GET /api/batch/{id}", ScopeChat, s.handleAPIBatchStatus)
+	s.route("GET /api/macros", ScopeChat, s.handleAPIMacros)
+	s.route("POST /api/macros/{name}/run", ScopeChat, s.handleAPIMacroRun)
+	s.route("GET /api/admin/status", ScopeTasksRead, s.handleAPIStatus)
+	s.route("POST /api/admin/broadcast", ScopeAdmin, s.handleAPIBroadcast)
+	s.route("GET /api/push/key", ScopeSessionsRead, s.handleAPIPushKey)
+	s.route("POST /api/push/subscriptions", ScopeChat, s.handleAPIPushSubscribe)
+	s.route("DELETE /api/push/subscriptions", ScopeChat, s.handleAPIPushUnsubscribe)
+	s.route("GET /sw.js", "", s.handleServiceWorker)
+	s.route("GET /manifest.webmanifest", "", s.handleManifest)
 	s.mux.HandleFunc("GET /", s.handleIndex)
 	return s
 }
 
+// SetRunHandler enables triggering a run from the upload endpoint. Without
+// it, uploads are stored but the prompt field is rejected.
+func (s *Server) SetRunHandler(h RunHandler) {
+	s.runs = h
+}
+
+// SetScheduler exposes the running scheduler's state in /api/admin/status.
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// SetToolNames sets the registered tools listed by the session tools API.
+func (s *Server) SetToolNames(names []string) {
+	s.toolNames = append([]string(nil), names...)
+	sort.Strings(s.toolNames)
+}
+
+// SetBroadcaster enables POST /api/admin/broadcast.
+func (s *Server) SetBroadcaster(b delivery.Broadcaster) {
+	s.broadcast = b
+}
+
+// EnableProfiling serves net/http/pprof under /debug/pprof/. Call it before
+// the server handles requests. Profiles expose command lines and memory
+// contents, so only enable it on a listener untrusted clients can't reach.
+func (s *Server) EnableProfiling() {
+	s.route("GET /debug/pprof/", ScopeAdmin, pprof.Index)
+	s.route("GET /debug/pprof/cmdline", ScopeAdmin, pprof.Cmdline)
+	s.route("GET /debug/pprof/profile", ScopeAdmin, pprof.Profile)
+	s.route("GET /debug/pprof/symbol", ScopeAdmin, pprof.Symbol)
+	s.route("POST /debug/pprof/symbol", ScopeAdmin, pprof.Symbol)
+	s.route("GET /debug/pprof/trace", ScopeAdmin, pprof.Trace)
+}
+
+// SetReadOnly makes the server refuse every request but GET and HEAD with
+// 503, for a follower instance that must not write to the data directory
+// the leader is using. Turn it off once the instance takes over.
+func (s *Server) SetReadOnly(on bool) {
+	s.readOnly.Store(on)
+}
+
 // ServeHTTP delegates to the internal mux, implementing http.Handler.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+	if s.readOnly.Load() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Retry-After", "15")
+		http.Error(w, `{"error":"this instance is a read-only follower; send changes to the leader"}`, http.StatusServiceUnavailable)
+		return
+	}
 	s.mux.ServeHTTP(w, r)
 }
 
@@ -60,6 +217,59 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// AdminStatus is the JSON shape of /api/admin/status.
+type AdminStatus struct {
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// Scheduler lists the tasks the running scheduler has loaded; nil when
+	// the daemon runs without a scheduler.
+	Scheduler []scheduler.Entry `json:"scheduler"`
+}
+
+func (s *Server) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	status := AdminStatus{
+		StartedAt:     s.started,
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+	}
+	if s.scheduler != nil {
+		status.Scheduler = s.scheduler.Snapshot()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// broadcastRequest is the JSON body for POST /api/admin/broadcast.
+type broadcastRequest struct {
+	Message string `json:"message"`
+}
+
+func (s *Server) handleAPIBroadcast(w http.ResponseWriter, r *http.Request) {
+	if s.broadcast == nil {
+		http.Error(w, `{"error":"broadcast not available"}`, http.StatusNotImplemented)
+		return
+	}
+	var req broadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		http.Error(w, `{"error":"message is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.broadcast(r.Context(), req.Message)
+	if err != nil {
+		slog.Error("broadcast failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // adHocRequest is the JSON body for POST /webhook.
 type adHocRequest struct {
 	Prompt     string `json:"prompt"`
@@ -79,6 +289,14 @@ func (s *Server) handleAdHoc(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp, err := s.handler(req.SessionKey, req.Prompt)
+	if errors.Is(err, gateway.ErrSessionLocked) {
+		writeLocked(w, err)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidSessionKey) {
+		writeInvalidKey(w, err)
+		return
+	}
 	if err != nil {
 		slog.Error("webhook ad-hoc handler failed", "error", err)
 		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
@@ -89,6 +307,54 @@ func (s *Server) handleAdHoc(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"response": resp})
 }
 
+// chatRequest is the JSON body for POST /api/chat.
+type chatRequest struct {
+	SessionKey string `json:"session_key"`
+	Text       string `json:"text"`
+}
+
+// handleAPIChat runs a conversational message, recorded like one from a
+// chat adapter rather than a task, for `gopherclaw chat`.
+func (s *Server) handleAPIChat(w http.ResponseWriter, r *http.Request) {
+	if s.runs == nil {
+		http.Error(w, `{"error":"runs not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	key, err := types.ParseSessionKey(req.SessionKey)
+	if req.Text == "" || err != nil || key.Scheme != "cli" {
+		http.Error(w, `{"error":"text and a cli: session_key are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.runs(&types.InboundEvent{
+		Source:     "cli",
+		SessionKey: key.Key(),
+		UserID:     key.Field("user"),
+		Text:       req.Text,
+	})
+	if errors.Is(err, gateway.ErrSessionLocked) {
+		writeLocked(w, err)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidSessionKey) {
+		writeInvalidKey(w, err)
+		return
+	}
+	if err != nil {
+		slog.Error("chat run failed", "session_key", key, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runResponse{Response: res.Text, RunDetails: newRunDetails(res)})
+}
+
 // namedTaskRequest is the optional JSON body for POST /webhook/{name}.
 type namedTaskRequest struct {
 	Prompt string `json:"prompt"`
@@ -115,13 +381,83 @@ func (s *Server) handleNamedTask(w http.ResponseWriter, r *http.Request) {
 	prompt := task.Prompt
 	sessionKey := task.SessionKey
 
-	// Allow body to override the prompt
-	var body namedTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&body); err == nil && body.Prompt != "" {
-		prompt = body.Prompt
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	var data any
+	hasJSON := len(raw) > 0 && json.Unmarshal(raw, &data) == nil
+
+	if task.PayloadTemplate != "" {
+		// Render the task's template with the structured body
+		tmpl, err := template.New(task.Name).Parse(task.PayloadTemplate)
+		if err != nil {
+			slog.Error("parse payload template failed", "task", name, "error", err)
+			http.Error(w, `{"error":"invalid payload template"}`, http.StatusInternalServerError)
+			return
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			http.Error(w, `{"error":"body does not match payload template"}`, http.StatusBadRequest)
+			return
+		}
+		prompt = buf.String()
+	} else if hasJSON {
+		// Allow body to override the prompt
+		var body namedTaskRequest
+		if err := json.Unmarshal(raw, &body); err == nil && body.Prompt != "" {
+			prompt = body.Prompt
+		}
+	}
+
+	var resp string
+	var details *RunDetails
+	if hasJSON && s.runs != nil && s.sessions != nil && s.artifacts != nil {
+		// Keep the raw body with the run so tools can read fields the
+		// prompt doesn't mention.
+		att, attErr := s.storeBody(r, types.SessionKey(sessionKey), raw)
+		if attErr != nil {
+			slog.Error("store webhook body failed", "task", name, "error", attErr)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		var res *gateway.RunResult
+		res, err = s.runs(&types.InboundEvent{
+			Source:      "task",
+			SessionKey:  types.SessionKey(sessionKey),
+			UserID:      "system",
+			Text:        prompt,
+			Attachments: []types.Attachment{att},
+			Metadata: &types.InboundMeta{
+				Version: types.InboundSchemaVersion,
+				Task:    name,
+				Headers: requestHeaders(r),
+			},
+		})
+		if err == nil {
+			resp, details = res.Text, newRunDetails(res)
+		}
+	} else {
+		resp, err = s.handler(sessionKey, prompt)
 	}
 
-	resp, err := s.handler(sessionKey, prompt)
+	run := state.TaskRun{At: time.Now(), Trigger: "webhook", Response: resp}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if recErr := s.store.RecordRun(name, run); recErr != nil {
+		slog.Warn("record task run failed", "task", name, "error", recErr)
+	}
+
+	if errors.Is(err, gateway.ErrSessionLocked) {
+		writeLocked(w, err)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidSessionKey) {
+		writeInvalidKey(w, err)
+		return
+	}
 	if err != nil {
 		slog.Error("webhook named task handler failed", "task", name, "error", err)
 		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
@@ -129,7 +465,106 @@ func (s *Server) handleNamedTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"response": resp})
+	json.NewEncoder(w).Encode(runResponse{Response: resp, RunDetails: details})
+}
+
+// storeBody saves a webhook's raw JSON body as an artifact in the task's
+// session and returns it as an attachment.
+func (s *Server) storeBody(r *http.Request, key types.SessionKey, raw []byte) (types.Attachment, error) {
+	ctx := r.Context()
+	sid, err := s.sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		return types.Attachment{}, fmt.Errorf("resolve session: %w", err)
+	}
+	id, err := s.artifacts.Put(ctx, sid, "", "webhook", json.RawMessage(raw))
+	if err != nil {
+		return types.Attachment{}, fmt.Errorf("store body: %w", err)
+	}
+	return types.Attachment{
+		ArtifactID:  id,
+		ContentType: "application/json",
+		Filename:    "webhook-body.json",
+		Size:        int64(len(raw)),
+	}, nil
+}
+
+// taskResponse is the JSON shape of a task in /api/tasks.
+type taskResponse struct {
+	Name            string            `json:"name"`
+	Prompt          string            `json:"prompt"`
+	Schedule        string            `json:"schedule,omitempty"`
+	Timezone        string            `json:"timezone,omitempty"`
+	SessionKey      string            `json:"session_key"`
+	DeliverTo       []string          `json:"deliver_to,omitempty"`
+	Enabled         bool              `json:"enabled"`
+	Concurrency     int               `json:"concurrency,omitempty"`
+	PayloadTemplate string            `json:"payload_template,omitempty"`
+	Expect          *state.TaskExpect `json:"expect,omitempty"`
+	Dedupe          bool              `json:"dedupe,omitempty"`
+	CatchUp         string            `json:"catch_up,omitempty"`
+	NextFire        string            `json:"next_fire,omitempty"`
+	LastRun         *state.TaskRun    `json:"last_run,omitempty"`
+}
+
+// newTaskResponse describes task, whose schedule fires in time zone tz.
+func newTaskResponse(task *state.Task, tz string) taskResponse {
+	resp := taskResponse{
+		Name:            task.Name,
+		Prompt:          task.Prompt,
+		Schedule:        task.Schedule,
+		Timezone:        tz,
+		SessionKey:      task.SessionKey,
+		DeliverTo:       task.DeliverTo,
+		Enabled:         task.Enabled,
+		Concurrency:     task.Concurrency,
+		PayloadTemplate: task.PayloadTemplate,
+		Expect:          task.Expect,
+		Dedupe:          task.Dedupe,
+		CatchUp:         task.CatchUp,
+		LastRun:         task.LastRun,
+	}
+	if task.Enabled && task.Schedule != "" {
+		if next, err := scheduler.NextFire(scheduler.Spec(task.Schedule, tz), time.Now()); err == nil {
+			resp.NextFire = next.Format(time.RFC3339)
+		}
+	}
+	return resp
+}
+
+// timezone returns the time zone task's schedule fires in.
+func (s *Server) timezone(task *state.Task) string {
+	if s.scheduler == nil {
+		return task.Timezone
+	}
+	return s.scheduler.Timezone(task)
+}
+
+func (s *Server) handleAPITasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.store.List()
+	if err != nil {
+		slog.Error("list tasks failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]taskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, newTaskResponse(task, s.timezone(task)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleAPITask(w http.ResponseWriter, r *http.Request) {
+	task, err := s.store.Get(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, `{"error":"task not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTaskResponse(task, s.timezone(task)))
 }
 
 type sessionResponse struct {
@@ -140,13 +575,27 @@ type sessionResponse struct {
 	CreatedAt  string `json:"created_at"`
 	UpdatedAt  string `json:"updated_at"`
 	EventCount int64  `json:"event_count"`
+	Locked     bool   `json:"locked,omitempty"`
+	// ArchivedFrom and ArchivedAt are set on rotated sessions.
+	ArchivedFrom string `json:"archived_from,omitempty"`
+	ArchivedAt   string `json:"archived_at,omitempty"`
 }
 
+// handleAPISessions lists sessions, most recently updated first. The
+// status, channel and key query parameters filter the list; status=archived
+// is the view of rotated conversations, which key and channel match by the
+// key they were archived from.
 func (s *Server) handleAPISessions(w http.ResponseWriter, r *http.Request) {
 	if s.sessions == nil || s.events == nil {
 		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
 		return
 	}
+	q := r.URL.Query()
+	filter := types.SessionFilter{Status: q.Get("status"), Channel: q.Get("channel"), Key: types.SessionKey(q.Get("key"))}
+	if filter.Status != "" && filter.Status != types.SessionActive && filter.Status != types.SessionArchived {
+		http.Error(w, `{"error":"status must be active or archived"}`, http.StatusBadRequest)
+		return
+	}
 	ctx := r.Context()
 	sessions, err := s.sessions.List(ctx)
 	if err != nil {
@@ -154,6 +603,7 @@ func (s *Server) handleAPISessions(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
 		return
 	}
+	sessions = types.FilterSessions(sessions, filter)
 
 	result := make([]sessionResponse, 0, len(sessions))
 	for _, sess := range sessions {
@@ -161,15 +611,21 @@ func (s *Server) handleAPISessions(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			slog.Warn("count events failed", "session_id", sess.SessionID, "error", err)
 		}
-		result = append(result, sessionResponse{
-			SessionID:  string(sess.SessionID),
-			SessionKey: string(sess.SessionKey),
-			Agent:      sess.Agent,
-			Status:     sess.Status,
-			CreatedAt:  sess.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:  sess.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			EventCount: count,
-		})
+		resp := sessionResponse{
+			SessionID:    string(sess.SessionID),
+			SessionKey:   string(sess.SessionKey),
+			Agent:        sess.Agent,
+			Status:       sess.Status,
+			CreatedAt:    sess.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt:    sess.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			EventCount:   count,
+			Locked:       sess.Locked,
+			ArchivedFrom: string(sess.ArchivedFrom),
+		}
+		if sess.ArchivedAt != nil {
+			resp.ArchivedAt = sess.ArchivedAt.Format("2006-01-02T15:04:05Z07:00")
+		}
+		result = append(result, resp)
 	}
 
 	sort.Slice(result, func(i, j int) bool {
@@ -216,26 +672,448 @@ func (s *Server) handleAPISessionEvents(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(events)
 }
 
+// artifactResponse is the JSON body returned by GET /api/artifacts/{id}.
+type artifactResponse struct {
+	Meta    *types.ArtifactMeta `json:"meta"`
+	Data    json.RawMessage     `json:"data,omitempty"`
+	Excerpt *string             `json:"excerpt,omitempty"`
+}
+
+// handleAPIArtifact returns an artifact's metadata and data. ?raw=1 sends
+// the data alone, unwrapped, with its own Content-Type; ?excerpt=<tokens>
+// with an optional &q= returns ArtifactStore.Excerpt instead of the data.
 func (s *Server) handleAPIArtifact(w http.ResponseWriter, r *http.Request) {
 	if s.artifacts == nil {
 		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
 		return
 	}
 
-	id := strings.TrimPrefix(r.URL.Path, "/api/artifacts/")
-	if id == "" {
+	id := types.ArtifactID(strings.TrimPrefix(r.URL.Path, "/api/artifacts/"))
+	if id == "" || strings.Contains(string(id), "/") {
 		http.Error(w, `{"error":"artifact id required"}`, http.StatusBadRequest)
 		return
 	}
+	ctx := r.Context()
+	query := r.URL.Query()
 
-	data, err := s.artifacts.Get(r.Context(), types.ArtifactID(id))
+	meta, err := s.artifacts.GetMeta(ctx, id)
 	if err != nil {
 		http.Error(w, `{"error":"artifact not found"}`, http.StatusNotFound)
 		return
 	}
 
+	if query.Has("excerpt") {
+		maxTokens := 0
+		if v := query.Get("excerpt"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, `{"error":"excerpt must be a token count"}`, http.StatusBadRequest)
+				return
+			}
+			maxTokens = n
+		}
+		excerpt, err := s.artifacts.Excerpt(ctx, id, query.Get("q"), maxTokens)
+		if err != nil {
+			slog.Error("artifact excerpt failed", "artifact_id", id, "error", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(artifactResponse{Meta: meta, Excerpt: &excerpt})
+		return
+	}
+
+	data, err := s.artifacts.Get(ctx, id)
+	if err != nil {
+		http.Error(w, `{"error":"artifact not found"}`, http.StatusNotFound)
+		return
+	}
+	if query.Get("raw") == "1" {
+		body, contentType := rawArtifact(meta, data)
+		w.Header().Set("Content-Type", contentType)
+		// Served from the API's origin, so uploaded HTML must not run.
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(artifactResponse{Meta: meta, Data: data})
+}
+
+// rawArtifact unwraps an artifact's stored data: text is sent as text,
+// uploaded files as their decoded bytes, and structured data as JSON.
+func rawArtifact(meta *types.ArtifactMeta, data json.RawMessage) ([]byte, string) {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return data, "application/json"
+	}
+	if raw, ok := fileBytes(meta, text); ok {
+		contentType := meta.MimeType
+		if contentType == "" {
+			contentType = http.DetectContentType(raw)
+		}
+		return raw, contentType
+	}
+	if meta.MimeType != "" {
+		return []byte(text), meta.MimeType
+	}
+	return []byte(text), "text/plain; charset=utf-8"
+}
+
+// uploadResponse is the JSON body returned by POST /api/sessions/{key}/files.
+type uploadResponse struct {
+	SessionID   string             `json:"session_id"`
+	Attachments []types.Attachment `json:"attachments"`
+	Response    *string            `json:"response,omitempty"`
+	*RunDetails
+}
+
+// handleAPIUpload stores multipart "file" parts as artifacts in the session
+// for {key}. If a "prompt" field is present, a run is started with the files
+// attached and the response is returned alongside the attachment refs.
+func (s *Server) handleAPIUpload(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.artifacts == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	key := types.SessionKey(r.PathValue("key"))
+	if _, err := key.Parse(); err != nil {
+		writeInvalidKey(w, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		http.Error(w, `{"error":"invalid multipart body"}`, http.StatusBadRequest)
+		return
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		http.Error(w, `{"error":"at least one file part is required"}`, http.StatusBadRequest)
+		return
+	}
+	prompt := r.FormValue("prompt")
+	if prompt != "" && s.runs == nil {
+		http.Error(w, `{"error":"runs not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	sid, err := s.sessions.ResolveOrCreate(ctx, key, "default")
+	if err != nil {
+		slog.Error("resolve session failed", "session_key", key, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	attachments := make([]types.Attachment, 0, len(files))
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			http.Error(w, `{"error":"invalid file part"}`, http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			http.Error(w, `{"error":"invalid file part"}`, http.StatusBadRequest)
+			return
+		}
+
+		contentType := fh.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = http.DetectContentType(data)
+		}
+
+		id, err := s.artifacts.Put(ctx, sid, "", "upload", data)
+		if err != nil {
+			slog.Error("store upload failed", "session_id", sid, "error", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		attachments = append(attachments, types.Attachment{
+			ArtifactID:  id,
+			ContentType: contentType,
+			Filename:    fh.Filename,
+			Size:        int64(len(data)),
+		})
+	}
+
+	result := uploadResponse{SessionID: string(sid), Attachments: attachments}
+	if prompt != "" {
+		res, err := s.runs(&types.InboundEvent{
+			Source:      "http",
+			SessionKey:  key,
+			UserID:      "http",
+			Text:        prompt,
+			Attachments: attachments,
+			Metadata: &types.InboundMeta{
+				Version: types.InboundSchemaVersion,
+				Headers: requestHeaders(r),
+			},
+		})
+		if errors.Is(err, gateway.ErrSessionLocked) {
+			writeLocked(w, err)
+			return
+		}
+		if err != nil {
+			slog.Error("upload run failed", "session_key", key, "error", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		result.Response = &res.Text
+		result.RunDetails = newRunDetails(res)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleAPIFeedback(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.events == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	stats, err := feedback.Collect(r.Context(), s.sessions, s.events)
+	if err != nil {
+		slog.Error("collect feedback failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// requestHeaders returns a request's headers for run metadata, first value
+// only, leaving out anything that may carry credentials.
+func requestHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		if len(values) == 0 || sensitiveHeader(name) {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	return headers
+}
+
+func sensitiveHeader(name string) bool {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie":
+		return true
+	}
+	for _, s := range []string{"token", "secret", "signature", "api-key", "apikey", "password"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeInvalidKey responds 400 for a malformed session key.
+func writeInvalidKey(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// writeLocked responds 423 with the gateway's polite notice.
+func writeLocked(w http.ResponseWriter, err error) {
+	notice := "session is locked"
+	var locked *gateway.LockedError
+	if errors.As(err, &locked) {
+		notice = locked.Notice()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusLocked)
+	json.NewEncoder(w).Encode(map[string]string{"error": notice})
+}
+
+// lockRequest is the optional JSON body for POST /api/sessions/{id}/lock.
+type lockRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleAPILock serves both /lock and /unlock for a session ID.
+func (s *Server) handleAPILock(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	id := types.SessionID(r.PathValue("id"))
+	locked := strings.HasSuffix(r.URL.Path, "/lock")
+	var req lockRequest
+	if locked {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	if err := gateway.SetLocked(r.Context(), s.sessions, id, locked, req.Reason); err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"session_id": id, "locked": locked})
+}
+
+// toolStatus is the JSON shape of a tool in /api/sessions/{id}/tools.
+type toolStatus struct {
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Dangerous bool   `json:"dangerous,omitempty"`
+}
+
+func (s *Server) handleAPITools(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	sess, err := s.sessions.Get(r.Context(), types.SessionID(r.PathValue("id")))
+	if err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+
+	result := make([]toolStatus, 0, len(s.toolNames))
+	for _, name := range s.toolNames {
+		result = append(result, toolStatus{
+			Name:      name,
+			Enabled:   gateway.ToolEnabled(sess, name),
+			Dangerous: gateway.IsDangerousTool(name),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// setToolRequest is the JSON body for POST /api/sessions/{id}/tools.
+type setToolRequest struct {
+	Tool    string `json:"tool"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (s *Server) handleAPISetTool(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	var req setToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	known := false
+	for _, name := range s.toolNames {
+		if name == req.Tool {
+			known = true
+		}
+	}
+	if !known {
+		http.Error(w, `{"error":"unknown tool"}`, http.StatusBadRequest)
+		return
+	}
+
+	id := types.SessionID(r.PathValue("id"))
+	if err := gateway.SetToolEnabled(r.Context(), s.sessions, id, req.Tool, req.Enabled); err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toolStatus{Name: req.Tool, Enabled: req.Enabled, Dangerous: gateway.IsDangerousTool(req.Tool)})
+}
+
+// instructionsResponse is the JSON shape of /api/sessions/{id}/instructions.
+type instructionsResponse struct {
+	SessionID    types.SessionID `json:"session_id"`
+	Instructions []string        `json:"instructions"`
+}
+
+// addInstructionRequest is the JSON body for POST
+// /api/sessions/{id}/instructions.
+type addInstructionRequest struct {
+	Text string `json:"text"`
+}
+
+func (s *Server) handleAPIInstructions(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	s.writeInstructions(w, r, types.SessionID(r.PathValue("id")))
+}
+
+func (s *Server) handleAPIAddInstruction(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.events == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	var req addInstructionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		http.Error(w, `{"error":"text is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	id := types.SessionID(r.PathValue("id"))
+	if _, err := s.sessions.Get(r.Context(), id); err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+	if err := gateway.AddInstruction(r.Context(), s.sessions, s.events, id, "http", text); err != nil {
+		slog.Error("add instruction failed", "session_id", id, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	s.writeInstructions(w, r, id)
+}
+
+func (s *Server) handleAPIClearInstructions(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.events == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	id := types.SessionID(r.PathValue("id"))
+	if _, err := s.sessions.Get(r.Context(), id); err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+	if err := gateway.ClearInstructions(r.Context(), s.sessions, s.events, id, "http"); err != nil {
+		slog.Error("clear instructions failed", "session_id", id, "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	s.writeInstructions(w, r, id)
+}
+
+// writeInstructions responds with a session's current standing instructions.
+func (s *Server) writeInstructions(w http.ResponseWriter, r *http.Request, id types.SessionID) {
+	sess, err := s.sessions.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+	instructions := sess.Instructions
+	if instructions == nil {
+		instructions = []string{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(data)
+	json.NewEncoder(w).Encode(instructionsResponse{SessionID: id, Instructions: instructions})
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -1,8 +1,11 @@
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -10,8 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/scheduler"
 	"github.com/user/gopherclaw/internal/state"
 	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/pkg/llm"
 )
 
 type mockGateway struct {
@@ -88,6 +96,32 @@ func TestWebhookAdHoc(t *testing.T) {
 	}
 }
 
+func TestReadOnlyRefusesWrites(t *testing.T) {
+	mock := &mockGateway{response: "hello from LLM"}
+	srv := setupServer(t, mock)
+	srv.SetReadOnly(true)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"prompt":"say hi","session_key":"http:test"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || mock.lastPrompt != "" {
+		t.Fatalf("expected a follower to refuse the run, got %d and prompt %q", w.Code, mock.lastPrompt)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected reads to be served, got %d", w.Code)
+	}
+
+	srv.SetReadOnly(false)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"prompt":"say hi","session_key":"http:test"}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected writes once leading, got %d", w.Code)
+	}
+}
+
 func TestWebhookAdHocMissingFields(t *testing.T) {
 	mock := &mockGateway{response: "unused"}
 	srv := setupServer(t, mock)
@@ -104,6 +138,22 @@ func TestWebhookAdHocMissingFields(t *testing.T) {
 	}
 }
 
+func TestWebhookAdHocInvalidSessionKey(t *testing.T) {
+	dir := t.TempDir()
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), func(sessionKey, prompt string) (string, error) {
+		_, err := types.ParseSessionKey(sessionKey)
+		return "", err
+	}, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"prompt":"hi","session_key":"telegram:alice"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid session key") {
+		t.Errorf("expected 400 naming the bad key, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestWebhookNamedTask(t *testing.T) {
 	mock := &mockGateway{response: "greetings!"}
 	task := &state.Task{
@@ -241,6 +291,46 @@ func TestAPISessionsList(t *testing.T) {
 	}
 }
 
+func TestAPISessionsFilter(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	ctx := context.Background()
+	oldID, _ := sessions.ResolveOrCreate(ctx, "telegram:1:1", "default")
+	sessions.Rotate(ctx, "telegram:1:1", "")
+	sessions.ResolveOrCreate(ctx, "http:other", "default")
+
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), mock.HandleTask, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+	list := func(query string) []map[string]any {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sessions"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, w.Code)
+		}
+		var result []map[string]any
+		json.NewDecoder(w.Body).Decode(&result)
+		return result
+	}
+
+	if got := list(""); len(got) != 3 {
+		t.Errorf("expected 3 sessions unfiltered, got %d", len(got))
+	}
+	archived := list("?status=archived&key=telegram:1:1")
+	if len(archived) != 1 || archived[0]["session_id"] != string(oldID) || archived[0]["archived_from"] != "telegram:1:1" || archived[0]["archived_at"] == nil {
+		t.Errorf("expected the rotated session in the archived view, got %v", archived)
+	}
+	if got := list("?status=active&channel=telegram"); len(got) != 1 || got[0]["session_key"] != "telegram:1:1" {
+		t.Errorf("expected the fresh telegram session, got %v", got)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sessions?status=gone", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown status, got %d", w.Code)
+	}
+}
+
 func TestAPISessionEvents(t *testing.T) {
 	mock := &mockGateway{response: "unused"}
 	dir := t.TempDir()
@@ -383,12 +473,80 @@ func TestAPIArtifact(t *testing.T) {
 		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
 
-	var result string
+	var result struct {
+		Meta types.ArtifactMeta `json:"meta"`
+		Data string             `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Data != "hello world output" {
+		t.Errorf("expected 'hello world output', got %q", result.Data)
+	}
+	if result.Meta.ID != aid || result.Meta.Tool != "bash" || result.Meta.SessionID != sid {
+		t.Errorf("unexpected meta %+v", result.Meta)
+	}
+}
+
+func TestAPIArtifactModes(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), mock.HandleTask, sessions, state.NewEventStore(dir), artifacts)
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, "test:key", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := artifacts.Put(ctx, sid, types.NewRunID(), "bash", "line one\nthe needle is here\nline three")
+	structured, _ := artifacts.Put(ctx, sid, types.NewRunID(), "brave_search", map[string]int{"hits": 2})
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	file, _ := artifacts.Put(ctx, sid, types.NewRunID(), "upload", png)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/api/artifacts/" + string(text) + "?raw=1", "text/plain; charset=utf-8", "line one\nthe needle is here\nline three"},
+		{"/api/artifacts/" + string(structured) + "?raw=1", "application/json", `{"hits":2}`},
+		{"/api/artifacts/" + string(file) + "?raw=1", "image/png", string(png)},
+	}
+	for _, tt := range tests {
+		w := get(tt.path)
+		body := w.Body.String()
+		if tt.contentType == "application/json" {
+			var buf bytes.Buffer
+			json.Compact(&buf, w.Body.Bytes())
+			body = buf.String()
+		}
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.contentType || body != tt.body {
+			t.Errorf("%s: %d %s %q", tt.path, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+
+	w := get("/api/artifacts/" + string(text) + "?excerpt=4&q=needle")
+	var result struct {
+		Meta    types.ArtifactMeta `json:"meta"`
+		Excerpt string             `json:"excerpt"`
+		Data    json.RawMessage    `json:"data"`
+	}
 	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
 		t.Fatal(err)
 	}
-	if result != "hello world output" {
-		t.Errorf("expected 'hello world output', got %q", result)
+	if !strings.Contains(result.Excerpt, "needle") || len(result.Excerpt) > 16 || result.Data != nil || result.Meta.ID != text {
+		t.Errorf("unexpected excerpt response %+v", result)
+	}
+	if w := get("/api/artifacts/" + string(text) + "?excerpt=lots"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad excerpt size, got %d", w.Code)
 	}
 }
 
@@ -429,3 +587,775 @@ func TestAPISessionEventsNotFound(t *testing.T) {
 		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
 }
+
+func newUploadRequest(t *testing.T, key, prompt string, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if prompt != "" {
+		mw.WriteField("prompt", prompt)
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/"+key+"/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestAPIUploadWithPrompt(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	taskStore := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+
+	srv := NewServer(taskStore, mock.HandleTask, sessions, events, artifacts)
+	var got *types.InboundEvent
+	srv.SetRunHandler(func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		got = event
+		return &gateway.RunResult{Text: "3 rows"}, nil
+	})
+
+	req := newUploadRequest(t, "http:csv", "analyze this CSV", map[string]string{"data.csv": "a,b\n1,2\n3,4\n"})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result uploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response == nil || *result.Response != "3 rows" {
+		t.Errorf("expected response '3 rows', got %v", result.Response)
+	}
+	if len(result.Attachments) != 1 || result.Attachments[0].Filename != "data.csv" {
+		t.Fatalf("unexpected attachments: %+v", result.Attachments)
+	}
+	if got == nil || got.Text != "analyze this CSV" || string(got.SessionKey) != "http:csv" || len(got.Attachments) != 1 {
+		t.Fatalf("unexpected run event: %+v", got)
+	}
+
+	data, err := artifacts.Get(context.Background(), result.Attachments[0].ArtifactID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []byte
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != "a,b\n1,2\n3,4\n" {
+		t.Errorf("unexpected stored content %q", stored)
+	}
+}
+
+func TestAPIUploadWithoutPrompt(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	taskStore := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	sessions := state.NewSessionStore(dir)
+	srv := NewServer(taskStore, mock.HandleTask, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+
+	req := newUploadRequest(t, "http:files", "", map[string]string{"notes.txt": "hello"})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result uploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response != nil {
+		t.Errorf("expected no response without a prompt, got %q", *result.Response)
+	}
+	if len(result.Attachments) != 1 || !strings.HasPrefix(result.Attachments[0].ContentType, "text/plain") {
+		t.Errorf("unexpected attachments: %+v", result.Attachments)
+	}
+}
+
+func TestAPIUploadRequiresFile(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	taskStore := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	srv := NewServer(taskStore, mock.HandleTask, state.NewSessionStore(dir), state.NewEventStore(dir), state.NewArtifactStore(dir))
+
+	req := newUploadRequest(t, "http:files", "hi", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestAPIChat(t *testing.T) {
+	srv := setupServer(t, &mockGateway{response: "unused"})
+	var got *types.InboundEvent
+	srv.SetRunHandler(func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		got = event
+		return &gateway.RunResult{
+			RunID:     "r1",
+			Text:      "hello there",
+			Artifacts: []types.ArtifactID{"a1"},
+			ToolCalls: []gateway.ToolCall{{Tool: "bash", CallID: "c1", ArtifactID: "a1"}},
+			Usage:     llm.Usage{InputTokens: 10, OutputTokens: 3, TotalTokens: 13},
+		}, nil
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"session_key":"cli:alice","text":"hi"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result runResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response != "hello there" || result.RunDetails == nil {
+		t.Fatalf("unexpected response %+v", result)
+	}
+	if result.RunID != "r1" || len(result.Artifacts) != 1 || len(result.ToolCalls) != 1 ||
+		result.ToolCalls[0].ArtifactID != "a1" || result.Usage.TotalTokens != 13 {
+		t.Errorf("unexpected run details %+v", *result.RunDetails)
+	}
+	if got == nil || got.Source != "cli" || got.SessionKey != "cli:alice" || got.UserID != "alice" || got.Text != "hi" {
+		t.Errorf("unexpected run event %+v", got)
+	}
+
+	for _, body := range []string{
+		`{"session_key":"telegram:1:1","text":"hi"}`,
+		`{"session_key":"cli:alice"}`,
+		`not json`,
+	} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestAPIMessage(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), (&mockGateway{}).HandleTask, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+	ctx := context.Background()
+	old, _ := sessions.ResolveOrCreate(ctx, "telegram:1:2", "default")
+	sessions.Rotate(ctx, "telegram:1:2", "")
+	current, _ := sessions.ResolveOrCreate(ctx, "telegram:1:2", "default")
+
+	var got *types.InboundEvent
+	srv.SetRunHandler(func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		got = event
+		sid, _ := sessions.ResolveOrCreate(ctx, event.SessionKey, "default")
+		return &gateway.RunResult{RunID: "r1", SessionID: sid, Text: "noted"}, nil
+	})
+	post := func(ref, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/sessions/"+ref+"/messages", strings.NewReader(body)))
+		return w
+	}
+
+	for _, ref := range []string{string(current), "telegram:1:2"} {
+		w := post(ref, `{"text":"deploy finished","user_id":"ci"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", ref, w.Code, w.Body.String())
+		}
+		var result messageResponse
+		json.NewDecoder(w.Body).Decode(&result)
+		if result.Response != "noted" || result.SessionID != current || result.SessionKey != "telegram:1:2" || result.RunDetails == nil || result.RunID != "r1" {
+			t.Errorf("%s: unexpected response %+v", ref, result)
+		}
+		if got.Source != "http" || got.SessionKey != "telegram:1:2" || got.UserID != "ci" || got.Text != "deploy finished" {
+			t.Errorf("%s: unexpected run event %+v", ref, got)
+		}
+	}
+
+	cases := map[string]int{
+		string(old) + "|hi": http.StatusConflict,
+		"missing|hi":        http.StatusNotFound,
+		"telegram:x:1|hi":   http.StatusBadRequest,
+		"telegram:1:2|":     http.StatusBadRequest,
+	}
+	for c, want := range cases {
+		ref, text, _ := strings.Cut(c, "|")
+		body, _ := json.Marshal(map[string]string{"text": text})
+		if w := post(ref, string(body)); w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", c, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAPISessionLock(t *testing.T) {
+	dir := t.TempDir()
+	taskStore := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	sessions := state.NewSessionStore(dir)
+	handler := func(sessionKey, prompt string) (string, error) {
+		return "", &gateway.LockedError{SessionID: "s1", Reason: "incident"}
+	}
+	srv := NewServer(taskStore, handler, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, "test:key", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/"+string(sid)+"/lock", strings.NewReader(`{"reason":"incident"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	sess, err := sessions.Get(ctx, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.Locked || sess.LockReason != "incident" {
+		t.Errorf("expected locked session with reason, got %+v", sess)
+	}
+
+	// Runs against a locked session are refused with 423
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"prompt":"hi","session_key":"test:key"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusLocked {
+		t.Errorf("expected 423, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "incident") {
+		t.Errorf("expected notice with reason, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/"+string(sid)+"/unlock", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if sess, _ := sessions.Get(ctx, sid); sess.Locked {
+		t.Error("expected session unlocked")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/nope/lock", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown session, got %d", w.Code)
+	}
+}
+
+func TestWebhookNamedTaskPayloadTemplate(t *testing.T) {
+	mock := &mockGateway{response: "unused"}
+	dir := t.TempDir()
+	taskStore := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	if err := taskStore.Add(&state.Task{
+		Name:            "orders",
+		Prompt:          "unused",
+		SessionKey:      "http:orders",
+		Enabled:         true,
+		PayloadTemplate: "New order {{.order.id}} from {{.customer.name}}",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	artifacts := state.NewArtifactStore(dir)
+	srv := NewServer(taskStore, mock.HandleTask, state.NewSessionStore(dir), state.NewEventStore(dir), artifacts)
+	var got *types.InboundEvent
+	srv.SetRunHandler(func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		got = event
+		return &gateway.RunResult{Text: "noted"}, nil
+	})
+
+	body := `{"order":{"id":"A-17","total":42},"customer":{"name":"Ada"}}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook/orders", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Delivery", "d-1")
+	req.Header.Set("X-Hub-Signature-256", "sha256=abc")
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got == nil {
+		t.Fatal("expected run handler to be called")
+	}
+	if got.Text != "New order A-17 from Ada" {
+		t.Errorf("unexpected rendered prompt %q", got.Text)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].ContentType != "application/json" {
+		t.Fatalf("expected raw body attached, got %+v", got.Attachments)
+	}
+	data, err := artifacts.Get(context.Background(), got.Attachments[0].ArtifactID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored["customer"].(map[string]any)["name"] != "Ada" {
+		t.Errorf("unexpected stored body %s", data)
+	}
+	if mock.lastPrompt != "" {
+		t.Error("expected plain task handler not to be used")
+	}
+	meta := got.Metadata
+	if meta == nil || meta.Version != types.InboundSchemaVersion || meta.Task != "orders" {
+		t.Fatalf("expected task metadata, got %+v", meta)
+	}
+	if meta.Headers["X-Github-Delivery"] != "d-1" {
+		t.Errorf("expected delivery header kept, got %v", meta.Headers)
+	}
+	if _, ok := meta.Headers["Authorization"]; ok {
+		t.Error("expected Authorization header dropped")
+	}
+	if _, ok := meta.Headers["X-Hub-Signature-256"]; ok {
+		t.Error("expected signature header dropped")
+	}
+}
+
+func TestAPITasks(t *testing.T) {
+	mock := &mockGateway{response: "done"}
+	srv := setupServer(t, mock,
+		&state.Task{Name: "daily", Prompt: "p", Schedule: "0 8 * * *", SessionKey: "telegram:1:1", Enabled: true},
+		&state.Task{Name: "hook", Prompt: "p", SessionKey: "http:hook", Enabled: true},
+	)
+
+	// Trigger the webhook task so it has a last run
+	req := httptest.NewRequest(http.MethodPost, "/webhook/hook", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var tasks []taskResponse
+	if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+	if tasks[0].NextFire == "" {
+		t.Error("expected next fire for scheduled task")
+	}
+	if tasks[1].NextFire != "" {
+		t.Errorf("expected no next fire for webhook-only task, got %q", tasks[1].NextFire)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/hook", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var task taskResponse
+	if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task.LastRun == nil || task.LastRun.Trigger != "webhook" || task.LastRun.Response != "done" {
+		t.Errorf("unexpected last run: %+v", task.LastRun)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/missing", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestAPIAdminStatus(t *testing.T) {
+	mock := &mockGateway{response: "done"}
+	srv := setupServer(t, mock,
+		&state.Task{Name: "daily", Prompt: "p", Schedule: "0 8 * * *", SessionKey: "telegram:1:1", Enabled: true},
+		&state.Task{Name: "hook", Prompt: "p", SessionKey: "http:hook", Enabled: true},
+	)
+
+	// Without a scheduler the list is empty
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/status", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var status AdminStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.StartedAt.IsZero() || status.Scheduler != nil {
+		t.Errorf("unexpected status without scheduler: %+v", status)
+	}
+
+	sched := scheduler.New(srv.store, mock.HandleTask)
+	if err := sched.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Stop()
+	srv.SetScheduler(sched)
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/status", nil))
+	status = AdminStatus{}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Scheduler) != 1 {
+		t.Fatalf("expected only the scheduled task, got %+v", status.Scheduler)
+	}
+	if entry := status.Scheduler[0]; entry.Name != "daily" || entry.Next == nil {
+		t.Errorf("expected daily with a next fire time, got %+v", entry)
+	}
+}
+
+func TestAPIBroadcast(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+
+	// Not configured
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/broadcast", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a broadcaster, got %d", w.Code)
+	}
+
+	var got string
+	srv.SetBroadcaster(func(ctx context.Context, message string) (*delivery.BroadcastResult, error) {
+		got = message
+		return &delivery.BroadcastResult{Sent: 2, Skipped: 1}, nil
+	})
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/broadcast", strings.NewReader(`{"message":""}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty message, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/broadcast", strings.NewReader(`{"message":"restarting in 5 minutes"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var result delivery.BroadcastResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if got != "restarting in 5 minutes" || result.Sent != 2 || result.Skipped != 1 {
+		t.Errorf("unexpected broadcast: message=%q result=%+v", got, result)
+	}
+}
+
+func TestAPIMacroRun(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+	macros := state.NewMacroStore(filepath.Join(t.TempDir(), "macros.json"))
+	if err := macros.Put(&state.Macro{Name: "report", Template: "Status report for week {{.week}}, team {{.team}}.", Defaults: map[string]string{"team": "ops"}}); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetMacroStore(macros)
+	var got *types.InboundEvent
+	srv.SetRunHandler(func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		got = event
+		return &gateway.RunResult{Text: "report written"}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/macros", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var list []state.Macro
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "report" {
+		t.Fatalf("unexpected macro list %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/macros/report/run", strings.NewReader(`{"session_key":"http:me","args":{"week":"12"}}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp macroRunResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Prompt != "Status report for week 12, team ops." || resp.Response != "report written" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if got == nil || got.Text != resp.Prompt || got.SessionKey != "http:me" {
+		t.Errorf("unexpected run event %+v", got)
+	}
+
+	// A missing argument is the caller's mistake.
+	req = httptest.NewRequest(http.MethodPost, "/api/macros/report/run", strings.NewReader(`{"session_key":"http:me"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "week") {
+		t.Errorf("expected 400 naming the missing argument, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/macros/nope/run", strings.NewReader(`{"session_key":"http:me"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown macro, got %d", w.Code)
+	}
+}
+
+// fakePreviewer records which preview mode was asked for.
+type fakePreviewer struct{ seq int64 }
+
+func (f *fakePreviewer) PromptAt(_ context.Context, id types.SessionID, seq int64) (*runtime.PromptPreview, error) {
+	f.seq = seq
+	return &runtime.PromptPreview{SessionID: id, Seq: seq, Messages: []llm.Message{{Role: "system", Content: "then"}}}, nil
+}
+
+func (f *fakePreviewer) PromptForRun(_ context.Context, id types.SessionID, run types.RunID) (*runtime.PromptPreview, error) {
+	return nil, errors.New("run " + string(run) + " not found in session")
+}
+
+func TestAPIPromptPreview(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+	previewer := &fakePreviewer{}
+	srv.SetPromptPreviewer(previewer)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions/s1/prompt?seq=7", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var preview runtime.PromptPreview
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatal(err)
+	}
+	if previewer.seq != 7 || preview.SessionID != "s1" || preview.Messages[0].Content != "then" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+
+	for path, want := range map[string]int{
+		"/api/sessions/s1/prompt":         http.StatusBadRequest,
+		"/api/sessions/s1/prompt?seq=abc": http.StatusBadRequest,
+		"/api/sessions/s1/prompt?run=r9":  http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestAPISessionTools(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	sessions := state.NewSessionStore(dir)
+	srv := NewServer(store, (&mockGateway{}).HandleTask, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+	srv.SetToolNames([]string{"read_url", "bash"})
+
+	sid, err := sessions.ResolveOrCreate(context.Background(), "telegram:1:1", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/"+string(sid)+"/tools", strings.NewReader(`{"tool":"bash","enabled":false}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/"+string(sid)+"/tools", strings.NewReader(`{"tool":"nope","enabled":false}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown tool, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/sessions/"+string(sid)+"/tools", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got []toolStatus
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []toolStatus{{Name: "bash", Enabled: false, Dangerous: true}, {Name: "read_url", Enabled: true}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAPISessionInstructions(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	sessions := state.NewSessionStore(dir)
+	srv := NewServer(store, (&mockGateway{}).HandleTask, sessions, state.NewEventStore(dir), state.NewArtifactStore(dir))
+
+	sid, err := sessions.ResolveOrCreate(context.Background(), "telegram:1:1", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/sessions/" + string(sid) + "/instructions"
+
+	for _, text := range []string{"Answer in French.", "Keep replies short."} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"text":"`+text+`"}`))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"text":"  "}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty text, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var got instructionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Instructions) != 2 || got.Instructions[1] != "Keep replies short." {
+		t.Errorf("unexpected instructions %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, path, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if sess, _ := sessions.Get(context.Background(), sid); len(sess.Instructions) != 0 {
+		t.Errorf("expected instructions cleared, got %v", sess.Instructions)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/nope/instructions", strings.NewReader(`{"text":"hi"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown session, got %d", w.Code)
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	dir := t.TempDir()
+	store := state.NewTaskStore(filepath.Join(dir, "tasks.json"))
+	handler := func(sessionKey, prompt string) (string, error) {
+		if sessionKey == "http:locked" {
+			return "", gateway.ErrSessionLocked
+		}
+		return "summary of " + prompt, nil
+	}
+	srv := NewServer(store, handler, nil, nil, nil)
+
+	body := `{"items":[
+		{"session_key":"http:batch","prompt":"https://a.example"},
+		{"session_key":"http:batch","prompt":"https://b.example"},
+		{"session_key":"http:locked","prompt":"https://c.example"}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var accepted struct {
+		ID    string `json:"id"`
+		Total int    `json:"total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatal(err)
+	}
+	if accepted.ID == "" || accepted.Total != 3 {
+		t.Fatalf("unexpected accept response: %+v", accepted)
+	}
+
+	var job batchJob
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/batch/"+accepted.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		job = batchJob{}
+		if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == "done" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch did not finish: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if job.Completed != 3 || job.Failed != 1 || job.FinishedAt == nil {
+		t.Errorf("unexpected job totals: %+v", job)
+	}
+	if job.Items[0].Status != "done" || job.Items[0].Response != "summary of https://a.example" {
+		t.Errorf("unexpected first item: %+v", job.Items[0])
+	}
+	if job.Items[2].Status != "failed" || job.Items[2].Error == "" {
+		t.Errorf("expected locked item to fail, got %+v", job.Items[2])
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/batch/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown job, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(`{"items":[{"prompt":"x"}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for item without session_key, got %d", w.Code)
+	}
+}
+
+func TestProfilingDisabledByDefault(t *testing.T) {
+	srv := setupServer(t, &mockGateway{})
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without profiling, got %d", w.Code)
+	}
+
+	srv.EnableProfiling()
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected goroutine profile, got %d", w.Code)
+	}
+}
@@ -0,0 +1,31 @@
+// Service worker for the gopherclaw dashboard: shows push notifications
+// for finished long runs and webpush: deliveries, and opens the session
+// when one is clicked.
+"use strict";
+
+self.addEventListener("push", function(event) {
+  var data = {};
+  try {
+    data = event.data ? event.data.json() : {};
+  } catch (e) {
+    data = { body: event.data ? event.data.text() : "" };
+  }
+  event.waitUntil(self.registration.showNotification(data.title || "gopherclaw", {
+    body: data.body || "",
+    tag: data.tag || undefined,
+    data: { url: data.url || "/" }
+  }));
+});
+
+self.addEventListener("notificationclick", function(event) {
+  event.notification.close();
+  var url = new URL(event.notification.data.url || "/", self.location.origin).href;
+  event.waitUntil(clients.matchAll({ type: "window", includeUncontrolled: true }).then(function(windows) {
+    for (var i = 0; i < windows.length; i++) {
+      if (new URL(windows[i].url).origin === self.location.origin && "focus" in windows[i]) {
+        return windows[i].navigate(url).then(function(w) { return (w || windows[i]).focus(); });
+      }
+    }
+    return clients.openWindow(url);
+  }));
+});
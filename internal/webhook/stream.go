@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+const (
+	// streamKeepAlive is how often an idle event stream gets a comment, so
+	// proxies don't time it out.
+	streamKeepAlive = 15 * time.Second
+	// maxStreamReplay caps the events replayed when a stream starts.
+	maxStreamReplay = 1000
+)
+
+// eventSubscriber is an event store that can push new events, such as
+// *state.EventStore.
+type eventSubscriber interface {
+	Subscribe(sessionID types.SessionID) (<-chan *types.Event, func())
+}
+
+// handleAPIStream streams a session's events as server-sent events while
+// they are appended. Each event is one message whose id is its sequence
+// number. A client reconnecting with Last-Event-ID, or asking with
+// ?since=<seq>, first gets the events after that sequence number; ?tail=N
+// starts with the last N events instead. The stream ends if the client
+// falls too far behind; reconnecting with Last-Event-ID catches up.
+func (s *Server) handleAPIStream(w http.ResponseWriter, r *http.Request) {
+	subscriber, ok := s.events.(eventSubscriber)
+	if !ok || s.sessions == nil {
+		http.Error(w, `{"error":"event streaming not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	ctx := r.Context()
+	sessionID := types.SessionID(r.PathValue("id"))
+	if _, err := s.sessions.Get(ctx, sessionID); err != nil {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+	since := int64(-1)
+	for _, v := range []string{r.Header.Get("Last-Event-ID"), r.URL.Query().Get("since")} {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			since = n
+			break
+		}
+	}
+	tail, _ := strconv.Atoi(r.URL.Query().Get("tail"))
+
+	// Subscribe before reading the log so nothing appended in between is
+	// lost; events already sent from the log are skipped below.
+	live, cancel := subscriber.Subscribe(sessionID)
+	defer cancel()
+
+	var replay []*types.Event
+	if since >= 0 || tail > 0 {
+		limit := min(tail, maxStreamReplay)
+		if since >= 0 {
+			count, err := s.events.Count(ctx, sessionID)
+			if err != nil {
+				slog.Error("count events failed", "session_id", sessionID, "error", err)
+				http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+				return
+			}
+			limit = int(min(max(count-since, 0), maxStreamReplay))
+		}
+		if limit > 0 {
+			events, err := s.events.Tail(ctx, sessionID, limit)
+			if err != nil {
+				slog.Error("tail events failed", "session_id", sessionID, "error", err)
+				http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+				return
+			}
+			replay = events
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	last := since
+	send := func(event *types.Event) error {
+		if event.Seq <= last {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		last = event.Seq
+		_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Seq, data)
+		return err
+	}
+	for _, event := range replay {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-live:
+			if !ok {
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestAPIStream(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), (&mockGateway{}).HandleTask, sessions, events, state.NewArtifactStore(dir))
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	ctx := context.Background()
+	sid, _ := sessions.ResolveOrCreate(ctx, "http:watch", "default")
+	appendText := func(text string) {
+		payload, _ := json.Marshal(map[string]string{"text": text})
+		if err := events.Append(ctx, &types.Event{ID: types.NewEventID(), SessionID: sid, Type: "user_message", At: time.Now(), Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appendText("one")
+	appendText("two")
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/sessions/"+string(sid)+"/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (string, *types.Event) {
+		t.Helper()
+		var id string
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				var e types.Event
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+					t.Fatal(err)
+				}
+				return id, &e
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return "", nil
+	}
+
+	// The event after Last-Event-ID is replayed, then new ones arrive live.
+	if id, e := next(); id != "2" || e.Seq != 2 || string(e.Payload) != `{"text":"two"}` {
+		t.Errorf("replayed %s: %+v", id, e)
+	}
+	appendText("three")
+	if id, e := next(); id != "3" || string(e.Payload) != `{"text":"three"}` {
+		t.Errorf("live %s: %+v", id, e)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sessions/missing/stream", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", w.Code)
+	}
+}
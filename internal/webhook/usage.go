@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// SetModelRegistry prices the token usage GET /api/usage reports; without
+// it every call counts as unpriced.
+func (s *Server) SetModelRegistry(models *llm.Registry) {
+	s.models = models
+}
+
+// handleAPIUsage reports token usage and cost per day, session and model,
+// over the last ?days= days (everything without it), for one ?session=
+// (with its runs) or all of them.
+func (s *Server) handleAPIUsage(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil || s.events == nil {
+		http.Error(w, `{"error":"debug API not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	query := r.URL.Query()
+	var filter usage.Filter
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"days must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		filter.Since = usage.Since(n, time.Now())
+	}
+	if v := query.Get("session"); v != "" {
+		filter.SessionID = types.SessionID(v)
+		if _, err := s.sessions.Get(r.Context(), filter.SessionID); err != nil {
+			http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+			return
+		}
+	}
+
+	report, err := usage.Collect(r.Context(), s.sessions, s.events, s.models, filter)
+	if err != nil {
+		slog.Error("collect usage failed", "error", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
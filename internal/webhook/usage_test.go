@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/usage"
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestAPIUsage(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	events := state.NewEventStore(dir)
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil, sessions, events, state.NewArtifactStore(dir))
+	srv.SetModelRegistry(llm.NewRegistry())
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	ctx := context.Background()
+	sid, _ := sessions.ResolveOrCreate(ctx, "test:a", "default")
+	old := usage.Event(sid, "r1", usage.Reply, &llm.Response{Model: "gpt-4o", Usage: llm.Usage{InputTokens: 1_000_000}})
+	old.At = time.Now().AddDate(0, 0, -10)
+	events.Append(ctx, old)
+	events.Append(ctx, usage.Event(sid, "r2", usage.Reply, &llm.Response{Model: "gpt-4o", Usage: llm.Usage{InputTokens: 400, OutputTokens: 100}}))
+
+	var report usage.Report
+	w := get("/api/usage")
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Calls != 2 || report.Total.CostUSD < 2.5 || len(report.Sessions) != 1 || report.Sessions[0].Name != "test:a" || report.Runs != nil {
+		t.Errorf("report = %+v", report)
+	}
+
+	report = usage.Report{}
+	json.NewDecoder(get("/api/usage?days=7&session=" + string(sid)).Body).Decode(&report)
+	if report.Total.Calls != 1 || report.Total.InputTokens != 400 || len(report.Runs) != 1 || report.Runs[0].Key != "r2" {
+		t.Errorf("filtered report = %+v", report)
+	}
+
+	if w := get("/api/usage?days=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for days=0, got %d", w.Code)
+	}
+	if w := get("/api/usage?session=missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", w.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/gopherclaw/internal/types"
+)
+
+//go:embed static/artifact.html
+var artifactHTML string
+
+var artifactPage = template.Must(template.New("artifact").Parse(artifactHTML))
+
+// Artifact view kinds, also accepted as ?as= to override detection.
+const (
+	viewJSON     = "json"
+	viewMarkdown = "markdown"
+	viewCode     = "code"
+	viewText     = "text"
+	viewImage    = "image"
+	viewBinary   = "binary"
+)
+
+// SetPublicURL sets the externally reachable base URL of the HTTP server
+// (e.g. "https://bot.example.com"), used to build artifact links.
+func (s *Server) SetPublicURL(base string) {
+	s.publicURL = strings.TrimRight(base, "/")
+}
+
+// SetLinkSecret signs artifact links so they open in a browser without a
+// bearer token. Typically the admin token.
+func (s *Server) SetLinkSecret(secret string) {
+	s.linkSecret = []byte(secret)
+}
+
+// ArtifactURL returns the viewer link for an artifact, or "" when no public
+// URL is set.
+func (s *Server) ArtifactURL(id types.ArtifactID) string {
+	if s.publicURL == "" {
+		return ""
+	}
+	link := s.publicURL + "/artifacts/" + url.PathEscape(string(id)) + "/view"
+	if len(s.linkSecret) > 0 {
+		link += "?sig=" + s.artifactSig(string(id))
+	}
+	return link
+}
+
+// artifactSig is the link signature for an artifact ID.
+func (s *Server) artifactSig(id string) string {
+	mac := hmac.New(sha256.New, s.linkSecret)
+	mac.Write([]byte("artifact:" + id))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// signedLink reports whether the request is an artifact view with a valid
+// link signature.
+func (s *Server) signedLink(r *http.Request) bool {
+	if len(s.linkSecret) == 0 || r.Method != http.MethodGet {
+		return false
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/artifacts/")
+	if !ok {
+		return false
+	}
+	id, ok := strings.CutSuffix(rest, "/view")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return false
+	}
+	return hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(s.artifactSig(id)))
+}
+
+// artifactView is the data for the artifact page.
+type artifactView struct {
+	Meta *types.ArtifactMeta
+	Kind string
+	Size int
+	Body template.HTML
+	// Kinds are the text kinds offered as ?as= overrides.
+	Kinds []string
+	// Sig carries the link signature into the page's "view as" links.
+	Sig string
+}
+
+// handleArtifactView renders an artifact as a readable page: pretty-printed
+// JSON, rendered markdown, highlighted code, or an inline image.
+func (s *Server) handleArtifactView(w http.ResponseWriter, r *http.Request) {
+	if s.artifacts == nil {
+		http.Error(w, "artifact store not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := types.ArtifactID(r.PathValue("id"))
+	meta, err := s.artifacts.GetMeta(r.Context(), id)
+	if err != nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+	data, err := s.artifacts.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
+	view := renderArtifact(meta, data, r.URL.Query().Get("as"))
+	if s.signedLink(r) {
+		view.Sig = r.URL.Query().Get("sig")
+	}
+	var buf bytes.Buffer
+	if err := artifactPage.Execute(&buf, view); err != nil {
+		http.Error(w, "render artifact", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// renderArtifact picks how to show an artifact's data, honouring a valid
+// ?as= override, and renders it to HTML.
+func renderArtifact(meta *types.ArtifactMeta, data json.RawMessage, as string) *artifactView {
+	view := &artifactView{Meta: meta, Size: len(data), Kinds: []string{viewMarkdown, viewCode, viewJSON, viewText}}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		// Structured data stored as-is.
+		view.Kind, view.Body = viewJSON, renderJSON(data)
+		return view
+	}
+	view.Size = len(text)
+
+	// Files ([]byte) are stored base64-encoded.
+	if raw, ok := fileBytes(meta, text); ok {
+		view.Size = len(raw)
+		contentType := meta.MimeType
+		if contentType == "" {
+			contentType = http.DetectContentType(raw)
+		}
+		switch {
+		case strings.HasPrefix(contentType, "image/"):
+			src := "data:" + contentType + ";base64," + text
+			view.Kind = viewImage
+			view.Body = template.HTML(`<img alt="artifact" src="` + template.HTMLEscapeString(src) + `">`)
+			return view
+		case utf8.Valid(raw):
+			text = string(raw)
+		default:
+			view.Kind = viewBinary
+			view.Body = template.HTML(fmt.Sprintf("<p>Binary file (%s, %d bytes).</p>", template.HTMLEscapeString(contentType), len(raw)))
+			return view
+		}
+	}
+
+	view.Kind = as
+	switch as {
+	case viewJSON, viewMarkdown, viewCode, viewText:
+	default:
+		view.Kind = detectKind(meta.Tool, text)
+	}
+	switch view.Kind {
+	case viewJSON:
+		view.Body = renderJSON(json.RawMessage(text))
+	case viewMarkdown:
+		view.Body = renderMarkdown(text)
+	case viewCode:
+		view.Body = template.HTML(`<pre class="code">` + highlight(text) + `</pre>`)
+	default:
+		view.Body = template.HTML(`<pre>` + template.HTMLEscapeString(text) + `</pre>`)
+	}
+	return view
+}
+
+// fileBytes decodes the base64 data of a stored file. Only artifacts that
+// hold uploaded files are treated this way.
+func fileBytes(meta *types.ArtifactMeta, text string) ([]byte, bool) {
+	switch meta.Tool {
+	case "attachment", "upload":
+	default:
+		return nil, false
+	}
+	raw, err := base64.StdEncoding.DecodeString(text)
+	return raw, err == nil
+}
+
+// detectKind guesses how to show a text artifact from the tool that made
+// it and its content.
+func detectKind(tool, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return viewJSON
+	}
+	if tool == "read_url" || looksLikeMarkdown(trimmed) {
+		return viewMarkdown
+	}
+	return viewText
+}
+
+// looksLikeMarkdown reports whether text has headings, fences or links
+// typical of markdown.
+func looksLikeMarkdown(text string) bool {
+	for _, line := range strings.SplitN(text, "\n", 50) {
+		if strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "```") {
+			return true
+		}
+	}
+	return strings.Contains(text, "](http")
+}
+
+// renderJSON pretty-prints JSON with highlighting, or shows it as text if
+// it doesn't parse.
+func renderJSON(data json.RawMessage) template.HTML {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return template.HTML(`<pre>` + template.HTMLEscapeString(string(data)) + `</pre>`)
+	}
+	return template.HTML(`<pre class="code">` + highlight(buf.String()) + `</pre>`)
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+func TestArtifactView(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, "test:key", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(tool string, data any) types.ArtifactID {
+		id, err := artifacts.Put(ctx, sid, types.NewRunID(), tool, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil, sessions, state.NewEventStore(dir), artifacts)
+	view := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	tests := []struct {
+		name  string
+		id    types.ArtifactID
+		query string
+		want  []string
+	}{
+		{"json text", put("bash", `{"a":[1,"x"]}`), "", []string{"· json ·", `<span class="s">&#34;a&#34;</span>`}},
+		{"structured", put("webhook", map[string]any{"k": "v"}), "", []string{"· json ·", "&#34;k&#34;"}},
+		{"markdown", put("read_url", "# Title\n\nSome **bold** and [link](https://go.dev).\n\n- one\n- two\n\n```go\nfunc main() {}\n```"), "", []string{
+			"<h1>Title</h1>", "<strong>bold</strong>", `<a href="https://go.dev"`, "<li>one</li>", `<span class="k">func</span>`,
+		}},
+		{"plain text escaped", put("bash", "<script>alert(1)</script>"), "", []string{"· text ·", "&lt;script&gt;"}},
+		{"forced code", put("bash", `x := "hi" // greet`), "as=code", []string{"· code ·", `<span class="s">&#34;hi&#34;</span>`, `<span class="c">// greet</span>`}},
+		{"image", put("upload", png), "", []string{"· image ·", `src="data:image/png;base64,`}},
+	}
+	for _, tt := range tests {
+		code, body := view("/artifacts/" + string(tt.id) + "/view?" + tt.query)
+		if code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.name, code, body)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected %q in page:\n%s", tt.name, want, body)
+			}
+		}
+		if strings.Contains(body, "<script>") {
+			t.Errorf("%s: unescaped script in page", tt.name)
+		}
+	}
+
+	if code, _ := view("/artifacts/missing/view"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown artifact, got %d", code)
+	}
+}
+
+func TestArtifactViewSignedLinks(t *testing.T) {
+	dir := t.TempDir()
+	sessions := state.NewSessionStore(dir)
+	artifacts := state.NewArtifactStore(dir)
+	ctx := context.Background()
+	sid, err := sessions.ResolveOrCreate(ctx, "test:key", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := artifacts.Put(ctx, sid, types.NewRunID(), "bash", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(state.NewTaskStore(filepath.Join(dir, "tasks.json")), nil, sessions, state.NewEventStore(dir), artifacts)
+	if got := srv.ArtifactURL(id); got != "" {
+		t.Errorf("expected no link without a public URL, got %q", got)
+	}
+	srv.SetPublicURL("https://bot.example.com/")
+	srv.SetTokens(map[string]Role{"admin-secret": RoleAdmin})
+	srv.SetLinkSecret("admin-secret")
+
+	link := srv.ArtifactURL(id)
+	if !strings.HasPrefix(link, "https://bot.example.com/artifacts/"+string(id)+"/view?sig=") {
+		t.Fatalf("unexpected link %q", link)
+	}
+	path := strings.TrimPrefix(link, "https://bot.example.com")
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+	code, body := get(path)
+	if code != http.StatusOK {
+		t.Fatalf("signed link: got %d", code)
+	}
+	if !strings.Contains(body, "sig=") {
+		t.Error("expected view-as links to keep the signature")
+	}
+	if code, _ := get("/artifacts/" + string(id) + "/view"); code != http.StatusUnauthorized {
+		t.Errorf("unsigned link: got %d, want 401", code)
+	}
+	if code, _ := get("/artifacts/" + string(id) + "/view?sig=deadbeef"); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: got %d, want 401", code)
+	}
+	// A signature only opens the viewer page it was made for.
+	if code, _ := get("/api/artifacts/" + string(id) + "?" + strings.SplitN(path, "?", 2)[1]); code != http.StatusUnauthorized {
+		t.Errorf("signature on the API: got %d, want 401", code)
+	}
+}
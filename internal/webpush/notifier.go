@@ -0,0 +1,139 @@
+package webpush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// Notification is the JSON payload the web UI's service worker shows.
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// URL is opened when the notification is clicked.
+	URL string `json:"url,omitempty"`
+	// Tag makes a newer notification replace an older one with the same tag.
+	Tag string `json:"tag,omitempty"`
+}
+
+// maxBody bounds a notification's body; browsers show a few lines anyway.
+const maxBody = 1000
+
+// defaultTTL is how long push services hold a notification for a browser
+// that is offline.
+const defaultTTL = 24 * time.Hour
+
+// Notifier sends notifications to the subscriptions in a PushStore and
+// forgets those the push service reports gone.
+type Notifier struct {
+	store  *state.PushStore
+	sender *Sender
+	ttl    time.Duration
+}
+
+// NewNotifier creates a Notifier.
+func NewNotifier(store *state.PushStore, sender *Sender) *Notifier {
+	return &Notifier{store: store, sender: sender, ttl: defaultTTL}
+}
+
+// Deliver is the delivery handler for "webpush:<name>" session keys: it
+// sends message to every browser subscribed under name. It fails if name
+// has no subscriptions or none of them could be reached.
+func (n *Notifier) Deliver(sessionKey, message string) error {
+	key, err := types.ParseSessionKey(sessionKey)
+	if err != nil {
+		return err
+	}
+	subs, err := n.store.ForName(key.Field("name"))
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return fmt.Errorf("no push subscriptions for %q", key.Field("name"))
+	}
+	return n.send(context.Background(), subs, Notification{
+		Title: "gopherclaw",
+		Body:  message,
+		URL:   SessionURL(sessionKey),
+		Tag:   sessionKey,
+	})
+}
+
+// NotifyRunDone tells every subscribed browser that a long run finished,
+// with the start of its reply. The notification opens the session.
+func (n *Notifier) NotifyRunDone(ctx context.Context, sessionKey types.SessionKey, sessionID types.SessionID, elapsed time.Duration, response string) error {
+	subs, err := n.store.List()
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	body := response
+	if body == "" {
+		body = "(no reply)"
+	}
+	return n.send(ctx, subs, Notification{
+		Title: fmt.Sprintf("Run finished in %s after %s", sessionKey, elapsed.Round(time.Second)),
+		Body:  body,
+		URL:   SessionURL(string(sessionID)),
+		Tag:   string(sessionID),
+	})
+}
+
+// send delivers the notification to each subscription, removing any the
+// push service has dropped. It succeeds if at least one was delivered.
+func (n *Notifier) send(ctx context.Context, subs []*state.PushSubscription, note Notification) error {
+	note.Body = excerpt(note.Body, maxBody)
+	payload, err := json.Marshal(note)
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+
+	var errs []error
+	sent := 0
+	for _, sub := range subs {
+		err := n.sender.Send(ctx, Subscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}, payload, n.ttl)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ErrGone):
+			slog.InfoContext(ctx, "removing expired push subscription", "name", sub.Name, "endpoint", sub.Endpoint)
+			if _, rmErr := n.store.Remove(sub.Endpoint); rmErr != nil {
+				slog.WarnContext(ctx, "remove push subscription failed", "error", rmErr)
+			}
+			errs = append(errs, err)
+		default:
+			errs = append(errs, err)
+		}
+	}
+	if sent == 0 && len(errs) > 0 {
+		return fmt.Errorf("push to %d subscriptions failed: %w", len(subs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// SessionURL is the web UI address that opens a session, given its ID or
+// session key.
+func SessionURL(session string) string {
+	return "/#session=" + url.QueryEscape(session)
+}
+
+// excerpt cuts s to at most n bytes on a rune boundary, marking the cut.
+func excerpt(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	cut := n - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
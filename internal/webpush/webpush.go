@@ -0,0 +1,302 @@
+// Package webpush sends Web Push notifications to browsers that subscribed
+// through the web UI. Payloads are encrypted as RFC 8291 (aes128gcm) and
+// requests are signed with VAPID (RFC 8292), using only the standard
+// library.
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrGone means the push service no longer knows the subscription (404 or
+// 410); it should be forgotten.
+var ErrGone = errors.New("push subscription expired")
+
+// MaxPayload is the largest plaintext a push service must accept in one
+// aes128gcm record: 4096 bytes less the header, tag and delimiter.
+const MaxPayload = 4096 - 86 - 16 - 1
+
+// recordSize is the rs field of the aes128gcm header.
+const recordSize = 4096
+
+// b64 is the unpadded base64url encoding used for keys, JWTs and the
+// browser's subscription fields.
+var b64 = base64.RawURLEncoding
+
+// Subscription is where and how to reach one browser, as returned by
+// PushManager.subscribe: the push service endpoint, the browser's P-256
+// public key and its 16-byte auth secret, both base64url.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// Keys is the server's VAPID key pair, base64url: the uncompressed P-256
+// public key browsers subscribe with, and the private scalar.
+type Keys struct {
+	Public  string `json:"public_key"`
+	Private string `json:"private_key"`
+}
+
+// GenerateKeys creates a new VAPID key pair.
+func GenerateKeys() (*Keys, error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate vapid key: %w", err)
+	}
+	return &Keys{
+		Public:  b64.EncodeToString(priv.PublicKey().Bytes()),
+		Private: b64.EncodeToString(priv.Bytes()),
+	}, nil
+}
+
+// LoadOrCreateKeys reads the key pair at path, generating and saving one
+// if the file doesn't exist. Changing keys invalidates every subscription,
+// so the file is kept with the rest of the data directory.
+func LoadOrCreateKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var keys Keys
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("parse vapid keys: %w", err)
+		}
+		if _, err := keys.signingKey(); err != nil {
+			return nil, err
+		}
+		return &keys, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read vapid keys: %w", err)
+	}
+
+	keys, err := GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal vapid keys: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create vapid keys dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return nil, fmt.Errorf("write temp vapid keys file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("rename temp vapid keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// signingKey decodes the private key for ES256 signatures.
+func (k *Keys) signingKey() (*ecdsa.PrivateKey, error) {
+	raw, err := b64.DecodeString(k.Private)
+	if err != nil {
+		return nil, fmt.Errorf("decode vapid private key: %w", err)
+	}
+	priv, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse vapid private key: %w", err)
+	}
+	return priv, nil
+}
+
+// Sender posts encrypted messages to push services.
+type Sender struct {
+	keys    *Keys
+	signer  *ecdsa.PrivateKey
+	subject string
+	client  *http.Client
+}
+
+// NewSender creates a Sender signing with keys. subject is the contact push
+// services may use about misbehaving traffic, a "mailto:" or "https:" URL.
+func NewSender(keys *Keys, subject string) (*Sender, error) {
+	signer, err := keys.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{
+		keys:    keys,
+		signer:  signer,
+		subject: subject,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.keys.Public
+}
+
+// Send delivers payload to the subscription. ttl is how long the push
+// service keeps the message while the browser is offline. A subscription
+// the service has dropped returns an error wrapping ErrGone.
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte, ttl time.Duration) error {
+	if len(payload) > MaxPayload {
+		return fmt.Errorf("push payload is %d bytes, max %d", len(payload), MaxPayload)
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme != "https" && endpoint.Scheme != "http" {
+		return fmt.Errorf("invalid push endpoint %q", sub.Endpoint)
+	}
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	token, err := s.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", "vapid t="+token+", k="+s.keys.Public)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request: %w", err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: status %d", ErrGone, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// vapidToken signs the ES256 JWT that identifies this server to the push
+// service at audience.
+func (s *Sender) vapidToken(audience string) (string, error) {
+	header := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": s.subject,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal vapid claims: %w", err)
+	}
+	signed := header + "." + b64.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.signer, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign vapid token: %w", err)
+	}
+	// JWS wants r and s as fixed-width big-endian integers.
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	sig.FillBytes(raw[32:])
+	return signed + "." + b64.EncodeToString(raw), nil
+}
+
+// Encrypt encrypts payload for the subscription's browser as a single
+// aes128gcm record (RFC 8291), with a fresh ephemeral key and salt.
+func Encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	uaRaw, err := b64.DecodeString(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("decode p256dh: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parse p256dh: %w", err)
+	}
+	auth, err := b64.DecodeString(sub.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, fmt.Errorf("invalid auth secret")
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate ephemeral key: %w", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	secret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh: %w", err)
+	}
+	asRaw := asPrivate.PublicKey().Bytes()
+	gcm, nonce, err := contentKeys(secret, auth, salt, uaRaw, asRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	// One record: the payload and the 0x02 last-record delimiter, unpadded.
+	plain := append(append([]byte(nil), payload...), 0x02)
+	header := make([]byte, 0, 21+len(asRaw))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asRaw)))
+	header = append(header, asRaw...)
+	return gcm.Seal(header, nonce, plain, nil), nil
+}
+
+// contentKeys derives the record cipher and nonce from the ECDH secret,
+// the browser's auth secret and both public keys (RFC 8291 section 3.4).
+func contentKeys(secret, auth, salt, uaPublic, asPublic []byte) (cipher.AEAD, []byte, error) {
+	mac := hmac.New(sha256.New, auth)
+	mac.Write(secret)
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, mac.Sum(nil), keyInfo, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("derive ikm: %w", err)
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("derive prk: %w", err)
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("derive content key: %w", err)
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, fmt.Errorf("derive nonce: %w", err)
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return gcm, nonce, nil
+}
@@ -0,0 +1,250 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+)
+
+// browser is the receiving end of a subscription.
+type browser struct {
+	priv *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) *browser {
+	t.Helper()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &browser{priv: priv, auth: auth}
+}
+
+func (b *browser) subscription(endpoint string) Subscription {
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   b64.EncodeToString(b.priv.PublicKey().Bytes()),
+		Auth:     b64.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses Encrypt the way a browser does.
+func (b *browser) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	asRaw := body[21 : 21+idlen]
+	if rs != recordSize || idlen != 65 {
+		t.Fatalf("unexpected header rs=%d idlen=%d", rs, idlen)
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(asRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := b.priv.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, nonce, err := contentKeys(secret, b.auth, salt, b.priv.PublicKey().Bytes(), asRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	b := newBrowser(t)
+	body, err := Encrypt(b.subscription("https://push.example/1"), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.decrypt(t, body); string(got) != "hello" {
+		t.Errorf("decrypted %q", got)
+	}
+
+	if _, err := Encrypt(Subscription{P256dh: "not-a-key", Auth: b64.EncodeToString(b.auth)}, nil); err == nil {
+		t.Error("expected an invalid p256dh to be rejected")
+	}
+}
+
+func TestLoadOrCreateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vapid.json")
+	keys, err := LoadOrCreateKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadOrCreateKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again != *keys {
+		t.Error("expected the saved keys to be loaded again")
+	}
+	if raw, _ := b64.DecodeString(keys.Public); len(raw) != 65 || raw[0] != 4 {
+		t.Errorf("public key should be an uncompressed P-256 point, got %d bytes", len(raw))
+	}
+}
+
+// pushService is a fake push service that records decrypted payloads.
+type pushService struct {
+	t       *testing.T
+	browser *browser
+	keys    *Keys
+	status  int
+
+	mu       sync.Mutex
+	payloads []string
+}
+
+func (p *pushService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+		http.Error(w, "bad headers", http.StatusBadRequest)
+		return
+	}
+	if err := p.checkVAPID(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if p.status != 0 {
+		w.WriteHeader(p.status)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	p.payloads = append(p.payloads, string(p.browser.decrypt(p.t, body)))
+	p.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkVAPID verifies the JWT's ES256 signature against the k= key and its
+// audience against the request's origin.
+func (p *pushService) checkVAPID(r *http.Request) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "vapid t=")
+	if !ok {
+		return errors.New("missing vapid authorization")
+	}
+	token, key, ok := strings.Cut(auth, ", k=")
+	if !ok || key != p.keys.Public {
+		return errors.New("wrong vapid key")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed jwt")
+	}
+	raw, _ := b64.DecodeString(key)
+	x, y := elliptic.Unmarshal(elliptic.P256(), raw)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	sig, _ := b64.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return errors.New("bad signature")
+	}
+	claimsJSON, _ := b64.DecodeString(parts[1])
+	var claims struct {
+		Aud string `json:"aud"`
+		Sub string `json:"sub"`
+	}
+	json.Unmarshal(claimsJSON, &claims)
+	if claims.Aud != "http://"+r.Host || claims.Sub != "mailto:ops@example.com" {
+		return errors.New("bad claims")
+	}
+	return nil
+}
+
+func newPushService(t *testing.T, keys *Keys) (*pushService, *httptest.Server) {
+	svc := &pushService{t: t, browser: newBrowser(t), keys: keys}
+	srv := httptest.NewServer(svc)
+	t.Cleanup(srv.Close)
+	return svc, srv
+}
+
+func TestSenderSend(t *testing.T) {
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(keys, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, srv := newPushService(t, keys)
+	sub := svc.browser.subscription(srv.URL + "/push/abc")
+
+	if err := sender.Send(context.Background(), sub, []byte(`{"title":"hi"}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.payloads) != 1 || svc.payloads[0] != `{"title":"hi"}` {
+		t.Errorf("push service got %q", svc.payloads)
+	}
+
+	svc.status = http.StatusGone
+	if err := sender.Send(context.Background(), sub, []byte("x"), time.Hour); !errors.Is(err, ErrGone) {
+		t.Errorf("expected ErrGone for a 410, got %v", err)
+	}
+	if err := sender.Send(context.Background(), sub, make([]byte, MaxPayload+1), time.Hour); err == nil {
+		t.Error("expected an oversized payload to be rejected")
+	}
+}
+
+func TestNotifierDeliver(t *testing.T) {
+	keys, _ := GenerateKeys()
+	sender, _ := NewSender(keys, "mailto:ops@example.com")
+	store := state.NewPushStore(filepath.Join(t.TempDir(), "push.json"))
+	n := NewNotifier(store, sender)
+
+	if err := n.Deliver("webpush:alice", "hello"); err == nil {
+		t.Error("expected an error with no subscriptions")
+	}
+
+	live, liveSrv := newPushService(t, keys)
+	gone, goneSrv := newPushService(t, keys)
+	gone.status = http.StatusNotFound
+	for _, sub := range []Subscription{live.browser.subscription(liveSrv.URL + "/1"), gone.browser.subscription(goneSrv.URL + "/2")} {
+		if err := store.Put(&state.PushSubscription{Name: "alice", Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := n.Deliver("webpush:alice", "Daily report: "+strings.Repeat("ok ", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if len(live.payloads) != 1 {
+		t.Fatalf("expected one push, got %d", len(live.payloads))
+	}
+	var note Notification
+	if err := json.Unmarshal([]byte(live.payloads[0]), &note); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(note.Body, "Daily report: ok") || len(note.Body) > maxBody || note.URL != "/#session=webpush%3Aalice" {
+		t.Errorf("unexpected notification %+v", note)
+	}
+	if subs, _ := store.ForName("alice"); len(subs) != 1 || subs[0].Endpoint != liveSrv.URL+"/1" {
+		t.Errorf("expected the gone subscription to be removed, got %+v", subs)
+	}
+}
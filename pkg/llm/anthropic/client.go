@@ -0,0 +1,483 @@
+// Package anthropic implements llm.Provider against Anthropic's Messages API.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// providerName is reported in llm.Response.Provider.
+const providerName = "anthropic"
+
+// DefaultBaseURL is the Anthropic API endpoint.
+const DefaultBaseURL = "https://api.anthropic.com/v1"
+
+// apiVersion is sent as the anthropic-version header.
+const apiVersion = "2023-06-01"
+
+// defaultMaxTokens is used when the config sets none; the Messages API
+// requires max_tokens on every request.
+const defaultMaxTokens = 4096
+
+// Client implements the llm.Provider interface for the Anthropic Messages
+// API.
+type Client struct {
+	config     *llm.Config
+	httpClient *http.Client
+}
+
+// New creates a new Anthropic client with the given configuration. An empty
+// BaseURL uses DefaultBaseURL.
+func New(config *llm.Config) *Client {
+	return &Client{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// messagesRequest is the Messages API request body.
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	Tools       []tool    `json:"tools,omitempty"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float32  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+// message is one conversation turn. Roles alternate between "user" and
+// "assistant"; tool results are user turns.
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a text, tool_use, tool_result or thinking block.
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+
+	// thinking
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// tool is the Messages API tool definition.
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// messagesResponse is the Messages API response body.
+type messagesResponse struct {
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// errorResponse is the Messages API error body.
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newRequest builds a Messages API request for the conversation.
+func (c *Client) newRequest(ctx context.Context, messages []llm.Message, tools []llm.Tool, stream bool) (*http.Request, error) {
+	system, reqMessages := convertMessages(messages)
+	reqBody := messagesRequest{
+		Model:     c.config.Model,
+		System:    system,
+		Messages:  reqMessages,
+		MaxTokens: c.config.MaxTokens,
+		Stream:    stream,
+	}
+	if reqBody.MaxTokens <= 0 {
+		reqBody.MaxTokens = defaultMaxTokens
+	}
+	for _, t := range tools {
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		reqBody.Tools = append(reqBody.Tools, tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if c.config.Temperature != 0 {
+		temp := c.config.Temperature
+		reqBody.Temperature = &temp
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	baseURL := c.config.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", c.config.APIKey)
+	req.Header.Set("Anthropic-Version", apiVersion)
+	return req, nil
+}
+
+// apiError builds the error for a non-200 response from its body.
+func apiError(status int, body []byte) error {
+	var apiErr errorResponse
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return &llm.APIError{Status: status, Message: apiErr.Error.Type + ": " + apiErr.Error.Message}
+	}
+	return &llm.APIError{Status: status, Message: string(body)}
+}
+
+// Complete sends a Messages API request and returns the full response.
+func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	req, err := c.newRequest(ctx, messages, tools, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp.StatusCode, respBody)
+	}
+
+	var msgResp messagesResponse
+	if err := json.Unmarshal(respBody, &msgResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	var text, reasoning []string
+	var toolCalls []llm.ToolCall
+	for _, block := range msgResp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "thinking":
+			reasoning = append(reasoning, block.Thinking)
+		case "tool_use":
+			args := block.Input
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			toolCalls = append(toolCalls, llm.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: llm.FunctionCall{
+					Name:      block.Name,
+					Arguments: args,
+				},
+			})
+		}
+	}
+
+	model := msgResp.Model
+	if model == "" {
+		model = c.config.Model
+	}
+	return &llm.Response{
+		Content:   strings.Join(text, ""),
+		ToolCalls: toolCalls,
+		Usage: llm.Usage{
+			InputTokens:  msgResp.Usage.InputTokens,
+			OutputTokens: msgResp.Usage.OutputTokens,
+			TotalTokens:  msgResp.Usage.InputTokens + msgResp.Usage.OutputTokens,
+		},
+		Provider:     providerName,
+		Model:        model,
+		FinishReason: finishReason(msgResp.StopReason),
+		Reasoning:    strings.Join(reasoning, "\n\n"),
+	}, nil
+}
+
+// finishReason maps a stop_reason to the OpenAI-style finish reasons the
+// rest of gopherclaw records.
+func finishReason(stop string) string {
+	switch stop {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	default:
+		return stop
+	}
+}
+
+// convertMessages translates OpenAI-style messages into a system prompt and
+// Messages API turns. System messages are joined into the system prompt,
+// assistant tool calls become tool_use blocks, and tool messages become
+// tool_result blocks in a user turn. Consecutive turns with the same role
+// are merged, since the API expects roles to alternate.
+func convertMessages(messages []llm.Message) (string, []message) {
+	var system []string
+	var out []message
+	add := func(role string, blocks ...contentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, message{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "tool":
+			if len(msg.Tools) == 0 {
+				continue
+			}
+			content := msg.Content
+			if content == "" {
+				content = "(no output)"
+			}
+			add("user", contentBlock{Type: "tool_result", ToolUseID: msg.Tools[0].ID, Content: content})
+		case "assistant":
+			var blocks []contentBlock
+			if strings.TrimSpace(msg.Content) != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.Tools {
+				input := tc.Function.Arguments
+				if len(bytes.TrimSpace(input)) == 0 || !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			add("assistant", blocks...)
+		default:
+			if strings.TrimSpace(msg.Content) != "" {
+				add("user", contentBlock{Type: "text", Text: msg.Content})
+			}
+		}
+	}
+	// The conversation must open with a user turn; history trimmed to
+	// start at an assistant reply gets a placeholder.
+	if len(out) > 0 && out[0].Role == "assistant" {
+		out = append([]message{{Role: "user", Content: []contentBlock{{Type: "text", Text: "(earlier conversation omitted)"}}}}, out...)
+	}
+	return strings.Join(system, "\n\n"), out
+}
+
+// Stream sends a Messages API request with stream:true and returns a
+// channel of incremental deltas parsed from the server-sent events. Errors
+// before the first byte of the body are returned directly; later ones end
+// the stream with a Delta carrying Err. Cancelling ctx aborts the request
+// and closes the channel.
+func (c *Client) Stream(ctx context.Context, messages []llm.Message, tools []llm.Tool) (<-chan llm.Delta, error) {
+	req, err := c.newRequest(ctx, messages, tools, true)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// The client timeout covers reading the whole body, which would cut
+	// long generations short; the stream is bounded by ctx instead.
+	client := *c.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, apiError(resp.StatusCode, respBody)
+	}
+
+	ch := make(chan llm.Delta)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		send := func(d llm.Delta) bool {
+			select {
+			case ch <- d:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := readStream(resp.Body, c.config.Model, send); err != nil && ctx.Err() == nil {
+			send(llm.Delta{Err: err})
+		}
+	}()
+	return ch, nil
+}
+
+// maxStreamLine bounds a single server-sent event line.
+const maxStreamLine = 1 << 20
+
+// streamEvent is one Messages API stream event. Which fields are set
+// depends on Type.
+type streamEvent struct {
+	Type string `json:"type"`
+	// message_start
+	Message *messagesResponse `json:"message"`
+	// content_block_start, content_block_delta
+	Index        int           `json:"index"`
+	ContentBlock *contentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		// message_delta
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	// message_delta
+	Usage *struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	// error
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readStream parses server-sent events from r and passes text, thinking
+// and tool input to send as they arrive, then a final Delta with the stop
+// reason and usage, until message_stop, the end of the body, or send
+// reports that the consumer has gone.
+func readStream(r io.Reader, model string, send func(llm.Delta) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	var usage llm.Usage
+	var stop string
+	// Tool calls are numbered among themselves, not among content blocks.
+	toolIndex := map[int]int{}
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// Blank separators, event names and other SSE fields.
+			continue
+		}
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return fmt.Errorf("parsing stream event: %w", err)
+		}
+
+		var d llm.Delta
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				if ev.Message.Model != "" {
+					model = ev.Message.Model
+				}
+				usage.InputTokens = ev.Message.Usage.InputTokens
+				usage.OutputTokens = ev.Message.Usage.OutputTokens
+			}
+			continue
+		case "content_block_start":
+			if ev.ContentBlock == nil || ev.ContentBlock.Type != "tool_use" {
+				continue
+			}
+			// The block's input is always empty here; it arrives as
+			// input_json_delta fragments.
+			toolIndex[ev.Index] = len(toolIndex)
+			d.ToolCalls = []llm.ToolCall{{
+				Index:    toolIndex[ev.Index],
+				ID:       ev.ContentBlock.ID,
+				Type:     "function",
+				Function: llm.FunctionCall{Name: ev.ContentBlock.Name},
+			}}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				d.Content = ev.Delta.Text
+			case "thinking_delta":
+				d.Reasoning = ev.Delta.Thinking
+			case "input_json_delta":
+				i, ok := toolIndex[ev.Index]
+				if !ok || ev.Delta.PartialJSON == "" {
+					continue
+				}
+				d.ToolCalls = []llm.ToolCall{{Index: i, Function: llm.FunctionCall{Arguments: json.RawMessage(ev.Delta.PartialJSON)}}}
+			default:
+				continue
+			}
+			if d.Content == "" && d.Reasoning == "" && len(d.ToolCalls) == 0 {
+				continue
+			}
+		case "message_delta":
+			if ev.Delta.StopReason != "" {
+				stop = ev.Delta.StopReason
+			}
+			if ev.Usage != nil {
+				usage.OutputTokens = ev.Usage.OutputTokens
+			}
+			continue
+		case "message_stop":
+			usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+			send(llm.Delta{FinishReason: finishReason(stop), Usage: &usage, Provider: providerName, Model: model})
+			return nil
+		case "error":
+			if ev.Error != nil {
+				return fmt.Errorf("stream error: %s: %s", ev.Error.Type, ev.Error.Message)
+			}
+			return errors.New("stream error")
+		default:
+			// ping, content_block_stop
+			continue
+		}
+		d.Provider, d.Model = providerName, model
+		if !send(d) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading stream: %w", err)
+	}
+	return errors.New("stream ended before message_stop")
+}
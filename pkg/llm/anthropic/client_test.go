@@ -0,0 +1,197 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestAnthropicClient(t *testing.T) {
+	var got messagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("X-Api-Key") != "test-key" || r.Header.Get("Anthropic-Version") != apiVersion {
+			t.Error("missing or invalid auth headers")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(`{
+			"model": "claude-sonnet-4-20250514",
+			"content": [
+				{"type": "thinking", "thinking": "Need the weather."},
+				{"type": "text", "text": "Let me check."},
+				{"type": "tool_use", "id": "toolu_2", "name": "weather", "input": {"city": "Oslo"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 30, "output_tokens": 12}
+		}`))
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL, APIKey: "test-key", Model: "claude-sonnet-4"})
+	messages := []llm.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "Search for Oslo"},
+		{Role: "assistant", Tools: []llm.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: llm.FunctionCall{Name: "search", Arguments: json.RawMessage(`{"q":"Oslo"}`)}},
+		}},
+		{Role: "tool", Content: "Oslo is in Norway", Tools: []llm.ToolCall{{ID: "toolu_1"}}},
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "And the weather?"},
+	}
+	tools := []llm.Tool{{Type: "function", Function: llm.Function{
+		Name: "weather", Description: "Get the weather", Parameters: json.RawMessage(`{"type":"object"}`),
+	}}}
+
+	resp, err := client.Complete(context.Background(), messages, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.System != "You are helpful.\n\nBe brief." {
+		t.Errorf("unexpected system prompt %q", got.System)
+	}
+	if got.MaxTokens != defaultMaxTokens {
+		t.Errorf("expected default max_tokens, got %d", got.MaxTokens)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "weather" || string(got.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected tools %+v", got.Tools)
+	}
+	// The tool result and the follow-up question share one user turn.
+	if len(got.Messages) != 3 {
+		t.Fatalf("expected 3 turns, got %+v", got.Messages)
+	}
+	use := got.Messages[1].Content[0]
+	if got.Messages[1].Role != "assistant" || use.Type != "tool_use" || use.ID != "toolu_1" || string(use.Input) != `{"q":"Oslo"}` {
+		t.Errorf("unexpected tool_use turn %+v", got.Messages[1])
+	}
+	result := got.Messages[2]
+	if result.Role != "user" || len(result.Content) != 2 ||
+		result.Content[0].Type != "tool_result" || result.Content[0].ToolUseID != "toolu_1" ||
+		result.Content[1].Text != "And the weather?" {
+		t.Errorf("unexpected tool_result turn %+v", result)
+	}
+
+	if resp.Content != "Let me check." || resp.Reasoning != "Need the weather." {
+		t.Errorf("unexpected content %q / reasoning %q", resp.Content, resp.Reasoning)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_2" ||
+		resp.ToolCalls[0].Function.Name != "weather" || string(resp.ToolCalls[0].Function.Arguments) != `{"city": "Oslo"}` {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" || resp.Provider != "anthropic" || resp.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("unexpected metadata %+v", resp)
+	}
+	if resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 12 || resp.Usage.TotalTokens != 42 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL, Model: "claude-sonnet-4"})
+	_, err := client.Complete(context.Background(), []llm.Message{{Role: "user", Content: "hi"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "authentication_error: invalid x-api-key") {
+		t.Fatalf("expected the API error message, got %v", err)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	var got messagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range []string{
+			`{"type":"message_start","message":{"model":"claude-sonnet-4-20250514","content":[],"usage":{"input_tokens":30,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Need the weather."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Let me "}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"check."}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"weather","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\": "}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"Oslo\"}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var typ struct{ Type string }
+			json.Unmarshal([]byte(ev), &typ)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, ev)
+		}
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL, APIKey: "test-key", Model: "claude-sonnet-4"})
+	deltas, err := client.Stream(context.Background(), []llm.Message{{Role: "user", Content: "Weather in Oslo?"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := llm.Collect(deltas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Stream {
+		t.Error("expected stream:true in the request")
+	}
+	if resp.Content != "Let me check." || resp.Reasoning != "Need the weather." {
+		t.Errorf("unexpected content %q / reasoning %q", resp.Content, resp.Reasoning)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_2" ||
+		resp.ToolCalls[0].Function.Name != "weather" || string(resp.ToolCalls[0].Function.Arguments) != `{"city": "Oslo"}` {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" || resp.Provider != "anthropic" || resp.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("unexpected metadata %+v", resp)
+	}
+	if resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 12 || resp.Usage.TotalTokens != 42 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":3}}}\n\n"))
+		w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL, Model: "claude-sonnet-4"})
+	deltas, err := client.Stream(context.Background(), []llm.Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llm.Collect(deltas); err == nil || !strings.Contains(err.Error(), "overloaded_error: Overloaded") {
+		t.Fatalf("expected the stream error, got %v", err)
+	}
+}
+
+func TestConvertMessagesLeadingAssistant(t *testing.T) {
+	_, out := convertMessages([]llm.Message{
+		{Role: "assistant", Content: "Earlier reply"},
+		{Role: "user", Content: "Thanks"},
+	})
+	if len(out) != 3 || out[0].Role != "user" || out[1].Role != "assistant" {
+		t.Fatalf("expected a placeholder user turn first, got %+v", out)
+	}
+}
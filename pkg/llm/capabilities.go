@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+)
+
+// Capabilities describes what a model can do and what it costs.
+type Capabilities struct {
+	// ContextWindow is the total token window (input plus output).
+	ContextWindow int `json:"context_window"`
+	// SupportsTools reports whether the model accepts tool definitions.
+	SupportsTools bool `json:"supports_tools"`
+	// SupportsVision reports whether the model accepts image input.
+	SupportsVision bool `json:"supports_vision"`
+	// Tokenizer is the tiktoken encoding used to count tokens
+	// (e.g. "cl100k_base", "o200k_base"). Models from other vendors use
+	// the closest available approximation.
+	Tokenizer string `json:"tokenizer"`
+	// InputPrice and OutputPrice are in USD per million tokens.
+	InputPrice  float64 `json:"input_price"`
+	OutputPrice float64 `json:"output_price"`
+}
+
+// Cost returns the USD cost of a request with the given usage.
+func (c Capabilities) Cost(u Usage) float64 {
+	return (float64(u.InputTokens)*c.InputPrice + float64(u.OutputTokens)*c.OutputPrice) / 1e6
+}
+
+// defaultModels are the built-in capabilities. Prices are list prices at the
+// time of writing; override them in config when they change.
+var defaultModels = map[string]Capabilities{
+	"gpt-3.5-turbo":     {ContextWindow: 16385, SupportsTools: true, Tokenizer: "cl100k_base", InputPrice: 0.5, OutputPrice: 1.5},
+	"gpt-4":             {ContextWindow: 8192, SupportsTools: true, Tokenizer: "cl100k_base", InputPrice: 30, OutputPrice: 60},
+	"gpt-4-turbo":       {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, Tokenizer: "cl100k_base", InputPrice: 10, OutputPrice: 30},
+	"gpt-4o":            {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, Tokenizer: "o200k_base", InputPrice: 2.5, OutputPrice: 10},
+	"gpt-4o-mini":       {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, Tokenizer: "o200k_base", InputPrice: 0.15, OutputPrice: 0.6},
+	"gpt-4.1":           {ContextWindow: 1047576, SupportsTools: true, SupportsVision: true, Tokenizer: "o200k_base", InputPrice: 2, OutputPrice: 8},
+	"gpt-4.1-mini":      {ContextWindow: 1047576, SupportsTools: true, SupportsVision: true, Tokenizer: "o200k_base", InputPrice: 0.4, OutputPrice: 1.6},
+	"o3-mini":           {ContextWindow: 200000, SupportsTools: true, Tokenizer: "o200k_base", InputPrice: 1.1, OutputPrice: 4.4},
+	"claude-3-5-haiku":  {ContextWindow: 200000, SupportsTools: true, Tokenizer: "cl100k_base", InputPrice: 0.8, OutputPrice: 4},
+	"claude-3-5-sonnet": {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, Tokenizer: "cl100k_base", InputPrice: 3, OutputPrice: 15},
+	"claude-sonnet-4":   {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, Tokenizer: "cl100k_base", InputPrice: 3, OutputPrice: 15},
+	"claude-opus-4":     {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, Tokenizer: "cl100k_base", InputPrice: 15, OutputPrice: 75},
+}
+
+// Registry maps model names to their capabilities. It is safe for
+// concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]Capabilities
+}
+
+// NewRegistry returns a registry preloaded with the built-in models.
+func NewRegistry() *Registry {
+	r := &Registry{models: make(map[string]Capabilities, len(defaultModels))}
+	for name, caps := range defaultModels {
+		r.models[name] = caps
+	}
+	return r
+}
+
+// Set registers or replaces the capabilities for a model name.
+func (r *Registry) Set(model string, caps Capabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[model] = caps
+}
+
+// Lookup returns the capabilities for a model. Names are matched exactly
+// first, then by the longest registered prefix so dated snapshots such as
+// "gpt-4o-2024-08-06" resolve to "gpt-4o". A vendor prefix like
+// "openai/gpt-4o" (as used by routers such as OpenRouter) is ignored.
+func (r *Registry) Lookup(model string) (Capabilities, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if caps, ok := r.models[model]; ok {
+		return caps, true
+	}
+	if i := strings.LastIndexByte(model, '/'); i >= 0 {
+		model = model[i+1:]
+		if caps, ok := r.models[model]; ok {
+			return caps, true
+		}
+	}
+
+	best := ""
+	for name := range r.models {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Capabilities{}, false
+	}
+	return r.models[best], true
+}
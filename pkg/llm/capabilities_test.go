@@ -0,0 +1,55 @@
+package llm
+
+import "testing"
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry()
+
+	tests := []struct {
+		model  string
+		window int
+		ok     bool
+	}{
+		{"gpt-4o", 128000, true},
+		{"gpt-4o-2024-08-06", 128000, true},
+		{"gpt-4o-mini-2024-07-18", 128000, true},
+		{"gpt-4", 8192, true},
+		{"gpt-4.1-mini", 1047576, true},
+		{"openai/gpt-4o", 128000, true},
+		{"llama3", 0, false},
+	}
+	for _, tt := range tests {
+		caps, ok := r.Lookup(tt.model)
+		if ok != tt.ok || caps.ContextWindow != tt.window {
+			t.Errorf("Lookup(%q) = %d, %v; want %d, %v", tt.model, caps.ContextWindow, ok, tt.window, tt.ok)
+		}
+	}
+
+	mini, _ := r.Lookup("gpt-4o-mini-2024-07-18")
+	if mini.InputPrice != 0.15 {
+		t.Errorf("expected gpt-4o-mini pricing, got %v", mini.InputPrice)
+	}
+}
+
+func TestRegistrySet(t *testing.T) {
+	r := NewRegistry()
+	r.Set("llama3", Capabilities{ContextWindow: 8192, Tokenizer: "cl100k_base"})
+
+	caps, ok := r.Lookup("llama3:8b")
+	if !ok || caps.ContextWindow != 8192 {
+		t.Errorf("expected override to be found by prefix, got %+v, %v", caps, ok)
+	}
+
+	// Overrides don't leak into other registries
+	if _, ok := NewRegistry().Lookup("llama3"); ok {
+		t.Error("expected fresh registry to be unaffected")
+	}
+}
+
+func TestCapabilitiesCost(t *testing.T) {
+	caps := Capabilities{InputPrice: 2.5, OutputPrice: 10}
+	got := caps.Cost(Usage{InputTokens: 1_000_000, OutputTokens: 500_000})
+	if got != 7.5 {
+		t.Errorf("expected 7.5, got %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Error classes. Providers and tools wrap one of them in the errors they
+// return, and callers deciding whether to retry or fail over test for them
+// with errors.Is, or use Class.
+var (
+	// ErrTransient is a failure that may not recur: a server error,
+	// a timeout or a dropped connection.
+	ErrTransient = errors.New("transient error")
+	// ErrRateLimited is a refusal for sending too much, too fast.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAuth is a rejected or missing key.
+	ErrAuth = errors.New("authentication failed")
+	// ErrInvalidRequest is a request that will fail the same way if sent
+	// again, such as one with bad arguments or an unknown model.
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// APIError is an HTTP error response from an API. It wraps the error class
+// of its status.
+type APIError struct {
+	Status int
+	// Message is the error the API gave, or the response body.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.Status, e.Message)
+}
+
+// Unwrap returns the error class of the status.
+func (e *APIError) Unwrap() error {
+	return StatusClass(e.Status)
+}
+
+// StatusClass returns the error class of an HTTP error status, or nil for
+// a status that isn't an error.
+func StatusClass(status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusRequestTimeout || status >= 500:
+		return ErrTransient
+	case status >= 400:
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// Class returns the error class err belongs to: the one it wraps, or
+// ErrTransient for a network error or a deadline that isn't the caller's.
+// It returns nil if err is nil or of no known class.
+func Class(err error) error {
+	for _, class := range []error{ErrRateLimited, ErrAuth, ErrInvalidRequest, ErrTransient} {
+		if errors.Is(err, class) {
+			return class
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrTransient
+	}
+	return nil
+}
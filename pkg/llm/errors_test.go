@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestClass(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want error
+	}{
+		{&APIError{Status: 500, Message: "boom"}, ErrTransient},
+		{&APIError{Status: 529, Message: "overloaded"}, ErrTransient},
+		{&APIError{Status: 408, Message: "timeout"}, ErrTransient},
+		{&APIError{Status: 429, Message: "slow down"}, ErrRateLimited},
+		{fmt.Errorf("backup: %w", &RateLimitError{Quota: true}), ErrRateLimited},
+		{&APIError{Status: 401, Message: "invalid key"}, ErrAuth},
+		{&APIError{Status: 403, Message: "forbidden"}, ErrAuth},
+		{&APIError{Status: 400, Message: "bad request"}, ErrInvalidRequest},
+		{&APIError{Status: 404, Message: "no such model"}, ErrInvalidRequest},
+		{fmt.Errorf("%w: url is required", ErrInvalidRequest), ErrInvalidRequest},
+		{fmt.Errorf("sending request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), ErrTransient},
+		{fmt.Errorf("sending request: %w", context.DeadlineExceeded), ErrTransient},
+		{errors.New("API error (status 500): only looks like one"), nil},
+		{nil, nil},
+	} {
+		if got := Class(tc.err); got != tc.want {
+			t.Errorf("Class(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+
+	err := &APIError{Status: 502, Message: "bad gateway"}
+	if got := err.Error(); got != "API error (status 502): bad gateway" {
+		t.Errorf("Error() = %q", got)
+	}
+}
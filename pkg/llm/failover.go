@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Backend is a named provider in a Failover.
+type Backend struct {
+	Name     string
+	Provider Provider
+}
+
+// Attempt is a backend that failed a call before another served it.
+type Attempt struct {
+	Backend string `json:"backend"`
+	Error   string `json:"error"`
+}
+
+// Failover is a Provider that tries its backends in order, moving on to the
+// next when one fails with a server error, a rate limit, a timeout or a
+// connection error. Other errors, such as a rejected request, are returned
+// as they are, since the next backend would likely reject it too. Responses
+// name the backend that served them in Backend, and list the ones that
+// failed before it in Failed.
+type Failover struct {
+	backends []Backend
+}
+
+// NewFailover creates a Failover over backends, tried in the given order.
+func NewFailover(backends ...Backend) *Failover {
+	return &Failover{backends: backends}
+}
+
+// Complete returns the first response a backend gives.
+func (f *Failover) Complete(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	var failed []Attempt
+	for i, b := range f.backends {
+		resp, err := b.Provider.Complete(ctx, messages, tools)
+		if err == nil {
+			resp.Backend, resp.Failed = b.Name, failed
+			return resp, nil
+		}
+		if failed, err = f.failed(ctx, i, failed, err); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("llm failover: no backends")
+}
+
+// Stream returns the stream of the first backend that starts one. A stream
+// that fails once started is not retried, since part of the reply may
+// already have been shown. The backend is reported on a final delta.
+func (f *Failover) Stream(ctx context.Context, messages []Message, tools []Tool) (<-chan Delta, error) {
+	var failed []Attempt
+	for i, b := range f.backends {
+		deltas, err := b.Provider.Stream(ctx, messages, tools)
+		if err == nil {
+			out := make(chan Delta)
+			go func() {
+				defer close(out)
+				send := func(d Delta) bool {
+					select {
+					case out <- d:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				}
+				for d := range deltas {
+					if !send(d) || d.Err != nil {
+						return
+					}
+				}
+				send(Delta{Backend: b.Name, Failed: failed})
+			}()
+			return out, nil
+		}
+		if failed, err = f.failed(ctx, i, failed, err); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("llm failover: no backends")
+}
+
+// failed records backend i's failure. It returns the error to give up
+// with if the call should not move on to the next backend.
+func (f *Failover) failed(ctx context.Context, i int, failed []Attempt, err error) ([]Attempt, error) {
+	name := f.backends[i].Name
+	if !ShouldFailover(ctx, err) {
+		return failed, fmt.Errorf("%s: %w", name, err)
+	}
+	failed = append(failed, Attempt{Backend: name, Error: err.Error()})
+	if i == len(f.backends)-1 {
+		return failed, fmt.Errorf("all %d llm backends failed, last %s: %w", len(f.backends), name, err)
+	}
+	slog.WarnContext(ctx, "llm backend failed, failing over", "backend", name, "next", f.backends[i+1].Name, "error", err)
+	return failed, nil
+}
+
+// ShouldFailover reports whether err, from a call made with ctx, is worth
+// trying another backend for: a transient error or a rate limit. It is
+// false once ctx itself is done.
+func ShouldFailover(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	class := Class(err)
+	return class == ErrTransient || class == ErrRateLimited
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func failing(err error, calls *int) *MockProvider {
+	return &MockProvider{
+		CompleteFunc: func(context.Context, []Message, []Tool) (*Response, error) {
+			*calls++
+			return nil, err
+		},
+		StreamFunc: func(context.Context, []Message, []Tool) (<-chan Delta, error) {
+			*calls++
+			return nil, err
+		},
+	}
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	var primary, secondary int
+	f := NewFailover(
+		Backend{Name: "primary", Provider: failing(&APIError{Status: 503, Message: "overloaded"}, &primary)},
+		Backend{Name: "secondary", Provider: failing(fmt.Errorf("sending request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), &secondary)},
+		Backend{Name: "backup", Provider: &MockProvider{}},
+	)
+
+	resp, err := f.Complete(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "mock response" || resp.Backend != "backup" || len(resp.Failed) != 2 || resp.Failed[0].Backend != "primary" || resp.Failed[1].Backend != "secondary" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	deltas, err := f.Stream(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = Collect(deltas)
+	if err != nil || resp.Content != "mock stream" || resp.Backend != "backup" || len(resp.Failed) != 2 {
+		t.Errorf("streamed response = %+v, %v", resp, err)
+	}
+	if primary != 2 || secondary != 2 {
+		t.Errorf("calls = %d, %d, want each backend tried twice", primary, secondary)
+	}
+}
+
+func TestFailoverStops(t *testing.T) {
+	var first, second int
+	rejected := &APIError{Status: 400, Message: "bad request"}
+	f := NewFailover(
+		Backend{Name: "first", Provider: failing(rejected, &first)},
+		Backend{Name: "second", Provider: failing(&APIError{Status: 429, Message: "slow down"}, &second)},
+	)
+	if _, err := f.Complete(context.Background(), nil, nil); !errors.Is(err, rejected) || second != 0 {
+		t.Errorf("expected a rejected request not to fail over, got %v after %d calls", err, second)
+	}
+
+	f = NewFailover(
+		Backend{Name: "first", Provider: failing(context.DeadlineExceeded, &first)},
+		Backend{Name: "second", Provider: failing(&APIError{Status: 429, Message: "slow down"}, &second)},
+	)
+	if _, err := f.Complete(context.Background(), nil, nil); err == nil || second != 1 {
+		t.Errorf("expected every backend to be tried, got %v after %d calls", err, second)
+	}
+}
+
+func TestShouldFailover(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{context.Background(), &APIError{Status: 500, Message: "boom"}, true},
+		{context.Background(), &APIError{Status: 429, Message: "rate limited"}, true},
+		{context.Background(), &APIError{Status: 401, Message: "unauthorized"}, false},
+		{context.Background(), fmt.Errorf("sending request: %w", context.DeadlineExceeded), true},
+		{context.Background(), errors.New("parsing response: bad json"), false},
+		{context.Background(), errors.New("invalid JSON from upstream (status 500)"), false},
+		{canceled, &APIError{Status: 500, Message: "boom"}, false},
+	} {
+		if got := ShouldFailover(tc.ctx, tc.err); got != tc.want {
+			t.Errorf("ShouldFailover(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package llm
+
+import "strings"
+
+// StripFence removes a markdown code fence around JSON, which models often
+// add even when asked for bare JSON.
+func StripFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "```"), "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 && !strings.ContainsAny(s[:i], "{[\"") {
+		s = s[i+1:] // language tag such as "json"
+	}
+	return strings.TrimSpace(s)
+}
+
+// UnwrapFence returns the body of a reply that is a single fenced code
+// block, and any other reply trimmed of surrounding whitespace.
+func UnwrapFence(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") || len(trimmed) < 6 {
+		return trimmed
+	}
+	body := trimmed[3 : len(trimmed)-3]
+	nl := strings.IndexByte(body, '\n')
+	if nl < 0 || strings.Contains(body, "```") {
+		return trimmed
+	}
+	// Drop the info string (e.g. "json") on the opening line.
+	return strings.TrimSpace(body[nl+1:])
+}
@@ -0,0 +1,31 @@
+package llm
+
+import "testing"
+
+func TestStripFence(t *testing.T) {
+	for in, want := range map[string]string{
+		`{"a":1}`:                 `{"a":1}`,
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"```\n[1,2]\n```":         `[1,2]`,
+		"```{\"a\":1}```":         `{"a":1}`,
+		"  plain text  ":          "plain text",
+		"```":                     "```",
+	} {
+		if got := StripFence(in); got != want {
+			t.Errorf("StripFence(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUnwrapFence(t *testing.T) {
+	for in, want := range map[string]string{
+		"```go\nfmt.Println()\n```": "fmt.Println()",
+		"```inline```":              "```inline```",
+		"```a\n```\nmid\n```b\n```": "```a\n```\nmid\n```b\n```",
+		" reply ":                   "reply",
+	} {
+		if got := UnwrapFence(in); got != want {
+			t.Errorf("UnwrapFence(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,17 +1,22 @@
 package openai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/user/gopherclaw/pkg/llm"
 )
 
+// providerName is reported in llm.Response.Provider.
+const providerName = "openai"
+
 // Client implements the llm.Provider interface for OpenAI-compatible APIs.
 type Client struct {
 	config     *llm.Config
@@ -35,6 +40,14 @@ type chatRequest struct {
 	Tools       []llm.Tool       `json:"tools,omitempty"`
 	MaxTokens   int              `json:"max_tokens,omitempty"`
 	Temperature *float32         `json:"temperature,omitempty"`
+
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamOptions asks for a final chunk with token usage when streaming.
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // requestMessage is the OpenAI message format for requests.
@@ -47,13 +60,15 @@ type requestMessage struct {
 
 // chatResponse is the OpenAI chat completions response body.
 type chatResponse struct {
+	Model   string        `json:"model"`
 	Choices []choice      `json:"choices"`
 	Usage   responseUsage `json:"usage"`
 }
 
 // choice represents a single completion choice.
 type choice struct {
-	Message responseMessage `json:"message"`
+	Message      responseMessage `json:"message"`
+	FinishReason string          `json:"finish_reason"`
 }
 
 // responseMessage is the OpenAI message format in responses.
@@ -61,6 +76,10 @@ type responseMessage struct {
 	Role      string         `json:"role"`
 	Content   string         `json:"content"`
 	ToolCalls []llm.ToolCall `json:"tool_calls,omitempty"`
+	// ReasoningContent (DeepSeek) and Reasoning (OpenRouter) carry the
+	// reasoning trace of reasoning models.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
 }
 
 // responseUsage is the OpenAI token usage format.
@@ -70,8 +89,8 @@ type responseUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-// Complete sends a chat completion request and returns the full response.
-func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+// newRequest builds a chat completions request for messages and tools.
+func (c *Client) newRequest(ctx context.Context, messages []llm.Message, tools []llm.Tool, stream bool) (*http.Request, error) {
 	reqMessages := make([]requestMessage, len(messages))
 	for i, msg := range messages {
 		rm := requestMessage{
@@ -104,6 +123,11 @@ func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []l
 		reqBody.Temperature = &temp
 	}
 
+	if stream {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
@@ -117,10 +141,16 @@ func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []l
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	return req, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// Complete sends a chat completion request and returns the full response.
+func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	resp, err := c.send(ctx, c.httpClient, func() (*http.Request, error) {
+		return c.newRequest(ctx, messages, tools, false)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("sending request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -130,7 +160,7 @@ func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []l
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, &llm.APIError{Status: resp.StatusCode, Message: string(respBody)}
 	}
 
 	var chatResp chatResponse
@@ -143,32 +173,181 @@ func (c *Client) Complete(ctx context.Context, messages []llm.Message, tools []l
 	}
 
 	choice := chatResp.Choices[0]
+	model := chatResp.Model
+	if model == "" {
+		model = c.config.Model
+	}
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+	content := choice.Message.Content
+	if reasoning == "" {
+		reasoning, content = splitThink(content)
+	}
 	return &llm.Response{
-		Content:   choice.Message.Content,
+		Content:   content,
 		ToolCalls: choice.Message.ToolCalls,
 		Usage: llm.Usage{
 			InputTokens:  chatResp.Usage.PromptTokens,
 			OutputTokens: chatResp.Usage.CompletionTokens,
 			TotalTokens:  chatResp.Usage.TotalTokens,
 		},
+		Provider:     providerName,
+		Model:        model,
+		FinishReason: choice.FinishReason,
+		Reasoning:    reasoning,
 	}, nil
 }
 
-// Stream sends a chat completion request and returns a channel of incremental deltas.
-// In v1, this is a simple wrapper over Complete that sends the complete response as a
-// single delta, then closes the channel.
+// splitThink separates a leading <think>...</think> block, which some hosts
+// of open reasoning models (e.g. DeepSeek-R1) leave inline in the content.
+func splitThink(content string) (reasoning, rest string) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if !strings.HasPrefix(trimmed, "<think>") {
+		return "", content
+	}
+	end := strings.Index(trimmed, "</think>")
+	if end < 0 {
+		return "", content
+	}
+	reasoning = strings.TrimSpace(trimmed[len("<think>"):end])
+	rest = strings.TrimSpace(trimmed[end+len("</think>"):])
+	return reasoning, rest
+}
+
+// streamChunk is one server-sent event of a streamed chat completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *responseUsage `json:"usage"`
+	Model string         `json:"model"`
+}
+
+// Stream sends a chat completion request with stream:true and returns a
+// channel of incremental deltas parsed from the server-sent events. Errors
+// before the first byte of the body are returned directly; later ones end
+// the stream with a Delta carrying Err. Cancelling ctx aborts the request
+// and closes the channel.
 func (c *Client) Stream(ctx context.Context, messages []llm.Message, tools []llm.Tool) (<-chan llm.Delta, error) {
-	resp, err := c.Complete(ctx, messages, tools)
+	// The client timeout covers reading the whole body, which would cut
+	// long generations short; the stream is bounded by ctx instead.
+	client := *c.httpClient
+	client.Timeout = 0
+	resp, err := c.send(ctx, &client, func() (*http.Request, error) {
+		req, err := c.newRequest(ctx, messages, tools, true)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	ch := make(chan llm.Delta, 1)
-	ch <- llm.Delta{
-		Content:   resp.Content,
-		ToolCalls: resp.ToolCalls,
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &llm.APIError{Status: resp.StatusCode, Message: string(respBody)}
 	}
-	close(ch)
 
+	ch := make(chan llm.Delta)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		send := func(d llm.Delta) bool {
+			select {
+			case ch <- d:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := readStream(resp.Body, send); err != nil && ctx.Err() == nil {
+			send(llm.Delta{Err: err})
+		}
+	}()
 	return ch, nil
 }
+
+// maxStreamLine bounds a single server-sent event line.
+const maxStreamLine = 1 << 20
+
+// readStream parses server-sent events from r and passes each chunk to
+// send as a Delta until the [DONE] event, the end of the body, or send
+// reports that the consumer has gone.
+func readStream(r io.Reader, send func(llm.Delta) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// Blank separators, comments and other SSE fields.
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("parsing stream chunk: %w", err)
+		}
+		var d llm.Delta
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
+			d.Content = choice.Delta.Content
+			d.Reasoning = choice.Delta.ReasoningContent
+			if d.Reasoning == "" {
+				d.Reasoning = choice.Delta.Reasoning
+			}
+			for _, tc := range choice.Delta.ToolCalls {
+				d.ToolCalls = append(d.ToolCalls, llm.ToolCall{
+					Index: tc.Index,
+					ID:    tc.ID,
+					Type:  tc.Type,
+					Function: llm.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: json.RawMessage(tc.Function.Arguments),
+					},
+				})
+			}
+			d.FinishReason = choice.FinishReason
+		}
+		if chunk.Usage != nil {
+			d.Usage = &llm.Usage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:  chunk.Usage.TotalTokens,
+			}
+		}
+		if d.Content == "" && d.Reasoning == "" && len(d.ToolCalls) == 0 && d.FinishReason == "" && d.Usage == nil {
+			continue
+		}
+		d.Provider, d.Model = providerName, chunk.Model
+		if !send(d) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading stream: %w", err)
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/user/gopherclaw/pkg/llm"
 )
@@ -18,12 +21,14 @@ func TestOpenAIClient(t *testing.T) {
 		}
 
 		resp := map[string]any{
+			"model": "gpt-3.5-turbo-0125",
 			"choices": []map[string]any{
 				{
 					"message": map[string]any{
 						"role":    "assistant",
 						"content": "test response",
 					},
+					"finish_reason": "stop",
 				},
 			},
 			"usage": map[string]any{
@@ -64,6 +69,15 @@ func TestOpenAIClient(t *testing.T) {
 	if resp.Usage.TotalTokens != 15 {
 		t.Errorf("expected 15 total tokens, got %d", resp.Usage.TotalTokens)
 	}
+	if resp.Provider != "openai" {
+		t.Errorf("expected provider 'openai', got %q", resp.Provider)
+	}
+	if resp.Model != "gpt-3.5-turbo-0125" {
+		t.Errorf("expected model 'gpt-3.5-turbo-0125', got %q", resp.Model)
+	}
+	if resp.FinishReason != "stop" {
+		t.Errorf("expected finish reason 'stop', got %q", resp.FinishReason)
+	}
 }
 
 func TestOpenAIClientRequestFormat(t *testing.T) {
@@ -217,41 +231,117 @@ func TestOpenAIClientAPIError(t *testing.T) {
 }
 
 func TestOpenAIClientStream(t *testing.T) {
+	var got chatRequest
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		resp := map[string]any{
-			"choices": []map[string]any{
-				{"message": map[string]any{"role": "assistant", "content": "streamed response"}},
-			},
-			"usage": map[string]any{
-				"prompt_tokens":     5,
-				"completion_tokens": 3,
-				"total_tokens":      8,
-			},
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
 		}
-		json.NewEncoder(w).Encode(resp)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"model":"gpt-4-0613","choices":[{"delta":{"role":"assistant","reasoning_content":"Think."}}]}`,
+			`{"choices":[{"delta":{"content":"Checking "}}]}`,
+			`{"choices":[{"delta":{"content":"both."}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, ": keep-alive\n\ndata: [DONE]\n\n")
 	}))
 	defer server.Close()
 
-	config := &llm.Config{
-		BaseURL: server.URL,
-		APIKey:  "key",
-		Model:   "gpt-4",
+	client := New(&llm.Config{BaseURL: server.URL, APIKey: "key", Model: "gpt-4"})
+	stream, err := client.Stream(context.Background(), []llm.Message{{Role: "user", Content: "hello"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deltas []llm.Delta
+	for d := range stream {
+		deltas = append(deltas, d)
+	}
+	if !got.Stream || got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+		t.Errorf("expected a streaming request with usage, got %+v", got)
+	}
+	if len(deltas) != 9 || deltas[1].Content != "Checking " {
+		t.Fatalf("expected one delta per chunk, got %+v", deltas)
 	}
-	client := New(config)
 
-	stream, err := client.Stream(context.Background(), []llm.Message{
-		{Role: "user", Content: "hello"},
-	}, nil)
+	ch := make(chan llm.Delta, len(deltas))
+	for _, d := range deltas {
+		ch <- d
+	}
+	close(ch)
+	resp, err := llm.Collect(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Checking both." || resp.Reasoning != "Think." || resp.FinishReason != "tool_calls" || resp.Usage.TotalTokens != 8 ||
+		resp.Provider != "openai" || resp.Model != "gpt-4-0613" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(resp.ToolCalls) != 2 || resp.ToolCalls[0].ID != "call_1" || string(resp.ToolCalls[0].Function.Arguments) != `{"city":"Oslo"}` ||
+		resp.ToolCalls[1].Function.Name != "time" {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestOpenAIClientStreamErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/limited/") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"rate limited"}`))
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: {not json\n\n")
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL + "/limited", Model: "gpt-4"})
+	if _, err := client.Stream(context.Background(), nil, nil); err == nil || !strings.Contains(err.Error(), "status 429") {
+		t.Fatalf("expected the API error up front, got %v", err)
+	}
+
+	client = New(&llm.Config{BaseURL: server.URL, Model: "gpt-4"})
+	stream, err := client.Stream(context.Background(), nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := llm.Collect(stream); err == nil || !strings.Contains(err.Error(), "parsing stream chunk") {
+		t.Fatalf("expected the malformed chunk to end the stream, got %v", err)
+	}
+}
 
-	var content string
-	for delta := range stream {
-		content += delta.Content
+func TestOpenAIClientStreamCancel(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(done)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := New(&llm.Config{BaseURL: server.URL, Model: "gpt-4"})
+	stream, err := client.Stream(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if content != "streamed response" {
-		t.Errorf("expected 'streamed response', got %q", content)
+	if d := <-stream; d.Content != "Hi" {
+		t.Fatalf("expected the first chunk, got %+v", d)
+	}
+	cancel()
+	for range stream {
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not aborted on cancel")
 	}
 }
 
@@ -259,3 +349,55 @@ func TestOpenAIClientProviderInterface(t *testing.T) {
 	// Verify Client satisfies the llm.Provider interface at compile time.
 	var _ llm.Provider = (*Client)(nil)
 }
+
+func TestOpenAIClientReasoning(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   map[string]any
+		content   string
+		reasoning string
+	}{
+		{
+			name:      "reasoning_content field",
+			message:   map[string]any{"role": "assistant", "content": "42", "reasoning_content": "6 times 7"},
+			content:   "42",
+			reasoning: "6 times 7",
+		},
+		{
+			name:      "reasoning field",
+			message:   map[string]any{"role": "assistant", "content": "42", "reasoning": "6 times 7"},
+			content:   "42",
+			reasoning: "6 times 7",
+		},
+		{
+			name:      "inline think block",
+			message:   map[string]any{"role": "assistant", "content": "<think>\n6 times 7\n</think>\n\n42"},
+			content:   "42",
+			reasoning: "6 times 7",
+		},
+		{
+			name:    "no reasoning",
+			message: map[string]any{"role": "assistant", "content": "I <think> this is fine"},
+			content: "I <think> this is fine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{
+					"choices": []map[string]any{{"message": tt.message, "finish_reason": "stop"}},
+				})
+			}))
+			defer server.Close()
+
+			client := New(&llm.Config{BaseURL: server.URL, Model: "deepseek-reasoner"})
+			resp, err := client.Complete(context.Background(), []llm.Message{{Role: "user", Content: "q"}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != tt.content || resp.Reasoning != tt.reasoning {
+				t.Errorf("got content=%q reasoning=%q, want content=%q reasoning=%q", resp.Content, resp.Reasoning, tt.content, tt.reasoning)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Retries of rate-limited requests wait for the time the API asks for, or
+// back off exponentially from rateLimitBackoff if it doesn't say. A request
+// asked to wait longer than maxRateLimitWait fails instead.
+const (
+	rateLimitBackoff = time.Second
+	maxRateLimitWait = 30 * time.Second
+)
+
+// send sends the request newReq builds, retrying it up to
+// config.MaxRetries times while the API answers 429. Any other response is
+// returned for the caller to handle; running out of retries, or a 429 that
+// waiting can't fix, returns a *llm.RateLimitError.
+func (c *Client) send(ctx context.Context, client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("sending request: %w", err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		rlErr := rateLimitError(resp.Header, body)
+
+		if attempt >= c.config.MaxRetries || !rlErr.Temporary() {
+			return nil, rlErr
+		}
+		wait := rlErr.RetryAfter
+		if wait == 0 {
+			wait = rateLimitBackoff << attempt
+		}
+		if wait > maxRateLimitWait {
+			return nil, rlErr
+		}
+		slog.WarnContext(ctx, "llm rate limited, retrying", "provider", providerName, "attempt", attempt+1, "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, rlErr
+		}
+	}
+}
+
+// rateLimitError builds the error for a 429 response from its headers and
+// body.
+func rateLimitError(h http.Header, body []byte) *llm.RateLimitError {
+	e := &llm.RateLimitError{Provider: providerName, Body: string(body), RetryAfter: retryAfter(h)}
+	var apiErr struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		e.Quota = apiErr.Error.Type == "insufficient_quota" || apiErr.Error.Code == "insufficient_quota"
+	}
+	return e
+}
+
+// retryAfter returns how long h asks to wait: retry-after-ms, then
+// Retry-After in seconds or as a date, then the reset time of whichever
+// x-ratelimit limit is used up (the later one if both or neither say so).
+func retryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.Atoi(h.Get("Retry-After-Ms")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0)
+		}
+	}
+
+	var wait time.Duration
+	anyOut := h.Get("X-Ratelimit-Remaining-Requests") == "0" || h.Get("X-Ratelimit-Remaining-Tokens") == "0"
+	for _, limit := range []string{"Requests", "Tokens"} {
+		if anyOut && h.Get("X-Ratelimit-Remaining-"+limit) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(h.Get("X-Ratelimit-Reset-" + limit)); err == nil && d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
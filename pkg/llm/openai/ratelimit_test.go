@@ -0,0 +1,117 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+func TestOpenAIClientRateLimitRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After-Ms", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"message":"slow down","type":"requests"}}`))
+			return
+		}
+		if r.Header.Get("Accept") == "text/event-stream" {
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	client := New(&llm.Config{BaseURL: server.URL, Model: "gpt-4", MaxRetries: 2})
+	resp, err := client.Complete(context.Background(), nil, nil)
+	if err != nil || resp.Content != "Hi" || calls.Load() != 3 {
+		t.Fatalf("expected success on the third attempt, got %+v, %v after %d calls", resp, err, calls.Load())
+	}
+
+	calls.Store(0)
+	stream, err := client.Stream(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := llm.Collect(stream); err != nil || resp.Content != "Hi" || calls.Load() != 3 {
+		t.Fatalf("expected the stream to start on the third attempt, got %+v, %v after %d calls", resp, err, calls.Load())
+	}
+
+	calls.Store(0)
+	client = New(&llm.Config{BaseURL: server.URL, Model: "gpt-4", MaxRetries: 1})
+	_, err = client.Complete(context.Background(), nil, nil)
+	var rl *llm.RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter != 10*time.Millisecond || !rl.Temporary() || calls.Load() != 2 {
+		t.Fatalf("expected a RateLimitError after one retry, got %v after %d calls", err, calls.Load())
+	}
+	if !llm.ShouldFailover(context.Background(), err) {
+		t.Error("expected a rate limit to fail over")
+	}
+}
+
+func TestOpenAIClientRateLimitNoRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/quota/chat/completions" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"message":"You exceeded your current quota","type":"insufficient_quota"}}`))
+			return
+		}
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	var rl *llm.RateLimitError
+	client := New(&llm.Config{BaseURL: server.URL + "/quota", Model: "gpt-4", MaxRetries: 3})
+	if _, err := client.Complete(context.Background(), nil, nil); !errors.As(err, &rl) || !rl.Quota || rl.Temporary() || calls.Load() != 1 {
+		t.Fatalf("expected an out-of-quota error without retrying, got %v after %d calls", err, calls.Load())
+	}
+
+	calls.Store(0)
+	client = New(&llm.Config{BaseURL: server.URL, Model: "gpt-4", MaxRetries: 3})
+	if _, err := client.Complete(context.Background(), nil, nil); !errors.As(err, &rl) || rl.RetryAfter != 2*time.Minute || calls.Load() != 1 {
+		t.Fatalf("expected a long Retry-After to fail at once, got %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"milliseconds first", map[string]string{"Retry-After-Ms": "250", "Retry-After": "3"}, 250 * time.Millisecond},
+		{"seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{"exhausted tokens", map[string]string{
+			"X-Ratelimit-Remaining-Requests": "40", "X-Ratelimit-Reset-Requests": "8s",
+			"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "1.5s",
+		}, 1500 * time.Millisecond},
+		{"longer reset", map[string]string{"X-Ratelimit-Reset-Requests": "6m0s", "X-Ratelimit-Reset-Tokens": "2s"}, 6 * time.Minute},
+		{"bad value", map[string]string{"Retry-After": "soon"}, 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+		if got := retryAfter(h); got != tt.want {
+			t.Errorf("%s: retryAfter = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	h := http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	if got := retryAfter(h); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter of an HTTP date = %v, want about an hour", got)
+	}
+}
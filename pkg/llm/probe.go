@@ -0,0 +1,20 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Probe sends a minimal completion to check that the provider is reachable,
+// the credentials are accepted, and the model exists. Callers should bound
+// ctx with a short timeout and configure the provider for a tiny response.
+func Probe(ctx context.Context, p Provider) error {
+	resp, err := p.Complete(ctx, []Message{{Role: "user", Content: "ping"}}, nil)
+	if err != nil {
+		return fmt.Errorf("probe completion: %w", err)
+	}
+	if resp == nil {
+		return fmt.Errorf("probe completion: empty response")
+	}
+	return nil
+}
@@ -20,4 +20,7 @@ type Config struct {
 	Model       string
 	MaxTokens   int
 	Temperature float32
+	// MaxRetries is how many times a rate-limited (429) request is retried
+	// inside the client before its llm.RateLimitError is returned.
+	MaxRetries int
 }
@@ -2,6 +2,8 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -110,3 +112,28 @@ func TestMockProviderCustomStream(t *testing.T) {
 		t.Errorf("expected 'hello world!', got %q", accumulated)
 	}
 }
+
+func TestProbe(t *testing.T) {
+	var got []Message
+	ok := &MockProvider{CompleteFunc: func(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+		got = messages
+		if tools != nil {
+			t.Error("probe should not send tools")
+		}
+		return &Response{Content: "pong"}, nil
+	}}
+	if err := Probe(context.Background(), ok); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if len(got) != 1 || got[0].Role != "user" {
+		t.Errorf("expected a single user message, got %+v", got)
+	}
+
+	failing := &MockProvider{CompleteFunc: func(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+		return nil, errors.New("API error (status 401): invalid key")
+	}}
+	err := Probe(context.Background(), failing)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected wrapped provider error, got %v", err)
+	}
+}
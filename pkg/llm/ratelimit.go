@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimitError is a provider's 429 response. Its message keeps the
+// form of an APIError's, and it wraps ErrRateLimited.
+type RateLimitError struct {
+	// Provider is the backend that refused the call, e.g. "openai".
+	Provider string
+	// RetryAfter is how long the provider asked callers to wait, from
+	// Retry-After or its rate-limit reset headers; zero if it didn't say.
+	RetryAfter time.Duration
+	// Quota is set when the account is out of quota or credit rather than
+	// briefly over a rate limit, so waiting won't help.
+	Quota bool
+	// Body is the response body.
+	Body string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("API error (status 429): %s", e.Body)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// Temporary reports whether the call may succeed after waiting.
+func (e *RateLimitError) Temporary() bool {
+	return !e.Quota
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Collect reads a stream of deltas to the end and assembles them into a
+// Response, joining text chunks and the argument fragments of each tool
+// call. It returns the error carried by a failed stream.
+func Collect(deltas <-chan Delta) (*Response, error) {
+	var content, reasoning strings.Builder
+	var calls []ToolCall
+	var args [][]byte
+	resp := &Response{}
+	for d := range deltas {
+		if d.Err != nil {
+			return nil, d.Err
+		}
+		content.WriteString(d.Content)
+		reasoning.WriteString(d.Reasoning)
+		for _, tc := range d.ToolCalls {
+			for len(calls) <= tc.Index {
+				calls = append(calls, ToolCall{Type: "function"})
+				args = append(args, nil)
+			}
+			call := &calls[tc.Index]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			if tc.Function.Name != "" {
+				call.Function.Name = tc.Function.Name
+			}
+			args[tc.Index] = append(args[tc.Index], tc.Function.Arguments...)
+		}
+		if d.FinishReason != "" {
+			resp.FinishReason = d.FinishReason
+		}
+		if d.Usage != nil {
+			resp.Usage = *d.Usage
+		}
+		if d.Provider != "" {
+			resp.Provider = d.Provider
+		}
+		if d.Model != "" {
+			resp.Model = d.Model
+		}
+		if d.Backend != "" {
+			resp.Backend, resp.Failed = d.Backend, d.Failed
+		}
+	}
+
+	resp.Content = content.String()
+	resp.Reasoning = reasoning.String()
+	for i := range calls {
+		calls[i].Function.Arguments = json.RawMessage(args[i])
+		if len(args[i]) == 0 {
+			calls[i].Function.Arguments = json.RawMessage("{}")
+		}
+	}
+	resp.ToolCalls = calls
+	return resp, nil
+}
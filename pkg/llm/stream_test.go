@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	ch := make(chan Delta, 3)
+	ch <- Delta{Content: "Hel", ToolCalls: []ToolCall{{Index: 0, ID: "c1", Function: FunctionCall{Name: "now"}}}}
+	ch <- Delta{Content: "lo", FinishReason: "tool_calls"}
+	ch <- Delta{Usage: &Usage{InputTokens: 2, OutputTokens: 1, TotalTokens: 3}, Provider: "openai", Model: "gpt-4o-2024-08-06"}
+	close(ch)
+
+	resp, err := Collect(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" || resp.FinishReason != "tool_calls" || resp.Usage.TotalTokens != 3 || resp.Provider != "openai" || resp.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Type != "function" || string(resp.ToolCalls[0].Function.Arguments) != "{}" {
+		t.Errorf("expected a call with empty arguments, got %+v", resp.ToolCalls)
+	}
+
+	failed := errors.New("connection reset")
+	ch = make(chan Delta, 2)
+	ch <- Delta{Content: "partial"}
+	ch <- Delta{Err: failed}
+	close(ch)
+	if _, err := Collect(ch); !errors.Is(err, failed) {
+		t.Errorf("expected the stream error, got %v", err)
+	}
+}
@@ -14,6 +14,9 @@ type ToolCall struct {
 	ID       string       `json:"id"`
 	Type     string       `json:"type"`
 	Function FunctionCall `json:"function"`
+	// Index identifies the call a streamed fragment belongs to when a
+	// response holds several. It is only set on Delta tool calls.
+	Index int `json:"-"`
 }
 
 // FunctionCall contains the function name and arguments for a tool call.
@@ -40,6 +43,23 @@ type Response struct {
 	Content   string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	Usage     Usage      `json:"usage"`
+
+	// Provider identifies the backend that produced the response (e.g. "openai").
+	Provider string `json:"provider,omitempty"`
+	// Model is the model name reported by the backend, which may differ from
+	// the requested model when the API resolves aliases.
+	Model string `json:"model,omitempty"`
+	// FinishReason is the backend's reason for ending generation
+	// (e.g. "stop", "tool_calls", "length").
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Reasoning is the model's reasoning trace, for providers that return
+	// one separately from the answer. It is never part of Content.
+	Reasoning string `json:"reasoning,omitempty"`
+	// Backend names the Failover backend that produced the response, and
+	// Failed the backends that failed the call before it. Both are empty
+	// without a Failover.
+	Backend string    `json:"backend,omitempty"`
+	Failed  []Attempt `json:"failed,omitempty"`
 }
 
 // Usage tracks token consumption for a request/response pair.
@@ -49,8 +69,24 @@ type Usage struct {
 	TotalTokens  int `json:"total_tokens"`
 }
 
-// Delta represents an incremental update during streaming.
+// Delta represents an incremental update during streaming. Content and
+// Reasoning are the next chunk of text. Each tool call is a fragment: ID,
+// Type and Name arrive once per call, and Arguments holds the next piece of
+// the arguments text, which is only valid JSON once all pieces for that
+// Index are joined. FinishReason and Usage arrive with the last deltas.
+// Provider and Model may be set on any delta, as in Response.
 type Delta struct {
-	Content   string     `json:"content,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	Reasoning    string     `json:"reasoning,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	Usage        *Usage     `json:"usage,omitempty"`
+	Provider     string     `json:"provider,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	// Backend and Failed are as in Response, sent by a Failover on a
+	// final delta.
+	Backend string    `json:"backend,omitempty"`
+	Failed  []Attempt `json:"failed,omitempty"`
+	// Err ends a stream that failed part way; it is the last delta sent.
+	Err error `json:"-"`
 }
@@ -0,0 +1,289 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/types"
+)
+
+// echoTool returns its text argument.
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echoes the input text" }
+func (echoTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`)
+}
+func (echoTool) Execute(_ context.Context, args json.RawMessage) (string, error) {
+	var p struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return "", err
+	}
+	return p.Text, nil
+}
+
+func TestTelegramConversation(t *testing.T) {
+	h := Start(t)
+	h.LLM.Script(Text("Hi! How can I help?"), Text("You said hello earlier."))
+
+	if got := h.Ask(7, 7, "hello"); got != "Hi! How can I help?" {
+		t.Fatalf("unexpected first reply %q", got)
+	}
+	if got := h.Ask(7, 7, "what did I say?"); got != "You said hello earlier." {
+		t.Fatalf("unexpected second reply %q", got)
+	}
+
+	key := TelegramKey(7, 7)
+	want := []string{"user_message", "usage", "assistant_message", "user_message", "usage", "assistant_message"}
+	if got := h.EventTypes(key); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events %v", got)
+	}
+
+	// The second request carries the first exchange as history.
+	reqs := h.LLM.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 LLM requests, got %d", len(reqs))
+	}
+	var history []string
+	for _, m := range reqs[1].Messages {
+		history = append(history, m.Content)
+	}
+	joined := strings.Join(history, "\n")
+	if !strings.Contains(joined, "hello") || !strings.Contains(joined, "Hi! How can I help?") {
+		t.Errorf("expected history in second request, got %q", joined)
+	}
+
+	var p struct {
+		Metadata *types.InboundMeta `json:"metadata"`
+	}
+	json.Unmarshal(h.SessionEvents(key)[0].Payload, &p)
+	if p.Metadata == nil || p.Metadata.ChatID != "7" {
+		t.Errorf("expected Telegram metadata on user_message, got %+v", p.Metadata)
+	}
+}
+
+func TestToolRoundTrip(t *testing.T) {
+	h := Start(t, WithTool(echoTool{}))
+	h.LLM.Script(
+		CallTool("echo", map[string]string{"text": "ping"}),
+		Text("The echo said ping."),
+	)
+
+	if got := h.Ask(8, 8, "echo ping"); got != "The echo said ping." {
+		t.Fatalf("unexpected reply %q", got)
+	}
+
+	want := []string{"user_message", "usage", "tool_call", "tool_result", "usage", "assistant_message"}
+	if got := h.EventTypes(TelegramKey(8, 8)); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events %v", got)
+	}
+
+	reqs := h.LLM.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 LLM requests, got %d", len(reqs))
+	}
+	if !contains(reqs[0].Tools, "echo") {
+		t.Errorf("expected echo offered to the model, got %v", reqs[0].Tools)
+	}
+	if last := reqs[1].Last(); last.Role != "tool" || last.Content != "ping" {
+		t.Errorf("expected tool result in follow-up request, got %+v", last)
+	}
+}
+
+func TestTelegramStreamingEditsOneMessage(t *testing.T) {
+	h := Start(t, WithTool(echoTool{}), WithStreaming())
+	h.LLM.Script(
+		Reply{Content: "Let me check.", ToolCalls: CallTool("echo", map[string]string{"text": "ping"}).ToolCalls},
+		Text("The echo said ping."),
+	)
+
+	h.SendTelegram(9, 9, "echo ping")
+	deadline := time.Now().Add(DefaultTimeout)
+	sent := h.Telegram.WaitForMessages(9, 1, DefaultTimeout)
+	for sent[0].ParseMode == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		sent = h.Telegram.Sent(9)
+	}
+	h.WaitIdle()
+
+	sent = h.Telegram.Sent(9)
+	if len(sent) != 1 {
+		t.Fatalf("expected one streamed message, got %+v", sent)
+	}
+	if sent[0].Text != "The echo said ping." || sent[0].ParseMode != "Markdown" || sent[0].Edits == 0 {
+		t.Errorf("expected the placeholder edited into the final reply, got %+v", sent[0])
+	}
+}
+
+func TestProviderErrorIsRecorded(t *testing.T) {
+	h := Start(t)
+	h.LLM.Script(Fail(http.StatusInternalServerError, "upstream exploded"))
+
+	reply := h.Ask(9, 9, "hello")
+	if !strings.Contains(reply, "went wrong") {
+		t.Errorf("expected apology, got %q", reply)
+	}
+
+	events := h.SessionEvents(TelegramKey(9, 9))
+	last := events[len(events)-1]
+	if last.Type != "error" || !strings.Contains(string(last.Payload), "upstream exploded") {
+		t.Errorf("expected error event, got %s %s", last.Type, last.Payload)
+	}
+}
+
+func TestWebhookTask(t *testing.T) {
+	h := Start(t, WithTask(&state.Task{Name: "hook", Prompt: "check the thing", SessionKey: "http:hook", Enabled: true}))
+	h.LLM.Script(Text("thing checked"))
+
+	var resp struct {
+		Response string `json:"response"`
+	}
+	if code := h.PostJSON("/webhook/hook", map[string]string{"prompt": "check it now"}, &resp); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.Response != "thing checked" {
+		t.Errorf("unexpected response %q", resp.Response)
+	}
+	if last := h.LLM.Requests()[0].Last(); !strings.Contains(last.Content, "check it now") {
+		t.Errorf("expected body prompt sent to the model, got %q", last.Content)
+	}
+
+	task, err := h.Tasks.Get("hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.LastRun == nil || task.LastRun.Trigger != "webhook" {
+		t.Errorf("expected webhook run recorded, got %+v", task.LastRun)
+	}
+}
+
+func TestTelegramPollerRestart(t *testing.T) {
+	h := Start(t)
+	h.LLM.Script(Text("first"), Text("second"))
+
+	if got := h.Ask(6, 6, "one"); got != "first" {
+		t.Fatalf("unexpected reply %q", got)
+	}
+	// The watchdog's restart replaces the poll loop; nothing is lost or
+	// handled twice.
+	if err := h.Adapter.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Ask(6, 6, "two"); got != "second" {
+		t.Fatalf("unexpected reply after restart %q", got)
+	}
+	if n := len(h.LLM.Requests()); n != 2 {
+		t.Errorf("expected 2 LLM requests, got %d", n)
+	}
+	if time.Since(h.Adapter.LastPoll()) > time.Minute {
+		t.Errorf("poller reports no recent poll: %v", h.Adapter.LastPoll())
+	}
+}
+
+func TestScheduledTaskDeliversToTelegram(t *testing.T) {
+	h := Start(t, WithTask(&state.Task{
+		Name:       "tick",
+		Prompt:     "say tick",
+		Schedule:   "* * * * * *", // every second
+		SessionKey: string(TelegramKey(5, 5)),
+		Enabled:    true,
+	}))
+	// Enough replies for any fires between the first delivery and shutdown.
+	for i := 0; i < 5; i++ {
+		h.LLM.Script(Text("tick"))
+	}
+
+	sent := h.Telegram.WaitForMessages(5, 1, DefaultTimeout)
+	h.Scheduler.Stop()
+	if sent[0].Text != "tick" {
+		t.Errorf("unexpected delivery %q", sent[0].Text)
+	}
+
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		if task, err := h.Tasks.Get("tick"); err == nil && task.LastRun != nil {
+			if task.LastRun.Trigger != "schedule" {
+				t.Errorf("expected scheduled run, got %+v", task.LastRun)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the scheduled run to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSimulationHasNoSideEffects(t *testing.T) {
+	h := Start(t, WithSimulation(), WithTask(&state.Task{
+		Name:       "remember",
+		Prompt:     "remember my coffee order",
+		Schedule:   "* * * * * *", // every second
+		SessionKey: string(TelegramKey(4, 4)),
+		Enabled:    true,
+	}))
+	h.LLM.Script(
+		CallTool("memory_save", map[string]string{"content": "flat white"}),
+		Text("Saved your coffee order."),
+	)
+	for i := 0; i < 5; i++ {
+		h.LLM.Script(Text("nothing new"))
+	}
+
+	deadline := time.Now().Add(DefaultTimeout)
+	var delivered string
+	for delivered == "" {
+		entries, err := h.Simulation.Entries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Kind == "delivery" && e.SessionKey == TelegramKey(4, 4) {
+				delivered = e.Message
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a logged delivery, got %+v", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Scheduler.Stop()
+	h.WaitIdle()
+
+	if delivered != "Saved your coffee order." {
+		t.Errorf("unexpected delivery %q", delivered)
+	}
+	entries, _ := h.Simulation.Entries()
+	if entries[0].Kind != "tool" || entries[0].Tool != "memory_save" {
+		t.Errorf("expected the memory_save call recorded first, got %+v", entries[0])
+	}
+	if data, _ := os.ReadFile(filepath.Join(h.DataDir, "memory.md")); strings.Contains(string(data), "flat white") {
+		t.Errorf("memory_save ran in simulation: %q", data)
+	}
+	if sent := h.Telegram.Sent(4); len(sent) != 0 {
+		t.Errorf("simulation sent to Telegram: %+v", sent)
+	}
+}
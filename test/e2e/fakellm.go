@@ -0,0 +1,216 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/user/gopherclaw/pkg/llm"
+)
+
+// Reply is one scripted response of the fake LLM.
+type Reply struct {
+	Content   string
+	ToolCalls []llm.ToolCall
+	// Status, when non-zero, makes the server fail the request with this
+	// HTTP status and Content as the body.
+	Status int
+}
+
+// Text is a plain assistant reply.
+func Text(content string) Reply {
+	return Reply{Content: content}
+}
+
+// CallTool is a reply requesting a single tool call with the given
+// arguments, which are marshaled to JSON.
+func CallTool(name string, args any) Reply {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		panic(fmt.Sprintf("marshal tool args: %v", err))
+	}
+	return Reply{ToolCalls: []llm.ToolCall{{
+		Type:     "function",
+		Function: llm.FunctionCall{Name: name, Arguments: raw},
+	}}}
+}
+
+// Fail is a reply that fails with the given HTTP status.
+func Fail(status int, body string) Reply {
+	return Reply{Status: status, Content: body}
+}
+
+// LLMRequest is a chat completion request received by the fake LLM.
+type LLMRequest struct {
+	Model    string
+	Messages []LLMMessage
+	// Tools are the names of the tools offered to the model.
+	Tools []string
+}
+
+// LLMMessage is one message of a recorded request.
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Last returns the final message of the request.
+func (r LLMRequest) Last() LLMMessage {
+	if len(r.Messages) == 0 {
+		return LLMMessage{}
+	}
+	return r.Messages[len(r.Messages)-1]
+}
+
+// FakeLLM is an OpenAI-compatible chat completions server that answers
+// from a script, in order, and records every request.
+type FakeLLM struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	script   []Reply
+	requests []LLMRequest
+	calls    int
+}
+
+// NewFakeLLM starts a fake LLM server, closed when the test ends.
+func NewFakeLLM(t testing.TB) *FakeLLM {
+	f := &FakeLLM{t: t}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// URL is the base URL to configure as the provider's llm.base_url.
+func (f *FakeLLM) URL() string {
+	return f.srv.URL
+}
+
+// Script queues replies. Each request consumes the next one; a request
+// with nothing queued fails the test.
+func (f *FakeLLM) Script(replies ...Reply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, replies...)
+}
+
+// Requests returns the requests received so far.
+func (f *FakeLLM) Requests() []LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]LLMRequest(nil), f.requests...)
+}
+
+// Pending returns how many scripted replies have not been used.
+func (f *FakeLLM) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.script)
+}
+
+func (f *FakeLLM) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/chat/completions" {
+		http.NotFound(w, r)
+		return
+	}
+	var body struct {
+		Model    string       `json:"model"`
+		Messages []LLMMessage `json:"messages"`
+		Tools    []llm.Tool   `json:"tools"`
+		Stream   bool         `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request body", http.StatusBadRequest)
+		return
+	}
+	req := LLMRequest{Model: body.Model, Messages: body.Messages}
+	for _, tool := range body.Tools {
+		req.Tools = append(req.Tools, tool.Function.Name)
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	if len(f.script) == 0 {
+		f.mu.Unlock()
+		f.t.Errorf("fake llm: unscripted request (last message %q)", req.Last().Content)
+		http.Error(w, "fake llm: script exhausted", http.StatusInternalServerError)
+		return
+	}
+	reply := f.script[0]
+	f.script = f.script[1:]
+	f.calls++
+	call := f.calls
+	f.mu.Unlock()
+
+	if reply.Status != 0 {
+		http.Error(w, reply.Content, reply.Status)
+		return
+	}
+
+	finish := "stop"
+	toolCalls := make([]llm.ToolCall, len(reply.ToolCalls))
+	for i, tc := range reply.ToolCalls {
+		if tc.ID == "" {
+			tc.ID = fmt.Sprintf("call_%d_%d", call, i)
+		}
+		toolCalls[i] = tc
+		finish = "tool_calls"
+	}
+	if body.Stream {
+		f.stream(w, body.Model, reply.Content, toolCalls, finish)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"model": body.Model,
+		"choices": []map[string]any{{
+			"message": map[string]any{
+				"role":       "assistant",
+				"content":    reply.Content,
+				"tool_calls": toolCalls,
+			},
+			"finish_reason": finish,
+		}},
+		"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+}
+
+// stream answers a stream:true request with server-sent events: the
+// content a word at a time, then the tool calls, finish reason and usage.
+func (f *FakeLLM) stream(w http.ResponseWriter, model, content string, toolCalls []llm.ToolCall, finish string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	event := func(delta map[string]any, finishReason any, usage any) {
+		raw, _ := json.Marshal(map[string]any{
+			"model":   model,
+			"choices": []map[string]any{{"delta": delta, "finish_reason": finishReason}},
+			"usage":   usage,
+		})
+		fmt.Fprintf(w, "data: %s\n\n", raw)
+		if fl, ok := w.(http.Flusher); ok {
+			fl.Flush()
+		}
+	}
+	if content != "" {
+		for _, word := range strings.SplitAfter(content, " ") {
+			event(map[string]any{"content": word}, nil, nil)
+		}
+	}
+	for i, tc := range toolCalls {
+		event(map[string]any{"tool_calls": []map[string]any{{
+			"index": i,
+			"id":    tc.ID,
+			"type":  "function",
+			"function": map[string]any{
+				"name":      tc.Function.Name,
+				"arguments": string(tc.Function.Arguments),
+			},
+		}}}, nil, nil)
+	}
+	event(map[string]any{}, finish, map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
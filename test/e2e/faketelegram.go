@@ -0,0 +1,217 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FakeToken is the bot token the harness uses against the fake Telegram
+// server.
+const FakeToken = "e2e-token"
+
+// pollWait is how long getUpdates holds a request open when there are no
+// updates, standing in for Telegram's long polling.
+const pollWait = 100 * time.Millisecond
+
+// SentMessage is a message the bot sent through the fake Telegram API.
+type SentMessage struct {
+	ChatID    int64
+	MessageID int
+	Text      string
+	ParseMode string
+	// Edits counts editMessageText calls; Text and ParseMode are the
+	// latest version.
+	Edits int
+}
+
+// FakeTelegram is a minimal Telegram Bot API server: it serves queued
+// updates to getUpdates and records what the bot sends, edits and deletes.
+type FakeTelegram struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	updates  []tgbotapi.Update
+	nextID   int
+	sent     []SentMessage
+	incoming chan struct{}
+}
+
+// NewFakeTelegram starts a fake Telegram API server, closed when the test
+// ends.
+func NewFakeTelegram(t testing.TB) *FakeTelegram {
+	f := &FakeTelegram{t: t, nextID: 1, incoming: make(chan struct{}, 1)}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// Endpoint is the API endpoint format string for
+// telegram.NewWithAPIEndpoint.
+func (f *FakeTelegram) Endpoint() string {
+	return f.srv.URL + "/bot%s/%s"
+}
+
+// SendMessage queues a text message from a user in a chat, as if typed in
+// Telegram, and returns its message ID. Text starting with "/" is marked
+// as a bot command.
+func (f *FakeTelegram) SendMessage(userID, chatID int64, text string) int {
+	f.mu.Lock()
+	id := f.nextID
+	f.nextID++
+	msg := &tgbotapi.Message{
+		MessageID: id,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User" + strconv.FormatInt(userID, 10)},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		length := len(text)
+		if i := strings.IndexByte(text, ' '); i >= 0 {
+			length = i
+		}
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	f.updates = append(f.updates, tgbotapi.Update{UpdateID: id, Message: msg})
+	f.mu.Unlock()
+
+	select {
+	case f.incoming <- struct{}{}:
+	default:
+	}
+	return id
+}
+
+// Sent returns the messages the bot has sent to a chat.
+func (f *FakeTelegram) Sent(chatID int64) []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []SentMessage
+	for _, m := range f.sent {
+		if m.ChatID == chatID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// WaitForMessages waits until the bot has sent at least n messages to a
+// chat and returns them, failing the test after timeout.
+func (f *FakeTelegram) WaitForMessages(chatID int64, n int, timeout time.Duration) []SentMessage {
+	f.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		sent := f.Sent(chatID)
+		if len(sent) >= n {
+			return sent
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("timed out waiting for %d messages to chat %d, got %d", n, chatID, len(sent))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (f *FakeTelegram) handle(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+FakeToken {
+		writeTelegram(w, false, nil)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTelegram(w, false, nil)
+		return
+	}
+
+	switch parts[1] {
+	case "getMe":
+		writeTelegram(w, true, tgbotapi.User{ID: 1, IsBot: true, FirstName: "gopherclaw", UserName: "gopherclaw_bot"})
+	case "getUpdates":
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		writeTelegram(w, true, f.pending(r, offset))
+	case "sendMessage":
+		chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+		msg := SentMessage{ChatID: chatID, Text: r.FormValue("text"), ParseMode: r.FormValue("parse_mode")}
+		f.mu.Lock()
+		msg.MessageID = f.nextID
+		f.nextID++
+		f.sent = append(f.sent, msg)
+		f.mu.Unlock()
+		writeTelegram(w, true, tgbotapi.Message{
+			MessageID: msg.MessageID,
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			Date:      int(time.Now().Unix()),
+			Text:      msg.Text,
+		})
+	case "editMessageText":
+		chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+		id, _ := strconv.Atoi(r.FormValue("message_id"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i := range f.sent {
+			if m := &f.sent[i]; m.ChatID == chatID && m.MessageID == id {
+				m.Text, m.ParseMode = r.FormValue("text"), r.FormValue("parse_mode")
+				m.Edits++
+				writeTelegram(w, true, tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Text: m.Text})
+				return
+			}
+		}
+		writeTelegram(w, false, nil)
+	case "deleteMessage":
+		chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+		id, _ := strconv.Atoi(r.FormValue("message_id"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, m := range f.sent {
+			if m.ChatID == chatID && m.MessageID == id {
+				f.sent = append(f.sent[:i], f.sent[i+1:]...)
+				break
+			}
+		}
+		writeTelegram(w, true, true)
+	default: // sendChatAction and anything else the bot may call
+		writeTelegram(w, true, true)
+	}
+}
+
+// pending returns updates at or after offset, holding the request open for
+// up to pollWait when there are none.
+func (f *FakeTelegram) pending(r *http.Request, offset int) []tgbotapi.Update {
+	timer := time.NewTimer(pollWait)
+	defer timer.Stop()
+	for {
+		f.mu.Lock()
+		var out []tgbotapi.Update
+		for _, u := range f.updates {
+			if u.UpdateID >= offset {
+				out = append(out, u)
+			}
+		}
+		f.mu.Unlock()
+		if len(out) > 0 {
+			return out
+		}
+		select {
+		case <-f.incoming:
+		case <-timer.C:
+			return []tgbotapi.Update{}
+		case <-r.Context().Done():
+			return []tgbotapi.Update{}
+		}
+	}
+}
+
+func writeTelegram(w http.ResponseWriter, ok bool, result any) {
+	raw, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"ok": ok, "result": json.RawMessage(raw)})
+}
@@ -0,0 +1,366 @@
+// Package e2e boots the daemon's wiring — stores, gateway, runtime,
+// scheduler, Telegram adapter and webhook server — against a scripted fake
+// LLM and a fake Telegram API, so tests can drive whole conversations and
+// assert on what was stored and sent.
+//
+// The wiring mirrors serve in cmd/gopherclaw/serve.go; keep the two
+// in step when either changes.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ctxengine "github.com/user/gopherclaw/internal/context"
+	"github.com/user/gopherclaw/internal/delivery"
+	"github.com/user/gopherclaw/internal/gateway"
+	"github.com/user/gopherclaw/internal/pipeline"
+	"github.com/user/gopherclaw/internal/runtime"
+	"github.com/user/gopherclaw/internal/runtime/tools"
+	"github.com/user/gopherclaw/internal/scheduler"
+	"github.com/user/gopherclaw/internal/simulate"
+	"github.com/user/gopherclaw/internal/state"
+	"github.com/user/gopherclaw/internal/telegram"
+	"github.com/user/gopherclaw/internal/types"
+	"github.com/user/gopherclaw/internal/webhook"
+	"github.com/user/gopherclaw/pkg/llm"
+	"github.com/user/gopherclaw/pkg/llm/openai"
+)
+
+// DefaultTimeout bounds the harness's waits.
+const DefaultTimeout = 10 * time.Second
+
+// fakeModel is the model name sent to the fake LLM.
+const fakeModel = "gpt-4o"
+
+// Harness is a running daemon wired to fakes.
+type Harness struct {
+	t testing.TB
+
+	DataDir  string
+	LLM      *FakeLLM
+	Telegram *FakeTelegram
+
+	Sessions  *state.SessionStore
+	Events    *state.EventStore
+	Artifacts *state.ArtifactStore
+	Tasks     *state.TaskStore
+
+	Gateway   *gateway.Gateway
+	Runtime   *runtime.Runtime
+	Scheduler *scheduler.Scheduler
+	// Adapter is nil in simulation mode.
+	Adapter *telegram.Adapter
+	// Simulation holds what simulation mode recorded; nil outside it.
+	Simulation *simulate.Recorder
+	// HTTP serves the webhook server (debug UI, API, webhooks).
+	HTTP *httptest.Server
+}
+
+type options struct {
+	tasks         []*state.Task
+	tools         []runtime.Tool
+	maxToolRounds int
+	simulate      bool
+	streaming     bool
+}
+
+// Option configures a Harness.
+type Option func(*options)
+
+// WithTask adds a task to tasks.json before the scheduler starts.
+func WithTask(task *state.Task) Option {
+	return func(o *options) { o.tasks = append(o.tasks, task) }
+}
+
+// WithTool registers an extra tool alongside the built-in ones.
+func WithTool(tool runtime.Tool) Option {
+	return func(o *options) { o.tools = append(o.tools, tool) }
+}
+
+// WithMaxToolRounds overrides the runtime's tool round limit.
+func WithMaxToolRounds(n int) Option {
+	return func(o *options) { o.maxToolRounds = n }
+}
+
+// WithSimulation runs the harness in simulation mode, as serve --simulate
+// with simulate.model set: the fake LLM still answers, mutating tools only
+// record their calls and deliveries go to the simulation log.
+func WithSimulation() Option {
+	return func(o *options) { o.simulate = true }
+}
+
+// WithStreaming streams LLM calls and has the Telegram adapter edit a
+// message as the reply arrives, as serve does unless telegram.no_stream is
+// set. Without it replies are sent once complete.
+func WithStreaming() Option {
+	return func(o *options) { o.streaming = true }
+}
+
+// Start boots the daemon wiring in a temporary data directory. Everything
+// is shut down when the test ends.
+func Start(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+	o := options{maxToolRounds: 10}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	h := &Harness{
+		t:        t,
+		DataDir:  t.TempDir(),
+		LLM:      NewFakeLLM(t),
+		Telegram: NewFakeTelegram(t),
+	}
+
+	if _, err := state.CheckIntegrity(h.DataDir); err != nil {
+		t.Fatalf("check integrity: %v", err)
+	}
+	h.Sessions = state.NewSessionStore(h.DataDir)
+	h.Events = state.NewEventStore(h.DataDir)
+	h.Artifacts = state.NewArtifactStore(h.DataDir)
+	h.Tasks = state.NewTaskStore(filepath.Join(h.DataDir, "tasks.json"))
+	for _, task := range o.tasks {
+		if err := h.Tasks.Add(task); err != nil {
+			t.Fatalf("add task %q: %v", task.Name, err)
+		}
+	}
+
+	var provider llm.Provider = openai.New(&llm.Config{
+		BaseURL: h.LLM.URL(),
+		APIKey:  "e2e",
+		Model:   fakeModel,
+	})
+	engine, err := ctxengine.New(fakeModel, 128000, 4096, "")
+	if err != nil {
+		t.Fatalf("create context engine: %v", err)
+	}
+
+	// Built-in tools that don't reach outside the data directory.
+	registry := runtime.NewRegistry()
+	registry.Register(tools.NewNoReply())
+	memoryPath := filepath.Join(h.DataDir, "memory.md")
+	registry.Register(tools.NewMemorySave(memoryPath))
+	registry.Register(tools.NewMemoryDelete(memoryPath))
+	registry.Register(tools.NewMemoryList(memoryPath))
+	reminders := state.NewReminderStore(filepath.Join(h.DataDir, "reminders.json"))
+	registry.Register(tools.NewReminderSet(reminders))
+	registry.Register(tools.NewReminderList(reminders))
+	registry.Register(tools.NewReminderCancel(reminders))
+	for _, tool := range o.tools {
+		registry.Register(tool)
+	}
+	if o.simulate {
+		if h.Simulation, err = simulate.NewRecorder(filepath.Join(h.DataDir, "simulate.jsonl")); err != nil {
+			t.Fatalf("create simulation log: %v", err)
+		}
+		for _, tool := range registry.All() {
+			if gateway.IsMutatingTool(tool.Name()) {
+				registry.Register(h.Simulation.Tool(tool))
+			}
+		}
+	}
+	engine.SetMemoryPath(memoryPath)
+
+	h.Runtime = runtime.New(runtime.SingleModel(provider), engine, h.Sessions, h.Events, h.Artifacts, registry, o.maxToolRounds)
+
+	h.Gateway = gateway.New(h.Sessions, h.Events, h.Artifacts, 2)
+	h.Gateway.Queue.SetProcessor(h.Runtime.ProcessRun)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.Gateway.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		h.Gateway.Stop()
+	})
+
+	var toolNames []string
+	for _, tool := range registry.All() {
+		toolNames = append(toolNames, tool.Name())
+	}
+
+	deliveryReg := delivery.NewRegistry()
+	outbox := delivery.NewOutbox(deliveryReg, state.NewOutboxStore(filepath.Join(h.DataDir, "outbox.json")), 0)
+	var adapter *telegram.Adapter
+	if h.Simulation != nil {
+		deliveryReg.Register("telegram:", h.Simulation.Deliver)
+	} else {
+		adapter, err = telegram.NewWithAPIEndpoint(h.Telegram.Endpoint(), FakeToken, h.Gateway, h.Events, h.Sessions, engine, toolNames, memoryPath)
+		if err != nil {
+			t.Fatalf("create telegram adapter: %v", err)
+		}
+		h.Adapter = adapter
+		adapter.SetArtifactStore(h.Artifacts)
+		adapter.SetReminderStore(reminders)
+		adapter.SetStreaming(o.streaming)
+		go adapter.Start(ctx)
+		deliveryReg.Register("telegram:", func(sessionKey, message string) error {
+			return adapter.SendTo(sessionKey, message)
+		})
+	}
+
+	processEvent := func(event *types.InboundEvent) (*gateway.RunResult, error) {
+		done := make(chan *gateway.RunResult, 1)
+		if err := h.Gateway.HandleInbound(ctx, event, gateway.WithOnResult(func(result *gateway.RunResult) {
+			done <- result
+		})); err != nil {
+			return nil, err
+		}
+		return <-done, nil
+	}
+	processTask := func(sessionKey, prompt string) (string, error) {
+		res, err := processEvent(&types.InboundEvent{
+			Source:     "task",
+			SessionKey: types.SessionKey(sessionKey),
+			UserID:     "system",
+			Text:       prompt,
+		})
+		if err != nil {
+			return "", err
+		}
+		return res.Text, nil
+	}
+
+	h.Scheduler = scheduler.New(h.Tasks, processTask)
+	h.Scheduler.SetDeliverer(func(task *state.Task, response string) error {
+		message, err := delivery.Apply(task, task.SessionKey, response, time.Now())
+		if err != nil {
+			return err
+		}
+		return outbox.Deliver(task.SessionKey, message, "task:"+task.Name)
+	})
+	h.Scheduler.SetReminders(reminders, func(r *state.Reminder) error {
+		if adapter != nil && adapter.SendReminder(r) == nil {
+			return nil
+		}
+		return outbox.Deliver(r.SessionKey, "Reminder: "+r.Text, "reminder:"+r.ID)
+	})
+	pipelines := pipeline.NewStore(filepath.Join(h.DataDir, "pipelines"))
+	h.Scheduler.SetPipelineRunner(func(task *state.Task, llm scheduler.Handler) (string, error) {
+		p, err := pipelines.Get(task.Pipeline)
+		if err != nil {
+			return "", err
+		}
+		return p.Execute(ctx, task.Name, func(prompt string) (string, error) {
+			return llm(task.SessionKey, prompt)
+		})
+	})
+	if err := h.Scheduler.Start(); err != nil {
+		t.Fatalf("start scheduler: %v", err)
+	}
+	t.Cleanup(h.Scheduler.Stop)
+
+	srv := webhook.NewServer(h.Tasks, processTask, h.Sessions, h.Events, h.Artifacts)
+	srv.SetRunHandler(processEvent)
+	srv.SetScheduler(h.Scheduler)
+	srv.SetToolNames(toolNames)
+	h.HTTP = httptest.NewServer(srv)
+	t.Cleanup(h.HTTP.Close)
+
+	return h
+}
+
+// SendTelegram sends a message to the bot as a Telegram user.
+func (h *Harness) SendTelegram(userID, chatID int64, text string) {
+	h.Telegram.SendMessage(userID, chatID, text)
+}
+
+// WaitForReply waits for the bot's next message to a chat, counting the
+// messages it had already sent before, and returns its text.
+func (h *Harness) WaitForReply(chatID int64, already int) string {
+	h.t.Helper()
+	sent := h.Telegram.WaitForMessages(chatID, already+1, DefaultTimeout)
+	return sent[already].Text
+}
+
+// Ask sends a Telegram message and returns the bot's reply.
+func (h *Harness) Ask(userID, chatID int64, text string) string {
+	h.t.Helper()
+	already := len(h.Telegram.Sent(chatID))
+	h.SendTelegram(userID, chatID, text)
+	return h.WaitForReply(chatID, already)
+}
+
+// WaitIdle waits for every queued run to finish.
+func (h *Harness) WaitIdle() {
+	h.t.Helper()
+	if !h.Gateway.Queue.WaitIdle(DefaultTimeout) {
+		h.t.Fatal("timed out waiting for runs to finish")
+	}
+}
+
+// Session returns the session currently bound to key, failing the test if
+// there is none.
+func (h *Harness) Session(key types.SessionKey) *types.SessionIndex {
+	h.t.Helper()
+	all, err := h.Sessions.List(context.Background())
+	if err != nil {
+		h.t.Fatalf("list sessions: %v", err)
+	}
+	for _, sess := range all {
+		if sess.SessionKey == key {
+			return sess
+		}
+	}
+	h.t.Fatalf("no session for key %s", key)
+	return nil
+}
+
+// SessionEvents returns all events of the session bound to key.
+func (h *Harness) SessionEvents(key types.SessionKey) []*types.Event {
+	h.t.Helper()
+	ctx := context.Background()
+	sess := h.Session(key)
+	count, err := h.Events.Count(ctx, sess.SessionID)
+	if err != nil {
+		h.t.Fatalf("count events: %v", err)
+	}
+	events, err := h.Events.Tail(ctx, sess.SessionID, int(count))
+	if err != nil {
+		h.t.Fatalf("read events: %v", err)
+	}
+	return events
+}
+
+// EventTypes returns the types of the events of the session bound to key,
+// in order.
+func (h *Harness) EventTypes(key types.SessionKey) []string {
+	h.t.Helper()
+	var out []string
+	for _, ev := range h.SessionEvents(key) {
+		out = append(out, ev.Type)
+	}
+	return out
+}
+
+// PostJSON posts body as JSON to the webhook server and decodes the JSON
+// response into out, if non-nil. It returns the status code.
+func (h *Harness) PostJSON(path string, body, out any) int {
+	h.t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatalf("marshal body: %v", err)
+	}
+	resp, err := http.Post(h.HTTP.URL+path, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		h.t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			h.t.Fatalf("decode %s response: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// TelegramKey is the session key the adapter uses for a user in a chat.
+func TelegramKey(userID, chatID int64) types.SessionKey {
+	return types.TelegramKey(userID, chatID)
+}
@@ -117,7 +117,7 @@ func TestEndToEndWithRuntime(t *testing.T) {
 	}
 
 	registry := runtime.NewRegistry()
-	rt := runtime.New(provider, engine, sessions, events, artifacts, registry, 10)
+	rt := runtime.New(runtime.SingleModel(provider), engine, sessions, events, artifacts, registry, 10)
 
 	gw := gateway.New(sessions, events, artifacts)
 	gw.Queue.SetProcessor(rt.ProcessRun)